@@ -9,10 +9,15 @@ import (
 	"os"
 	"path/filepath"
 
+	clientkeysaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/client_keys"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	oidcaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/oidc_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mtls"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/secrets"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -89,6 +94,12 @@ func main() {
 	// Parse the command-line flags.
 	flag.Parse()
 
+	// Maintenance subcommands run without loading the server configuration.
+	if args := flag.Args(); len(args) > 0 && args[0] == "conv" {
+		cmd.DoConvCommand(args[1:])
+		return
+	}
+
 	// Core application variables.
 	var err error
 	var cfg *config.Config
@@ -113,6 +124,7 @@ func main() {
 		log.Fatalf("failed to load config: %v", err)
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
+	usage.ConfigureUtilizationWebhook(cfg.UtilizationReport)
 
 	if err = logging.ConfigureLogOutput(cfg.LoggingToFile); err != nil {
 		log.Fatalf("failed to configure log output: %v", err)
@@ -129,16 +141,46 @@ func main() {
 		cfg.AuthDir = resolvedAuthDir
 	}
 
+	if err = authcrypt.Configure(cfg.AuthEncryption); err != nil {
+		log.Fatalf("failed to configure auth file encryption: %v", err)
+	}
+	if err = mtls.ConfigureOutbound(cfg.UpstreamTLS); err != nil {
+		log.Fatalf("failed to configure upstream TLS: %v", err)
+	}
+
+	if args := flag.Args(); len(args) > 0 && args[0] == "trash" {
+		cmd.DoTrashCommand(cfg, args[1:])
+		return
+	}
+	if args := flag.Args(); len(args) > 0 && args[0] == "import-cookies" {
+		cmd.DoImportCookiesCommand(cfg, args[1:])
+		return
+	}
+	if args := flag.Args(); len(args) > 0 && args[0] == "rewrite-auth-files" {
+		cmd.DoRewriteAuthFilesCommand(cfg)
+		return
+	}
+
 	// Create login options to be used in authentication flows.
 	options := &cmd.LoginOptions{
 		NoBrowser: noBrowser,
 	}
 
 	// Register the shared token store once so all components use the same persistence backend.
-	sdkAuth.RegisterTokenStore(sdkAuth.NewFileTokenStore())
+	secretBackend, errBackend := secrets.New(cfg.AuthStore)
+	if errBackend != nil {
+		log.Fatalf("failed to configure auth store: %v", errBackend)
+	}
+	if secretBackend != nil {
+		sdkAuth.RegisterTokenStore(sdkAuth.NewSecretTokenStore(secretBackend))
+	} else {
+		sdkAuth.RegisterTokenStore(sdkAuth.NewFileTokenStore())
+	}
 
 	// Register built-in access providers before constructing services.
 	configaccess.Register()
+	clientkeysaccess.Register()
+	oidcaccess.Register()
 
 	// Handle different command modes based on the provided flags.
 
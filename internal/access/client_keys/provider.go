@@ -0,0 +1,100 @@
+package clientkeysaccess
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/clientkeys"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+var registerOnce sync.Once
+
+// Register ensures the managed client key provider is available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(clientkeys.AccessProviderType, newProvider)
+	})
+}
+
+// provider authenticates requests against the shared client key store, reading the key from
+// the same places as the config-api-key provider.
+type provider struct {
+	name string
+}
+
+func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	name := cfg.Name
+	if name == "" {
+		name = clientkeys.AccessProviderName
+	}
+	return &provider{name: name}, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return clientkeys.AccessProviderName
+	}
+	return p.name
+}
+
+func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil || !clientkeys.Default().Enabled() {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	queryKey := ""
+	if r.URL != nil {
+		queryKey = r.URL.Query().Get("key")
+	}
+	candidates := []struct {
+		value  string
+		source string
+	}{
+		{extractBearerToken(r.Header.Get("Authorization")), "authorization"},
+		{r.Header.Get("X-Goog-Api-Key"), "x-goog-api-key"},
+		{r.Header.Get("X-Api-Key"), "x-api-key"},
+		{queryKey, "query-key"},
+	}
+
+	missing := true
+	for _, candidate := range candidates {
+		if candidate.value == "" {
+			continue
+		}
+		missing = false
+		key, ok := clientkeys.Default().Lookup(candidate.value)
+		if !ok {
+			continue
+		}
+		metadata := map[string]string{
+			"source": candidate.source,
+			"key_id": key.ID,
+		}
+		if key.Owner != "" {
+			metadata["owner"] = key.Owner
+		}
+		return &sdkaccess.Result{
+			Provider:  p.Identifier(),
+			Principal: candidate.value,
+			Metadata:  metadata,
+		}, nil
+	}
+	if missing {
+		return nil, sdkaccess.ErrNoCredentials
+	}
+	return nil, sdkaccess.ErrInvalidCredential
+}
+
+func extractBearerToken(header string) string {
+	if header == "" {
+		return ""
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return header
+	}
+	return strings.TrimSpace(parts[1])
+}
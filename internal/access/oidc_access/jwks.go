@@ -0,0 +1,206 @@
+package oidcaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minUnknownKeyRefresh bounds how often a token signed with an unknown key may trigger a
+// refresh, so that forged key IDs cannot make the provider hammer the issuer.
+const minUnknownKeyRefresh = time.Minute
+
+// keySet fetches and caches the issuer's JSON Web Key Set.
+type keySet struct {
+	client  *http.Client
+	issuer  string
+	jwksURL string
+	refresh time.Duration
+
+	// fetchMu serialises fetches; mu guards the cached keys.
+	fetchMu   sync.Mutex
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// key returns the public key kid, fetching the key set when it is stale or does not hold
+// kid. Tokens without a kid are accepted when the set holds a single key.
+func (s *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.RLock()
+	key, found := s.lookupLocked(kid)
+	fresh := time.Since(s.fetchedAt) < s.refresh
+	recent := time.Since(s.fetchedAt) < minUnknownKeyRefresh
+	s.mu.RUnlock()
+	if found && fresh {
+		return key, nil
+	}
+	if !found && recent {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+	// Another request may have refreshed the set while this one waited.
+	s.mu.RLock()
+	refetched := time.Since(s.fetchedAt) < minUnknownKeyRefresh
+	key, found = s.lookupLocked(kid)
+	s.mu.RUnlock()
+	if !refetched {
+		keys, err := s.fetch(ctx)
+		if err != nil {
+			if found {
+				// Keep using the stale set while the issuer is unreachable.
+				return key, nil
+			}
+			return nil, err
+		}
+		s.mu.Lock()
+		s.keys = keys
+		s.fetchedAt = time.Now()
+		key, found = s.lookupLocked(kid)
+		s.mu.Unlock()
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (s *keySet) lookupLocked(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (s *keySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := s.jwksURL
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := s.getJSON(ctx, strings.TrimSuffix(s.issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("discovery: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("discovery document has no jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := s.getJSON(ctx, jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks holds no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (s *keySet) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %d", url, resp.StatusCode)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// jwk is one JSON Web Key (RFC 7517) of type RSA, EC or OKP (Ed25519).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var validate ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, validate = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, validate = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, validate = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != size || len(y) != size {
+			return nil, errors.New("invalid EC key")
+		}
+		// ecdh rejects points that are not on the curve.
+		if _, err := validate.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, errors.New("invalid EC key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,314 @@
+// Package oidcaccess authenticates client requests with bearer JWTs issued by an OpenID
+// Connect provider. Signing keys are discovered from the issuer and cached; token claims can
+// select a quota tier and restrict the models the caller may use.
+package oidcaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/clientkeys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// AccessProviderType is the access provider type validating OIDC tokens.
+	AccessProviderType = "oidc"
+	// AccessProviderName names the access provider added when oidc is enabled.
+	AccessProviderName = "oidc"
+
+	// PrincipalPrefix is put in front of the principal claim so that token principals cannot
+	// collide with API keys.
+	PrincipalPrefix = "oidc:"
+)
+
+var registerOnce sync.Once
+
+// Register ensures the OIDC provider is available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(AccessProviderType, newProvider)
+	})
+}
+
+// ProviderConfig returns the access provider entry for cfg, carrying cfg as its options so
+// that configuration changes rebuild the provider. It returns nil when oidc is disabled.
+func ProviderConfig(cfg config.OIDCConfig) *sdkconfig.AccessProvider {
+	if !cfg.Enabled {
+		return nil
+	}
+	var options map[string]any
+	if data, err := json.Marshal(cfg); err == nil {
+		_ = json.Unmarshal(data, &options)
+	}
+	return &sdkconfig.AccessProvider{Name: AccessProviderName, Type: AccessProviderType, Config: options}
+}
+
+type provider struct {
+	name string
+	cfg  config.OIDCConfig
+	keys *keySet
+	skew time.Duration
+}
+
+func newProvider(entry *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	var cfg config.OIDCConfig
+	data, err := json.Marshal(entry.Config)
+	if err == nil {
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid config: %w", err)
+	}
+	cfg.Issuer = strings.TrimSpace(cfg.Issuer)
+	if cfg.Issuer == "" {
+		return nil, errors.New("oidc: issuer is required")
+	}
+	if strings.TrimSpace(cfg.PrincipalClaim) == "" {
+		cfg.PrincipalClaim = "sub"
+	}
+	refresh := time.Duration(cfg.JWKSRefreshMinutes) * time.Minute
+	if refresh <= 0 {
+		refresh = time.Hour
+	}
+	skew := time.Duration(cfg.ClockSkewSeconds) * time.Second
+	if cfg.ClockSkewSeconds <= 0 {
+		skew = time.Minute
+	}
+	name := entry.Name
+	if name == "" {
+		name = AccessProviderName
+	}
+	return &provider{
+		name: name,
+		cfg:  cfg,
+		keys: &keySet{
+			client:  &http.Client{Timeout: 10 * time.Second},
+			issuer:  cfg.Issuer,
+			jwksURL: strings.TrimSpace(cfg.JWKSURL),
+			refresh: refresh,
+		},
+		skew: skew,
+	}, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return AccessProviderName
+	}
+	return p.name
+}
+
+// Authenticate validates a JWT in the Authorization header. Bearer values that are not JWTs
+// are left to the other providers.
+func (p *provider) Authenticate(ctx context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "bearer") || strings.Count(token, ".") != 2 {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	claims, err := p.verify(ctx, token, time.Now())
+	if err != nil {
+		log.Debugf("oidc: rejected token: %v", err)
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	subject := claimStrings(claims, p.cfg.PrincipalClaim)
+	if len(subject) == 0 {
+		log.Debugf("oidc: rejected token: missing %s claim", p.cfg.PrincipalClaim)
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	principal := PrincipalPrefix + subject[0]
+	metadata := map[string]string{"source": "authorization", "subject": subject[0]}
+	clientkeys.GrantMetadata(metadata, p.grant(claims))
+	return &sdkaccess.Result{Provider: p.Identifier(), Principal: principal, Metadata: metadata}, nil
+}
+
+// grant maps the token's claims to a quota tier and allowed models.
+func (p *provider) grant(claims []byte) clientkeys.Key {
+	var grant clientkeys.Key
+	if p.cfg.QuotaTierClaim != "" {
+		if tiers := claimStrings(claims, p.cfg.QuotaTierClaim); len(tiers) > 0 {
+			grant.QuotaTier = tiers[0]
+		}
+	}
+	modelsFromClaim := false
+	if p.cfg.ModelsClaim != "" {
+		if value := gjson.GetBytes(claims, p.cfg.ModelsClaim); value.Exists() {
+			modelsFromClaim = true
+			for _, model := range claimStrings(claims, p.cfg.ModelsClaim) {
+				grant.AllowedModels = append(grant.AllowedModels, strings.FieldsFunc(model, func(r rune) bool {
+					return r == ' ' || r == ','
+				})...)
+			}
+		}
+	}
+	for _, rule := range p.cfg.ClaimRules {
+		if !claimContains(claims, rule.Claim, rule.Value) {
+			continue
+		}
+		if grant.QuotaTier == "" {
+			grant.QuotaTier = strings.TrimSpace(rule.QuotaTier)
+		}
+		if !modelsFromClaim {
+			grant.AllowedModels = append(grant.AllowedModels, rule.AllowedModels...)
+		}
+	}
+	return grant
+}
+
+// verify checks the token's signature, issuer, audience and validity window and returns its claims.
+func (p *provider) verify(ctx context.Context, token string, now time.Time) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	rawHeader, errHeader := base64.RawURLEncoding.DecodeString(parts[0])
+	claims, errClaims := base64.RawURLEncoding.DecodeString(parts[1])
+	signature, errSig := base64.RawURLEncoding.DecodeString(parts[2])
+	if errHeader != nil || errClaims != nil || errSig != nil {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, errors.New("malformed header")
+	}
+	key, err := p.keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+	if !gjson.ValidBytes(claims) || !gjson.ParseBytes(claims).IsObject() {
+		return nil, errors.New("malformed claims")
+	}
+
+	if iss := gjson.GetBytes(claims, "iss").String(); iss != p.cfg.Issuer {
+		return nil, fmt.Errorf("issuer %q does not match", iss)
+	}
+	if len(p.cfg.Audiences) > 0 {
+		matched := false
+		for _, aud := range p.cfg.Audiences {
+			if claimContains(claims, "aud", aud) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, errors.New("audience does not match")
+		}
+	}
+	exp := gjson.GetBytes(claims, "exp")
+	if exp.Type != gjson.Number {
+		return nil, errors.New("missing exp claim")
+	}
+	expiry := time.Unix(exp.Int(), 0)
+	if now.After(expiry.Add(p.skew)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf := gjson.GetBytes(claims, "nbf"); nbf.Type == gjson.Number && now.Add(p.skew).Before(time.Unix(nbf.Int(), 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	return claims, nil
+}
+
+// verifySignature checks signature over input with key for the JWS algorithm alg. Symmetric
+// algorithms and "none" are rejected.
+func verifySignature(alg string, key crypto.PublicKey, input, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, input, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		switch alg[0] {
+		case 'R':
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		case 'P':
+			err = rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		default:
+			err = errors.New("key type does not match algorithm")
+		}
+		if err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || len(signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return errors.New("key type does not match algorithm")
+	}
+}
+
+// claimStrings returns the string values of the claim at path, a string or an array.
+func claimStrings(claims []byte, path string) []string {
+	value := gjson.GetBytes(claims, path)
+	var out []string
+	if value.IsArray() {
+		for _, item := range value.Array() {
+			if s := strings.TrimSpace(item.String()); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	if s := strings.TrimSpace(value.String()); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+func claimContains(claims []byte, path, want string) bool {
+	for _, value := range claimStrings(claims, path) {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
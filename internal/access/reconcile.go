@@ -6,6 +6,8 @@ import (
 	"sort"
 	"strings"
 
+	oidcaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/oidc_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/clientkeys"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkConfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -182,6 +184,12 @@ func accessProviderMap(cfg *config.Config) map[string]*sdkConfig.AccessProvider
 			}
 		}
 	}
+	if provider := clientKeysProvider(cfg); provider != nil {
+		result[providerIdentifier(provider)] = provider
+	}
+	if provider := oidcaccess.ProviderConfig(cfg.OIDC); provider != nil {
+		result[providerIdentifier(provider)] = provider
+	}
 	return result
 }
 
@@ -201,9 +209,24 @@ func collectProviderEntries(cfg *config.Config) []*sdkConfig.AccessProvider {
 			entries = append(entries, inline)
 		}
 	}
+	if provider := clientKeysProvider(cfg); provider != nil {
+		entries = append(entries, provider)
+	}
+	if provider := oidcaccess.ProviderConfig(cfg.OIDC); provider != nil {
+		entries = append(entries, provider)
+	}
 	return entries
 }
 
+// clientKeysProvider returns the provider entry for managed client keys, which is added after
+// the configured providers when client-keys is enabled.
+func clientKeysProvider(cfg *config.Config) *sdkConfig.AccessProvider {
+	if cfg == nil || !cfg.ClientKeys.Enabled {
+		return nil
+	}
+	return &sdkConfig.AccessProvider{Name: clientkeys.AccessProviderName, Type: clientkeys.AccessProviderType}
+}
+
 func providerIdentifier(provider *sdkConfig.AccessProvider) string {
 	if provider == nil {
 		return ""
@@ -0,0 +1,325 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+const (
+	mcpProtocolVersion = "2025-03-26"
+
+	// mcpToolsTTL is how long the tool list of an MCP server is reused before it is listed
+	// again.
+	mcpToolsTTL = 5 * time.Minute
+)
+
+// errMCPSessionExpired is returned when the server no longer knows the session, which the
+// streamable HTTP transport signals with 404.
+var errMCPSessionExpired = errors.New("mcp session expired")
+
+// mcpServerEntry is the shared client of one MCP server and its last tool list.
+type mcpServerEntry struct {
+	client *mcpClient
+	tools  []Tool
+	listed time.Time
+}
+
+// mcpServers keeps one initialised client per configured server across agent loop runs,
+// keyed by mcpServerKey.
+var mcpServers = struct {
+	sync.Mutex
+	entries map[string]*mcpServerEntry
+}{entries: make(map[string]*mcpServerEntry)}
+
+// mcpServerKey identifies a server configuration; a changed URL or header gets a new client.
+func mcpServerKey(server sdkconfig.MCPServerConfig) string {
+	keys := make([]string, 0, len(server.Headers))
+	for key := range server.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(server.Name + "\x00" + server.URL)
+	for _, key := range keys {
+		b.WriteString("\x00" + key + "=" + server.Headers[key])
+	}
+	return b.String()
+}
+
+// mcpServerTools returns the tools of server, listing them again once mcpToolsTTL has passed.
+// The client and its session are reused between calls.
+func mcpServerTools(ctx context.Context, server sdkconfig.MCPServerConfig) ([]Tool, error) {
+	key := mcpServerKey(server)
+	mcpServers.Lock()
+	entry, ok := mcpServers.entries[key]
+	if ok && time.Since(entry.listed) < mcpToolsTTL {
+		tools := entry.tools
+		mcpServers.Unlock()
+		return tools, nil
+	}
+	if !ok {
+		entry = &mcpServerEntry{client: newMCPClient(server)}
+	}
+	mcpServers.Unlock()
+
+	tools, err := entry.client.listTools(ctx)
+	mcpServers.Lock()
+	defer mcpServers.Unlock()
+	if err != nil {
+		delete(mcpServers.entries, key)
+		return nil, err
+	}
+	entry.tools, entry.listed = tools, time.Now()
+	mcpServers.entries[key] = entry
+	return tools, nil
+}
+
+// pruneMCPServers drops the clients of servers that are no longer configured.
+func pruneMCPServers(servers []sdkconfig.MCPServerConfig) {
+	keep := make(map[string]struct{}, len(servers))
+	for _, server := range servers {
+		keep[mcpServerKey(server)] = struct{}{}
+	}
+	mcpServers.Lock()
+	defer mcpServers.Unlock()
+	for key := range mcpServers.entries {
+		if _, ok := keep[key]; !ok {
+			delete(mcpServers.entries, key)
+		}
+	}
+}
+
+// mcpClient speaks JSON-RPC to an MCP server over the streamable HTTP transport.
+type mcpClient struct {
+	server sdkconfig.MCPServerConfig
+	http   *http.Client
+	nextID atomic.Int64
+
+	mu        sync.Mutex
+	session   string
+	initiated bool
+}
+
+func newMCPClient(server sdkconfig.MCPServerConfig) *mcpClient {
+	return &mcpClient{server: server, http: &http.Client{Timeout: 60 * time.Second}}
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// listTools initialises the session and returns the server's tools, named "<server>__<tool>".
+func (c *mcpClient) listTools(ctx context.Context) ([]Tool, error) {
+	if err := c.initialize(ctx); err != nil {
+		return nil, err
+	}
+	raw, err := c.call(ctx, "tools/list", map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Tools []struct {
+			Name        string          `json:"name"`
+			Description string          `json:"description"`
+			InputSchema json.RawMessage `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("decode tools/list: %w", err)
+	}
+	prefix := strings.TrimSpace(c.server.Name)
+	tools := make([]Tool, 0, len(result.Tools))
+	for _, item := range result.Tools {
+		remote := item.Name
+		name := remote
+		if prefix != "" {
+			name = prefix + "__" + remote
+		}
+		tools = append(tools, Tool{
+			Name:        name,
+			Description: item.Description,
+			Parameters:  item.InputSchema,
+			Call: func(ctx context.Context, args json.RawMessage) (string, error) {
+				return c.callTool(ctx, remote, args)
+			},
+		})
+	}
+	return tools, nil
+}
+
+func (c *mcpClient) callTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+	params := map[string]any{"name": name, "arguments": args}
+	raw, err := c.call(ctx, "tools/call", params)
+	if errors.Is(err, errMCPSessionExpired) {
+		c.reset()
+		if err = c.initialize(ctx); err != nil {
+			return "", err
+		}
+		raw, err = c.call(ctx, "tools/call", params)
+	}
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return string(raw), nil
+	}
+	var b strings.Builder
+	for _, part := range result.Content {
+		if part.Type != "text" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(part.Text)
+	}
+	if result.IsError {
+		return "", fmt.Errorf("%s", b.String())
+	}
+	return b.String(), nil
+}
+
+func (c *mcpClient) initialize(ctx context.Context) error {
+	c.mu.Lock()
+	done := c.initiated
+	c.mu.Unlock()
+	if done {
+		return nil
+	}
+	_, err := c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "cli-proxy-api", "version": "1"},
+	})
+	if err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	if err = c.notify(ctx, "notifications/initialized"); err != nil {
+		return fmt.Errorf("initialized notification: %w", err)
+	}
+	c.mu.Lock()
+	c.initiated = true
+	c.mu.Unlock()
+	return nil
+}
+
+// reset forgets the session so the next call initialises a new one.
+func (c *mcpClient) reset() {
+	c.mu.Lock()
+	c.session, c.initiated = "", false
+	c.mu.Unlock()
+}
+
+func (c *mcpClient) notify(ctx context.Context, method string) error {
+	resp, err := c.post(ctx, map[string]any{"jsonrpc": "2.0", "method": method})
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (c *mcpClient) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := c.nextID.Add(1)
+	resp, err := c.post(ctx, map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusNotFound && resp.Request.Header.Get("Mcp-Session-Id") != "" {
+		return nil, fmt.Errorf("%s: %w", method, errMCPSessionExpired)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s: status %d: %s", method, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if session := resp.Header.Get("Mcp-Session-Id"); session != "" {
+		c.mu.Lock()
+		c.session = session
+		c.mu.Unlock()
+	}
+
+	var rpc rpcResponse
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		found := false
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			data, ok := strings.CutPrefix(line, "data:")
+			if !ok {
+				continue
+			}
+			var candidate rpcResponse
+			if json.Unmarshal([]byte(strings.TrimSpace(data)), &candidate) != nil || string(candidate.ID) != fmt.Sprint(id) {
+				continue
+			}
+			rpc, found = candidate, true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: no response in event stream", method)
+		}
+	} else if err = json.NewDecoder(resp.Body).Decode(&rpc); err != nil {
+		return nil, fmt.Errorf("%s: decode response: %w", method, err)
+	}
+	if rpc.Error != nil {
+		return nil, fmt.Errorf("%s: %s (code %d)", method, rpc.Error.Message, rpc.Error.Code)
+	}
+	return rpc.Result, nil
+}
+
+func (c *mcpClient) post(ctx context.Context, payload any) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.server.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for key, value := range c.server.Headers {
+		req.Header.Set(key, value)
+	}
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+	return c.http.Do(req)
+}
@@ -0,0 +1,140 @@
+// Package agent provides the tools executed by the server-side agent loop: a small set of
+// built-in tools plus tools discovered on configured MCP servers.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Tool is a function the agent loop can execute on the model's behalf.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments object.
+	Parameters json.RawMessage
+	Call       func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// Toolbox holds the tools available to one agent loop run.
+type Toolbox struct {
+	tools map[string]Tool
+}
+
+// NewToolbox collects the configured built-in tools and the tools of every MCP server. MCP
+// clients and their tool lists are shared between runs; servers that cannot be reached are
+// skipped with a warning.
+func NewToolbox(ctx context.Context, cfg sdkconfig.AgentLoopConfig) *Toolbox {
+	box := &Toolbox{tools: make(map[string]Tool)}
+	for _, tool := range builtinTools() {
+		if len(cfg.BuiltinTools) > 0 && !containsFold(cfg.BuiltinTools, tool.Name) {
+			continue
+		}
+		box.tools[tool.Name] = tool
+	}
+	pruneMCPServers(cfg.MCPServers)
+	for _, server := range cfg.MCPServers {
+		if strings.TrimSpace(server.URL) == "" {
+			continue
+		}
+		listCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		tools, err := mcpServerTools(listCtx, server)
+		cancel()
+		if err != nil {
+			log.Warnf("agent loop: failed to list tools of MCP server %s: %v", server.Name, err)
+			continue
+		}
+		for _, tool := range tools {
+			box.tools[tool.Name] = tool
+		}
+	}
+	return box
+}
+
+// Has reports whether name is executed by the toolbox.
+func (b *Toolbox) Has(name string) bool {
+	_, ok := b.tools[name]
+	return ok
+}
+
+// Call executes the named tool. Tool failures are returned as the result text so the
+// model can react to them.
+func (b *Toolbox) Call(ctx context.Context, name string, args json.RawMessage) string {
+	tool, ok := b.tools[name]
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %q", name)
+	}
+	out, err := tool.Call(ctx, args)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return out
+}
+
+// OpenAITools returns the tool definitions in OpenAI chat completions format.
+func (b *Toolbox) OpenAITools() []map[string]any {
+	names := make([]string, 0, len(b.tools))
+	for name := range b.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		tool := b.tools[name]
+		params := tool.Parameters
+		if len(params) == 0 {
+			params = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out = append(out, map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  params,
+			},
+		})
+	}
+	return out
+}
+
+func builtinTools() []Tool {
+	return []Tool{
+		{
+			Name:        "current_time",
+			Description: "Returns the current date and time. Accepts an optional IANA time zone name.",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"timezone":{"type":"string","description":"IANA time zone, e.g. Europe/Berlin. Defaults to UTC."}}}`),
+			Call: func(_ context.Context, args json.RawMessage) (string, error) {
+				var in struct {
+					Timezone string `json:"timezone"`
+				}
+				if len(args) > 0 {
+					_ = json.Unmarshal(args, &in)
+				}
+				loc := time.UTC
+				if tz := strings.TrimSpace(in.Timezone); tz != "" {
+					var err error
+					if loc, err = time.LoadLocation(tz); err != nil {
+						return "", fmt.Errorf("unknown time zone %q", tz)
+					}
+				}
+				return time.Now().In(loc).Format(time.RFC3339), nil
+			},
+		},
+	}
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
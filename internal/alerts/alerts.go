@@ -0,0 +1,176 @@
+// Package alerts notifies operators of events that need their attention, such as account
+// cookies that expired, through a webhook, email or a Telegram bot.
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// EventCredentialsExpired is sent when an account's credentials were rejected and requests
+// are no longer routed to it until they are replaced.
+const EventCredentialsExpired = "credentials_expired"
+
+const (
+	defaultSMTPPort = 587
+	telegramAPIBase = "https://api.telegram.org"
+)
+
+// Alert is one notification. It is posted as JSON to the webhook and rendered as text for
+// email and Telegram.
+type Alert struct {
+	Event    string    `json:"event"`
+	Provider string    `json:"provider,omitempty"`
+	AuthID   string    `json:"auth_id,omitempty"`
+	Account  string    `json:"account,omitempty"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// Text renders the alert for humans.
+func (a Alert) Text() string {
+	var b strings.Builder
+	b.WriteString("CLIProxyAPI alert: ")
+	b.WriteString(a.Message)
+	if a.Account != "" {
+		fmt.Fprintf(&b, "\nAccount: %s", a.Account)
+	}
+	if a.Provider != "" {
+		fmt.Fprintf(&b, "\nProvider: %s", a.Provider)
+	}
+	if a.AuthID != "" {
+		fmt.Fprintf(&b, "\nAuth: %s", a.AuthID)
+	}
+	fmt.Fprintf(&b, "\nTime: %s", a.Time.UTC().Format(time.RFC3339))
+	return b.String()
+}
+
+type notifier struct {
+	mu     sync.Mutex
+	cfg    config.AlertsConfig
+	client *http.Client
+}
+
+var defaultNotifier = &notifier{client: &http.Client{Timeout: 30 * time.Second}}
+
+// Configure applies the alert channel settings; it is safe to call on every config reload.
+func Configure(cfg config.AlertsConfig) {
+	defaultNotifier.mu.Lock()
+	defaultNotifier.cfg = cfg
+	defaultNotifier.mu.Unlock()
+}
+
+// Send delivers alert to every configured channel in the background. Failed deliveries are
+// logged and not retried.
+func Send(alert Alert) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	n := defaultNotifier
+	n.mu.Lock()
+	cfg := n.cfg
+	n.mu.Unlock()
+	log.Warnf("alert %s: %s", alert.Event, alert.Message)
+	go n.deliver(cfg, alert)
+}
+
+func (n *notifier) deliver(cfg config.AlertsConfig, alert Alert) {
+	if url := strings.TrimSpace(cfg.WebhookURL); url != "" {
+		if err := n.postWebhook(url, cfg.WebhookHeaders, alert); err != nil {
+			log.Warnf("alerts: webhook delivery failed: %v", err)
+		}
+	}
+	if email := cfg.Email; strings.TrimSpace(email.SMTPHost) != "" && len(email.To) > 0 {
+		if err := sendEmail(email, alert); err != nil {
+			log.Warnf("alerts: email delivery failed: %v", err)
+		}
+	}
+	if tg := cfg.Telegram; strings.TrimSpace(tg.BotToken) != "" && strings.TrimSpace(tg.ChatID) != "" {
+		if err := n.sendTelegram(tg, alert); err != nil {
+			log.Warnf("alerts: telegram delivery failed: %v", err)
+		}
+	}
+}
+
+func (n *notifier) postWebhook(url string, headers map[string]string, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return n.do(req)
+}
+
+func (n *notifier) sendTelegram(cfg config.AlertTelegramConfig, alert Alert) error {
+	body, err := json.Marshal(map[string]string{"chat_id": strings.TrimSpace(cfg.ChatID), "text": alert.Text()})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/bot%s/sendMessage", telegramAPIBase, strings.TrimSpace(cfg.BotToken))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return n.do(req)
+}
+
+func (n *notifier) do(req *http.Request) error {
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func sendEmail(cfg config.AlertEmailConfig, alert Alert) error {
+	host := strings.TrimSpace(cfg.SMTPHost)
+	port := cfg.SMTPPort
+	if port <= 0 {
+		port = defaultSMTPPort
+	}
+	from := strings.TrimSpace(cfg.From)
+	if from == "" {
+		from = cfg.Username
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	subject := "CLIProxyAPI alert: " + alert.Event
+	if alert.Account != "" {
+		subject += " (" + alert.Account + ")"
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(alert.Text(), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return smtp.SendMail(net.JoinHostPort(host, strconv.Itoa(port)), auth, from, cfg.To, msg.Bytes())
+}
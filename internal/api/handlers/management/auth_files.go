@@ -20,6 +20,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/codex"
 	geminiAuth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/qwen"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	// legacy client removed
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -111,15 +112,22 @@ func (h *Handler) ListAuthFiles(c *gin.Context) {
 
 			// Read file to get type field
 			full := filepath.Join(h.cfg.AuthDir, name)
-			if data, errRead := os.ReadFile(full); errRead == nil {
-				typeValue := gjson.GetBytes(data, "type").String()
-				fileData["type"] = typeValue
+			if raw, errRead := os.ReadFile(full); errRead == nil {
+				fileData["encrypted"] = authcrypt.IsEncrypted(raw)
+				if data, errOpen := authcrypt.Open(raw); errOpen == nil {
+					typeValue := gjson.GetBytes(data, "type").String()
+					fileData["type"] = typeValue
+				}
 			}
 
 			files = append(files, fileData)
 		}
 	}
-	c.JSON(200, gin.H{"files": files})
+	page, next, ok := paginate(c, files, func(file gin.H) string { return file["name"].(string) })
+	if !ok {
+		return
+	}
+	respondCached(c, withNextCursor(gin.H{"files": page}, next))
 }
 
 // Download single auth file by name
@@ -134,7 +142,7 @@ func (h *Handler) DownloadAuthFile(c *gin.Context) {
 		return
 	}
 	full := filepath.Join(h.cfg.AuthDir, name)
-	data, err := os.ReadFile(full)
+	data, err := authcrypt.ReadFile(full)
 	if err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(404, gin.H{"error": "file not found"})
@@ -166,13 +174,23 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 				dst = abs
 			}
 		}
-		if errSave := c.SaveUploadedFile(file, dst); errSave != nil {
-			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to save file: %v", errSave)})
+		src, errOpen := file.Open()
+		if errOpen != nil {
+			c.JSON(400, gin.H{"error": "failed to read file"})
 			return
 		}
-		data, errRead := os.ReadFile(dst)
+		data, errRead := io.ReadAll(src)
+		_ = src.Close()
 		if errRead != nil {
-			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to read saved file: %v", errRead)})
+			c.JSON(400, gin.H{"error": "failed to read file"})
+			return
+		}
+		if data, errRead = authcrypt.Open(data); errRead != nil {
+			c.JSON(400, gin.H{"error": errRead.Error()})
+			return
+		}
+		if errSave := authcrypt.WriteFile(dst, data, 0o600); errSave != nil {
+			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to save file: %v", errSave)})
 			return
 		}
 		if errReg := h.registerAuthFromFile(ctx, dst, data); errReg != nil {
@@ -202,7 +220,11 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 			dst = abs
 		}
 	}
-	if errWrite := os.WriteFile(dst, data, 0o600); errWrite != nil {
+	if data, err = authcrypt.Open(data); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if errWrite := authcrypt.WriteFile(dst, data, 0o600); errWrite != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to write file: %v", errWrite)})
 		return
 	}
@@ -241,7 +263,7 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 					full = abs
 				}
 			}
-			if err = os.Remove(full); err == nil {
+			if err = h.removeAuthFile(c, full); err == nil {
 				deleted++
 				h.disableAuth(ctx, full)
 			}
@@ -260,7 +282,7 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 			full = abs
 		}
 	}
-	if err := os.Remove(full); err != nil {
+	if err := h.removeAuthFile(c, full); err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(404, gin.H{"error": "file not found"})
 		} else {
@@ -281,7 +303,7 @@ func (h *Handler) registerAuthFromFile(ctx context.Context, path string, data []
 	}
 	if data == nil {
 		var err error
-		data, err = os.ReadFile(path)
+		data, err = authcrypt.ReadFile(path)
 		if err != nil {
 			return fmt.Errorf("failed to read auth file: %w", err)
 		}
@@ -0,0 +1,146 @@
+package management
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/clientkeys"
+)
+
+type clientKeyPayload struct {
+	Name          *string   `json:"name"`
+	Owner         *string   `json:"owner"`
+	QuotaTier     *string   `json:"quota_tier"`
+	AllowedModels *[]string `json:"allowed_models"`
+}
+
+// ListClientKeys returns every managed client key, revoked ones included. Keys are shown by
+// their hint only.
+func (h *Handler) ListClientKeys(c *gin.Context) {
+	store := clientkeys.Default()
+	keys := store.List()
+	out := make([]gin.H, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, clientKeyJSON(key))
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": store.Enabled(), "keys": out})
+}
+
+// CreateClientKey creates a managed client key. The key is returned in the "key" field of
+// this response only.
+func (h *Handler) CreateClientKey(c *gin.Context) {
+	if !h.clientKeysEnabled(c) {
+		return
+	}
+	var body clientKeyPayload
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if body.Name == nil || strings.TrimSpace(*body.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	spec := clientkeys.Spec{Name: *body.Name}
+	if body.Owner != nil {
+		spec.Owner = *body.Owner
+	}
+	if body.QuotaTier != nil {
+		spec.QuotaTier = *body.QuotaTier
+	}
+	if body.AllowedModels != nil {
+		spec.AllowedModels = *body.AllowedModels
+	}
+	key, secret, err := clientkeys.Default().Create(spec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create client key: %v", err)})
+		return
+	}
+	out := clientKeyJSON(key)
+	out["key"] = secret
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "client_key": out})
+}
+
+// UpdateClientKey renames the client key identified by the "id" path parameter or changes its
+// owner, quota tier or allowed models. Omitted fields are left alone.
+func (h *Handler) UpdateClientKey(c *gin.Context) {
+	if !h.clientKeysEnabled(c) {
+		return
+	}
+	var body clientKeyPayload
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if body.Name != nil && strings.TrimSpace(*body.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+		return
+	}
+	key, err := clientkeys.Default().Update(c.Param("id"), clientkeys.Patch{
+		Name:          body.Name,
+		Owner:         body.Owner,
+		QuotaTier:     body.QuotaTier,
+		AllowedModels: body.AllowedModels,
+	})
+	if !h.clientKeyResult(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "client_key": clientKeyJSON(key)})
+}
+
+// RevokeClientKey revokes the client key identified by the "id" path parameter. Requests with
+// the key are rejected from then on; its record and usage are kept.
+func (h *Handler) RevokeClientKey(c *gin.Context) {
+	if !h.clientKeysEnabled(c) {
+		return
+	}
+	key, err := clientkeys.Default().Revoke(c.Param("id"))
+	if !h.clientKeyResult(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "client_key": clientKeyJSON(key)})
+}
+
+func (h *Handler) clientKeysEnabled(c *gin.Context) bool {
+	if clientkeys.Default().Enabled() {
+		return true
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "client-keys is not enabled"})
+	return false
+}
+
+func (h *Handler) clientKeyResult(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, clientkeys.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "client key not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update client key: %v", err)})
+	}
+	return false
+}
+
+func clientKeyJSON(key clientkeys.Key) gin.H {
+	models := key.AllowedModels
+	if models == nil {
+		models = []string{}
+	}
+	out := gin.H{
+		"id":             key.ID,
+		"name":           key.Name,
+		"owner":          key.Owner,
+		"quota_tier":     key.QuotaTier,
+		"allowed_models": models,
+		"hint":           key.Hint,
+		"created_at":     key.CreatedAt,
+		"revoked":        key.Revoked(),
+	}
+	if key.RevokedAt != nil {
+		out["revoked_at"] = *key.RevokedAt
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// resolveGeminiWebAuth selects the Gemini Web auth addressed by the "auth" query parameter,
+// matching by ID, file name or label. Without the parameter the first enabled account is used.
+func (h *Handler) resolveGeminiWebAuth(c *gin.Context) (*coreauth.Auth, bool) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return nil, false
+	}
+	want := strings.TrimSpace(c.Query("auth"))
+	for _, auth := range h.authManager.List() {
+		if auth == nil || auth.Provider != "gemini-web" {
+			continue
+		}
+		if want == "" {
+			if auth.Disabled {
+				continue
+			}
+			return auth, true
+		}
+		if auth.ID == want || filepath.Base(auth.ID) == want || auth.Label == want {
+			return auth, true
+		}
+	}
+	if want == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no gemini web account available"})
+	} else {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("gemini web account not found: %s", want)})
+	}
+	return nil, false
+}
+
+// ListGeminiWebGems returns the Gems available to a Gemini Web account.
+func (h *Handler) ListGeminiWebGems(c *gin.Context) {
+	state, auth, ok := h.geminiWebStateFromQuery(c)
+	if !ok {
+		return
+	}
+	includeHidden := c.Query("include-hidden") == "true"
+	gems, err := state.ListGems(includeHidden)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to fetch gems: %v", err)})
+		return
+	}
+	items := make([]gin.H, 0, len(gems))
+	for _, gem := range gems {
+		items = append(items, gemJSON(gem))
+	}
+	c.JSON(http.StatusOK, gin.H{"auth": filepath.Base(auth.ID), "gems": items})
+}
+
+type gemPayload struct {
+	Name        string `json:"name"`
+	Prompt      string `json:"prompt"`
+	Description string `json:"description"`
+}
+
+// CreateGeminiWebGem creates a custom Gem on a Gemini Web account.
+func (h *Handler) CreateGeminiWebGem(c *gin.Context) {
+	var body gemPayload
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	state, auth, ok := h.geminiWebStateFromQuery(c)
+	if !ok {
+		return
+	}
+	gem, err := state.CreateGem(body.Name, body.Prompt, body.Description)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to create gem: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "auth": filepath.Base(auth.ID), "gem": gemJSON(gem)})
+}
+
+// UpdateGeminiWebGem updates a custom Gem identified by the "id" path parameter.
+func (h *Handler) UpdateGeminiWebGem(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var body gemPayload
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if id == "" || body.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id and name are required"})
+		return
+	}
+	state, auth, ok := h.geminiWebStateFromQuery(c)
+	if !ok {
+		return
+	}
+	gem, err := state.UpdateGem(id, body.Name, body.Prompt, body.Description)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to update gem: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "auth": filepath.Base(auth.ID), "gem": gemJSON(gem)})
+}
+
+// DeleteGeminiWebGem deletes a custom Gem identified by the "id" path parameter.
+func (h *Handler) DeleteGeminiWebGem(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+	state, _, ok := h.geminiWebStateFromQuery(c)
+	if !ok {
+		return
+	}
+	if err := state.DeleteGem(id); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to delete gem: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *Handler) geminiWebStateFromQuery(c *gin.Context) (*geminiwebapi.GeminiWebState, *coreauth.Auth, bool) {
+	auth, ok := h.resolveGeminiWebAuth(c)
+	if !ok {
+		return nil, nil, false
+	}
+	state, err := h.liveGeminiWebState(auth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	return state, auth, true
+}
+
+// liveGeminiWebState returns the session shared with the executor for auth, a copy returned
+// by the auth manager. A session created here is attached to the registered auth; if another
+// caller attached one first, that one is used and ours is closed.
+func (h *Handler) liveGeminiWebState(auth *coreauth.Auth) (*geminiwebapi.GeminiWebState, error) {
+	if state, ok := executor.ExistingGeminiWebState(auth); ok {
+		return state, nil
+	}
+	state, err := executor.GeminiWebStateFor(h.cfg, auth)
+	if err != nil {
+		return nil, err
+	}
+	attached, ok := h.authManager.AttachRuntime(auth.ID, auth.Runtime)
+	if !ok || attached == auth.Runtime {
+		return state, nil
+	}
+	state.Close()
+	if live, exists := executor.ExistingGeminiWebState(&coreauth.Auth{Runtime: attached}); exists {
+		return live, nil
+	}
+	return nil, fmt.Errorf("gemini web session of %s is unavailable", filepath.Base(auth.ID))
+}
+
+func gemJSON(gem geminiwebapi.Gem) gin.H {
+	item := gin.H{"id": gem.ID, "name": gem.Name, "predefined": gem.Predefined}
+	if gem.Description != nil {
+		item["description"] = *gem.Description
+	}
+	if gem.Prompt != nil {
+		item["prompt"] = *gem.Prompt
+	}
+	return item
+}
@@ -0,0 +1,352 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	geminiAuth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListGeminiWebAccounts returns the registered Gemini Web accounts.
+func (h *Handler) ListGeminiWebAccounts(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	accounts := make([]gin.H, 0)
+	for _, auth := range h.authManager.List() {
+		if auth == nil || auth.Provider != "gemini-web" {
+			continue
+		}
+		accounts = append(accounts, geminiWebAccountJSON(auth))
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i]["file"].(string) < accounts[j]["file"].(string) })
+	page, next, ok := paginate(c, accounts, func(account gin.H) string { return account["file"].(string) })
+	if !ok {
+		return
+	}
+	respondCached(c, withNextCursor(gin.H{"accounts": page}, next))
+}
+
+// AddGeminiWebAccount validates a cookie pair with an init handshake, persists it to the auth
+// directory and registers the account so it serves requests without a restart.
+func (h *Handler) AddGeminiWebAccount(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body struct {
+		Secure1PSID   string `json:"secure_1psid"`
+		Secure1PSIDTS string `json:"secure_1psidts"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	body.Secure1PSID = strings.TrimSpace(body.Secure1PSID)
+	body.Secure1PSIDTS = strings.TrimSpace(body.Secure1PSIDTS)
+	if body.Secure1PSID == "" || body.Secure1PSIDTS == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "secure_1psid and secure_1psidts are required"})
+		return
+	}
+
+	proxyURL := ""
+	if h.cfg != nil {
+		proxyURL = h.cfg.ProxyURL
+	}
+	if err := geminiwebapi.ValidateCookies(body.Secure1PSID, body.Secure1PSIDTS, proxyURL); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("cookie validation failed: %v", err)})
+		return
+	}
+
+	fileName := geminiAuth.GeminiWebFileName(body.Secure1PSID)
+	path := filepath.Join(h.cfg.AuthDir, fileName)
+	if _, err := os.Stat(path); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "account already exists", "file": fileName})
+		return
+	}
+	storage := &geminiAuth.GeminiWebTokenStorage{
+		Secure1PSID:   body.Secure1PSID,
+		Secure1PSIDTS: body.Secure1PSIDTS,
+		Label:         strings.TrimSuffix(fileName, ".json"),
+	}
+	if err := storage.SaveTokenToFile(path); err != nil {
+		log.Errorf("failed to save Gemini Web account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save account"})
+		return
+	}
+	auth, err := h.registerGeminiWebAccount(c, fileName, path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "account": geminiWebAccountJSON(auth)})
+}
+
+// ImportGeminiWebAccount creates an account, or renews the cookies of an existing one, from the
+// Google session of a Chrome, Chromium, Edge, Brave or Firefox profile on the machine running
+// the server. The body must set consent to true before any cookie store is read; browser and
+// profile narrow the search and are required when several profiles are signed in.
+func (h *Handler) ImportGeminiWebAccount(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body struct {
+		Browser string `json:"browser"`
+		Profile string `json:"profile"`
+		Label   string `json:"label"`
+		Consent bool   `json:"consent"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if !body.Consent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "consent must be true to read the browser cookie stores"})
+		return
+	}
+
+	sessions, errs := geminiAuth.FindGeminiWebBrowserSessions(strings.TrimSpace(body.Browser), strings.TrimSpace(body.Profile))
+	problems := make([]string, 0, len(errs))
+	for _, err := range errs {
+		problems = append(problems, err.Error())
+	}
+	switch {
+	case len(sessions) == 0:
+		c.JSON(http.StatusNotFound, gin.H{"error": "no browser profile signed in to Google was found", "problems": problems})
+		return
+	case len(sessions) > 1:
+		profiles := make([]browser.Profile, 0, len(sessions))
+		for _, session := range sessions {
+			profiles = append(profiles, session.Profile)
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "several profiles are signed in to Google; choose one with browser and profile", "profiles": profiles})
+		return
+	}
+	session := sessions[0]
+
+	if err := geminiwebapi.ValidateCookies(session.Secure1PSID, session.Secure1PSIDTS, h.cfg.ProxyURL); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("cookie validation failed: %v", err), "profile": session.Profile})
+		return
+	}
+	label := strings.TrimSpace(body.Label)
+	if label == "" {
+		httpClient := util.SetProxy(&h.cfg.SDKConfig, &http.Client{Timeout: 15 * time.Second})
+		if email, err := geminiAuth.FetchGeminiWebAccountEmail(httpClient, session.Cookie); err != nil {
+			log.Debugf("gemini web import: %v", err)
+		} else {
+			label = email
+		}
+	}
+	path, created, err := geminiAuth.SaveGeminiWebBrowserSession(h.cfg.AuthDir, session, label)
+	if err != nil {
+		log.Errorf("failed to save Gemini Web account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save account"})
+		return
+	}
+	fileName := filepath.Base(path)
+	if existing, ok := h.authManager.GetByID(fileName); ok && existing != nil {
+		// Drop the session built from the old cookies.
+		executor.ReleaseGeminiWebState(existing)
+	}
+	auth, err := h.registerGeminiWebAccount(c, fileName, path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Infof("management: imported Gemini Web cookies from %s profile %s into %s", session.Profile.Browser, session.Profile.Name, fileName)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "created": created, "profile": session.Profile, "account": geminiWebAccountJSON(auth)})
+}
+
+// UpdateGeminiWebAccount activates or deactivates an account. The flag is stored in the auth
+// file so it survives restarts; activating also clears an expired-cookies mark.
+func (h *Handler) UpdateGeminiWebAccount(c *gin.Context) {
+	var body struct {
+		Disabled *bool `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Disabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "disabled is required"})
+		return
+	}
+	auth, path, ok := h.geminiWebAccountFromParam(c)
+	if !ok {
+		return
+	}
+	disabled := *body.Disabled
+	if err := updateAuthFileMetadata(path, func(meta map[string]any) {
+		if disabled {
+			meta["disabled"] = true
+		} else {
+			delete(meta, "disabled")
+			delete(meta, "expired")
+		}
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update auth file: %v", err)})
+		return
+	}
+	if auth.Metadata == nil {
+		auth.Metadata = make(map[string]any)
+	}
+	auth.Disabled = disabled
+	auth.UpdatedAt = time.Now()
+	if disabled {
+		auth.Metadata["disabled"] = true
+		auth.Status = coreauth.StatusDisabled
+		auth.StatusMessage = "deactivated via management API"
+		executor.ReleaseGeminiWebState(auth)
+		removeGeminiWebStickyEntries(auth)
+	} else {
+		delete(auth.Metadata, "disabled")
+		delete(auth.Metadata, "expired")
+		auth.Status = coreauth.StatusActive
+		auth.StatusMessage = ""
+	}
+	updated, err := h.authManager.Update(c.Request.Context(), auth)
+	if err != nil || updated == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update account: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "account": geminiWebAccountJSON(updated)})
+}
+
+// DeleteGeminiWebAccount closes the account's session, moves its auth file to the trash (or
+// removes it, see removeAuthFile) and disables it.
+func (h *Handler) DeleteGeminiWebAccount(c *gin.Context) {
+	auth, path, ok := h.geminiWebAccountFromParam(c)
+	if !ok {
+		return
+	}
+	executor.ReleaseGeminiWebState(auth)
+	removeGeminiWebStickyEntries(auth)
+	if err := h.removeAuthFile(c, path); err != nil && !os.IsNotExist(err) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to remove file: %v", err)})
+		return
+	}
+	h.disableAuth(c.Request.Context(), auth.ID)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// registerGeminiWebAccount registers the account under the same ID the auth watcher uses and
+// creates its session state.
+func (h *Handler) registerGeminiWebAccount(c *gin.Context, id, path string) (*coreauth.Auth, error) {
+	data, err := authcrypt.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth file: %w", err)
+	}
+	metadata := make(map[string]any)
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid auth file: %w", err)
+	}
+	now := time.Now()
+	auth := &coreauth.Auth{
+		ID:         id,
+		Provider:   "gemini-web",
+		FileName:   id,
+		Label:      "gemini-web",
+		Status:     coreauth.StatusActive,
+		Attributes: map[string]string{"path": path, "source": path},
+		Metadata:   metadata,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if _, err = executor.GeminiWebStateFor(h.cfg, auth); err != nil {
+		return nil, err
+	}
+	if existing, ok := h.authManager.GetByID(id); ok && existing != nil {
+		auth.CreatedAt = existing.CreatedAt
+		return h.authManager.Update(c.Request.Context(), auth)
+	}
+	return h.authManager.Register(c.Request.Context(), auth)
+}
+
+// geminiWebAccountFromParam resolves the account named by the ":name" path parameter.
+func (h *Handler) geminiWebAccountFromParam(c *gin.Context) (*coreauth.Auth, string, bool) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return nil, "", false
+	}
+	name := strings.TrimSpace(c.Param("name"))
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid name"})
+		return nil, "", false
+	}
+	for _, auth := range h.authManager.List() {
+		if auth == nil || auth.Provider != "gemini-web" {
+			continue
+		}
+		if auth.ID != name && filepath.Base(auth.ID) != name && auth.Label != name {
+			continue
+		}
+		path := ""
+		if auth.Attributes != nil {
+			path = auth.Attributes["path"]
+		}
+		if path == "" {
+			path = filepath.Join(h.cfg.AuthDir, filepath.Base(auth.ID))
+		}
+		return auth, path, true
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("gemini web account not found: %s", name)})
+	return nil, "", false
+}
+
+// updateAuthFileMetadata rewrites the JSON auth file at path after applying mutate.
+func updateAuthFileMetadata(path string, mutate func(map[string]any)) error {
+	data, err := authcrypt.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	meta := make(map[string]any)
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+	mutate(meta)
+	out, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return authcrypt.WriteFile(path, out, 0o600)
+}
+
+func removeGeminiWebStickyEntries(auth *coreauth.Auth) {
+	label := coreauth.GeminiWebAccountLabel(auth)
+	if label == "" {
+		return
+	}
+	if err := conversation.RemoveMatchesByLabel(label); err != nil {
+		log.Debugf("failed to remove gemini web sticky entries for %s: %v", label, err)
+	}
+}
+
+func geminiWebAccountJSON(auth *coreauth.Auth) gin.H {
+	item := gin.H{
+		"id":       auth.ID,
+		"file":     filepath.Base(auth.ID),
+		"label":    auth.Label,
+		"status":   auth.Status,
+		"disabled": auth.Disabled,
+	}
+	if auth.StatusMessage != "" {
+		item["status_message"] = auth.StatusMessage
+	}
+	if !auth.LastRefreshedAt.IsZero() {
+		item["last_refreshed_at"] = auth.LastRefreshedAt
+	}
+	return item
+}
@@ -0,0 +1,52 @@
+package management
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+)
+
+// ListGeminiWebBreakers returns the circuit breaker state of every Gemini Web account.
+// Accounts that have not served a request yet report a closed breaker.
+func (h *Handler) ListGeminiWebBreakers(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	breakers := make([]gin.H, 0)
+	for _, auth := range h.authManager.List() {
+		if auth == nil || auth.Provider != "gemini-web" {
+			continue
+		}
+		status := geminiwebapi.BreakerStatus{State: geminiwebapi.BreakerClosed}
+		if state, ok := executor.ExistingGeminiWebState(auth); ok {
+			status = state.BreakerStatus()
+		}
+		breakers = append(breakers, gin.H{
+			"id":      auth.ID,
+			"file":    filepath.Base(auth.ID),
+			"label":   auth.Label,
+			"breaker": status,
+		})
+	}
+	sort.Slice(breakers, func(i, j int) bool { return breakers[i]["file"].(string) < breakers[j]["file"].(string) })
+	c.JSON(http.StatusOK, gin.H{"breakers": breakers})
+}
+
+// ResetGeminiWebBreaker closes the circuit breaker of one account so it serves requests again.
+func (h *Handler) ResetGeminiWebBreaker(c *gin.Context) {
+	auth, _, ok := h.geminiWebAccountFromParam(c)
+	if !ok {
+		return
+	}
+	status := geminiwebapi.BreakerStatus{State: geminiwebapi.BreakerClosed}
+	if state, exists := executor.ExistingGeminiWebState(auth); exists {
+		state.ResetBreaker()
+		status = state.BreakerStatus()
+	}
+	c.JSON(http.StatusOK, gin.H{"id": auth.ID, "breaker": status})
+}
@@ -0,0 +1,356 @@
+package management
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListGeminiWebConversations returns the conversation records stored for an account.
+func (h *Handler) ListGeminiWebConversations(c *gin.Context) {
+	state, name, ok := h.geminiWebConversationState(c)
+	if !ok {
+		return
+	}
+	page, next, ok := paginate(c, state.Conversations(), conversationCursorKey)
+	if !ok {
+		return
+	}
+	respondCached(c, withNextCursor(gin.H{"account": name, "conversations": page}, next))
+}
+
+// conversationCursorKey orders conversation summaries newest first, ties broken by ID.
+func conversationCursorKey(summary geminiwebapi.ConversationSummary) string {
+	var nanos int64
+	if !summary.UpdatedAt.IsZero() {
+		nanos = summary.UpdatedAt.UnixNano()
+	}
+	return fmt.Sprintf("%020d|%s", math.MaxInt64-nanos, summary.ID)
+}
+
+// GetGeminiWebConversation returns one conversation record with its full message history and
+// the index entries that decide whether requests reuse it.
+func (h *Handler) GetGeminiWebConversation(c *gin.Context) {
+	state, name, ok := h.geminiWebConversationState(c)
+	if !ok {
+		return
+	}
+	detail, err := state.InspectConversation(strings.TrimSpace(c.Param("id")))
+	if err != nil {
+		if errors.Is(err, geminiwebapi.ErrConversationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"account": name, "conversation": detail})
+}
+
+// SearchGeminiWebConversations searches the conversation records of every Gemini Web account,
+// or of ?account=, by ?model=, an RFC 3339 ?since=/?until= range of the last update and a ?q=
+// text substring.
+func (h *Handler) SearchGeminiWebConversations(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	query := geminiwebapi.ConversationQuery{
+		Model: strings.TrimSpace(c.Query("model")),
+		Text:  strings.TrimSpace(c.Query("q")),
+	}
+	for param, dst := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		raw := strings.TrimSpace(c.Query(param))
+		if raw == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s: expected RFC 3339 time", param)})
+			return
+		}
+		*dst = ts
+	}
+	account := strings.TrimSpace(c.Query("account"))
+	results := make([]gin.H, 0)
+	for _, auth := range h.authManager.List() {
+		if auth == nil || auth.Provider != "gemini-web" {
+			continue
+		}
+		name := filepath.Base(auth.ID)
+		if account != "" && auth.ID != account && name != account && auth.Label != account {
+			continue
+		}
+		state, err := h.liveGeminiWebState(auth)
+		if err != nil {
+			log.Debugf("management: skipping gemini web account %s in conversation search: %v", name, err)
+			continue
+		}
+		for _, summary := range state.SearchConversations(query) {
+			results = append(results, gin.H{"account": name, "conversation": summary})
+		}
+	}
+	page, next, ok := paginate(c, results, func(result gin.H) string {
+		return result["account"].(string) + "\x00" + conversationCursorKey(result["conversation"].(geminiwebapi.ConversationSummary))
+	})
+	if !ok {
+		return
+	}
+	respondCached(c, withNextCursor(gin.H{"results": page}, next))
+}
+
+// DeleteGeminiWebConversation removes a conversation record so it is no longer reused. It goes
+// to the trash unless soft delete is disabled or the request asks for ?permanent=true.
+func (h *Handler) DeleteGeminiWebConversation(c *gin.Context) {
+	state, name, ok := h.geminiWebConversationState(c)
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(c.Param("id"))
+	permanent := h.cfg.SoftDelete.Disabled || isTruthy(c.Query("permanent"))
+	actor := managementActor(c)
+	if err := state.DeleteConversation(c.Request.Context(), id, actor, permanent); err != nil {
+		if errors.Is(err, geminiwebapi.ErrConversationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to delete conversation: %v", err)})
+		return
+	}
+	log.Infof("management: gemini web conversation %s of %s deleted by %s (permanent=%t)", id, name, actor, permanent)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// DeleteConversation deletes a Gemini Web conversation everywhere: the chat in the account's
+// Gemini history and every local record of it, bypassing the trash. The account is taken from
+// ?account= or found by searching all Gemini Web accounts for the record.
+func (h *Handler) DeleteConversation(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	id := strings.TrimSpace(c.Param("id"))
+	account := strings.TrimSpace(c.Query("account"))
+	for _, auth := range h.authManager.List() {
+		if auth == nil || auth.Provider != "gemini-web" {
+			continue
+		}
+		name := filepath.Base(auth.ID)
+		if account != "" && auth.ID != account && name != account && auth.Label != account {
+			continue
+		}
+		state, err := h.liveGeminiWebState(auth)
+		if err != nil {
+			if account != "" {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			continue
+		}
+		if !state.HasConversation(id) {
+			continue
+		}
+		actor := managementActor(c)
+		deleted, remote, err := state.DeleteUpstreamConversation(c.Request.Context(), id, actor)
+		if err != nil {
+			if errors.Is(err, geminiwebapi.ErrConversationNotFound) {
+				break
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to delete conversation: %v", err)})
+			return
+		}
+		log.Infof("management: gemini web conversation %s of %s deleted upstream by %s (records=%d, remote=%t)", id, name, actor, deleted, remote)
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "account": name, "deleted_records": deleted, "remote_deleted": remote})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+}
+
+// ListDeletedGeminiWebConversations returns an account's conversations in the trash, purging
+// expired entries first.
+func (h *Handler) ListDeletedGeminiWebConversations(c *gin.Context) {
+	state, name, ok := h.geminiWebConversationState(c)
+	if !ok {
+		return
+	}
+	retention := h.cfg.SoftDelete.Retention()
+	deleted, err := state.DeletedConversations(c.Request.Context(), retention)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read trash: %v", err)})
+		return
+	}
+	items := make([]gin.H, 0, len(deleted))
+	for _, d := range deleted {
+		items = append(items, gin.H{
+			"conversation": d.Summary(),
+			"deleted_at":   d.DeletedAt,
+			"deleted_by":   d.DeletedBy,
+			"expires_at":   d.DeletedAt.Add(retention),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"account": name, "conversations": items})
+}
+
+// RestoreGeminiWebConversation moves a conversation out of the trash so it is reused again.
+func (h *Handler) RestoreGeminiWebConversation(c *gin.Context) {
+	state, name, ok := h.geminiWebConversationState(c)
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(c.Param("id"))
+	if err := state.RestoreConversation(id); err != nil {
+		if errors.Is(err, geminiwebapi.ErrConversationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deleted conversation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to restore conversation: %v", err)})
+		return
+	}
+	log.Infof("management: gemini web conversation %s of %s restored by %s", id, name, managementActor(c))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ReindexGeminiWebConversations rebuilds an account's conversation index from its records with
+// the current hashing rules.
+func (h *Handler) ReindexGeminiWebConversations(c *gin.Context) {
+	state, name, ok := h.geminiWebConversationState(c)
+	if !ok {
+		return
+	}
+	result, err := state.ReindexConversations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to rebuild conversation index: %v", err)})
+		return
+	}
+	log.Infof("management: gemini web conversation index of %s rebuilt by %s (%d entries, %d added, %d removed)",
+		name, managementActor(c), result.IndexEntries, result.Added, result.Removed)
+	c.JSON(http.StatusOK, gin.H{"account": name, "result": result})
+}
+
+// ListGeminiWebConversationSnapshots returns the versions of a conversation, one per turn.
+func (h *Handler) ListGeminiWebConversationSnapshots(c *gin.Context) {
+	state, name, ok := h.geminiWebConversationState(c)
+	if !ok {
+		return
+	}
+	snapshots, err := state.ConversationSnapshots(strings.TrimSpace(c.Param("id")))
+	if err != nil {
+		if errors.Is(err, geminiwebapi.ErrConversationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"account": name, "snapshots": snapshots})
+}
+
+// RestoreGeminiWebConversationTurn rolls a conversation back to the version of a given turn.
+// Later versions go to the trash unless soft delete is disabled or ?permanent=true is set.
+func (h *Handler) RestoreGeminiWebConversationTurn(c *gin.Context) {
+	state, name, ok := h.geminiWebConversationState(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Turn *int `json:"turn"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if body.Turn == nil || *body.Turn < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "turn must be a positive number"})
+		return
+	}
+	id := strings.TrimSpace(c.Param("id"))
+	permanent := h.cfg.SoftDelete.Disabled || isTruthy(c.Query("permanent"))
+	actor := managementActor(c)
+	snapshot, replay, err := state.RestoreConversationTurn(c.Request.Context(), id, *body.Turn, actor, permanent)
+	if err != nil {
+		switch {
+		case errors.Is(err, geminiwebapi.ErrConversationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		case errors.Is(err, geminiwebapi.ErrSnapshotNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no snapshot for turn %d", *body.Turn)})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to restore conversation: %v", err)})
+		}
+		return
+	}
+	log.Infof("management: gemini web conversation %s of %s restored to turn %d by %s", id, name, *body.Turn, actor)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "snapshot": snapshot, "replay": replay})
+}
+
+// ListGeminiWebConversationIDs returns the explicit conversation IDs mapped to upstream
+// conversations, optionally filtered by ?account=.
+func (h *Handler) ListGeminiWebConversationIDs(c *gin.Context) {
+	records, err := conversation.ListConversationIDs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read conversation ids: %v", err)})
+		return
+	}
+	if account := strings.TrimSpace(c.Query("account")); account != "" {
+		account = strings.TrimSuffix(account, filepath.Ext(account))
+		filtered := records[:0]
+		for _, rec := range records {
+			if strings.EqualFold(rec.AccountLabel, account) {
+				filtered = append(filtered, rec)
+			}
+		}
+		records = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{"conversation_ids": records})
+}
+
+// GetGeminiWebConversationID returns one explicit conversation ID mapping by its key.
+func (h *Handler) GetGeminiWebConversationID(c *gin.Context) {
+	rec, found, err := conversation.LookupConversationID(strings.TrimSpace(c.Param("key")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read conversation id: %v", err)})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation id not found"})
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
+
+// DeleteGeminiWebConversationID removes an explicit conversation ID mapping; the next request
+// with that ID starts a new upstream conversation.
+func (h *Handler) DeleteGeminiWebConversationID(c *gin.Context) {
+	key := strings.TrimSpace(c.Param("key"))
+	if err := conversation.DeleteConversationID(key); err != nil {
+		if errors.Is(err, conversation.ErrConversationIDNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversation id not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to delete conversation id: %v", err)})
+		return
+	}
+	log.Infof("management: gemini web conversation id %s deleted by %s", key, managementActor(c))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *Handler) geminiWebConversationState(c *gin.Context) (*geminiwebapi.GeminiWebState, string, bool) {
+	auth, _, ok := h.geminiWebAccountFromParam(c)
+	if !ok {
+		return nil, "", false
+	}
+	state, err := h.liveGeminiWebState(auth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+	return state, filepath.Base(auth.ID), true
+}
@@ -0,0 +1,38 @@
+package management
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+)
+
+// ListGeminiWebQueues returns the request queue of every Gemini Web account: requests in
+// flight, requests waiting and how many were turned away.
+func (h *Handler) ListGeminiWebQueues(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	queues := make([]gin.H, 0)
+	for _, auth := range h.authManager.List() {
+		if auth == nil || auth.Provider != "gemini-web" {
+			continue
+		}
+		var status geminiwebapi.QueueStatus
+		if state, ok := executor.ExistingGeminiWebState(auth); ok {
+			status = state.QueueStatus()
+		}
+		queues = append(queues, gin.H{
+			"id":    auth.ID,
+			"file":  filepath.Base(auth.ID),
+			"label": auth.Label,
+			"queue": status,
+		})
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i]["file"].(string) < queues[j]["file"].(string) })
+	c.JSON(http.StatusOK, gin.H{"queues": queues})
+}
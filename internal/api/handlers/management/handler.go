@@ -36,6 +36,9 @@ type Handler struct {
 	tokenStore     coreauth.Store
 
 	localPassword string
+
+	requestLogsDir string
+	replayTarget   http.Handler
 }
 
 // NewHandler creates a new management handler instance.
@@ -0,0 +1,155 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// GetModelAliases returns the global model aliases, the per-key overrides from api-key-rules
+// and the built-in aliases of providers, which the global aliases override.
+func (h *Handler) GetModelAliases(c *gin.Context) {
+	overrides := make([]gin.H, 0)
+	for _, rule := range h.cfg.APIKeyRules {
+		if len(rule.ModelAliases) > 0 {
+			overrides = append(overrides, gin.H{"api-key": rule.APIKey, "model-aliases": rule.ModelAliases})
+		}
+	}
+	aliases := h.cfg.ModelAliases
+	if aliases == nil {
+		aliases = map[string]string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"model-aliases": aliases, "api-key-overrides": overrides, "builtin": registry.GetGlobalRegistry().BuiltinAliases()})
+}
+
+// PutModelAliases replaces the global aliases, or those of the key named by ?api-key=. The
+// body is an alias-to-model object, optionally wrapped in {"items": {...}}.
+func (h *Handler) PutModelAliases(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	var aliases map[string]string
+	if err = json.Unmarshal(data, &aliases); err != nil {
+		var obj struct {
+			Items map[string]string `json:"items"`
+		}
+		if errObj := json.Unmarshal(data, &obj); errObj != nil || obj.Items == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+		aliases = obj.Items
+	}
+	cleaned, ok := normalizeModelAliases(aliases)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "aliases and models must be non-empty"})
+		return
+	}
+	h.setModelAliases(c.Query("api-key"), func(map[string]string) map[string]string { return cleaned })
+	h.persist(c)
+}
+
+// PatchModelAliases adds or changes one alias: {"alias": "gemini-pro-latest", "model": "gemini-2.5-pro"}.
+func (h *Handler) PatchModelAliases(c *gin.Context) {
+	var body struct {
+		Alias *string `json:"alias"`
+		Model *string `json:"model"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Alias == nil || body.Model == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	alias, model := strings.TrimSpace(*body.Alias), strings.TrimSpace(*body.Model)
+	if alias == "" || model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "alias and model must be non-empty"})
+		return
+	}
+	h.setModelAliases(c.Query("api-key"), func(current map[string]string) map[string]string {
+		next := make(map[string]string, len(current)+1)
+		for k, v := range current {
+			next[k] = v
+		}
+		next[alias] = model
+		return next
+	})
+	h.persist(c)
+}
+
+// DeleteModelAliases removes the alias named by ?alias=, from the key named by ?api-key= if set.
+func (h *Handler) DeleteModelAliases(c *gin.Context) {
+	alias := strings.TrimSpace(c.Query("alias"))
+	if alias == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing alias"})
+		return
+	}
+	apiKey := c.Query("api-key")
+	var current map[string]string
+	if apiKey == "" {
+		current = h.cfg.ModelAliases
+	} else if rule := h.apiKeyRuleFor(apiKey); rule != nil {
+		current = rule.ModelAliases
+	}
+	if _, exists := current[alias]; !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alias not found"})
+		return
+	}
+	h.setModelAliases(apiKey, func(current map[string]string) map[string]string {
+		next := make(map[string]string, len(current))
+		for k, v := range current {
+			if k != alias {
+				next[k] = v
+			}
+		}
+		return next
+	})
+	h.persist(c)
+}
+
+// setModelAliases updates the global aliases when apiKey is empty, otherwise the aliases of
+// that key's rule, which is created when missing. Global changes apply to /v1/models at once.
+func (h *Handler) setModelAliases(apiKey string, update func(map[string]string) map[string]string) {
+	if apiKey == "" {
+		h.cfg.ModelAliases = emptyAliasesToNil(update(h.cfg.ModelAliases))
+		registry.GetGlobalRegistry().SetModelAliases(h.cfg.ModelAliases)
+		return
+	}
+	rule := h.apiKeyRuleFor(apiKey)
+	if rule == nil {
+		h.cfg.APIKeyRules = append(h.cfg.APIKeyRules, sdkconfig.APIKeyRule{APIKey: apiKey})
+		rule = &h.cfg.APIKeyRules[len(h.cfg.APIKeyRules)-1]
+	}
+	rule.ModelAliases = emptyAliasesToNil(update(rule.ModelAliases))
+}
+
+func (h *Handler) apiKeyRuleFor(apiKey string) *sdkconfig.APIKeyRule {
+	for i := range h.cfg.APIKeyRules {
+		if h.cfg.APIKeyRules[i].APIKey == apiKey {
+			return &h.cfg.APIKeyRules[i]
+		}
+	}
+	return nil
+}
+
+func normalizeModelAliases(aliases map[string]string) (map[string]string, bool) {
+	out := make(map[string]string, len(aliases))
+	for alias, model := range aliases {
+		alias, model = strings.TrimSpace(alias), strings.TrimSpace(model)
+		if alias == "" || model == "" {
+			return nil, false
+		}
+		out[alias] = model
+	}
+	return out, true
+}
+
+func emptyAliasesToNil(aliases map[string]string) map[string]string {
+	if len(aliases) == 0 {
+		return nil
+	}
+	return aliases
+}
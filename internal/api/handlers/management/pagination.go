@@ -0,0 +1,96 @@
+package management
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultPageLimit sizes pages requested with a cursor but without a limit.
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// paginate returns the page of items selected by ?limit= and ?cursor=, and the cursor of the
+// next page, empty on the last one. key must be unique per item; pages follow its ascending
+// order, so a cursor stays valid when items before or after it change. Without either
+// parameter every item is returned in its original order. ok is false after an error response.
+func paginate[T any](c *gin.Context, items []T, key func(T) string) (page []T, next string, ok bool) {
+	rawLimit := strings.TrimSpace(c.Query("limit"))
+	rawCursor := strings.TrimSpace(c.Query("cursor"))
+	if rawLimit == "" && rawCursor == "" {
+		return items, "", true
+	}
+	limit := defaultPageLimit
+	if rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return nil, "", false
+		}
+		limit = min(parsed, maxPageLimit)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return key(items[i]) < key(items[j]) })
+	start := 0
+	if rawCursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(rawCursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return nil, "", false
+		}
+		start = sort.Search(len(items), func(i int) bool { return key(items[i]) > string(after) })
+	}
+	end := min(start+limit, len(items))
+	if end < len(items) {
+		next = base64.RawURLEncoding.EncodeToString([]byte(key(items[end-1])))
+	}
+	return items[start:end], next, true
+}
+
+// respondCached writes body as JSON with an ETag derived from its content. When the request's
+// If-None-Match already names that ETag, it answers 304 Not Modified without a body.
+func respondCached(c *gin.Context, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to encode response: %v", err)})
+		return
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// etagMatches reports whether the If-None-Match header value names etag, using the weak
+// comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// withNextCursor adds next to body as next_cursor unless it is empty.
+func withNextCursor(body gin.H, next string) gin.H {
+	if next != "" {
+		body["next_cursor"] = next
+	}
+	return body
+}
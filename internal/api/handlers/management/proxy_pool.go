@@ -0,0 +1,17 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
+)
+
+// GetProxyPool returns the health of the pool proxies and the accounts assigned to each.
+func (h *Handler) GetProxyPool(c *gin.Context) {
+	pool := proxypool.Default()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": pool.Enabled(),
+		"proxies": pool.Snapshot(),
+	})
+}
@@ -0,0 +1,18 @@
+package management
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+)
+
+// GetRateLimits returns the current state of the rate limit buckets. API keys are masked.
+func (h *Handler) GetRateLimits(c *gin.Context) {
+	limiter := ratelimit.GetLimiter()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": limiter.Enabled(),
+		"buckets": limiter.Snapshot(time.Now()),
+	})
+}
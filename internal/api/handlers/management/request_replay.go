@@ -0,0 +1,77 @@
+package management
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// SetRequestReplay enables replaying request logs from logsDir through target, the
+// server's own router.
+func (h *Handler) SetRequestReplay(logsDir string, target http.Handler) {
+	h.requestLogsDir = logsDir
+	h.replayTarget = target
+}
+
+// ReplayRequestLog re-runs the client request recorded in the request log ?name= with the
+// client's original headers, so it is authenticated, routed and quota-checked as that
+// client's key would be. The response, streamed or not, is written to the operator.
+// Replays are not charged to the key's quota and skip rate limits.
+func (h *Handler) ReplayRequestLog(c *gin.Context) {
+	if h.replayTarget == nil || h.requestLogsDir == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "request replay unavailable"})
+		return
+	}
+	name := c.Query("name")
+	if name == "" || strings.Contains(name, string(os.PathSeparator)) || strings.Contains(name, "/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid name"})
+		return
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".log") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must end with .log"})
+		return
+	}
+	logged, err := logging.ReadRequestLog(filepath.Join(h.requestLogsDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("failed to read request log: %v", err)})
+		}
+		return
+	}
+	path, _, _ := strings.Cut(logged.URL, "?")
+	if !strings.HasPrefix(path, "/v1/") && !strings.HasPrefix(path, "/v1beta/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("only API requests can be replayed, not %s", path)})
+		return
+	}
+
+	req, err := http.NewRequestWithContext(usage.WithReplay(c.Request.Context()), logged.Method, logged.URL, bytes.NewReader(logged.Body))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("invalid logged request: %v", err)})
+		return
+	}
+	for key, values := range logged.Headers {
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Length", "Connection", "Accept-Encoding", "Host":
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Host = c.Request.Host
+	req.RemoteAddr = c.Request.RemoteAddr
+
+	log.Infof("management: replaying request log %s (%s %s)", name, logged.Method, path)
+	c.Header("X-CLIProxy-Replay-Of", name)
+	h.replayTarget.ServeHTTP(c.Writer, req)
+}
@@ -0,0 +1,325 @@
+package management
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const maxStoredRequestsPage = 500
+
+// ListStoredRequests returns stored requests, newest first, filtered by since/until (RFC 3339),
+// key (a client API key), model and path (exact, or a prefix ending in "*"), and status (a code,
+// "4xx"/"5xx" or "error"). Pass the last id of a page as before to get the next one.
+func (h *Handler) ListStoredRequests(c *gin.Context) {
+	store := logging.GetRequestStore()
+	if !store.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request store is disabled"})
+		return
+	}
+	query := logging.RequestQuery{
+		Key:    strings.TrimSpace(c.Query("key")),
+		Model:  strings.TrimSpace(c.Query("model")),
+		Path:   strings.TrimSpace(c.Query("path")),
+		Status: strings.TrimSpace(c.Query("status")),
+		Before: strings.TrimSpace(c.Query("before")),
+	}
+	for param, dst := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		raw := strings.TrimSpace(c.Query(param))
+		if raw == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s: expected RFC 3339 time", param)})
+			return
+		}
+		*dst = ts
+	}
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		query.Limit = min(limit, maxStoredRequestsPage)
+	}
+	requests, err := store.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to query request store: %v", err)})
+		return
+	}
+	if requests == nil {
+		requests = []*logging.StoredRequest{}
+	}
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// GetStoredRequest returns one stored request with its headers and bodies.
+func (h *Handler) GetStoredRequest(c *gin.Context) {
+	rec, err := logging.GetRequestStore().Get(c.Param("id"))
+	switch {
+	case errors.Is(err, logging.ErrRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read request store: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
+
+// ClearStoredRequests removes every stored request.
+func (h *Handler) ClearStoredRequests(c *gin.Context) {
+	if err := logging.GetRequestStore().Clear(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to clear request store: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// maxReplayDifferences caps the differences listed by ReplayStoredRequest.
+const maxReplayDifferences = 100
+
+// volatileResponseFields are JSON fields that differ on every response and are not compared.
+var volatileResponseFields = map[string]struct{}{
+	"id":                 {},
+	"created":            {},
+	"created_at":         {},
+	"responseId":         {},
+	"system_fingerprint": {},
+}
+
+// replayDifference is one JSON path whose value differs between two responses.
+type replayDifference struct {
+	Path     string `json:"path"`
+	Recorded any    `json:"recorded"`
+	Replayed any    `json:"replayed"`
+}
+
+// ReplayStoredRequest runs a stored request again as the client key that sent it, optionally
+// through ?account= (an auth ID, label or file name) or only the accounts of ?provider=, and
+// compares the response with the recorded one. ?compare=structure compares JSON paths and
+// value types only, which suits checking translator changes against non-deterministic models.
+// Replays are not charged to the key's quota and skip rate limits.
+func (h *Handler) ReplayStoredRequest(c *gin.Context) {
+	if h.replayTarget == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "request replay unavailable"})
+		return
+	}
+	rec, err := logging.GetRequestStore().Get(c.Param("id"))
+	switch {
+	case errors.Is(err, logging.ErrRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read request store: %v", err)})
+		return
+	}
+	if rec.RequestHeaders == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "request was stored without its body; enable request-store.bodies"})
+		return
+	}
+	if rec.RequestTruncated {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "stored request body is truncated"})
+		return
+	}
+	if !strings.HasPrefix(rec.Path, "/v1/") && !strings.HasPrefix(rec.Path, "/v1beta/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("only API requests can be replayed, not %s", rec.Path)})
+		return
+	}
+	compare := strings.ToLower(strings.TrimSpace(c.DefaultQuery("compare", "full")))
+	if compare != "full" && compare != "structure" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "compare must be full or structure"})
+		return
+	}
+	apiKey := ""
+	for _, key := range h.cfg.APIKeys {
+		sum := sha256.Sum256([]byte(key))
+		if rec.KeyHash != "" && hex.EncodeToString(sum[:]) == rec.KeyHash {
+			apiKey = key
+			break
+		}
+	}
+	if apiKey == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "the client API key of this request is no longer configured"})
+		return
+	}
+
+	body := []byte(rec.RequestBody)
+	account := strings.TrimSpace(c.Query("account"))
+	provider := strings.ToLower(strings.TrimSpace(c.Query("provider")))
+	if account != "" || provider != "" {
+		if !gjson.ValidBytes(body) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "account and provider need a JSON request body"})
+			return
+		}
+		if account != "" {
+			body, _ = sjson.SetBytes(body, "x_cliproxy.account", account)
+		}
+		if provider != "" {
+			if h.authManager == nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+				return
+			}
+			// Keep the request on the provider by excluding every account of the others.
+			excluded := []string{}
+			found := false
+			for _, auth := range h.authManager.List() {
+				if strings.EqualFold(auth.Provider, provider) {
+					found = true
+				} else {
+					excluded = append(excluded, auth.ID)
+				}
+			}
+			if !found {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("no accounts for provider %s", provider)})
+				return
+			}
+			body, _ = sjson.SetBytes(body, "x_cliproxy.exclude_accounts", excluded)
+		}
+	}
+
+	target := rec.Path
+	if rec.Query != "" {
+		target += "?" + rec.Query
+	}
+	req, err := http.NewRequestWithContext(usage.WithReplay(c.Request.Context()), rec.Method, target, bytes.NewReader(body))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("invalid stored request: %v", err)})
+		return
+	}
+	for key, values := range rec.RequestHeaders {
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Length", "Connection", "Accept-Encoding", "Host":
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Host = c.Request.Host
+	req.RemoteAddr = c.Request.RemoteAddr
+
+	log.Infof("management: replaying stored request %s (%s %s)", rec.ID, rec.Method, rec.Path)
+	recorder := httptest.NewRecorder()
+	h.replayTarget.ServeHTTP(recorder, req)
+	replayed := recorder.Body.String()
+
+	differences, comparable := diffResponses(rec.ResponseBody, replayed, compare == "structure")
+	identical := rec.Status == recorder.Code && len(differences) == 0
+	if !comparable {
+		identical = rec.Status == recorder.Code && rec.ResponseBody == replayed
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"request_id": rec.ID,
+		"account":    account,
+		"provider":   provider,
+		"compare":    compare,
+		"recorded": gin.H{
+			"status":    rec.Status,
+			"model":     rec.Model,
+			"account":   rec.Account,
+			"body":      rec.ResponseBody,
+			"truncated": rec.ResponseTruncated,
+		},
+		"replayed": gin.H{
+			"status":   recorder.Code,
+			"provider": recorder.Header().Get("X-CLIProxy-Provider"),
+			"body":     replayed,
+		},
+		"identical":   identical,
+		"json":        comparable,
+		"differences": differences,
+	})
+}
+
+// diffResponses compares two JSON response bodies leaf by leaf, ignoring volatile fields. With
+// structureOnly only the presence and JSON type of each leaf is compared. It reports false
+// when either body is not JSON, e.g. a streamed response.
+func diffResponses(recorded, replayed string, structureOnly bool) ([]replayDifference, bool) {
+	var a, b any
+	if json.Unmarshal([]byte(recorded), &a) != nil || json.Unmarshal([]byte(replayed), &b) != nil {
+		return nil, false
+	}
+	left := make(map[string]any)
+	right := make(map[string]any)
+	flattenJSON("", a, left)
+	flattenJSON("", b, right)
+	paths := make([]string, 0, len(left)+len(right))
+	for path := range left {
+		paths = append(paths, path)
+	}
+	for path := range right {
+		if _, ok := left[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	differences := []replayDifference{}
+	for _, path := range paths {
+		lv, lok := left[path]
+		rv, rok := right[path]
+		same := lok && rok && reflect.DeepEqual(lv, rv)
+		if structureOnly {
+			same = lok && rok && fmt.Sprintf("%T", lv) == fmt.Sprintf("%T", rv)
+		}
+		if same {
+			continue
+		}
+		if len(differences) == maxReplayDifferences {
+			break
+		}
+		differences = append(differences, replayDifference{Path: path, Recorded: lv, Replayed: rv})
+	}
+	return differences, true
+}
+
+// flattenJSON records every leaf of v in out under its dotted path.
+func flattenJSON(prefix string, v any, out map[string]any) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		if len(val) == 0 {
+			out[prefix] = val
+		}
+		for key, child := range val {
+			if _, volatile := volatileResponseFields[key]; volatile {
+				continue
+			}
+			flattenJSON(join(key), child, out)
+		}
+	case []any:
+		if len(val) == 0 {
+			out[prefix] = val
+		}
+		for i, child := range val {
+			flattenJSON(join(strconv.Itoa(i)), child, out)
+		}
+	default:
+		out[prefix] = val
+	}
+}
@@ -0,0 +1,113 @@
+package management
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/trash"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// removeAuthFile deletes the auth file at full. Unless soft delete is disabled or the request
+// asks for ?permanent=true, the file is moved to the trash so it can be restored later.
+func (h *Handler) removeAuthFile(c *gin.Context, full string) error {
+	if h.cfg.SoftDelete.Disabled || isTruthy(c.Query("permanent")) {
+		return os.Remove(full)
+	}
+	actor := managementActor(c)
+	if _, err := trash.Delete(filepath.Dir(full), filepath.Base(full), actor, idgen.Now(c.Request.Context())); err != nil {
+		return err
+	}
+	log.Infof("management: auth file %s moved to trash by %s", filepath.Base(full), actor)
+	return nil
+}
+
+// ListDeletedAuthFiles returns the auth files in the trash, purging expired entries first.
+func (h *Handler) ListDeletedAuthFiles(c *gin.Context) {
+	retention := h.cfg.SoftDelete.Retention()
+	if _, err := trash.Purge(h.cfg.AuthDir, retention, idgen.Now(c.Request.Context())); err != nil {
+		log.Warnf("management: failed to purge auth file trash: %v", err)
+	}
+	entries, err := trash.List(h.cfg.AuthDir, retention)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read trash: %v", err)})
+		return
+	}
+	if entries == nil {
+		entries = []trash.Entry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"files": entries})
+}
+
+// RestoreAuthFile moves a deleted auth file back into the auth directory and registers it.
+func (h *Handler) RestoreAuthFile(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	name := c.Query("name")
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid name"})
+		return
+	}
+	path, err := trash.Restore(h.cfg.AuthDir, name)
+	switch {
+	case errors.Is(err, trash.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "deleted file not found"})
+		return
+	case errors.Is(err, trash.ErrExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "a file with this name already exists"})
+		return
+	case err != nil && path == "":
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to restore file: %v", err)})
+		return
+	case err != nil:
+		log.Warnf("management: restored %s but failed to remove its trash entry: %v", name, err)
+	}
+	if !filepath.IsAbs(path) {
+		if abs, errAbs := filepath.Abs(path); errAbs == nil {
+			path = abs
+		}
+	}
+	data, err := authcrypt.ReadFile(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read restored file: %v", err)})
+		return
+	}
+	if gjson.GetBytes(data, "type").String() == "gemini-web" {
+		_, err = h.registerGeminiWebAccount(c, name, path)
+	} else {
+		err = h.registerAuthFromFile(c.Request.Context(), path, data)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Infof("management: auth file %s restored by %s", name, managementActor(c))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// managementActor identifies who made a management request for the trash records. Callers
+// can name themselves with X-Management-Actor; otherwise the client IP is used.
+func managementActor(c *gin.Context) string {
+	if actor := strings.TrimSpace(c.GetHeader("X-Management-Actor")); actor != "" {
+		return actor
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func isTruthy(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
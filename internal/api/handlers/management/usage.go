@@ -2,8 +2,12 @@ package management
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 )
 
@@ -13,5 +17,77 @@ func (h *Handler) GetUsageStatistics(c *gin.Context) {
 	if h != nil && h.usageStats != nil {
 		snapshot = h.usageStats.Snapshot()
 	}
-	c.JSON(http.StatusOK, gin.H{"usage": snapshot})
+	respondCached(c, gin.H{"usage": snapshot})
+}
+
+// GetUsageExport returns usage aggregated per client and model, with client keys replaced
+// by pseudonyms and, when usage-export.differential-privacy is enabled, noised counts that
+// stay fixed for the release period.
+func (h *Handler) GetUsageExport(c *gin.Context) {
+	var snapshot usage.StatisticsSnapshot
+	if h != nil && h.usageStats != nil {
+		snapshot = h.usageStats.Snapshot()
+	}
+	var cfg config.UsageExportConfig
+	if h != nil && h.cfg != nil {
+		cfg = h.cfg.UsageExport
+	}
+	export, err := usage.ReleaseExport(snapshot, cfg, time.Now())
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// GetUtilizationReport analyses recent traffic against the account pool and returns
+// per-model utilization with capacity recommendations. The window defaults to 24 hours
+// and can be changed with the "hours" query parameter.
+func (h *Handler) GetUtilizationReport(c *gin.Context) {
+	hours := 24
+	if raw := strings.TrimSpace(c.Query("hours")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > 168 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be between 1 and 168"})
+			return
+		}
+		hours = n
+	}
+	threshold := 0.0
+	if h.cfg != nil {
+		threshold = h.cfg.UtilizationReport.SaturationThreshold
+	}
+	report := usage.GetUtilizationTracker().Report(time.Now(), time.Duration(hours)*time.Hour, threshold)
+	c.JSON(http.StatusOK, report)
+}
+
+// GetAPIKeyQuotas returns token usage against the configured quota of every client API key.
+func (h *Handler) GetAPIKeyQuotas(c *gin.Context) {
+	manager := usage.GetQuotaManager()
+	page, next, ok := paginate(c, manager.Snapshot(time.Now()), func(status usage.QuotaStatus) string { return status.Key })
+	if !ok {
+		return
+	}
+	respondCached(c, withNextCursor(gin.H{
+		"enabled": manager.Enabled(),
+		"keys":    page,
+	}, next))
+}
+
+// GetBandwidthUsage returns upstream traffic per account against its bandwidth ceilings.
+func (h *Handler) GetBandwidthUsage(c *gin.Context) {
+	tracker := usage.GetBandwidthTracker()
+	page, next, ok := paginate(c, tracker.Snapshot(time.Now()), func(status usage.BandwidthStatus) string { return status.Account })
+	if !ok {
+		return
+	}
+	respondCached(c, withNextCursor(gin.H{
+		"enabled":  tracker.Enabled(),
+		"accounts": page,
+	}, next))
+}
+
+// GetTrafficSplitUsage returns request outcomes per traffic split route.
+func (h *Handler) GetTrafficSplitUsage(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"routes": usage.GetTrafficSplitStats().Snapshot()})
 }
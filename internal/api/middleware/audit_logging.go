@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// AuditLoggingMiddleware writes one audit record per API request once the handler returns.
+// Token counts and the serving account come from the usage entries executors attach to the
+// context; management and static routes are skipped.
+func AuditLoggingMiddleware(logger *logging.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !logger.Enabled() || strings.HasPrefix(c.Request.URL.Path, "/v0/management") {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		logger.Log(newAuditRecord(c, start))
+	}
+}
+
+// newAuditRecord summarises the request handled by c, which started at start, with the usage
+// entries executors attached to it.
+func newAuditRecord(c *gin.Context, start time.Time) logging.AuditRecord {
+	record := logging.AuditRecord{
+		Timestamp: start.UTC(),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		ClientIP:  c.ClientIP(),
+		Status:    c.Writer.Status(),
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if v, exists := c.Get("apiKey"); exists {
+		if key, ok := v.(string); ok {
+			record.APIKey = key
+		}
+	}
+	record.AddRequestUsage(c)
+	return record
+}
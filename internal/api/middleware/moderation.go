@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// maxModeratedBodyBytes bounds the request bodies read for moderation. Larger bodies are
+// rejected rather than passed through unfiltered.
+const maxModeratedBodyBytes = 32 << 20
+
+// ModerationMiddleware filters the prompts of API requests: matches are redacted in the body
+// seen by later middleware and handlers, and blocked requests are rejected with 400. It runs
+// after authentication, so unauthenticated requests are never read; the request log is given
+// the filtered body. Only JSON bodies are filtered, and GET requests are skipped.
+func ModerationMiddleware(filter *moderation.Filter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if filter == nil || c.Request.Method == http.MethodGet || c.Request.Body == nil ||
+			c.Request.Body == http.NoBody || !filter.Active(moderation.StagePrompt) {
+			c.Next()
+			return
+		}
+		moderated, sniff := moderatedContentType(c.Request.Header.Get("Content-Type"))
+		if !moderated {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxModeratedBodyBytes {
+			abortBodyTooLarge(c)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxModeratedBodyBytes+1))
+		_ = c.Request.Body.Close()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{Message: fmt.Sprintf("failed to read request body: %v", err), Type: "invalid_request_error"},
+			})
+			return
+		}
+		if len(body) > maxModeratedBodyBytes {
+			abortBodyTooLarge(c)
+			return
+		}
+		if sniff && !looksLikeJSON(body) {
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Next()
+			return
+		}
+		filtered, blockedBy := filter.Apply(moderation.StagePrompt, body)
+		if blockedBy != "" {
+			setLoggedRequestBody(c, nil)
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{
+					Message: fmt.Sprintf("request blocked by moderation rule %s", blockedBy),
+					Type:    "invalid_request_error",
+					Code:    "content_blocked",
+				},
+			})
+			return
+		}
+		setLoggedRequestBody(c, filtered)
+		c.Request.Body = io.NopCloser(bytes.NewReader(filtered))
+		c.Request.ContentLength = int64(len(filtered))
+		c.Request.Header.Set("Content-Length", strconv.Itoa(len(filtered)))
+		c.Next()
+	}
+}
+
+// moderatedContentType reports whether a body of contentType may carry JSON and is moderated.
+// sniff reports that the type is missing or one clients commonly send JSON under, such as
+// curl's default form type, so the body itself decides.
+func moderatedContentType(contentType string) (moderated, sniff bool) {
+	if strings.TrimSpace(contentType) == "" {
+		return true, true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false, false
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return true, false
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "text/plain":
+		return true, true
+	}
+	return false, false
+}
+
+// looksLikeJSON reports whether body starts like a JSON object or array.
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+func abortBodyTooLarge(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: fmt.Sprintf("request body exceeds the moderation limit of %d bytes", maxModeratedBodyBytes),
+			Type:    "invalid_request_error",
+			Code:    "request_too_large",
+		},
+	})
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+)
+
+// QuotaMiddleware enforces per-API-key token quotas. Requests from a key over its daily or
+// monthly limit are rejected with 429; otherwise the request's tokens are charged once the
+// handler returns. Read-only GET requests are neither checked nor charged, and operator
+// replays are checked but not charged. Tokens reported by executors are used when
+// available and otherwise estimated from the text of the request and response. Must run after
+// authentication.
+func QuotaMiddleware(manager *usage.QuotaManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if manager == nil || !manager.Enabled() || c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
+		apiKey := c.GetString("apiKey")
+		if apiKey == "" {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		status := manager.Status(apiKey, c.GetStringMapString(requestctx.AccessMetadataKey), now)
+		setQuotaHeaders(c, status)
+		if status.Exceeded != "" {
+			reset := status.DailyReset
+			if status.Exceeded == "monthly" {
+				reset = status.MonthlyReset
+			}
+			c.Header("X-Quota-Reset", reset.Format(time.RFC3339))
+			c.Header("Retry-After", strconv.FormatInt(int64(time.Until(reset).Seconds())+1, 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{
+					Message: fmt.Sprintf("%s token quota exceeded for this API key; resets at %s", status.Exceeded, reset.Format(time.RFC3339)),
+					Type:    "insufficient_quota",
+					Code:    "quota_exceeded",
+				},
+			})
+			return
+		}
+
+		var requestBody []byte
+		if c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err == nil {
+				requestBody = body
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		capture := &quotaResponseCapture{ResponseWriter: c.Writer}
+		c.Writer = capture
+
+		c.Next()
+
+		if usage.IsReplay(c.Request.Context()) {
+			return
+		}
+		var promptTokens, completionTokens int64
+		if v, exists := c.Get(logging.RequestUsageKey); exists {
+			if entries, ok := v.([]logging.RequestUsage); ok {
+				for _, entry := range entries {
+					promptTokens += entry.InputTokens
+					completionTokens += max(entry.TotalTokens-entry.InputTokens, entry.OutputTokens)
+				}
+			}
+		}
+		if promptTokens+completionTokens == 0 && c.Writer.Status() < http.StatusBadRequest {
+			promptTokens = usage.EstimatePromptTokens(requestBody)
+			completionTokens = usage.EstimateCompletionTokens(capture.body.Bytes())
+			if captured := capture.body.Len(); capture.truncated && captured > 0 {
+				// Scale the estimate of the captured prefix to the full response.
+				completionTokens = completionTokens * int64(c.Writer.Size()) / int64(captured)
+			}
+		}
+		manager.Add(apiKey, promptTokens, completionTokens, time.Now())
+	}
+}
+
+// quotaCaptureLimit bounds how much of a response is kept for estimating its tokens.
+const quotaCaptureLimit = 4 << 20
+
+// quotaResponseCapture keeps a copy of the response body so completion tokens can be
+// estimated when the upstream reports no usage.
+type quotaResponseCapture struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *quotaResponseCapture) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.capture(data[:n])
+	return n, err
+}
+
+func (w *quotaResponseCapture) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.capture([]byte(s[:n]))
+	return n, err
+}
+
+func (w *quotaResponseCapture) capture(data []byte) {
+	if w.truncated {
+		return
+	}
+	if w.body.Len()+len(data) > quotaCaptureLimit {
+		w.truncated = true
+		return
+	}
+	w.body.Write(data)
+}
+
+func setQuotaHeaders(c *gin.Context, status usage.QuotaStatus) {
+	if status.DailyLimit > 0 {
+		c.Header("X-Quota-Daily-Limit", strconv.FormatInt(status.DailyLimit, 10))
+		c.Header("X-Quota-Daily-Remaining", strconv.FormatInt(max(status.DailyLimit-status.DailyUsed, 0), 10))
+	}
+	if status.MonthlyLimit > 0 {
+		c.Header("X-Quota-Monthly-Limit", strconv.FormatInt(status.MonthlyLimit, 10))
+		c.Header("X-Quota-Monthly-Remaining", strconv.FormatInt(max(status.MonthlyLimit-status.MonthlyUsed, 0), 10))
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/asyncjobs"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// RateLimitMiddleware applies the global and per-key token buckets. Every limited response
+// carries X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset for the bucket
+// closest to running out; rejected requests get 429 with Retry-After. Must run after
+// authentication so the client API key is known. Operator replays are not limited.
+func RateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil || !limiter.Enabled() || usage.IsReplay(c.Request.Context()) {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		applyRateLimitDecision(c, limiter.Allow(c.GetString("apiKey"), "", now), now)
+	}
+}
+
+// IPRateLimitMiddleware applies the per-IP token bucket. It runs before authentication so
+// that requests with missing or invalid keys are throttled by address as well. Async jobs
+// were throttled when they were accepted and are not counted again when they run.
+func IPRateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil || !limiter.Enabled() || usage.IsReplay(c.Request.Context()) || asyncjobs.IsJob(c.Request.Context()) {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		applyRateLimitDecision(c, limiter.AllowIP(c.ClientIP(), now), now)
+	}
+}
+
+func applyRateLimitDecision(c *gin.Context, decision ratelimit.Decision, now time.Time) {
+	if state := decision.Tightest; state.Scope != "" {
+		c.Header("X-RateLimit-Limit", strconv.Itoa(state.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(state.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(state.Reset.Sub(now).Seconds())), 10))
+		c.Header("X-RateLimit-Scope", state.Scope)
+	}
+	if decision.Allowed {
+		c.Next()
+		return
+	}
+	c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(decision.RetryAfter.Seconds())), 10))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: fmt.Sprintf("%s rate limit exceeded; retry after %s", decision.Tightest.Scope, decision.RetryAfter.Round(time.Millisecond)),
+			Type:    "rate_limit_error",
+			Code:    "rate_limit_exceeded",
+		},
+	})
+}
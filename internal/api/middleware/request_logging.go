@@ -11,6 +11,19 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 )
 
+// requestInfoKey is the gin context key of the *RequestInfo being logged for a request.
+const requestInfoKey = "requestLogInfo"
+
+// setLoggedRequestBody replaces the request body recorded by the request log, if one is being
+// written, so that logs hold the body the handler saw.
+func setLoggedRequestBody(c *gin.Context, body []byte) {
+	if value, ok := c.Get(requestInfoKey); ok {
+		if info, okInfo := value.(*RequestInfo); okInfo {
+			info.Body = body
+		}
+	}
+}
+
 // RequestLoggingMiddleware creates a Gin middleware that logs HTTP requests and responses.
 // It captures detailed information about the request and response, including headers and body,
 // and uses the provided RequestLogger to record this data. If logging is disabled in the
@@ -32,6 +45,9 @@ func RequestLoggingMiddleware(logger logging.RequestLogger) gin.HandlerFunc {
 			return
 		}
 
+		// Let middleware that rewrites the body, such as moderation, update the logged copy
+		c.Set(requestInfoKey, requestInfo)
+
 		// Create response writer wrapper
 		wrapper := NewResponseWriterWrapper(c.Writer, logger, requestInfo)
 		c.Writer = wrapper
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/tidwall/gjson"
+)
+
+// storedRequestHeaders are request headers that carry credentials and are never stored.
+var storedRequestHeaders = map[string]struct{}{
+	"Authorization":  {},
+	"X-Api-Key":      {},
+	"X-Goog-Api-Key": {},
+	"Cookie":         {},
+}
+
+// RequestStoreMiddleware records every authenticated API request in store once the handler
+// returns. With bodies enabled the request and response bodies are kept as well, truncated to
+// the configured size, and only that much of the request body is buffered; management routes
+// are skipped.
+func RequestStoreMiddleware(store *logging.RequestStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !store.Enabled() || strings.HasPrefix(c.Request.URL.Path, "/v0/management") {
+			c.Next()
+			return
+		}
+		withBodies, maxBody := store.Bodies()
+		var requestBody []byte
+		var requestTruncated bool
+		var capture *bodyCaptureWriter
+		if withBodies {
+			if c.Request.Body != nil && c.Request.Body != http.NoBody {
+				// Keep only the stored prefix; the handler reads it back followed by the rest.
+				prefix, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(maxBody)+1))
+				c.Request.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(prefix), c.Request.Body), Closer: c.Request.Body}
+				if err == nil {
+					requestBody = prefix
+					if len(requestBody) > maxBody {
+						requestBody = requestBody[:maxBody]
+						requestTruncated = true
+					}
+				}
+			}
+			capture = &bodyCaptureWriter{ResponseWriter: c.Writer, limit: maxBody}
+			c.Writer = capture
+		}
+		start := time.Now()
+		c.Next()
+
+		rec := &logging.StoredRequest{AuditRecord: newAuditRecord(c, start)}
+		if query := c.Request.URL.Query(); len(query) > 0 {
+			query.Del("key")
+			rec.Query = query.Encode()
+		}
+		if rec.Model == "" && !requestTruncated {
+			rec.Model = gjson.GetBytes(requestBody, "model").String()
+		}
+		if withBodies {
+			rec.RequestHeaders = make(map[string][]string, len(c.Request.Header))
+			for key, values := range c.Request.Header {
+				if _, secret := storedRequestHeaders[http.CanonicalHeaderKey(key)]; !secret {
+					rec.RequestHeaders[key] = values
+				}
+			}
+			rec.RequestBody = string(requestBody)
+			rec.RequestTruncated = requestTruncated
+			rec.ResponseBody = capture.body.String()
+			rec.ResponseTruncated = capture.truncated
+		}
+		store.Save(rec)
+	}
+}
+
+// bodyCaptureWriter keeps the first limit bytes written to the response.
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *bodyCaptureWriter) capture(data []byte) {
+	if room := w.limit - w.body.Len(); room < len(data) {
+		data = data[:max(room, 0)]
+		w.truncated = true
+	}
+	w.body.Write(data)
+}
+
+func (w *bodyCaptureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// readCloser reads from Reader and closes Closer, the original request body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -12,16 +12,33 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/alerts"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/assets"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/asyncjobs"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batches"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/clientkeys"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/conformance"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/grpcapi"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mtls"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/promptjobs"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -30,6 +47,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/gemini"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -126,6 +144,18 @@ type Server struct {
 	// management handler
 	mgmt *managementHandlers.Handler
 
+	// promptJobs runs the configured scheduled prompts.
+	promptJobs *promptjobs.Scheduler
+
+	// batches stores uploaded batch files and runs queued batches.
+	batches *batches.Service
+
+	// asyncJobs runs requests sent with "Prefer: respond-async" in the background.
+	asyncJobs *asyncjobs.Service
+
+	// grpc serves the gRPC API when grpc-port is set.
+	grpc *grpcapi.Server
+
 	localPassword string
 
 	keepAliveEnabled   bool
@@ -170,6 +200,9 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		engine.Use(mw)
 	}
 
+	moderation.GetFilter().Configure(cfg.Moderation)
+	moderation.GetSecretScanner().Configure(cfg.Moderation.SecretDetection)
+
 	// Add request logging middleware (positioned after recovery, before auth)
 	// Resolve logs directory relative to the configuration file directory.
 	var requestLogger logging.RequestLogger
@@ -184,6 +217,32 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		}
 	}
 
+	if err := logging.GetAuditLogger().Configure(cfg.AuditLog); err != nil {
+		log.Errorf("failed to configure audit log: %v", err)
+	}
+	executor.ConfigureFaultInjection(cfg.FaultInjection)
+	clientkeys.Default().Configure(cfg.ClientKeys, cfg.AuthDir)
+	usage.GetQuotaManager().Configure(cfg.APIKeyQuotas, cfg.AuthDir)
+	usage.GetBandwidthTracker().Configure(cfg.Bandwidth, cfg.AuthDir)
+	proxypool.Default().Configure(cfg.ProxyPool)
+	ratelimit.GetLimiter().Configure(cfg.RateLimit)
+	alerts.Configure(cfg.Alerts)
+	if err := authcrypt.Configure(cfg.AuthEncryption); err != nil {
+		log.Errorf("failed to configure auth file encryption: %v", err)
+	}
+	if err := mtls.ConfigureOutbound(cfg.UpstreamTLS); err != nil {
+		log.Errorf("failed to configure upstream TLS: %v", err)
+	}
+	geminiwebapi.ConfigureTransport(cfg.GeminiWeb.Transport)
+	geminiwebapi.ConfigureUploadCache(cfg.GeminiWeb.UploadCache)
+	geminiwebapi.ConfigureBackups(cfg.GeminiWeb.Backup)
+	assets.GetService().Configure(cfg.Assets)
+	registry.GetGlobalRegistry().SetModelAliases(cfg.ModelAliases)
+	engine.Use(middleware.AuditLoggingMiddleware(logging.GetAuditLogger()))
+	if err := logging.GetRequestStore().Configure(cfg.RequestStore); err != nil {
+		log.Errorf("failed to configure request store: %v", err)
+	}
+
 	engine.Use(corsMiddleware())
 
 	// Create server instance
@@ -203,6 +262,17 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		s.mgmt.SetLocalPassword(optionState.localPassword)
 	}
 	s.localPassword = optionState.localPassword
+	if direr, ok := requestLogger.(interface{ LogsDir() string }); ok {
+		s.mgmt.SetRequestReplay(direr.LogsDir(), engine)
+	}
+
+	s.promptJobs = promptjobs.NewScheduler(openai.NewOpenAIAPIHandler(s.handlers))
+	s.promptJobs.Configure(cfg.PromptJobs)
+	s.batches = batches.NewService(openai.NewOpenAIAPIHandler(s.handlers))
+	s.batches.Configure(cfg.Batches)
+	s.asyncJobs = asyncjobs.NewService(engine)
+	s.asyncJobs.Configure(cfg.AsyncJobs)
+	s.grpc = grpcapi.NewServer(openai.NewOpenAIAPIHandler(s.handlers), accessManager)
 
 	// Setup routes
 	s.setupRoutes()
@@ -235,19 +305,44 @@ func (s *Server) setupRoutes() {
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(middleware.IPRateLimitMiddleware(ratelimit.GetLimiter()), AuthMiddleware(s.accessManager), middleware.ModerationMiddleware(moderation.GetFilter()), middleware.RequestStoreMiddleware(logging.GetRequestStore()), s.asyncJobs.Middleware(), middleware.RateLimitMiddleware(ratelimit.GetLimiter()), middleware.QuotaMiddleware(usage.GetQuotaManager()))
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
+		v1.GET("/models/:model", openaiHandlers.OpenAIModel)
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
+		v1.POST("/chat/:action", openaiHandlers.ChatCompletionsAction)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/images/generations", openaiHandlers.ImageGenerations)
+		v1.POST("/embeddings", openaiHandlers.Embeddings)
+		v1.POST("/audio/speech", openaiHandlers.AudioSpeech)
+		v1.POST("/audio/transcriptions", openaiHandlers.AudioTranscriptions)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
-	}
+		v1.GET("/responses/:id", openaiResponsesHandlers.GetResponse)
+		v1.DELETE("/responses/:id", openaiResponsesHandlers.DeleteResponse)
+		v1.POST("/sessions", openaiHandlers.CreateChatSession)
+		v1.GET("/sessions/:id", openaiHandlers.GetChatSession)
+		v1.DELETE("/sessions/:id", openaiHandlers.DeleteChatSession)
+		v1.POST("/files", s.batches.UploadFile)
+		v1.GET("/files", s.batches.ListFiles)
+		v1.GET("/files/:id", s.batches.GetFile)
+		v1.GET("/files/:id/content", s.batches.FileContent)
+		v1.DELETE("/files/:id", s.batches.DeleteFile)
+		v1.POST("/batches", s.batches.CreateBatch)
+		v1.GET("/batches", s.batches.ListBatches)
+		v1.GET("/batches/:id", s.batches.GetBatch)
+		v1.POST("/batches/:id/cancel", s.batches.CancelBatch)
+		v1.GET("/jobs/:id", s.asyncJobs.Get)
+		v1.GET("/conformance", conformance.Handle)
+	}
+
+	// Re-hosted images are served without an API key; their names are unguessable.
+	s.engine.GET(assets.RoutePath+":name", middleware.IPRateLimitMiddleware(ratelimit.GetLimiter()), assets.GetService().Handle)
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(middleware.IPRateLimitMiddleware(ratelimit.GetLimiter()), AuthMiddleware(s.accessManager), middleware.ModerationMiddleware(moderation.GetFilter()), middleware.RequestStoreMiddleware(logging.GetRequestStore()), s.asyncJobs.Middleware(), middleware.RateLimitMiddleware(ratelimit.GetLimiter()), middleware.QuotaMiddleware(usage.GetQuotaManager()))
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/:action", geminiHandlers.GeminiHandler)
@@ -266,7 +361,7 @@ func (s *Server) setupRoutes() {
 			},
 		})
 	})
-	s.engine.POST("/v1internal:method", geminiCLIHandlers.CLIHandler)
+	s.engine.POST("/v1internal:method", middleware.ModerationMiddleware(moderation.GetFilter()), middleware.RequestStoreMiddleware(logging.GetRequestStore()), geminiCLIHandlers.CLIHandler)
 
 	// OAuth callback endpoints (reuse main server port)
 	// These endpoints receive provider redirects and persist
@@ -315,6 +410,17 @@ func (s *Server) setupRoutes() {
 		mgmt.Use(s.mgmt.Middleware())
 		{
 			mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
+			mgmt.GET("/usage/export", s.mgmt.GetUsageExport)
+			mgmt.GET("/usage/utilization", s.mgmt.GetUtilizationReport)
+			mgmt.GET("/usage/quotas", s.mgmt.GetAPIKeyQuotas)
+			mgmt.GET("/usage/bandwidth", s.mgmt.GetBandwidthUsage)
+			mgmt.GET("/usage/traffic-splits", s.mgmt.GetTrafficSplitUsage)
+			mgmt.GET("/rate-limits", s.mgmt.GetRateLimits)
+
+			mgmt.GET("/client-keys", s.mgmt.ListClientKeys)
+			mgmt.POST("/client-keys", s.mgmt.CreateClientKey)
+			mgmt.PATCH("/client-keys/:id", s.mgmt.UpdateClientKey)
+			mgmt.POST("/client-keys/:id/revoke", s.mgmt.RevokeClientKey)
 			mgmt.GET("/config", s.mgmt.GetConfig)
 
 			mgmt.GET("/debug", s.mgmt.GetDebug)
@@ -333,6 +439,7 @@ func (s *Server) setupRoutes() {
 			mgmt.PUT("/proxy-url", s.mgmt.PutProxyURL)
 			mgmt.PATCH("/proxy-url", s.mgmt.PutProxyURL)
 			mgmt.DELETE("/proxy-url", s.mgmt.DeleteProxyURL)
+			mgmt.GET("/proxy-pool", s.mgmt.GetProxyPool)
 
 			mgmt.GET("/quota-exceeded/switch-project", s.mgmt.GetSwitchProject)
 			mgmt.PUT("/quota-exceeded/switch-project", s.mgmt.PutSwitchProject)
@@ -347,6 +454,11 @@ func (s *Server) setupRoutes() {
 			mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
 			mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
 
+			mgmt.GET("/model-aliases", s.mgmt.GetModelAliases)
+			mgmt.PUT("/model-aliases", s.mgmt.PutModelAliases)
+			mgmt.PATCH("/model-aliases", s.mgmt.PatchModelAliases)
+			mgmt.DELETE("/model-aliases", s.mgmt.DeleteModelAliases)
+
 			mgmt.GET("/generative-language-api-key", s.mgmt.GetGlKeys)
 			mgmt.PUT("/generative-language-api-key", s.mgmt.PutGlKeys)
 			mgmt.PATCH("/generative-language-api-key", s.mgmt.PatchGlKeys)
@@ -355,6 +467,12 @@ func (s *Server) setupRoutes() {
 			mgmt.GET("/request-log", s.mgmt.GetRequestLog)
 			mgmt.PUT("/request-log", s.mgmt.PutRequestLog)
 			mgmt.PATCH("/request-log", s.mgmt.PutRequestLog)
+			mgmt.POST("/request-log/replay", s.mgmt.ReplayRequestLog)
+
+			mgmt.GET("/requests", s.mgmt.ListStoredRequests)
+			mgmt.GET("/requests/:id", s.mgmt.GetStoredRequest)
+			mgmt.POST("/requests/:id/replay", s.mgmt.ReplayStoredRequest)
+			mgmt.DELETE("/requests", s.mgmt.ClearStoredRequests)
 
 			mgmt.GET("/request-retry", s.mgmt.GetRequestRetry)
 			mgmt.PUT("/request-retry", s.mgmt.PutRequestRetry)
@@ -379,11 +497,38 @@ func (s *Server) setupRoutes() {
 			mgmt.GET("/auth-files/download", s.mgmt.DownloadAuthFile)
 			mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
 			mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
+			mgmt.GET("/auth-files/deleted", s.mgmt.ListDeletedAuthFiles)
+			mgmt.POST("/auth-files/restore", s.mgmt.RestoreAuthFile)
 
 			mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
 			mgmt.GET("/codex-auth-url", s.mgmt.RequestCodexToken)
 			mgmt.GET("/gemini-cli-auth-url", s.mgmt.RequestGeminiCLIToken)
 			mgmt.POST("/gemini-web-token", s.mgmt.CreateGeminiWebToken)
+			mgmt.GET("/gemini-web/accounts", s.mgmt.ListGeminiWebAccounts)
+			mgmt.POST("/gemini-web/accounts", s.mgmt.AddGeminiWebAccount)
+			mgmt.POST("/gemini-web/accounts/import", s.mgmt.ImportGeminiWebAccount)
+			mgmt.PATCH("/gemini-web/accounts/:name", s.mgmt.UpdateGeminiWebAccount)
+			mgmt.DELETE("/gemini-web/accounts/:name", s.mgmt.DeleteGeminiWebAccount)
+			mgmt.GET("/gemini-web/accounts/:name/conversations", s.mgmt.ListGeminiWebConversations)
+			mgmt.GET("/gemini-web/accounts/:name/conversations/deleted", s.mgmt.ListDeletedGeminiWebConversations)
+			mgmt.POST("/gemini-web/accounts/:name/conversations/reindex", s.mgmt.ReindexGeminiWebConversations)
+			mgmt.GET("/gemini-web/accounts/:name/conversations/:id", s.mgmt.GetGeminiWebConversation)
+			mgmt.DELETE("/gemini-web/accounts/:name/conversations/:id", s.mgmt.DeleteGeminiWebConversation)
+			mgmt.POST("/gemini-web/accounts/:name/conversations/:id/restore", s.mgmt.RestoreGeminiWebConversation)
+			mgmt.GET("/gemini-web/accounts/:name/conversations/:id/snapshots", s.mgmt.ListGeminiWebConversationSnapshots)
+			mgmt.POST("/gemini-web/accounts/:name/conversations/:id/restore-turn", s.mgmt.RestoreGeminiWebConversationTurn)
+			mgmt.DELETE("/conversations/:id", s.mgmt.DeleteConversation)
+			mgmt.GET("/gemini-web/conversations/search", s.mgmt.SearchGeminiWebConversations)
+			mgmt.GET("/gemini-web/conversation-ids", s.mgmt.ListGeminiWebConversationIDs)
+			mgmt.GET("/gemini-web/conversation-ids/:key", s.mgmt.GetGeminiWebConversationID)
+			mgmt.DELETE("/gemini-web/conversation-ids/:key", s.mgmt.DeleteGeminiWebConversationID)
+			mgmt.GET("/gemini-web/breakers", s.mgmt.ListGeminiWebBreakers)
+			mgmt.POST("/gemini-web/breakers/:name/reset", s.mgmt.ResetGeminiWebBreaker)
+			mgmt.GET("/gemini-web/queues", s.mgmt.ListGeminiWebQueues)
+			mgmt.GET("/gemini-web/gems", s.mgmt.ListGeminiWebGems)
+			mgmt.POST("/gemini-web/gems", s.mgmt.CreateGeminiWebGem)
+			mgmt.PUT("/gemini-web/gems/:id", s.mgmt.UpdateGeminiWebGem)
+			mgmt.DELETE("/gemini-web/gems/:id", s.mgmt.DeleteGeminiWebGem)
 			mgmt.GET("/qwen-auth-url", s.mgmt.RequestQwenToken)
 			mgmt.GET("/get-auth-status", s.mgmt.GetAuthStatus)
 		}
@@ -523,8 +668,25 @@ func (s *Server) unifiedModelsHandler(openaiHandler *openai.OpenAIAPIHandler, cl
 func (s *Server) Start() error {
 	log.Debugf("Starting API server on %s", s.server.Addr)
 
-	// Start the HTTP server.
-	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	tlsConfig, err := mtls.ServerConfig(s.cfg.TLS)
+	if err != nil {
+		return fmt.Errorf("failed to configure TLS: %v", err)
+	}
+
+	if s.cfg.GRPCPort > 0 {
+		if err = s.grpc.Start(s.cfg.GRPCPort, tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	// Start the HTTP server, over TLS when configured.
+	if tlsConfig != nil {
+		s.server.TLSConfig = tlsConfig
+		err = s.server.ListenAndServeTLS("", "")
+	} else {
+		err = s.server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("failed to start HTTP server: %v", err)
 	}
 
@@ -553,6 +715,14 @@ func (s *Server) Stop(ctx context.Context) error {
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
+	if err := s.grpc.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown gRPC server: %v", err)
+	}
+
+	usage.GetQuotaManager().Flush()
+	usage.GetBandwidthTracker().Flush()
+	s.promptJobs.Stop()
+	s.batches.Stop()
 
 	log.Debug("API server stopped")
 	return nil
@@ -568,6 +738,7 @@ func corsMiddleware() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "*")
+		c.Header("Access-Control-Expose-Headers", "ETag")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -631,6 +802,44 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if err := logging.GetAuditLogger().Configure(cfg.AuditLog); err != nil {
+		log.Errorf("failed to reconfigure audit log: %v", err)
+	}
+	if err := logging.GetRequestStore().Configure(cfg.RequestStore); err != nil {
+		log.Errorf("failed to reconfigure request store: %v", err)
+	}
+	moderation.GetFilter().Configure(cfg.Moderation)
+	moderation.GetSecretScanner().Configure(cfg.Moderation.SecretDetection)
+	executor.ConfigureFaultInjection(cfg.FaultInjection)
+	clientkeys.Default().Configure(cfg.ClientKeys, cfg.AuthDir)
+	usage.GetQuotaManager().Configure(cfg.APIKeyQuotas, cfg.AuthDir)
+	usage.GetBandwidthTracker().Configure(cfg.Bandwidth, cfg.AuthDir)
+	ratelimit.GetLimiter().Configure(cfg.RateLimit)
+	registry.GetGlobalRegistry().SetModelAliases(cfg.ModelAliases)
+	alerts.Configure(cfg.Alerts)
+	if err := authcrypt.Configure(cfg.AuthEncryption); err != nil {
+		log.Errorf("failed to reconfigure auth file encryption: %v", err)
+	}
+	if err := mtls.ConfigureOutbound(cfg.UpstreamTLS); err != nil {
+		log.Errorf("failed to reconfigure upstream TLS: %v", err)
+	}
+	geminiwebapi.ConfigureTransport(cfg.GeminiWeb.Transport)
+	geminiwebapi.ConfigureUploadCache(cfg.GeminiWeb.UploadCache)
+	geminiwebapi.ConfigureBackups(cfg.GeminiWeb.Backup)
+	if oldCfg != nil && oldCfg.UpstreamTLS != cfg.UpstreamTLS {
+		geminiwebapi.ResetTransports()
+	}
+
+	if oldCfg == nil || oldCfg.Assets != cfg.Assets {
+		assets.GetService().Configure(cfg.Assets)
+	}
+	if oldCfg == nil || oldCfg.UtilizationReport != cfg.UtilizationReport {
+		usage.ConfigureUtilizationWebhook(cfg.UtilizationReport)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.ProxyPool, cfg.ProxyPool) {
+		proxypool.Default().Configure(cfg.ProxyPool)
+	}
+
 	// Update log level dynamically when debug flag changes
 	if oldCfg == nil || oldCfg.Debug != cfg.Debug {
 		util.SetLogLevel(cfg)
@@ -644,6 +853,9 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	s.applyAccessConfig(oldCfg, cfg)
 	s.cfg = cfg
 	s.handlers.UpdateClients(&cfg.SDKConfig)
+	s.promptJobs.Configure(cfg.PromptJobs)
+	s.batches.Configure(cfg.Batches)
+	s.asyncJobs.Configure(cfg.AsyncJobs)
 
 	if !cfg.RemoteManagement.DisableControlPanel {
 		staticDir := managementasset.StaticDir(s.configFilePath)
@@ -698,7 +910,7 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 				c.Set("apiKey", result.Principal)
 				c.Set("accessProvider", result.Provider)
 				if len(result.Metadata) > 0 {
-					c.Set("accessMetadata", result.Metadata)
+					c.Set(requestctx.AccessMetadataKey, result.Metadata)
 				}
 			}
 			c.Next()
@@ -0,0 +1,290 @@
+// Package assets re-hosts generated images behind stable links served by the proxy. Upstream
+// image URLs expire and need the account cookies, so images are downloaded at response time,
+// kept on local disk, S3 or Google Cloud Storage, and removed once their TTL passes.
+package assets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+)
+
+// RoutePath is the path images are served from; the final segment is the asset name.
+const RoutePath = "/v1/assets/"
+
+// sweepInterval is the minimum time between two removals of expired assets.
+const sweepInterval = time.Hour
+
+// ErrNotFound is returned for assets that do not exist or have expired.
+var ErrNotFound = errors.New("asset not found")
+
+// reName matches the names handed out by Store, which are also the storage object names.
+var reName = regexp.MustCompile(`^img_[0-9a-f]{32}\.[a-z0-9]{2,5}$`)
+
+// Object is a stored asset.
+type Object struct {
+	Name        string
+	ContentType string
+	Modified    time.Time
+	Data        []byte
+}
+
+// Backend keeps asset bytes. Names are unique and never rewritten.
+type Backend interface {
+	Put(ctx context.Context, name, contentType string, data []byte) error
+	// Get returns ErrNotFound for missing objects.
+	Get(ctx context.Context, name string) (Object, error)
+	Delete(ctx context.Context, name string) error
+	// List returns the names and modification times of all objects, without data.
+	List(ctx context.Context) ([]Object, error)
+}
+
+// Service stores assets in the configured backend. The zero value is disabled.
+type Service struct {
+	mu        sync.RWMutex
+	enabled   bool
+	backend   Backend
+	ttl       time.Duration
+	baseURL   string
+	lastSweep time.Time
+	sweeping  bool
+}
+
+var defaultService = &Service{}
+
+// GetService returns the shared asset service.
+func GetService() *Service { return defaultService }
+
+// Configure selects the backend described by cfg. An invalid storage configuration disables
+// the service with an error log, so responses fall back to inline images.
+func (s *Service) Configure(cfg config.AssetsConfig) {
+	var backend Backend
+	if cfg.Enabled {
+		var err error
+		if backend, err = newBackend(cfg); err != nil {
+			log.Errorf("assets: disabled: %v", err)
+		}
+	}
+	s.mu.Lock()
+	s.enabled = backend != nil
+	s.backend = backend
+	s.ttl = cfg.TTL()
+	s.baseURL = strings.TrimSuffix(strings.TrimSpace(cfg.PublicBaseURL), "/")
+	s.mu.Unlock()
+}
+
+func newBackend(cfg config.AssetsConfig) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Storage)) {
+	case "", "local":
+		dir := strings.TrimSpace(cfg.Dir)
+		if dir == "" {
+			wd, err := os.Getwd()
+			if err != nil || wd == "" {
+				wd = "."
+			}
+			dir = filepath.Join(wd, "assets")
+		}
+		return newLocalBackend(dir)
+	case "s3":
+		return newS3Backend(cfg.S3)
+	case "gcs":
+		return newGCSBackend(cfg.GCS)
+	default:
+		return nil, fmt.Errorf("unknown storage %q", cfg.Storage)
+	}
+}
+
+// Enabled reports whether images should be re-hosted.
+func (s *Service) Enabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.enabled
+}
+
+// Store saves data and returns the link it is served from. The link is absolute when a public
+// base URL is configured or ctx carries the client request.
+func (s *Service) Store(ctx context.Context, data []byte, contentType string) (string, error) {
+	s.mu.RLock()
+	backend, baseURL := s.backend, s.baseURL
+	s.mu.RUnlock()
+	if backend == nil {
+		return "", errors.New("assets: storage is disabled")
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	name, err := newName(contentType)
+	if err != nil {
+		return "", err
+	}
+	if err = backend.Put(ctx, name, contentType, data); err != nil {
+		return "", fmt.Errorf("assets: store %s: %w", name, err)
+	}
+	s.maybeSweep(time.Now())
+	if baseURL == "" {
+		baseURL = requestBaseURL(ctx)
+	}
+	return baseURL + RoutePath + name, nil
+}
+
+// Open returns the asset called name unless it has expired.
+func (s *Service) Open(ctx context.Context, name string) (Object, error) {
+	s.mu.RLock()
+	backend, ttl := s.backend, s.ttl
+	s.mu.RUnlock()
+	if backend == nil || !reName.MatchString(name) {
+		return Object{}, ErrNotFound
+	}
+	obj, err := backend.Get(ctx, name)
+	if err != nil {
+		return Object{}, err
+	}
+	if time.Since(obj.Modified) > ttl {
+		return Object{}, ErrNotFound
+	}
+	return obj, nil
+}
+
+// Sweep deletes the assets older than the TTL at now and returns how many were removed.
+func (s *Service) Sweep(ctx context.Context, now time.Time) (int, error) {
+	s.mu.RLock()
+	backend, ttl := s.backend, s.ttl
+	s.mu.RUnlock()
+	if backend == nil {
+		return 0, nil
+	}
+	objects, err := backend.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, obj := range objects {
+		if !reName.MatchString(obj.Name) || now.Sub(obj.Modified) <= ttl {
+			continue
+		}
+		if errDelete := backend.Delete(ctx, obj.Name); errDelete != nil {
+			return removed, errDelete
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// maybeSweep starts a background sweep when the last one is older than sweepInterval.
+func (s *Service) maybeSweep(now time.Time) {
+	s.mu.Lock()
+	if s.sweeping || now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.sweeping = true
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			s.sweeping = false
+			s.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if n, err := s.Sweep(ctx, now); err != nil {
+			log.Warnf("assets: failed to remove expired images: %v", err)
+		} else if n > 0 {
+			log.Infof("assets: removed %d expired images", n)
+		}
+	}()
+}
+
+// Handle serves GET /v1/assets/:name. Asset names are unguessable, so links work without
+// an API key, like the upstream URLs they replace.
+func (s *Service) Handle(c *gin.Context) {
+	obj, err := s.Open(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Warnf("assets: failed to read %s: %v", c.Param("name"), err)
+			c.Status(http.StatusBadGateway)
+			return
+		}
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400, immutable")
+	c.Data(http.StatusOK, obj.ContentType, obj.Data)
+}
+
+// newName returns a random asset name with the extension of contentType.
+func newName(contentType string) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return "img_" + hex.EncodeToString(b[:]) + extension(contentType), nil
+}
+
+var extensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+	"image/avif": ".avif",
+}
+
+func extension(contentType string) string {
+	mime := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	if ext, ok := extensions[mime]; ok {
+		return ext
+	}
+	return ".bin"
+}
+
+// contentTypeOf returns the content type of an asset name, for backends that keep none.
+func contentTypeOf(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	for mime, e := range extensions {
+		if e == ext {
+			return mime
+		}
+	}
+	return "application/octet-stream"
+}
+
+// requestBaseURL returns the scheme and host the client reached the proxy on, or "" outside
+// a client request.
+func requestBaseURL(ctx context.Context) string {
+	c := requestctx.Gin(ctx)
+	if c == nil || c.Request == nil {
+		return ""
+	}
+	r := c.Request
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0]); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := r.Host
+	if fwd := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Host"), ",")[0]); fwd != "" {
+		host = fwd
+	}
+	if host == "" {
+		return ""
+	}
+	return scheme + "://" + host
+}
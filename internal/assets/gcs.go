@@ -0,0 +1,176 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	gcsScope      = "https://www.googleapis.com/auth/devstorage.read_write"
+	gcsAPIBase    = "https://storage.googleapis.com/storage/v1/b/"
+	gcsUploadBase = "https://storage.googleapis.com/upload/storage/v1/b/"
+)
+
+// gcsBackend keeps assets in a Google Cloud Storage bucket through the JSON API.
+type gcsBackend struct {
+	client *http.Client
+	bucket string
+	prefix string
+}
+
+func newGCSBackend(cfg config.AssetsGCSConfig) (*gcsBackend, error) {
+	bucket := strings.TrimSpace(cfg.Bucket)
+	if bucket == "" {
+		return nil, errors.New("gcs: bucket is required")
+	}
+	// Token requests outlive any single request, so they are bound to a background context.
+	ctx := context.Background()
+	var creds *google.Credentials
+	var err error
+	if path := strings.TrimSpace(cfg.CredentialsFile); path != "" {
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			return nil, fmt.Errorf("gcs: %w", errRead)
+		}
+		creds, err = google.CredentialsFromJSON(ctx, data, gcsScope)
+	} else {
+		creds, err = google.FindDefaultCredentials(ctx, gcsScope)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gcs: credentials: %w", err)
+	}
+	client := oauth2.NewClient(ctx, creds.TokenSource)
+	client.Timeout = 60 * time.Second
+	return &gcsBackend{client: client, bucket: bucket, prefix: cfg.Prefix}, nil
+}
+
+func (b *gcsBackend) objectURL(name string) string {
+	return gcsAPIBase + url.PathEscape(b.bucket) + "/o/" + url.PathEscape(b.prefix+name)
+}
+
+func (b *gcsBackend) Put(ctx context.Context, name, contentType string, data []byte) error {
+	u := gcsUploadBase + url.PathEscape(b.bucket) + "/o?uploadType=media&name=" + url.QueryEscape(b.prefix+name)
+	resp, err := b.do(ctx, http.MethodPost, u, contentType, data)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+type gcsObject struct {
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Updated     time.Time `json:"updated"`
+}
+
+func (b *gcsBackend) Get(ctx context.Context, name string) (Object, error) {
+	resp, err := b.do(ctx, http.MethodGet, b.objectURL(name), "", nil)
+	if err != nil {
+		return Object{}, err
+	}
+	var meta gcsObject
+	err = json.NewDecoder(resp.Body).Decode(&meta)
+	_ = resp.Body.Close()
+	if err != nil {
+		return Object{}, fmt.Errorf("gcs: decode object: %w", err)
+	}
+	resp, err = b.do(ctx, http.MethodGet, b.objectURL(name)+"?alt=media", "", nil)
+	if err != nil {
+		return Object{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Object{}, err
+	}
+	contentType := meta.ContentType
+	if contentType == "" {
+		contentType = contentTypeOf(name)
+	}
+	return Object{Name: name, ContentType: contentType, Modified: meta.Updated, Data: data}, nil
+}
+
+func (b *gcsBackend) Delete(ctx context.Context, name string) error {
+	resp, err := b.do(ctx, http.MethodDelete, b.objectURL(name), "", nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (b *gcsBackend) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	pageToken := ""
+	for {
+		query := url.Values{"fields": {"items(name,updated),nextPageToken"}}
+		if b.prefix != "" {
+			query.Set("prefix", b.prefix)
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		resp, err := b.do(ctx, http.MethodGet, gcsAPIBase+url.PathEscape(b.bucket)+"/o?"+query.Encode(), "", nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items         []gcsObject `json:"items"`
+			NextPageToken string      `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("gcs: decode object list: %w", err)
+		}
+		for _, item := range page.Items {
+			objects = append(objects, Object{Name: strings.TrimPrefix(item.Name, b.prefix), Modified: item.Updated})
+		}
+		if page.NextPageToken == "" {
+			return objects, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// do sends an authorized request. Responses other than 2xx are closed and returned as errors;
+// 404 maps to ErrNotFound.
+func (b *gcsBackend) do(ctx context.Context, method, u, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	return nil, fmt.Errorf("gcs: %s %s: %d %s", method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,80 @@
+package assets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// localBackend keeps assets as files in one directory; the file time is the asset age.
+type localBackend struct {
+	dir string
+}
+
+func newLocalBackend(dir string) (*localBackend, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &localBackend{dir: dir}, nil
+}
+
+func (b *localBackend) Put(_ context.Context, name, _ string, data []byte) error {
+	tmp, err := os.CreateTemp(b.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(b.dir, name))
+}
+
+func (b *localBackend) Get(_ context.Context, name string) (Object, error) {
+	path := filepath.Join(b.dir, name)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Object{}, ErrNotFound
+	}
+	if err != nil {
+		return Object{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{Name: name, ContentType: contentTypeOf(name), Modified: info.ModTime(), Data: data}, nil
+}
+
+func (b *localBackend) Delete(_ context.Context, name string) error {
+	err := os.Remove(filepath.Join(b.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (b *localBackend) List(_ context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]Object, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo != nil {
+			continue
+		}
+		objects = append(objects, Object{Name: entry.Name(), Modified: info.ModTime()})
+	}
+	return objects, nil
+}
@@ -0,0 +1,262 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// s3Backend keeps assets in an S3 bucket, addressed path-style so that S3-compatible
+// services work with a custom endpoint.
+type s3Backend struct {
+	client   *http.Client
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	region   string
+	keyID    string
+	secret   string
+	token    string
+}
+
+func newS3Backend(cfg config.AssetsS3Config) (*s3Backend, error) {
+	if strings.TrimSpace(cfg.Bucket) == "" {
+		return nil, errors.New("s3: bucket is required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3: access-key-id and secret-access-key are required")
+	}
+	region := strings.TrimSpace(cfg.Region)
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := strings.TrimSpace(cfg.Endpoint)
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", endpoint)
+	}
+	return &s3Backend{
+		client:   &http.Client{Timeout: 60 * time.Second},
+		endpoint: u,
+		bucket:   strings.TrimSpace(cfg.Bucket),
+		prefix:   cfg.Prefix,
+		region:   region,
+		keyID:    cfg.AccessKeyID,
+		secret:   cfg.SecretAccessKey,
+		token:    cfg.SessionToken,
+	}, nil
+}
+
+func (b *s3Backend) Put(ctx context.Context, name, contentType string, data []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, b.prefix+name, nil, contentType, data)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (b *s3Backend) Get(ctx context.Context, name string) (Object, error) {
+	resp, err := b.do(ctx, http.MethodGet, b.prefix+name, nil, "", nil)
+	if err != nil {
+		return Object{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Object{}, err
+	}
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = contentTypeOf(name)
+	}
+	return Object{Name: name, ContentType: contentType, Modified: modified, Data: data}, nil
+}
+
+func (b *s3Backend) Delete(ctx context.Context, name string) error {
+	resp, err := b.do(ctx, http.MethodDelete, b.prefix+name, nil, "", nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (b *s3Backend) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if b.prefix != "" {
+			query.Set("prefix", b.prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := b.do(ctx, http.MethodGet, "", query, "", nil)
+		if err != nil {
+			return nil, err
+		}
+		var page s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: decode object list: %w", err)
+		}
+		for _, item := range page.Contents {
+			objects = append(objects, Object{Name: strings.TrimPrefix(item.Key, b.prefix), Modified: item.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// do sends a signed request for key (the bucket itself when empty). Responses other than 2xx
+// are closed and returned as errors; 404 maps to ErrNotFound.
+func (b *s3Backend) do(ctx context.Context, method, key string, query url.Values, contentType string, body []byte) (*http.Response, error) {
+	u := *b.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + b.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	b.sign(req, body, time.Now())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	return nil, fmt.Errorf("s3: %s %s: %d %s", method, u.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+// sign adds an AWS Signature Version 4 for the s3 service to req.
+func (b *s3Backend) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.token != "" {
+		req.Header.Set("X-Amz-Security-Token", b.token)
+	}
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		headers["content-type"] = ct
+	}
+	if b.token != "" {
+		headers["x-amz-security-token"] = b.token
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// S3 signs the path as sent, and the query with keys sorted and values escaped.
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	keys := make([]string, 0, len(req.URL.Query()))
+	for k := range req.URL.Query() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		params = append(params, s3Escape(k)+"="+s3Escape(req.URL.Query().Get(k)))
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, strings.Join(params, "&"), canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := hmacSHA256([]byte("AWS4"+b.secret), date)
+	key = hmacSHA256(key, b.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+b.keyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// s3Escape percent-encodes everything except the RFC 3986 unreserved characters.
+func s3Escape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%%%02X", c))
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
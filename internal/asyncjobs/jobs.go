@@ -0,0 +1,362 @@
+// Package asyncjobs runs API requests sent with "Prefer: respond-async" (RFC 7240) in the
+// background. The client gets a job ID at once; the finished response is posted to the
+// configured webhook and can be polled from /v1/jobs/{id}.
+package asyncjobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxRunning = 8
+	defaultMaxQueued  = 100
+	defaultTimeout    = 10 * time.Minute
+	defaultRetention  = time.Hour
+)
+
+// webhookDelays are the waits before each webhook delivery attempt.
+var webhookDelays = []time.Duration{0, 5 * time.Second, 30 * time.Second, 2 * time.Minute}
+
+// Job statuses.
+const (
+	StatusQueued     = "queued"
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+)
+
+// Job is one request executed in the background.
+type Job struct {
+	ID          string
+	Owner       string
+	Method      string
+	Path        string
+	Status      string
+	CreatedAt   time.Time
+	StartedAt   time.Time
+	CompletedAt time.Time
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	// WebhookStatus is "pending", "delivered" or "failed"; empty without a webhook.
+	WebhookStatus   string
+	WebhookAttempts int
+}
+
+type jobContextKey struct{}
+
+// withJob marks ctx as the background execution of a job.
+func withJob(ctx context.Context) context.Context {
+	return context.WithValue(ctx, jobContextKey{}, true)
+}
+
+// IsJob reports whether ctx belongs to the background execution of a job. Such requests were
+// already admitted when the job was created.
+func IsJob(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	job, _ := ctx.Value(jobContextKey{}).(bool)
+	return job
+}
+
+// Service keeps the jobs in memory and runs them through target, normally the API engine, so
+// they take the same authentication, limits and routing as direct requests.
+type Service struct {
+	target http.Handler
+	client *http.Client
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	cfg     config.AsyncJobsConfig
+	jobs    map[string]*Job
+	queued  int
+	running int
+}
+
+// NewService returns a service executing jobs with target.
+func NewService(target http.Handler) *Service {
+	s := &Service{target: target, client: &http.Client{Timeout: 30 * time.Second}, jobs: make(map[string]*Job)}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Configure applies cfg to new jobs and to the queue.
+func (s *Service) Configure(cfg config.AsyncJobsConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	s.cond.Broadcast()
+}
+
+// prefersAsync reports whether a Prefer header asks for respond-async.
+func prefersAsync(values []string) bool {
+	for _, value := range values {
+		for _, preference := range strings.Split(value, ",") {
+			token, _, _ := strings.Cut(preference, ";")
+			token, _, _ = strings.Cut(token, "=")
+			if strings.EqualFold(strings.TrimSpace(token), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Middleware turns POST requests preferring respond-async into jobs, answering 202 with the
+// job. It must run after authentication so that jobs are owned by the client API key.
+func (s *Service) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || IsJob(c.Request.Context()) || !prefersAsync(c.Request.Header.Values("Prefer")) {
+			c.Next()
+			return
+		}
+		s.mu.Lock()
+		cfg := s.cfg
+		s.mu.Unlock()
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{Message: fmt.Sprintf("Invalid request: %v", err), Type: "invalid_request_error"},
+			})
+			return
+		}
+		req, err := http.NewRequestWithContext(withJob(context.Background()), c.Request.Method, c.Request.URL.String(), bytes.NewReader(body))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{Message: fmt.Sprintf("Invalid request: %v", err), Type: "invalid_request_error"},
+			})
+			return
+		}
+		for key, values := range c.Request.Header {
+			switch http.CanonicalHeaderKey(key) {
+			case "Prefer", "Content-Length", "Connection", "Accept-Encoding":
+				continue
+			}
+			req.Header[key] = append([]string(nil), values...)
+		}
+		req.Host = c.Request.Host
+		req.RemoteAddr = c.Request.RemoteAddr
+
+		ctx := c.Request.Context()
+		job := &Job{
+			ID:        idgen.NewID(ctx, "job_"),
+			Owner:     responsestore.OwnerOf(c.GetString("apiKey")),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    StatusQueued,
+			CreatedAt: idgen.Now(ctx),
+		}
+		if strings.TrimSpace(cfg.WebhookURL) != "" {
+			job.WebhookStatus = "pending"
+		}
+
+		s.mu.Lock()
+		s.sweepLocked(time.Now())
+		maxQueued := cfg.MaxQueued
+		if maxQueued <= 0 {
+			maxQueued = defaultMaxQueued
+		}
+		if s.queued >= maxQueued {
+			s.mu.Unlock()
+			c.Header("Retry-After", "30")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{Message: "Too many queued async jobs; retry later", Type: "rate_limit_error"},
+			})
+			return
+		}
+		s.jobs[job.ID] = job
+		s.queued++
+		view := viewLocked(job)
+		s.mu.Unlock()
+
+		go s.run(job, req)
+		c.Header("Preference-Applied", "respond-async")
+		c.Header("Location", "/v1/jobs/"+job.ID)
+		c.AbortWithStatusJSON(http.StatusAccepted, view)
+	}
+}
+
+// Get handles GET /v1/jobs/:id. Jobs are only visible to the API key that created them.
+func (s *Service) Get(c *gin.Context) {
+	owner := responsestore.OwnerOf(c.GetString("apiKey"))
+	s.mu.Lock()
+	s.sweepLocked(time.Now())
+	job, ok := s.jobs[c.Param("id")]
+	var view gin.H
+	if ok && job.Owner == owner {
+		view = viewLocked(job)
+	}
+	s.mu.Unlock()
+	if view == nil {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: fmt.Sprintf("No job found with id '%s'.", c.Param("id")), Type: "invalid_request_error"},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func (s *Service) run(job *Job, req *http.Request) {
+	s.mu.Lock()
+	for {
+		maxRunning := s.cfg.MaxRunning
+		if maxRunning <= 0 {
+			maxRunning = defaultMaxRunning
+		}
+		if s.running < maxRunning {
+			break
+		}
+		s.cond.Wait()
+	}
+	s.queued--
+	s.running++
+	job.Status = StatusInProgress
+	job.StartedAt = time.Now()
+	timeout := defaultTimeout
+	if s.cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(s.cfg.TimeoutSeconds) * time.Second
+	}
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	recorder := httptest.NewRecorder()
+	s.target.ServeHTTP(recorder, req.WithContext(ctx))
+	cancel()
+
+	s.mu.Lock()
+	s.running--
+	s.cond.Signal()
+	job.CompletedAt = time.Now()
+	job.StatusCode = recorder.Code
+	job.ContentType = recorder.Header().Get("Content-Type")
+	job.Body = recorder.Body.Bytes()
+	job.Status = StatusCompleted
+	if recorder.Code >= http.StatusBadRequest {
+		job.Status = StatusFailed
+	}
+	cfg := s.cfg
+	view := viewLocked(job)
+	delete(view, "webhook")
+	payload, _ := json.Marshal(view)
+	s.mu.Unlock()
+	log.Debugf("async job %s %s %s finished with %d", job.ID, job.Method, job.Path, job.StatusCode)
+
+	if job.WebhookStatus != "" {
+		s.deliver(job, cfg, payload)
+	}
+}
+
+// deliver posts the finished job to the webhook, retrying failed deliveries.
+func (s *Service) deliver(job *Job, cfg config.AsyncJobsConfig, payload []byte) {
+	url := strings.TrimSpace(cfg.WebhookURL)
+	status := "failed"
+	for attempt, delay := range webhookDelays {
+		time.Sleep(delay)
+		s.mu.Lock()
+		job.WebhookAttempts = attempt + 1
+		s.mu.Unlock()
+		err := s.post(url, cfg, payload)
+		if err == nil {
+			status = "delivered"
+			break
+		}
+		log.Warnf("async job %s: webhook delivery attempt %d failed: %v", job.ID, attempt+1, err)
+	}
+	s.mu.Lock()
+	job.WebhookStatus = status
+	s.mu.Unlock()
+}
+
+func (s *Service) post(url string, cfg config.AsyncJobsConfig, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range cfg.WebhookHeaders {
+		req.Header.Set(key, value)
+	}
+	if cfg.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(cfg.WebhookSecret))
+		mac.Write(payload)
+		req.Header.Set("X-CLIProxy-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sweepLocked drops finished jobs older than the retention period.
+func (s *Service) sweepLocked(now time.Time) {
+	retention := defaultRetention
+	if s.cfg.RetentionMinutes > 0 {
+		retention = time.Duration(s.cfg.RetentionMinutes) * time.Minute
+	}
+	for id, job := range s.jobs {
+		if !job.CompletedAt.IsZero() && job.WebhookStatus != "pending" && now.Sub(job.CompletedAt) > retention {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func viewLocked(job *Job) gin.H {
+	view := gin.H{
+		"id":           job.ID,
+		"object":       "async_job",
+		"status":       job.Status,
+		"created_at":   job.CreatedAt.Unix(),
+		"started_at":   nil,
+		"completed_at": nil,
+		"request":      gin.H{"method": job.Method, "path": job.Path},
+		"response":     nil,
+	}
+	if !job.StartedAt.IsZero() {
+		view["started_at"] = job.StartedAt.Unix()
+	}
+	if !job.CompletedAt.IsZero() {
+		view["completed_at"] = job.CompletedAt.Unix()
+		var body any = string(job.Body)
+		if json.Valid(job.Body) {
+			body = json.RawMessage(job.Body)
+		}
+		view["response"] = gin.H{
+			"status_code": job.StatusCode,
+			"headers":     gin.H{"content-type": job.ContentType},
+			"body":        body,
+		}
+	}
+	if job.WebhookStatus != "" {
+		view["webhook"] = gin.H{"status": job.WebhookStatus, "attempts": job.WebhookAttempts}
+	}
+	return view
+}
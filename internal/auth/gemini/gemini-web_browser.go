@@ -0,0 +1,151 @@
+package gemini
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
+)
+
+// GeminiWebBrowserSession is a signed-in Google session found in a local browser profile.
+type GeminiWebBrowserSession struct {
+	Profile       browser.Profile `json:"profile"`
+	Secure1PSID   string          `json:"-"`
+	Secure1PSIDTS string          `json:"-"`
+	// Cookie is the profile's full google.com cookie header, used to look up the account email.
+	Cookie  string    `json:"-"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// FindGeminiWebBrowserSessions reads the google.com cookies of the local browser profiles,
+// optionally only those of browserName and profileName, and returns the profiles that hold
+// both __Secure-1PSID and __Secure-1PSIDTS. Profiles that could not be read are returned
+// as errors next to the sessions that were found.
+func FindGeminiWebBrowserSessions(browserName, profileName string) ([]GeminiWebBrowserSession, []error) {
+	var sessions []GeminiWebBrowserSession
+	var errs []error
+	for _, profile := range browser.FindProfiles(browserName) {
+		if profileName != "" && !strings.EqualFold(profile.Name, profileName) {
+			continue
+		}
+		cookies, err := browser.ReadCookies(profile, "google.com")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s profile %s: %w", profile.Browser, profile.Name, err))
+			continue
+		}
+		session := GeminiWebBrowserSession{Profile: profile}
+		pairs := make([]string, 0, len(cookies))
+		for _, cookie := range cookies {
+			host := strings.TrimPrefix(cookie.Host, ".")
+			if host != "google.com" {
+				continue
+			}
+			pairs = append(pairs, cookie.Name+"="+cookie.Value)
+			switch cookie.Name {
+			case "__Secure-1PSID":
+				session.Secure1PSID = cookie.Value
+				session.Expires = cookie.Expires
+			case "__Secure-1PSIDTS":
+				session.Secure1PSIDTS = cookie.Value
+			}
+		}
+		if session.Secure1PSID == "" || session.Secure1PSIDTS == "" {
+			continue
+		}
+		session.Cookie = strings.Join(pairs, "; ")
+		sessions = append(sessions, session)
+	}
+	return sessions, errs
+}
+
+// FetchGeminiWebAccountEmail returns the email of the primary account signed in with cookie,
+// a Cookie header for google.com, from https://accounts.google.com/ListAccounts.
+func FetchGeminiWebAccountEmail(httpClient *http.Client, cookie string) (string, error) {
+	// Use POST per upstream behavior.
+	req, err := http.NewRequest(http.MethodPost, "https://accounts.google.com/ListAccounts", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Cookie", cookie)
+	req.Header.Set("Accept", "application/json, text/plain, */*")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+	req.Header.Set("Origin", "https://accounts.google.com")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request to ListAccounts failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ListAccounts returned status code: %d", resp.StatusCode)
+	}
+	var payload []any
+	if err = json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("failed to parse ListAccounts response: %w", err)
+	}
+	// Expected structure like: ["gaia.l.a.r", [["gaia.l.a",1,"Name","email@example.com", ... ]]]
+	if len(payload) >= 2 {
+		if accounts, ok := payload[1].([]any); ok && len(accounts) >= 1 {
+			if first, ok1 := accounts[0].([]any); ok1 && len(first) >= 4 {
+				if email, ok2 := first[3].(string); ok2 && strings.TrimSpace(email) != "" {
+					return strings.TrimSpace(email), nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("failed to parse email from ListAccounts response")
+}
+
+// GeminiWebFileName returns the auth file name of the account signed in with secure1PSID.
+func GeminiWebFileName(secure1PSID string) string {
+	sum := sha256.Sum256([]byte(secure1PSID))
+	return fmt.Sprintf("gemini-web-%s.json", hex.EncodeToString(sum[:])[:16])
+}
+
+// SaveGeminiWebBrowserSession writes the cookies of session to its auth file in authDir. An
+// existing file keeps its other fields and loses its expired mark; otherwise a new file
+// labelled label is created. It returns the file path and whether the file was created.
+func SaveGeminiWebBrowserSession(authDir string, session GeminiWebBrowserSession, label string) (string, bool, error) {
+	path := filepath.Join(authDir, GeminiWebFileName(session.Secure1PSID))
+	data, err := authcrypt.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		storage := &GeminiWebTokenStorage{
+			Secure1PSID:   session.Secure1PSID,
+			Secure1PSIDTS: session.Secure1PSIDTS,
+			Label:         label,
+		}
+		if err = storage.SaveTokenToFile(path); err != nil {
+			return "", false, err
+		}
+		return path, true, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read auth file: %w", err)
+	}
+	metadata := make(map[string]any)
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return "", false, fmt.Errorf("invalid auth file: %w", err)
+	}
+	metadata["secure_1psid"] = session.Secure1PSID
+	metadata["secure_1psidts"] = session.Secure1PSIDTS
+	metadata["last_refresh"] = time.Now().Format(time.RFC3339)
+	delete(metadata, "expired")
+	out, err := json.Marshal(metadata)
+	if err != nil {
+		return "", false, err
+	}
+	if err = authcrypt.WriteFile(path, out, 0o600); err != nil {
+		return "", false, fmt.Errorf("failed to write auth file: %w", err)
+	}
+	return path, false, nil
+}
@@ -0,0 +1,296 @@
+// Package authcrypt encrypts auth files at rest with AES-256-GCM. Encrypted files remain JSON
+// documents, so the watcher and the management API still recognise them, and plaintext files
+// are read as before, which lets existing deployments turn encryption on without migrating.
+package authcrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+// DefaultKeyEnv is the environment variable read for the key when no other source is set.
+const DefaultKeyEnv = "CLIPROXY_AUTH_KEY"
+
+const (
+	envelopeField = "cliproxy_encrypted"
+	algorithm     = "AES-256-GCM"
+
+	// Passphrases are stretched with PBKDF2; a fixed salt keeps the derived key stable across
+	// restarts without storing anything next to the files.
+	passphraseSalt       = "cliproxyapi-auth-files"
+	passphraseIterations = 600000
+
+	keyCommandTimeout = 30 * time.Second
+)
+
+// ErrNoKey is returned when a file is encrypted but none of the configured keys matches it.
+var ErrNoKey = errors.New("auth file is encrypted with a key that is not configured")
+
+// envelope is the on-disk form of an encrypted auth file.
+type envelope struct {
+	Algorithm string `json:"cliproxy_encrypted"`
+	KeyID     string `json:"key_id"`
+	Nonce     []byte `json:"nonce"`
+	Data      []byte `json:"data"`
+}
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+type keyring struct {
+	mu      sync.RWMutex
+	cfg     config.AuthEncryptionConfig
+	loaded  bool
+	enabled bool
+	// keys[0] encrypts; every key decrypts, which allows rotating keys.
+	keys []key
+}
+
+var ring = &keyring{}
+
+// Configure loads the keys for cfg. The key material holds one or more keys separated by
+// commas or newlines: the first encrypts new writes and the others only decrypt, so a key can
+// be rotated by prepending the new one. Each key is 32 bytes in hex or base64, or a
+// passphrase. Configure is safe to call on every config reload; it keeps the previous keys
+// when loading fails and only reruns the key command when the settings changed.
+func Configure(cfg config.AuthEncryptionConfig) error {
+	ring.mu.RLock()
+	unchanged := ring.loaded && ring.cfg == cfg
+	ring.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	material, err := keyMaterial(cfg)
+	if err != nil {
+		return err
+	}
+	var keys []key
+	for _, part := range strings.FieldsFunc(material, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		k, errKey := newKey(part)
+		if errKey != nil {
+			return errKey
+		}
+		keys = append(keys, k)
+	}
+	if cfg.Enabled && len(keys) == 0 {
+		return fmt.Errorf("auth-encryption is enabled but no key is set in %s or key-command", keyEnv(cfg))
+	}
+	ring.mu.Lock()
+	ring.cfg = cfg
+	ring.loaded = true
+	ring.enabled = cfg.Enabled
+	ring.keys = keys
+	ring.mu.Unlock()
+	return nil
+}
+
+// Enabled reports whether auth files are encrypted when written.
+func Enabled() bool {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return ring.enabled
+}
+
+func keyEnv(cfg config.AuthEncryptionConfig) string {
+	if name := strings.TrimSpace(cfg.KeyEnv); name != "" {
+		return name
+	}
+	return DefaultKeyEnv
+}
+
+func keyMaterial(cfg config.AuthEncryptionConfig) (string, error) {
+	command := strings.TrimSpace(cfg.KeyCommand)
+	if command == "" {
+		return os.Getenv(keyEnv(cfg)), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keyCommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("auth-encryption key-command failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func newKey(material string) (key, error) {
+	raw := decodeKey(material)
+	if raw == nil {
+		derived, err := pbkdf2.Key(sha256.New, material, []byte(passphraseSalt), passphraseIterations, 32)
+		if err != nil {
+			return key{}, err
+		}
+		raw = derived
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return key{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return key{}, err
+	}
+	sum := sha256.Sum256(append([]byte("cliproxyapi-key-id:"), raw...))
+	return key{id: hex.EncodeToString(sum[:8]), aead: aead}, nil
+}
+
+// decodeKey returns material as a raw 32-byte key when it is one in hex or base64.
+func decodeKey(material string) []byte {
+	if b, err := hex.DecodeString(material); err == nil && len(b) == 32 {
+		return b
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(material); err == nil && len(b) == 32 {
+			return b
+		}
+	}
+	return nil
+}
+
+// IsEncrypted reports whether data is an encrypted auth file.
+func IsEncrypted(data []byte) bool {
+	return gjson.GetBytes(data, envelopeField).Exists()
+}
+
+// Seal encrypts plain with the current key when encryption is enabled and returns it
+// unchanged otherwise.
+func Seal(plain []byte) ([]byte, error) {
+	ring.mu.RLock()
+	enabled, keys := ring.enabled, ring.keys
+	ring.mu.RUnlock()
+	if !enabled {
+		return plain, nil
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("auth-encryption is enabled but no key is loaded")
+	}
+	k := keys[0]
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	env := envelope{Algorithm: algorithm, KeyID: k.id, Nonce: nonce}
+	env.Data = k.aead.Seal(nil, nonce, plain, []byte(k.id))
+	return json.Marshal(env)
+}
+
+// Open decrypts an encrypted auth file and returns plaintext files unchanged.
+func Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid encrypted auth file: %w", err)
+	}
+	if env.Algorithm != algorithm {
+		return nil, fmt.Errorf("unsupported auth file encryption %q", env.Algorithm)
+	}
+	ring.mu.RLock()
+	keys := ring.keys
+	ring.mu.RUnlock()
+	for _, k := range keys {
+		if k.id != env.KeyID {
+			continue
+		}
+		plain, err := k.aead.Open(nil, env.Nonce, env.Data, []byte(k.id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt auth file: %w", err)
+		}
+		return plain, nil
+	}
+	return nil, fmt.Errorf("%w (key id %s)", ErrNoKey, env.KeyID)
+}
+
+// ReadFile reads an auth file and decrypts it when needed.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return plain, nil
+}
+
+// WriteFile writes an auth file, encrypting it when encryption is enabled. The file is
+// replaced atomically so that the watcher never sees a partial write.
+func WriteFile(path string, plain []byte, perm os.FileMode) error {
+	data, err := Seal(plain)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Rewrite rewrites the auth file at path in the form the current settings call for:
+// encrypted with the current key when encryption is enabled, plaintext otherwise. It reports
+// whether the file changed.
+func Rewrite(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	plain, err := Open(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if IsEncrypted(data) == Enabled() && (!Enabled() || currentKeyID(data)) {
+		return false, nil
+	}
+	if err = WriteFile(path, plain, info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// currentKeyID reports whether the encrypted data uses the current encryption key.
+func currentKeyID(data []byte) bool {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return len(ring.keys) > 0 && gjson.GetBytes(data, "key_id").String() == ring.keys[0].id
+}
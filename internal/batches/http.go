@@ -0,0 +1,407 @@
+package batches
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// enabledStore writes a 404 and returns nil when batches are disabled.
+func (s *Service) enabledStore(c *gin.Context) *store {
+	st := s.storeIfEnabled()
+	if st == nil {
+		writeError(c, http.StatusNotFound, "Batches are not enabled on this server", "invalid_request_error")
+	}
+	return st
+}
+
+func writeError(c *gin.Context, status int, message, errType string) {
+	c.JSON(status, handlers.ErrorResponse{Error: handlers.ErrorDetail{Message: message, Type: errType}})
+}
+
+func writeStoreError(c *gin.Context, kind, id string, err error) {
+	if errors.Is(err, ErrNotFound) {
+		writeError(c, http.StatusNotFound, fmt.Sprintf("No %s found with id '%s'.", kind, id), "invalid_request_error")
+		return
+	}
+	log.Errorf("batches: %s %s: %v", kind, id, err)
+	writeError(c, http.StatusInternalServerError, "Batch store unavailable", "server_error")
+}
+
+func ownerOf(c *gin.Context) string {
+	return responsestore.OwnerOf(c.GetString("apiKey"))
+}
+
+// UploadFile handles POST /v1/files. Only files with purpose "batch" are accepted.
+func (s *Service) UploadFile(c *gin.Context) {
+	st := s.enabledStore(c)
+	if st == nil {
+		return
+	}
+	maxMB := s.config().MaxFileMB
+	if maxMB <= 0 {
+		maxMB = defaultMaxFileMB
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxMB+1)<<20)
+	if purpose := c.PostForm("purpose"); purpose != "batch" {
+		writeError(c, http.StatusBadRequest, fmt.Sprintf("purpose must be 'batch', got '%s'", purpose), "invalid_request_error")
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		writeError(c, http.StatusBadRequest, fmt.Sprintf("file is required: %v", err), "invalid_request_error")
+		return
+	}
+	if header.Size > int64(maxMB)<<20 {
+		writeError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("file is larger than %d MB", maxMB), "invalid_request_error")
+		return
+	}
+	src, err := header.Open()
+	if err != nil {
+		writeError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err), "invalid_request_error")
+		return
+	}
+	defer func() { _ = src.Close() }()
+
+	ctx := c.Request.Context()
+	now := idgen.Now(ctx)
+	f := File{
+		ID:        idgen.NewID(ctx, "file-"),
+		Owner:     ownerOf(c),
+		Filename:  header.Filename,
+		Purpose:   "batch",
+		Bytes:     header.Size,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.retention()),
+	}
+	dst, err := os.OpenFile(st.contentPath(f.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err == nil {
+		_, err = io.Copy(dst, src)
+		if errClose := dst.Close(); err == nil {
+			err = errClose
+		}
+	}
+	if err == nil {
+		err = st.putFile(f)
+	}
+	if err != nil {
+		_ = os.Remove(st.contentPath(f.ID))
+		writeStoreError(c, "file", f.ID, err)
+		return
+	}
+	c.JSON(http.StatusOK, fileView(f))
+}
+
+// ListFiles handles GET /v1/files, optionally filtered by ?purpose=.
+func (s *Service) ListFiles(c *gin.Context) {
+	st := s.enabledStore(c)
+	if st == nil {
+		return
+	}
+	owner, purpose, now := ownerOf(c), c.Query("purpose"), idgen.Now(c.Request.Context())
+	var files []File
+	err := st.each(bucketFiles, func(raw []byte) {
+		var f File
+		if json.Unmarshal(raw, &f) != nil || f.Owner != owner || (purpose != "" && f.Purpose != purpose) {
+			return
+		}
+		if !f.ExpiresAt.IsZero() && now.After(f.ExpiresAt) {
+			return
+		}
+		files = append(files, f)
+	})
+	if err != nil {
+		writeStoreError(c, "file", "", err)
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	data := make([]gin.H, 0, len(files))
+	for _, f := range files {
+		data = append(data, fileView(f))
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data, "has_more": false})
+}
+
+// GetFile handles GET /v1/files/:id.
+func (s *Service) GetFile(c *gin.Context) {
+	st := s.enabledStore(c)
+	if st == nil {
+		return
+	}
+	f, err := st.file(c.Param("id"), ownerOf(c), idgen.Now(c.Request.Context()))
+	if err != nil {
+		writeStoreError(c, "file", c.Param("id"), err)
+		return
+	}
+	c.JSON(http.StatusOK, fileView(f))
+}
+
+// FileContent handles GET /v1/files/:id/content.
+func (s *Service) FileContent(c *gin.Context) {
+	st := s.enabledStore(c)
+	if st == nil {
+		return
+	}
+	f, err := st.file(c.Param("id"), ownerOf(c), idgen.Now(c.Request.Context()))
+	if err != nil {
+		writeStoreError(c, "file", c.Param("id"), err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", f.Filename))
+	c.File(st.contentPath(f.ID))
+}
+
+// DeleteFile handles DELETE /v1/files/:id.
+func (s *Service) DeleteFile(c *gin.Context) {
+	st := s.enabledStore(c)
+	if st == nil {
+		return
+	}
+	id := c.Param("id")
+	if _, err := st.file(id, ownerOf(c), idgen.Now(c.Request.Context())); err != nil {
+		writeStoreError(c, "file", id, err)
+		return
+	}
+	if err := st.deleteFile(id); err != nil {
+		writeStoreError(c, "file", id, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "file", "deleted": true})
+}
+
+// CreateBatch handles POST /v1/batches. The batch is queued and runs in the background; its
+// requests run as the API key that created it.
+func (s *Service) CreateBatch(c *gin.Context) {
+	st := s.enabledStore(c)
+	if st == nil {
+		return
+	}
+	rawJSON, err := c.GetRawData()
+	if err != nil || !gjson.ValidBytes(rawJSON) {
+		writeError(c, http.StatusBadRequest, "Invalid request: body must be a JSON object", "invalid_request_error")
+		return
+	}
+	root := gjson.ParseBytes(rawJSON)
+	endpoint := root.Get("endpoint").String()
+	if endpoint != EndpointChatCompletions && endpoint != EndpointEmbeddings {
+		writeError(c, http.StatusBadRequest, fmt.Sprintf("endpoint must be %s or %s", EndpointChatCompletions, EndpointEmbeddings), "invalid_request_error")
+		return
+	}
+	window := root.Get("completion_window").String()
+	if window == "" {
+		window = "24h"
+	}
+	windowDuration, err := time.ParseDuration(window)
+	if err != nil || windowDuration < time.Hour || windowDuration > 7*24*time.Hour {
+		writeError(c, http.StatusBadRequest, "completion_window must be a duration between 1h and 168h, such as 24h", "invalid_request_error")
+		return
+	}
+	ctx := c.Request.Context()
+	owner, inputID := ownerOf(c), root.Get("input_file_id").String()
+	input, err := st.file(inputID, owner, idgen.Now(ctx))
+	if err != nil {
+		writeStoreError(c, "file", inputID, err)
+		return
+	}
+	if input.Purpose != "batch" {
+		writeError(c, http.StatusBadRequest, "input_file_id must refer to a file uploaded with purpose 'batch'", "invalid_request_error")
+		return
+	}
+	var metadata map[string]string
+	if md := root.Get("metadata"); md.IsObject() {
+		metadata = make(map[string]string)
+		md.ForEach(func(key, value gjson.Result) bool {
+			metadata[key.String()] = value.String()
+			return true
+		})
+	}
+	now := idgen.Now(ctx)
+	b := &Batch{
+		ID:               idgen.NewID(ctx, "batch_"),
+		Owner:            owner,
+		Tenant:           c.GetString("apiKey"),
+		Endpoint:         endpoint,
+		InputFileID:      inputID,
+		CompletionWindow: window,
+		Status:           StatusValidating,
+		Metadata:         metadata,
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(windowDuration),
+	}
+	s.mu.Lock()
+	err = st.putBatch(b)
+	s.mu.Unlock()
+	if err != nil {
+		writeStoreError(c, "batch", b.ID, err)
+		return
+	}
+	s.notify()
+	c.JSON(http.StatusOK, batchView(b))
+}
+
+// GetBatch handles GET /v1/batches/:id.
+func (s *Service) GetBatch(c *gin.Context) {
+	st := s.enabledStore(c)
+	if st == nil {
+		return
+	}
+	b, err := st.batch(c.Param("id"), ownerOf(c), true)
+	if err != nil {
+		writeStoreError(c, "batch", c.Param("id"), err)
+		return
+	}
+	c.JSON(http.StatusOK, batchView(b))
+}
+
+// ListBatches handles GET /v1/batches with the ?limit= and ?after= cursor of the OpenAI API,
+// newest first.
+func (s *Service) ListBatches(c *gin.Context) {
+	st := s.enabledStore(c)
+	if st == nil {
+		return
+	}
+	limit, errLimit := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if errLimit != nil || limit < 1 || limit > 100 {
+		writeError(c, http.StatusBadRequest, "limit must be between 1 and 100", "invalid_request_error")
+		return
+	}
+	owner := ownerOf(c)
+	var list []*Batch
+	err := st.each(bucketBatches, func(raw []byte) {
+		b := &Batch{}
+		if json.Unmarshal(raw, b) == nil && b.Owner == owner {
+			list = append(list, b)
+		}
+	})
+	if err != nil {
+		writeStoreError(c, "batch", "", err)
+		return
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	if after := c.Query("after"); after != "" {
+		for i, b := range list {
+			if b.ID == after {
+				list = list[i+1:]
+				break
+			}
+		}
+	}
+	hasMore := len(list) > limit
+	if hasMore {
+		list = list[:limit]
+	}
+	data := make([]gin.H, 0, len(list))
+	for _, b := range list {
+		data = append(data, batchView(b))
+	}
+	resp := gin.H{"object": "list", "data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(list) > 0 {
+		resp["first_id"], resp["last_id"] = list[0].ID, list[len(list)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CancelBatch handles POST /v1/batches/:id/cancel. Requests already running finish; the rest
+// are skipped and the batch ends as cancelled.
+func (s *Service) CancelBatch(c *gin.Context) {
+	st := s.enabledStore(c)
+	if st == nil {
+		return
+	}
+	id := c.Param("id")
+	s.mu.Lock()
+	b, err := st.batch(id, ownerOf(c), true)
+	if err == nil && !b.finished() && b.Status != StatusCancelling {
+		b.Status = StatusCancelling
+		b.CancellingAt = idgen.Now(c.Request.Context())
+		err = st.putBatch(b)
+		if err == nil && s.running == id && s.abort != nil {
+			s.abort()
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeStoreError(c, "batch", id, err)
+		return
+	}
+	s.notify()
+	c.JSON(http.StatusOK, batchView(b))
+}
+
+func unixOrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Unix()
+}
+
+func stringOrNil(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func fileView(f File) gin.H {
+	return gin.H{
+		"id":         f.ID,
+		"object":     "file",
+		"bytes":      f.Bytes,
+		"created_at": f.CreatedAt.Unix(),
+		"expires_at": unixOrNil(f.ExpiresAt),
+		"filename":   f.Filename,
+		"purpose":    f.Purpose,
+		"status":     "processed",
+	}
+}
+
+func batchView(b *Batch) gin.H {
+	var errs any
+	if len(b.Errors) > 0 {
+		errs = gin.H{"object": "list", "data": b.Errors}
+	}
+	var metadata any
+	if len(b.Metadata) > 0 {
+		metadata = b.Metadata
+	}
+	// Result files fill up while the batch runs and are only published once it has finished.
+	var outputID, errorID any
+	if b.finished() {
+		outputID, errorID = stringOrNil(b.OutputFileID), stringOrNil(b.ErrorFileID)
+	}
+	return gin.H{
+		"id":                b.ID,
+		"object":            "batch",
+		"endpoint":          b.Endpoint,
+		"errors":            errs,
+		"input_file_id":     b.InputFileID,
+		"completion_window": b.CompletionWindow,
+		"status":            b.Status,
+		"output_file_id":    outputID,
+		"error_file_id":     errorID,
+		"created_at":        b.CreatedAt.Unix(),
+		"in_progress_at":    unixOrNil(b.InProgressAt),
+		"expires_at":        unixOrNil(b.ExpiresAt),
+		"finalizing_at":     unixOrNil(b.FinalizingAt),
+		"completed_at":      unixOrNil(b.CompletedAt),
+		"failed_at":         unixOrNil(b.FailedAt),
+		"expired_at":        unixOrNil(b.ExpiredAt),
+		"cancelling_at":     unixOrNil(b.CancellingAt),
+		"cancelled_at":      unixOrNil(b.CancelledAt),
+		"request_counts":    b.RequestCounts,
+		"metadata":          metadata,
+	}
+}
@@ -0,0 +1,463 @@
+package batches
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Endpoints that batches may target.
+const (
+	EndpointChatCompletions = "/v1/chat/completions"
+	EndpointEmbeddings      = "/v1/embeddings"
+)
+
+const (
+	requestTimeout = 10 * time.Minute
+	maxLineErrors  = 100
+	maxBackoff     = 15 * time.Minute
+)
+
+// request is one line of a batch input file.
+type request struct {
+	Line     int
+	CustomID string
+	Body     []byte
+}
+
+// parseRequests reads a batch input file. Every line must be a request to endpoint with a
+// unique custom_id; problems are reported per line.
+func parseRequests(data []byte, endpoint string, maxRequests int) ([]request, []LineError) {
+	var requests []request
+	var errs []LineError
+	seen := make(map[string]struct{})
+	fail := func(line int, code, message string) {
+		if len(errs) < maxLineErrors {
+			errs = append(errs, LineError{Code: code, Message: message, Line: line})
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		if !gjson.ValidBytes(raw) {
+			fail(line, "invalid_json_line", "This line is not parseable as valid JSON.")
+			continue
+		}
+		root := gjson.ParseBytes(raw)
+		customID := root.Get("custom_id").String()
+		switch {
+		case customID == "":
+			fail(line, "missing_required_parameter", "custom_id is required.")
+			continue
+		case !strings.EqualFold(root.Get("method").String(), http.MethodPost):
+			fail(line, "invalid_value", "method must be POST.")
+			continue
+		case root.Get("url").String() != endpoint:
+			fail(line, "mismatched_endpoint", fmt.Sprintf("The url %q does not match the batch endpoint %s.", root.Get("url").String(), endpoint))
+			continue
+		case !root.Get("body").IsObject() || root.Get("body.model").String() == "":
+			fail(line, "invalid_request", "body must be an object with a model.")
+			continue
+		}
+		if _, dup := seen[customID]; dup {
+			fail(line, "duplicate_custom_id", fmt.Sprintf("The custom_id %q is used more than once.", customID))
+			continue
+		}
+		seen[customID] = struct{}{}
+		requests = append(requests, request{Line: line, CustomID: customID, Body: []byte(root.Get("body").Raw)})
+	}
+	if err := scanner.Err(); err != nil {
+		fail(line+1, "invalid_json_line", err.Error())
+	}
+	if len(requests) == 0 && len(errs) == 0 {
+		fail(0, "empty_file", "The input file contains no requests.")
+	}
+	if len(requests) > maxRequests {
+		fail(0, "too_many_requests", fmt.Sprintf("The input file has %d requests; the limit is %d.", len(requests), maxRequests))
+	}
+	return requests, errs
+}
+
+// run takes a batch through validation and execution to a final status. It returns early,
+// leaving the batch to be resumed, when ctx is cancelled.
+func (s *Service) run(ctx context.Context, b *Batch) {
+	s.mu.Lock()
+	current, err := s.store.batch(b.ID, "", false)
+	if err != nil {
+		s.mu.Unlock()
+		return
+	}
+	b = current
+	if b.Status == StatusCancelling {
+		s.mu.Unlock()
+		s.finish(b, StatusCancelled)
+		return
+	}
+	runCtx, abort := context.WithDeadline(ctx, b.ExpiresAt)
+	defer abort()
+	s.running, s.abort = b.ID, abort
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running, s.abort = "", nil
+		s.mu.Unlock()
+	}()
+
+	requests, ok := s.prepare(b)
+	if !ok {
+		return
+	}
+	done := s.doneIDs(b)
+	cfg := s.config()
+	workers := cfg.Concurrency
+	if workers <= 0 {
+		workers = defaultConcurrency
+	}
+	log.Infof("batch %s: running %d of %d requests against %s", b.ID, len(requests)-len(done), len(requests), b.Endpoint)
+
+	var mu sync.Mutex
+	queue := make(chan request)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range queue {
+				line, failed, errRun := s.execute(runCtx, b, req)
+				if errRun != nil {
+					continue
+				}
+				mu.Lock()
+				target := b.OutputFileID
+				if failed {
+					target = b.ErrorFileID
+					b.RequestCounts.Failed++
+				} else {
+					b.RequestCounts.Completed++
+				}
+				if errWrite := s.appendLine(target, line); errWrite != nil {
+					log.Errorf("batch %s: failed to write result of %s: %v", b.ID, req.CustomID, errWrite)
+				}
+				s.saveProgress(b)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, req := range requests {
+		if _, skip := done[req.CustomID]; skip {
+			continue
+		}
+		if runCtx.Err() != nil {
+			break
+		}
+		queue <- req
+	}
+	close(queue)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+	if latest, errGet := s.store.batch(b.ID, "", false); errGet == nil && latest.Status == StatusCancelling {
+		b.Status, b.CancellingAt = latest.Status, latest.CancellingAt
+		s.finish(b, StatusCancelled)
+		return
+	}
+	if runCtx.Err() != nil {
+		// The completion window ran out: report the requests that never ran.
+		done = s.doneIDs(b)
+		for _, req := range requests {
+			if _, skip := done[req.CustomID]; skip {
+				continue
+			}
+			line := resultLine(idgen.NewID(ctx, "batch_req_"), req.CustomID, 0, nil, `{"code":"batch_expired","message":"This request could not be executed before the completion window expired."}`)
+			_ = s.appendLine(b.ErrorFileID, line)
+		}
+		s.finish(b, StatusExpired)
+		return
+	}
+	s.finish(b, StatusCompleted)
+}
+
+// prepare validates a new batch and creates its output files. It returns the requests to run,
+// or false when the batch failed.
+func (s *Service) prepare(b *Batch) ([]request, bool) {
+	data, err := os.ReadFile(s.store.contentPath(b.InputFileID))
+	if err != nil {
+		b.Errors = []LineError{{Code: "invalid_file", Message: "The input file no longer exists."}}
+		s.finish(b, StatusFailed)
+		return nil, false
+	}
+	maxRequests := s.config().MaxRequests
+	if maxRequests <= 0 {
+		maxRequests = defaultMaxRequests
+	}
+	requests, errs := parseRequests(data, b.Endpoint, maxRequests)
+	if len(errs) > 0 {
+		b.Errors = errs
+		s.finish(b, StatusFailed)
+		return nil, false
+	}
+	if b.Status == StatusValidating {
+		now := time.Now()
+		for _, f := range []struct {
+			id      *string
+			purpose string
+		}{{&b.OutputFileID, "batch_output"}, {&b.ErrorFileID, "batch_error"}} {
+			file := File{
+				ID:        idgen.NewID(context.Background(), "file-"),
+				Owner:     b.Owner,
+				Filename:  b.ID + "_" + strings.TrimPrefix(f.purpose, "batch_") + ".jsonl",
+				Purpose:   f.purpose,
+				CreatedAt: now,
+			}
+			if err = os.WriteFile(s.store.contentPath(file.ID), nil, 0o600); err == nil {
+				err = s.store.putFile(file)
+			}
+			if err != nil {
+				log.Errorf("batch %s: failed to create output file: %v", b.ID, err)
+				b.Errors = []LineError{{Code: "server_error", Message: "The output file could not be created."}}
+				s.finish(b, StatusFailed)
+				return nil, false
+			}
+			*f.id = file.ID
+		}
+		b.Status = StatusInProgress
+		b.InProgressAt = now
+		b.RequestCounts = RequestCounts{Total: len(requests)}
+		s.saveProgress(b)
+	}
+	return requests, true
+}
+
+// doneIDs returns the custom_ids already present in the output and error files of b.
+func (s *Service) doneIDs(b *Batch) map[string]struct{} {
+	done := make(map[string]struct{})
+	for _, id := range []string{b.OutputFileID, b.ErrorFileID} {
+		data, err := os.ReadFile(s.store.contentPath(id))
+		if err != nil {
+			continue
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if customID := gjson.GetBytes(line, "custom_id").String(); customID != "" {
+				done[customID] = struct{}{}
+			}
+		}
+	}
+	return done
+}
+
+// execute runs one request, retrying rate-limited and unavailable responses. It returns the
+// result line and whether it belongs in the error file; err is set when ctx ended first.
+func (s *Service) execute(ctx context.Context, b *Batch, req request) (line []byte, failed bool, err error) {
+	body, model, errMsg := requestBody(b.Endpoint, req.Body)
+	maxRetries := s.config().MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	id := idgen.NewID(ctx, "batch_req_")
+	for attempt := 0; errMsg == nil; attempt++ {
+		if err = s.pace(ctx); err != nil {
+			return nil, false, err
+		}
+		callCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		callCtx = requestctx.WithMetadata(callCtx, requestctx.Metadata{
+			HandlerType:   s.handler.HandlerType(),
+			Tenant:        b.Tenant,
+			CorrelationID: id,
+		})
+		var resp []byte
+		if b.Endpoint == EndpointEmbeddings {
+			resp, errMsg = s.handler.ExecuteEmbeddingsWithAuthManager(callCtx, s.handler.HandlerType(), model, body)
+		} else {
+			resp, errMsg = s.handler.ExecuteWithAuthManager(callCtx, s.handler.HandlerType(), model, body, "")
+		}
+		cancel()
+		if errMsg == nil {
+			return resultLine(id, req.CustomID, http.StatusOK, resp, "null"), false, nil
+		}
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		if !retryable(errMsg.StatusCode) || attempt >= maxRetries {
+			break
+		}
+		delay := retryDelay(errMsg, attempt)
+		log.Debugf("batch %s: %s got %d, retrying in %s", b.ID, req.CustomID, errMsg.StatusCode, delay)
+		s.backoff(delay)
+		errMsg = nil
+	}
+	status := errMsg.StatusCode
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	message := "request failed"
+	if errMsg.Error != nil {
+		message = errMsg.Error.Error()
+	}
+	errBody, _ := sjson.SetBytes([]byte(`{"error":{"type":"invalid_request_error"}}`), "error.message", message)
+	if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+		errBody, _ = sjson.SetBytes(errBody, "error.type", "server_error")
+	}
+	return resultLine(id, req.CustomID, status, errBody, "null"), true, nil
+}
+
+// requestBody prepares the body of a batch request for the handler and returns its model.
+func requestBody(endpoint string, raw []byte) ([]byte, string, *interfaces.ErrorMessage) {
+	model := gjson.GetBytes(raw, "model").String()
+	if endpoint == EndpointEmbeddings {
+		input := gjson.GetBytes(raw, "input")
+		switch {
+		case input.Type == gjson.String:
+			raw, _ = sjson.SetBytes(raw, "input", []string{input.String()})
+		case input.IsArray():
+			for _, item := range input.Array() {
+				if item.Type != gjson.String {
+					return nil, model, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New("input must be a string or an array of strings")}
+				}
+			}
+		default:
+			return nil, model, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New("input is required")}
+		}
+		return raw, model, nil
+	}
+	raw, _ = sjson.DeleteBytes(raw, "stream")
+	raw, _ = sjson.DeleteBytes(raw, "stream_options")
+	return raw, model, nil
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529:
+		return true
+	}
+	return false
+}
+
+// retryDelay honours a Retry-After header and otherwise backs off exponentially from 30s.
+func retryDelay(errMsg *interfaces.ErrorMessage, attempt int) time.Duration {
+	if errMsg.Addon != nil {
+		if seconds, err := strconv.Atoi(strings.TrimSpace(errMsg.Addon.Get("Retry-After"))); err == nil && seconds > 0 {
+			return min(time.Duration(seconds)*time.Second, maxBackoff)
+		}
+	}
+	return min(30*time.Second<<min(attempt, 5), maxBackoff)
+}
+
+// resultLine renders one line of a batch output or error file. A zero status leaves the
+// response null, for requests that never ran.
+func resultLine(id, customID string, status int, body []byte, errJSON string) []byte {
+	line := []byte(`{"id":"","custom_id":"","response":null,"error":null}`)
+	line, _ = sjson.SetBytes(line, "id", id)
+	line, _ = sjson.SetBytes(line, "custom_id", customID)
+	if status != 0 {
+		resp := []byte(`{"status_code":0,"request_id":"","body":null}`)
+		resp, _ = sjson.SetBytes(resp, "status_code", status)
+		resp, _ = sjson.SetBytes(resp, "request_id", id)
+		if gjson.ValidBytes(body) {
+			resp, _ = sjson.SetRawBytes(resp, "body", body)
+		} else {
+			resp, _ = sjson.SetBytes(resp, "body", string(body))
+		}
+		line, _ = sjson.SetRawBytes(line, "response", resp)
+	}
+	line, _ = sjson.SetRawBytes(line, "error", []byte(errJSON))
+	return line
+}
+
+// appendLine appends a JSONL line to the content of file id.
+func (s *Service) appendLine(id string, line []byte) error {
+	f, err := os.OpenFile(s.store.contentPath(id), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// saveProgress stores b unless it was cancelled meanwhile, in which case only the counts
+// are taken over so that the cancellation is not lost.
+func (s *Service) saveProgress(b *Batch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if latest, err := s.store.batch(b.ID, "", false); err == nil && latest.Status == StatusCancelling && b.Status != StatusCancelling {
+		b.Status, b.CancellingAt = latest.Status, latest.CancellingAt
+	}
+	if err := s.store.putBatch(b); err != nil {
+		log.Errorf("batch %s: failed to save progress: %v", b.ID, err)
+	}
+}
+
+// finish moves b to a final status, sizes its output files and schedules its removal. Output
+// files that stayed empty are dropped.
+func (s *Service) finish(b *Batch, status string) {
+	now := time.Now()
+	retention := s.retention()
+	for _, id := range []*string{&b.OutputFileID, &b.ErrorFileID} {
+		if *id == "" {
+			continue
+		}
+		var f File
+		if err := s.store.get(bucketFiles, *id, &f); err != nil {
+			*id = ""
+			continue
+		}
+		info, err := os.Stat(s.store.contentPath(f.ID))
+		if err != nil || info.Size() == 0 {
+			_ = s.store.deleteFile(f.ID)
+			*id = ""
+			continue
+		}
+		f.Bytes = info.Size()
+		f.ExpiresAt = now.Add(retention)
+		_ = s.store.putFile(f)
+	}
+	if status == StatusCompleted {
+		b.FinalizingAt = now
+	}
+	b.Status = status
+	switch status {
+	case StatusCompleted:
+		b.CompletedAt = now
+	case StatusFailed:
+		b.FailedAt = now
+	case StatusExpired:
+		b.ExpiredAt = now
+	case StatusCancelled:
+		b.CancelledAt = now
+	}
+	b.DeleteAt = now.Add(retention)
+	s.mu.Lock()
+	err := s.store.putBatch(b)
+	s.mu.Unlock()
+	if err != nil {
+		log.Errorf("batch %s: failed to save final status: %v", b.ID, err)
+		return
+	}
+	log.Infof("batch %s %s: %d completed, %d failed of %d", b.ID, status, b.RequestCounts.Completed, b.RequestCounts.Failed, b.RequestCounts.Total)
+}
@@ -0,0 +1,205 @@
+// Package batches emulates the OpenAI Files and Batch APIs. Uploaded JSONL request files are
+// run in the background through the OpenAI handler, so batch requests take the same routing,
+// aliasing and account selection as client requests, and the results are kept as output files.
+package batches
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultConcurrency     = 1
+	defaultRequestInterval = time.Second
+	defaultMaxRetries      = 8
+	defaultMaxFileMB       = 200
+	defaultMaxRequests     = 50000
+	defaultRetention       = 30 * 24 * time.Hour
+	sweepInterval          = time.Hour
+	idleRecheck            = time.Minute
+)
+
+// Service stores files and batches and runs queued batches one at a time.
+type Service struct {
+	handler *openai.OpenAIAPIHandler
+	dataDir string
+
+	mu      sync.Mutex
+	cfg     config.BatchesConfig
+	store   *store
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running string
+	abort   context.CancelFunc
+
+	paceMu    sync.Mutex
+	notBefore time.Time
+}
+
+// NewService returns a service executing requests with handler and keeping its data in
+// <working dir>/data.
+func NewService(handler *openai.OpenAIAPIHandler) *Service {
+	wd, err := os.Getwd()
+	if err != nil || wd == "" {
+		wd = "."
+	}
+	return &Service{handler: handler, dataDir: filepath.Join(wd, "data"), wake: make(chan struct{}, 1)}
+}
+
+// Configure applies cfg, starting the runner when batches are enabled and stopping it when
+// they are not. Batches left unfinished by a previous run are resumed.
+func (s *Service) Configure(cfg config.BatchesConfig) {
+	s.mu.Lock()
+	s.cfg = cfg
+	if !cfg.Enabled {
+		s.mu.Unlock()
+		s.Stop()
+		return
+	}
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	if s.store == nil {
+		st, err := openStore(s.dataDir)
+		if err != nil {
+			log.Errorf("batches: failed to open store: %v", err)
+			return
+		}
+		s.store = st
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go s.dispatch(ctx)
+}
+
+// Stop interrupts the running batch and waits for the runner to return. Interrupted requests
+// are run again when the service is started next.
+func (s *Service) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		s.wg.Wait()
+	}
+}
+
+func (s *Service) config() config.BatchesConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+// storeIfEnabled returns the store while batches are enabled.
+func (s *Service) storeIfEnabled() *store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.cfg.Enabled {
+		return nil
+	}
+	return s.store
+}
+
+func (s *Service) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) dispatch(ctx context.Context) {
+	defer s.wg.Done()
+	var lastSweep time.Time
+	for {
+		if now := time.Now(); now.Sub(lastSweep) >= sweepInterval {
+			lastSweep = now
+			s.store.sweep(now)
+		}
+		if b := s.nextBatch(); b != nil {
+			s.run(ctx, b)
+		} else {
+			timer := time.NewTimer(idleRecheck)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-s.wake:
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// nextBatch returns the oldest unfinished batch.
+func (s *Service) nextBatch() *Batch {
+	var pending []*Batch
+	_ = s.store.each(bucketBatches, func(raw []byte) {
+		b := &Batch{}
+		if json.Unmarshal(raw, b) == nil && !b.finished() {
+			pending = append(pending, b)
+		}
+	})
+	if len(pending) == 0 {
+		return nil
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending[0]
+}
+
+// pace waits for the next request slot. Slots are RequestIntervalMs apart and are pushed
+// back by backoff after rate limiting.
+func (s *Service) pace(ctx context.Context) error {
+	interval := defaultRequestInterval
+	if ms := s.config().RequestIntervalMs; ms > 0 {
+		interval = time.Duration(ms) * time.Millisecond
+	}
+	s.paceMu.Lock()
+	start := time.Now()
+	if s.notBefore.After(start) {
+		start = s.notBefore
+	}
+	s.notBefore = start.Add(interval)
+	s.paceMu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff holds every request of the service for d.
+func (s *Service) backoff(d time.Duration) {
+	s.paceMu.Lock()
+	defer s.paceMu.Unlock()
+	if until := time.Now().Add(d); until.After(s.notBefore) {
+		s.notBefore = until
+	}
+}
+
+func (s *Service) retention() time.Duration {
+	if h := s.config().RetentionHours; h > 0 {
+		return time.Duration(h) * time.Hour
+	}
+	return defaultRetention
+}
@@ -0,0 +1,228 @@
+package batches
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	bucketFiles   = "files"
+	bucketBatches = "batches"
+	dbFile        = "batches.bolt"
+	contentDir    = "batch-files"
+)
+
+// ErrNotFound is returned for unknown, expired or foreign files and batches.
+var ErrNotFound = errors.New("not found")
+
+// File is an uploaded input file or a generated output file. The content lives on disk next
+// to the database.
+type File struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Filename  string    `json:"filename"`
+	Purpose   string    `json:"purpose"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RequestCounts tracks the progress of a batch.
+type RequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// LineError reports an invalid line of a batch input file.
+type LineError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// Batch is one batch job.
+type Batch struct {
+	ID    string `json:"id"`
+	Owner string `json:"owner"`
+	// Tenant is the client API key the requests run as, so its api-key-rules apply.
+	Tenant           string            `json:"tenant,omitempty"`
+	Endpoint         string            `json:"endpoint"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           string            `json:"status"`
+	OutputFileID     string            `json:"output_file_id,omitempty"`
+	ErrorFileID      string            `json:"error_file_id,omitempty"`
+	Errors           []LineError       `json:"errors,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	RequestCounts    RequestCounts     `json:"request_counts"`
+	CreatedAt        time.Time         `json:"created_at"`
+	InProgressAt     time.Time         `json:"in_progress_at,omitzero"`
+	ExpiresAt        time.Time         `json:"expires_at"`
+	FinalizingAt     time.Time         `json:"finalizing_at,omitzero"`
+	CompletedAt      time.Time         `json:"completed_at,omitzero"`
+	FailedAt         time.Time         `json:"failed_at,omitzero"`
+	ExpiredAt        time.Time         `json:"expired_at,omitzero"`
+	CancellingAt     time.Time         `json:"cancelling_at,omitzero"`
+	CancelledAt      time.Time         `json:"cancelled_at,omitzero"`
+	// DeleteAt is when the finished batch is removed from the store.
+	DeleteAt time.Time `json:"delete_at,omitzero"`
+}
+
+// Batch statuses, as in the OpenAI Batch API.
+const (
+	StatusValidating = "validating"
+	StatusInProgress = "in_progress"
+	StatusFinalizing = "finalizing"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+	StatusExpired    = "expired"
+	StatusCancelling = "cancelling"
+	StatusCancelled  = "cancelled"
+)
+
+// finished reports whether the batch will not run any more requests.
+func (b *Batch) finished() bool {
+	switch b.Status {
+	case StatusCompleted, StatusFailed, StatusExpired, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// store keeps file and batch records in a BoltDB file and file contents in a directory.
+type store struct {
+	db  *bolt.DB
+	dir string
+}
+
+func openStore(dataDir string) (*store, error) {
+	dir := filepath.Join(dataDir, contentDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(dataDir, dbFile), 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{bucketFiles, bucketBatches} {
+			if _, errCreate := tx.CreateBucketIfNotExists([]byte(name)); errCreate != nil {
+				return errCreate
+			}
+		}
+		return nil
+	}); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &store{db: db, dir: dir}, nil
+}
+
+func (s *store) close() error {
+	return s.db.Close()
+}
+
+// contentPath returns where the content of file id is kept.
+func (s *store) contentPath(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id))
+}
+
+func (s *store) put(bucket, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Put([]byte(id), data)
+	})
+}
+
+func (s *store) get(bucket, id string, v any) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket([]byte(bucket)).Get([]byte(id))
+		if raw == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(raw, v)
+	})
+}
+
+func (s *store) delete(bucket, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Delete([]byte(id))
+	})
+}
+
+// each calls fn with the raw value of every record in bucket.
+func (s *store) each(bucket string, fn func(raw []byte)) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).ForEach(func(_, v []byte) error {
+			fn(v)
+			return nil
+		})
+	})
+}
+
+func (s *store) putFile(f File) error { return s.put(bucketFiles, f.ID, f) }
+
+// file returns the file with id when it belongs to owner and has not expired at now.
+func (s *store) file(id, owner string, now time.Time) (File, error) {
+	var f File
+	if err := s.get(bucketFiles, id, &f); err != nil {
+		return File{}, err
+	}
+	if f.Owner != owner || (!f.ExpiresAt.IsZero() && now.After(f.ExpiresAt)) {
+		return File{}, ErrNotFound
+	}
+	return f, nil
+}
+
+func (s *store) deleteFile(id string) error {
+	if err := os.Remove(s.contentPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return s.delete(bucketFiles, id)
+}
+
+func (s *store) putBatch(b *Batch) error { return s.put(bucketBatches, b.ID, b) }
+
+// batch returns the batch with id; owner is checked unless checkOwner is false.
+func (s *store) batch(id, owner string, checkOwner bool) (*Batch, error) {
+	b := &Batch{}
+	if err := s.get(bucketBatches, id, b); err != nil {
+		return nil, err
+	}
+	if checkOwner && b.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// sweep removes expired files and batches finished long enough ago.
+func (s *store) sweep(now time.Time) {
+	var files, batches []string
+	_ = s.each(bucketFiles, func(raw []byte) {
+		var f File
+		if json.Unmarshal(raw, &f) == nil && !f.ExpiresAt.IsZero() && now.After(f.ExpiresAt) {
+			files = append(files, f.ID)
+		}
+	})
+	_ = s.each(bucketBatches, func(raw []byte) {
+		var b Batch
+		if json.Unmarshal(raw, &b) == nil && b.finished() && !b.DeleteAt.IsZero() && now.After(b.DeleteAt) {
+			batches = append(batches, b.ID)
+		}
+	})
+	for _, id := range files {
+		_ = s.deleteFile(id)
+	}
+	for _, id := range batches {
+		_ = s.delete(bucketBatches, id)
+	}
+}
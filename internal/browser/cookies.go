@@ -0,0 +1,218 @@
+package browser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Browsers whose cookie stores ReadCookies understands.
+const (
+	Chrome   = "chrome"
+	Chromium = "chromium"
+	Edge     = "edge"
+	Brave    = "brave"
+	Firefox  = "firefox"
+)
+
+// errUnsupportedEncryption is returned for cookie values encrypted with a scheme that cannot be
+// decrypted outside the browser.
+var errUnsupportedEncryption = errors.New("unsupported cookie encryption")
+
+// Cookie is one cookie read from a browser profile.
+type Cookie struct {
+	Host  string
+	Name  string
+	Value string
+	// Expires is zero for session cookies.
+	Expires time.Time
+}
+
+// Profile is a local browser profile that has a cookie store.
+type Profile struct {
+	Browser string `json:"browser"`
+	Name    string `json:"profile"`
+	// CookiePath is the profile's cookie database.
+	CookiePath string `json:"cookie_path"`
+	// userDataDir is the Chromium directory holding "Local State"; empty for Firefox.
+	userDataDir string
+}
+
+// FindProfiles returns the profiles of the installed browsers, or of one browser when name is
+// not empty, sorted by browser and profile name.
+func FindProfiles(name string) []Profile {
+	name = strings.ToLower(strings.TrimSpace(name))
+	var profiles []Profile
+	for browserName, dir := range chromiumUserDataDirs() {
+		if name != "" && name != browserName {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			for _, rel := range []string{filepath.Join("Network", "Cookies"), "Cookies"} {
+				path := filepath.Join(dir, entry.Name(), rel)
+				if fileExists(path) {
+					profiles = append(profiles, Profile{Browser: browserName, Name: entry.Name(), CookiePath: path, userDataDir: dir})
+					break
+				}
+			}
+		}
+	}
+	if name == "" || name == Firefox {
+		for _, dir := range firefoxProfileDirs() {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				path := filepath.Join(dir, entry.Name(), "cookies.sqlite")
+				if entry.IsDir() && fileExists(path) {
+					profiles = append(profiles, Profile{Browser: Firefox, Name: entry.Name(), CookiePath: path})
+				}
+			}
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Browser != profiles[j].Browser {
+			return profiles[i].Browser < profiles[j].Browser
+		}
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// ReadCookies returns the cookies of profile set for domain or any of its subdomains. Chromium
+// cookies are decrypted with the key from the operating system's keystore, which may ask the
+// user for permission. The store is read without locking, so it works while the browser runs.
+func ReadCookies(profile Profile, domain string) ([]Cookie, error) {
+	db, err := openSQLite(profile.CookiePath)
+	if err != nil {
+		return nil, err
+	}
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	matches := func(host string) bool {
+		host = strings.TrimPrefix(strings.ToLower(host), ".")
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	}
+	if profile.Browser == Firefox {
+		return readFirefoxCookies(db, matches)
+	}
+	return readChromiumCookies(db, profile, matches)
+}
+
+func readFirefoxCookies(db *sqliteDB, matches func(string) bool) ([]Cookie, error) {
+	var cookies []Cookie
+	err := db.rows("moz_cookies", func(row map[string]any) {
+		host := rowString(row, "host")
+		if !matches(host) {
+			return
+		}
+		cookie := Cookie{Host: host, Name: rowString(row, "name"), Value: rowString(row, "value")}
+		if expiry := rowInt(row, "expiry"); expiry > 0 {
+			// Newer releases store milliseconds.
+			if expiry > 1e11 {
+				cookie.Expires = time.UnixMilli(expiry)
+			} else {
+				cookie.Expires = time.Unix(expiry, 0)
+			}
+		}
+		cookies = append(cookies, cookie)
+	})
+	return cookies, err
+}
+
+// chromiumEpochOffset is the number of microseconds between 1601-01-01, the epoch of Chromium
+// timestamps, and the Unix epoch.
+const chromiumEpochOffset = 11644473600 * 1000000
+
+func readChromiumCookies(db *sqliteDB, profile Profile, matches func(string) bool) ([]Cookie, error) {
+	// Since schema version 24 the plaintext starts with the SHA-256 of the host.
+	version := int64(0)
+	_ = db.rows("meta", func(row map[string]any) {
+		if rowString(row, "key") == "version" {
+			version = rowInt(row, "value")
+		}
+	})
+	var decrypt func([]byte) ([]byte, error)
+	var decryptErr error
+	var cookies []Cookie
+	var failed error
+	err := db.rows("cookies", func(row map[string]any) {
+		host := rowString(row, "host_key")
+		if !matches(host) {
+			return
+		}
+		cookie := Cookie{Host: host, Name: rowString(row, "name"), Value: rowString(row, "value")}
+		if expires := rowInt(row, "expires_utc"); expires > chromiumEpochOffset {
+			cookie.Expires = time.UnixMicro(expires - chromiumEpochOffset)
+		}
+		encrypted, _ := row["encrypted_value"].([]byte)
+		if cookie.Value == "" && len(encrypted) > 0 {
+			if decrypt == nil && decryptErr == nil {
+				decrypt, decryptErr = chromiumDecrypter(profile.Browser, profile.userDataDir)
+			}
+			if decryptErr != nil {
+				failed = decryptErr
+				return
+			}
+			plain, errDecrypt := decrypt(encrypted)
+			if errDecrypt != nil {
+				failed = fmt.Errorf("failed to decrypt cookie %s: %w", cookie.Name, errDecrypt)
+				return
+			}
+			if version >= 24 && len(plain) >= 32 {
+				plain = plain[32:]
+			}
+			cookie.Value = string(plain)
+		}
+		cookies = append(cookies, cookie)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(cookies) == 0 && failed != nil {
+		return nil, failed
+	}
+	return cookies, nil
+}
+
+func rowString(row map[string]any, column string) string {
+	switch v := row[column].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return ""
+}
+
+func rowInt(row map[string]any, column string) int64 {
+	switch v := row[column].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case string:
+		n, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n
+	}
+	return 0
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,37 @@
+//go:build linux || darwin
+
+package browser
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"errors"
+)
+
+// chromiumCBCKey derives the AES-128 key Chromium uses on Linux and macOS from the keystore
+// password.
+func chromiumCBCKey(password string, iterations int) []byte {
+	key, _ := pbkdf2.Key(sha1.New, password, []byte("saltysalt"), iterations, 16)
+	return key
+}
+
+// decryptChromiumCBC decrypts a value without its "v10"/"v11" prefix.
+func decryptChromiumCBC(key, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid ciphertext length")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, bytes.Repeat([]byte{' '}, aes.BlockSize)).CryptBlocks(plain, ciphertext)
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(plain) {
+		return nil, errors.New("invalid padding; wrong key?")
+	}
+	return plain[:len(plain)-pad], nil
+}
@@ -0,0 +1,51 @@
+package browser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func chromiumUserDataDirs() map[string]string {
+	home, _ := os.UserHomeDir()
+	support := filepath.Join(home, "Library", "Application Support")
+	return map[string]string{
+		Chrome:   filepath.Join(support, "Google", "Chrome"),
+		Chromium: filepath.Join(support, "Chromium"),
+		Edge:     filepath.Join(support, "Microsoft Edge"),
+		Brave:    filepath.Join(support, "BraveSoftware", "Brave-Browser"),
+	}
+}
+
+func firefoxProfileDirs() []string {
+	home, _ := os.UserHomeDir()
+	return []string{filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles")}
+}
+
+// keychainServices are the Keychain items holding each browser's cookie password.
+var keychainServices = map[string]string{
+	Chrome:   "Chrome Safe Storage",
+	Chromium: "Chromium Safe Storage",
+	Edge:     "Microsoft Edge Safe Storage",
+	Brave:    "Brave Safe Storage",
+}
+
+// chromiumDecrypter reads the cookie password from the Keychain; macOS asks the user to allow
+// the access.
+func chromiumDecrypter(browser, _ string) (func([]byte) ([]byte, error), error) {
+	service := keychainServices[browser]
+	out, err := exec.Command("security", "find-generic-password", "-w", "-s", service).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q from the Keychain: %w", service, err)
+	}
+	key := chromiumCBCKey(strings.TrimSpace(string(out)), 1003)
+	return func(value []byte) ([]byte, error) {
+		if !bytes.HasPrefix(value, []byte("v10")) {
+			return nil, errUnsupportedEncryption
+		}
+		return decryptChromiumCBC(key, value[3:])
+	}, nil
+}
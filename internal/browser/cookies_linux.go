@@ -0,0 +1,68 @@
+package browser
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func chromiumUserDataDirs() map[string]string {
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		home, _ := os.UserHomeDir()
+		config = filepath.Join(home, ".config")
+	}
+	return map[string]string{
+		Chrome:   filepath.Join(config, "google-chrome"),
+		Chromium: filepath.Join(config, "chromium"),
+		Edge:     filepath.Join(config, "microsoft-edge"),
+		Brave:    filepath.Join(config, "BraveSoftware", "Brave-Browser"),
+	}
+}
+
+func firefoxProfileDirs() []string {
+	home, _ := os.UserHomeDir()
+	return []string{
+		filepath.Join(home, ".mozilla", "firefox"),
+		filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox"),
+		filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox"),
+	}
+}
+
+// keyringApplications are the "application" attributes under which each browser stores its
+// password in the Secret Service keyring.
+var keyringApplications = map[string]string{
+	Chrome:   "chrome",
+	Chromium: "chromium",
+	Edge:     "microsoft-edge",
+	Brave:    "brave",
+}
+
+// chromiumDecrypter handles "v10" values, encrypted with a fixed password when no keyring is
+// available, and "v11" values, encrypted with a password from the keyring that is looked up
+// with secret-tool.
+func chromiumDecrypter(browser, _ string) (func([]byte) ([]byte, error), error) {
+	v10 := chromiumCBCKey("peanuts", 1)
+	var v11 []byte
+	keyringErr := errors.New("the browser's keyring password is unavailable; install secret-tool (libsecret-tools) and unlock the keyring")
+	if out, err := exec.Command("secret-tool", "lookup", "application", keyringApplications[browser]).Output(); err == nil {
+		if password := strings.TrimSpace(string(out)); password != "" {
+			v11 = chromiumCBCKey(password, 1)
+		}
+	}
+	return func(value []byte) ([]byte, error) {
+		switch {
+		case bytes.HasPrefix(value, []byte("v10")):
+			return decryptChromiumCBC(v10, value[3:])
+		case bytes.HasPrefix(value, []byte("v11")):
+			if v11 == nil {
+				return nil, keyringErr
+			}
+			return decryptChromiumCBC(v11, value[3:])
+		}
+		return nil, errUnsupportedEncryption
+	}, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package browser
+
+import "errors"
+
+func chromiumUserDataDirs() map[string]string { return nil }
+
+func firefoxProfileDirs() []string { return nil }
+
+func chromiumDecrypter(_, _ string) (func([]byte) ([]byte, error), error) {
+	return nil, errors.New("reading Chromium cookies is not supported on this platform")
+}
@@ -0,0 +1,90 @@
+package browser
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+func chromiumUserDataDirs() map[string]string {
+	local := os.Getenv("LOCALAPPDATA")
+	return map[string]string{
+		Chrome:   filepath.Join(local, "Google", "Chrome", "User Data"),
+		Chromium: filepath.Join(local, "Chromium", "User Data"),
+		Edge:     filepath.Join(local, "Microsoft", "Edge", "User Data"),
+		Brave:    filepath.Join(local, "BraveSoftware", "Brave-Browser", "User Data"),
+	}
+}
+
+func firefoxProfileDirs() []string {
+	return []string{filepath.Join(os.Getenv("APPDATA"), "Mozilla", "Firefox", "Profiles")}
+}
+
+// chromiumDecrypter unwraps the AES-256 key in "Local State" with DPAPI. Values with the
+// "v20" prefix use app-bound encryption, which only the browser itself can undo.
+func chromiumDecrypter(_, userDataDir string) (func([]byte) ([]byte, error), error) {
+	state, err := os.ReadFile(filepath.Join(userDataDir, "Local State"))
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(gjson.GetBytes(state, "os_crypt.encrypted_key").String())
+	if err != nil || !bytes.HasPrefix(wrapped, []byte("DPAPI")) {
+		return nil, errors.New("Local State has no usable os_crypt.encrypted_key")
+	}
+	key, err := dpapiDecrypt(wrapped[5:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap the cookie key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return func(value []byte) ([]byte, error) {
+		switch {
+		case bytes.HasPrefix(value, []byte("v20")):
+			return nil, errors.New("app-bound encrypted cookies cannot be read outside the browser; use Firefox or paste the cookies manually")
+		case bytes.HasPrefix(value, []byte("v10")) || bytes.HasPrefix(value, []byte("v11")):
+			if len(value) < 3+12+gcm.Overhead() {
+				return nil, errors.New("invalid ciphertext length")
+			}
+			return gcm.Open(nil, value[3:15], value[15:], nil)
+		}
+		// Values from before Chrome 80 are encrypted with DPAPI directly.
+		return dpapiDecrypt(value)
+	}, nil
+}
+
+var procCryptUnprotectData = syscall.NewLazyDLL("crypt32.dll").NewProc("CryptUnprotectData")
+
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+// dpapiDecrypt decrypts data protected for the current user.
+func dpapiDecrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty DPAPI blob")
+	}
+	in := dataBlob{size: uint32(len(data)), data: &data[0]}
+	var out dataBlob
+	r, _, err := procCryptUnprotectData.Call(uintptr(unsafe.Pointer(&in)), 0, 0, 0, 0, 0, uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, err
+	}
+	defer func() { _, _ = syscall.LocalFree(syscall.Handle(unsafe.Pointer(out.data))) }()
+	return bytes.Clone(unsafe.Slice(out.data, out.size)), nil
+}
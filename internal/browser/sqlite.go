@@ -0,0 +1,308 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// sqliteDB is a read-only view of an SQLite database file, including the pages committed to
+// its write-ahead log. It implements only what reading browser cookie stores needs: walking
+// table b-trees and decoding records, so that no SQLite library is required.
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	usable   int
+	wal      map[uint32][]byte
+}
+
+func openSQLite(path string) (*sqliteDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 100 || string(data[:16]) != "SQLite format 3\x00" {
+		return nil, fmt.Errorf("%s is not an SQLite database", path)
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 {
+		return nil, fmt.Errorf("%s has an invalid page size", path)
+	}
+	db := &sqliteDB{data: data, pageSize: pageSize, usable: pageSize - int(data[20])}
+	if wal, errWAL := os.ReadFile(path + "-wal"); errWAL == nil {
+		db.wal = parseWAL(wal, pageSize)
+	}
+	return db, nil
+}
+
+// parseWAL returns the latest committed version of every page in a write-ahead log. Frames
+// left over from an earlier log generation carry other salts and end the scan.
+func parseWAL(wal []byte, pageSize int) map[uint32][]byte {
+	if len(wal) < 32 {
+		return nil
+	}
+	if magic := binary.BigEndian.Uint32(wal[0:4]); magic != 0x377f0682 && magic != 0x377f0683 {
+		return nil
+	}
+	if int(binary.BigEndian.Uint32(wal[8:12])) != pageSize {
+		return nil
+	}
+	salts := wal[16:24]
+	committed := make(map[uint32][]byte)
+	pending := make(map[uint32][]byte)
+	for off := 32; off+24+pageSize <= len(wal); off += 24 + pageSize {
+		frame := wal[off : off+24]
+		if !bytes.Equal(frame[8:16], salts) {
+			break
+		}
+		pending[binary.BigEndian.Uint32(frame[0:4])] = wal[off+24 : off+24+pageSize]
+		if binary.BigEndian.Uint32(frame[4:8]) != 0 {
+			for pgno, page := range pending {
+				committed[pgno] = page
+			}
+			clear(pending)
+		}
+	}
+	return committed
+}
+
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	if page, ok := db.wal[n]; ok {
+		return page, nil
+	}
+	start := (int(n) - 1) * db.pageSize
+	if n == 0 || start+db.pageSize > len(db.data) {
+		return nil, fmt.Errorf("page %d out of range", n)
+	}
+	return db.data[start : start+db.pageSize], nil
+}
+
+// rows calls fn with every row of table, keyed by column name. Columns added after a row was
+// written are missing from it.
+func (db *sqliteDB) rows(table string, fn func(map[string]any)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("corrupt database: %v", r)
+		}
+	}()
+	root, columns, err := db.table(table)
+	if err != nil {
+		return err
+	}
+	return db.walk(root, make(map[uint32]bool), func(record []any) {
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		fn(row)
+	})
+}
+
+// table returns the root page and column names of a table from the schema on page 1.
+func (db *sqliteDB) table(name string) (uint32, []string, error) {
+	var root uint32
+	var sql string
+	err := db.walk(1, make(map[uint32]bool), func(record []any) {
+		if len(record) < 5 {
+			return
+		}
+		kind, _ := record[0].(string)
+		tableName, _ := record[1].(string)
+		if kind != "table" || !strings.EqualFold(tableName, name) {
+			return
+		}
+		if page, ok := record[3].(int64); ok {
+			root = uint32(page)
+		}
+		sql, _ = record[4].(string)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if root == 0 {
+		return 0, nil, fmt.Errorf("table %s not found", name)
+	}
+	return root, sqliteColumns(sql), nil
+}
+
+// walk visits the records of the table b-tree rooted at page n in rowid order. Pages already
+// in seen make the tree cyclic, which only a corrupt file can be.
+func (db *sqliteDB) walk(n uint32, seen map[uint32]bool, fn func([]any)) error {
+	if seen[n] {
+		return fmt.Errorf("page %d is linked twice", n)
+	}
+	seen[n] = true
+	page, err := db.page(n)
+	if err != nil {
+		return err
+	}
+	header := 0
+	if n == 1 {
+		header = 100
+	}
+	cells := int(binary.BigEndian.Uint16(page[header+3:]))
+	switch page[header] {
+	case 0x0d: // table leaf
+		for i := 0; i < cells; i++ {
+			offset := int(binary.BigEndian.Uint16(page[header+8+2*i:]))
+			payload, errPayload := db.leafPayload(page, offset)
+			if errPayload != nil {
+				return errPayload
+			}
+			fn(decodeRecord(payload))
+		}
+	case 0x05: // table interior
+		for i := 0; i < cells; i++ {
+			offset := int(binary.BigEndian.Uint16(page[header+12+2*i:]))
+			if err = db.walk(binary.BigEndian.Uint32(page[offset:]), seen, fn); err != nil {
+				return err
+			}
+		}
+		return db.walk(binary.BigEndian.Uint32(page[header+8:]), seen, fn)
+	default:
+		return fmt.Errorf("page %d is not a table page", n)
+	}
+	return nil
+}
+
+// leafPayload returns the payload of the leaf cell at offset, following overflow pages.
+func (db *sqliteDB) leafPayload(page []byte, offset int) ([]byte, error) {
+	size, n := sqliteVarint(page[offset:])
+	_, m := sqliteVarint(page[offset+n:])
+	start := offset + n + m
+	total := int(size)
+	maxLocal := db.usable - 35
+	if total <= maxLocal {
+		return page[start : start+total], nil
+	}
+	minLocal := (db.usable-12)*32/255 - 23
+	local := minLocal + (total-minLocal)%(db.usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	out := make([]byte, 0, total)
+	out = append(out, page[start:start+local]...)
+	next := binary.BigEndian.Uint32(page[start+local:])
+	for next != 0 && len(out) < total {
+		overflow, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = binary.BigEndian.Uint32(overflow)
+		take := min(db.usable-4, total-len(out))
+		out = append(out, overflow[4:4+take]...)
+	}
+	return out, nil
+}
+
+// decodeRecord decodes a record into nil, int64, float64, string or []byte values.
+func decodeRecord(payload []byte) []any {
+	headerLen, n := sqliteVarint(payload)
+	var types []uint64
+	for pos := n; pos < int(headerLen); {
+		t, k := sqliteVarint(payload[pos:])
+		types = append(types, t)
+		pos += k
+	}
+	body := payload[headerLen:]
+	values := make([]any, 0, len(types))
+	for _, t := range types {
+		var size int
+		switch {
+		case t == 0:
+			values = append(values, nil)
+			continue
+		case t == 8 || t == 9:
+			values = append(values, int64(t-8))
+			continue
+		case t >= 1 && t <= 4:
+			size = int(t)
+		case t == 5:
+			size = 6
+		case t == 6 || t == 7:
+			size = 8
+		case t >= 12:
+			size = int(t-12) / 2
+		default:
+			size = 0
+		}
+		raw := body[:size]
+		body = body[size:]
+		switch {
+		case t <= 6:
+			values = append(values, sqliteInt(raw))
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(raw)))
+		case t%2 == 0:
+			values = append(values, bytes.Clone(raw))
+		default:
+			values = append(values, string(raw))
+		}
+	}
+	return values
+}
+
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+func sqliteInt(b []byte) int64 {
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// sqliteColumns returns the column names of a CREATE TABLE statement.
+func sqliteColumns(sql string) []string {
+	open := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if open < 0 || end <= open {
+		return nil
+	}
+	var parts []string
+	depth, start := 0, open+1
+	for i := open + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, sql[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, sql[start:end])
+	columns := make([]string, 0, len(parts))
+	for _, part := range parts {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		columns = append(columns, strings.Trim(fields[0], "\"`[]"))
+	}
+	return columns
+}
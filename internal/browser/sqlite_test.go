@@ -0,0 +1,218 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The fixtures in testdata were written by SQLite 3.40 with 512-byte pages:
+//
+//   - cookies.sqlite holds a Chromium-like cookies table of 121 rows, so that the table is a
+//     two-level b-tree, and one row whose value and encrypted_value spill onto overflow pages.
+//   - wal.sqlite holds 20 rows with the value "old". Its write-ahead log wal.sqlite-wal commits
+//     two more transactions: the first sets the value of c0 to "new", the second inserts the
+//     row "late".
+
+const cookiesFixtureRows = 121
+
+func readCookies(t *testing.T, path string) (map[string]map[string]any, error) {
+	t.Helper()
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	rows := make(map[string]map[string]any)
+	err = db.rows("cookies", func(row map[string]any) {
+		name, _ := row["name"].(string)
+		rows[name] = row
+	})
+	return rows, err
+}
+
+// copyFixture copies the testdata files named to a temporary directory, applying edit to their
+// contents, and returns the path of the first.
+func copyFixture(t *testing.T, edit func(name string, data []byte) []byte, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatalf("read fixture: %v", err)
+		}
+		if edit != nil {
+			data = edit(name, data)
+		}
+		if err = os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatalf("write fixture: %v", err)
+		}
+	}
+	return filepath.Join(dir, names[0])
+}
+
+func TestSQLiteReadsRowsAndOverflowPages(t *testing.T) {
+	rows, err := readCookies(t, filepath.Join("testdata", "cookies.sqlite"))
+	if err != nil {
+		t.Fatalf("rows: %v", err)
+	}
+	if len(rows) != cookiesFixtureRows {
+		t.Fatalf("rows = %d, want %d", len(rows), cookiesFixtureRows)
+	}
+	row := rows["c42"]
+	if row["host_key"] != ".example42.com" || row["value"] != "v42" {
+		t.Fatalf("c42 = %v, want host .example42.com and value v42", row)
+	}
+	if got, want := row["expires_utc"], int64(13300000000000042); got != want {
+		t.Fatalf("c42 expires_utc = %v, want %d", got, want)
+	}
+	if got := row["encrypted_value"]; !bytes.Equal(got.([]byte), []byte{42, 42, 42, 42}) {
+		t.Fatalf("c42 encrypted_value = %v, want 4 bytes of 42", got)
+	}
+
+	big := rows["big"]
+	var value strings.Builder
+	for i := 0; i < 3000; i++ {
+		value.WriteByte(byte('a' + i%26))
+	}
+	if big["value"] != value.String() {
+		t.Fatalf("big value has %d bytes, want the 3000-byte fixture value", len(big["value"].(string)))
+	}
+	encrypted := make([]byte, 2000)
+	for i := range encrypted {
+		encrypted[i] = byte(i % 251)
+	}
+	if !bytes.Equal(big["encrypted_value"].([]byte), encrypted) {
+		t.Fatalf("big encrypted_value differs from the 2000-byte fixture value")
+	}
+}
+
+func TestSQLiteWALFramesOverrideMainFile(t *testing.T) {
+	mainOnly := copyFixture(t, nil, "wal.sqlite")
+	rows, err := readCookies(t, mainOnly)
+	if err != nil {
+		t.Fatalf("rows without WAL: %v", err)
+	}
+	if len(rows) != 20 || rows["c0"]["value"] != "old" {
+		t.Fatalf("without WAL: %d rows, c0 = %v; want 20 rows and c0 old", len(rows), rows["c0"]["value"])
+	}
+
+	rows, err = readCookies(t, filepath.Join("testdata", "wal.sqlite"))
+	if err != nil {
+		t.Fatalf("rows with WAL: %v", err)
+	}
+	if len(rows) != 21 || rows["c0"]["value"] != "new" || rows["late"]["value"] != "late" {
+		t.Fatalf("with WAL: %d rows, c0 = %v, late = %v; want 21 rows, c0 new and late present", len(rows), rows["c0"]["value"], rows["late"])
+	}
+	if rows["c1"]["value"] != "old" {
+		t.Fatalf("with WAL: c1 = %v, want old", rows["c1"]["value"])
+	}
+}
+
+func TestSQLiteIgnoresUncommittedWALFrames(t *testing.T) {
+	// Dropping the last frame removes the commit frame of the second transaction.
+	path := copyFixture(t, func(name string, data []byte) []byte {
+		if strings.HasSuffix(name, "-wal") {
+			return data[:len(data)-(24+512)]
+		}
+		return data
+	}, "wal.sqlite", "wal.sqlite-wal")
+	rows, err := readCookies(t, path)
+	if err != nil {
+		t.Fatalf("rows: %v", err)
+	}
+	if len(rows) != 20 || rows["c0"]["value"] != "new" {
+		t.Fatalf("%d rows, c0 = %v; want 20 rows and c0 new", len(rows), rows["c0"]["value"])
+	}
+	if _, ok := rows["late"]; ok {
+		t.Fatalf("row late of the uncommitted transaction was read")
+	}
+}
+
+func TestSQLiteIgnoresWALOfAnotherGeneration(t *testing.T) {
+	path := copyFixture(t, func(name string, data []byte) []byte {
+		if strings.HasSuffix(name, "-wal") {
+			data[16] ^= 0xff // The header salts no longer match the frames.
+		}
+		return data
+	}, "wal.sqlite", "wal.sqlite-wal")
+	rows, err := readCookies(t, path)
+	if err != nil {
+		t.Fatalf("rows: %v", err)
+	}
+	if len(rows) != 20 || rows["c0"]["value"] != "old" {
+		t.Fatalf("%d rows, c0 = %v; want 20 rows and c0 old", len(rows), rows["c0"]["value"])
+	}
+}
+
+func TestSQLiteTruncatedFiles(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "cookies.sqlite"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	dir := t.TempDir()
+	for size := 0; size < len(data); size += 97 {
+		path := filepath.Join(dir, "truncated.sqlite")
+		if err = os.WriteFile(path, data[:size], 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		rows, errRows := readCookies(t, path)
+		// Pages of the primary key index are never read, so losing only those is harmless.
+		if errRows == nil && len(rows) != cookiesFixtureRows {
+			t.Fatalf("truncated to %d bytes: %d rows and no error, want an error", size, len(rows))
+		}
+	}
+	if _, err = readCookies(t, copyFixture(t, func(_ string, data []byte) []byte { return data[:len(data)-1] }, "cookies.sqlite")); err == nil {
+		t.Fatalf("file missing its last byte: no error")
+	}
+}
+
+func TestSQLiteCorruptFiles(t *testing.T) {
+	db, err := openSQLite(filepath.Join("testdata", "cookies.sqlite"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	root, _, err := db.table("cookies")
+	if err != nil {
+		t.Fatalf("table: %v", err)
+	}
+	rootOffset := int(root-1) * db.pageSize
+
+	cases := map[string]func(data []byte){
+		"bad page type": func(data []byte) { data[rootOffset] = 0xff },
+		"cyclic b-tree": func(data []byte) {
+			binary.BigEndian.PutUint32(data[rootOffset+8:], root)
+		},
+		"child out of range": func(data []byte) {
+			binary.BigEndian.PutUint32(data[rootOffset+8:], 1<<30)
+		},
+		"cell count past the page": func(data []byte) {
+			binary.BigEndian.PutUint16(data[rootOffset+3:], 0xffff)
+		},
+		"bad header": func(data []byte) { copy(data, "SQLite format 2") },
+	}
+	for name, corrupt := range cases {
+		path := copyFixture(t, func(_ string, data []byte) []byte {
+			corrupt(data)
+			return data
+		}, "cookies.sqlite")
+		if _, err = readCookies(t, path); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+
+	// Random damage must never panic or hang, whatever it makes of the rows.
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		path := copyFixture(t, func(_ string, data []byte) []byte {
+			for j := 0; j < 8; j++ {
+				data[100+random.Intn(len(data)-100)] = byte(random.Intn(256))
+			}
+			return data
+		}, "cookies.sqlite")
+		_, _ = readCookies(t, path)
+	}
+}
@@ -0,0 +1,317 @@
+// Package clientkeys manages client API keys created through the management API. Only a
+// SHA-256 hash of each key is kept, together with the metadata the routing and quota
+// subsystems read: an owner, a quota tier and the models the key may use. Principals
+// authenticated by other access providers, such as OIDC, can be granted the same metadata.
+package clientkeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AccessProviderType is the access provider type that authenticates managed keys.
+	AccessProviderType = "client-key"
+	// AccessProviderName names the access provider added when client-keys is enabled.
+	AccessProviderName = "client-keys"
+
+	keyPrefix = "cpk-"
+	// hintLength is how much of a key is kept in clear so that operators can recognise it.
+	hintLength = len(keyPrefix) + 6
+)
+
+// ErrNotFound is returned for key IDs that do not exist.
+var ErrNotFound = errors.New("client key not found")
+
+// Key is the stored record of a managed client API key.
+type Key struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Owner string `json:"owner,omitempty"`
+	// QuotaTier selects the api-key-quotas tier applied to the key.
+	QuotaTier string `json:"quota_tier,omitempty"`
+	// AllowedModels lists the models the key may use; a trailing "*" matches by prefix. An
+	// empty list allows every model.
+	AllowedModels []string   `json:"allowed_models,omitempty"`
+	Hint          string     `json:"hint"`
+	Hash          string     `json:"hash"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key no longer authenticates.
+func (k Key) Revoked() bool { return k.RevokedAt != nil }
+
+// Spec describes a key to create.
+type Spec struct {
+	Name          string
+	Owner         string
+	QuotaTier     string
+	AllowedModels []string
+}
+
+// Patch changes the metadata of a key; nil fields are left alone.
+type Patch struct {
+	Name          *string
+	Owner         *string
+	QuotaTier     *string
+	AllowedModels *[]string
+}
+
+// Store keeps the managed keys in memory and persists them to a file.
+type Store struct {
+	mu      sync.RWMutex
+	enabled bool
+	path    string
+	keys    []*Key
+	byHash  map[string]*Key
+}
+
+var defaultStore = &Store{byHash: make(map[string]*Key)}
+
+// Default returns the shared key store.
+func Default() *Store { return defaultStore }
+
+// Access result metadata keys under which other access providers grant the principal of a
+// request a quota tier and allowed models. Allowed models are comma-separated.
+const (
+	MetadataQuotaTier     = "quota_tier"
+	MetadataAllowedModels = "allowed_models"
+)
+
+// GrantMetadata adds the quota tier and allowed models of key to the access result metadata
+// md, which carries them with the request. Grants are never consulted to authenticate one.
+func GrantMetadata(md map[string]string, key Key) {
+	if key.QuotaTier != "" {
+		md[MetadataQuotaTier] = key.QuotaTier
+	}
+	if len(key.AllowedModels) > 0 {
+		md[MetadataAllowedModels] = strings.Join(key.AllowedModels, ",")
+	}
+}
+
+// Lookup returns the metadata of the principal apiKey: the active managed key matching it
+// in the shared store, or the grant in the access metadata md of its request.
+func Lookup(apiKey string, md map[string]string) (Key, bool) {
+	if key, ok := defaultStore.Lookup(apiKey); ok {
+		return key, true
+	}
+	tier, models := md[MetadataQuotaTier], md[MetadataAllowedModels]
+	if apiKey == "" || (tier == "" && models == "") {
+		return Key{}, false
+	}
+	return Key{QuotaTier: tier, AllowedModels: cleanModels(strings.Split(models, ","))}, true
+}
+
+// Configure applies cfg and loads the store file when its path changes.
+func (s *Store) Configure(cfg config.ClientKeysConfig, authDir string) {
+	file := strings.TrimSpace(cfg.StoreFile)
+	if file == "" {
+		file = "client-keys.store"
+	}
+	if !filepath.IsAbs(file) && authDir != "" {
+		if dir, err := util.ResolveAuthDir(authDir); err == nil {
+			file = filepath.Join(dir, file)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled = cfg.Enabled
+	if !cfg.Enabled || file == s.path {
+		return
+	}
+	s.path = file
+	s.keys = nil
+	s.byHash = make(map[string]*Key)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("client keys: failed to read %s: %v", file, err)
+		}
+		return
+	}
+	var stored struct {
+		Keys []*Key `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &stored); err != nil {
+		log.Warnf("client keys: failed to parse %s: %v", file, err)
+		return
+	}
+	s.setKeysLocked(stored.Keys)
+}
+
+// Enabled reports whether managed keys are accepted.
+func (s *Store) Enabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.enabled
+}
+
+// Lookup returns the key record matching apiKey unless it is unknown or revoked.
+func (s *Store) Lookup(apiKey string) (Key, bool) {
+	if apiKey == "" {
+		return Key{}, false
+	}
+	hash := hashKey(apiKey)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.enabled {
+		return Key{}, false
+	}
+	key, ok := s.byHash[hash]
+	if !ok || key.Revoked() {
+		return Key{}, false
+	}
+	return *key, true
+}
+
+// List returns every key, revoked ones included, in creation order.
+func (s *Store) List() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		out = append(out, *key)
+	}
+	return out
+}
+
+// Create generates a key for spec and stores its hash. The returned secret is the key
+// itself; it cannot be recovered later.
+func (s *Store) Create(spec Spec) (Key, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return Key{}, "", fmt.Errorf("client keys: generate key: %w", err)
+	}
+	secret := keyPrefix + hex.EncodeToString(buf)
+	key := &Key{
+		ID:            uuid.NewString(),
+		Name:          strings.TrimSpace(spec.Name),
+		Owner:         strings.TrimSpace(spec.Owner),
+		QuotaTier:     strings.TrimSpace(spec.QuotaTier),
+		AllowedModels: cleanModels(spec.AllowedModels),
+		Hint:          secret[:hintLength],
+		Hash:          hashKey(secret),
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := append(append([]*Key(nil), s.keys...), key)
+	if err := s.saveLocked(keys); err != nil {
+		return Key{}, "", err
+	}
+	s.setKeysLocked(keys)
+	return *key, secret, nil
+}
+
+// Update applies patch to the key id.
+func (s *Store) Update(id string, patch Patch) (Key, error) {
+	return s.modify(id, func(key *Key) {
+		if patch.Name != nil {
+			key.Name = strings.TrimSpace(*patch.Name)
+		}
+		if patch.Owner != nil {
+			key.Owner = strings.TrimSpace(*patch.Owner)
+		}
+		if patch.QuotaTier != nil {
+			key.QuotaTier = strings.TrimSpace(*patch.QuotaTier)
+		}
+		if patch.AllowedModels != nil {
+			key.AllowedModels = cleanModels(*patch.AllowedModels)
+		}
+	})
+}
+
+// Revoke stops the key id from authenticating. The record is kept so that its usage stays
+// attributable; revoking a revoked key is a no-op.
+func (s *Store) Revoke(id string) (Key, error) {
+	return s.modify(id, func(key *Key) {
+		if key.RevokedAt == nil {
+			now := time.Now().UTC()
+			key.RevokedAt = &now
+		}
+	})
+}
+
+// modify replaces the key id with a copy changed by fn once the change is saved.
+func (s *Store) modify(id string, fn func(*Key)) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := append([]*Key(nil), s.keys...)
+	for i, key := range keys {
+		if key.ID != id {
+			continue
+		}
+		updated := *key
+		fn(&updated)
+		keys[i] = &updated
+		if err := s.saveLocked(keys); err != nil {
+			return Key{}, err
+		}
+		s.setKeysLocked(keys)
+		return updated, nil
+	}
+	return Key{}, ErrNotFound
+}
+
+func (s *Store) setKeysLocked(keys []*Key) {
+	s.keys = keys
+	s.byHash = make(map[string]*Key, len(keys))
+	for _, key := range keys {
+		if key != nil && key.Hash != "" {
+			s.byHash[key.Hash] = key
+		}
+	}
+}
+
+func (s *Store) saveLocked(keys []*Key) error {
+	if !s.enabled || s.path == "" {
+		return errors.New("client keys: client-keys is not enabled")
+	}
+	data, err := json.MarshalIndent(map[string]any{"keys": keys}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("client keys: encode: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("client keys: create store directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("client keys: write %s: %w", tmp, err)
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("client keys: replace %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func cleanModels(models []string) []string {
+	var out []string
+	for _, model := range models {
+		if model = strings.TrimSpace(model); model != "" {
+			out = append(out, model)
+		}
+	}
+	return out
+}
+
+func hashKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
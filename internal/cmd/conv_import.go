@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoConvCommand dispatches "conv" subcommands. Supported:
+//
+//	conv import-json [-dir <conv directory>]
+//	conv dedup [-dir <conv directory>]
+//	conv check [-dir <conv directory>] [-repair]
+//	conv backup [-dir <conv directory>] [-keep <n>]
+//	conv reindex [-dir <conv directory>]
+func DoConvCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: conv import-json|dedup|check|backup|reindex [-dir <conv directory>]")
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "import-json":
+		doConvImportJSON(args[1:])
+	case "dedup":
+		doConvDedup(args[1:])
+	case "check":
+		doConvCheck(args[1:])
+	case "backup":
+		doConvBackup(args[1:])
+	case "reindex":
+		doConvReindex(args[1:])
+	default:
+		fmt.Printf("unknown conv subcommand: %s\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// doConvImportJSON folds legacy JSON conversation files into the BoltDB stores.
+func doConvImportJSON(args []string) {
+	fs := flag.NewFlagSet("conv import-json", flag.ExitOnError)
+	dir := fs.String("dir", defaultConvDir(), "Directory containing legacy *.conv.json / *.data.json files")
+	_ = fs.Parse(args)
+
+	results, err := geminiwebapi.MigrateLegacyConversations(*dir)
+	for _, result := range results {
+		fmt.Printf("%s: %d metadata entries, %d conversations imported, %d skipped -> %s\n",
+			result.Account, result.MetaEntries, result.Conversations, result.Skipped, result.BoltPath)
+	}
+	if len(results) == 0 && err == nil {
+		fmt.Printf("no legacy conversation files found in %s\n", *dir)
+	}
+	if err != nil {
+		log.Fatalf("conversation import failed: %v", err)
+	}
+}
+
+// doConvDedup moves message bodies into the shared blob store, merges duplicate records,
+// prunes dangling index entries and compacts the conversation stores. Stop the server
+// before running it.
+func doConvDedup(args []string) {
+	fs := flag.NewFlagSet("conv dedup", flag.ExitOnError)
+	dir := fs.String("dir", defaultConvDir(), "Directory containing the *.bolt conversation stores")
+	_ = fs.Parse(args)
+
+	result, err := geminiwebapi.DedupConversationStores(*dir)
+	fmt.Printf("%d stores, %d conversations, %d duplicate records merged, %d dangling index entries pruned\n",
+		result.Stores, result.Conversations, result.DuplicateRecords, result.PrunedIndexEntries)
+	fmt.Printf("%d message blobs kept, %d removed; %d -> %d bytes\n",
+		result.Blobs, result.RemovedBlobs, result.BytesBefore, result.BytesAfter)
+	if err != nil {
+		log.Fatalf("conversation dedup failed: %v", err)
+	}
+}
+
+// doConvCheck runs the integrity check on every conversation store and the message blobs,
+// optionally recovering the stores that fail it. Stop the server before repairing.
+func doConvCheck(args []string) {
+	fs := flag.NewFlagSet("conv check", flag.ExitOnError)
+	dir := fs.String("dir", defaultConvDir(), "Directory containing the *.bolt conversation stores")
+	repair := fs.Bool("repair", false, "Recover the stores that fail the check")
+	_ = fs.Parse(args)
+
+	paths, err := geminiwebapi.ConversationStoreFiles(*dir)
+	if err != nil {
+		log.Fatalf("conversation check failed: %v", err)
+	}
+	failed := 0
+	for _, path := range paths {
+		errCheck := geminiwebapi.CheckConversationStore(path)
+		if errCheck == nil {
+			fmt.Printf("%s: ok\n", filepath.Base(path))
+			continue
+		}
+		fmt.Printf("%s: %v\n", filepath.Base(path), errCheck)
+		if !*repair {
+			failed++
+			continue
+		}
+		result, errRecover := geminiwebapi.RecoverConversationStore(path)
+		if errRecover != nil {
+			fmt.Printf("%s: recovery failed: %v\n", filepath.Base(path), errRecover)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: recovered, %d records, %d index entries, %d entries salvaged", filepath.Base(path),
+			result.Records, result.IndexEntries, result.Salvaged)
+		if result.Restored > 0 {
+			fmt.Printf(", %d entries restored from %s", result.Restored, result.RestoredBackup)
+		}
+		if result.RestoredBlobs > 0 {
+			fmt.Printf(", %d blobs restored", result.RestoredBlobs)
+		}
+		fmt.Println()
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// doConvBackup writes a backup of every conversation store and the message blobs.
+func doConvBackup(args []string) {
+	fs := flag.NewFlagSet("conv backup", flag.ExitOnError)
+	dir := fs.String("dir", defaultConvDir(), "Directory containing the *.bolt conversation stores")
+	keep := fs.Int("keep", 3, "Number of backups kept per file")
+	_ = fs.Parse(args)
+
+	n, err := geminiwebapi.BackupConversationStores(*dir, *keep, time.Now())
+	fmt.Printf("%d files backed up to %s\n", n, filepath.Join(*dir, "backups"))
+	if err != nil {
+		log.Fatalf("conversation backup failed: %v", err)
+	}
+}
+
+// doConvReindex rebuilds the index of every conversation store from its records with the
+// current hashing rules, for example after the hashing changed or an account file was
+// renamed together with its store. Stop the server before running it.
+func doConvReindex(args []string) {
+	fs := flag.NewFlagSet("conv reindex", flag.ExitOnError)
+	dir := fs.String("dir", defaultConvDir(), "Directory containing the *.bolt conversation stores")
+	_ = fs.Parse(args)
+
+	paths, err := filepath.Glob(filepath.Join(*dir, "*.bolt"))
+	if err != nil {
+		log.Fatalf("conversation reindex failed: %v", err)
+	}
+	failed := 0
+	for _, path := range paths {
+		result, errReindex := geminiwebapi.ReindexConversationStore(path)
+		if errReindex != nil {
+			fmt.Printf("%s: reindex failed: %v\n", filepath.Base(path), errReindex)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: %d records, %d index entries (%d added, %d removed), %d records kept their entries\n",
+			filepath.Base(path), result.Records, result.IndexEntries, result.Added, result.Removed, result.Kept)
+	}
+	if len(paths) == 0 {
+		fmt.Printf("no conversation stores found in %s\n", *dir)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func defaultConvDir() string {
+	return geminiwebapi.ConvDir()
+}
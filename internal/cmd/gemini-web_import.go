@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// DoImportCookiesCommand creates or renews a Gemini Web auth file from the Google session of a
+// local Chrome, Chromium, Edge, Brave or Firefox profile, instead of pasting cookies by hand:
+//
+//	import-cookies [-browser <name>] [-profile <name>] [-label <label>] [-yes]
+//
+// The user is asked for consent before any cookie store is read, and to pick a profile when
+// several are signed in to Google. A running server picks the file up through its watcher.
+func DoImportCookiesCommand(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("import-cookies", flag.ExitOnError)
+	browserName := fs.String("browser", "", "Only read this browser: chrome, chromium, edge, brave or firefox")
+	profileName := fs.String("profile", "", "Only read this profile, e.g. Default or Profile 1 (Chromium) or the profile directory (Firefox)")
+	label := fs.String("label", "", "Label of a new account (default: the account email)")
+	yes := fs.Bool("yes", false, "Consent to reading the cookie stores and take the only matching profile without prompting")
+	_ = fs.Parse(args)
+
+	reader := bufio.NewReader(os.Stdin)
+	banner("Gemini Web Browser Cookie Import")
+	if !*yes {
+		fmt.Println("This reads your Google cookies (__Secure-1PSID and __Secure-1PSIDTS) from the")
+		fmt.Println("local browser profiles and saves them to the auth directory. Your operating system")
+		fmt.Println("may ask you to allow access to the browser's keystore.")
+		fmt.Print("Continue? [y/N]: ")
+		answer, _ := reader.ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("==> Aborted.")
+			return
+		}
+	}
+
+	sessions, errs := gemini.FindGeminiWebBrowserSessions(*browserName, *profileName)
+	for _, err := range errs {
+		fmt.Println("!!", err)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("!! No browser profile signed in to Google was found.")
+		os.Exit(1)
+	}
+	session := sessions[0]
+	if len(sessions) > 1 {
+		fmt.Println("--- Signed-in profiles ---")
+		for i, s := range sessions {
+			fmt.Printf("  %d) %s %s\n", i+1, s.Profile.Browser, s.Profile.Name)
+		}
+		if *yes {
+			fmt.Println("!! Several profiles are signed in; choose one with -browser and -profile.")
+			os.Exit(2)
+		}
+		fmt.Print("Profile number: ")
+		v, _ := reader.ReadString('\n')
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < 1 || n > len(sessions) {
+			fmt.Println("!! Invalid choice.")
+			os.Exit(2)
+		}
+		session = sessions[n-1]
+	}
+	fmt.Printf("==> Using %s profile %s\n", session.Profile.Browser, session.Profile.Name)
+
+	if err := geminiwebapi.ValidateCookies(session.Secure1PSID, session.Secure1PSIDTS, cfg.ProxyURL); err != nil {
+		fmt.Println("!! Gemini did not accept the cookies:", err)
+		fmt.Println("Tip: open gemini.google.com in that browser to refresh the session, then try again.")
+		os.Exit(1)
+	}
+
+	accountLabel := strings.TrimSpace(*label)
+	if accountLabel == "" {
+		httpClient := util.SetProxy(&cfg.SDKConfig, &http.Client{Timeout: 15 * time.Second})
+		if email, err := gemini.FetchGeminiWebAccountEmail(httpClient, session.Cookie); err != nil {
+			fmt.Println("!!", err)
+		} else {
+			accountLabel = email
+		}
+	}
+	path, created, err := gemini.SaveGeminiWebBrowserSession(cfg.AuthDir, session, accountLabel)
+	if err != nil {
+		fmt.Println("!! Failed to save Gemini Web token to file:", err)
+		os.Exit(1)
+	}
+	if created {
+		fmt.Println("==> Successfully saved Gemini Web token!")
+	} else {
+		fmt.Println("==> Renewed the cookies of the existing Gemini Web account.")
+	}
+	fmt.Println("==> Saved to:", path)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoRewriteAuthFilesCommand rewrites every auth file in the form auth-encryption calls for:
+// plaintext files and files encrypted with an older key are encrypted with the current key,
+// or, with encryption disabled, encrypted files are decrypted. Files already in that form are
+// left alone.
+func DoRewriteAuthFilesCommand(cfg *config.Config) {
+	entries, err := os.ReadDir(cfg.AuthDir)
+	if err != nil {
+		log.Fatalf("failed to read auth dir: %v", err)
+	}
+	rewritten, failed := 0, 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
+			continue
+		}
+		changed, errRewrite := authcrypt.Rewrite(filepath.Join(cfg.AuthDir, entry.Name()))
+		switch {
+		case errRewrite != nil:
+			failed++
+			fmt.Printf("  %s: %v\n", entry.Name(), errRewrite)
+		case changed:
+			rewritten++
+			fmt.Printf("  %s\n", entry.Name())
+		}
+	}
+	state := "plaintext"
+	if authcrypt.Enabled() {
+		state = "encrypted with the current key"
+	}
+	fmt.Printf("rewrote %d auth files as %s, %d failed\n", rewritten, state, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
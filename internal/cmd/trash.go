@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/trash"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoTrashCommand dispatches "trash" subcommands for soft-deleted auth files and Gemini Web
+// conversations. Supported:
+//
+//	trash list [-conv-dir <conv directory>]
+//	trash restore <auth file name>
+//	trash restore-conv [-conv-dir <conv directory>] <account file name> <conversation id>
+//	trash purge [-conv-dir <conv directory>]
+//
+// The commands edit the stores directly; stop the server before running them.
+func DoTrashCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: trash list|restore|restore-conv|purge")
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "list":
+		doTrashList(cfg, args[1:])
+	case "restore":
+		doTrashRestore(cfg, args[1:])
+	case "restore-conv":
+		doTrashRestoreConversation(args[1:])
+	case "purge":
+		doTrashPurge(cfg, args[1:])
+	default:
+		fmt.Printf("unknown trash subcommand: %s\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// doTrashList prints the deleted auth files and conversations with who deleted them.
+func doTrashList(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("trash list", flag.ExitOnError)
+	dir := fs.String("conv-dir", defaultConvDir(), "Directory containing the *.bolt conversation stores")
+	_ = fs.Parse(args)
+
+	retention := cfg.SoftDelete.Retention()
+	entries, err := trash.List(cfg.AuthDir, retention)
+	if err != nil {
+		log.Fatalf("failed to read auth file trash: %v", err)
+	}
+	fmt.Printf("auth files (%d):\n", len(entries))
+	for _, entry := range entries {
+		fmt.Printf("  %s  deleted %s by %s, expires %s\n", entry.Name,
+			entry.DeletedAt.Local().Format(time.RFC3339), deletedBy(entry.DeletedBy), entry.ExpiresAt.Local().Format(time.RFC3339))
+	}
+
+	stores, err := filepath.Glob(filepath.Join(*dir, "*.bolt"))
+	if err != nil {
+		log.Fatalf("failed to list conversation stores: %v", err)
+	}
+	for _, store := range stores {
+		deleted, errLoad := geminiwebapi.LoadDeletedConversations(store)
+		if errLoad != nil {
+			log.Errorf("%s: %v", filepath.Base(store), errLoad)
+			continue
+		}
+		if len(deleted) == 0 {
+			continue
+		}
+		fmt.Printf("conversations of %s (%d):\n", strings.TrimSuffix(filepath.Base(store), ".bolt"), len(deleted))
+		for _, d := range deleted {
+			fmt.Printf("  %s  %s, %d messages, deleted %s by %s, expires %s\n", d.ID, d.Record.Model, len(d.Record.Messages),
+				d.DeletedAt.Local().Format(time.RFC3339), deletedBy(d.DeletedBy), d.DeletedAt.Add(retention).Local().Format(time.RFC3339))
+		}
+	}
+}
+
+// doTrashRestore puts a deleted auth file back into the auth directory.
+func doTrashRestore(cfg *config.Config, args []string) {
+	if len(args) != 1 {
+		fmt.Println("usage: trash restore <auth file name>")
+		os.Exit(2)
+	}
+	path, err := trash.Restore(cfg.AuthDir, args[0])
+	if err != nil && path == "" {
+		log.Fatalf("failed to restore %s: %v", args[0], err)
+	}
+	if err != nil {
+		log.Warnf("restored %s but failed to remove its trash entry: %v", args[0], err)
+	}
+	fmt.Printf("restored %s\n", path)
+}
+
+// doTrashRestoreConversation puts a deleted conversation back into its account's store. The
+// record is reused by its account right away; cross-account matches are rebuilt the next
+// time the conversation continues.
+func doTrashRestoreConversation(args []string) {
+	fs := flag.NewFlagSet("trash restore-conv", flag.ExitOnError)
+	dir := fs.String("conv-dir", defaultConvDir(), "Directory containing the *.bolt conversation stores")
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Println("usage: trash restore-conv [-conv-dir <conv directory>] <account file name> <conversation id>")
+		os.Exit(2)
+	}
+	account := strings.TrimSuffix(filepath.Base(fs.Arg(0)), ".json")
+	store := filepath.Join(*dir, account+".bolt")
+	if _, err := os.Stat(store); err != nil {
+		log.Fatalf("no conversation store for %s: %v", account, err)
+	}
+	if err := geminiwebapi.RestoreConversationInStore(store, fs.Arg(1)); err != nil {
+		log.Fatalf("failed to restore conversation %s: %v", fs.Arg(1), err)
+	}
+	fmt.Printf("restored conversation %s of %s\n", fs.Arg(1), account)
+}
+
+// doTrashPurge removes the entries older than the retention window.
+func doTrashPurge(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("trash purge", flag.ExitOnError)
+	dir := fs.String("conv-dir", defaultConvDir(), "Directory containing the *.bolt conversation stores")
+	_ = fs.Parse(args)
+
+	retention := cfg.SoftDelete.Retention()
+	now := time.Now()
+	files, err := trash.Purge(cfg.AuthDir, retention, now)
+	if err != nil {
+		log.Fatalf("failed to purge auth file trash: %v", err)
+	}
+	conversations, err := geminiwebapi.PurgeDeletedConversations(*dir, retention, now)
+	if err != nil {
+		log.Fatalf("failed to purge conversation trash: %v", err)
+	}
+	fmt.Printf("purged %d auth files and %d conversations deleted more than %s ago\n", files, conversations, retention)
+}
+
+func deletedBy(actor string) string {
+	if actor == "" {
+		return "unknown"
+	}
+	return actor
+}
@@ -7,6 +7,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	"golang.org/x/crypto/bcrypt"
@@ -19,6 +20,18 @@ type Config struct {
 	// Port is the network port on which the API server will listen.
 	Port int `yaml:"port" json:"-"`
 
+	// GRPCPort is the port of the gRPC API; 0 disables it. Changes require a restart.
+	GRPCPort int `yaml:"grpc-port,omitempty" json:"-"`
+
+	// TLS serves the API and gRPC ports over HTTPS, optionally verifying client certificates.
+	// Enabling or disabling it requires a restart; renewed certificate files are picked up
+	// while running.
+	TLS TLSConfig `yaml:"tls,omitempty" json:"tls,omitempty"`
+
+	// UpstreamTLS configures the client certificate presented to, and the CAs trusted for,
+	// HTTPS proxies in proxy-url and per-account proxy URLs.
+	UpstreamTLS UpstreamTLSConfig `yaml:"upstream-tls,omitempty" json:"upstream-tls,omitempty"`
+
 	// AuthDir is the directory where authentication token files are stored.
 	AuthDir string `yaml:"auth-dir" json:"-"`
 
@@ -31,6 +44,69 @@ type Config struct {
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// APIKeyQuotas enforces daily and monthly token quotas per client API key.
+	APIKeyQuotas APIKeyQuotaConfig `yaml:"api-key-quotas" json:"api-key-quotas"`
+
+	// ClientKeys accepts client API keys created through the management API, in addition to
+	// the static api-keys.
+	ClientKeys ClientKeysConfig `yaml:"client-keys,omitempty" json:"client-keys,omitempty"`
+
+	// OIDC accepts bearer JWTs issued by an OpenID Connect provider in addition to API keys.
+	OIDC OIDCConfig `yaml:"oidc,omitempty" json:"oidc,omitempty"`
+
+	// RateLimit throttles client requests with token buckets.
+	RateLimit RateLimitConfig `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+
+	// Bandwidth enforces upstream traffic ceilings per account.
+	Bandwidth BandwidthConfig `yaml:"bandwidth,omitempty" json:"bandwidth,omitempty"`
+
+	// ProxyPool spreads accounts over several egress proxies and moves them off failing ones.
+	ProxyPool ProxyPoolConfig `yaml:"proxy-pool,omitempty" json:"proxy-pool,omitempty"`
+
+	// Moderation scans prompts and completions against redaction and blocking rules.
+	Moderation ModerationConfig `yaml:"moderation,omitempty" json:"moderation,omitempty"`
+
+	// FaultInjection introduces artificial upstream failures for resilience testing.
+	FaultInjection FaultInjectionConfig `yaml:"fault-injection" json:"fault-injection"`
+
+	// AuditLog configures the structured per-request audit trail.
+	AuditLog AuditLogConfig `yaml:"audit-log" json:"audit-log"`
+
+	// RequestStore keeps recent requests, optionally with bodies, for the management query API.
+	RequestStore RequestStoreConfig `yaml:"request-store,omitempty" json:"request-store,omitempty"`
+
+	// Alerts notify operators of accounts that need attention, such as expired cookies.
+	Alerts AlertsConfig `yaml:"alerts,omitempty" json:"alerts,omitempty"`
+
+	// UtilizationReport configures daily account pool utilization summaries.
+	UtilizationReport UtilizationReportConfig `yaml:"utilization-report" json:"utilization-report"`
+
+	// UsageExport configures the aggregated usage export shared outside the deployment.
+	UsageExport UsageExportConfig `yaml:"usage-export,omitempty" json:"usage-export,omitempty"`
+
+	// PromptJobs are prompts run on a schedule, with results sent to a webhook or directory.
+	PromptJobs []PromptJob `yaml:"prompt-jobs,omitempty" json:"prompt-jobs,omitempty"`
+
+	// SoftDelete keeps deleted auth files and Gemini Web conversations restorable for a while.
+	SoftDelete SoftDeleteConfig `yaml:"soft-delete,omitempty" json:"soft-delete,omitempty"`
+
+	// AuthEncryption encrypts auth files at rest.
+	AuthEncryption AuthEncryptionConfig `yaml:"auth-encryption,omitempty" json:"auth-encryption,omitempty"`
+
+	// AuthStore keeps auth tokens in a secrets backend instead of auth-dir.
+	AuthStore AuthStoreConfig `yaml:"auth-store,omitempty" json:"auth-store,omitempty"`
+
+	// Assets re-hosts images generated by Gemini Web behind stable links served by the proxy.
+	Assets AssetsConfig `yaml:"assets,omitempty" json:"assets,omitempty"`
+
+	// Batches emulates the OpenAI Files and Batch APIs, running uploaded JSONL requests in the
+	// background against the account pool.
+	Batches BatchesConfig `yaml:"batches,omitempty" json:"batches,omitempty"`
+
+	// AsyncJobs runs requests sent with "Prefer: respond-async" in the background and posts
+	// their responses to a webhook.
+	AsyncJobs AsyncJobsConfig `yaml:"async-jobs,omitempty" json:"async-jobs,omitempty"`
+
 	// QuotaExceeded defines the behavior when a quota is exceeded.
 	QuotaExceeded QuotaExceeded `yaml:"quota-exceeded" json:"quota-exceeded"`
 
@@ -46,6 +122,12 @@ type Config struct {
 	// Codex defines a list of Codex API key configurations as specified in the YAML configuration file.
 	CodexKey []CodexKey `yaml:"codex-api-key" json:"codex-api-key"`
 
+	// Bedrock defines AWS Bedrock credentials served through the Converse API.
+	Bedrock []BedrockKey `yaml:"bedrock,omitempty" json:"bedrock,omitempty"`
+
+	// VertexAI defines Vertex AI service accounts serving the Gemini models.
+	VertexAI []VertexAIKey `yaml:"vertex-ai,omitempty" json:"vertex-ai,omitempty"`
+
 	// OpenAICompatibility defines OpenAI API compatibility configurations for external providers.
 	OpenAICompatibility []OpenAICompatibility `yaml:"openai-compatibility" json:"openai-compatibility"`
 
@@ -78,6 +160,424 @@ type GeminiWebConfig struct {
 	// DisableContinuationHint, when true, disables the continuation hint for split prompts.
 	// The hint is enabled by default.
 	DisableContinuationHint bool `yaml:"disable-continuation-hint,omitempty" json:"disable-continuation-hint,omitempty"`
+
+	// ContextOverflow decides what happens to a prompt estimated to exceed the model's
+	// context window:
+	//   - "reject" (default): fail the request with a 400 error naming the estimate and limit
+	//   - "truncate": drop the oldest messages until the prompt fits
+	ContextOverflow string `yaml:"context-overflow,omitempty" json:"context-overflow,omitempty"`
+
+	// SystemPromptMode controls how system instructions reach Gemini Web:
+	//   - "" (default): leave them to the translated prompt as-is
+	//   - "delimiter": prepend them to the first turn inside a <system_instructions> block
+	//   - "gem": attach them as a custom Gem, created on demand and reused by content hash
+	SystemPromptMode string `yaml:"system-prompt-mode,omitempty" json:"system-prompt-mode,omitempty"`
+
+	// SystemPromptGemLimit caps how many system prompt Gems are kept per account in "gem"
+	// mode; the least recently used one is deleted to make room. Defaults to 16.
+	SystemPromptGemLimit int `yaml:"system-prompt-gem-limit,omitempty" json:"system-prompt-gem-limit,omitempty"`
+
+	// Gems attaches Gems to requests by model and/or client API key.
+	// Rules are evaluated in order and the first match wins; when none match,
+	// CodeMode falls back to the predefined "Coding partner" Gem.
+	Gems []GeminiWebGemRule `yaml:"gems,omitempty" json:"gems,omitempty"`
+
+	// StreamProfiles shapes OpenAI-compatible streaming chunks per client API key,
+	// for clients that depend on the exact OpenAI chunk sequence. A profile without
+	// api-keys applies to every key not matched by a more specific profile.
+	StreamProfiles []GeminiWebStreamProfile `yaml:"stream-profiles,omitempty" json:"stream-profiles,omitempty"`
+
+	// OutputPolicies append an attribution footer and/or embed an invisible watermark
+	// in generated text, per client API key. The first matching policy applies.
+	OutputPolicies []GeminiWebOutputPolicy `yaml:"output-policies,omitempty" json:"output-policies,omitempty"`
+
+	// ConversationIDFromUser treats the OpenAI "user" field as an explicit conversation ID
+	// when no X-Conversation-ID header or metadata.conversation_id is sent.
+	ConversationIDFromUser bool `yaml:"conversation-id-from-user,omitempty" json:"conversation-id-from-user,omitempty"`
+
+	// ModelMigration replays a conversation recorded under another model to the requested
+	// model when a client switches models mid-chat, instead of losing its context.
+	ModelMigration bool `yaml:"model-migration,omitempty" json:"model-migration,omitempty"`
+
+	// HistoryCompaction summarizes the older turns of a long history replayed into a new
+	// conversation instead of sending them in full.
+	HistoryCompaction GeminiWebHistoryCompaction `yaml:"history-compaction,omitempty" json:"history-compaction,omitempty"`
+
+	// SharedIndex mirrors the conversation match index to Redis so replicas behind a load
+	// balancer continue conversations created by each other instead of opening new ones.
+	SharedIndex GeminiWebSharedIndex `yaml:"shared-index,omitempty" json:"shared-index,omitempty"`
+
+	// ContextReuseHeaders adds X-CLIProxy-Context-Reuse, X-CLIProxy-Overlap and
+	// X-CLIProxy-Account to responses, reporting whether the request continued a conversation
+	// and which account served it.
+	ContextReuseHeaders bool `yaml:"context-reuse-headers,omitempty" json:"context-reuse-headers,omitempty"`
+
+	// HashNormalization makes conversation matching ignore text differences that clients
+	// introduce between turns, such as reflowed whitespace or timestamps.
+	HashNormalization GeminiWebHashNormalization `yaml:"hash-normalization,omitempty" json:"hash-normalization,omitempty"`
+
+	// Storage controls how conversation text is persisted for context reuse.
+	Storage GeminiWebStorage `yaml:"storage,omitempty" json:"storage,omitempty"`
+
+	// ConversationCacheSize is the number of conversation records per account kept in memory;
+	// the others are read from the store when looked up. Defaults to 256.
+	ConversationCacheSize int `yaml:"conversation-cache-size,omitempty" json:"conversation-cache-size,omitempty"`
+
+	// Backup keeps rolling backups of the conversation stores in conv/backups.
+	Backup GeminiWebBackup `yaml:"backup,omitempty" json:"backup,omitempty"`
+
+	// ResponseCache serves repeated identical requests from memory instead of sending them
+	// to Gemini Web again, e.g. during client retry storms.
+	ResponseCache GeminiWebResponseCache `yaml:"response-cache,omitempty" json:"response-cache,omitempty"`
+
+	// Timeout bounds how long Gemini Web may take to answer a request, globally, per model
+	// and per request.
+	Timeout GeminiWebTimeout `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// PartialOnTimeout answers with the part of a reply received before the timeout instead
+	// of failing, and stores it so the client can continue the conversation.
+	PartialOnTimeout GeminiWebPartialOnTimeout `yaml:"partial-on-timeout,omitempty" json:"partial-on-timeout,omitempty"`
+
+	// Retry configures retries of transient Gemini Web failures.
+	Retry GeminiWebRetry `yaml:"retry,omitempty" json:"retry,omitempty"`
+
+	// CircuitBreaker stops routing to an account after consecutive upstream failures.
+	CircuitBreaker GeminiWebCircuitBreaker `yaml:"circuit-breaker,omitempty" json:"circuit-breaker,omitempty"`
+
+	// Queue bounds the requests waiting for each account.
+	Queue GeminiWebQueue `yaml:"queue,omitempty" json:"queue,omitempty"`
+
+	// LocaleContext tells the model the current date, time and unit system, which it would
+	// otherwise take from the account's locale.
+	LocaleContext GeminiWebLocaleContext `yaml:"locale-context,omitempty" json:"locale-context,omitempty"`
+
+	// StructuredOutput controls how JSON replies requested with response_format are enforced.
+	StructuredOutput GeminiWebStructuredOutput `yaml:"structured-output,omitempty" json:"structured-output,omitempty"`
+
+	// Files bounds the size of attached files by type.
+	Files GeminiWebFiles `yaml:"files,omitempty" json:"files,omitempty"`
+
+	// ReasoningContent moves <think> blocks out of reply text into the reasoning field of the
+	// client format (OpenAI reasoning_content, Anthropic thinking blocks). When false they stay
+	// in the reply text and are only stripped from stored conversations.
+	ReasoningContent bool `yaml:"reasoning-content,omitempty" json:"reasoning-content,omitempty"`
+
+	// BrowserRefresh renews account cookies with a headless browser when rotating
+	// __Secure-1PSIDTS keeps failing.
+	BrowserRefresh GeminiWebBrowserRefresh `yaml:"browser-refresh,omitempty" json:"browser-refresh,omitempty"`
+
+	// Transport tunes the HTTP transports that Gemini Web accounts share.
+	Transport GeminiWebTransport `yaml:"transport,omitempty" json:"transport,omitempty"`
+
+	// UploadCache bounds the reuse of upload handles for attachments whose content was
+	// uploaded before.
+	UploadCache GeminiWebUploadCache `yaml:"upload-cache,omitempty" json:"upload-cache,omitempty"`
+}
+
+// GeminiWebUploadCache configures the in-memory cache mapping attachment content to the
+// handle the upload endpoint returned for it, shared by all accounts.
+type GeminiWebUploadCache struct {
+	// TTLMinutes is how long a handle is reused before the file is uploaded again; defaults
+	// to 30. A negative value disables the cache.
+	TTLMinutes int `yaml:"ttl-minutes,omitempty" json:"ttl-minutes,omitempty"`
+
+	// MaxEntries bounds the number of cached handles; defaults to 1024. The oldest handle is
+	// dropped to make room.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+}
+
+// GeminiWebTransport tunes the upstream HTTP transports of Gemini Web. Accounts using the same
+// proxy share one transport, so connections and TLS sessions are reused across accounts.
+type GeminiWebTransport struct {
+	// MaxIdleConns caps idle connections across all hosts; defaults to 100.
+	MaxIdleConns int `yaml:"max-idle-conns,omitempty" json:"max-idle-conns,omitempty"`
+
+	// MaxIdleConnsPerHost caps idle connections per host; defaults to 16.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host,omitempty" json:"max-idle-conns-per-host,omitempty"`
+
+	// MaxConnsPerHost caps all connections per host; 0 means unlimited.
+	MaxConnsPerHost int `yaml:"max-conns-per-host,omitempty" json:"max-conns-per-host,omitempty"`
+
+	// IdleConnTimeoutSeconds closes connections idle for longer; defaults to 90.
+	IdleConnTimeoutSeconds int `yaml:"idle-conn-timeout-seconds,omitempty" json:"idle-conn-timeout-seconds,omitempty"`
+
+	// TLSSessionCacheSize is the number of TLS sessions kept for resumption; defaults to 256.
+	// A negative value disables session resumption.
+	TLSSessionCacheSize int `yaml:"tls-session-cache-size,omitempty" json:"tls-session-cache-size,omitempty"`
+
+	// DisableHTTP2 restricts connections to HTTP/1.1.
+	DisableHTTP2 bool `yaml:"disable-http2,omitempty" json:"disable-http2,omitempty"`
+
+	// DNSServers resolves host names with these servers ("host:port", used in rotation) instead
+	// of the system resolver.
+	DNSServers []string `yaml:"dns-servers,omitempty" json:"dns-servers,omitempty"`
+}
+
+// GeminiWebBrowserRefresh configures the headless browser fallback of cookie rotation. The
+// browser opens Gemini with the account's stored cookies in a profile kept per account, and
+// the cookies it holds afterwards replace the stored ones. It needs Chrome or Chromium on the
+// server and is off unless Enabled is set.
+type GeminiWebBrowserRefresh struct {
+	// Enabled turns the fallback on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// ExecPath is the Chrome or Chromium binary; found on PATH when empty.
+	ExecPath string `yaml:"exec-path,omitempty" json:"exec-path,omitempty"`
+
+	// ProfileDir holds one browser profile per account; defaults to browser-profiles in the
+	// working directory.
+	// A profile signed in to Google by hand is used as is.
+	ProfileDir string `yaml:"profile-dir,omitempty" json:"profile-dir,omitempty"`
+
+	// AfterFailures is the number of consecutive failed rotations that starts the browser;
+	// defaults to 3. Rejected cookies start it at once.
+	AfterFailures int `yaml:"after-failures,omitempty" json:"after-failures,omitempty"`
+
+	// TimeoutSeconds bounds one browser session; defaults to 60.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+}
+
+// GeminiWebFiles sets per-type size limits, in megabytes, for files attached to prompts.
+// Files of other types are rejected.
+type GeminiWebFiles struct {
+	// MaxImageMB defaults to 20.
+	MaxImageMB int `yaml:"max-image-mb,omitempty" json:"max-image-mb,omitempty"`
+
+	// MaxDocumentMB covers PDFs and text documents; defaults to 100.
+	MaxDocumentMB int `yaml:"max-document-mb,omitempty" json:"max-document-mb,omitempty"`
+
+	// MaxAudioMB defaults to 100.
+	MaxAudioMB int `yaml:"max-audio-mb,omitempty" json:"max-audio-mb,omitempty"`
+
+	// MaxVideoMB defaults to 500.
+	MaxVideoMB int `yaml:"max-video-mb,omitempty" json:"max-video-mb,omitempty"`
+}
+
+// GeminiWebStructuredOutput configures validation of JSON replies. A reply that is not valid
+// JSON or does not match the requested schema is sent back to the model for correction.
+type GeminiWebStructuredOutput struct {
+	// MaxAttempts is the total number of replies requested before the request fails with
+	// 502; defaults to 3. Set it to 1 to disable re-asking.
+	MaxAttempts int `yaml:"max-attempts,omitempty" json:"max-attempts,omitempty"`
+}
+
+// GeminiWebLocaleContext configures the date/time and unit hint added to each prompt.
+// Clients may override both per request with the X-Client-Timezone and X-Client-Units
+// headers or the x_cliproxy.timezone and x_cliproxy.units request fields.
+type GeminiWebLocaleContext struct {
+	// Enabled turns the hint on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Timezone is the IANA time zone used when the client sends none; defaults to the
+	// server's local time zone.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// Units is "metric" or "imperial". When empty, units are only mentioned if the client
+	// asks for them.
+	Units string `yaml:"units,omitempty" json:"units,omitempty"`
+}
+
+// GeminiWebQueue configures the per-account request queue.
+type GeminiWebQueue struct {
+	// MaxConcurrency is the number of requests an account serves at once; defaults to 1.
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
+
+	// MaxDepth is the number of requests that may wait for an account; defaults to 32.
+	MaxDepth int `yaml:"max-depth,omitempty" json:"max-depth,omitempty"`
+
+	// TimeoutSeconds is how long a request waits in the queue before failing; defaults to 120.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+}
+
+// GeminiWebCircuitBreaker configures the per-account circuit breaker.
+type GeminiWebCircuitBreaker struct {
+	// Disabled turns the breaker off.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// FailureThreshold is the number of consecutive failures that opens the breaker; defaults to 5.
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+
+	// CooldownSeconds is how long an open breaker rejects requests before probing; defaults to 60.
+	CooldownSeconds int `yaml:"cooldown-seconds,omitempty" json:"cooldown-seconds,omitempty"`
+
+	// HalfOpenProbes is the number of successful probes that closes the breaker; defaults to 1.
+	HalfOpenProbes int `yaml:"half-open-probes,omitempty" json:"half-open-probes,omitempty"`
+}
+
+// GeminiWebRetry configures the retry policy of Gemini Web calls.
+type GeminiWebRetry struct {
+	// MaxAttempts is the total number of attempts per upstream call; defaults to 3.
+	// Set it to 1 to disable retries.
+	MaxAttempts int `yaml:"max-attempts,omitempty" json:"max-attempts,omitempty"`
+
+	// InitialBackoffMs is the delay before the first retry; defaults to 1000. The delay
+	// doubles on each further retry up to MaxBackoffMs (default 8000), with random jitter.
+	InitialBackoffMs int `yaml:"initial-backoff-ms,omitempty" json:"initial-backoff-ms,omitempty"`
+	MaxBackoffMs     int `yaml:"max-backoff-ms,omitempty" json:"max-backoff-ms,omitempty"`
+
+	// RetryOn lists the retried error classes: "network", "server" (5xx responses),
+	// "invalid-response" (unparseable replies) and "image" (image generation failures).
+	// Empty retries all of them.
+	RetryOn []string `yaml:"retry-on,omitempty" json:"retry-on,omitempty"`
+}
+
+// GeminiWebHistoryCompaction configures the summarization of long Gemini Web histories.
+type GeminiWebHistoryCompaction struct {
+	// Enabled turns history compaction on.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// ThresholdChars is the history size in characters above which older turns are
+	// summarized; defaults to max-chars-per-request.
+	ThresholdChars int `yaml:"threshold-chars,omitempty" json:"threshold-chars,omitempty"`
+
+	// KeepRecent is the number of most recent messages always sent verbatim; defaults to 6.
+	KeepRecent int `yaml:"keep-recent,omitempty" json:"keep-recent,omitempty"`
+
+	// Model writes the summaries; defaults to gemini-2.5-flash.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+}
+
+// GeminiWebResponseCache configures the in-memory cache of Gemini Web responses.
+type GeminiWebResponseCache struct {
+	// TTLSeconds is how long a response is served from the cache; zero disables caching.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxEntries bounds the number of cached responses; defaults to 256.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+}
+
+// GeminiWebStorage configures the persisted form of conversation messages.
+type GeminiWebStorage struct {
+	// Mode is "" (default) to store message text as sent, or "redact" to store each message
+	// only as a salted hash plus a short prefix. Context reuse keeps working because the
+	// client resends the history, which is matched against the hashes.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Salt keys the message hashes in redact mode. When empty, a random salt is generated
+	// once and kept next to the conversation stores.
+	Salt string `yaml:"salt,omitempty" json:"salt,omitempty"`
+
+	// PrefixChars is the number of leading characters kept per message in redact mode.
+	// Zero uses 16; a negative value keeps no text at all.
+	PrefixChars int `yaml:"prefix-chars,omitempty" json:"prefix-chars,omitempty"`
+}
+
+// GeminiWebBackup configures the rolling backups of the conversation stores and message blobs.
+type GeminiWebBackup struct {
+	// Keep is the number of backups kept per file; zero uses 3 and a negative value disables
+	// backups.
+	Keep int `yaml:"keep,omitempty" json:"keep,omitempty"`
+
+	// IntervalHours is the time between backups; defaults to 24. A backup is also written
+	// when the server starts.
+	IntervalHours int `yaml:"interval-hours,omitempty" json:"interval-hours,omitempty"`
+}
+
+// GeminiWebSharedIndex configures the Redis-backed conversation index shared between replicas.
+type GeminiWebSharedIndex struct {
+	// RedisURL is redis://[:password@]host:port[/db] (rediss:// for TLS). Empty disables sharing.
+	RedisURL string `yaml:"redis-url,omitempty" json:"redis-url,omitempty"`
+
+	// KeyPrefix namespaces the keys; defaults to "cliproxy:gemini-web:".
+	KeyPrefix string `yaml:"key-prefix,omitempty" json:"key-prefix,omitempty"`
+
+	// TTLHours expires entries not refreshed for this long; defaults to 168 (7 days).
+	TTLHours int `yaml:"ttl-hours,omitempty" json:"ttl-hours,omitempty"`
+}
+
+// GeminiWebTimeout configures how long Gemini Web may take to answer. A timeout covers the
+// whole upstream exchange of a request, including retries and the parts of a split prompt.
+type GeminiWebTimeout struct {
+	// Seconds is the timeout of requests to models without a rule; defaults to 300.
+	Seconds int `yaml:"seconds,omitempty" json:"seconds,omitempty"`
+
+	// Models sets the timeout of some models, e.g. longer ones for deep research models.
+	// The first rule matching the model applies.
+	Models []GeminiWebModelTimeout `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// MaxRequestSeconds caps the timeout clients may request with X-CLIProxy-Timeout or
+	// x_cliproxy.timeout. By default clients can only shorten the timeout of the model.
+	MaxRequestSeconds int `yaml:"max-request-seconds,omitempty" json:"max-request-seconds,omitempty"`
+}
+
+// GeminiWebPartialOnTimeout configures the replies salvaged from upstream timeouts.
+type GeminiWebPartialOnTimeout struct {
+	// Enabled returns partial replies. Requests with a JSON response format still fail.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// FinishReason is reported for partial replies: "length" (default) or "timeout".
+	FinishReason string `yaml:"finish-reason,omitempty" json:"finish-reason,omitempty"`
+}
+
+// GeminiWebModelTimeout sets the timeout of requests to some models.
+type GeminiWebModelTimeout struct {
+	// Models lists the model names (aliases or underlying IDs) the rule applies to.
+	Models []string `yaml:"models" json:"models"`
+
+	// Seconds is the timeout of requests to these models.
+	Seconds int `yaml:"seconds" json:"seconds"`
+}
+
+// GeminiWebHashNormalization configures how message text is normalized before it is hashed or
+// compared for conversation reuse. Changing it rebuilds the conversation indexes.
+type GeminiWebHashNormalization struct {
+	// Trim removes leading and trailing whitespace.
+	Trim bool `yaml:"trim,omitempty" json:"trim,omitempty"`
+
+	// CollapseWhitespace replaces every run of whitespace with a single space.
+	CollapseWhitespace bool `yaml:"collapse-whitespace,omitempty" json:"collapse-whitespace,omitempty"`
+
+	// StripPatterns are regular expressions whose matches are removed, e.g. timestamps that
+	// clients embed in each message. They run before the whitespace options.
+	StripPatterns []string `yaml:"strip-patterns,omitempty" json:"strip-patterns,omitempty"`
+}
+
+// GeminiWebOutputPolicy describes post-processing applied to generated text.
+type GeminiWebOutputPolicy struct {
+	// APIKeys restricts the policy to these client API keys. An empty list matches every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Footer is appended to the response text after a blank line. "{model}" is replaced
+	// with the requested model name.
+	Footer string `yaml:"footer,omitempty" json:"footer,omitempty"`
+
+	// Watermark is encoded with zero-width characters and embedded in the response text.
+	Watermark string `yaml:"watermark,omitempty" json:"watermark,omitempty"`
+}
+
+// GeminiWebStreamProfile describes how streamed chat completion chunks are shaped.
+type GeminiWebStreamProfile struct {
+	// Name identifies the profile in logs.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// APIKeys lists the client API keys using this profile.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// RoleChunk emits a role-only first delta ({"role":"assistant","content":""}).
+	RoleChunk bool `yaml:"role-chunk" json:"role-chunk"`
+
+	// FinalChunk moves finish_reason and usage into a trailing chunk with an empty delta.
+	FinalChunk bool `yaml:"final-chunk" json:"final-chunk"`
+
+	// OmitNullFields drops null delta fields and non-standard fields such as native_finish_reason.
+	OmitNullFields bool `yaml:"omit-null-fields" json:"omit-null-fields"`
+
+	// OmitDone ends the stream without the "data: [DONE]" line.
+	OmitDone bool `yaml:"omit-done,omitempty" json:"omit-done,omitempty"`
+}
+
+// GeminiWebGemRule binds a Gem ID to matching Gemini Web requests.
+type GeminiWebGemRule struct {
+	// GemID is the Gem identifier, e.g. "coding-partner" or the ID of a custom Gem.
+	GemID string `yaml:"gem-id" json:"gem-id"`
+
+	// Models restricts the rule to these model names (aliases or underlying IDs).
+	// An empty list matches every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// APIKeys restricts the rule to requests authenticated with these client API keys.
+	// An empty list matches every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
 }
 
 // RemoteManagement holds management API configuration under 'remote-management'.
@@ -100,6 +600,701 @@ type QuotaExceeded struct {
 	SwitchPreviewModel bool `yaml:"switch-preview-model" json:"switch-preview-model"`
 }
 
+// APIKeyQuotaConfig configures per-key token accounting and quota enforcement.
+type APIKeyQuotaConfig struct {
+	// Enabled turns on token accounting and quota checks for client API keys.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// StoreFile persists usage counters across restarts. Relative paths are resolved against
+	// the auth directory; defaults to "api-key-quotas.usage". Avoid a .json extension there,
+	// since the auth watcher treats JSON files in that directory as credentials.
+	StoreFile string `yaml:"store-file,omitempty" json:"store-file,omitempty"`
+
+	// Default applies to keys without an explicit entry in Keys. Zero limits mean unlimited.
+	Default APIKeyQuotaLimits `yaml:"default" json:"default"`
+
+	// Keys overrides the limits for individual API keys.
+	Keys []APIKeyQuota `yaml:"keys,omitempty" json:"keys,omitempty"`
+
+	// Tiers names limits that managed client keys refer to by their quota tier. A key's
+	// entry in Keys takes precedence over its tier; keys with an unknown tier use Default.
+	Tiers map[string]APIKeyQuotaLimits `yaml:"tiers,omitempty" json:"tiers,omitempty"`
+}
+
+// TLSConfig configures HTTPS for the inbound API.
+type TLSConfig struct {
+	// Enabled serves HTTPS instead of plain HTTP.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Cert and Key are PEM files holding the server certificate chain and its private key.
+	Cert string `yaml:"cert" json:"cert"`
+	Key  string `yaml:"key" json:"key"`
+
+	// ClientCA is a PEM bundle of CAs that client certificates must chain to. When set,
+	// clients must present a valid certificate unless ClientAuth says otherwise.
+	ClientCA string `yaml:"client-ca,omitempty" json:"client-ca,omitempty"`
+
+	// ClientAuth is "require" (the default with ClientCA) or "verify-if-given", which accepts
+	// clients without a certificate but verifies those that present one.
+	ClientAuth string `yaml:"client-auth,omitempty" json:"client-auth,omitempty"`
+
+	// MinVersion is the lowest TLS version accepted: "1.2" (default) or "1.3".
+	MinVersion string `yaml:"min-version,omitempty" json:"min-version,omitempty"`
+}
+
+// UpstreamTLSConfig configures TLS for outbound connections through HTTPS proxies.
+type UpstreamTLSConfig struct {
+	// Cert and Key are PEM files holding the client certificate chain and its private key,
+	// presented when a proxy or upstream requests a client certificate.
+	Cert string `yaml:"cert,omitempty" json:"cert,omitempty"`
+	Key  string `yaml:"key,omitempty" json:"key,omitempty"`
+
+	// CA is a PEM bundle of CAs trusted in addition to the system roots.
+	CA string `yaml:"ca,omitempty" json:"ca,omitempty"`
+}
+
+// OIDCConfig configures validation of bearer JWTs issued by an OpenID Connect provider. Valid
+// tokens authenticate as "oidc:<principal claim>", which quotas and usage are tracked by.
+type OIDCConfig struct {
+	// Enabled accepts JWTs in the Authorization header.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Issuer must equal the token's "iss" claim. The signing keys are discovered from
+	// <issuer>/.well-known/openid-configuration unless JWKSURL is set.
+	Issuer string `yaml:"issuer" json:"issuer"`
+
+	// JWKSURL fetches the signing keys from this URL instead of using discovery.
+	JWKSURL string `yaml:"jwks-url,omitempty" json:"jwks-url,omitempty"`
+
+	// Audiences lists accepted "aud" values; a token must carry one of them. An empty list
+	// skips the audience check.
+	Audiences []string `yaml:"audiences,omitempty" json:"audiences,omitempty"`
+
+	// PrincipalClaim names the claim identifying the caller; defaults to "sub".
+	PrincipalClaim string `yaml:"principal-claim,omitempty" json:"principal-claim,omitempty"`
+
+	// JWKSRefreshMinutes is how long fetched signing keys are used before they are fetched
+	// again; defaults to 60. Tokens signed with an unknown key trigger an early refresh.
+	JWKSRefreshMinutes int `yaml:"jwks-refresh-minutes,omitempty" json:"jwks-refresh-minutes,omitempty"`
+
+	// ClockSkewSeconds is the leeway applied to "exp" and "nbf"; defaults to 60.
+	ClockSkewSeconds int `yaml:"clock-skew-seconds,omitempty" json:"clock-skew-seconds,omitempty"`
+
+	// QuotaTierClaim names a claim holding the api-key-quotas tier of the caller. Nested
+	// claims are addressed with dots, e.g. "app_metadata.tier".
+	QuotaTierClaim string `yaml:"quota-tier-claim,omitempty" json:"quota-tier-claim,omitempty"`
+
+	// ModelsClaim names a claim holding the models the caller may use, as an array or a
+	// space- or comma-separated string.
+	ModelsClaim string `yaml:"models-claim,omitempty" json:"models-claim,omitempty"`
+
+	// ClaimRules map claim values, such as group memberships, to a quota tier and models.
+	ClaimRules []OIDCClaimRule `yaml:"claim-rules,omitempty" json:"claim-rules,omitempty"`
+}
+
+// OIDCClaimRule applies when a token's claim equals Value, or contains it for array claims.
+// The first matching rule with a tier sets the tier unless QuotaTierClaim already did; the
+// allowed models are the union of the matching rules' models unless ModelsClaim is present.
+type OIDCClaimRule struct {
+	// Claim names the claim; nested claims are addressed with dots, e.g. "realm_access.roles".
+	Claim string `yaml:"claim" json:"claim"`
+
+	// Value is compared with the claim.
+	Value string `yaml:"value" json:"value"`
+
+	// QuotaTier selects an api-key-quotas tier.
+	QuotaTier string `yaml:"quota-tier,omitempty" json:"quota-tier,omitempty"`
+
+	// AllowedModels lists the models the caller may use; a trailing "*" matches by prefix.
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+}
+
+// ClientKeysConfig configures client API keys managed through the management API. Only a
+// SHA-256 hash of each key is stored; the key itself is shown once, when it is created.
+type ClientKeysConfig struct {
+	// Enabled accepts managed keys on the client-facing API and exposes the
+	// /client-keys management endpoints.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// StoreFile holds the key records. Relative paths are resolved against the auth
+	// directory; defaults to "client-keys.store".
+	StoreFile string `yaml:"store-file,omitempty" json:"store-file,omitempty"`
+}
+
+// APIKeyQuotaLimits holds token limits per calendar day and month (UTC).
+type APIKeyQuotaLimits struct {
+	// DailyTokens caps total tokens per UTC day; 0 means unlimited.
+	DailyTokens int64 `yaml:"daily-tokens,omitempty" json:"daily-tokens,omitempty"`
+
+	// MonthlyTokens caps total tokens per UTC calendar month; 0 means unlimited.
+	MonthlyTokens int64 `yaml:"monthly-tokens,omitempty" json:"monthly-tokens,omitempty"`
+}
+
+// APIKeyQuota binds quota limits to a client API key.
+type APIKeyQuota struct {
+	APIKey            string `yaml:"api-key" json:"api-key"`
+	APIKeyQuotaLimits `yaml:",inline"`
+}
+
+// RateLimitConfig configures token-bucket rate limits for the client-facing API. A request
+// must fit in every bucket that applies to it.
+type RateLimitConfig struct {
+	// Enabled turns rate limiting on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Global is shared by all requests.
+	Global RateLimitRule `yaml:"global,omitempty" json:"global,omitempty"`
+
+	// PerKey applies to each client API key without an entry in Keys.
+	PerKey RateLimitRule `yaml:"per-key,omitempty" json:"per-key,omitempty"`
+
+	// PerIP applies to each client IP address.
+	PerIP RateLimitRule `yaml:"per-ip,omitempty" json:"per-ip,omitempty"`
+
+	// Keys overrides PerKey for individual API keys.
+	Keys []RateLimitKey `yaml:"keys,omitempty" json:"keys,omitempty"`
+}
+
+// RateLimitRule describes one token bucket. A zero rate disables the bucket.
+type RateLimitRule struct {
+	// RequestsPerMinute is the sustained refill rate.
+	RequestsPerMinute float64 `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// Burst is the bucket capacity; defaults to one minute's worth of requests.
+	Burst int `yaml:"burst,omitempty" json:"burst,omitempty"`
+}
+
+// RateLimitKey binds a rate limit to a client API key.
+type RateLimitKey struct {
+	APIKey        string `yaml:"api-key" json:"api-key"`
+	RateLimitRule `yaml:",inline"`
+}
+
+// ModerationConfig configures the content filter. Prompts are filtered as they arrive, before
+// request logs or upstreams see them; completions before they reach the client.
+type ModerationConfig struct {
+	// Enabled turns the filter on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Rules are applied in order; the first blocking match rejects the request.
+	Rules []ModerationRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// SecretDetection masks credentials found in prompts sent to selected providers.
+	SecretDetection SecretDetectionConfig `yaml:"secret-detection,omitempty" json:"secret-detection,omitempty"`
+}
+
+// SecretDetectionConfig configures the built-in credential detectors (AWS keys, GitHub and
+// Slack tokens, Google API keys, private keys). It works independently of Enabled and Rules.
+type SecretDetectionConfig struct {
+	// Enabled turns detection on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Providers whose requests are scanned. Defaults to gemini-web; "*" scans every provider.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Action is "mask" (default) to replace secrets before sending, or "log" to only flag them.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// ModerationRule matches a regular expression or keywords in the text of a payload.
+type ModerationRule struct {
+	// Name identifies the rule in logs and block messages.
+	Name string `yaml:"name" json:"name"`
+
+	// Pattern is a regular expression (RE2 syntax).
+	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+
+	// Keywords match case-insensitively as whole words.
+	Keywords []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+
+	// Action is "redact" (default), "block" or "log".
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// Replacement substitutes redacted matches. Defaults to "[REDACTED]".
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+
+	// Scope is "prompt", "completion" or empty for both.
+	Scope string `yaml:"scope,omitempty" json:"scope,omitempty"`
+}
+
+// ProxyPoolConfig configures a pool of egress proxies. Accounts without their own proxy-url
+// are assigned a pool proxy instead of the global proxy-url and keep it while it is healthy.
+type ProxyPoolConfig struct {
+	// Enabled turns on the pool.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Proxies lists the proxy URLs (socks5, http or https).
+	Proxies []string `yaml:"proxies,omitempty" json:"proxies,omitempty"`
+
+	// HealthCheckURL is fetched through each proxy; any status below 400 counts as healthy.
+	// Defaults to "https://www.gstatic.com/generate_204".
+	HealthCheckURL string `yaml:"health-check-url,omitempty" json:"health-check-url,omitempty"`
+
+	// HealthCheckIntervalSeconds is the time between health checks; defaults to 60.
+	HealthCheckIntervalSeconds int `yaml:"health-check-interval-seconds,omitempty" json:"health-check-interval-seconds,omitempty"`
+
+	// HealthCheckTimeoutSeconds bounds each health check; defaults to 10.
+	HealthCheckTimeoutSeconds int `yaml:"health-check-timeout-seconds,omitempty" json:"health-check-timeout-seconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed health checks or requests after
+	// which a proxy is marked unhealthy; defaults to 2.
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+}
+
+// BandwidthConfig configures per-account upstream traffic ceilings. Traffic is always
+// counted; ceilings apply only when Enabled is set.
+type BandwidthConfig struct {
+	// Enabled turns on ceiling checks before requests are sent to an account.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// StoreFile persists traffic counters across restarts. Relative paths are resolved against
+	// the auth directory; defaults to "bandwidth.usage".
+	StoreFile string `yaml:"store-file,omitempty" json:"store-file,omitempty"`
+
+	// Default applies to accounts without an explicit entry in Accounts.
+	Default BandwidthLimits `yaml:"default" json:"default"`
+
+	// Accounts overrides the limits for individual accounts.
+	Accounts []BandwidthAccount `yaml:"accounts,omitempty" json:"accounts,omitempty"`
+}
+
+// BandwidthLimits caps the bytes sent plus received per calendar day and month (UTC).
+type BandwidthLimits struct {
+	// DailyBytes caps traffic per UTC day; 0 means unlimited.
+	DailyBytes int64 `yaml:"daily-bytes,omitempty" json:"daily-bytes,omitempty"`
+
+	// MonthlyBytes caps traffic per UTC calendar month; 0 means unlimited.
+	MonthlyBytes int64 `yaml:"monthly-bytes,omitempty" json:"monthly-bytes,omitempty"`
+}
+
+// BandwidthAccount binds bandwidth limits to an account, identified by its auth ID (the auth
+// file name for file-based credentials).
+type BandwidthAccount struct {
+	Account         string `yaml:"account" json:"account"`
+	BandwidthLimits `yaml:",inline"`
+}
+
+// FaultInjectionConfig gates the chaos testing fault injector. It is meant for test
+// deployments only: injected 401/429 errors put accounts into cooldown exactly as real ones do.
+type FaultInjectionConfig struct {
+	// Enabled turns fault injection on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Rules are evaluated in order; the first rule matching the provider and model applies.
+	Rules []FaultInjectionRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// FaultInjectionRule describes the faults applied to matching upstream requests.
+type FaultInjectionRule struct {
+	// Provider is the executor identifier (e.g. "gemini-web", "claude"); "*" or empty matches all.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Models restricts the rule to these models. An empty list matches every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// LatencyMs delays every matching request by this many milliseconds.
+	LatencyMs int `yaml:"latency-ms,omitempty" json:"latency-ms,omitempty"`
+
+	// LatencyJitterMs adds a random extra delay in [0, LatencyJitterMs).
+	LatencyJitterMs int `yaml:"latency-jitter-ms,omitempty" json:"latency-jitter-ms,omitempty"`
+
+	// ErrorRate is the probability (0-1) of failing the request with one of ErrorStatuses.
+	ErrorRate float64 `yaml:"error-rate,omitempty" json:"error-rate,omitempty"`
+
+	// ErrorStatuses lists the HTTP statuses to pick from. Defaults to 429 and 500.
+	ErrorStatuses []int `yaml:"error-statuses,omitempty" json:"error-statuses,omitempty"`
+
+	// TruncateRate is the probability (0-1) of cutting a streaming response short.
+	TruncateRate float64 `yaml:"truncate-rate,omitempty" json:"truncate-rate,omitempty"`
+
+	// TruncateAfterChunks is the number of chunks delivered before a truncated stream ends.
+	TruncateAfterChunks int `yaml:"truncate-after-chunks,omitempty" json:"truncate-after-chunks,omitempty"`
+
+	// CookieExpiryRate is the probability (0-1) of failing with a 401 that simulates
+	// expired web session cookies.
+	CookieExpiryRate float64 `yaml:"cookie-expiry-rate,omitempty" json:"cookie-expiry-rate,omitempty"`
+}
+
+// AuditLogConfig controls the JSONL audit log written for every proxied request.
+type AuditLogConfig struct {
+	// Enabled turns the audit log on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Dir is the directory for rotating audit files. Defaults to "logs/audit" when no
+	// HTTP sink is configured; set to "-" to disable the file sink.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// MaxSizeMB rotates the current file once it reaches this size. Defaults to 50.
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+
+	// MaxBackups is the number of rotated files to keep; 0 keeps all of them.
+	MaxBackups int `yaml:"max-backups,omitempty" json:"max-backups,omitempty"`
+
+	// MaxAgeDays removes rotated files older than this many days; 0 disables age-based cleanup.
+	MaxAgeDays int `yaml:"max-age-days,omitempty" json:"max-age-days,omitempty"`
+
+	// Compress gzips rotated files.
+	Compress bool `yaml:"compress,omitempty" json:"compress,omitempty"`
+
+	// HTTPURL receives batches of records as newline-delimited JSON POSTs.
+	HTTPURL string `yaml:"http-url,omitempty" json:"http-url,omitempty"`
+
+	// HTTPHeaders are added to every request sent to HTTPURL (e.g. Authorization).
+	HTTPHeaders map[string]string `yaml:"http-headers,omitempty" json:"http-headers,omitempty"`
+
+	// Redact lists per-field redaction rules. Without a rule for "api_key" the key is masked.
+	Redact []AuditRedactRule `yaml:"redact,omitempty" json:"redact,omitempty"`
+}
+
+// AuditRedactRule describes how one audit record field is redacted.
+type AuditRedactRule struct {
+	// Field is the JSON field name in the audit record, e.g. "api_key" or "client_ip".
+	Field string `yaml:"field" json:"field"`
+
+	// Mode is "mask" (keep a short prefix and suffix), "hash" (SHA-256 prefix), or "drop".
+	Mode string `yaml:"mode" json:"mode"`
+}
+
+// RequestStoreConfig configures the queryable store of recent requests.
+type RequestStoreConfig struct {
+	// Enabled records every API request in data/request-store.bolt.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Bodies also keeps request and response bodies, each truncated to MaxBodyKB.
+	Bodies bool `yaml:"bodies,omitempty" json:"bodies,omitempty"`
+
+	// MaxBodyKB caps each stored body. Defaults to 256.
+	MaxBodyKB int `yaml:"max-body-kb,omitempty" json:"max-body-kb,omitempty"`
+
+	// MaxSizeMB caps the stored records; the oldest are removed beyond it. Defaults to 512.
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+}
+
+// AuthEncryptionConfig configures encryption of auth files with AES-256-GCM. The key comes
+// from an environment variable or from a command, such as a KMS or secret manager client.
+type AuthEncryptionConfig struct {
+	// Enabled encrypts auth files when they are written. Encrypted files are read whenever a
+	// key is available, so they stay readable after encryption is turned off again.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// KeyEnv names the environment variable holding the key. Defaults to CLIPROXY_AUTH_KEY.
+	KeyEnv string `yaml:"key-env,omitempty" json:"key-env,omitempty"`
+
+	// KeyCommand is run by the shell and prints the key. It takes precedence over KeyEnv.
+	KeyCommand string `yaml:"key-command,omitempty" json:"key-command,omitempty"`
+}
+
+// AuthStoreConfig selects where auth tokens are kept. By default they are JSON files in
+// auth-dir; a secrets backend keeps each auth as one JSON secret instead.
+type AuthStoreConfig struct {
+	// Type is "file" (default), "vault" or "aws-secrets-manager".
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+
+	// Prefix is prepended to secret names. Defaults to "cliproxy/auth/".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	Vault AuthStoreVaultConfig `yaml:"vault,omitempty" json:"vault,omitempty"`
+	AWS   AuthStoreAWSConfig   `yaml:"aws,omitempty" json:"aws,omitempty"`
+}
+
+// AuthStoreVaultConfig locates a HashiCorp Vault KV version 2 secrets engine. Empty fields
+// fall back to the standard VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE variables.
+type AuthStoreVaultConfig struct {
+	Address   string `yaml:"address,omitempty" json:"address,omitempty"`
+	Token     string `yaml:"token,omitempty" json:"-"`
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Mount is the path of the KV engine. Defaults to "secret".
+	Mount string `yaml:"mount,omitempty" json:"mount,omitempty"`
+}
+
+// AuthStoreAWSConfig locates AWS Secrets Manager. Empty fields fall back to the standard
+// AWS_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables.
+type AuthStoreAWSConfig struct {
+	Region string `yaml:"region,omitempty" json:"region,omitempty"`
+
+	// Endpoint overrides the regional endpoint, e.g. for a VPC endpoint or LocalStack.
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+
+	// KMSKeyID encrypts newly created secrets with this KMS key instead of the AWS managed one.
+	KMSKeyID string `yaml:"kms-key-id,omitempty" json:"kms-key-id,omitempty"`
+
+	AccessKeyID     string `yaml:"access-key-id,omitempty" json:"-"`
+	SecretAccessKey string `yaml:"secret-access-key,omitempty" json:"-"`
+	SessionToken    string `yaml:"session-token,omitempty" json:"-"`
+}
+
+// SoftDeleteConfig controls how long deleted items stay restorable.
+type SoftDeleteConfig struct {
+	// Disabled makes deletions permanent immediately, as in earlier versions.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// RetentionHours is how long a deleted item can be restored before it is purged;
+	// defaults to 168 (7 days).
+	RetentionHours int `yaml:"retention-hours,omitempty" json:"retention-hours,omitempty"`
+}
+
+// Retention returns the restore window, applying the default.
+func (c SoftDeleteConfig) Retention() time.Duration {
+	if c.RetentionHours <= 0 {
+		return 168 * time.Hour
+	}
+	return time.Duration(c.RetentionHours) * time.Hour
+}
+
+// BatchesConfig controls the /v1/files and /v1/batches endpoints and how batch requests are
+// paced.
+type BatchesConfig struct {
+	// Enabled exposes the endpoints and runs queued batches.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Concurrency is how many requests of a batch run at once. Defaults to 1.
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// RequestIntervalMs is the minimum delay between the starts of two batch requests.
+	// Defaults to 1000.
+	RequestIntervalMs int `yaml:"request-interval-ms,omitempty" json:"request-interval-ms,omitempty"`
+
+	// MaxRetries is how often a rate-limited or unavailable request is retried before it is
+	// reported as failed. Defaults to 8.
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+
+	// MaxFileMB caps uploaded files. Defaults to 200.
+	MaxFileMB int `yaml:"max-file-mb,omitempty" json:"max-file-mb,omitempty"`
+
+	// MaxRequests caps the requests of one batch. Defaults to 50000.
+	MaxRequests int `yaml:"max-requests,omitempty" json:"max-requests,omitempty"`
+
+	// RetentionHours is how long files and finished batches are kept. Defaults to 720 (30 days).
+	RetentionHours int `yaml:"retention-hours,omitempty" json:"retention-hours,omitempty"`
+}
+
+// AlertsConfig lists the channels operator alerts are sent to. Every configured channel
+// receives every alert; none are configured by default.
+type AlertsConfig struct {
+	// WebhookURL receives each alert as a JSON POST.
+	WebhookURL string `yaml:"webhook-url,omitempty" json:"webhook-url,omitempty"`
+
+	// WebhookHeaders are added to the webhook request, e.g. an Authorization header.
+	WebhookHeaders map[string]string `yaml:"webhook-headers,omitempty" json:"webhook-headers,omitempty"`
+
+	// Email sends alerts through an SMTP server.
+	Email AlertEmailConfig `yaml:"email,omitempty" json:"email,omitempty"`
+
+	// Telegram sends alerts to a chat through a Telegram bot.
+	Telegram AlertTelegramConfig `yaml:"telegram,omitempty" json:"telegram,omitempty"`
+}
+
+// AlertEmailConfig configures alert delivery by email. It is used when SMTPHost and To are set.
+type AlertEmailConfig struct {
+	SMTPHost string `yaml:"smtp-host,omitempty" json:"smtp-host,omitempty"`
+
+	// SMTPPort defaults to 587.
+	SMTPPort int `yaml:"smtp-port,omitempty" json:"smtp-port,omitempty"`
+
+	// Username and Password authenticate with PLAIN auth; leave empty for unauthenticated relays.
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+
+	From string   `yaml:"from,omitempty" json:"from,omitempty"`
+	To   []string `yaml:"to,omitempty" json:"to,omitempty"`
+}
+
+// AlertTelegramConfig configures alert delivery through a Telegram bot.
+type AlertTelegramConfig struct {
+	BotToken string `yaml:"bot-token,omitempty" json:"bot-token,omitempty"`
+	ChatID   string `yaml:"chat-id,omitempty" json:"chat-id,omitempty"`
+}
+
+// AsyncJobsConfig controls background execution of requests that prefer an asynchronous
+// response.
+type AsyncJobsConfig struct {
+	// Enabled honours "Prefer: respond-async"; otherwise the preference is ignored and the
+	// request is answered synchronously.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// WebhookURL receives every finished job as a JSON POST. When empty, results can only be
+	// polled from /v1/jobs/{id}.
+	WebhookURL string `yaml:"webhook-url,omitempty" json:"webhook-url,omitempty"`
+
+	// WebhookHeaders are added to the webhook request, e.g. an Authorization header.
+	WebhookHeaders map[string]string `yaml:"webhook-headers,omitempty" json:"webhook-headers,omitempty"`
+
+	// WebhookSecret signs the webhook body with HMAC-SHA256 in the X-CLIProxy-Signature header.
+	WebhookSecret string `yaml:"webhook-secret,omitempty" json:"webhook-secret,omitempty"`
+
+	// MaxRunning caps the jobs executing at once; further jobs wait. Defaults to 8.
+	MaxRunning int `yaml:"max-running,omitempty" json:"max-running,omitempty"`
+
+	// MaxQueued caps the jobs waiting to run; requests beyond it are rejected with 429.
+	// Defaults to 100.
+	MaxQueued int `yaml:"max-queued,omitempty" json:"max-queued,omitempty"`
+
+	// TimeoutSeconds caps the execution of one job. Defaults to 600.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// RetentionMinutes is how long a finished job can be polled. Defaults to 60.
+	RetentionMinutes int `yaml:"retention-minutes,omitempty" json:"retention-minutes,omitempty"`
+}
+
+// AssetsConfig controls where re-hosted images are kept and for how long.
+type AssetsConfig struct {
+	// Enabled downloads generated images at response time and returns proxy links instead of
+	// inline base64 data.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Storage is "local" (default), "s3" or "gcs".
+	Storage string `yaml:"storage,omitempty" json:"storage,omitempty"`
+
+	// Dir is the directory of local storage; defaults to "assets" in the working directory.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// TTLHours is how long an image stays available; defaults to 168 (7 days).
+	TTLHours int `yaml:"ttl-hours,omitempty" json:"ttl-hours,omitempty"`
+
+	// PublicBaseURL is the externally reachable URL of the proxy links are built on, e.g.
+	// https://proxy.example.com. When empty the host of the client request is used.
+	PublicBaseURL string `yaml:"public-base-url,omitempty" json:"public-base-url,omitempty"`
+
+	// S3 configures the "s3" storage, which also works with S3-compatible services.
+	S3 AssetsS3Config `yaml:"s3,omitempty" json:"s3,omitempty"`
+
+	// GCS configures the "gcs" storage.
+	GCS AssetsGCSConfig `yaml:"gcs,omitempty" json:"gcs,omitempty"`
+}
+
+// TTL returns how long an image stays available, applying the default.
+func (c AssetsConfig) TTL() time.Duration {
+	if c.TTLHours <= 0 {
+		return 168 * time.Hour
+	}
+	return time.Duration(c.TTLHours) * time.Hour
+}
+
+// AssetsS3Config locates an S3 bucket and the credentials to write to it.
+type AssetsS3Config struct {
+	Bucket string `yaml:"bucket" json:"bucket"`
+
+	// Region defaults to us-east-1.
+	Region string `yaml:"region,omitempty" json:"region,omitempty"`
+
+	// Endpoint overrides the AWS endpoint for S3-compatible services such as MinIO or R2.
+	// Requests use path-style addressing.
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+
+	// Prefix is prepended to object names, e.g. "cliproxy/".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	AccessKeyID     string `yaml:"access-key-id" json:"-"`
+	SecretAccessKey string `yaml:"secret-access-key" json:"-"`
+	SessionToken    string `yaml:"session-token,omitempty" json:"-"`
+}
+
+// AssetsGCSConfig locates a Google Cloud Storage bucket.
+type AssetsGCSConfig struct {
+	Bucket string `yaml:"bucket" json:"bucket"`
+
+	// Prefix is prepended to object names, e.g. "cliproxy/".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// CredentialsFile is the path of a service account JSON key; when empty the application
+	// default credentials are used.
+	CredentialsFile string `yaml:"credentials-file,omitempty" json:"credentials-file,omitempty"`
+}
+
+// UtilizationReportConfig controls delivery of the daily account pool utilization report.
+type UtilizationReportConfig struct {
+	// WebhookURL receives the report as a JSON POST once a day. Empty disables delivery.
+	WebhookURL string `yaml:"webhook-url" json:"webhook-url"`
+
+	// HourUTC is the hour of day (0-23, UTC) at which the report is sent.
+	HourUTC int `yaml:"hour-utc" json:"hour-utc"`
+
+	// SaturationThreshold is the share of a model's accounts (0-1] that must hit quota within
+	// the same hour before a recommendation is made. Defaults to 0.5.
+	SaturationThreshold float64 `yaml:"saturation-threshold" json:"saturation-threshold"`
+}
+
+// UsageExportConfig controls the per-user usage export served at /usage/export.
+type UsageExportConfig struct {
+	// PseudonymSalt keys the hash that replaces client API keys in the export. When empty the
+	// plain SHA-256 of the key is used.
+	PseudonymSalt string `yaml:"pseudonym-salt,omitempty" json:"pseudonym-salt,omitempty"`
+
+	// DifferentialPrivacy adds calibrated noise to the exported counts.
+	DifferentialPrivacy UsageExportPrivacy `yaml:"differential-privacy,omitempty" json:"differential-privacy,omitempty"`
+}
+
+// UsageExportPrivacy configures Laplace noise for the usage export.
+type UsageExportPrivacy struct {
+	// Enabled turns on noise and small-count suppression.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Epsilon is the privacy budget of one release; smaller values add more noise. Defaults to 1.
+	Epsilon float64 `yaml:"epsilon,omitempty" json:"epsilon,omitempty"`
+
+	// MaxTokensPerRequest bounds the tokens a single request contributes, which sets the scale
+	// of the token noise. Defaults to 32768.
+	MaxTokensPerRequest int64 `yaml:"max-tokens-per-request,omitempty" json:"max-tokens-per-request,omitempty"`
+
+	// MinCount suppresses user/model rows whose noised request count is below it. Defaults to 10.
+	MinCount int64 `yaml:"min-count,omitempty" json:"min-count,omitempty"`
+
+	// ReleasePeriodHours is how long one noised release is served unchanged; repeated exports
+	// within the period return the same figures and spend no further budget. Defaults to 24.
+	ReleasePeriodHours int `yaml:"release-period-hours,omitempty" json:"release-period-hours,omitempty"`
+
+	// TotalEpsilon caps the budget spent by all releases since the server started; once a new
+	// release would exceed it, exports are refused. 0 means no cap.
+	TotalEpsilon float64 `yaml:"total-epsilon,omitempty" json:"total-epsilon,omitempty"`
+}
+
+// ReleasePeriod returns how long one noised release is served, applying the default.
+func (p UsageExportPrivacy) ReleasePeriod() time.Duration {
+	if p.ReleasePeriodHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(p.ReleasePeriodHours) * time.Hour
+}
+
+// PromptJob is a recurring prompt executed by the server itself.
+type PromptJob struct {
+	// Name identifies the job in logs and result files.
+	Name string `yaml:"name" json:"name"`
+
+	// Schedule is a five-field cron expression (minute hour day-of-month month day-of-week),
+	// one of @hourly, @daily, @weekly, @monthly, or "@every <duration>".
+	Schedule string `yaml:"schedule" json:"schedule"`
+
+	// Timezone is the IANA zone the cron expression is evaluated in. Defaults to local time.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// Model is the model or alias to call.
+	Model string `yaml:"model" json:"model"`
+
+	// APIKey runs the job as this client key, so its api-key-rules (aliases, allowed
+	// providers) select the account pool.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// System is an optional system prompt.
+	System string `yaml:"system,omitempty" json:"system,omitempty"`
+
+	// Prompt is the user message. "{date}" and "{time}" are replaced with the run time.
+	Prompt string `yaml:"prompt" json:"prompt"`
+
+	// WebhookURL receives the result as a JSON POST.
+	WebhookURL string `yaml:"webhook-url,omitempty" json:"webhook-url,omitempty"`
+
+	// WebhookHeaders are added to the webhook request, e.g. an Authorization header.
+	WebhookHeaders map[string]string `yaml:"webhook-headers,omitempty" json:"webhook-headers,omitempty"`
+
+	// OutputDir receives the result as "<name>-<timestamp>.json".
+	OutputDir string `yaml:"output-dir,omitempty" json:"output-dir,omitempty"`
+
+	// Disabled keeps the job configured without running it.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
 // ClaudeKey represents the configuration for a Claude API key,
 // including the API key itself and an optional base URL for the API endpoint.
 type ClaudeKey struct {
@@ -112,6 +1307,11 @@ type ClaudeKey struct {
 
 	// ProxyURL overrides the global proxy setting for this API key if provided.
 	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
+
+	// ClaudeCode sends requests as Claude Code (bearer auth, Claude Code system prompt and
+	// headers) for endpoints that expect it. By default requests are plain Messages API calls
+	// authenticated with x-api-key that keep the client's system prompt.
+	ClaudeCode bool `yaml:"claude-code,omitempty" json:"claude-code,omitempty"`
 }
 
 // CodexKey represents the configuration for a Codex API key,
@@ -128,6 +1328,53 @@ type CodexKey struct {
 	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
 }
 
+// BedrockKey represents AWS credentials for Amazon Bedrock and the models they serve.
+type BedrockKey struct {
+	// AccessKeyID and SecretAccessKey sign requests with AWS Signature Version 4.
+	AccessKeyID     string `yaml:"access-key-id" json:"access-key-id"`
+	SecretAccessKey string `yaml:"secret-access-key" json:"secret-access-key"`
+
+	// SessionToken is required for temporary (STS) credentials.
+	SessionToken string `yaml:"session-token,omitempty" json:"session-token,omitempty"`
+
+	// Region is the AWS region of the bedrock-runtime endpoint, e.g. us-east-1.
+	Region string `yaml:"region" json:"region"`
+
+	// BaseURL overrides the bedrock-runtime endpoint, e.g. for a VPC endpoint.
+	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
+
+	// ProxyURL overrides the global proxy setting for these credentials if provided.
+	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Models maps Bedrock model or inference profile IDs to the aliases clients request.
+	Models []BedrockModel `yaml:"models" json:"models"`
+}
+
+// BedrockModel maps a Bedrock model ID to the alias used in the API.
+type BedrockModel struct {
+	// Name is the Bedrock model or inference profile ID, e.g. anthropic.claude-3-5-sonnet-20241022-v2:0.
+	Name string `yaml:"name" json:"name"`
+
+	// Alias is the model name clients use; empty exposes Name itself.
+	Alias string `yaml:"alias,omitempty" json:"alias,omitempty"`
+}
+
+// VertexAIKey represents a Google Cloud service account serving the Gemini models through
+// Vertex AI.
+type VertexAIKey struct {
+	// CredentialsFile is the path of the service account JSON key.
+	CredentialsFile string `yaml:"credentials-file" json:"credentials-file"`
+
+	// ProjectID overrides the project of the service account key.
+	ProjectID string `yaml:"project-id,omitempty" json:"project-id,omitempty"`
+
+	// Location is the Vertex AI region, e.g. us-central1, or "global". Defaults to us-central1.
+	Location string `yaml:"location,omitempty" json:"location,omitempty"`
+
+	// ProxyURL overrides the global proxy setting for this service account if provided.
+	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+}
+
 // OpenAICompatibility represents the configuration for OpenAI API compatibility
 // with external providers, allowing model aliases to be routed through OpenAI API format.
 type OpenAICompatibility struct {
@@ -165,6 +1412,10 @@ type OpenAICompatibilityModel struct {
 
 	// Alias is the model name alias that clients will use to reference this model.
 	Alias string `yaml:"alias" json:"alias"`
+
+	// BaseURL overrides the provider base URL for this model, e.g. to serve it from a
+	// self-hosted vLLM or llama.cpp server while other models stay on the hosted API.
+	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
 }
 
 // LoadConfig reads a YAML configuration file from the given path,
@@ -284,6 +1535,7 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 
 	// Merge generated into original in-place, preserving comments/order of existing nodes.
 	mergeMappingPreserve(original.Content[0], generated.Content[0])
+	syncModelAliasKeys(original.Content[0], generated.Content[0])
 
 	// Write back.
 	f, err := os.Create(configFile)
@@ -513,6 +1765,48 @@ func copyNodeShallow(dst, src *yaml.Node) {
 	}
 }
 
+// syncModelAliasKeys drops the aliases removed from the configuration. Alias maps are keyed by
+// user-chosen names, and the merge only adds and updates keys.
+func syncModelAliasKeys(dst, src *yaml.Node) {
+	syncMapKeys(dst, src, "model-aliases")
+	di, si := findMapKeyIndex(dst, "api-key-rules"), findMapKeyIndex(src, "api-key-rules")
+	if di < 0 || si < 0 {
+		return
+	}
+	dstRules, srcRules := dst.Content[di+1], src.Content[si+1]
+	if dstRules.Kind != yaml.SequenceNode || srcRules.Kind != yaml.SequenceNode {
+		return
+	}
+	for i := 0; i < len(dstRules.Content) && i < len(srcRules.Content); i++ {
+		syncMapKeys(dstRules.Content[i], srcRules.Content[i], "model-aliases")
+	}
+}
+
+// syncMapKeys makes the mapping under key in dst hold only the keys present under key in src,
+// removing it entirely when src no longer has it.
+func syncMapKeys(dst, src *yaml.Node, key string) {
+	di := findMapKeyIndex(dst, key)
+	if di < 0 {
+		return
+	}
+	si := findMapKeyIndex(src, key)
+	if si < 0 {
+		removeMapKey(dst, key)
+		return
+	}
+	dstMap, srcMap := dst.Content[di+1], src.Content[si+1]
+	if dstMap.Kind != yaml.MappingNode || srcMap.Kind != yaml.MappingNode {
+		return
+	}
+	kept := dstMap.Content[:0]
+	for i := 0; i+1 < len(dstMap.Content); i += 2 {
+		if findMapKeyIndex(srcMap, dstMap.Content[i].Value) >= 0 {
+			kept = append(kept, dstMap.Content[i], dstMap.Content[i+1])
+		}
+	}
+	dstMap.Content = kept
+}
+
 func removeMapKey(mapNode *yaml.Node, key string) {
 	if mapNode == nil || mapNode.Kind != yaml.MappingNode || key == "" {
 		return
@@ -0,0 +1,271 @@
+// Package conformance runs self-tests of the client-facing API formats against a mock
+// provider. Each check translates a canned client request to the Gemini format, feeds a
+// canned Gemini response back through the same translators the real executors use and
+// validates the shape a client of that format would receive.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	mockModel    = "conformance-mock"
+	mockProvider = constant.Gemini
+)
+
+// Check is the outcome of one self-test.
+type Check struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Passed   bool   `json:"passed"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Report summarises a conformance run for one handler type.
+type Report struct {
+	HandlerType string  `json:"handler_type"`
+	Provider    string  `json:"provider"`
+	Passed      int     `json:"passed"`
+	Failed      int     `json:"failed"`
+	Checks      []Check `json:"checks"`
+}
+
+// ErrUnknownHandlerType is returned by Run for handler types without a suite.
+var ErrUnknownHandlerType = errors.New("unknown handler type")
+
+// HandlerTypes lists the handler types that have a conformance suite.
+func HandlerTypes() []string {
+	types := make([]string, 0, len(suites))
+	for handlerType := range suites {
+		types = append(types, handlerType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Run executes every check of the suite for handlerType.
+func Run(ctx context.Context, handlerType string) (Report, error) {
+	s, ok := suites[handlerType]
+	if !ok {
+		return Report{}, fmt.Errorf("%w %q", ErrUnknownHandlerType, handlerType)
+	}
+	r := &runner{ctx: ctx, from: sdktranslator.FromString(handlerType), to: sdktranslator.FromString(mockProvider)}
+	report := Report{HandlerType: handlerType, Provider: "mock-" + mockProvider}
+
+	report.add("text request is translated", "request", r.checkRequest(s.textRequest, func(req gjson.Result) error {
+		return expectPromptText(req, "Say hello.")
+	}))
+	report.add("text response", "non_stream", r.checkNonStream(s.textRequest, mockTextResponse, s.text))
+	report.add("text stream", "stream", r.checkStream(s.textRequest, mockTextStream, s.textStream))
+	report.add("tool definitions are translated", "tools", r.checkRequest(s.toolRequest, expectToolDeclaration))
+	report.add("tool call response", "tools", r.checkNonStream(s.toolRequest, mockToolResponse, s.tool))
+	report.add("tool call stream", "tools", r.checkStream(s.toolRequest, mockToolStream, s.toolStream))
+	report.add("image input is translated", "images", r.checkRequest(s.imageRequest, expectInlineImage))
+	report.add("rate limit error keeps status and JSON body", "errors", checkError(http.StatusTooManyRequests, mockRateLimitError))
+	report.add("upstream failure without body", "errors", checkError(http.StatusBadGateway, ""))
+	return report, nil
+}
+
+func (r *Report) add(name, category string, err error) {
+	check := Check{Name: name, Category: category, Passed: err == nil}
+	if err != nil {
+		check.Detail = err.Error()
+		r.Failed++
+	} else {
+		r.Passed++
+	}
+	r.Checks = append(r.Checks, check)
+}
+
+type runner struct {
+	ctx      context.Context
+	from, to sdktranslator.Format
+}
+
+func (r *runner) translate(request string, stream bool) []byte {
+	return sdktranslator.TranslateRequest(r.from, r.to, mockModel, []byte(request), stream)
+}
+
+func (r *runner) checkRequest(request string, validate func(gjson.Result) error) error {
+	translated := r.translate(request, false)
+	if !gjson.ValidBytes(translated) {
+		return fmt.Errorf("translated request is not valid JSON: %s", truncate(string(translated)))
+	}
+	return validate(gjson.ParseBytes(translated))
+}
+
+func (r *runner) checkNonStream(request, upstream string, validate func(gjson.Result) error) error {
+	translated := r.translate(request, false)
+	var param any
+	out := sdktranslator.TranslateNonStream(r.ctx, r.to, r.from, mockModel, []byte(request), translated, []byte(upstream), &param)
+	if !gjson.Valid(out) {
+		return fmt.Errorf("response is not valid JSON: %s", truncate(out))
+	}
+	if err := validate(gjson.Parse(out)); err != nil {
+		return fmt.Errorf("%w; got %s", err, truncate(out))
+	}
+	return nil
+}
+
+func (r *runner) checkStream(request string, upstream []string, validate func([]gjson.Result) error) error {
+	translated := r.translate(request, true)
+	var param any
+	var events []gjson.Result
+	for _, chunk := range append(upstream, "[DONE]") {
+		for _, out := range sdktranslator.TranslateStream(r.ctx, r.to, r.from, mockModel, []byte(request), translated, []byte(chunk), &param) {
+			parsed, err := streamEvents(out)
+			if err != nil {
+				return err
+			}
+			events = append(events, parsed...)
+		}
+	}
+	if len(events) == 0 {
+		return errors.New("stream produced no events")
+	}
+	return validate(events)
+}
+
+// streamEvents extracts the JSON payloads of one translated stream chunk, which is either
+// bare JSON or SSE lines.
+func streamEvents(chunk string) ([]gjson.Result, error) {
+	chunk = strings.TrimSpace(chunk)
+	if chunk == "" {
+		return nil, nil
+	}
+	if gjson.Valid(chunk) {
+		return []gjson.Result{gjson.Parse(chunk)}, nil
+	}
+	var events []gjson.Result
+	for _, line := range strings.Split(chunk, "\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			continue
+		}
+		if !gjson.Valid(data) {
+			return nil, fmt.Errorf("stream event is not valid JSON: %s", truncate(data))
+		}
+		events = append(events, gjson.Parse(data))
+	}
+	return events, nil
+}
+
+// checkError renders an upstream error the way every handler does and checks the status
+// and body a client would see.
+func checkError(status int, body string) error {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	msg := &interfaces.ErrorMessage{StatusCode: status}
+	if body != "" {
+		msg.Error = errors.New(body)
+	}
+	(&handlers.BaseAPIHandler{}).WriteErrorResponse(c, msg)
+	if recorder.Code != status {
+		return fmt.Errorf("expected status %d, got %d", status, recorder.Code)
+	}
+	if recorder.Body.Len() == 0 {
+		return errors.New("empty error body")
+	}
+	if body != "" {
+		if !gjson.Valid(recorder.Body.String()) {
+			return fmt.Errorf("error body is not valid JSON: %s", truncate(recorder.Body.String()))
+		}
+		if gjson.Get(recorder.Body.String(), "error.message").String() == "" {
+			return errors.New("error body has no error.message")
+		}
+	}
+	return nil
+}
+
+func expectPromptText(req gjson.Result, text string) error {
+	found := false
+	req.Get("contents").ForEach(func(_, content gjson.Result) bool {
+		content.Get("parts").ForEach(func(_, part gjson.Result) bool {
+			if strings.Contains(part.Get("text").String(), text) {
+				found = true
+			}
+			return !found
+		})
+		return !found
+	})
+	if !found {
+		return fmt.Errorf("prompt %q not found in upstream contents: %s", text, truncate(req.Raw))
+	}
+	return nil
+}
+
+func expectToolDeclaration(req gjson.Result) error {
+	found := false
+	req.Get("tools").ForEach(func(_, tool gjson.Result) bool {
+		tool.Get("functionDeclarations").ForEach(func(_, decl gjson.Result) bool {
+			found = decl.Get("name").String() == mockToolName
+			return !found
+		})
+		return !found
+	})
+	if !found {
+		return fmt.Errorf("function declaration %q not found upstream: %s", mockToolName, truncate(req.Raw))
+	}
+	return nil
+}
+
+func expectInlineImage(req gjson.Result) error {
+	found := false
+	req.Get("contents").ForEach(func(_, content gjson.Result) bool {
+		content.Get("parts").ForEach(func(_, part gjson.Result) bool {
+			inline := part.Get("inlineData")
+			if !inline.Exists() {
+				inline = part.Get("inline_data")
+			}
+			found = inline.Get("data").String() == mockImageData
+			return !found
+		})
+		return !found
+	})
+	if !found {
+		return fmt.Errorf("image not forwarded as inline data: %s", truncate(req.Raw))
+	}
+	return nil
+}
+
+func truncate(s string) string {
+	const limit = 400
+	if len(s) > limit {
+		return s[:limit] + "..."
+	}
+	return s
+}
+
+// Handle serves GET /v1/conformance?handler_type=<type>.
+func Handle(c *gin.Context) {
+	handlerType := strings.TrimSpace(c.Query("handler_type"))
+	report, err := Run(c.Request.Context(), handlerType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("handler_type must be one of: %s", strings.Join(HandlerTypes(), ", ")),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
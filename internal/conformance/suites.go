@@ -0,0 +1,347 @@
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	mockText      = "Hello from the conformance mock."
+	mockToolName  = "get_weather"
+	mockImageData = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
+
+	mockTextResponse = `{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello from the conformance mock."}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":6,"totalTokenCount":11},"modelVersion":"conformance-mock","responseId":"mock-text"}`
+	mockToolResponse = `{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":8,"totalTokenCount":28},"modelVersion":"conformance-mock","responseId":"mock-tool"}`
+
+	mockRateLimitError = `{"error":{"code":429,"message":"Resource has been exhausted (conformance mock).","status":"RESOURCE_EXHAUSTED"}}`
+)
+
+var (
+	mockTextStream = []string{
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hello from "}]},"index":0}],"modelVersion":"conformance-mock","responseId":"mock-text"}`,
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"the conformance mock."}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":6,"totalTokenCount":11},"modelVersion":"conformance-mock","responseId":"mock-text"}`,
+	}
+	mockToolStream = []string{
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":8,"totalTokenCount":28},"modelVersion":"conformance-mock","responseId":"mock-tool"}`,
+	}
+)
+
+// suite holds the canned requests of one client format and the validators of the
+// responses that format must produce.
+type suite struct {
+	textRequest, toolRequest, imageRequest string
+
+	text       func(gjson.Result) error
+	textStream func([]gjson.Result) error
+	tool       func(gjson.Result) error
+	toolStream func([]gjson.Result) error
+}
+
+var suites = map[string]suite{
+	constant.OpenAI: {
+		textRequest:  `{"model":"conformance-mock","messages":[{"role":"user","content":"Say hello."}]}`,
+		toolRequest:  `{"model":"conformance-mock","messages":[{"role":"user","content":"Weather in Paris?"}],"tools":[{"type":"function","function":{"name":"get_weather","description":"Current weather","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}}]}`,
+		imageRequest: `{"model":"conformance-mock","messages":[{"role":"user","content":[{"type":"text","text":"Describe this image."},{"type":"image_url","image_url":{"url":"data:image/png;base64,` + mockImageData + `"}}]}]}`,
+		text: func(resp gjson.Result) error {
+			return all(
+				expectEqual(resp, "object", "chat.completion"),
+				expectEqual(resp, "choices.0.message.role", "assistant"),
+				expectEqual(resp, "choices.0.message.content", mockText),
+				expectEqual(resp, "choices.0.finish_reason", "stop"),
+				expectPresent(resp, "usage.total_tokens"),
+			)
+		},
+		textStream: func(events []gjson.Result) error {
+			var content strings.Builder
+			finish := ""
+			for _, event := range events {
+				if event.Get("object").String() != "chat.completion.chunk" {
+					return fmt.Errorf("chunk object is %q, want chat.completion.chunk", event.Get("object").String())
+				}
+				content.WriteString(event.Get("choices.0.delta.content").String())
+				if f := event.Get("choices.0.finish_reason").String(); f != "" {
+					finish = f
+				}
+			}
+			return all(expectText(content.String()), expectFinish(finish, "stop"))
+		},
+		tool: func(resp gjson.Result) error {
+			return all(
+				expectEqual(resp, "choices.0.message.tool_calls.0.type", "function"),
+				expectEqual(resp, "choices.0.message.tool_calls.0.function.name", mockToolName),
+				expectPresent(resp, "choices.0.message.tool_calls.0.id"),
+				expectJSONString(resp, "choices.0.message.tool_calls.0.function.arguments"),
+				expectEqual(resp, "choices.0.finish_reason", "tool_calls"),
+			)
+		},
+		toolStream: func(events []gjson.Result) error {
+			name, finish := "", ""
+			var args strings.Builder
+			for _, event := range events {
+				call := event.Get("choices.0.delta.tool_calls.0")
+				if n := call.Get("function.name").String(); n != "" {
+					name = n
+				}
+				args.WriteString(call.Get("function.arguments").String())
+				if f := event.Get("choices.0.finish_reason").String(); f != "" {
+					finish = f
+				}
+			}
+			return all(expectName(name), expectArguments(args.String()), expectFinish(finish, "tool_calls"))
+		},
+	},
+	constant.Claude: {
+		textRequest:  `{"model":"conformance-mock","max_tokens":256,"messages":[{"role":"user","content":"Say hello."}]}`,
+		toolRequest:  `{"model":"conformance-mock","max_tokens":256,"messages":[{"role":"user","content":"Weather in Paris?"}],"tools":[{"name":"get_weather","description":"Current weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}]}`,
+		imageRequest: `{"model":"conformance-mock","max_tokens":256,"messages":[{"role":"user","content":[{"type":"text","text":"Describe this image."},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + mockImageData + `"}}]}]}`,
+		text: func(resp gjson.Result) error {
+			return all(
+				expectEqual(resp, "type", "message"),
+				expectEqual(resp, "role", "assistant"),
+				expectEqual(resp, "content.0.type", "text"),
+				expectEqual(resp, "content.0.text", mockText),
+				expectEqual(resp, "stop_reason", "end_turn"),
+				expectPresent(resp, "usage.output_tokens"),
+			)
+		},
+		textStream: func(events []gjson.Result) error {
+			if err := expectEventOrder(events, "type", "message_start", "message_stop"); err != nil {
+				return err
+			}
+			var content strings.Builder
+			stop := ""
+			for _, event := range events {
+				if event.Get("type").String() == "content_block_delta" {
+					content.WriteString(event.Get("delta.text").String())
+				}
+				if s := event.Get("delta.stop_reason").String(); s != "" {
+					stop = s
+				}
+			}
+			return all(expectText(content.String()), expectFinish(stop, "end_turn"))
+		},
+		tool: func(resp gjson.Result) error {
+			block := firstWith(resp.Get("content"), "type", "tool_use")
+			if !block.Exists() {
+				return errors.New("no tool_use content block")
+			}
+			return all(
+				expectEqual(block, "name", mockToolName),
+				expectPresent(block, "id"),
+				expectObject(block, "input"),
+				expectEqual(resp, "stop_reason", "tool_use"),
+			)
+		},
+		toolStream: func(events []gjson.Result) error {
+			if err := expectEventOrder(events, "type", "message_start", "message_stop"); err != nil {
+				return err
+			}
+			name, stop := "", ""
+			var args strings.Builder
+			for _, event := range events {
+				if event.Get("content_block.type").String() == "tool_use" {
+					name = event.Get("content_block.name").String()
+				}
+				if event.Get("delta.type").String() == "input_json_delta" {
+					args.WriteString(event.Get("delta.partial_json").String())
+				}
+				if s := event.Get("delta.stop_reason").String(); s != "" {
+					stop = s
+				}
+			}
+			return all(expectName(name), expectArguments(args.String()), expectFinish(stop, "tool_use"))
+		},
+	},
+	constant.Gemini: {
+		textRequest:  `{"contents":[{"role":"user","parts":[{"text":"Say hello."}]}]}`,
+		toolRequest:  `{"contents":[{"role":"user","parts":[{"text":"Weather in Paris?"}]}],"tools":[{"functionDeclarations":[{"name":"get_weather","description":"Current weather","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}]}]}`,
+		imageRequest: `{"contents":[{"role":"user","parts":[{"text":"Describe this image."},{"inlineData":{"mimeType":"image/png","data":"` + mockImageData + `"}}]}]}`,
+		text: func(resp gjson.Result) error {
+			return all(
+				expectEqual(resp, "candidates.0.content.parts.0.text", mockText),
+				expectEqual(resp, "candidates.0.finishReason", "STOP"),
+				expectPresent(resp, "usageMetadata.totalTokenCount"),
+			)
+		},
+		textStream: func(events []gjson.Result) error {
+			var content strings.Builder
+			finish := ""
+			for _, event := range events {
+				event.Get("candidates.0.content.parts").ForEach(func(_, part gjson.Result) bool {
+					content.WriteString(part.Get("text").String())
+					return true
+				})
+				if f := event.Get("candidates.0.finishReason").String(); f != "" {
+					finish = f
+				}
+			}
+			return all(expectText(content.String()), expectFinish(finish, "STOP"))
+		},
+		tool: func(resp gjson.Result) error {
+			return all(
+				expectEqual(resp, "candidates.0.content.parts.0.functionCall.name", mockToolName),
+				expectObject(resp, "candidates.0.content.parts.0.functionCall.args"),
+			)
+		},
+		toolStream: func(events []gjson.Result) error {
+			name := ""
+			for _, event := range events {
+				if n := event.Get("candidates.0.content.parts.0.functionCall.name").String(); n != "" {
+					name = n
+				}
+			}
+			return expectName(name)
+		},
+	},
+	constant.OpenaiResponse: {
+		textRequest:  `{"model":"conformance-mock","input":"Say hello."}`,
+		toolRequest:  `{"model":"conformance-mock","input":"Weather in Paris?","tools":[{"type":"function","name":"get_weather","description":"Current weather","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}]}`,
+		imageRequest: `{"model":"conformance-mock","input":[{"role":"user","content":[{"type":"input_text","text":"Describe this image."},{"type":"input_image","image_url":"data:image/png;base64,` + mockImageData + `"}]}]}`,
+		text: func(resp gjson.Result) error {
+			message := firstWith(resp.Get("output"), "type", "message")
+			if !message.Exists() {
+				return errors.New("no message output item")
+			}
+			return all(
+				expectEqual(resp, "object", "response"),
+				expectEqual(resp, "status", "completed"),
+				expectEqual(message, "content.0.type", "output_text"),
+				expectEqual(message, "content.0.text", mockText),
+				expectPresent(resp, "usage.total_tokens"),
+			)
+		},
+		textStream: func(events []gjson.Result) error {
+			if err := expectEventOrder(events, "type", "response.created", "response.completed"); err != nil {
+				return err
+			}
+			var content strings.Builder
+			for _, event := range events {
+				if event.Get("type").String() == "response.output_text.delta" {
+					content.WriteString(event.Get("delta").String())
+				}
+			}
+			return expectText(content.String())
+		},
+		tool: func(resp gjson.Result) error {
+			call := firstWith(resp.Get("output"), "type", "function_call")
+			if !call.Exists() {
+				return errors.New("no function_call output item")
+			}
+			return all(
+				expectEqual(call, "name", mockToolName),
+				expectPresent(call, "call_id"),
+				expectJSONString(call, "arguments"),
+			)
+		},
+		toolStream: func(events []gjson.Result) error {
+			if err := expectEventOrder(events, "type", "response.created", "response.completed"); err != nil {
+				return err
+			}
+			name := ""
+			var args strings.Builder
+			for _, event := range events {
+				if event.Get("item.type").String() == "function_call" {
+					name = event.Get("item.name").String()
+				}
+				if event.Get("type").String() == "response.function_call_arguments.delta" {
+					args.WriteString(event.Get("delta").String())
+				}
+			}
+			return all(expectName(name), expectArguments(args.String()))
+		},
+	},
+}
+
+// all returns the first non-nil error.
+func all(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expectEqual(v gjson.Result, path, want string) error {
+	if got := v.Get(path).String(); got != want {
+		return fmt.Errorf("%s is %q, want %q", path, got, want)
+	}
+	return nil
+}
+
+func expectPresent(v gjson.Result, path string) error {
+	if !v.Get(path).Exists() {
+		return fmt.Errorf("%s is missing", path)
+	}
+	return nil
+}
+
+func expectObject(v gjson.Result, path string) error {
+	if !v.Get(path).IsObject() {
+		return fmt.Errorf("%s is not an object", path)
+	}
+	return nil
+}
+
+func expectJSONString(v gjson.Result, path string) error {
+	field := v.Get(path)
+	if field.Type != gjson.String || !gjson.Valid(field.String()) {
+		return fmt.Errorf("%s is not a JSON-encoded string", path)
+	}
+	return nil
+}
+
+func expectText(got string) error {
+	if got != mockText {
+		return fmt.Errorf("streamed text is %q, want %q", got, mockText)
+	}
+	return nil
+}
+
+func expectName(got string) error {
+	if got != mockToolName {
+		return fmt.Errorf("streamed tool name is %q, want %q", got, mockToolName)
+	}
+	return nil
+}
+
+func expectArguments(got string) error {
+	if gjson.Get(got, "city").String() != "Paris" {
+		return fmt.Errorf("streamed tool arguments are %q, want {\"city\":\"Paris\"}", got)
+	}
+	return nil
+}
+
+func expectFinish(got, want string) error {
+	if got != want {
+		return fmt.Errorf("finish reason is %q, want %q", got, want)
+	}
+	return nil
+}
+
+// expectEventOrder checks that the stream starts with first and ends with last.
+func expectEventOrder(events []gjson.Result, field, first, last string) error {
+	if got := events[0].Get(field).String(); got != first {
+		return fmt.Errorf("first event is %q, want %q", got, first)
+	}
+	if got := events[len(events)-1].Get(field).String(); got != last {
+		return fmt.Errorf("last event is %q, want %q", got, last)
+	}
+	return nil
+}
+
+func firstWith(list gjson.Result, field, value string) gjson.Result {
+	var found gjson.Result
+	list.ForEach(func(_, item gjson.Result) bool {
+		if item.Get(field).String() == value {
+			found = item
+			return false
+		}
+		return true
+	})
+	return found
+}
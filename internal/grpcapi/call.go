@@ -0,0 +1,307 @@
+package grpcapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// storedMetadata are call metadata keys that carry credentials and are never stored.
+var storedMetadata = map[string]struct{}{
+	"Authorization":  {},
+	"X-Api-Key":      {},
+	"X-Goog-Api-Key": {},
+	"Cookie":         {},
+}
+
+// call is one gRPC call seen as the HTTP request the access providers, rate limits and
+// executors expect: its metadata become the request headers and the Gin context collects the
+// usage executors report, as it does for the HTTP API.
+type call struct {
+	start  time.Time
+	gin    *gin.Context
+	writer *callWriter
+	apiKey string
+
+	// The messages kept for the request store while bodies are enabled.
+	mu                sync.Mutex
+	withBodies        bool
+	maxBody           int
+	request           bytes.Buffer
+	response          bytes.Buffer
+	requestTruncated  bool
+	responseTruncated bool
+}
+
+func newCall(ctx context.Context, fullMethod string) *call {
+	header := make(http.Header)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	req := (&http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: fullMethod},
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     header,
+		Body:       http.NoBody,
+		Host:       strings.Join(md.Get(":authority"), ""),
+	}).WithContext(ctx)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+		if info, isTLS := p.AuthInfo.(credentials.TLSInfo); isTLS {
+			req.TLS = &info.State
+		}
+	}
+	writer := &callWriter{header: make(http.Header), status: http.StatusOK}
+	cl := &call{start: time.Now(), gin: &gin.Context{Request: req, Writer: writer}, writer: writer}
+	cl.withBodies, cl.maxBody = logging.GetRequestStore().Bodies()
+	return cl
+}
+
+// clientIP returns the address of the peer without its port.
+func (cl *call) clientIP() string {
+	ip, _, err := net.SplitHostPort(cl.gin.Request.RemoteAddr)
+	if err != nil {
+		return cl.gin.Request.RemoteAddr
+	}
+	return ip
+}
+
+// admit authenticates the call and applies the rate limits, then returns the context the call
+// runs with.
+func (s *Server) admit(ctx context.Context, cl *call) (context.Context, error) {
+	limiter := ratelimit.GetLimiter()
+	now := time.Now()
+	if limiter.Enabled() {
+		if decision := limiter.AllowIP(cl.clientIP(), now); !decision.Allowed {
+			return nil, status.Errorf(codes.ResourceExhausted, "%s rate limit exceeded; retry after %s", decision.Tightest.Scope, decision.RetryAfter.Round(time.Millisecond))
+		}
+	}
+	if s.access != nil {
+		result, err := s.access.Authenticate(ctx, cl.gin.Request)
+		switch {
+		case errors.Is(err, sdkaccess.ErrNoCredentials):
+			return nil, status.Error(codes.Unauthenticated, "missing API key")
+		case errors.Is(err, sdkaccess.ErrInvalidCredential):
+			return nil, status.Error(codes.Unauthenticated, "invalid API key")
+		case err != nil:
+			log.Errorf("gRPC authentication error: %v", err)
+			return nil, status.Error(codes.Internal, "authentication service error")
+		}
+		if result != nil {
+			cl.apiKey = result.Principal
+			cl.gin.Set("apiKey", result.Principal)
+			cl.gin.Set("accessProvider", result.Provider)
+			if len(result.Metadata) > 0 {
+				cl.gin.Set(requestctx.AccessMetadataKey, result.Metadata)
+			}
+		}
+	}
+	if limiter.Enabled() {
+		if decision := limiter.Allow(cl.apiKey, "", now); !decision.Allowed {
+			return nil, status.Errorf(codes.ResourceExhausted, "%s rate limit exceeded; retry after %s", decision.Tightest.Scope, decision.RetryAfter.Round(time.Millisecond))
+		}
+	}
+
+	requestctx.GuardResponse(cl.gin)
+	ctx = requestctx.WithGin(ctx, cl.gin)
+	ctx = requestctx.WithMetadata(ctx, requestctx.Metadata{
+		HandlerType:   s.handler.HandlerType(),
+		Tenant:        cl.apiKey,
+		CorrelationID: idgen.NewID(ctx, "grpc_"),
+		Debug:         log.IsLevelEnabled(log.DebugLevel),
+	})
+	cl.gin.Request = cl.gin.Request.WithContext(ctx)
+	return ctx, nil
+}
+
+// interceptUnary admits unary calls and records them once they return.
+func (s *Server) interceptUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	cl := newCall(ctx, info.FullMethod)
+	ctx, err := s.admit(ctx, cl)
+	if err != nil {
+		return nil, err
+	}
+	cl.recordRequest(req)
+	resp, err := handler(ctx, req)
+	if err == nil {
+		cl.recordResponse(resp)
+	}
+	cl.finish(err)
+	return resp, err
+}
+
+// interceptStream admits streaming calls and records them once they return.
+func (s *Server) interceptStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	cl := newCall(stream.Context(), info.FullMethod)
+	ctx, err := s.admit(stream.Context(), cl)
+	if err != nil {
+		return err
+	}
+	err = handler(srv, &callStream{ServerStream: stream, ctx: ctx, call: cl})
+	cl.finish(err)
+	return err
+}
+
+// callStream runs a streaming call with the context of its call and records its messages.
+type callStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	call *call
+}
+
+func (s *callStream) Context() context.Context { return s.ctx }
+
+func (s *callStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.call.recordRequest(m)
+	return nil
+}
+
+func (s *callStream) SendMsg(m any) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.call.recordResponse(m)
+	return nil
+}
+
+func (cl *call) recordRequest(m any) {
+	cl.record(&cl.request, &cl.requestTruncated, m)
+}
+
+func (cl *call) recordResponse(m any) {
+	cl.record(&cl.response, &cl.responseTruncated, m)
+}
+
+// record appends m to buf as a line of JSON while bodies are stored, keeping the first maxBody
+// bytes.
+func (cl *call) record(buf *bytes.Buffer, truncated *bool, m any) {
+	msg, ok := m.(proto.Message)
+	if !cl.withBodies || !ok {
+		return
+	}
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if buf.Len() > 0 {
+		data = append([]byte{'\n'}, data...)
+	}
+	if room := cl.maxBody - buf.Len(); room < len(data) {
+		data = data[:max(room, 0)]
+		*truncated = true
+	}
+	buf.Write(data)
+}
+
+// finish writes the audit record of the call, which returned err, and saves it in the request
+// store.
+func (cl *call) finish(err error) {
+	cl.writer.status = httpFromCode(status.Code(err))
+	record := logging.AuditRecord{
+		Timestamp: cl.start.UTC(),
+		Method:    cl.gin.Request.Method,
+		Path:      cl.gin.Request.URL.Path,
+		ClientIP:  cl.clientIP(),
+		APIKey:    cl.apiKey,
+		Status:    cl.writer.status,
+		LatencyMs: time.Since(cl.start).Milliseconds(),
+	}
+	record.AddRequestUsage(cl.gin)
+	if logger := logging.GetAuditLogger(); logger.Enabled() {
+		logger.Log(record)
+	}
+
+	store := logging.GetRequestStore()
+	if !store.Enabled() {
+		return
+	}
+	rec := &logging.StoredRequest{AuditRecord: record}
+	if cl.withBodies {
+		rec.RequestHeaders = make(map[string][]string, len(cl.gin.Request.Header))
+		for key, values := range cl.gin.Request.Header {
+			if _, secret := storedMetadata[key]; !secret {
+				rec.RequestHeaders[key] = values
+			}
+		}
+		cl.mu.Lock()
+		rec.RequestBody, rec.RequestTruncated = cl.request.String(), cl.requestTruncated
+		rec.ResponseBody, rec.ResponseTruncated = cl.response.String(), cl.responseTruncated
+		cl.mu.Unlock()
+	}
+	store.Save(rec)
+}
+
+// callWriter is the response writer of the Gin context of a gRPC call. It keeps the headers
+// and status handlers and executors set; the call's messages are sent by gRPC instead.
+type callWriter struct {
+	header http.Header
+	status int
+	size   int
+}
+
+var _ gin.ResponseWriter = (*callWriter)(nil)
+
+func (w *callWriter) Header() http.Header { return w.header }
+
+func (w *callWriter) Write(data []byte) (int, error) {
+	w.size += len(data)
+	return len(data), nil
+}
+
+func (w *callWriter) WriteString(s string) (int, error) { return w.Write([]byte(s)) }
+
+func (w *callWriter) WriteHeader(code int) { w.status = code }
+
+func (w *callWriter) WriteHeaderNow() {}
+
+func (w *callWriter) Status() int { return w.status }
+
+func (w *callWriter) Size() int { return w.size }
+
+func (w *callWriter) Written() bool { return w.size > 0 }
+
+func (w *callWriter) Flush() {}
+
+func (w *callWriter) Pusher() http.Pusher { return nil }
+
+func (w *callWriter) CloseNotify() <-chan bool { return make(chan bool) }
+
+func (w *callWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("grpcapi: a gRPC call cannot be hijacked")
+}
@@ -0,0 +1,382 @@
+// Package grpcapi serves the cliproxy.v1.Proxy gRPC service defined in proxy.proto, using the
+// stubs generated into proxy.pb.go and proxy_grpc.pb.go. Calls are mapped onto the OpenAI chat
+// completions handler, so they share routing, aliasing, API key rules, account selection and
+// executors with the HTTP API.
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// maxMessageSize bounds the request messages the server accepts.
+const maxMessageSize = 16 << 20
+
+// Server serves the Proxy service. Every call passes the interceptors in call.go, which
+// authenticate it, apply the rate limits and record it in the audit log and request store.
+type Server struct {
+	UnimplementedProxyServer
+
+	handler *openai.OpenAIAPIHandler
+	access  *sdkaccess.Manager
+
+	mu     sync.Mutex
+	server *grpc.Server
+}
+
+// NewServer returns a server executing calls with handler and authenticating them with
+// access. A nil access manager accepts every call.
+func NewServer(handler *openai.OpenAIAPIHandler, access *sdkaccess.Manager) *Server {
+	return &Server{handler: handler, access: access}
+}
+
+// newGRPCServer returns a gRPC server with the Proxy service registered, serving over TLS
+// when tlsConfig is not nil.
+func (s *Server) newGRPCServer(tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.UnaryInterceptor(s.interceptUnary),
+		grpc.StreamInterceptor(s.interceptStream),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(opts...)
+	RegisterProxyServer(server, s)
+	return server
+}
+
+// Start listens on port and serves calls in the background until Stop. Calls are served
+// over TLS when tlsConfig is not nil and over cleartext HTTP/2 otherwise.
+func (s *Server) Start(port int, tlsConfig *tls.Config) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC: %w", err)
+	}
+	server := s.newGRPCServer(tlsConfig)
+	s.mu.Lock()
+	s.server = server
+	s.mu.Unlock()
+	log.Infof("gRPC API listening on %s", listener.Addr())
+	go func() {
+		if errServe := server.Serve(listener); errServe != nil && !errors.Is(errServe, grpc.ErrServerStopped) {
+			log.Errorf("gRPC server stopped: %v", errServe)
+		}
+	}()
+	return nil
+}
+
+// Stop shuts the server down, waiting for active calls until ctx ends and cancelling the
+// remaining ones then.
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	server := s.server
+	s.server = nil
+	s.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		return ctx.Err()
+	}
+}
+
+// Chat runs a chat completion and returns the whole response.
+func (s *Server) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	apiKey := requestctx.Tenant(ctx)
+	body, err := chatBody(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	resp, errMsg := s.handler.ExecuteWithAuthManager(ctx, s.handler.HandlerType(), gjson.GetBytes(body, "model").String(), body, "")
+	if errMsg != nil {
+		if err = contextError(ctx); err != nil {
+			return nil, err
+		}
+		return nil, status.Errorf(codeFromHTTP(errMsg.StatusCode), "%v", errMsg.Error)
+	}
+	out := &ChatResponse{
+		Id:                 gjson.GetBytes(resp, "id").String(),
+		Model:              gjson.GetBytes(resp, "model").String(),
+		Content:            gjson.GetBytes(resp, "choices.0.message.content").String(),
+		Reasoning:          gjson.GetBytes(resp, "choices.0.message.reasoning_content").String(),
+		FinishReason:       gjson.GetBytes(resp, "choices.0.finish_reason").String(),
+		Usage:              parseUsage(gjson.GetBytes(resp, "usage")),
+		OpenaiResponseJson: string(resp),
+	}
+	chargeQuota(apiKey, body, out.Usage, out.Content)
+	return out, nil
+}
+
+// StreamChat runs a chat completion and streams its deltas.
+func (s *Server) StreamChat(req *ChatRequest, stream grpc.ServerStreamingServer[ChatChunk]) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	apiKey := requestctx.Tenant(ctx)
+	body, err := chatBody(ctx, req, true)
+	if err != nil {
+		return err
+	}
+	dataChan, errChan := s.handler.ExecuteStreamWithAuthManager(ctx, s.handler.HandlerType(), gjson.GetBytes(body, "model").String(), body, "")
+
+	var content strings.Builder
+	var reported *Usage
+	defer func() { chargeQuota(apiKey, body, reported, content.String()) }()
+	for {
+		select {
+		case <-ctx.Done():
+			return contextError(ctx)
+		case chunk, ok := <-dataChan:
+			if !ok {
+				return nil
+			}
+			chunk = []byte(strings.TrimSpace(strings.TrimPrefix(string(chunk), "data:")))
+			if len(chunk) == 0 || string(chunk) == "[DONE]" {
+				continue
+			}
+			out := &ChatChunk{
+				Id:              gjson.GetBytes(chunk, "id").String(),
+				Model:           gjson.GetBytes(chunk, "model").String(),
+				Content:         gjson.GetBytes(chunk, "choices.0.delta.content").String(),
+				Reasoning:       gjson.GetBytes(chunk, "choices.0.delta.reasoning_content").String(),
+				FinishReason:    gjson.GetBytes(chunk, "choices.0.finish_reason").String(),
+				Usage:           parseUsage(gjson.GetBytes(chunk, "usage")),
+				OpenaiChunkJson: string(chunk),
+			}
+			content.WriteString(out.Content)
+			if out.Usage != nil {
+				reported = out.Usage
+			}
+			if err = stream.Send(out); err != nil {
+				return err
+			}
+		case errMsg, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			if errMsg == nil {
+				return nil
+			}
+			return status.Errorf(codeFromHTTP(errMsg.StatusCode), "%v", errMsg.Error)
+		}
+	}
+}
+
+// ListModels lists the models currently served.
+func (s *Server) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	out := &ListModelsResponse{}
+	for _, model := range s.handler.Models() {
+		id, _ := model["id"].(string)
+		if id == "" {
+			continue
+		}
+		entry := &Model{Id: id}
+		entry.OwnedBy, _ = model["owned_by"].(string)
+		switch created := model["created"].(type) {
+		case int64:
+			entry.Created = created
+		case int:
+			entry.Created = int64(created)
+		}
+		out.Models = append(out.Models, entry)
+	}
+	return out, nil
+}
+
+// GetUsage reports the statistics and quota of the calling key only.
+func (s *Server) GetUsage(ctx context.Context, _ *GetUsageRequest) (*GetUsageResponse, error) {
+	apiKey := requestctx.Tenant(ctx)
+	out := &GetUsageResponse{}
+	if api, ok := usage.GetRequestStatistics().Snapshot().APIs[apiKey]; ok {
+		out.TotalRequests = api.TotalRequests
+		out.TotalTokens = api.TotalTokens
+		for name, model := range api.Models {
+			out.Models = append(out.Models, &ModelUsage{Model: name, Requests: model.TotalRequests, Tokens: model.TotalTokens})
+		}
+		sort.Slice(out.Models, func(i, j int) bool { return out.Models[i].Model < out.Models[j].Model })
+	}
+	if quotas := usage.GetQuotaManager(); quotas.Enabled() && apiKey != "" {
+		st := quotas.Status(apiKey, requestctx.AccessMetadata(ctx), time.Now())
+		out.DailyQuotaUsed, out.DailyQuotaLimit = st.DailyUsed, st.DailyLimit
+		out.MonthlyQuotaUsed, out.MonthlyQuotaLimit = st.MonthlyUsed, st.MonthlyLimit
+	}
+	return out, nil
+}
+
+// chatBody builds the OpenAI chat completions request of req, applying prompt moderation and
+// checking the token quota of the calling key.
+func chatBody(ctx context.Context, req *ChatRequest, stream bool) ([]byte, error) {
+	body := []byte(`{}`)
+	if raw := strings.TrimSpace(req.GetOpenaiRequestJson()); raw != "" {
+		if !gjson.Valid(raw) || !gjson.Parse(raw).IsObject() {
+			return nil, status.Error(codes.InvalidArgument, "openai_request_json is not a JSON object")
+		}
+		body = []byte(raw)
+	}
+	if req.GetModel() != "" {
+		body, _ = sjson.SetBytes(body, "model", req.GetModel())
+	}
+	if len(req.GetMessages()) > 0 {
+		messages := make([]map[string]string, 0, len(req.GetMessages()))
+		for _, msg := range req.GetMessages() {
+			messages = append(messages, map[string]string{"role": msg.GetRole(), "content": msg.GetContent()})
+		}
+		body, _ = sjson.SetBytes(body, "messages", messages)
+	}
+	if req.Temperature != nil {
+		body, _ = sjson.SetBytes(body, "temperature", req.GetTemperature())
+	}
+	if req.TopP != nil {
+		body, _ = sjson.SetBytes(body, "top_p", req.GetTopP())
+	}
+	if req.MaxTokens != nil {
+		body, _ = sjson.SetBytes(body, "max_tokens", req.GetMaxTokens())
+	}
+	if len(req.GetStop()) > 0 {
+		body, _ = sjson.SetBytes(body, "stop", req.GetStop())
+	}
+	body, _ = sjson.SetBytes(body, "stream", stream)
+	if stream && !gjson.GetBytes(body, "stream_options.include_usage").Exists() {
+		body, _ = sjson.SetBytes(body, "stream_options.include_usage", true)
+	}
+	if gjson.GetBytes(body, "model").String() == "" {
+		return nil, status.Error(codes.InvalidArgument, "model is required")
+	}
+	if len(gjson.GetBytes(body, "messages").Array()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "messages are required")
+	}
+
+	if filter := moderation.GetFilter(); filter.Active(moderation.StagePrompt) {
+		filtered, blockedBy := filter.Apply(moderation.StagePrompt, body)
+		if blockedBy != "" {
+			return nil, status.Errorf(codes.InvalidArgument, "request blocked by moderation rule %s", blockedBy)
+		}
+		body = filtered
+	}
+	if apiKey, quotas := requestctx.Tenant(ctx), usage.GetQuotaManager(); quotas.Enabled() && apiKey != "" {
+		if st := quotas.Status(apiKey, requestctx.AccessMetadata(ctx), time.Now()); st.Exceeded != "" {
+			return nil, status.Errorf(codes.ResourceExhausted, "%s token quota exceeded for this API key", st.Exceeded)
+		}
+	}
+	return body, nil
+}
+
+// chargeQuota adds the tokens of a completed call to the quota of apiKey, estimating them when
+// the upstream reported no usage.
+func chargeQuota(apiKey string, body []byte, reported *Usage, completion string) {
+	quotas := usage.GetQuotaManager()
+	if !quotas.Enabled() || apiKey == "" {
+		return
+	}
+	var prompt, completionTokens int64
+	if reported != nil {
+		prompt, completionTokens = reported.PromptTokens, max(reported.TotalTokens-reported.PromptTokens, reported.CompletionTokens)
+	}
+	if prompt+completionTokens == 0 {
+		prompt, completionTokens = usage.EstimatePromptTokens(body), usage.EstimateTextTokens(completion)
+	}
+	quotas.Add(apiKey, prompt, completionTokens, time.Now())
+}
+
+func parseUsage(u gjson.Result) *Usage {
+	if !u.IsObject() {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     u.Get("prompt_tokens").Int(),
+		CompletionTokens: u.Get("completion_tokens").Int(),
+		TotalTokens:      u.Get("total_tokens").Int(),
+	}
+}
+
+// codeFromHTTP maps an HTTP status returned by the execution layer to a gRPC code.
+func codeFromHTTP(httpStatus int) codes.Code {
+	switch {
+	case httpStatus == http.StatusBadRequest:
+		return codes.InvalidArgument
+	case httpStatus == http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case httpStatus == http.StatusForbidden:
+		return codes.PermissionDenied
+	case httpStatus == http.StatusNotFound:
+		return codes.NotFound
+	case httpStatus == http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case httpStatus == http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case httpStatus == http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case httpStatus >= http.StatusInternalServerError:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
+
+// httpFromCode maps the gRPC code of a finished call to the HTTP status recorded for it in the
+// audit log and request store.
+func httpFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// contextError returns the status of a call whose context ended, or nil.
+func contextError(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return status.FromContextError(ctx.Err()).Err()
+}
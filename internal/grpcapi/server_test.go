@@ -0,0 +1,196 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	testProvider = "grpcapi-test"
+	testModel    = "grpcapi-test-model"
+	testKey      = "test-key"
+)
+
+// testExecutor answers every request with a canned OpenAI completion naming the tenant.
+type testExecutor struct{}
+
+func (testExecutor) Identifier() string { return testProvider }
+
+func (testExecutor) Execute(ctx context.Context, _ *coreauth.Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	payload := `{"id":"chatcmpl-1","object":"chat.completion","model":"` + req.Model + `","choices":[{"index":0,"message":{"role":"assistant","content":"hello ` + requestctx.Tenant(ctx) + `"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`
+	return cliproxyexecutor.Response{Payload: []byte(payload)}, nil
+}
+
+func (testExecutor) ExecuteStream(_ context.Context, _ *coreauth.Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	out := make(chan cliproxyexecutor.StreamChunk, 3)
+	for _, content := range []string{"hel", "lo"} {
+		out <- cliproxyexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-2","object":"chat.completion.chunk","model":"` + req.Model + `","choices":[{"index":0,"delta":{"content":"` + content + `"}}]}`)}
+	}
+	out <- cliproxyexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-2","object":"chat.completion.chunk","model":"` + req.Model + `","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)}
+	close(out)
+	return out, nil
+}
+
+func (testExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (testExecutor) CountTokens(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not supported")
+}
+
+// testAccess accepts the bearer token testKey.
+type testAccess struct{}
+
+func (testAccess) Identifier() string { return "test" }
+
+func (testAccess) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	switch {
+	case !found:
+		return nil, sdkaccess.ErrNoCredentials
+	case token != testKey:
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	return &sdkaccess.Result{Provider: "test", Principal: token}, nil
+}
+
+// newTestClient serves a Server over an in-memory listener and returns a client connected to it.
+func newTestClient(t *testing.T) ProxyClient {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(testExecutor{})
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: "grpcapi-test-auth", Provider: testProvider, Status: coreauth.StatusActive}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient("grpcapi-test-auth", testProvider, []*registry.ModelInfo{{ID: testModel, OwnedBy: "test", Created: 1700000000}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("grpcapi-test-auth") })
+
+	access := sdkaccess.NewManager()
+	access.SetProviders([]sdkaccess.Provider{testAccess{}})
+	server := NewServer(openai.NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&config.SDKConfig{}, manager)), access)
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := server.newGRPCServer(nil)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewProxyClient(conn)
+}
+
+func authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+testKey)
+}
+
+func TestChatRoundTrip(t *testing.T) {
+	client := newTestClient(t)
+	resp, err := client.Chat(authorized(), &ChatRequest{Model: testModel, Messages: []*Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.GetContent() != "hello "+testKey {
+		t.Fatalf("Content = %q, want %q", resp.GetContent(), "hello "+testKey)
+	}
+	if resp.GetFinishReason() != "stop" {
+		t.Fatalf("FinishReason = %q, want stop", resp.GetFinishReason())
+	}
+	if resp.GetUsage().GetTotalTokens() != 5 {
+		t.Fatalf("TotalTokens = %d, want 5", resp.GetUsage().GetTotalTokens())
+	}
+}
+
+func TestStreamChatRoundTrip(t *testing.T) {
+	client := newTestClient(t)
+	stream, err := client.StreamChat(authorized(), &ChatRequest{Model: testModel, Messages: []*Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	var content strings.Builder
+	var finish string
+	for {
+		chunk, errRecv := stream.Recv()
+		if errors.Is(errRecv, io.EOF) {
+			break
+		}
+		if errRecv != nil {
+			t.Fatalf("Recv: %v", errRecv)
+		}
+		content.WriteString(chunk.GetContent())
+		if chunk.GetFinishReason() != "" {
+			finish = chunk.GetFinishReason()
+		}
+	}
+	if content.String() != "hello" {
+		t.Fatalf("content = %q, want hello", content.String())
+	}
+	if finish != "stop" {
+		t.Fatalf("finish reason = %q, want stop", finish)
+	}
+}
+
+func TestListModelsRoundTrip(t *testing.T) {
+	client := newTestClient(t)
+	resp, err := client.ListModels(authorized(), &ListModelsRequest{})
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	for _, model := range resp.GetModels() {
+		if model.GetId() == testModel {
+			return
+		}
+	}
+	t.Fatalf("ListModels = %v, want %s listed", resp.GetModels(), testModel)
+}
+
+func TestCallsRequireAPIKey(t *testing.T) {
+	client := newTestClient(t)
+	req := &ChatRequest{Model: testModel, Messages: []*Message{{Role: "user", Content: "hi"}}}
+
+	_, err := client.Chat(context.Background(), req)
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Fatalf("Chat without key: code = %v, want %v", code, codes.Unauthenticated)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	stream, err := client.StreamChat(ctx, req)
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Fatalf("StreamChat with wrong key: code = %v, want %v", code, codes.Unauthenticated)
+	}
+}
+
+func TestChatRejectsMissingModel(t *testing.T) {
+	client := newTestClient(t)
+	_, err := client.Chat(authorized(), &ChatRequest{Messages: []*Message{{Role: "user", Content: "hi"}}})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Fatalf("code = %v, want %v", code, codes.InvalidArgument)
+	}
+}
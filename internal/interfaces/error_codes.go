@@ -0,0 +1,91 @@
+package interfaces
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorCodeHeader carries the machine-readable error code on every error response.
+const ErrorCodeHeader = "X-CLIProxy-Error-Code"
+
+// Error codes are stable identifiers of why a request failed. They are sent in the
+// X-CLIProxy-Error-Code header and in the error body so that clients can decide whether
+// and how to retry without parsing messages.
+const (
+	// ErrorCodeUsageLimit means the Gemini Web account reached its usage limit for the model.
+	ErrorCodeUsageLimit = "GEMINI_WEB_USAGE_LIMIT"
+	// ErrorCodeTempBlocked means Gemini temporarily blocked the account or its IP.
+	ErrorCodeTempBlocked = "TEMP_BLOCKED"
+	// ErrorCodeCookieExpired means the account cookies were rejected and must be renewed.
+	ErrorCodeCookieExpired = "COOKIE_EXPIRED"
+	// ErrorCodeModelInvalid means the upstream does not serve the requested model.
+	ErrorCodeModelInvalid = "MODEL_INVALID"
+	// ErrorCodeInvalidRequest means the request itself was rejected; retrying will not help.
+	ErrorCodeInvalidRequest = "INVALID_REQUEST"
+	// ErrorCodeUpstreamTimeout means the upstream did not answer in time.
+	ErrorCodeUpstreamTimeout = "UPSTREAM_TIMEOUT"
+	// ErrorCodeAccountBusy means the account queue was full; retry after Retry-After.
+	ErrorCodeAccountBusy = "ACCOUNT_BUSY"
+	// ErrorCodeNoReusableContext means X-CLIProxy-Context: strict was sent but no account holds
+	// an upstream conversation continuing the history.
+	ErrorCodeNoReusableContext = "NO_REUSABLE_CONTEXT"
+	// ErrorCodeNoAvailableAccount means no account can serve the model right now.
+	ErrorCodeNoAvailableAccount = "NO_AVAILABLE_ACCOUNT"
+	// ErrorCodeAllCandidatesFailed means several accounts were tried and failed differently.
+	ErrorCodeAllCandidatesFailed = "ALL_CANDIDATES_FAILED"
+	// ErrorCodeRateLimited means the upstream or the proxy rate limited the request.
+	ErrorCodeRateLimited = "RATE_LIMITED"
+	// ErrorCodeUnauthorized means the upstream rejected the account credentials.
+	ErrorCodeUnauthorized = "UNAUTHORIZED"
+	// ErrorCodeForbidden means the upstream refused the request for this account.
+	ErrorCodeForbidden = "FORBIDDEN"
+	// ErrorCodeNotFound means the upstream did not find the requested resource.
+	ErrorCodeNotFound = "NOT_FOUND"
+	// ErrorCodeUnavailable means the upstream is temporarily unavailable.
+	ErrorCodeUnavailable = "UPSTREAM_UNAVAILABLE"
+	// ErrorCodeUpstreamError means the upstream failed with an unexpected error.
+	ErrorCodeUpstreamError = "UPSTREAM_ERROR"
+	// ErrorCodeInternal means the proxy itself failed.
+	ErrorCodeInternal = "INTERNAL_ERROR"
+)
+
+// ErrorCoder is implemented by errors that know their error code.
+type ErrorCoder interface {
+	ErrorCode() string
+}
+
+// ErrorCodeOf returns the code of the first error in err's chain that has one, and otherwise
+// the generic code for status.
+func ErrorCodeOf(err error, status int) string {
+	var coder ErrorCoder
+	if errors.As(err, &coder) && coder != nil {
+		if code := coder.ErrorCode(); code != "" {
+			return code
+		}
+	}
+	return ErrorCodeForStatus(status)
+}
+
+// ErrorCodeForStatus returns the generic error code for an HTTP status.
+func ErrorCodeForStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case status == http.StatusForbidden:
+		return ErrorCodeForbidden
+	case status == http.StatusNotFound:
+		return ErrorCodeNotFound
+	case status == http.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	case status == http.StatusGatewayTimeout || status == http.StatusRequestTimeout:
+		return ErrorCodeUpstreamTimeout
+	case status == http.StatusServiceUnavailable:
+		return ErrorCodeUnavailable
+	case status == http.StatusBadGateway:
+		return ErrorCodeUpstreamError
+	case status >= 400 && status < 500:
+		return ErrorCodeInvalidRequest
+	default:
+		return ErrorCodeInternal
+	}
+}
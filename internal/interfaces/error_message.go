@@ -17,4 +17,7 @@ type ErrorMessage struct {
 
 	// Addon contains additional headers to be added to the response.
 	Addon http.Header
+
+	// Code is the machine-readable error code; when empty it is derived from Error and StatusCode.
+	Code string
 }
@@ -0,0 +1,312 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// RequestUsageKey is the Gin context key under which executors accumulate RequestUsage entries.
+	RequestUsageKey = "REQUEST_USAGE"
+	// SecretsDetectedKey is the Gin context key under which executors list the kinds of
+	// credentials ([]string) found in the request.
+	SecretsDetectedKey = "SECRETS_DETECTED"
+	// UpstreamProxyKey is the Gin context key under which the pool proxy (string, credentials
+	// redacted) that served the last upstream request is recorded.
+	UpstreamProxyKey = "UPSTREAM_PROXY"
+
+	auditQueueSize     = 1024
+	auditBatchSize     = 100
+	auditFlushInterval = 2 * time.Second
+)
+
+// RequestUsage is the per-attempt usage summary an executor attaches to the request context.
+type RequestUsage struct {
+	Provider        string
+	Model           string
+	AuthID          string
+	AuthLabel       string
+	Outcome         string
+	InputTokens     int64
+	OutputTokens    int64
+	ReasoningTokens int64
+	CachedTokens    int64
+	TotalTokens     int64
+}
+
+// AuditRecord is one line of the audit log.
+type AuditRecord struct {
+	Timestamp       time.Time `json:"timestamp"`
+	Method          string    `json:"method"`
+	Path            string    `json:"path"`
+	ClientIP        string    `json:"client_ip,omitempty"`
+	APIKey          string    `json:"api_key,omitempty"`
+	Model           string    `json:"model,omitempty"`
+	Provider        string    `json:"provider,omitempty"`
+	Account         string    `json:"account,omitempty"`
+	Proxy           string    `json:"proxy,omitempty"`
+	Attempts        int       `json:"attempts,omitempty"`
+	Status          int       `json:"status"`
+	Outcome         string    `json:"outcome,omitempty"`
+	InputTokens     int64     `json:"input_tokens"`
+	OutputTokens    int64     `json:"output_tokens"`
+	ReasoningTokens int64     `json:"reasoning_tokens"`
+	CachedTokens    int64     `json:"cached_tokens"`
+	TotalTokens     int64     `json:"total_tokens"`
+	LatencyMs       int64     `json:"latency_ms"`
+	// SecretsDetected lists the kinds of credentials found in the prompt.
+	SecretsDetected []string `json:"secrets_detected,omitempty"`
+}
+
+// AddRequestUsage fills in the attempts, token counts, serving model, provider and account,
+// upstream proxy and detected secrets of r from the entries executors attached to c.
+func (r *AuditRecord) AddRequestUsage(c *gin.Context) {
+	if v, exists := c.Get(RequestUsageKey); exists {
+		if entries, ok := v.([]RequestUsage); ok && len(entries) > 0 {
+			r.Attempts = len(entries)
+			for _, entry := range entries {
+				r.InputTokens += entry.InputTokens
+				r.OutputTokens += entry.OutputTokens
+				r.ReasoningTokens += entry.ReasoningTokens
+				r.CachedTokens += entry.CachedTokens
+				r.TotalTokens += entry.TotalTokens
+			}
+			last := entries[len(entries)-1]
+			r.Model = last.Model
+			r.Provider = last.Provider
+			r.Outcome = last.Outcome
+			r.Account = last.AuthLabel
+			if r.Account == "" {
+				r.Account = filepath.Base(last.AuthID)
+			}
+		}
+	}
+	if v, exists := c.Get(UpstreamProxyKey); exists {
+		r.Proxy, _ = v.(string)
+	}
+	if v, exists := c.Get(SecretsDetectedKey); exists {
+		r.SecretsDetected, _ = v.([]string)
+	}
+}
+
+// AuditLogger writes AuditRecords to a rotating JSONL file and/or an HTTP sink.
+// Records are queued and written by a background worker so request handling never blocks
+// on disk or network I/O; records are dropped with a warning when the queue is full.
+type AuditLogger struct {
+	mu      sync.RWMutex
+	cfg     config.AuditLogConfig
+	enabled bool
+	rules   map[string]string
+	file    *lumberjack.Logger
+	queue   chan []byte
+	client  *http.Client
+	started sync.Once
+}
+
+var defaultAuditLogger = &AuditLogger{
+	queue:  make(chan []byte, auditQueueSize),
+	client: &http.Client{Timeout: 15 * time.Second},
+}
+
+// GetAuditLogger returns the shared audit logger.
+func GetAuditLogger() *AuditLogger { return defaultAuditLogger }
+
+// Enabled reports whether audit records are currently accepted.
+func (l *AuditLogger) Enabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.enabled
+}
+
+// Configure applies audit log settings. It is safe to call on every config reload; sinks
+// are only reopened when the settings change.
+func (l *AuditLogger) Configure(cfg config.AuditLogConfig) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if reflect.DeepEqual(l.cfg, cfg) {
+		return nil
+	}
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+	l.cfg = cfg
+	l.enabled = cfg.Enabled
+	l.rules = make(map[string]string, len(cfg.Redact)+1)
+	l.rules["api_key"] = "mask"
+	for _, rule := range cfg.Redact {
+		field := strings.TrimSpace(rule.Field)
+		if field == "" {
+			continue
+		}
+		l.rules[field] = strings.ToLower(strings.TrimSpace(rule.Mode))
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" && strings.TrimSpace(cfg.HTTPURL) == "" {
+		dir = filepath.Join("logs", "audit")
+	}
+	if dir != "" && dir != "-" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			l.enabled = false
+			return fmt.Errorf("logging: failed to create audit log directory: %w", err)
+		}
+		maxSize := cfg.MaxSizeMB
+		if maxSize <= 0 {
+			maxSize = 50
+		}
+		l.file = &lumberjack.Logger{
+			Filename:   filepath.Join(dir, "audit.jsonl"),
+			MaxSize:    maxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAgeDays,
+			Compress:   cfg.Compress,
+		}
+	}
+	l.started.Do(func() { go l.run() })
+	return nil
+}
+
+// Log redacts and queues a record.
+func (l *AuditLogger) Log(record AuditRecord) {
+	if !l.Enabled() {
+		return
+	}
+	line, err := l.encode(record)
+	if err != nil {
+		log.Warnf("audit log: failed to encode record: %v", err)
+		return
+	}
+	select {
+	case l.queue <- line:
+	default:
+		log.Warn("audit log: queue full, dropping record")
+	}
+}
+
+func (l *AuditLogger) encode(record AuditRecord) ([]byte, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.RLock()
+	rules := l.rules
+	l.mu.RUnlock()
+	if len(rules) == 0 {
+		return append(raw, '\n'), nil
+	}
+	fields := make(map[string]any)
+	if err = json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for field, mode := range rules {
+		value, ok := fields[field]
+		if !ok {
+			continue
+		}
+		switch mode {
+		case "drop":
+			delete(fields, field)
+		case "hash":
+			sum := sha256.Sum256([]byte(fmt.Sprint(value)))
+			fields[field] = "sha256:" + hex.EncodeToString(sum[:8])
+		case "mask":
+			fields[field] = util.HideAPIKey(fmt.Sprint(value))
+		}
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func (l *AuditLogger) run() {
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+	var batch [][]byte
+	for {
+		select {
+		case line := <-l.queue:
+			batch = append(batch, line)
+			if len(batch) < auditBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		l.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+func (l *AuditLogger) flush(batch [][]byte) {
+	payload := bytes.Join(batch, nil)
+	l.mu.RLock()
+	file := l.file
+	url := strings.TrimSpace(l.cfg.HTTPURL)
+	headers := l.cfg.HTTPHeaders
+	l.mu.RUnlock()
+
+	if file != nil {
+		if _, err := file.Write(payload); err != nil {
+			log.Warnf("audit log: failed to write file sink: %v", err)
+		}
+	}
+	if url != "" {
+		if err := l.post(url, headers, payload); err != nil {
+			log.Warnf("audit log: failed to deliver %d record(s) to HTTP sink: %v", len(batch), err)
+		}
+	}
+}
+
+func (l *AuditLogger) post(url string, headers map[string]string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
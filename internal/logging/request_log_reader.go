@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// LoggedRequest is the client request recorded at the top of a request log file.
+type LoggedRequest struct {
+	URL     string
+	Method  string
+	Headers http.Header
+	Body    []byte
+}
+
+// ReadRequestLog reads the client request from a log file written by FileRequestLogger.
+//
+// Parameters:
+//   - path: The log file path
+//
+// Returns:
+//   - *LoggedRequest: The recorded request
+//   - error: An error if the file cannot be read or is not a request log
+func ReadRequestLog(path string) (*LoggedRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseRequestLog(data)
+}
+
+func parseRequestLog(data []byte) (*LoggedRequest, error) {
+	const (
+		infoMarker    = "=== REQUEST INFO ===\n"
+		headersMarker = "=== HEADERS ===\n"
+		bodyMarker    = "=== REQUEST BODY ===\n"
+	)
+	text := string(data)
+	infoStart := strings.Index(text, infoMarker)
+	headersStart := strings.Index(text, headersMarker)
+	bodyStart := strings.Index(text, bodyMarker)
+	if infoStart < 0 || headersStart < infoStart || bodyStart < headersStart {
+		return nil, fmt.Errorf("not a request log")
+	}
+
+	req := &LoggedRequest{Headers: make(http.Header)}
+	for _, line := range strings.Split(text[infoStart+len(infoMarker):headersStart], "\n") {
+		if v, ok := strings.CutPrefix(line, "URL: "); ok {
+			req.URL = v
+		} else if v, ok = strings.CutPrefix(line, "Method: "); ok {
+			req.Method = v
+		}
+	}
+	for _, line := range strings.Split(text[headersStart+len(headersMarker):bodyStart], "\n") {
+		if key, value, ok := strings.Cut(line, ": "); ok && key != "" {
+			req.Headers.Add(key, value)
+		}
+	}
+	if req.URL == "" || req.Method == "" {
+		return nil, fmt.Errorf("request log has no URL or method")
+	}
+
+	// The body is followed by a blank line and the next section, or by the end of the file.
+	body := data[bodyStart+len(bodyMarker):]
+	if end := bytes.Index(body, []byte("\n\n===")); end >= 0 {
+		body = body[:end]
+	} else {
+		body = bytes.TrimSuffix(body, []byte("\n\n"))
+	}
+	req.Body = body
+	return req, nil
+}
@@ -126,6 +126,14 @@ func (l *FileRequestLogger) IsEnabled() bool {
 	return l.enabled
 }
 
+// LogsDir returns the directory where log files are written.
+//
+// Returns:
+//   - string: The resolved logs directory
+func (l *FileRequestLogger) LogsDir() string {
+	return l.logsDir
+}
+
 // SetEnabled updates the request logging enabled state.
 // This method allows dynamic enabling/disabling of request logging.
 //
@@ -0,0 +1,377 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	requestStoreFile        = "request-store.bolt"
+	requestStoreBucket      = "requests"
+	requestStoreQueueSize   = 1024
+	requestStoreBatchSize   = 64
+	requestStoreFlushPeriod = time.Second
+
+	defaultRequestStoreMaxBodyKB = 256
+	defaultRequestStoreMaxSizeMB = 512
+)
+
+// ErrRequestNotFound is returned for unknown or evicted stored requests.
+var ErrRequestNotFound = errors.New("request not found")
+
+// StoredRequest is one request kept by the RequestStore. The client API key is masked; KeyHash
+// lets queries match it without storing it.
+type StoredRequest struct {
+	ID string `json:"id"`
+	AuditRecord
+	KeyHash string `json:"key_hash,omitempty"`
+	// Query is the raw query string, without any "key" parameter.
+	Query          string              `json:"query,omitempty"`
+	RequestHeaders map[string][]string `json:"request_headers,omitempty"`
+	RequestBody    string              `json:"request_body,omitempty"`
+	ResponseBody   string              `json:"response_body,omitempty"`
+	// RequestTruncated and ResponseTruncated report bodies cut at max-body-kb.
+	RequestTruncated  bool `json:"request_truncated,omitempty"`
+	ResponseTruncated bool `json:"response_truncated,omitempty"`
+}
+
+// RequestQuery filters stored requests. Zero fields match everything.
+type RequestQuery struct {
+	Since  time.Time
+	Until  time.Time
+	Key    string
+	Model  string
+	Path   string
+	Status string
+	// Before returns only requests older than the one with this ID, for paging.
+	Before string
+	Limit  int
+}
+
+// RequestStore keeps recent requests in a BoltDB file, keyed by time so that queries walk
+// them newest first. Records are written by a background worker; the oldest are removed once
+// the stored bytes exceed max-size-mb.
+type RequestStore struct {
+	mu      sync.RWMutex
+	cfg     config.RequestStoreConfig
+	dataDir string
+	db      *bolt.DB
+	size    int64
+	queue   chan *StoredRequest
+	seq     atomic.Uint32
+	started sync.Once
+}
+
+var defaultRequestStore = &RequestStore{queue: make(chan *StoredRequest, requestStoreQueueSize)}
+
+// GetRequestStore returns the shared request store.
+func GetRequestStore() *RequestStore { return defaultRequestStore }
+
+// Enabled reports whether requests are currently recorded.
+func (s *RequestStore) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Enabled && s.db != nil
+}
+
+// Bodies reports whether bodies are recorded and the size each is truncated to.
+func (s *RequestStore) Bodies() (bool, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	maxKB := s.cfg.MaxBodyKB
+	if maxKB <= 0 {
+		maxKB = defaultRequestStoreMaxBodyKB
+	}
+	return s.cfg.Bodies, maxKB << 10
+}
+
+// Configure applies store settings, opening <working dir>/data/request-store.bolt the first
+// time the store is enabled. It is safe to call on every config reload.
+func (s *RequestStore) Configure(cfg config.RequestStoreConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	if !cfg.Enabled || s.db != nil {
+		return nil
+	}
+	if s.dataDir == "" {
+		wd, err := os.Getwd()
+		if err != nil || wd == "" {
+			wd = "."
+		}
+		s.dataDir = filepath.Join(wd, "data")
+	}
+	if err := os.MkdirAll(s.dataDir, 0o700); err != nil {
+		return err
+	}
+	db, err := bolt.Open(filepath.Join(s.dataDir, requestStoreFile), 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return err
+	}
+	var size int64
+	if err = db.Update(func(tx *bolt.Tx) error {
+		bucket, errCreate := tx.CreateBucketIfNotExists([]byte(requestStoreBucket))
+		if errCreate != nil {
+			return errCreate
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			size += int64(len(k) + len(v))
+			return nil
+		})
+	}); err != nil {
+		_ = db.Close()
+		return err
+	}
+	s.db = db
+	s.size = size
+	s.started.Do(func() { go s.run() })
+	return nil
+}
+
+// Save assigns rec an ID and queues it for writing. Records are dropped with a warning when
+// the queue is full.
+func (s *RequestStore) Save(rec *StoredRequest) {
+	if !s.Enabled() || rec == nil {
+		return
+	}
+	var key [12]byte
+	binary.BigEndian.PutUint64(key[:8], uint64(rec.Timestamp.UnixNano()))
+	binary.BigEndian.PutUint32(key[8:], s.seq.Add(1))
+	rec.ID = hex.EncodeToString(key[:])
+	if rec.APIKey != "" {
+		rec.KeyHash = requestKeyHash(rec.APIKey)
+		rec.APIKey = util.HideAPIKey(rec.APIKey)
+	}
+	select {
+	case s.queue <- rec:
+	default:
+		log.Warn("request store: queue full, dropping record")
+	}
+}
+
+func requestKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *RequestStore) run() {
+	ticker := time.NewTicker(requestStoreFlushPeriod)
+	defer ticker.Stop()
+	var batch []*StoredRequest
+	for {
+		select {
+		case rec := <-s.queue:
+			batch = append(batch, rec)
+			if len(batch) < requestStoreBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := s.write(batch); err != nil {
+			log.Warnf("request store: failed to write %d record(s): %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+}
+
+// write stores batch and evicts the oldest records while the store is over its size cap.
+func (s *RequestStore) write(batch []*StoredRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	maxSize := int64(s.cfg.MaxSizeMB) << 20
+	if maxSize <= 0 {
+		maxSize = defaultRequestStoreMaxSizeMB << 20
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(requestStoreBucket))
+		for _, rec := range batch {
+			key, err := hex.DecodeString(rec.ID)
+			if err != nil {
+				continue
+			}
+			value, err := json.Marshal(rec)
+			if err != nil {
+				continue
+			}
+			if err = bucket.Put(key, value); err != nil {
+				return err
+			}
+			s.size += int64(len(key) + len(value))
+		}
+		// Evict down to 90% of the cap so that eviction does not run on every write.
+		if s.size > maxSize {
+			cursor := bucket.Cursor()
+			for k, v := cursor.First(); k != nil && s.size > maxSize*9/10; k, v = cursor.First() {
+				s.size -= int64(len(k) + len(v))
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Get returns the stored request with id.
+func (s *RequestStore) Get(id string) (*StoredRequest, error) {
+	key, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, ErrRequestNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.db == nil {
+		return nil, ErrRequestNotFound
+	}
+	rec := &StoredRequest{}
+	err = s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket([]byte(requestStoreBucket)).Get(key)
+		if raw == nil {
+			return ErrRequestNotFound
+		}
+		return json.Unmarshal(raw, rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Query returns the stored requests matching q, newest first, without their headers and bodies.
+func (s *RequestStore) Query(q RequestQuery) ([]*StoredRequest, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	keyHash := ""
+	if q.Key != "" {
+		keyHash = requestKeyHash(q.Key)
+	}
+	var upper []byte
+	if q.Before != "" {
+		if before, err := hex.DecodeString(q.Before); err == nil {
+			upper = before
+		}
+	}
+	if !q.Until.IsZero() {
+		var until [12]byte
+		binary.BigEndian.PutUint64(until[:8], uint64(q.Until.UnixNano()))
+		binary.BigEndian.PutUint32(until[8:], ^uint32(0))
+		if upper == nil || bytes.Compare(until[:], upper) < 0 {
+			upper = until[:]
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.db == nil {
+		return nil, nil
+	}
+	var out []*StoredRequest
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket([]byte(requestStoreBucket)).Cursor()
+		var k, v []byte
+		if upper == nil {
+			k, v = cursor.Last()
+		} else {
+			k, v = cursor.Seek(upper)
+			if k == nil {
+				k, v = cursor.Last()
+			}
+			for k != nil && bytes.Compare(k, upper) >= 0 {
+				k, v = cursor.Prev()
+			}
+		}
+		for ; k != nil && len(out) < limit; k, v = cursor.Prev() {
+			rec := &StoredRequest{}
+			if json.Unmarshal(v, rec) != nil {
+				continue
+			}
+			if !q.Since.IsZero() && rec.Timestamp.Before(q.Since) {
+				break
+			}
+			if !q.matches(rec, keyHash) {
+				continue
+			}
+			rec.RequestHeaders, rec.RequestBody, rec.ResponseBody = nil, "", ""
+			out = append(out, rec)
+		}
+		return nil
+	})
+	return out, err
+}
+
+func (q RequestQuery) matches(rec *StoredRequest, keyHash string) bool {
+	if keyHash != "" && rec.KeyHash != keyHash {
+		return false
+	}
+	if q.Model != "" && !matchPrefixPattern(q.Model, rec.Model) {
+		return false
+	}
+	if q.Path != "" && !matchPrefixPattern(q.Path, rec.Path) {
+		return false
+	}
+	switch status := strings.ToLower(q.Status); {
+	case status == "":
+	case status == "error":
+		return rec.Status >= 400
+	case len(status) == 3 && strings.HasSuffix(status, "xx"):
+		return status[0] == byte('0'+rec.Status/100)
+	default:
+		return status == strconv.Itoa(rec.Status)
+	}
+	return true
+}
+
+// matchPrefixPattern matches value against pattern exactly, or by prefix when pattern ends in "*".
+func matchPrefixPattern(pattern, value string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+	return pattern == value
+}
+
+// Clear removes every stored request.
+func (s *RequestStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(requestStoreBucket)); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket([]byte(requestStoreBucket))
+		return err
+	})
+	if err == nil {
+		s.size = 0
+	}
+	return err
+}
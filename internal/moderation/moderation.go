@@ -0,0 +1,277 @@
+// Package moderation filters prompts and completions against configurable rules that redact,
+// block or just log matching text (PII, secrets, profanity).
+package moderation
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Stage is the direction of a filtered payload.
+type Stage string
+
+const (
+	StagePrompt     Stage = "prompt"
+	StageCompletion Stage = "completion"
+)
+
+// Rule actions.
+const (
+	ActionRedact = "redact"
+	ActionBlock  = "block"
+	ActionLog    = "log"
+)
+
+const defaultReplacement = "[REDACTED]"
+
+type rule struct {
+	name        string
+	re          *regexp.Regexp
+	action      string
+	replacement string
+	prompt      bool
+	completion  bool
+}
+
+// Filter applies the configured rules. The zero value filters nothing.
+type Filter struct {
+	mu      sync.RWMutex
+	enabled bool
+	rules   []rule
+}
+
+var defaultFilter = &Filter{}
+
+// GetFilter returns the shared filter.
+func GetFilter() *Filter { return defaultFilter }
+
+// Configure compiles cfg. Rules with an invalid pattern, an unknown action or nothing to match
+// are skipped with an error log.
+func (f *Filter) Configure(cfg config.ModerationConfig) {
+	rules := make([]rule, 0, len(cfg.Rules))
+	for i, rc := range cfg.Rules {
+		name := strings.TrimSpace(rc.Name)
+		if name == "" {
+			name = "rule-" + strconv.Itoa(i+1)
+		}
+		re, err := compileRule(rc)
+		if err != nil {
+			log.Errorf("moderation: rule %s ignored: %v", name, err)
+			continue
+		}
+		if re == nil {
+			log.Errorf("moderation: rule %s ignored: no pattern or keywords", name)
+			continue
+		}
+		action := strings.ToLower(strings.TrimSpace(rc.Action))
+		switch action {
+		case "":
+			action = ActionRedact
+		case ActionRedact, ActionBlock, ActionLog:
+		default:
+			log.Errorf("moderation: rule %s ignored: unknown action %q", name, rc.Action)
+			continue
+		}
+		replacement := rc.Replacement
+		if replacement == "" {
+			replacement = defaultReplacement
+		}
+		scope := strings.ToLower(strings.TrimSpace(rc.Scope))
+		rules = append(rules, rule{
+			name:        name,
+			re:          re,
+			action:      action,
+			replacement: replacement,
+			prompt:      scope == "" || scope == string(StagePrompt),
+			completion:  scope == "" || scope == string(StageCompletion),
+		})
+	}
+	f.mu.Lock()
+	f.enabled = cfg.Enabled
+	f.rules = rules
+	f.mu.Unlock()
+}
+
+// compileRule combines the pattern and keywords of rc into one expression.
+func compileRule(rc config.ModerationRule) (*regexp.Regexp, error) {
+	var parts []string
+	if pattern := strings.TrimSpace(rc.Pattern); pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+		parts = append(parts, "(?:"+pattern+")")
+	}
+	var keywords []string
+	for _, kw := range rc.Keywords {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			keywords = append(keywords, regexp.QuoteMeta(kw))
+		}
+	}
+	if len(keywords) > 0 {
+		parts = append(parts, `(?i:\b(?:`+strings.Join(keywords, "|")+`)\b)`)
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile(strings.Join(parts, "|"))
+}
+
+// Active reports whether any rule applies to stage.
+func (f *Filter) Active(stage Stage) bool {
+	return len(f.rulesFor(stage)) > 0
+}
+
+func (f *Filter) rulesFor(stage Stage) []rule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.enabled {
+		return nil
+	}
+	out := make([]rule, 0, len(f.rules))
+	for _, r := range f.rules {
+		if (stage == StagePrompt && r.prompt) || (stage == StageCompletion && r.completion) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Apply filters every string value of payload, a JSON document or SSE "data:" lines carrying
+// JSON. It returns the payload with redactions applied, or the name of the rule that blocks it.
+// Matches split across stream chunks are not detected.
+func (f *Filter) Apply(stage Stage, payload []byte) (out []byte, blockedBy string) {
+	rules := f.rulesFor(stage)
+	if len(rules) == 0 || len(payload) == 0 {
+		return payload, ""
+	}
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return filterStrings(payload, func(text string) (string, string) { return applyRules(stage, rules, text) })
+	}
+	lines := bytes.Split(payload, []byte("\n"))
+	changed := false
+	for i, line := range lines {
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(line[len("data:"):])
+		if len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+			continue
+		}
+		filtered, blocked := filterStrings(data, func(text string) (string, string) { return applyRules(stage, rules, text) })
+		if blocked != "" {
+			return nil, blocked
+		}
+		if !bytes.Equal(filtered, data) {
+			lines[i] = append([]byte("data: "), filtered...)
+			changed = true
+		}
+	}
+	if !changed {
+		return payload, ""
+	}
+	return bytes.Join(lines, []byte("\n")), ""
+}
+
+// filterStrings rewrites every string value of the JSON document doc with fn, which returns
+// the new text, or a non-empty reason to stop and reject the document.
+func filterStrings(doc []byte, fn func(string) (string, string)) ([]byte, string) {
+	if !gjson.ValidBytes(doc) {
+		return doc, ""
+	}
+	type edit struct {
+		path  string
+		value string
+	}
+	var edits []edit
+	var blocked string
+	var walk func(path string, value gjson.Result) bool
+	walk = func(path string, value gjson.Result) bool {
+		switch {
+		case value.Type == gjson.String:
+			filtered, reason := fn(value.Str)
+			if reason != "" {
+				blocked = reason
+				return false
+			}
+			if filtered != value.Str {
+				edits = append(edits, edit{path: path, value: filtered})
+			}
+		case value.IsArray():
+			i := 0
+			cont := true
+			value.ForEach(func(_, item gjson.Result) bool {
+				cont = walk(joinPath(path, strconv.Itoa(i)), item)
+				i++
+				return cont
+			})
+			return cont
+		case value.IsObject():
+			cont := true
+			value.ForEach(func(key, item gjson.Result) bool {
+				cont = walk(joinPath(path, escapePathKey(key.Str)), item)
+				return cont
+			})
+			return cont
+		}
+		return true
+	}
+	walk("", gjson.ParseBytes(doc))
+	if blocked != "" {
+		return nil, blocked
+	}
+	out := doc
+	for _, e := range edits {
+		if updated, err := sjson.SetBytes(out, e.path, e.value); err == nil {
+			out = updated
+		}
+	}
+	return out, ""
+}
+
+// applyRules filters one string. When a blocking rule matches it returns the rule name.
+func applyRules(stage Stage, rules []rule, text string) (string, string) {
+	for _, r := range rules {
+		if !r.re.MatchString(text) {
+			continue
+		}
+		switch r.action {
+		case ActionBlock:
+			log.Warnf("moderation: %s blocked by rule %s", stage, r.name)
+			return "", r.name
+		case ActionLog:
+			log.Warnf("moderation: %s matched rule %s", stage, r.name)
+		default:
+			text = r.re.ReplaceAllLiteralString(text, r.replacement)
+		}
+	}
+	return text, ""
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// escapePathKey escapes the characters gjson/sjson give a meaning in paths.
+func escapePathKey(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		switch r {
+		case '.', '*', '?', '|', '#', '@', '\\', '!', '=', '<', '>', '%':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
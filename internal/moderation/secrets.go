@@ -0,0 +1,119 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// secretDetector recognises one kind of credential.
+type secretDetector struct {
+	name string
+	re   *regexp.Regexp
+}
+
+var secretDetectors = []secretDetector{
+	{name: "aws-access-key-id", re: regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{name: "aws-secret-access-key", re: regexp.MustCompile(`(?i)\baws_?secret_?access_?key\b["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+	{name: "github-token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{name: "slack-token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{name: "google-api-key", re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{name: "private-key", re: regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`)},
+}
+
+// SecretScanner masks credentials in request payloads bound for selected providers.
+type SecretScanner struct {
+	mu        sync.RWMutex
+	enabled   bool
+	mask      bool
+	all       bool
+	providers map[string]struct{}
+}
+
+var defaultSecretScanner = &SecretScanner{}
+
+// GetSecretScanner returns the shared scanner.
+func GetSecretScanner() *SecretScanner { return defaultSecretScanner }
+
+// Configure applies cfg.
+func (s *SecretScanner) Configure(cfg config.SecretDetectionConfig) {
+	providers := make(map[string]struct{})
+	all := false
+	for _, provider := range cfg.Providers {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		switch provider {
+		case "":
+		case "*":
+			all = true
+		default:
+			providers[provider] = struct{}{}
+		}
+	}
+	if len(providers) == 0 && !all {
+		providers["gemini-web"] = struct{}{}
+	}
+	action := strings.ToLower(strings.TrimSpace(cfg.Action))
+	if action != "" && action != "mask" && action != ActionLog {
+		log.Errorf("moderation: unknown secret-detection action %q, masking secrets", cfg.Action)
+	}
+	s.mu.Lock()
+	s.enabled = cfg.Enabled
+	s.mask = action != ActionLog
+	s.all = all
+	s.providers = providers
+	s.mu.Unlock()
+}
+
+// Applies reports whether requests to provider are scanned.
+func (s *SecretScanner) Applies(provider string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.enabled {
+		return false
+	}
+	if s.all {
+		return true
+	}
+	_, ok := s.providers[strings.ToLower(provider)]
+	return ok
+}
+
+// Scan looks for credentials in the string values of the JSON payload. It returns the names of
+// the detectors that matched and, unless the action is "log", the payload with each secret
+// replaced by [REDACTED:<detector>].
+func (s *SecretScanner) Scan(payload []byte) ([]byte, []string) {
+	s.mu.RLock()
+	mask := s.mask
+	s.mu.RUnlock()
+	var found []string
+	out, _ := filterStrings(payload, func(text string) (string, string) {
+		for _, d := range secretDetectors {
+			if !d.re.MatchString(text) {
+				continue
+			}
+			if !containsName(found, d.name) {
+				found = append(found, d.name)
+			}
+			if mask {
+				text = d.re.ReplaceAllLiteralString(text, "[REDACTED:"+d.name+"]")
+			}
+		}
+		return text, ""
+	})
+	if len(found) == 0 || !mask {
+		return payload, found
+	}
+	return out, found
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
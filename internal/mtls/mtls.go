@@ -0,0 +1,248 @@
+// Package mtls builds the TLS configurations for serving the API over HTTPS with client
+// certificate verification and for presenting a client certificate to HTTPS proxies.
+// Certificate and CA files are re-read when they change on disk, so renewed certificates
+// take effect without a restart.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadCheckInterval bounds how often the files are checked for changes.
+const reloadCheckInterval = 10 * time.Second
+
+// source holds a certificate and a CA pool loaded from files and reloads them when the
+// files' modification times or sizes change. A failed reload keeps the previous contents.
+type source struct {
+	certFile, keyFile, caFile string
+	// systemRoots starts the CA pool from the system roots instead of an empty pool.
+	systemRoots bool
+
+	mu      sync.Mutex
+	checked time.Time
+	stamp   string
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+}
+
+func newSource(certFile, keyFile, caFile string, systemRoots bool) (*source, error) {
+	s := &source{
+		certFile:    strings.TrimSpace(certFile),
+		keyFile:     strings.TrimSpace(keyFile),
+		caFile:      strings.TrimSpace(caFile),
+		systemRoots: systemRoots,
+	}
+	if (s.certFile == "") != (s.keyFile == "") {
+		return nil, errors.New("cert and key must be set together")
+	}
+	stamp, err := s.fileStamp()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.load(stamp); err != nil {
+		return nil, err
+	}
+	s.checked = time.Now()
+	return s, nil
+}
+
+// current returns the certificate and pool, reloading them first when the files changed.
+func (s *source) current() (*tls.Certificate, *x509.CertPool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.checked) >= reloadCheckInterval {
+		s.checked = time.Now()
+		stamp, err := s.fileStamp()
+		if err == nil && stamp != s.stamp {
+			err = s.load(stamp)
+			if err == nil {
+				log.Infof("tls: reloaded certificates (%s)", s.describe())
+			}
+		}
+		if err != nil {
+			log.Warnf("tls: failed to reload certificates (%s), keeping the previous ones: %v", s.describe(), err)
+		}
+	}
+	return s.cert, s.pool
+}
+
+func (s *source) load(stamp string) error {
+	var cert *tls.Certificate
+	if s.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			return fmt.Errorf("load key pair: %w", err)
+		}
+		cert = &pair
+	}
+	var pool *x509.CertPool
+	if s.caFile != "" {
+		data, err := os.ReadFile(s.caFile)
+		if err != nil {
+			return fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if s.systemRoots {
+			if system, errSystem := x509.SystemCertPool(); errSystem == nil {
+				pool = system
+			}
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %s", s.caFile)
+		}
+	}
+	s.cert, s.pool, s.stamp = cert, pool, stamp
+	return nil
+}
+
+func (s *source) fileStamp() (string, error) {
+	var b strings.Builder
+	for _, file := range []string{s.certFile, s.keyFile, s.caFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", file, info.ModTime().UnixNano(), info.Size())
+	}
+	return b.String(), nil
+}
+
+func (s *source) describe() string {
+	files := make([]string, 0, 2)
+	for _, file := range []string{s.certFile, s.caFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return strings.Join(files, ", ")
+}
+
+// ServerConfig returns the TLS configuration for serving the API, or nil when cfg is not
+// enabled. Each handshake uses the current certificate and client CA pool.
+func ServerConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if strings.TrimSpace(cfg.Cert) == "" || strings.TrimSpace(cfg.Key) == "" {
+		return nil, errors.New("tls: cert and key are required")
+	}
+	src, err := newSource(cfg.Cert, cfg.Key, cfg.ClientCA, false)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	clientAuth := tls.NoClientCert
+	if src.caFile != "" {
+		switch strings.ToLower(strings.TrimSpace(cfg.ClientAuth)) {
+		case "", "require":
+			clientAuth = tls.RequireAndVerifyClientCert
+		case "verify-if-given":
+			clientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("tls: unknown client-auth %q", cfg.ClientAuth)
+		}
+	}
+	minVersion := uint16(tls.VersionTLS12)
+	switch strings.TrimSpace(cfg.MinVersion) {
+	case "", "1.2":
+	case "1.3":
+		minVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("tls: unsupported min-version %q", cfg.MinVersion)
+	}
+	nextProtos := []string{"h2", "http/1.1"}
+	return &tls.Config{
+		MinVersion: minVersion,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := src.current()
+			return &tls.Config{
+				MinVersion:   minVersion,
+				NextProtos:   nextProtos,
+				Certificates: []tls.Certificate{*cert},
+				ClientAuth:   clientAuth,
+				ClientCAs:    pool,
+			}, nil
+		},
+	}, nil
+}
+
+var (
+	outboundMu  sync.RWMutex
+	outboundCfg config.UpstreamTLSConfig
+	outboundSrc *source
+)
+
+// ConfigureOutbound applies the upstream TLS settings. On error the previous settings stay.
+func ConfigureOutbound(cfg config.UpstreamTLSConfig) error {
+	outboundMu.RLock()
+	unchanged := cfg == outboundCfg
+	outboundMu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	var src *source
+	if strings.TrimSpace(cfg.Cert) != "" || strings.TrimSpace(cfg.Key) != "" || strings.TrimSpace(cfg.CA) != "" {
+		var err error
+		if src, err = newSource(cfg.Cert, cfg.Key, cfg.CA, true); err != nil {
+			return fmt.Errorf("upstream-tls: %w", err)
+		}
+	}
+	outboundMu.Lock()
+	outboundCfg, outboundSrc = cfg, src
+	outboundMu.Unlock()
+	return nil
+}
+
+// ApplyOutbound sets the upstream TLS configuration on transport when upstream-tls is
+// configured, keeping HTTP/2 enabled. The client certificate is resolved on each handshake,
+// so renewed or reconfigured certificates apply to existing transports; the CA bundle is
+// fixed when the transport is built. The transport is returned for chaining.
+func ApplyOutbound(transport *http.Transport) *http.Transport {
+	if transport == nil {
+		return transport
+	}
+	outboundMu.RLock()
+	src := outboundSrc
+	outboundMu.RUnlock()
+	if src == nil {
+		return transport
+	}
+	_, pool := src.current()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := currentOutboundCert(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+	}
+	transport.ForceAttemptHTTP2 = true
+	return transport
+}
+
+func currentOutboundCert() *tls.Certificate {
+	outboundMu.RLock()
+	src := outboundSrc
+	outboundMu.RUnlock()
+	if src == nil {
+		return nil
+	}
+	cert, _ := src.current()
+	return cert
+}
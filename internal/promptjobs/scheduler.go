@@ -0,0 +1,229 @@
+// Package promptjobs runs configured prompts on a schedule and delivers the results to a
+// webhook or a directory.
+package promptjobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const runTimeout = 10 * time.Minute
+
+// Result is the payload delivered for one job run.
+type Result struct {
+	Job        string          `json:"job"`
+	Model      string          `json:"model"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Content    string          `json:"content,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+}
+
+// Scheduler runs the configured prompt jobs through the OpenAI chat completions handler,
+// so jobs go through the same routing, aliasing and account selection as client requests.
+type Scheduler struct {
+	handler *openai.OpenAIAPIHandler
+	client  *http.Client
+
+	mu     sync.Mutex
+	jobs   []config.PromptJob
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler returns a scheduler executing requests with handler.
+func NewScheduler(handler *openai.OpenAIAPIHandler) *Scheduler {
+	return &Scheduler{handler: handler, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Configure replaces the running jobs. Unchanged configurations keep their timers.
+func (s *Scheduler) Configure(jobs []config.PromptJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil && reflect.DeepEqual(s.jobs, jobs) {
+		return
+	}
+	s.stopLocked()
+	s.jobs = append([]config.PromptJob(nil), jobs...)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, job := range s.jobs {
+		if job.Disabled {
+			continue
+		}
+		if strings.TrimSpace(job.Name) == "" || strings.TrimSpace(job.Model) == "" || strings.TrimSpace(job.Prompt) == "" {
+			log.Warnf("prompt job %q skipped: name, model and prompt are required", job.Name)
+			continue
+		}
+		loc := time.Local
+		if tz := strings.TrimSpace(job.Timezone); tz != "" {
+			var err error
+			if loc, err = time.LoadLocation(tz); err != nil {
+				log.Warnf("prompt job %s skipped: unknown timezone %q", job.Name, tz)
+				continue
+			}
+		}
+		sched, err := parseSchedule(job.Schedule, loc)
+		if err != nil {
+			log.Warnf("prompt job %s skipped: invalid schedule %q: %v", job.Name, job.Schedule, err)
+			continue
+		}
+		s.wg.Add(1)
+		go s.loop(ctx, job, sched)
+	}
+}
+
+// Stop cancels every job and waits for running ones to return.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+	s.jobs = nil
+}
+
+func (s *Scheduler) stopLocked() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.cancel = nil
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job config.PromptJob, sched schedule) {
+	defer s.wg.Done()
+	for {
+		next := sched.next(time.Now())
+		if next.IsZero() {
+			log.Warnf("prompt job %s: schedule never fires", job.Name)
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// Runs are sequential per job, so a slow run delays rather than overlaps the next.
+		s.run(ctx, job, next)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job config.PromptJob, at time.Time) {
+	result := Result{Job: job.Name, Model: job.Model, StartedAt: time.Now()}
+	resp, err := s.execute(ctx, job, at)
+	result.FinishedAt = time.Now()
+	if err != nil {
+		result.Error = err.Error()
+		log.Warnf("prompt job %s failed: %v", job.Name, err)
+	} else {
+		result.Response = resp
+		result.Content = gjson.GetBytes(resp, "choices.0.message.content").String()
+		log.Infof("prompt job %s completed in %s", job.Name, result.FinishedAt.Sub(result.StartedAt).Round(time.Millisecond))
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if dir := strings.TrimSpace(job.OutputDir); dir != "" {
+		if errWrite := writeResult(dir, result); errWrite != nil {
+			log.Warnf("prompt job %s: failed to write result: %v", job.Name, errWrite)
+		}
+	}
+	if url := strings.TrimSpace(job.WebhookURL); url != "" {
+		if errPost := s.deliver(ctx, url, job.WebhookHeaders, result); errPost != nil {
+			log.Warnf("prompt job %s: webhook delivery failed: %v", job.Name, errPost)
+		}
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, job config.PromptJob, at time.Time) ([]byte, error) {
+	prompt := strings.NewReplacer("{date}", at.Format("2006-01-02"), "{time}", at.Format("15:04")).Replace(job.Prompt)
+	body := `{"messages":[]}`
+	body, _ = sjson.Set(body, "model", job.Model)
+	if system := strings.TrimSpace(job.System); system != "" {
+		body, _ = sjson.Set(body, "messages.-1", map[string]string{"role": "system", "content": system})
+	}
+	body, _ = sjson.Set(body, "messages.-1", map[string]string{"role": "user", "content": prompt})
+
+	runCtx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+	// The job runs as its client key so api-key-rules apply to it.
+	runCtx = requestctx.WithMetadata(runCtx, requestctx.Metadata{
+		HandlerType:   s.handler.HandlerType(),
+		Tenant:        strings.TrimSpace(job.APIKey),
+		CorrelationID: idgen.NewID(runCtx, "job_"),
+	})
+	resp, errMsg := s.handler.ExecuteWithAuthManager(runCtx, s.handler.HandlerType(), job.Model, []byte(body), "")
+	if errMsg != nil {
+		return nil, errMsg.Error
+	}
+	return resp, nil
+}
+
+func writeResult(dir string, result Result) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.json", sanitizeName(result.Job), result.StartedAt.UTC().Format("20060102T150405Z"))
+	return os.WriteFile(filepath.Join(dir, name), data, 0o644)
+}
+
+func (s *Scheduler) deliver(ctx context.Context, url string, headers map[string]string, result Result) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
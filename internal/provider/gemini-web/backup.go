@@ -0,0 +1,585 @@
+package geminiwebapi
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Conversation stores are checked when an account loads them. A store that fails the check is
+// salvaged: the entries that can still be read are copied to a new file and the index is rebuilt
+// from the records. When nothing can be read, the newest backup that passes the check is
+// restored. The damaged file is kept next to the store with a .corrupt suffix. Backups of every
+// store and of the message blobs go to conv/backups at startup and then every
+// gemini-web.backup.interval-hours, keeping the newest gemini-web.backup.keep of each file.
+
+const (
+	convBackupDir             = "backups"
+	convBackupStampLayout     = "20060102-150405"
+	defaultConvBackupKeep     = 3
+	defaultConvBackupInterval = 24 * time.Hour
+	// convSalvageBatch bounds the entries held in memory while a damaged file is copied.
+	convSalvageBatch = 1000
+)
+
+// errConvIndexMissing reports a store holding records without an index bucket.
+var errConvIndexMissing = errors.New("conversation index is missing")
+
+var (
+	convBackupCfg atomic.Pointer[config.GeminiWebBackup]
+	// convBackupRunners records the conv directories that already have backups running.
+	convBackupRunners sync.Map
+)
+
+// ConfigureBackups applies the backup settings; the running backup loops pick them up at
+// their next run.
+func ConfigureBackups(cfg config.GeminiWebBackup) {
+	convBackupCfg.Store(&cfg)
+}
+
+func convBackupSettings() (int, time.Duration) {
+	keep, interval := defaultConvBackupKeep, defaultConvBackupInterval
+	if cfg := convBackupCfg.Load(); cfg != nil {
+		if cfg.Keep != 0 {
+			keep = cfg.Keep
+		}
+		if cfg.IntervalHours > 0 {
+			interval = time.Duration(cfg.IntervalHours) * time.Hour
+		}
+	}
+	return keep, interval
+}
+
+// ConvRecoveryResult describes how a damaged conversation store was recovered.
+type ConvRecoveryResult struct {
+	// Records is the number of conversation records in the recovered store.
+	Records      int `json:"records"`
+	IndexEntries int `json:"index_entries"`
+	// Salvaged is the number of entries copied out of the damaged file.
+	Salvaged int `json:"salvaged"`
+	// RestoredBackup names the backup that lost entries were taken from, if any.
+	RestoredBackup string `json:"restored_backup,omitempty"`
+	// Restored is the number of entries added back from that backup.
+	Restored int `json:"restored,omitempty"`
+	// RestoredBlobs is the number of message blobs added back from a backup.
+	RestoredBlobs int `json:"restored_blobs,omitempty"`
+	// CorruptFile is where the damaged file was moved to.
+	CorruptFile string `json:"corrupt_file,omitempty"`
+}
+
+// CheckConversationStore verifies that every page of the BoltDB file at path can be read and,
+// for an account store, that its records have an index. A missing file passes.
+func CheckConversationStore(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return safely(func() error {
+		db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+		return db.View(func(tx *bolt.Tx) error {
+			// Walking every bucket reads every branch and leaf page, and bbolt panics on pages it
+			// cannot decode. tx.Check is not used: it walks in a goroutine of its own, where such
+			// a panic would take the process down.
+			errWalk := tx.ForEach(func(_ []byte, b *bolt.Bucket) error {
+				return b.ForEach(func(_, _ []byte) error { return nil })
+			})
+			if errWalk != nil {
+				return errWalk
+			}
+			if items := tx.Bucket([]byte("conv_items")); items != nil && items.Stats().KeyN > 0 {
+				if index := tx.Bucket([]byte("conv_index")); index == nil || index.Stats().KeyN == 0 {
+					return errConvIndexMissing
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// convStoreDamaged reports whether err, returned by CheckConversationStore, means the file is
+// damaged rather than busy or inaccessible.
+func convStoreDamaged(err error) bool {
+	return !errors.Is(err, bolt.ErrTimeout) && !errors.Is(err, fs.ErrPermission)
+}
+
+// ensureConvStoreIntact checks the store at path and recovers it when the check fails.
+func ensureConvStoreIntact(path string) {
+	errCheck := CheckConversationStore(path)
+	if errCheck == nil {
+		return
+	}
+	if !convStoreDamaged(errCheck) {
+		log.Warnf("gemini web: conversation store %s could not be checked: %v", filepath.Base(path), errCheck)
+		return
+	}
+	log.Warnf("gemini web: conversation store %s failed the integrity check: %v", filepath.Base(path), errCheck)
+	result, err := RecoverConversationStore(path)
+	if err != nil {
+		log.Errorf("gemini web: failed to recover conversation store %s: %v", filepath.Base(path), err)
+		return
+	}
+	logConvRecovery(path, result)
+}
+
+func logConvRecovery(path string, result ConvRecoveryResult) {
+	switch {
+	case filepath.Base(path) == convBlobFile:
+		log.Warnf("gemini web: salvaged %d message blobs and restored %d from backup; damaged file kept as %s",
+			result.Salvaged, result.RestoredBlobs, result.CorruptFile)
+	case result.RestoredBackup != "":
+		log.Warnf("gemini web: salvaged %d entries of conversation store %s and restored %d from backup %s (%d records); damaged file kept as %s",
+			result.Salvaged, filepath.Base(path), result.Restored, result.RestoredBackup, result.Records, result.CorruptFile)
+	case result.CorruptFile != "":
+		log.Warnf("gemini web: salvaged %d entries of conversation store %s (%d records, %d index entries); damaged file kept as %s",
+			result.Salvaged, filepath.Base(path), result.Records, result.IndexEntries, result.CorruptFile)
+	default:
+		log.Infof("gemini web: rebuilt the index of conversation store %s (%d records, %d index entries)",
+			filepath.Base(path), result.Records, result.IndexEntries)
+	}
+}
+
+// RecoverConversationStore repairs the store at path when it fails CheckConversationStore. An
+// account store that only lacks its index gets the index rebuilt in place. Otherwise the
+// readable entries are salvaged into a new file, completed with the entries of the newest valid
+// backup that could not be read, and the damaged file is moved aside. A store without readable
+// entries or backups is moved aside, so the account starts over with an empty one. The message blob DB
+// is salvaged and completed with the blobs of its newest valid backup.
+func RecoverConversationStore(path string) (ConvRecoveryResult, error) {
+	var result ConvRecoveryResult
+	errCheck := CheckConversationStore(path)
+	if errCheck == nil {
+		return result, nil
+	}
+	if !convStoreDamaged(errCheck) {
+		return result, errCheck
+	}
+	if filepath.Base(path) == convBlobFile {
+		return recoverBlobStore(path)
+	}
+	if errors.Is(errCheck, errConvIndexMissing) {
+		reindexed, err := reindexConvStore(path, convStoreAccount(path))
+		result.Records, result.IndexEntries = reindexed.Records, reindexed.IndexEntries
+		return result, err
+	}
+
+	recovered := path + ".recover"
+	salvaged, errSalvage := copyBoltEntries(path, recovered, false)
+	if errSalvage != nil {
+		_ = os.Remove(recovered)
+		log.Warnf("gemini web: nothing salvaged from conversation store %s: %v", filepath.Base(path), errSalvage)
+		salvaged = 0
+	}
+	backup, err := newestValidBackup(path)
+	if err != nil {
+		_ = os.Remove(recovered)
+		return result, err
+	}
+	if backup != "" {
+		// Entries lost to the damage come back from the backup; salvaged ones are newer and win.
+		if result.Restored, err = copyBoltEntries(backup, recovered, true); err != nil {
+			_ = os.Remove(recovered)
+			return result, err
+		}
+		result.RestoredBackup = filepath.Base(backup)
+	} else if salvaged == 0 {
+		_ = os.Remove(recovered)
+		corrupt, errMove := moveCorruptFile(path)
+		result.CorruptFile = corrupt
+		if errMove != nil {
+			return result, errMove
+		}
+		return result, errors.New("no readable entries and no valid backup; starting with an empty store")
+	}
+	result.Salvaged = salvaged
+	corrupt, err := moveCorruptFile(path)
+	result.CorruptFile = corrupt
+	if err != nil {
+		_ = os.Remove(recovered)
+		return result, err
+	}
+	if err = os.Rename(recovered, path); err != nil {
+		return result, err
+	}
+	if backup != "" {
+		restoreBackupBlobs(path, backup)
+	}
+	reindexed, err := reindexConvStore(path, convStoreAccount(path))
+	result.Records, result.IndexEntries = reindexed.Records, reindexed.IndexEntries
+	return result, err
+}
+
+// recoverBlobStore salvages the shared message blob DB at path and adds the blobs it lost from
+// the newest valid backup.
+func recoverBlobStore(path string) (ConvRecoveryResult, error) {
+	var result ConvRecoveryResult
+	convBlobMu.Lock()
+	recovered := path + ".recover"
+	salvaged, errSalvage := copyBoltEntries(path, recovered, false)
+	if errSalvage != nil {
+		_ = os.Remove(recovered)
+		log.Warnf("gemini web: nothing salvaged from the message blob store: %v", errSalvage)
+	}
+	corrupt, err := moveCorruptFile(path)
+	if err == nil && errSalvage == nil {
+		err = os.Rename(recovered, path)
+	}
+	convBlobMu.Unlock()
+	result.Salvaged, result.CorruptFile = salvaged, corrupt
+	if err != nil {
+		return result, err
+	}
+	backup, err := newestValidBackup(path)
+	if err != nil || backup == "" {
+		return result, err
+	}
+	result.RestoredBackup = filepath.Base(backup)
+	result.RestoredBlobs, err = mergeMessageBlobs(backup, path)
+	return result, err
+}
+
+// restoreBackupBlobs adds the blobs that the store at path references and the message blob DB
+// lacks from the blob backup written together with the store backup.
+func restoreBackupBlobs(path, backup string) {
+	stamp := backup[strings.LastIndex(backup, ".")+1:]
+	blobBackup := filepath.Join(filepath.Dir(backup), convBlobFile+"."+stamp)
+	if _, err := os.Stat(blobBackup); err != nil {
+		return
+	}
+	refs := make(map[string]struct{})
+	if err := storedMessageRefs(path, refs); err != nil {
+		log.Warnf("gemini web: failed to read restored store %s: %v", filepath.Base(path), err)
+		return
+	}
+	keys := make([]string, 0, len(refs))
+	for ref := range refs {
+		keys = append(keys, ref)
+	}
+	blobs, err := getMessageBlobs(blobBackup, keys)
+	if err == nil && len(blobs) > 0 {
+		err = putMessageBlobs(convBlobPath(path), blobs)
+	}
+	if err != nil {
+		log.Warnf("gemini web: failed to restore message blobs from backup %s: %v", filepath.Base(blobBackup), err)
+	}
+}
+
+// mergeMessageBlobs adds the blobs of the blob DB at src that are missing from dst.
+func mergeMessageBlobs(src, dst string) (int, error) {
+	var keys []string
+	db, err := bolt.Open(src, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(convBlobBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	_ = db.Close()
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for start := 0; start < len(keys); start += convSalvageBatch {
+		blobs, errGet := getMessageBlobs(src, keys[start:min(start+convSalvageBatch, len(keys))])
+		if errGet != nil {
+			return added, errGet
+		}
+		existing, errExisting := getMessageBlobs(dst, keys[start:min(start+convSalvageBatch, len(keys))])
+		if errExisting != nil && !errors.Is(errExisting, os.ErrNotExist) {
+			return added, errExisting
+		}
+		for key := range existing {
+			delete(blobs, key)
+		}
+		if len(blobs) == 0 {
+			continue
+		}
+		if errPut := putMessageBlobs(dst, blobs); errPut != nil {
+			return added, errPut
+		}
+		added += len(blobs)
+	}
+	return added, nil
+}
+
+// copyBoltEntries copies every entry of src that can still be read into dst and returns the
+// number copied. Reading stops at the first damaged page of each bucket. Unless keepExisting
+// is set, dst is replaced by a new file; otherwise entries already in dst are kept. The
+// conversation index is skipped, since callers rebuild it from the copied records, and so is the
+// store's schema version, so that records copied from an older backup are upgraded on load.
+func copyBoltEntries(src, dst string, keepExisting bool) (int, error) {
+	var in *bolt.DB
+	if err := safely(func() error {
+		var errOpen error
+		in, errOpen = bolt.Open(src, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+		return errOpen
+	}); err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	if !keepExisting {
+		_ = os.Remove(dst)
+	}
+	out, err := bolt.Open(dst, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+	copied := 0
+	err = in.View(func(tx *bolt.Tx) error {
+		var names [][]byte
+		_ = safely(func() error {
+			return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+				if string(name) == "conv_index" || string(name) == bucketConvSchema {
+					return nil
+				}
+				names = append(names, append([]byte(nil), name...))
+				return nil
+			})
+		})
+		for _, name := range names {
+			var keys, values [][]byte
+			flush := func() error {
+				if len(keys) == 0 {
+					return nil
+				}
+				errUpdate := out.Update(func(otx *bolt.Tx) error {
+					b, errCreate := otx.CreateBucketIfNotExists(name)
+					if errCreate != nil {
+						return errCreate
+					}
+					for i := range keys {
+						if keepExisting && b.Get(keys[i]) != nil {
+							continue
+						}
+						if errPut := b.Put(keys[i], values[i]); errPut != nil {
+							return errPut
+						}
+						copied++
+					}
+					return nil
+				})
+				keys, values = keys[:0], values[:0]
+				return errUpdate
+			}
+			var errFlush error
+			_ = safely(func() error {
+				b := tx.Bucket(name)
+				if b == nil {
+					return nil
+				}
+				return b.ForEach(func(k, v []byte) error {
+					if v == nil {
+						// Nested buckets are not used by the conversation stores.
+						return nil
+					}
+					keys = append(keys, append([]byte(nil), k...))
+					values = append(values, append([]byte(nil), v...))
+					if len(keys) >= convSalvageBatch {
+						errFlush = flush()
+					}
+					return errFlush
+				})
+			})
+			if errFlush != nil {
+				return errFlush
+			}
+			if errFlush = flush(); errFlush != nil {
+				return errFlush
+			}
+		}
+		return nil
+	})
+	return copied, err
+}
+
+// safely runs fn, turning a panic, which bbolt raises on some damaged pages, into an error.
+func safely(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("damaged database: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func moveCorruptFile(path string) (string, error) {
+	corrupt := path + ".corrupt-" + time.Now().UTC().Format(convBackupStampLayout)
+	if err := os.Rename(path, corrupt); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return corrupt, nil
+}
+
+// startConvBackups recovers the message blob DB of dir if it is damaged and then backs up the
+// stores of dir at once and every gemini-web.backup.interval-hours, once per process and
+// directory.
+func startConvBackups(dir string) {
+	if dir == "" {
+		return
+	}
+	if _, running := convBackupRunners.LoadOrStore(dir, struct{}{}); running {
+		return
+	}
+	ensureConvStoreIntact(filepath.Join(dir, convBlobFile))
+	go func() {
+		for {
+			keep, interval := convBackupSettings()
+			if keep > 0 {
+				n, err := BackupConversationStores(dir, keep, time.Now())
+				if err != nil {
+					log.Warnf("gemini web: conversation backup incomplete: %v", err)
+				} else if n > 0 {
+					log.Debugf("gemini web: backed up %d conversation files", n)
+				}
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// BackupConversationStores copies every conversation store in dir and the message blob DB to
+// dir/backups, then deletes all but the newest keep backups of each file. Files failing the
+// integrity check are skipped so they do not rotate good backups out. It returns the number
+// of files backed up.
+func BackupConversationStores(dir string, keep int, now time.Time) (int, error) {
+	paths, err := ConversationStoreFiles(dir)
+	if err != nil || len(paths) == 0 {
+		return 0, err
+	}
+	backupDir := filepath.Join(dir, convBackupDir)
+	if err = os.MkdirAll(backupDir, 0o700); err != nil {
+		return 0, err
+	}
+	stamp := now.UTC().Format(convBackupStampLayout)
+	var errs []error
+	written := 0
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if errCheck := CheckConversationStore(path); errCheck != nil && !errors.Is(errCheck, errConvIndexMissing) {
+			errs = append(errs, fmt.Errorf("%s: skipped, integrity check failed: %w", name, errCheck))
+			continue
+		}
+		if errBackup := backupBoltFile(path, filepath.Join(backupDir, name+"."+stamp)); errBackup != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, errBackup))
+			continue
+		}
+		written++
+		if errPrune := pruneBackups(backupDir, name, keep); errPrune != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, errPrune))
+		}
+	}
+	return written, errors.Join(errs...)
+}
+
+// ConversationStoreFiles returns the account stores in dir followed by the message blob DB,
+// when it exists.
+func ConversationStoreFiles(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.bolt"))
+	if err != nil {
+		return nil, err
+	}
+	if _, errStat := os.Stat(filepath.Join(dir, convBlobFile)); errStat == nil {
+		paths = append(paths, filepath.Join(dir, convBlobFile))
+	}
+	return paths, nil
+}
+
+// backupBoltFile writes a consistent copy of the BoltDB file at path to dst.
+func backupBoltFile(path, dst string) error {
+	if filepath.Base(path) == convBlobFile {
+		convBlobMu.Lock()
+		defer convBlobMu.Unlock()
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	tmp := dst + ".tmp"
+	if err = db.View(func(tx *bolt.Tx) error { return tx.CopyFile(tmp, 0o600) }); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// convBackups returns the backups of the file named name in backupDir, newest first.
+func convBackups(backupDir, name string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(backupDir, name+".*"))
+	if err != nil {
+		return nil, err
+	}
+	out := matches[:0]
+	for _, match := range matches {
+		stamp := strings.TrimPrefix(filepath.Base(match), name+".")
+		if _, errParse := time.Parse(convBackupStampLayout, stamp); errParse == nil {
+			out = append(out, match)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(out)))
+	return out, nil
+}
+
+func pruneBackups(backupDir, name string, keep int) error {
+	backups, err := convBackups(backupDir, name)
+	if err != nil || len(backups) <= keep {
+		return err
+	}
+	var errs []error
+	for _, old := range backups[keep:] {
+		if errRemove := os.Remove(old); errRemove != nil {
+			errs = append(errs, errRemove)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newestValidBackup returns the newest backup of the file at path that passes the integrity
+// check, or "" when there is none.
+func newestValidBackup(path string) (string, error) {
+	backups, err := convBackups(filepath.Join(filepath.Dir(path), convBackupDir), filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	for _, backup := range backups {
+		if errCheck := CheckConversationStore(backup); errCheck == nil || errors.Is(errCheck, errConvIndexMissing) {
+			return backup, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,494 @@
+package geminiwebapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// convBlobFile is the BoltDB file, shared by every account in the conv directory, that
+	// holds message bodies keyed by content hash.
+	convBlobFile   = "message-blobs.db"
+	convBlobBucket = "message_blobs"
+)
+
+// convBlobMu serialises access to the shared blob DB; bbolt holds an exclusive file lock
+// while open, so concurrent opens from different accounts would otherwise time out.
+var convBlobMu sync.Mutex
+
+// convBlobGCMu is held shared by SaveConvData from writing blobs until the records that
+// reference them are stored, and exclusively by blob collection, so a collection never
+// deletes a blob whose record is still being written.
+var convBlobGCMu sync.RWMutex
+
+// convBlobGCInterval is how often a running server removes blobs no record references.
+const convBlobGCInterval = time.Hour
+
+// convBlobCollectors records the conv directories that already have a collector running.
+var convBlobCollectors sync.Map
+
+// storedConversationRecord is the on-disk form of a ConversationRecord. Messages are
+// replaced by MessageRefs into the shared blob bucket when the blobs could be written;
+// records written by older releases carry Messages inline and still load.
+type storedConversationRecord struct {
+	ConversationRecord
+	MessageRefs []string `json:"message_refs,omitempty"`
+}
+
+func convBlobPath(convPath string) string {
+	return filepath.Join(filepath.Dir(convPath), convBlobFile)
+}
+
+// messageBlobKey returns the content address of one stored message.
+func messageBlobKey(msg conversation.StoredMessage) string {
+	if msg.Digest != "" {
+		return conversation.Sha256Hex(msg.Role + "\x00" + msg.Name + "\x00" + msg.Content + "\x00" + msg.Digest)
+	}
+	return conversation.Sha256Hex(msg.Role + "\x00" + msg.Name + "\x00" + msg.Content)
+}
+
+// encodeConversationRecords converts records to their on-disk form, writing message bodies
+// to the shared blob DB next to convPath. When the blob DB cannot be written, records are
+// kept inline so no data is lost.
+func encodeConversationRecords(convPath string, items map[string]ConversationRecord) map[string]storedConversationRecord {
+	out := make(map[string]storedConversationRecord, len(items))
+	blobs := make(map[string]conversation.StoredMessage)
+	for key, rec := range items {
+		refs := make([]string, len(rec.Messages))
+		for i, msg := range rec.Messages {
+			ref := messageBlobKey(msg)
+			refs[i] = ref
+			blobs[ref] = msg
+		}
+		stored := storedConversationRecord{ConversationRecord: rec, MessageRefs: refs}
+		stored.Messages = nil
+		out[key] = stored
+	}
+	if len(blobs) == 0 {
+		return out
+	}
+	if err := putMessageBlobs(convBlobPath(convPath), blobs); err != nil {
+		log.Debugf("gemini web: failed to write message blobs, storing messages inline: %v", err)
+		for key, stored := range out {
+			stored.Messages = items[key].Messages
+			stored.MessageRefs = nil
+			out[key] = stored
+		}
+	}
+	return out
+}
+
+// decodeConversationRecords resolves message references from the shared blob DB. It fails
+// when the blob DB cannot be read or a referenced blob is missing, so callers never mistake
+// an unreadable store for an empty one and overwrite it.
+func decodeConversationRecords(convPath string, stored map[string]storedConversationRecord) (map[string]ConversationRecord, error) {
+	items, missing, err := resolveConversationRecords(convPath, stored)
+	if err != nil {
+		return nil, err
+	}
+	if missing > 0 {
+		return nil, fmt.Errorf("%d conversations reference missing message blobs", missing)
+	}
+	return items, nil
+}
+
+// resolveConversationRecords resolves message references from the shared blob DB, leaving
+// out the records that reference missing blobs and returning their number.
+func resolveConversationRecords(convPath string, stored map[string]storedConversationRecord) (map[string]ConversationRecord, int, error) {
+	items := make(map[string]ConversationRecord, len(stored))
+	var refs []string
+	for _, rec := range stored {
+		if len(rec.Messages) == 0 {
+			refs = append(refs, rec.MessageRefs...)
+		}
+	}
+	var blobs map[string]conversation.StoredMessage
+	if len(refs) > 0 {
+		var err error
+		blobs, err = getMessageBlobs(convBlobPath(convPath), refs)
+		if err != nil {
+			return nil, 0, fmt.Errorf("read message blobs: %w", err)
+		}
+	}
+	missing := 0
+	for key, rec := range stored {
+		if len(rec.Messages) == 0 && len(rec.MessageRefs) > 0 {
+			messages := make([]conversation.StoredMessage, 0, len(rec.MessageRefs))
+			for _, ref := range rec.MessageRefs {
+				msg, ok := blobs[ref]
+				if !ok {
+					messages = nil
+					break
+				}
+				messages = append(messages, msg)
+			}
+			if messages == nil {
+				missing++
+				continue
+			}
+			rec.Messages = messages
+		}
+		items[key] = rec.ConversationRecord
+	}
+	return items, missing, nil
+}
+
+func putMessageBlobs(path string, blobs map[string]conversation.StoredMessage) error {
+	convBlobMu.Lock()
+	defer convBlobMu.Unlock()
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, errCreate := tx.CreateBucketIfNotExists([]byte(convBlobBucket))
+		if errCreate != nil {
+			return errCreate
+		}
+		for key, msg := range blobs {
+			if b.Get([]byte(key)) != nil {
+				continue
+			}
+			enc, errMarshal := json.Marshal(msg)
+			if errMarshal != nil {
+				return errMarshal
+			}
+			if errPut := b.Put([]byte(key), enc); errPut != nil {
+				return errPut
+			}
+		}
+		return nil
+	})
+}
+
+func getMessageBlobs(path string, keys []string) (map[string]conversation.StoredMessage, error) {
+	out := make(map[string]conversation.StoredMessage, len(keys))
+	if _, err := os.Stat(path); err != nil {
+		return out, err
+	}
+	convBlobMu.Lock()
+	defer convBlobMu.Unlock()
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return out, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(convBlobBucket))
+		if b == nil {
+			return nil
+		}
+		for _, key := range keys {
+			if _, done := out[key]; done {
+				continue
+			}
+			v := b.Get([]byte(key))
+			if v == nil {
+				continue
+			}
+			var msg conversation.StoredMessage
+			if json.Unmarshal(v, &msg) == nil {
+				out[key] = msg
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ConvDedupResult summarises a deduplication pass over a conv directory.
+type ConvDedupResult struct {
+	Stores             int   `json:"stores"`
+	Conversations      int   `json:"conversations"`
+	DuplicateRecords   int   `json:"duplicate_records"`
+	PrunedIndexEntries int   `json:"pruned_index_entries"`
+	Blobs              int   `json:"blobs"`
+	RemovedBlobs       int   `json:"removed_blobs"`
+	BytesBefore        int64 `json:"bytes_before"`
+	BytesAfter         int64 `json:"bytes_after"`
+}
+
+// DedupConversationStores rewrites every account store in dir so message bodies live in the
+// shared blob DB, folds records holding the same history into the newest one, drops index
+// entries that no longer point at a record, removes blobs no store references, and compacts
+// the files. It must not run while a server is using dir.
+func DedupConversationStores(dir string) (ConvDedupResult, error) {
+	var result ConvDedupResult
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return result, nil
+		}
+		return result, err
+	}
+	var stores []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".bolt") {
+			stores = append(stores, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(stores)
+	blobPath := filepath.Join(dir, convBlobFile)
+	result.BytesBefore = fileSizes(append([]string{blobPath}, stores...)...)
+
+	var errs []error
+	for _, path := range stores {
+		items, index, errLoad := LoadConvData(path)
+		if errLoad != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), errLoad))
+			continue
+		}
+		result.DuplicateRecords += dedupConversationRecords(items, index)
+		for key, target := range index {
+			if _, ok := items[target]; !ok {
+				delete(index, key)
+				result.PrunedIndexEntries++
+			}
+		}
+		if errSave := SaveConvData(path, items, index); errSave != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), errSave))
+			continue
+		}
+		result.Stores++
+		result.Conversations += len(items)
+		if errCompact := compactBoltFile(path); errCompact != nil {
+			errs = append(errs, fmt.Errorf("compact %s: %w", filepath.Base(path), errCompact))
+		}
+	}
+	if len(errs) > 0 {
+		// Keep every blob when a store could not be read; it may still reference them.
+		result.BytesAfter = fileSizes(append([]string{blobPath}, stores...)...)
+		return result, errors.Join(errs...)
+	}
+
+	if _, errStat := os.Stat(blobPath); errStat == nil {
+		blobs, removed, errGC := CollectConversationBlobs(dir)
+		result.Blobs, result.RemovedBlobs = blobs, removed
+		if errGC != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", convBlobFile, errGC))
+		} else if errCompact := compactBoltFile(blobPath); errCompact != nil {
+			errs = append(errs, fmt.Errorf("compact %s: %w", convBlobFile, errCompact))
+		}
+	}
+	result.BytesAfter = fileSizes(append([]string{blobPath}, stores...)...)
+	return result, errors.Join(errs...)
+}
+
+// dedupConversationRecords keeps only the newest of the records that hold the same model and
+// history, which happens when the account's client ID changes with a cookie rotation, and
+// points the index entries of the others at it. It returns the number of records removed.
+func dedupConversationRecords(items map[string]ConversationRecord, index map[string]string) int {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	kept := make(map[string]string, len(items))
+	replaced := make(map[string]string)
+	for _, key := range keys {
+		rec := items[key]
+		refs := make([]string, len(rec.Messages))
+		for i, msg := range rec.Messages {
+			refs[i] = messageBlobKey(msg)
+		}
+		fingerprint := strings.ToLower(strings.TrimSpace(rec.Model)) + "\x00" + strings.Join(refs, ",")
+		winner, ok := kept[fingerprint]
+		if !ok {
+			kept[fingerprint] = key
+			continue
+		}
+		loser := key
+		if rec.UpdatedAt.After(items[winner].UpdatedAt) {
+			winner, loser = key, winner
+			kept[fingerprint] = winner
+		}
+		replaced[loser] = winner
+	}
+	for loser := range replaced {
+		delete(items, loser)
+	}
+	for key, target := range index {
+		for {
+			next, ok := replaced[target]
+			if !ok {
+				break
+			}
+			target = next
+		}
+		index[key] = target
+	}
+	return len(replaced)
+}
+
+// CollectConversationBlobs removes blobs that no record in the stores of dir references and
+// returns the remaining and removed counts. Nothing is removed when a store cannot be read,
+// since it may still reference any blob.
+func CollectConversationBlobs(dir string) (int, int, error) {
+	blobPath := filepath.Join(dir, convBlobFile)
+	if _, err := os.Stat(blobPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	stores, err := filepath.Glob(filepath.Join(dir, "*.bolt"))
+	if err != nil {
+		return 0, 0, err
+	}
+	convBlobGCMu.Lock()
+	defer convBlobGCMu.Unlock()
+	referenced := make(map[string]struct{})
+	for _, path := range stores {
+		if errRefs := storedMessageRefs(path, referenced); errRefs != nil {
+			return 0, 0, fmt.Errorf("%s: %w", filepath.Base(path), errRefs)
+		}
+	}
+	return collectMessageBlobs(blobPath, referenced)
+}
+
+// storedMessageRefs adds the blob references of every record in the store at path to refs.
+func storedMessageRefs(path string, refs map[string]struct{}) error {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("conv_items"))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var rec storedConversationRecord
+			if json.Unmarshal(v, &rec) != nil {
+				// Malformed records are skipped on load as well.
+				return nil
+			}
+			for _, ref := range rec.MessageRefs {
+				refs[ref] = struct{}{}
+			}
+			return nil
+		})
+	})
+}
+
+// startConvBlobCollector runs CollectConversationBlobs for dir every convBlobGCInterval,
+// once per process and directory.
+func startConvBlobCollector(dir string) {
+	if dir == "" {
+		return
+	}
+	if _, running := convBlobCollectors.LoadOrStore(dir, struct{}{}); running {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(convBlobGCInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			kept, removed, err := CollectConversationBlobs(dir)
+			if err != nil {
+				log.Debugf("gemini web: message blob collection skipped: %v", err)
+				continue
+			}
+			if removed > 0 {
+				log.Debugf("gemini web: removed %d unreferenced message blobs, %d kept", removed, kept)
+			}
+		}
+	}()
+}
+
+// collectMessageBlobs deletes blobs absent from referenced and returns the remaining and
+// removed counts.
+func collectMessageBlobs(path string, referenced map[string]struct{}) (int, int, error) {
+	convBlobMu.Lock()
+	defer convBlobMu.Unlock()
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	kept, removed := 0, 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(convBlobBucket))
+		if b == nil {
+			return nil
+		}
+		var stale [][]byte
+		if errEach := b.ForEach(func(k, _ []byte) error {
+			if _, ok := referenced[string(k)]; ok {
+				kept++
+				return nil
+			}
+			stale = append(stale, append([]byte(nil), k...))
+			return nil
+		}); errEach != nil {
+			return errEach
+		}
+		for _, k := range stale {
+			if errDelete := b.Delete(k); errDelete != nil {
+				return errDelete
+			}
+			removed++
+		}
+		return nil
+	})
+	return kept, removed, err
+}
+
+// compactBoltFile rewrites path into a fresh file so pages freed by deletes are returned
+// to the filesystem.
+func compactBoltFile(path string) error {
+	if filepath.Base(path) == convBlobFile {
+		convBlobMu.Lock()
+		defer convBlobMu.Unlock()
+	}
+	src, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".compact"
+	_ = os.Remove(tmp)
+	dst, err := bolt.Open(tmp, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		_ = src.Close()
+		return err
+	}
+	errCompact := bolt.Compact(dst, src, 1<<20)
+	_ = dst.Close()
+	_ = src.Close()
+	if errCompact != nil {
+		_ = os.Remove(tmp)
+		return errCompact
+	}
+	return os.Rename(tmp, path)
+}
+
+func fileSizes(paths ...string) int64 {
+	var total int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
@@ -0,0 +1,61 @@
+package geminiwebapi
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+)
+
+// A client that edits an earlier message sends a history that shares only a prefix with the
+// upstream conversation. Reusing that conversation would append the edited turn after replies
+// the client no longer sees, so such requests start a new upstream conversation instead.
+
+// upstreamHistory returns the longest recorded history of the upstream conversation that
+// metadata continues, identified by its Gemini conversation ID.
+func (s *GeminiWebState) upstreamHistory(metadata []string, incoming []RoleText) []RoleText {
+	if len(metadata) == 0 || strings.TrimSpace(metadata[0]) == "" {
+		return nil
+	}
+	cid := metadata[0]
+	s.convMu.RLock()
+	latest, longest := "", 0
+	for key, header := range s.convHeaders {
+		if header.conversationID() == cid && (latest == "" || header.Messages > longest) {
+			latest, longest = key, header.Messages
+		}
+	}
+	s.convMu.RUnlock()
+	if latest == "" {
+		return nil
+	}
+	rec, ok := s.conversationRecord(latest)
+	if !ok {
+		return nil
+	}
+	return s.recordHistory(rec, incoming)
+}
+
+// branchPoint reports whether incoming diverges from the upstream conversation that metadata
+// continues, and the index of the first message that differs. Histories that merely extend
+// the upstream conversation, or that it has not been recorded for, do not diverge.
+func (s *GeminiWebState) branchPoint(metadata []string, incoming []RoleText) (int, bool) {
+	upstream := s.upstreamHistory(metadata, incoming)
+	if len(upstream) == 0 {
+		return 0, false
+	}
+	n := len(upstream)
+	if len(incoming) < n {
+		n = len(incoming)
+	}
+	for i := 0; i < n; i++ {
+		if !conversation.EqualMessages(upstream[i:i+1], incoming[i:i+1]) {
+			return i, true
+		}
+	}
+	// Incoming stops inside the upstream conversation: a regenerate of an earlier turn, which
+	// must not continue after the replies already recorded.
+	if len(incoming) < len(upstream) {
+		return len(incoming) - 1, true
+	}
+	return 0, false
+}
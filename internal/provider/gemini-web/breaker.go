@@ -0,0 +1,186 @@
+package geminiwebapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = time.Minute
+	defaultBreakerProbes    = 1
+)
+
+// Circuit breaker states reported by BreakerStatus.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerStatus is a snapshot of an account's circuit breaker.
+type BreakerStatus struct {
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Trips               int       `json:"trips"`
+	OpenedAt            time.Time `json:"opened_at,omitempty"`
+	RetryAt             time.Time `json:"retry_at,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+type breakerSettings struct {
+	enabled   bool
+	threshold int
+	cooldown  time.Duration
+	probes    int
+}
+
+func breakerSettingsFor(cfg *config.Config) breakerSettings {
+	s := breakerSettings{enabled: true, threshold: defaultBreakerThreshold, cooldown: defaultBreakerCooldown, probes: defaultBreakerProbes}
+	if cfg == nil {
+		return s
+	}
+	b := cfg.GeminiWeb.CircuitBreaker
+	s.enabled = !b.Disabled
+	if b.FailureThreshold > 0 {
+		s.threshold = b.FailureThreshold
+	}
+	if b.CooldownSeconds > 0 {
+		s.cooldown = time.Duration(b.CooldownSeconds) * time.Second
+	}
+	if b.HalfOpenProbes > 0 {
+		s.probes = b.HalfOpenProbes
+	}
+	return s
+}
+
+// circuitBreaker stops sending to an account after consecutive upstream failures. After
+// the cooldown it lets single probe requests through and closes again once enough of them
+// succeed.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     string
+	failures  int
+	successes int
+	probing   bool
+	trips     int
+	openedAt  time.Time
+	lastError string
+}
+
+// allow reports whether a request may be sent and, when it may not, how long until the
+// next probe is admitted. An admitted request must be followed by record.
+func (b *circuitBreaker) allow(s breakerSettings, now time.Time) (bool, time.Duration) {
+	if !s.enabled {
+		return true, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.openedAt.Add(s.cooldown).Sub(now); wait > 0 {
+			return false, wait
+		}
+		b.state = BreakerHalfOpen
+		b.successes = 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probing {
+			return false, time.Second
+		}
+		b.probing = true
+	}
+	return true, 0
+}
+
+// release returns the slot of an admitted request that was never sent, without counting
+// it as a success or a failure.
+func (b *circuitBreaker) release(s breakerSettings) {
+	if !s.enabled {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// record feeds the outcome of an admitted request into the breaker.
+func (b *circuitBreaker) record(s breakerSettings, err error, now time.Time) {
+	if !s.enabled {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err != nil && !countsAsBreakerFailure(err) {
+		// A client-side error proves nothing about the account; a half-open breaker probes again.
+		return
+	}
+	if err == nil {
+		if b.state == BreakerHalfOpen {
+			b.successes++
+			if b.successes < s.probes {
+				return
+			}
+		}
+		b.state, b.failures, b.successes = BreakerClosed, 0, 0
+		return
+	}
+	b.lastError = err.Error()
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= s.threshold {
+		if b.state != BreakerOpen {
+			b.trips++
+		}
+		b.state = BreakerOpen
+		b.openedAt = now
+	}
+}
+
+func (b *circuitBreaker) status(s breakerSettings) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BreakerStatus{State: b.state, ConsecutiveFailures: b.failures, Trips: b.trips, LastError: b.lastError}
+	if st.State == "" {
+		st.State = BreakerClosed
+	}
+	if st.State != BreakerClosed {
+		st.OpenedAt = b.openedAt
+		st.RetryAt = b.openedAt.Add(s.cooldown)
+	}
+	return st
+}
+
+func (b *circuitBreaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state, b.failures, b.successes, b.probing = BreakerClosed, 0, 0, false
+}
+
+// countsAsBreakerFailure excludes errors caused by the request itself or by the caller
+// giving up, which say nothing about the health of the account.
+func countsAsBreakerFailure(err error) bool {
+	var valueErr *ValueError
+	var invalid *ModelInvalid
+	switch {
+	case errors.As(err, &valueErr), errors.As(err, &invalid):
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+// BreakerStatus returns the circuit breaker state of this account.
+func (s *GeminiWebState) BreakerStatus() BreakerStatus {
+	return s.breaker.status(breakerSettingsFor(s.config()))
+}
+
+// ResetBreaker closes the circuit breaker of this account.
+func (s *GeminiWebState) ResetBreaker() {
+	s.breaker.reset()
+}
@@ -0,0 +1,193 @@
+package geminiwebapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBrowserRefreshAfterFailures = 3
+	defaultBrowserRefreshTimeout       = time.Minute
+	// browserRefreshInterval is the least time between two browser sessions of an account.
+	browserRefreshInterval = 10 * time.Minute
+	// browserRefreshSettle is how long the page may run to rotate the cookies.
+	browserRefreshSettle = 5 * time.Second
+
+	browserRefreshURL = "https://gemini.google.com/app"
+	googleCookieURL   = "https://www.google.com/"
+)
+
+type browserRefreshSettings struct {
+	enabled       bool
+	execPath      string
+	profileDir    string
+	afterFailures int
+	timeout       time.Duration
+}
+
+func browserRefreshSettingsFor(cfg *config.Config) browserRefreshSettings {
+	s := browserRefreshSettings{afterFailures: defaultBrowserRefreshAfterFailures, timeout: defaultBrowserRefreshTimeout}
+	if cfg == nil {
+		return s
+	}
+	b := cfg.GeminiWeb.BrowserRefresh
+	s.enabled = b.Enabled
+	s.execPath = strings.TrimSpace(b.ExecPath)
+	s.profileDir = strings.TrimSpace(b.ProfileDir)
+	if b.AfterFailures > 0 {
+		s.afterFailures = b.AfterFailures
+	}
+	if b.TimeoutSeconds > 0 {
+		s.timeout = time.Duration(b.TimeoutSeconds) * time.Second
+	}
+	return s
+}
+
+// BrowserProfileDir returns the directory holding the per-account browser profiles used to
+// renew cookies.
+func BrowserProfileDir(cfg *config.Config) string {
+	if dir := browserRefreshSettingsFor(cfg).profileDir; dir != "" {
+		return dir
+	}
+	wd, err := os.Getwd()
+	if err != nil || wd == "" {
+		wd = "."
+	}
+	return filepath.Join(wd, "browser-profiles")
+}
+
+// refreshWithBrowser renews the account cookies in a headless browser after rotation failed
+// with cause. Attempts are skipped until rotation failed afterFailures times in a row, unless
+// the cookies were rejected, and run at most once per browserRefreshInterval.
+func (s *GeminiWebState) refreshWithBrowser(ctx context.Context, cause error) error {
+	cfg := s.config()
+	settings := browserRefreshSettingsFor(cfg)
+	s.browserMu.Lock()
+	defer s.browserMu.Unlock()
+	s.rotateFailures++
+	var authErr *AuthError
+	if !settings.enabled || (!errors.As(cause, &authErr) && s.rotateFailures < settings.afterFailures) {
+		return cause
+	}
+	if time.Since(s.lastBrowserRefresh) < browserRefreshInterval {
+		return cause
+	}
+	s.lastBrowserRefresh = time.Now()
+
+	label := s.accountID
+	log.Warnf("gemini web account %s: cookie rotation failed %d time(s) (%v), renewing cookies in a headless browser", label, s.rotateFailures, cause)
+	s.tokenMu.Lock()
+	psid, psidts := s.token.Secure1PSID, s.token.Secure1PSIDTS
+	s.tokenMu.Unlock()
+	proxyURL := s.proxyURL()
+	newPSID, newTS, err := browserSessionCookies(ctx, settings, filepath.Join(BrowserProfileDir(cfg), s.accountID), proxyURL, psid, psidts)
+	if err != nil {
+		log.Warnf("gemini web account %s: browser cookie refresh failed: %v", label, err)
+		return cause
+	}
+
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	client := NewGeminiClient(newPSID, newTS, proxyURL, WithBandwidthAccount(s.bandwidthAccount))
+	if err = client.Init(s.clientTimeoutSec(), false); err != nil {
+		log.Warnf("gemini web account %s: Gemini rejected the browser cookies: %v", label, err)
+		return cause
+	}
+	s.tokenMu.Lock()
+	s.token.Secure1PSID = newPSID
+	s.token.Secure1PSIDTS = newTS
+	s.tokenDirty = true
+	s.tokenMu.Unlock()
+	s.client = client
+	s.clientProxy = proxyURL
+	s.lastRefresh = time.Now()
+	s.rotateFailures = 0
+	log.Infof("gemini web account %s: renewed cookies in a headless browser, 1PSIDTS: %s", label, MaskToken28(newTS))
+	return nil
+}
+
+// browserSessionCookies opens Gemini in a headless browser using the profile in profileDir and
+// returns the __Secure-1PSID and __Secure-1PSIDTS it holds afterwards. The stored cookies are
+// put into the profile unless it has a Google session of its own, e.g. from signing in by hand.
+func browserSessionCookies(ctx context.Context, settings browserRefreshSettings, profileDir, proxyURL, psid, psidts string) (string, string, error) {
+	if err := os.MkdirAll(profileDir, 0o700); err != nil {
+		return "", "", fmt.Errorf("failed to create browser profile: %w", err)
+	}
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.UserDataDir(profileDir))
+	if settings.execPath != "" {
+		opts = append(opts, chromedp.ExecPath(settings.execPath))
+	}
+	if proxyURL != "" {
+		opts = append(opts, chromedp.ProxyServer(proxyURL))
+	}
+	ctx, cancel := context.WithTimeout(ctx, settings.timeout)
+	defer cancel()
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+	defer cancelAlloc()
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+
+	var location string
+	var cookies []*network.Cookie
+	err := chromedp.Run(browserCtx,
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			existing, err := network.GetCookies().WithURLs([]string{googleCookieURL}).Do(ctx)
+			if err != nil || findGoogleCookie(existing, "__Secure-1PSID") != "" {
+				return err
+			}
+			expires := cdp.TimeSinceEpoch(time.Now().AddDate(1, 0, 0))
+			for name, value := range map[string]string{"__Secure-1PSID": psid, "__Secure-1PSIDTS": psidts} {
+				if value == "" {
+					continue
+				}
+				err = network.SetCookie(name, value).WithDomain(".google.com").WithPath("/").
+					WithSecure(true).WithHTTPOnly(true).WithExpires(&expires).Do(ctx)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+		chromedp.Navigate(browserRefreshURL),
+		chromedp.WaitReady("body"),
+		chromedp.Sleep(browserRefreshSettle),
+		chromedp.Location(&location),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			cookies, err = network.GetCookies().WithURLs([]string{googleCookieURL, browserRefreshURL}).Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return "", "", err
+	}
+	if u, errParse := url.Parse(location); errParse == nil && u.Host == "accounts.google.com" {
+		return "", "", errors.New("the browser session is signed out; sign the profile in to Google again")
+	}
+	newPSID, newTS := findGoogleCookie(cookies, "__Secure-1PSID"), findGoogleCookie(cookies, "__Secure-1PSIDTS")
+	if newPSID == "" || newTS == "" {
+		return "", "", errors.New("the browser holds no Google session cookies")
+	}
+	return newPSID, newTS, nil
+}
+
+func findGoogleCookie(cookies []*network.Cookie, name string) string {
+	for _, cookie := range cookies {
+		if cookie != nil && cookie.Name == name && strings.TrimPrefix(cookie.Domain, ".") == "google.com" {
+			return cookie.Value
+		}
+	}
+	return ""
+}
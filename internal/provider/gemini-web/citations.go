@@ -0,0 +1,63 @@
+package geminiwebapi
+
+import (
+	"regexp"
+	"strings"
+)
+
+// reSourceLink matches the markdown links to web pages that Gemini Web puts in answers that
+// cite sources.
+var reSourceLink = regexp.MustCompile(`\[([^\]\n]*)\]\((https?://[^\s)]+)\)`)
+
+// groundingMetadata maps the sources of an answer to the groundingMetadata of a Gemini API
+// candidate: every linked page and web image becomes a grounding chunk, and each link becomes
+// a grounding support covering the link text (byte offsets into text, as in the Gemini API).
+// It returns nil when the answer cites nothing.
+func groundingMetadata(text string, webImages []WebImage) map[string]any {
+	var chunks []any
+	var supports []any
+	index := make(map[string]int)
+	addChunk := func(uri, title string) int {
+		if i, ok := index[uri]; ok {
+			return i
+		}
+		web := map[string]any{"uri": uri}
+		if title != "" {
+			web["title"] = title
+		}
+		index[uri] = len(chunks)
+		chunks = append(chunks, map[string]any{"web": web})
+		return index[uri]
+	}
+
+	for _, loc := range reSourceLink.FindAllStringSubmatchIndex(text, -1) {
+		title := strings.Trim(text[loc[2]:loc[3]], "` ")
+		i := addChunk(text[loc[4]:loc[5]], title)
+		supports = append(supports, map[string]any{
+			"segment": map[string]any{
+				"startIndex": loc[0],
+				"endIndex":   loc[1],
+				"text":       text[loc[0]:loc[1]],
+			},
+			"groundingChunkIndices": []int{i},
+		})
+	}
+	for _, img := range webImages {
+		if img.URL == "" {
+			continue
+		}
+		title := img.Title
+		if title == "" {
+			title = img.Alt
+		}
+		addChunk(img.URL, title)
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+	meta := map[string]any{"groundingChunks": chunks}
+	if len(supports) > 0 {
+		meta["groundingSupports"] = supports
+	}
+	return meta
+}
@@ -1,11 +1,12 @@
 package geminiwebapi
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -13,6 +14,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -25,6 +28,8 @@ type GeminiClient struct {
 	AccessToken string
 	Timeout     time.Duration
 	insecure    bool
+	// bandwidthAccount attributes request traffic to an account for bandwidth accounting.
+	bandwidthAccount string
 }
 
 // HTTP bootstrap utilities -------------------------------------------------
@@ -35,17 +40,8 @@ type httpOptions struct {
 }
 
 func newHTTPClient(opts httpOptions) *http.Client {
-	transport := &http.Transport{}
-	if opts.ProxyURL != "" {
-		if pu, err := url.Parse(opts.ProxyURL); err == nil {
-			transport.Proxy = http.ProxyURL(pu)
-		}
-	}
-	if opts.Insecure {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	}
 	jar, _ := cookiejar.New(nil)
-	client := &http.Client{Transport: transport, Timeout: 60 * time.Second, Jar: jar}
+	client := &http.Client{Transport: sharedTransport(opts.ProxyURL, opts.Insecure), Timeout: 60 * time.Second, Jar: jar}
 	if !opts.FollowRedirects {
 		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
@@ -77,6 +73,10 @@ func sendInitRequest(cookies map[string]string, proxy string, insecure bool) (*h
 	if err != nil {
 		return nil, nil, err
 	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		_ = resp.Body.Close()
+		return nil, nil, &APIError{Msg: resp.Status, Status: resp.StatusCode}
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return resp, nil, &AuthError{Msg: resp.Status}
 	}
@@ -130,12 +130,22 @@ func getAccessToken(baseCookies map[string]string, proxy string, verbose bool, i
 
 	reToken := regexp.MustCompile(`"SNlM0e":"([^"]+)"`)
 
+	// Only report an AuthError when Gemini answered and rejected the cookies, so that network
+	// failures and upstream outages do not mark the account as expired.
+	var transientErr error
+	rejected := false
 	for _, cookies := range trySets {
 		resp, mergedCookies, err := sendInitRequest(cookies, proxy, insecure)
 		if err != nil {
 			if verbose {
 				log.Warnf("Failed init request: %v", err)
 			}
+			var authErr *AuthError
+			if errors.As(err, &authErr) {
+				rejected = true
+			} else {
+				transientErr = err
+			}
 			continue
 		}
 		body, err := io.ReadAll(resp.Body)
@@ -151,6 +161,10 @@ func getAccessToken(baseCookies map[string]string, proxy string, verbose bool, i
 			}
 			return token, mergedCookies, nil
 		}
+		rejected = true
+	}
+	if !rejected && transientErr != nil {
+		return "", nil, transientErr
 	}
 	return "", nil, &AuthError{Msg: "Failed to retrieve token."}
 }
@@ -254,6 +268,11 @@ func WithInsecureTLS(insecure bool) func(*GeminiClient) {
 	return func(c *GeminiClient) { c.insecure = insecure }
 }
 
+// WithBandwidthAccount attributes the client's request traffic to account.
+func WithBandwidthAccount(account string) func(*GeminiClient) {
+	return func(c *GeminiClient) { c.bandwidthAccount = account }
+}
+
 // Init initializes the access token and http client.
 func (c *GeminiClient) Init(timeoutSec float64, verbose bool) error {
 	// get access token
@@ -265,17 +284,10 @@ func (c *GeminiClient) Init(timeoutSec float64, verbose bool) error {
 	c.AccessToken = token
 	c.Cookies = validCookies
 
-	tr := &http.Transport{}
-	if c.Proxy != "" {
-		if pu, errParse := url.Parse(c.Proxy); errParse == nil {
-			tr.Proxy = http.ProxyURL(pu)
-		}
-	}
-	if c.insecure {
-		// set via roundtripper in utils_get_access_token for token; here we reuse via default Transport
-		// intentionally not adding here, as requests rely on endpoints with normal TLS
-	}
-	c.httpClient = &http.Client{Transport: tr, Timeout: time.Duration(timeoutSec * float64(time.Second))}
+	// The insecure setting only applies to the token requests above; the API endpoints are
+	// reached with normal TLS verification.
+	tr := sharedTransport(c.Proxy, false)
+	c.httpClient = &http.Client{Transport: usage.NewBandwidthTransport(tr, constant.GeminiWeb, c.bandwidthAccount), Timeout: time.Duration(timeoutSec * float64(time.Second))}
 	c.Running = true
 
 	c.Timeout = time.Duration(timeoutSec * float64(time.Second))
@@ -292,6 +304,17 @@ func (c *GeminiClient) Close(delaySec float64) {
 	c.Running = false
 }
 
+// ValidateCookies performs the init handshake with the given cookie pair and returns an
+// error when Gemini does not accept it.
+func ValidateCookies(secure1psid, secure1psidts, proxy string) error {
+	client := NewGeminiClient(secure1psid, secure1psidts, proxy)
+	if err := client.Init(float64(geminiWebDefaultTimeoutSec), false); err != nil {
+		return err
+	}
+	client.Close(0)
+	return nil
+}
+
 // ensureRunning mirrors the decorator behavior and retries on APIError.
 func (c *GeminiClient) ensureRunning() error {
 	if c.Running {
@@ -309,7 +332,8 @@ func (c *GeminiClient) RotateTS() (string, error) {
 }
 
 // GenerateContent sends a prompt (with optional files) and parses the response into ModelOutput.
-func (c *GeminiClient) GenerateContent(prompt string, files []string, model Model, gem *Gem, chat *ChatSession) (ModelOutput, error) {
+// A deadline on ctx replaces the client timeout for the request.
+func (c *GeminiClient) GenerateContent(ctx context.Context, prompt string, files []string, model Model, gem *Gem, chat *ChatSession) (ModelOutput, error) {
 	var empty ModelOutput
 	if prompt == "" {
 		return empty, &ValueError{Msg: "Prompt cannot be empty."}
@@ -318,31 +342,8 @@ func (c *GeminiClient) GenerateContent(prompt string, files []string, model Mode
 		return empty, err
 	}
 
-	// Retry wrapper similar to decorator (retry=2)
-	retries := 2
-	for {
-		out, err := c.generateOnce(prompt, files, model, gem, chat)
-		if err == nil {
-			return out, nil
-		}
-		var apiErr *APIError
-		var imgErr *ImageGenerationError
-		shouldRetry := false
-		if errors.As(err, &imgErr) {
-			if retries > 1 {
-				retries = 1
-			} // only once for image generation
-			shouldRetry = true
-		} else if errors.As(err, &apiErr) {
-			shouldRetry = true
-		}
-		if shouldRetry && retries > 0 {
-			time.Sleep(time.Second)
-			retries--
-			continue
-		}
-		return empty, err
-	}
+	// Retries are applied by the caller, see sendMessageWithRetry.
+	return c.generateOnce(ctx, prompt, files, model, gem, chat)
 }
 
 func ensureAnyLen(slice []any, index int) []any {
@@ -353,12 +354,12 @@ func ensureAnyLen(slice []any, index int) []any {
 	return append(slice, make([]any, gap)...)
 }
 
-func (c *GeminiClient) generateOnce(prompt string, files []string, model Model, gem *Gem, chat *ChatSession) (ModelOutput, error) {
+func (c *GeminiClient) generateOnce(ctx context.Context, prompt string, files []string, model Model, gem *Gem, chat *ChatSession) (ModelOutput, error) {
 	var empty ModelOutput
 	// Build f.req
 	var uploaded [][]any
 	for _, fp := range files {
-		id, err := uploadFile(fp, c.Proxy, c.insecure)
+		id, err := uploadFileCached(fp, c.Proxy, c.insecure)
 		if err != nil {
 			return empty, err
 		}
@@ -404,13 +405,20 @@ func (c *GeminiClient) generateOnce(prompt string, files []string, model Model,
 	form.Set("at", c.AccessToken)
 	form.Set("f.req", string(outerJSON))
 
-	req, _ := http.NewRequest(http.MethodPost, EndpointGenerate, strings.NewReader(form.Encode()))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, EndpointGenerate, strings.NewReader(form.Encode()))
 	applyHeaders(req, HeadersGemini)
 	applyHeaders(req, model.ModelHeader)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
 	applyCookies(req, c.Cookies)
 
-	resp, err := c.httpClient.Do(req)
+	httpClient := c.httpClient
+	if _, ok := ctx.Deadline(); ok {
+		// The request timeout may exceed the client timeout, e.g. for deep research models.
+		unbounded := *c.httpClient
+		unbounded.Timeout = 0
+		httpClient = &unbounded
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return empty, &TimeoutError{GeminiError{Msg: "Generate content request timed out."}}
 	}
@@ -425,11 +433,34 @@ func (c *GeminiClient) generateOnce(prompt string, files []string, model Model,
 	}
 	if resp.StatusCode != 200 {
 		c.Close(0)
-		return empty, &APIError{Msg: fmt.Sprintf("Failed to generate contents. Status %d", resp.StatusCode)}
+		return empty, &APIError{Msg: fmt.Sprintf("Failed to generate contents. Status %d", resp.StatusCode), Status: resp.StatusCode}
+	}
+
+	b, errRead := io.ReadAll(resp.Body)
+	output, err := c.parseGenerateResponse(b, model)
+	var netErr net.Error
+	if errRead != nil && (errors.Is(ctx.Err(), context.DeadlineExceeded) || (errors.As(errRead, &netErr) && netErr.Timeout())) {
+		// The reply was cut off by the timeout; callers may still use the part that arrived.
+		timeout := TimeoutError{GeminiError{Msg: "Generate content request timed out."}}
+		if err != nil || strings.TrimSpace(output.Text()) == "" {
+			return empty, &timeout
+		}
+		return empty, &PartialOutputError{TimeoutError: timeout, Output: output}
+	}
+	if err != nil {
+		return empty, err
+	}
+	if chat != nil {
+		chat.lastOutput = &output
 	}
+	return output, nil
+}
 
-	// Read body and split lines; take the 3rd line (index 2)
-	b, _ := io.ReadAll(resp.Body)
+// parseGenerateResponse parses the body of a content generation response.
+func (c *GeminiClient) parseGenerateResponse(b []byte, model Model) (ModelOutput, error) {
+	var empty ModelOutput
+	var err error
+	// Split lines; take the 3rd line (index 2)
 	parts := strings.Split(string(b), "\n")
 	if len(parts) < 3 {
 		c.Close(0)
@@ -737,11 +768,7 @@ func (c *GeminiClient) generateOnce(prompt string, files []string, model Model,
 	if len(candidates) == 0 {
 		return empty, &GeminiError{Msg: "Failed to generate contents. No output data found in response."}
 	}
-	output := ModelOutput{Metadata: metadata, Candidates: candidates, Chosen: 0}
-	if chat != nil {
-		chat.lastOutput = &output
-	}
-	return output, nil
+	return ModelOutput{Metadata: metadata, Candidates: candidates, Chosen: 0}, nil
 }
 
 // extractErrorCode attempts to navigate the known nested error structure and fetch the integer code.
@@ -858,16 +885,21 @@ func (cs *ChatSession) setRCID(v string) {
 }
 
 // SendMessage shortcut to client's GenerateContent
-func (cs *ChatSession) SendMessage(prompt string, files []string) (ModelOutput, error) {
-	out, err := cs.client.GenerateContent(prompt, files, cs.model, cs.gem, cs)
+func (cs *ChatSession) SendMessage(ctx context.Context, prompt string, files []string) (ModelOutput, error) {
+	out, err := cs.client.GenerateContent(ctx, prompt, files, cs.model, cs.gem, cs)
 	if err == nil {
-		cs.lastOutput = &out
-		cs.SetMetadata(out.Metadata)
-		cs.setRCID(out.RCID())
+		cs.accept(out)
 	}
 	return out, err
 }
 
+// accept makes out the reply the session continues from.
+func (cs *ChatSession) accept(out ModelOutput) {
+	cs.lastOutput = &out
+	cs.SetMetadata(out.Metadata)
+	cs.setRCID(out.RCID())
+}
+
 // ChooseCandidate selects a candidate from last output and updates rcid
 func (cs *ChatSession) ChooseCandidate(index int) (ModelOutput, error) {
 	if cs.lastOutput == nil {
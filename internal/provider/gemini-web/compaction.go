@@ -0,0 +1,149 @@
+package geminiwebapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	log "github.com/sirupsen/logrus"
+)
+
+// A history replayed into a new upstream conversation can outgrow what Gemini Web accepts in
+// one prompt. With gemini-web.history-compaction enabled, the turns before the most recent
+// ones are summarized by a cheaper model and sent as a summary block instead. Summaries are
+// cached on the conversation record, keyed by the digest of the messages they cover, and a
+// later compaction only summarizes the messages added since.
+
+const (
+	defaultCompactionKeepRecent = 6
+	defaultCompactionModel      = "gemini-2.5-flash"
+
+	compactionInstruction = "Summarize the conversation below so it can replace the original messages as context for continuing it. " +
+		"Keep every fact, decision, name, number, code identifier, open question and instruction the participants gave. " +
+		"Reply with the summary only."
+)
+
+// compactHistory replaces the older messages of msgs with a summary when the history exceeds
+// the compaction threshold. It returns msgs unchanged when compaction is disabled, not needed
+// or fails, and the summary used otherwise.
+func (s *GeminiWebState) compactHistory(ctx context.Context, msgs []RoleText) ([]RoleText, *HistorySummary) {
+	cfg := s.config()
+	if cfg == nil || !cfg.GeminiWeb.HistoryCompaction.Enabled {
+		return msgs, nil
+	}
+	settings := cfg.GeminiWeb.HistoryCompaction
+	threshold := settings.ThresholdChars
+	if threshold <= 0 {
+		threshold = MaxCharsPerRequest(cfg)
+	}
+	keep := settings.KeepRecent
+	if keep <= 0 {
+		keep = defaultCompactionKeepRecent
+	}
+	if len(msgs) <= keep || historyChars(msgs) <= threshold {
+		return msgs, nil
+	}
+	older := msgs[:len(msgs)-keep]
+	summary := s.cachedSummary(older)
+	if summary == nil || summary.Messages < len(older) {
+		text, err := s.summarize(ctx, settings.Model, summary, older)
+		if err != nil {
+			log.Warnf("gemini web: history compaction failed, sending the full history: %v", err)
+			return msgs, nil
+		}
+		summary = &HistorySummary{Messages: len(older), Digest: historyDigest(older), Text: text}
+	}
+	out := make([]RoleText, 0, keep+1)
+	out = append(out, RoleText{Role: "system", Text: "<conversation_summary>\n" + summary.Text + "\n</conversation_summary>"})
+	out = append(out, cloneRoleTextSlice(msgs[len(msgs)-keep:])...)
+	return out, summary
+}
+
+// cachedSummary returns the stored summary covering the longest prefix of msgs.
+func (s *GeminiWebState) cachedSummary(msgs []RoleText) *HistorySummary {
+	digests := make(map[int]string)
+	s.convMu.RLock()
+	best, bestMessages := "", 0
+	for key, header := range s.convHeaders {
+		n := header.SummaryMessages
+		if n <= 0 || n > len(msgs) || n <= bestMessages {
+			continue
+		}
+		digest, ok := digests[n]
+		if !ok {
+			digest = historyDigest(msgs[:n])
+			digests[n] = digest
+		}
+		if digest == header.SummaryDigest {
+			best, bestMessages = key, n
+		}
+	}
+	s.convMu.RUnlock()
+	if best == "" {
+		return nil
+	}
+	rec, ok := s.conversationRecord(best)
+	if !ok || rec.Summary == nil || rec.Summary.Digest != digests[bestMessages] {
+		return nil
+	}
+	found := *rec.Summary
+	return &found
+}
+
+// summarize asks the compaction model for a summary of msgs, extending prev when it covers
+// a prefix of them. The summarizing conversation is deleted from the account afterwards.
+func (s *GeminiWebState) summarize(ctx context.Context, modelName string, prev *HistorySummary, msgs []RoleText) (string, error) {
+	if strings.TrimSpace(modelName) == "" {
+		modelName = defaultCompactionModel
+	}
+	model, err := ModelFromName(MapAliasToUnderlying(modelName))
+	if err != nil {
+		return "", err
+	}
+	client, err := s.ensureClient()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(compactionInstruction)
+	start := 0
+	if prev != nil {
+		b.WriteString("\n\n<previous_summary>\n")
+		b.WriteString(prev.Text)
+		b.WriteString("\n</previous_summary>")
+		start = prev.Messages
+	}
+	b.WriteString("\n\n")
+	b.WriteString(BuildPrompt(msgs[start:], true, false))
+
+	chat := client.StartChat(model, nil, nil)
+	output, err := SendWithSplit(ctx, chat, b.String(), nil, s.config())
+	if meta := chat.Metadata(); len(meta) > 0 && meta[0] != "" {
+		if errDelete := client.DeleteChat(meta[0]); errDelete != nil {
+			log.Debugf("gemini web: failed to delete history compaction chat: %v", errDelete)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(RemoveThinkTags(output.Text()))
+	if text == "" {
+		return "", errors.New("empty summary")
+	}
+	return text, nil
+}
+
+// historyDigest identifies a run of messages for the summary cache.
+func historyDigest(msgs []RoleText) string {
+	return conversation.HashConversationWithPrefix("summary", "", conversation.ToStoredMessages(msgs))
+}
+
+func historyChars(msgs []RoleText) int {
+	n := 0
+	for _, m := range msgs {
+		n += utf8.RuneCountInString(m.Text)
+	}
+	return n
+}
@@ -0,0 +1,76 @@
+package geminiwebapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+)
+
+// headerContext lets a client override gemini-web.context for one request, also accepted as
+// x_cliproxy.context in the body.
+const headerContext = "X-CLIProxy-Context"
+
+// Values of headerContext. fresh always starts a new upstream conversation; strict requires
+// continuing one and fails the request otherwise, even when context reuse is disabled.
+const (
+	contextModeDefault = ""
+	contextModeFresh   = "fresh"
+	contextModeStrict  = "strict"
+)
+
+// contextModeFrom returns the context mode requested by the client.
+func contextModeFrom(ctx context.Context, original []byte) (string, *interfaces.ErrorMessage) {
+	mode := strings.ToLower(clientLocaleValue(ctx, original, headerContext, "context"))
+	switch mode {
+	case contextModeDefault, contextModeFresh, contextModeStrict:
+		return mode, nil
+	default:
+		return "", &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("bad request: %s must be %q or %q, got %q", headerContext, contextModeFresh, contextModeStrict, mode),
+			Code:       interfaces.ErrorCodeInvalidRequest,
+		}
+	}
+}
+
+// errNoReusableContext fails a strict request on an account holding no conversation to continue.
+// The conflict moves the request on to the next account, which may hold one.
+func errNoReusableContext() *interfaces.ErrorMessage {
+	return &interfaces.ErrorMessage{
+		StatusCode: http.StatusConflict,
+		Error:      errors.New("no upstream conversation continues this history, and " + headerContext + " is strict"),
+		Code:       interfaces.ErrorCodeNoReusableContext,
+	}
+}
+
+// Response headers reporting whether a request continued a conversation, enabled with
+// gemini-web.context-reuse-headers.
+const (
+	headerContextReuse = "X-CLIProxy-Context-Reuse"
+	headerOverlap      = "X-CLIProxy-Overlap"
+	headerAccount      = "X-CLIProxy-Account"
+)
+
+// setReuseHeaders reports the reuse decision of prep on the response: "hit" with the number of
+// incoming messages the continued conversation already held, or "miss", and the account that
+// served the request. A request retried on another account reports the last one.
+func (s *GeminiWebState) setReuseHeaders(ctx context.Context, prep *geminiWebPrepared) {
+	cfg := s.config()
+	if cfg == nil || !cfg.GeminiWeb.ContextReuseHeaders {
+		return
+	}
+	if prep.reuse {
+		requestctx.SetHeader(ctx, headerContextReuse, "hit")
+		requestctx.SetHeader(ctx, headerOverlap, strconv.Itoa(prep.overlap))
+	} else {
+		requestctx.SetHeader(ctx, headerContextReuse, "miss")
+		requestctx.SetHeader(ctx, headerOverlap, "0")
+	}
+	requestctx.SetHeader(ctx, headerAccount, s.Label())
+}
@@ -0,0 +1,63 @@
+package geminiwebapi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+const (
+	// ContextOverflowReject fails requests estimated to exceed the context window.
+	ContextOverflowReject = "reject"
+	// ContextOverflowTruncate drops the oldest messages until the request fits.
+	ContextOverflowTruncate = "truncate"
+)
+
+// contextWindow returns the input token limit of an underlying model, 0 when unknown.
+func contextWindow(model string) int {
+	for _, info := range registry.GetGeminiModels() {
+		if info != nil && strings.EqualFold(info.ID, model) {
+			return info.InputTokenLimit
+		}
+	}
+	return 0
+}
+
+// estimatePromptTokens approximates the tokens of the prompt built from msgs, including a
+// system prompt sent with it.
+func estimatePromptTokens(msgs []RoleText, tagged bool, systemPrompt string) int64 {
+	return usage.EstimateTextTokens(BuildPrompt(msgs, tagged, tagged)) + usage.EstimateTextTokens(systemPrompt)
+}
+
+// fitContextWindow checks msgs against the context window of model before anything is sent.
+// Depending on gemini-web.context-overflow it rejects an oversized request with a 400 error or
+// drops its oldest messages, always keeping the last one.
+func (s *GeminiWebState) fitContextWindow(model string, msgs []RoleText, tagged bool, systemPrompt string) ([]RoleText, *interfaces.ErrorMessage) {
+	limit := int64(contextWindow(model))
+	if limit <= 0 {
+		return msgs, nil
+	}
+	estimate := estimatePromptTokens(msgs, tagged, systemPrompt)
+	if estimate <= limit {
+		return msgs, nil
+	}
+	mode := ""
+	if cfg := s.config(); cfg != nil {
+		mode = strings.ToLower(strings.TrimSpace(cfg.GeminiWeb.ContextOverflow))
+	}
+	if mode == ContextOverflowTruncate {
+		for start := 1; start < len(msgs); start++ {
+			if estimatePromptTokens(msgs[start:], tagged, systemPrompt) <= limit {
+				return cloneRoleTextSlice(msgs[start:]), nil
+			}
+		}
+		estimate = estimatePromptTokens(msgs[len(msgs)-1:], tagged, systemPrompt)
+	}
+	return nil, &interfaces.ErrorMessage{
+		StatusCode: 400,
+		Error:      fmt.Errorf("bad request: prompt is too long: an estimated %d tokens exceed the %d-token context window of %s", estimate, limit, model),
+	}
+}
@@ -7,37 +7,42 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 )
 
-var (
-	aliasOnce sync.Once
-	aliasMap  map[string]string
-)
+var aliasOnce sync.Once
 
-// EnsureGeminiWebAliasMap populates the alias map once.
+// EnsureGeminiWebAliasMap adds the Gemini Web aliases ("<model>-web" and the image preview
+// name) to the registry's built-in aliases once.
 func EnsureGeminiWebAliasMap() {
 	aliasOnce.Do(func() {
-		aliasMap = make(map[string]string)
+		aliases := make(map[string]string)
 		for _, m := range registry.GetGeminiModels() {
 			if m.ID == "gemini-2.5-flash-lite" {
 				continue
 			}
 			if m.ID == "gemini-2.5-flash" {
-				aliasMap["gemini-2.5-flash-image-preview"] = "gemini-2.5-flash"
+				aliases["gemini-2.5-flash-image-preview"] = "gemini-2.5-flash"
 			}
-			alias := AliasFromModelID(m.ID)
-			aliasMap[strings.ToLower(alias)] = strings.ToLower(m.ID)
+			aliases[strings.ToLower(AliasFromModelID(m.ID))] = strings.ToLower(m.ID)
 		}
+		registry.GetGlobalRegistry().AddBuiltinAliases(aliases)
 	})
 }
 
-// MapAliasToUnderlying normalizes a model alias to its underlying identifier.
+// MapAliasToUnderlying normalizes a model alias to its underlying identifier. It resolves
+// through the registry, so configured model-aliases apply before the built-in Gemini Web
+// aliases; a configured alias may point at a "-web" name, which is then resolved in turn.
 func MapAliasToUnderlying(name string) string {
 	EnsureGeminiWebAliasMap()
 	n := strings.ToLower(strings.TrimSpace(name))
 	if n == "" {
 		return n
 	}
-	if u, ok := aliasMap[n]; ok {
-		return u
+	reg := registry.GetGlobalRegistry()
+	for hop := 0; hop < 2; hop++ {
+		target, ok := reg.ResolveAlias(n)
+		if !ok {
+			break
+		}
+		n = strings.ToLower(strings.TrimSpace(target))
 	}
 	const suffix = "-web"
 	if strings.HasSuffix(n, suffix) {
@@ -56,7 +61,8 @@ func NormalizeModel(model string) string {
 	return MapAliasToUnderlying(model)
 }
 
-// GetGeminiWebAliasedModels returns alias metadata for registry exposure.
+// GetGeminiWebAliasedModels returns alias metadata for registry exposure. Each entry records
+// the model it maps to in AliasFor.
 func GetGeminiWebAliasedModels() []*registry.ModelInfo {
 	EnsureGeminiWebAliasMap()
 	aliased := make([]*registry.ModelInfo, 0)
@@ -69,11 +75,14 @@ func GetGeminiWebAliasedModels() []*registry.ModelInfo {
 			cpy.Name = "gemini-2.5-flash-image-preview"
 			cpy.DisplayName = "Nano Banana"
 			cpy.Description = "Gemini 2.5 Flash Preview Image"
+			cpy.AliasFor = MapAliasToUnderlying(cpy.ID)
+			cpy.OutputModalities = []string{"text", "image"}
 			aliased = append(aliased, &cpy)
 		}
 		cpy := *m
 		cpy.ID = AliasFromModelID(m.ID)
 		cpy.Name = cpy.ID
+		cpy.AliasFor = MapAliasToUnderlying(cpy.ID)
 		aliased = append(aliased, &cpy)
 	}
 	return aliased
@@ -18,6 +18,9 @@ type StoredMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
 	Name    string `json:"name,omitempty"`
+	// Digest is set on redacted messages: a salted hash of the original text, of which
+	// Content then holds only a prefix.
+	Digest string `json:"digest,omitempty"`
 }
 
 // Sha256Hex computes SHA-256 hex digest for the specified string.
@@ -46,7 +49,7 @@ func StoredToMessages(msgs []StoredMessage) []Message {
 
 // hashMessage normalizes message data and returns a stable digest.
 func hashMessage(m StoredMessage) string {
-	s := fmt.Sprintf(`{"content":%q,"role":%q}`, m.Content, strings.ToLower(m.Role))
+	s := fmt.Sprintf(`{"content":%q,"role":%q}`, NormalizeText(m.Content), strings.ToLower(m.Role))
 	return Sha256Hex(s)
 }
 
@@ -0,0 +1,166 @@
+package conversation
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Clients that track their own conversations can name them with an X-Conversation-ID header,
+// metadata.conversation_id or, when enabled, the OpenAI user field. The ID maps straight to the
+// upstream conversation it continues, without hash-based prefix matching. Mappings live in the
+// global index file and are scoped to the client API key.
+
+const (
+	bucketConversationIDs = "conversation_ids"
+	// ConversationIDHeader names the request header carrying an explicit conversation ID.
+	ConversationIDHeader = "X-Conversation-ID"
+	maxConversationIDLen = 256
+)
+
+// ErrConversationIDNotFound is returned for unknown conversation ID mappings.
+var ErrConversationIDNotFound = errors.New("conversation id not found")
+
+// ConversationIDRef identifies an explicit conversation ID within the scope of a client key.
+type ConversationIDRef struct {
+	ID    string
+	Owner string
+	// FromUser is set when the ID was taken from the OpenAI user field.
+	FromUser bool
+}
+
+// Key returns the storage key of the reference.
+func (r ConversationIDRef) Key() string {
+	return Sha256Hex(r.Owner + "\x00" + r.ID)[:32]
+}
+
+// ConversationIDRecord maps an explicit conversation ID to an upstream conversation.
+type ConversationIDRecord struct {
+	Key          string   `json:"key"`
+	ID           string   `json:"id"`
+	Owner        string   `json:"owner"`
+	AccountLabel string   `json:"account_label"`
+	Model        string   `json:"model"`
+	Metadata     []string `json:"metadata,omitempty"`
+	// Messages counts the messages the upstream conversation holds, so the next request only
+	// sends the ones after them.
+	Messages  int       `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConversationOwner returns the digest under which a client API key's conversation IDs are kept.
+func ConversationOwner(apiKey string) string {
+	return Sha256Hex(apiKey)[:16]
+}
+
+// ExplicitConversationID returns the conversation ID named by a request: the header value,
+// then metadata.conversation_id, then the OpenAI user field. ok is false when none is set.
+func ExplicitConversationID(header string, raw []byte, apiKey string) (ConversationIDRef, bool) {
+	ref := ConversationIDRef{Owner: ConversationOwner(apiKey)}
+	switch {
+	case strings.TrimSpace(header) != "":
+		ref.ID = strings.TrimSpace(header)
+	case gjson.GetBytes(raw, "metadata.conversation_id").String() != "":
+		ref.ID = strings.TrimSpace(gjson.GetBytes(raw, "metadata.conversation_id").String())
+	case gjson.GetBytes(raw, "user").String() != "":
+		ref.ID = strings.TrimSpace(gjson.GetBytes(raw, "user").String())
+		ref.FromUser = true
+	}
+	if ref.ID == "" || len(ref.ID) > maxConversationIDLen {
+		return ConversationIDRef{}, false
+	}
+	return ref, true
+}
+
+// LookupConversationID returns the mapping stored under key.
+func LookupConversationID(key string) (ConversationIDRecord, bool, error) {
+	db, err := openIndex()
+	if err != nil {
+		return ConversationIDRecord{}, false, err
+	}
+	var rec ConversationIDRecord
+	var found bool
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketConversationIDs))
+		if bucket == nil {
+			return nil
+		}
+		v := bucket.Get([]byte(key))
+		if len(v) == 0 {
+			return nil
+		}
+		if errUnmarshal := json.Unmarshal(v, &rec); errUnmarshal != nil {
+			return errUnmarshal
+		}
+		found = true
+		return nil
+	})
+	return rec, found, err
+}
+
+// StoreConversationID creates or updates the mapping of rec.Key.
+func StoreConversationID(rec ConversationIDRecord) error {
+	if strings.TrimSpace(rec.Key) == "" {
+		return errors.New("gemini-web conversation: empty conversation id key")
+	}
+	db, err := openIndex()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, errBucket := tx.CreateBucketIfNotExists([]byte(bucketConversationIDs))
+		if errBucket != nil {
+			return errBucket
+		}
+		return bucket.Put([]byte(rec.Key), payload)
+	})
+}
+
+// ListConversationIDs returns every mapping, most recently used first.
+func ListConversationIDs() ([]ConversationIDRecord, error) {
+	db, err := openIndex()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ConversationIDRecord, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketConversationIDs))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, v []byte) error {
+			var rec ConversationIDRecord
+			if json.Unmarshal(v, &rec) == nil {
+				out = append(out, rec)
+			}
+			return nil
+		})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out, err
+}
+
+// DeleteConversationID removes the mapping stored under key.
+func DeleteConversationID(key string) error {
+	db, err := openIndex()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketConversationIDs))
+		if bucket == nil || bucket.Get([]byte(key)) == nil {
+			return ErrConversationIDNotFound
+		}
+		return bucket.Delete([]byte(key))
+	})
+}
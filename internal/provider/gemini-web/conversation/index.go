@@ -2,6 +2,7 @@ package conversation
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -73,12 +75,12 @@ func StoreMatch(hash string, record MatchRecord) error {
 	if err != nil {
 		return err
 	}
-	record.UpdatedAt = time.Now().UTC().Unix()
+	record.UpdatedAt = idgen.Now(context.Background()).UTC().Unix()
 	payload, err := json.Marshal(record)
 	if err != nil {
 		return err
 	}
-	return db.Update(func(tx *bolt.Tx) error {
+	err = db.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists([]byte(bucketMatches))
 		if err != nil {
 			return err
@@ -96,6 +98,10 @@ func StoreMatch(hash string, record MatchRecord) error {
 		// We do not know its label; leave it for lookup fallback/cleanup elsewhere.
 		return nil
 	})
+	if err == nil {
+		storeSharedMatch(hash, strings.ToLower(strings.TrimSpace(record.AccountLabel)), payload)
+	}
+	return err
 }
 
 // LookupMatch retrieves a stored mapping.
@@ -161,6 +167,10 @@ func LookupMatch(hash string) (MatchRecord, bool, error) {
 		return MatchRecord{}, false, nil
 	}
 	if strings.TrimSpace(single.AccountLabel) == "" || single.PrefixLen <= 0 {
+		// Another replica may have created the conversation.
+		if rec, ok := lookupSharedMatch(hash); ok {
+			return rec, true, nil
+		}
 		return MatchRecord{}, false, nil
 	}
 	return single, true, nil
@@ -172,7 +182,7 @@ func RemoveMatch(hash string) error {
 	if err != nil {
 		return err
 	}
-	return db.Update(func(tx *bolt.Tx) error {
+	err = db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketMatches))
 		if bucket == nil {
 			return nil
@@ -189,6 +199,8 @@ func RemoveMatch(hash string) error {
 		_ = bucket.Delete([]byte(hash))
 		return nil
 	})
+	removeSharedMatch(hash, "")
+	return err
 }
 
 // RemoveMatchForLabel deletes the mapping for the given hash and label only.
@@ -197,6 +209,7 @@ func RemoveMatchForLabel(hash, label string) error {
 	if strings.TrimSpace(hash) == "" || label == "" {
 		return nil
 	}
+	removeSharedMatch(hash, label)
 	db, err := openIndex()
 	if err != nil {
 		return err
@@ -221,7 +234,8 @@ func RemoveMatchForLabel(hash, label string) error {
 	})
 }
 
-// RemoveMatchesByLabel removes all entries associated with the specified label.
+// RemoveMatchesByLabel removes all entries associated with the specified label. Entries in
+// the shared index are left to expire, since they cannot be enumerated by label cheaply.
 func RemoveMatchesByLabel(label string) error {
 	label = strings.TrimSpace(label)
 	if label == "" {
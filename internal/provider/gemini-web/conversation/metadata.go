@@ -3,4 +3,6 @@ package conversation
 const (
 	MetadataMessagesKey = "gemini_web_messages"
 	MetadataMatchKey    = "gemini_web_match"
+	// MetadataConversationIDKey carries the request's explicit ConversationIDRef.
+	MetadataConversationIDKey = "gemini_web_conversation_id"
 )
@@ -0,0 +1,89 @@
+package conversation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Message text is normalized before it is hashed or compared, so that a history resent with
+// cosmetic changes still matches the conversation it continues. The same normalization applies
+// when conversations are stored and when they are looked up.
+
+// normalizer holds the normalization settings.
+type normalizer struct {
+	trim               bool
+	collapseWhitespace bool
+	stripPatterns      []*regexp.Regexp
+	// fingerprint identifies the settings; empty when nothing is normalized.
+	fingerprint string
+}
+
+var (
+	normalization atomic.Pointer[normalizer]
+	reWhitespace  = regexp.MustCompile(`\s+`)
+)
+
+// ConfigureNormalization applies the normalization settings. Invalid patterns are left out and
+// reported in the returned error; the other settings apply regardless.
+func ConfigureNormalization(trim, collapseWhitespace bool, stripPatterns []string) error {
+	n := &normalizer{trim: trim, collapseWhitespace: collapseWhitespace}
+	var invalid []string
+	for _, pattern := range stripPatterns {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q: %v", pattern, err))
+			continue
+		}
+		n.stripPatterns = append(n.stripPatterns, re)
+	}
+	if n.trim || n.collapseWhitespace || len(n.stripPatterns) > 0 {
+		var b strings.Builder
+		b.WriteString(strconv.FormatBool(n.trim))
+		b.WriteString("|")
+		b.WriteString(strconv.FormatBool(n.collapseWhitespace))
+		for _, re := range n.stripPatterns {
+			b.WriteString("|")
+			b.WriteString(re.String())
+		}
+		n.fingerprint = Sha256Hex(b.String())[:16]
+	}
+	normalization.Store(n)
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid strip patterns: %s", strings.Join(invalid, "; "))
+	}
+	return nil
+}
+
+// NormalizationFingerprint identifies the current normalization settings, so that indexes
+// computed under other settings can be recognised. It is empty when nothing is normalized.
+func NormalizationFingerprint() string {
+	if n := normalization.Load(); n != nil {
+		return n.fingerprint
+	}
+	return ""
+}
+
+// NormalizeText applies the configured normalization to a message text: the strip patterns
+// first, then whitespace collapsing and trimming.
+func NormalizeText(s string) string {
+	n := normalization.Load()
+	if n == nil || n.fingerprint == "" {
+		return s
+	}
+	for _, re := range n.stripPatterns {
+		s = re.ReplaceAllString(s, "")
+	}
+	if n.collapseWhitespace {
+		s = reWhitespace.ReplaceAllString(s, " ")
+	}
+	if n.trim {
+		s = strings.TrimSpace(s)
+	}
+	return s
+}
@@ -25,13 +25,13 @@ func SanitizeAssistantMessages(msgs []Message) []Message {
 	return out
 }
 
-// EqualMessages compares two message slices for equality.
+// EqualMessages compares two message slices for equality after normalizing their text.
 func EqualMessages(a, b []Message) bool {
 	if len(a) != len(b) {
 		return false
 	}
 	for i := range a {
-		if a[i].Role != b[i].Role || a[i].Text != b[i].Text {
+		if a[i].Role != b[i].Role || NormalizeText(a[i].Text) != NormalizeText(b[i].Text) {
 			return false
 		}
 	}
@@ -0,0 +1,81 @@
+package conversation
+
+import (
+	"fmt"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Stored conversation records carry the version of the schema they were written with, so a
+// change to the hashing rules or to how messages are stored can upgrade existing stores in place.
+// Such a change bumps SchemaVersion and appends a migration from the previous version to
+// migrations; stores run the missing migrations when they are loaded.
+
+// SchemaVersion is the version of the records written by this release. Records written before
+// versioning was introduced have no version field and count as version 0.
+const SchemaVersion = 1
+
+// SchemaVersionField names the version field of a stored record.
+const SchemaVersionField = "schema_version"
+
+// Migration upgrades a stored record from version From to From+1.
+type Migration struct {
+	From int
+	// Name describes the change in logs.
+	Name string
+	// Record rewrites a record given as JSON with its messages inline. Nil when the record
+	// layout is unchanged.
+	Record func(raw []byte) ([]byte, error)
+	// Reindex is set when the change alters the hashing rules, so the index entries computed
+	// from the records before it no longer match and have to be rebuilt.
+	Reindex bool
+}
+
+var migrations = []Migration{
+	{From: 0, Name: "add schema version"},
+}
+
+func init() {
+	if len(migrations) != SchemaVersion {
+		panic(fmt.Sprintf("conversation: %d schema migrations for schema version %d", len(migrations), SchemaVersion))
+	}
+	for i, m := range migrations {
+		if m.From != i {
+			panic(fmt.Sprintf("conversation: schema migration %q starts at version %d, want %d", m.Name, m.From, i))
+		}
+	}
+}
+
+// RecordSchemaVersion returns the schema version of a stored record given as JSON.
+func RecordSchemaVersion(raw []byte) int {
+	return int(gjson.GetBytes(raw, SchemaVersionField).Int())
+}
+
+// PendingMigrations returns the migrations that upgrade records of version from to
+// SchemaVersion. It fails for versions newer than this release understands.
+func PendingMigrations(from int) ([]Migration, error) {
+	if from < 0 || from > SchemaVersion {
+		return nil, fmt.Errorf("unsupported conversation schema version %d (this release writes version %d)", from, SchemaVersion)
+	}
+	return migrations[from:], nil
+}
+
+// MigrateRecord upgrades a stored record given as JSON with its messages inline to
+// SchemaVersion and stamps it with that version. Records already current are returned as is.
+func MigrateRecord(raw []byte) ([]byte, error) {
+	version := RecordSchemaVersion(raw)
+	pending, err := PendingMigrations(version)
+	if err != nil || len(pending) == 0 {
+		return raw, err
+	}
+	for _, m := range pending {
+		if m.Record == nil {
+			continue
+		}
+		if raw, err = m.Record(raw); err != nil {
+			return nil, fmt.Errorf("conversation schema migration %q: %w", m.Name, err)
+		}
+	}
+	return sjson.SetBytes(raw, SchemaVersionField, SchemaVersion)
+}
@@ -0,0 +1,180 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSharedKeyPrefix = "cliproxy:gemini-web:"
+	defaultSharedTTL       = 7 * 24 * time.Hour
+	sharedIOTimeout        = 2 * time.Second
+	sharedPoolSize         = 8
+)
+
+// sharedIndex mirrors match records to Redis. Each conversation hash is stored as a Redis
+// hash keyed "<prefix>match:<hash>" whose fields are lowercased account labels, matching
+// the "hash:label" layout of the local bucket.
+type sharedIndex struct {
+	rawURL string
+	prefix string
+	ttl    time.Duration
+	client *redis.Client
+}
+
+var (
+	sharedMu  sync.RWMutex
+	sharedIdx *sharedIndex
+)
+
+// ConfigureSharedIndex enables (or, with an empty URL, disables) the Redis mirror of the
+// match index. Calling it again with unchanged settings keeps the current connection pool.
+func ConfigureSharedIndex(redisURL, keyPrefix string, ttl time.Duration) error {
+	redisURL = strings.TrimSpace(redisURL)
+	if keyPrefix = strings.TrimSpace(keyPrefix); keyPrefix == "" {
+		keyPrefix = defaultSharedKeyPrefix
+	}
+	if ttl <= 0 {
+		ttl = defaultSharedTTL
+	}
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if current := sharedIdx; current != nil {
+		if current.rawURL == redisURL && current.prefix == keyPrefix && current.ttl == ttl {
+			return nil
+		}
+		current.close()
+		sharedIdx = nil
+	}
+	if redisURL == "" {
+		return nil
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return fmt.Errorf("gemini-web shared index: invalid redis url: %w", err)
+	}
+	opts.DialTimeout = sharedIOTimeout
+	opts.ReadTimeout = sharedIOTimeout
+	opts.WriteTimeout = sharedIOTimeout
+	opts.PoolSize = sharedPoolSize
+	sharedIdx = &sharedIndex{rawURL: redisURL, prefix: keyPrefix, ttl: ttl, client: redis.NewClient(opts)}
+	return nil
+}
+
+func currentSharedIndex() *sharedIndex {
+	sharedMu.RLock()
+	defer sharedMu.RUnlock()
+	return sharedIdx
+}
+
+func (s *sharedIndex) key(hash string) string {
+	return s.prefix + "match:" + hash
+}
+
+func (s *sharedIndex) store(hash, label string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*sharedIOTimeout)
+	defer cancel()
+	key := s.key(hash)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, label, string(payload))
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	return err
+}
+
+// lookup returns the records stored for hash, keyed by lowercased label.
+func (s *sharedIndex) lookup(hash string) (map[string]MatchRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*sharedIOTimeout)
+	defer cancel()
+	fields, err := s.client.HGetAll(ctx, s.key(hash)).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]MatchRecord, len(fields))
+	for label, value := range fields {
+		var rec MatchRecord
+		if json.Unmarshal([]byte(value), &rec) != nil {
+			continue
+		}
+		out[label] = rec
+	}
+	return out, nil
+}
+
+func (s *sharedIndex) removeLabel(hash, label string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*sharedIOTimeout)
+	defer cancel()
+	return s.client.HDel(ctx, s.key(hash), label).Err()
+}
+
+func (s *sharedIndex) remove(hash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*sharedIOTimeout)
+	defer cancel()
+	return s.client.Del(ctx, s.key(hash)).Err()
+}
+
+func (s *sharedIndex) close() {
+	if err := s.client.Close(); err != nil {
+		log.Debugf("gemini-web shared index: close failed: %v", err)
+	}
+}
+
+// storeSharedMatch mirrors a local match record; failures only cost cross-replica reuse.
+func storeSharedMatch(hash, label string, payload []byte) {
+	if idx := currentSharedIndex(); idx != nil {
+		if err := idx.store(hash, label, payload); err != nil {
+			log.Debugf("gemini-web shared index: store failed: %v", err)
+		}
+	}
+}
+
+// lookupSharedMatch consults the shared index when the local index has no entry. Like the
+// local lookup, it refuses to answer when the hash is claimed by more than one account.
+func lookupSharedMatch(hash string) (MatchRecord, bool) {
+	idx := currentSharedIndex()
+	if idx == nil {
+		return MatchRecord{}, false
+	}
+	records, err := idx.lookup(hash)
+	if err != nil {
+		log.Debugf("gemini-web shared index: lookup failed: %v", err)
+		return MatchRecord{}, false
+	}
+	var found MatchRecord
+	count := 0
+	for _, rec := range records {
+		if strings.TrimSpace(rec.AccountLabel) == "" || rec.PrefixLen <= 0 {
+			continue
+		}
+		found = rec
+		count++
+	}
+	if count != 1 {
+		return MatchRecord{}, false
+	}
+	return found, true
+}
+
+func removeSharedMatch(hash, label string) {
+	idx := currentSharedIndex()
+	if idx == nil {
+		return
+	}
+	var err error
+	if label == "" {
+		err = idx.remove(hash)
+	} else {
+		err = idx.removeLabel(hash, label)
+	}
+	if err != nil {
+		log.Debugf("gemini-web shared index: remove failed: %v", err)
+	}
+}
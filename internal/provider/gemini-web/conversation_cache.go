@@ -0,0 +1,433 @@
+package geminiwebapi
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Conversation records are loaded lazily. An account keeps the lookup index and a small header
+// per record in memory and reads message bodies from its store when a record is looked up; the
+// records read most recently stay in an LRU cache sized by gemini-web.conversation-cache-size.
+// Changes are written to the store record by record instead of rewriting it.
+
+const (
+	defaultConversationCacheSize = 256
+	// conversationScanBatch bounds the records read from the store at once by scans.
+	conversationScanBatch = 256
+)
+
+// errConversationUnreadable is returned when a record is indexed but cannot be read from the
+// store, for example because its message blobs are missing.
+var errConversationUnreadable = errors.New("conversation record could not be read from the store")
+
+// conversationHeader is the part of a stored record kept in memory for every conversation.
+type conversationHeader struct {
+	Model     string
+	Metadata  []string
+	Messages  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// SummaryMessages and SummaryDigest identify the cached history summary, if any.
+	SummaryMessages int
+	SummaryDigest   string
+}
+
+func newConversationHeader(rec ConversationRecord) conversationHeader {
+	header := conversationHeader{
+		Model:     rec.Model,
+		Metadata:  cloneStringSlice(rec.Metadata),
+		Messages:  len(rec.Messages),
+		CreatedAt: rec.CreatedAt,
+		UpdatedAt: rec.UpdatedAt,
+	}
+	if rec.Summary != nil {
+		header.SummaryMessages = rec.Summary.Messages
+		header.SummaryDigest = rec.Summary.Digest
+	}
+	return header
+}
+
+func (h conversationHeader) summary(id string) ConversationSummary {
+	return ConversationSummary{ID: id, Model: h.Model, Messages: h.Messages, CreatedAt: h.CreatedAt, UpdatedAt: h.UpdatedAt}
+}
+
+// conversationID returns the Gemini conversation ID the record continues, or "".
+func (h conversationHeader) conversationID() string {
+	if len(h.Metadata) == 0 {
+		return ""
+	}
+	return h.Metadata[0]
+}
+
+// storedConversationHeader decodes the header fields of a storedConversationRecord without
+// its message bodies.
+type storedConversationHeader struct {
+	Model       string            `json:"model"`
+	Metadata    []string          `json:"metadata"`
+	Messages    []json.RawMessage `json:"messages"`
+	MessageRefs []string          `json:"message_refs"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Summary     *struct {
+		Messages int    `json:"messages"`
+		Digest   string `json:"digest"`
+	} `json:"summary"`
+}
+
+type conversationCacheEntry struct {
+	key string
+	rec ConversationRecord
+}
+
+// conversationCache is an LRU of full conversation records.
+type conversationCache struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newConversationCache() *conversationCache {
+	return &conversationCache{order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *conversationCache) get(key string) (ConversationRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return ConversationRecord{}, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*conversationCacheEntry).rec, true
+}
+
+func (c *conversationCache) put(key string, rec ConversationRecord, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*conversationCacheEntry).rec = rec
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&conversationCacheEntry{key: key, rec: rec})
+	for c.order.Len() > maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*conversationCacheEntry).key)
+	}
+}
+
+func (c *conversationCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// conversationCacheSize returns how many full records the account keeps in memory.
+func (s *GeminiWebState) conversationCacheSize() int {
+	if cfg := s.config(); cfg != nil && cfg.GeminiWeb.ConversationCacheSize > 0 {
+		return cfg.GeminiWeb.ConversationCacheSize
+	}
+	return defaultConversationCacheSize
+}
+
+// conversationRecord returns record key, reading it from the store when it is not cached.
+func (s *GeminiWebState) conversationRecord(key string) (ConversationRecord, bool) {
+	rec, ok := s.loadConversations([]string{key}, true)[key]
+	return rec, ok
+}
+
+// loadConversations returns the records of keys that exist, reading those that are not in
+// memory from the store in one pass. With keep set, the records read are added to the cache;
+// scans leave it alone so they do not evict the records in use.
+func (s *GeminiWebState) loadConversations(keys []string, keep bool) map[string]ConversationRecord {
+	out := make(map[string]ConversationRecord, len(keys))
+	var missing []string
+	s.convMu.RLock()
+	for _, key := range keys {
+		if _, ok := s.convHeaders[key]; !ok {
+			continue
+		}
+		if rec, ok := s.convUnsaved[key]; ok {
+			out[key] = rec
+			continue
+		}
+		if rec, ok := s.convCache.get(key); ok {
+			out[key] = rec
+			continue
+		}
+		missing = append(missing, key)
+	}
+	s.convMu.RUnlock()
+	if len(missing) == 0 {
+		return out
+	}
+	loaded, err := loadConvRecords(s.convPath(), missing)
+	if err != nil {
+		log.Warnf("gemini web: failed to read conversations of %s: %v", s.Label(), err)
+	}
+	size := s.conversationCacheSize()
+	s.convMu.RLock()
+	defer s.convMu.RUnlock()
+	for key, rec := range loaded {
+		header, ok := s.convHeaders[key]
+		if !ok {
+			// Deleted while it was read.
+			continue
+		}
+		if current, unsaved := s.convUnsaved[key]; unsaved {
+			out[key] = current
+			continue
+		}
+		out[key] = rec
+		// A record replaced while it was read is left for the next lookup to cache.
+		if keep && header.UpdatedAt.Equal(rec.UpdatedAt) {
+			s.convCache.put(key, rec, size)
+		}
+	}
+	return out
+}
+
+// scanConversations calls fn for the records of keys in order, reading them from the store
+// in batches, until fn returns false. Records that cannot be read are skipped.
+func (s *GeminiWebState) scanConversations(keys []string, fn func(key string, rec ConversationRecord) bool) {
+	for start := 0; start < len(keys); start += conversationScanBatch {
+		batch := keys[start:min(start+conversationScanBatch, len(keys))]
+		records := s.loadConversations(batch, false)
+		for _, key := range batch {
+			rec, ok := records[key]
+			if !ok {
+				continue
+			}
+			if !fn(key, rec) {
+				return
+			}
+		}
+	}
+}
+
+// addConversationLocked records rec under key together with its index entries, to be written
+// by the next flushConversations. convMu must be held for writing.
+func (s *GeminiWebState) addConversationLocked(key string, rec ConversationRecord, index map[string]string) {
+	s.convHeaders[key] = newConversationHeader(rec)
+	s.convUnsaved[key] = rec
+	s.convCache.remove(key)
+	for indexKey, target := range index {
+		s.convIndex[indexKey] = target
+		s.convUnsavedIndex[indexKey] = target
+	}
+}
+
+// removeConversationLocked forgets record key and returns the index keys that resolved to it.
+// convMu must be held for writing.
+func (s *GeminiWebState) removeConversationLocked(key string) []string {
+	var indexKeys []string
+	for indexKey, target := range s.convIndex {
+		if target == key {
+			indexKeys = append(indexKeys, indexKey)
+			delete(s.convIndex, indexKey)
+			delete(s.convUnsavedIndex, indexKey)
+		}
+	}
+	delete(s.convHeaders, key)
+	delete(s.convUnsaved, key)
+	s.convCache.remove(key)
+	return indexKeys
+}
+
+// flushConversations removes the records deleted and the index keys deleteIndex from the store
+// and writes the records and index entries not saved yet. convSaveMu must be held, so that the
+// store sees changes in the order they were made in memory.
+func (s *GeminiWebState) flushConversations(deleted, deleteIndex []string) error {
+	s.convMu.RLock()
+	items := make(map[string]ConversationRecord, len(s.convUnsaved))
+	for key, rec := range s.convUnsaved {
+		items[key] = rec
+	}
+	index := make(map[string]string, len(s.convUnsavedIndex))
+	for key, target := range s.convUnsavedIndex {
+		index[key] = target
+	}
+	s.convMu.RUnlock()
+	if err := writeConvRecords(s.convPath(), items, index, deleted, deleteIndex); err != nil {
+		return err
+	}
+	size := s.conversationCacheSize()
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
+	for key, rec := range items {
+		if current, ok := s.convUnsaved[key]; ok && current.UpdatedAt.Equal(rec.UpdatedAt) {
+			delete(s.convUnsaved, key)
+			s.convCache.put(key, rec, size)
+		}
+	}
+	for key, target := range index {
+		if s.convUnsavedIndex[key] == target {
+			delete(s.convUnsavedIndex, key)
+		}
+	}
+	return nil
+}
+
+// loadConvHeaders reads the record headers and the index of the store at path, without the
+// message bodies.
+func loadConvHeaders(path string) (map[string]conversationHeader, map[string]string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	headers := map[string]conversationHeader{}
+	index := map[string]string{}
+	err = db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte("conv_items")); b != nil {
+			if e := b.ForEach(func(k, v []byte) error {
+				var stored storedConversationHeader
+				if len(v) == 0 || json.Unmarshal(v, &stored) != nil {
+					// Skipped on load as well.
+					return nil
+				}
+				header := conversationHeader{
+					Model:     stored.Model,
+					Metadata:  stored.Metadata,
+					Messages:  len(stored.Messages),
+					CreatedAt: stored.CreatedAt,
+					UpdatedAt: stored.UpdatedAt,
+				}
+				if header.Messages == 0 {
+					header.Messages = len(stored.MessageRefs)
+				}
+				if stored.Summary != nil {
+					header.SummaryMessages = stored.Summary.Messages
+					header.SummaryDigest = stored.Summary.Digest
+				}
+				headers[string(k)] = header
+				return nil
+			}); e != nil {
+				return e
+			}
+		}
+		if b := tx.Bucket([]byte("conv_index")); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				index[string(k)] = string(v)
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return headers, index, nil
+}
+
+// loadConvRecords reads the records of keys from the store at path. Keys without a record are
+// left out, as are records whose message blobs are missing.
+func loadConvRecords(path string, keys []string) (map[string]ConversationRecord, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]storedConversationRecord, len(keys))
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("conv_items"))
+		if b == nil {
+			return nil
+		}
+		for _, key := range keys {
+			v := b.Get([]byte(key))
+			var rec storedConversationRecord
+			if len(v) == 0 || json.Unmarshal(v, &rec) != nil {
+				continue
+			}
+			stored[key] = rec
+		}
+		return nil
+	})
+	_ = db.Close()
+	if err != nil {
+		return nil, err
+	}
+	items, missing, err := resolveConversationRecords(path, stored)
+	if err != nil {
+		return nil, err
+	}
+	if missing > 0 {
+		return items, fmt.Errorf("%d conversations reference missing message blobs", missing)
+	}
+	return items, nil
+}
+
+// writeConvRecords removes the records deleted and the index keys deleteIndex from the store at
+// path, then stores items and the index entries of index, in one transaction.
+func writeConvRecords(path string, items map[string]ConversationRecord, index map[string]string, deleted, deleteIndex []string) error {
+	if len(items) == 0 && len(index) == 0 && len(deleted) == 0 && len(deleteIndex) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Message bodies go to the shared blob DB first so records never reference missing blobs.
+	convBlobGCMu.RLock()
+	defer convBlobGCMu.RUnlock()
+	stored := encodeConversationRecords(path, items)
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.Update(func(tx *bolt.Tx) error {
+		bi, errItems := tx.CreateBucketIfNotExists([]byte("conv_items"))
+		if errItems != nil {
+			return errItems
+		}
+		bx, errIndex := tx.CreateBucketIfNotExists([]byte("conv_index"))
+		if errIndex != nil {
+			return errIndex
+		}
+		for _, key := range deleted {
+			if e := bi.Delete([]byte(key)); e != nil {
+				return e
+			}
+		}
+		for _, key := range deleteIndex {
+			if e := bx.Delete([]byte(key)); e != nil {
+				return e
+			}
+		}
+		for key, rec := range stored {
+			enc, e := json.Marshal(rec)
+			if e != nil {
+				return e
+			}
+			if e = bi.Put([]byte(key), enc); e != nil {
+				return e
+			}
+		}
+		for key, target := range index {
+			if e := bx.Put([]byte(key), []byte(target)); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+}
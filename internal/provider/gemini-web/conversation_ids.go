@@ -0,0 +1,81 @@
+package geminiwebapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	log "github.com/sirupsen/logrus"
+)
+
+type conversationIDKey struct{}
+
+// WithConversationID attaches the explicit conversation ID of a request to ctx.
+func WithConversationID(ctx context.Context, ref *conversation.ConversationIDRef) context.Context {
+	if ref == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, conversationIDKey{}, ref)
+}
+
+func conversationIDFrom(ctx context.Context) *conversation.ConversationIDRef {
+	if ctx == nil {
+		return nil
+	}
+	ref, _ := ctx.Value(conversationIDKey{}).(*conversation.ConversationIDRef)
+	return ref
+}
+
+// explicitConversation returns the explicit conversation ID honoured for the request and the
+// reuse plan of the upstream conversation it maps to. The plan is nil for an unknown ID, or one
+// mapped to another account or model, which then starts a new upstream conversation. IDs taken
+// from the OpenAI user field are only honoured with gemini-web.conversation-id-from-user.
+func (s *GeminiWebState) explicitConversation(ctx context.Context, model string, msgs []RoleText) (*conversation.ConversationIDRef, *reuseComputation) {
+	ref := conversationIDFrom(ctx)
+	if ref == nil {
+		return nil, nil
+	}
+	if cfg := s.config(); ref.FromUser && (cfg == nil || !cfg.GeminiWeb.ConversationIDFromUser) {
+		return nil, nil
+	}
+	rec, found, err := conversation.LookupConversationID(ref.Key())
+	if err != nil {
+		log.Debugf("gemini web: failed to look up conversation id: %v", err)
+		return ref, nil
+	}
+	if !found || len(rec.Metadata) == 0 || len(msgs) == 0 ||
+		!strings.EqualFold(rec.AccountLabel, s.conversationLabel()) || !strings.EqualFold(rec.Model, model) {
+		return ref, nil
+	}
+	overlap := rec.Messages
+	if overlap >= len(msgs) {
+		overlap = len(msgs) - 1
+	}
+	return ref, &reuseComputation{
+		metadata: cloneStringSlice(rec.Metadata),
+		history:  cloneRoleTextSlice(msgs[:overlap]),
+		overlap:  overlap,
+	}
+}
+
+// storeConversationID maps an explicit conversation ID to the upstream conversation that
+// answered it.
+func (s *GeminiWebState) storeConversationID(ctx context.Context, ref *conversation.ConversationIDRef, model string, metadata []string, messages int) {
+	if len(metadata) == 0 {
+		return
+	}
+	now := idgen.Now(ctx).UTC()
+	rec, found, err := conversation.LookupConversationID(ref.Key())
+	if err != nil || !found {
+		rec = conversation.ConversationIDRecord{Key: ref.Key(), ID: ref.ID, Owner: ref.Owner, CreatedAt: now}
+	}
+	rec.AccountLabel = s.conversationLabel()
+	rec.Model = model
+	rec.Metadata = cloneStringSlice(metadata)
+	rec.Messages = messages
+	rec.UpdatedAt = now
+	if err = conversation.StoreConversationID(rec); err != nil {
+		log.Debugf("gemini web: failed to store conversation id: %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package geminiwebapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Every record carries the schema version it was written with. A store also remembers the
+// version all of its records were upgraded to, so loading a current store skips the record scan.
+// The upgrade goes record by record and is safe to interrupt: records already migrated carry the
+// new version and are skipped on the next load.
+
+const (
+	bucketConvSchema = "conv_schema"
+	convSchemaKey    = "version"
+)
+
+// ConvMigrationResult summarises the schema upgrade of one conversation store.
+type ConvMigrationResult struct {
+	// From is the oldest record version found.
+	From int
+	// Records and Deleted count the migrated records and trashed records.
+	Records int
+	Deleted int
+	// Skipped counts the records left behind because their message blobs are missing.
+	Skipped int
+	// Reindexed is set when a migration changed the hashing rules and the index was rebuilt.
+	Reindexed bool
+}
+
+// migrateConvStore upgrades the records of the store at path to conversation.SchemaVersion,
+// rebuilding the index with accountID when a migration requires it.
+func migrateConvStore(path, accountID string) (ConvMigrationResult, error) {
+	var result ConvMigrationResult
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	scan, err := scanConvSchema(path)
+	if err != nil || scan.stored == conversation.SchemaVersion {
+		return result, err
+	}
+	from := scan.oldest
+	if scan.stored >= 0 {
+		// An upgrade interrupted after the records were migrated still owes the index rebuild.
+		from = min(from, scan.stored)
+	} else if err = setConvSchemaVersion(path, from); err != nil {
+		return result, err
+	}
+	result.From = from
+	pending, err := conversation.PendingMigrations(from)
+	if err != nil {
+		return result, err
+	}
+	for start := 0; start < len(scan.items); start += convSalvageBatch {
+		migrated, skipped, errBatch := migrateConvRecords(path, scan.items[start:min(start+convSalvageBatch, len(scan.items))])
+		result.Records += migrated
+		result.Skipped += skipped
+		if errBatch != nil {
+			return result, errBatch
+		}
+	}
+	if len(scan.trashed) > 0 {
+		if result.Deleted, err = migrateDeletedConversations(path, scan.trashed); err != nil {
+			return result, err
+		}
+	}
+	for _, m := range pending {
+		if m.Reindex {
+			if _, err = reindexConvStore(path, accountID); err != nil {
+				return result, err
+			}
+			result.Reindexed = true
+			break
+		}
+	}
+	if result.Skipped > 0 {
+		// The store version stays behind so the records are retried on the next load.
+		return result, nil
+	}
+	return result, setConvSchemaVersion(path, conversation.SchemaVersion)
+}
+
+// convSchemaScan describes the schema versions found in a conversation store.
+type convSchemaScan struct {
+	// stored is the version the store was upgraded to, or -1 when it has none.
+	stored int
+	// oldest is the oldest record version, conversation.SchemaVersion when all are current.
+	oldest int
+	// items and trashed are the keys of the records and trashed records to upgrade.
+	items   []string
+	trashed []string
+}
+
+// scanConvSchema reads the schema versions of the store at path. The records of a store
+// marked current are not read.
+func scanConvSchema(path string) (convSchemaScan, error) {
+	scan := convSchemaScan{stored: -1, oldest: conversation.SchemaVersion}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+	if err != nil {
+		return scan, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	err = db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucketConvSchema)); b != nil {
+			if stored, errParse := strconv.Atoi(string(b.Get([]byte(convSchemaKey)))); errParse == nil {
+				scan.stored = stored
+			}
+			if scan.stored > conversation.SchemaVersion {
+				return fmt.Errorf("conversation store uses schema version %d, newer than version %d written by this release", scan.stored, conversation.SchemaVersion)
+			}
+			if scan.stored == conversation.SchemaVersion {
+				return nil
+			}
+		}
+		collect := func(bucket, field string, keys *[]string) error {
+			b := tx.Bucket([]byte(bucket))
+			if b == nil {
+				return nil
+			}
+			return b.ForEach(func(k, v []byte) error {
+				version := int(gjson.GetBytes(v, field).Int())
+				if version < conversation.SchemaVersion {
+					*keys = append(*keys, string(k))
+					scan.oldest = min(scan.oldest, version)
+				}
+				return nil
+			})
+		}
+		if errItems := collect("conv_items", conversation.SchemaVersionField, &scan.items); errItems != nil {
+			return errItems
+		}
+		return collect(bucketConvTrash, "record."+conversation.SchemaVersionField, &scan.trashed)
+	})
+	return scan, err
+}
+
+// migrateConvRecords upgrades the records of keys with their messages resolved from the blob
+// store, and writes them back. Records whose blobs are missing are skipped.
+func migrateConvRecords(path string, keys []string) (int, int, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+	if err != nil {
+		return 0, 0, err
+	}
+	stored := make(map[string]storedConversationRecord, len(keys))
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("conv_items"))
+		if b == nil {
+			return nil
+		}
+		for _, key := range keys {
+			var rec storedConversationRecord
+			if v := b.Get([]byte(key)); len(v) > 0 && json.Unmarshal(v, &rec) == nil {
+				stored[key] = rec
+			}
+		}
+		return nil
+	})
+	_ = db.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+	items, skipped, err := resolveConversationRecords(path, stored)
+	if err != nil {
+		return 0, 0, err
+	}
+	migrated := make(map[string]ConversationRecord, len(items))
+	for key, rec := range items {
+		if migrated[key], err = migrateConversationRecord(rec); err != nil {
+			return 0, skipped, fmt.Errorf("record %s: %w", key, err)
+		}
+	}
+	if err = writeConvRecords(path, migrated, nil, nil, nil); err != nil {
+		return 0, skipped, err
+	}
+	return len(migrated), skipped, nil
+}
+
+// migrateDeletedConversations upgrades the trashed records of keys, which are stored inline.
+func migrateDeletedConversations(path string, keys []string) (int, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	migrated := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketConvTrash))
+		if b == nil {
+			return nil
+		}
+		for _, key := range keys {
+			v := b.Get([]byte(key))
+			record := gjson.GetBytes(v, "record")
+			if !record.IsObject() {
+				continue
+			}
+			upgraded, errMigrate := conversation.MigrateRecord([]byte(record.Raw))
+			if errMigrate != nil {
+				return fmt.Errorf("deleted record %s: %w", key, errMigrate)
+			}
+			updated, errSet := sjson.SetRawBytes(v, "record", upgraded)
+			if errSet != nil {
+				return errSet
+			}
+			if errPut := b.Put([]byte(key), updated); errPut != nil {
+				return errPut
+			}
+			migrated++
+		}
+		return nil
+	})
+	return migrated, err
+}
+
+// migrateConversationRecord upgrades rec, given with its messages inline, to
+// conversation.SchemaVersion.
+func migrateConversationRecord(rec ConversationRecord) (ConversationRecord, error) {
+	if rec.SchemaVersion == conversation.SchemaVersion {
+		return rec, nil
+	}
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return rec, err
+	}
+	if raw, err = conversation.MigrateRecord(raw); err != nil {
+		return rec, err
+	}
+	var upgraded ConversationRecord
+	if err = json.Unmarshal(raw, &upgraded); err != nil {
+		return rec, err
+	}
+	return upgraded, nil
+}
+
+func setConvSchemaVersion(path string, version int) error {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, errCreate := tx.CreateBucketIfNotExists([]byte(bucketConvSchema))
+		if errCreate != nil {
+			return errCreate
+		}
+		return b.Put([]byte(convSchemaKey), []byte(strconv.Itoa(version)))
+	})
+}
+
+// upgradeConvStore runs migrateConvStore for an account loading its store and logs the outcome.
+func upgradeConvStore(path, accountID string) {
+	result, err := migrateConvStore(path, accountID)
+	if err != nil {
+		log.Warnf("gemini web: failed to upgrade conversation store %s to schema version %d: %v", filepath.Base(path), conversation.SchemaVersion, err)
+		return
+	}
+	if result.Records > 0 || result.Deleted > 0 || result.Reindexed {
+		log.Infof("gemini web: upgraded %d conversations and %d deleted conversations of %s from schema version %d to %d",
+			result.Records, result.Deleted, filepath.Base(path), result.From, conversation.SchemaVersion)
+	}
+	if result.Skipped > 0 {
+		log.Warnf("gemini web: %d conversations of %s reference missing message blobs and keep schema version %d",
+			result.Skipped, filepath.Base(path), result.From)
+	}
+}
@@ -0,0 +1,128 @@
+package geminiwebapi
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+)
+
+// ConversationQuery filters SearchConversations. Zero fields match every record.
+type ConversationQuery struct {
+	Model string
+	// Since and Until bound the time of the record's last update.
+	Since time.Time
+	Until time.Time
+	// Text is matched case-insensitively against the content of every message.
+	Text string
+}
+
+// ConversationMatch is one prefix hash a record is stored under in the global match index,
+// with the account the index currently routes it to.
+type ConversationMatch struct {
+	Hash      string `json:"hash"`
+	PrefixLen int    `json:"prefix_len"`
+	// AccountLabel is empty when the prefix is not indexed or claimed by several accounts.
+	AccountLabel string `json:"account_label,omitempty"`
+	// Current is set when the prefix routes to this account and this record's conversation.
+	Current bool `json:"current"`
+}
+
+// ConversationDetail is the full view of a conversation record.
+type ConversationDetail struct {
+	ConversationSummary
+	ClientID  string              `json:"client_id,omitempty"`
+	Metadata  []string            `json:"metadata,omitempty"`
+	History   []StoredMessage     `json:"history"`
+	Summary   *HistorySummary     `json:"summary,omitempty"`
+	IndexKeys []string            `json:"index_keys,omitempty"`
+	Matches   []ConversationMatch `json:"matches"`
+}
+
+// SearchConversations returns the records matching q, most recently updated first.
+func (s *GeminiWebState) SearchConversations(q ConversationQuery) []ConversationSummary {
+	text := strings.ToLower(strings.TrimSpace(q.Text))
+	s.convMu.RLock()
+	out := make([]ConversationSummary, 0)
+	for id, header := range s.convHeaders {
+		if q.Model != "" && !strings.EqualFold(header.Model, q.Model) {
+			continue
+		}
+		if !q.Since.IsZero() && header.UpdatedAt.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && header.UpdatedAt.After(q.Until) {
+			continue
+		}
+		out = append(out, header.summary(id))
+	}
+	s.convMu.RUnlock()
+	if text != "" {
+		// Only the records left after the other filters are read for their messages.
+		ids := make([]string, len(out))
+		for i, summary := range out {
+			ids[i] = summary.ID
+		}
+		out = out[:0]
+		s.scanConversations(ids, func(id string, rec ConversationRecord) bool {
+			if conversationContains(rec, text) {
+				out = append(out, summarizeConversation(id, rec))
+			}
+			return true
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out
+}
+
+// InspectConversation returns record id with its message history, the local index keys that
+// resolve to it and the state of its prefixes in the global match index, which explains why a
+// request did or did not reuse the conversation.
+func (s *GeminiWebState) InspectConversation(id string) (ConversationDetail, error) {
+	if !s.HasConversation(id) {
+		return ConversationDetail{}, ErrConversationNotFound
+	}
+	rec, ok := s.conversationRecord(id)
+	if !ok {
+		return ConversationDetail{}, errConversationUnreadable
+	}
+	detail := ConversationDetail{
+		ConversationSummary: summarizeConversation(id, rec),
+		ClientID:            rec.ClientID,
+		Metadata:            cloneStringSlice(rec.Metadata),
+		History:             append([]StoredMessage(nil), rec.Messages...),
+		Summary:             rec.Summary,
+		Matches:             make([]ConversationMatch, 0),
+	}
+	s.convMu.RLock()
+	for key, hash := range s.convIndex {
+		if hash == id {
+			detail.IndexKeys = append(detail.IndexKeys, key)
+		}
+	}
+	s.convMu.RUnlock()
+	sort.Strings(detail.IndexKeys)
+
+	label := s.conversationLabel()
+	for _, h := range conversation.BuildStorageHashes(rec.Model, conversation.StoredToMessages(rec.Messages)) {
+		match := ConversationMatch{Hash: h.Hash, PrefixLen: h.PrefixLen}
+		if found, ok, err := conversation.LookupMatch(h.Hash); err == nil && ok {
+			match.AccountLabel = found.AccountLabel
+			match.Current = strings.EqualFold(found.AccountLabel, label) &&
+				len(found.Metadata) > 0 && len(rec.Metadata) > 0 && found.Metadata[0] == rec.Metadata[0]
+		}
+		detail.Matches = append(detail.Matches, match)
+	}
+	return detail, nil
+}
+
+// conversationContains reports whether any message of rec contains the lower-cased text.
+func conversationContains(rec ConversationRecord, text string) bool {
+	for _, msg := range rec.Messages {
+		if strings.Contains(strings.ToLower(msg.Content), text) {
+			return true
+		}
+	}
+	return false
+}
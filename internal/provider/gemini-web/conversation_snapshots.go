@@ -0,0 +1,144 @@
+package geminiwebapi
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Every reply stores a new conversation record keyed by the full history, so the records of
+// one upstream conversation (same Gemini conversation ID) are its versions, one per turn. Each
+// version keeps the reply IDs of its last turn, which lets a rolled-back conversation continue
+// upstream from that reply.
+
+// ErrSnapshotNotFound is returned when a conversation has no version for the requested turn.
+var ErrSnapshotNotFound = errors.New("conversation snapshot not found")
+
+// ConversationSnapshot is one recorded version of an upstream conversation.
+type ConversationSnapshot struct {
+	ConversationSummary
+	// Turn counts the assistant replies in this version.
+	Turn int `json:"turn"`
+}
+
+// ConversationSnapshots lists the versions of the upstream conversation that record id belongs
+// to, oldest first.
+func (s *GeminiWebState) ConversationSnapshots(id string) ([]ConversationSnapshot, error) {
+	s.convMu.RLock()
+	header, ok := s.convHeaders[id]
+	versions := s.versionIDsLocked(id, header)
+	s.convMu.RUnlock()
+	if !ok {
+		return nil, ErrConversationNotFound
+	}
+	return s.snapshots(versions), nil
+}
+
+// RestoreConversationTurn rolls the upstream conversation of record id back to turn. Later
+// versions are deleted like DeleteConversation, so they stop being reused, and the account's
+// last conversation points at the restored version. A version without upstream metadata is
+// deleted as well: the next request then replays its history into a new upstream conversation,
+// which replay reports.
+func (s *GeminiWebState) RestoreConversationTurn(ctx context.Context, id string, turn int, by string, permanent bool) (snapshot ConversationSnapshot, replay bool, err error) {
+	s.convMu.Lock()
+	if err = s.ensureConvLoadedLocked(); err != nil {
+		s.convMu.Unlock()
+		return ConversationSnapshot{}, false, err
+	}
+	header, ok := s.convHeaders[id]
+	versions := s.versionIDsLocked(id, header)
+	s.convMu.Unlock()
+	if !ok {
+		return ConversationSnapshot{}, false, ErrConversationNotFound
+	}
+	snapshots := s.snapshots(versions)
+	target := -1
+	for i, snap := range snapshots {
+		if snap.Turn == turn {
+			target = i
+		}
+	}
+	if target < 0 {
+		return ConversationSnapshot{}, false, ErrSnapshotNotFound
+	}
+	snapshot = snapshots[target]
+	s.convMu.Lock()
+	restored, ok := s.convHeaders[snapshot.ID]
+	if !ok {
+		s.convMu.Unlock()
+		return ConversationSnapshot{}, false, ErrConversationNotFound
+	}
+	replay = len(restored.Metadata) == 0
+	drop := make([]string, 0, len(snapshots)-target)
+	for _, snap := range snapshots[target+1:] {
+		drop = append(drop, snap.ID)
+	}
+	if replay {
+		drop = append(drop, snapshot.ID)
+	} else {
+		s.convStore[AccountMetaKey(s.accountID, restored.Model)] = cloneStringSlice(restored.Metadata)
+	}
+	storeSnapshot := make(map[string][]string, len(s.convStore))
+	for k, v := range s.convStore {
+		if v != nil {
+			storeSnapshot[k] = cloneStringSlice(v)
+		}
+	}
+	s.convMu.Unlock()
+
+	if !replay {
+		if err = SaveConvStore(s.convPath(), storeSnapshot); err != nil {
+			return ConversationSnapshot{}, false, err
+		}
+	}
+	for _, dropID := range drop {
+		if err = s.DeleteConversation(ctx, dropID, by, permanent); err != nil && !errors.Is(err, ErrConversationNotFound) {
+			return ConversationSnapshot{}, false, err
+		}
+	}
+	return snapshot, replay, nil
+}
+
+// versionIDsLocked returns the records holding the versions of the conversation of record id.
+// Records without upstream metadata have only themselves as a version. convMu must be held.
+func (s *GeminiWebState) versionIDsLocked(id string, header conversationHeader) []string {
+	cid := header.conversationID()
+	if strings.TrimSpace(cid) == "" {
+		return []string{id}
+	}
+	var out []string
+	for key, candidate := range s.convHeaders {
+		if candidate.conversationID() == cid {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// snapshots returns the versions stored under ids, oldest first. Counting their turns needs
+// the messages, so the records are read from the store.
+func (s *GeminiWebState) snapshots(ids []string) []ConversationSnapshot {
+	out := make([]ConversationSnapshot, 0, len(ids))
+	s.scanConversations(ids, func(key string, rec ConversationRecord) bool {
+		out = append(out, newConversationSnapshot(key, rec))
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Messages != out[j].Messages {
+			return out[i].Messages < out[j].Messages
+		}
+		return out[i].UpdatedAt.Before(out[j].UpdatedAt)
+	})
+	return out
+}
+
+func newConversationSnapshot(id string, rec ConversationRecord) ConversationSnapshot {
+	turn := 0
+	for _, msg := range rec.Messages {
+		if strings.EqualFold(msg.Role, "assistant") {
+			turn++
+		}
+	}
+	return ConversationSnapshot{ConversationSummary: summarizeConversation(id, rec), Turn: turn}
+}
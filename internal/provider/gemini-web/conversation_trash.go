@@ -0,0 +1,411 @@
+package geminiwebapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Soft-deleted conversation records move from conv_items to the conv_trash bucket of the
+// same store, together with their index keys, so a restore puts them back exactly.
+const bucketConvTrash = "conv_trash"
+
+// ErrConversationNotFound is returned for unknown conversation IDs.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// ConversationSummary describes a stored conversation without its messages.
+type ConversationSummary struct {
+	ID        string    `json:"id"`
+	Model     string    `json:"model"`
+	Messages  int       `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DeletedConversation is a soft-deleted conversation record.
+type DeletedConversation struct {
+	ID        string             `json:"id"`
+	Record    ConversationRecord `json:"record"`
+	IndexKeys []string           `json:"index_keys,omitempty"`
+	DeletedAt time.Time          `json:"deleted_at"`
+	DeletedBy string             `json:"deleted_by,omitempty"`
+}
+
+// Summary returns the listing form of a deleted conversation.
+func (d DeletedConversation) Summary() ConversationSummary {
+	return summarizeConversation(d.ID, d.Record)
+}
+
+// Conversations lists the conversation records stored for the account, newest first.
+func (s *GeminiWebState) Conversations() []ConversationSummary {
+	s.convMu.RLock()
+	out := make([]ConversationSummary, 0, len(s.convHeaders))
+	for id, header := range s.convHeaders {
+		out = append(out, header.summary(id))
+	}
+	s.convMu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out
+}
+
+// DeleteConversation removes a conversation record and its index entries so it is no longer
+// reused. Unless permanent is set, the record is kept in the trash for RestoreConversation.
+func (s *GeminiWebState) DeleteConversation(ctx context.Context, id, by string, permanent bool) error {
+	path := s.convPath()
+	s.convSaveMu.Lock()
+	defer s.convSaveMu.Unlock()
+	s.convMu.Lock()
+	err := s.ensureConvLoadedLocked()
+	_, ok := s.convHeaders[id]
+	s.convMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConversationNotFound
+	}
+	// A record that cannot be read can still be deleted for good, but not kept in the trash.
+	rec, readable := s.conversationRecord(id)
+	if !readable && !permanent {
+		return errConversationUnreadable
+	}
+	deleted := DeletedConversation{ID: id, Record: rec, DeletedAt: idgen.Now(ctx).UTC(), DeletedBy: by}
+	s.convMu.Lock()
+	deleted.IndexKeys = s.removeConversationLocked(id)
+	s.convMu.Unlock()
+
+	if !permanent {
+		if err = putDeletedConversation(path, deleted); err != nil {
+			return err
+		}
+	}
+	if err = s.flushConversations([]string{id}, deleted.IndexKeys); err != nil {
+		return err
+	}
+	label := s.conversationLabel()
+	for _, h := range conversation.BuildStorageHashes(rec.Model, conversation.StoredToMessages(rec.Messages)) {
+		if err := conversation.RemoveMatchForLabel(h.Hash, label); err != nil {
+			log.Debugf("gemini web: failed to remove conversation match: %v", err)
+		}
+	}
+	return nil
+}
+
+// HasConversation reports whether a conversation record with id is stored for the account.
+func (s *GeminiWebState) HasConversation(id string) bool {
+	s.convMu.RLock()
+	defer s.convMu.RUnlock()
+	_, ok := s.convHeaders[id]
+	return ok
+}
+
+// DeleteUpstreamConversation deletes the Gemini Web chat behind record id and every local
+// trace of it: all versions of the conversation (permanently, bypassing the trash), explicit
+// conversation ID mappings and the account's last-conversation pointer. Nothing is removed
+// locally when the remote deletion fails. It returns the number of records deleted and
+// whether a remote chat was deleted; records without upstream metadata only exist locally.
+func (s *GeminiWebState) DeleteUpstreamConversation(ctx context.Context, id, by string) (int, bool, error) {
+	s.convMu.Lock()
+	if err := s.ensureConvLoadedLocked(); err != nil {
+		s.convMu.Unlock()
+		return 0, false, err
+	}
+	header, ok := s.convHeaders[id]
+	if !ok {
+		s.convMu.Unlock()
+		return 0, false, ErrConversationNotFound
+	}
+	versions := s.versionIDsLocked(id, header)
+	s.convMu.Unlock()
+
+	cid := strings.TrimSpace(header.conversationID())
+	if cid != "" {
+		client, err := s.ensureClient()
+		if err != nil {
+			return 0, false, err
+		}
+		if err = client.DeleteChat(cid); err != nil {
+			return 0, false, err
+		}
+	}
+
+	deleted := 0
+	for _, version := range versions {
+		if err := s.DeleteConversation(ctx, version, by, true); err != nil {
+			if errors.Is(err, ErrConversationNotFound) {
+				continue
+			}
+			return deleted, cid != "", err
+		}
+		deleted++
+	}
+	if cid == "" {
+		return deleted, false, nil
+	}
+
+	s.convMu.Lock()
+	for key, metadata := range s.convStore {
+		if len(metadata) > 0 && metadata[0] == cid {
+			delete(s.convStore, key)
+		}
+	}
+	storeSnapshot := make(map[string][]string, len(s.convStore))
+	for k, v := range s.convStore {
+		if v != nil {
+			storeSnapshot[k] = cloneStringSlice(v)
+		}
+	}
+	s.convMu.Unlock()
+	if err := SaveConvStore(s.convPath(), storeSnapshot); err != nil {
+		return deleted, true, err
+	}
+	mappings, err := conversation.ListConversationIDs()
+	if err != nil {
+		return deleted, true, err
+	}
+	for _, mapping := range mappings {
+		if len(mapping.Metadata) > 0 && mapping.Metadata[0] == cid {
+			if errDelete := conversation.DeleteConversationID(mapping.Key); errDelete != nil && !errors.Is(errDelete, conversation.ErrConversationIDNotFound) {
+				return deleted, true, errDelete
+			}
+		}
+	}
+	return deleted, true, nil
+}
+
+// DeletedConversations lists the account's soft-deleted conversations, newest first, after
+// purging those older than retention.
+func (s *GeminiWebState) DeletedConversations(ctx context.Context, retention time.Duration) ([]DeletedConversation, error) {
+	path := s.convPath()
+	if _, err := purgeDeletedConversations(path, retention, idgen.Now(ctx)); err != nil {
+		return nil, err
+	}
+	return LoadDeletedConversations(path)
+}
+
+// RestoreConversation moves a soft-deleted conversation back into the store.
+func (s *GeminiWebState) RestoreConversation(id string) error {
+	path := s.convPath()
+	s.convSaveMu.Lock()
+	defer s.convSaveMu.Unlock()
+	s.convMu.Lock()
+	if err := s.ensureConvLoadedLocked(); err != nil {
+		s.convMu.Unlock()
+		return err
+	}
+	s.convMu.Unlock()
+	deleted, err := takeDeletedConversation(path, id)
+	if err != nil {
+		return err
+	}
+	s.convMu.Lock()
+	index := make(map[string]string, len(deleted.IndexKeys))
+	for _, key := range deleted.IndexKeys {
+		if _, taken := s.convIndex[key]; !taken {
+			index[key] = deleted.ID
+		}
+	}
+	s.addConversationLocked(deleted.ID, deleted.Record, index)
+	s.convMu.Unlock()
+	if err = s.flushConversations(nil, nil); err != nil {
+		return err
+	}
+	rec := deleted.Record
+	if errStore := conversation.StoreConversation(s.conversationLabel(), rec.Model, conversation.StoredToMessages(rec.Messages), rec.Metadata); errStore != nil {
+		log.Debugf("gemini web: failed to restore conversation match: %v", errStore)
+	}
+	return nil
+}
+
+// RestoreConversationInStore restores a soft-deleted conversation directly in the store at
+// path. It is meant for offline use; a running server keeps its own index of the records.
+func RestoreConversationInStore(path, id string) error {
+	items, index, err := LoadConvData(path)
+	if err != nil {
+		return err
+	}
+	deleted, err := takeDeletedConversation(path, id)
+	if err != nil {
+		return err
+	}
+	restoreConversationInto(items, index, deleted)
+	return SaveConvData(path, items, index)
+}
+
+// PurgeDeletedConversations permanently removes conversations deleted longer than retention
+// ago from every store in dir and returns how many were removed.
+func PurgeDeletedConversations(dir string, retention time.Duration, now time.Time) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.bolt"))
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, path := range paths {
+		n, errPurge := purgeDeletedConversations(path, retention, now)
+		total += n
+		if errPurge != nil {
+			return total, errPurge
+		}
+	}
+	return total, nil
+}
+
+// LoadDeletedConversations reads the soft-deleted conversations of the store at path,
+// newest first.
+func LoadDeletedConversations(path string) ([]DeletedConversation, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	var out []DeletedConversation
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketConvTrash))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var d DeletedConversation
+			if json.Unmarshal(v, &d) == nil {
+				out = append(out, d)
+			}
+			return nil
+		})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].DeletedAt.After(out[j].DeletedAt) })
+	return out, err
+}
+
+func putDeletedConversation(path string, deleted DeletedConversation) error {
+	enc, err := json.Marshal(deleted)
+	if err != nil {
+		return err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, errCreate := tx.CreateBucketIfNotExists([]byte(bucketConvTrash))
+		if errCreate != nil {
+			return errCreate
+		}
+		return b.Put([]byte(deleted.ID), enc)
+	})
+}
+
+func takeDeletedConversation(path, id string) (DeletedConversation, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return DeletedConversation{}, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	var deleted DeletedConversation
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketConvTrash))
+		if b == nil {
+			return ErrConversationNotFound
+		}
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ErrConversationNotFound
+		}
+		if errDecode := json.Unmarshal(v, &deleted); errDecode != nil {
+			return errDecode
+		}
+		return b.Delete([]byte(id))
+	})
+	return deleted, err
+}
+
+func purgeDeletedConversations(path string, retention time.Duration, now time.Time) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	purged := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketConvTrash))
+		if b == nil {
+			return nil
+		}
+		var expired [][]byte
+		errScan := b.ForEach(func(k, v []byte) error {
+			var d DeletedConversation
+			if json.Unmarshal(v, &d) != nil || !now.Before(d.DeletedAt.Add(retention)) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if errScan != nil {
+			return errScan
+		}
+		for _, k := range expired {
+			if errDelete := b.Delete(k); errDelete != nil {
+				return errDelete
+			}
+			purged++
+		}
+		return nil
+	})
+	return purged, err
+}
+
+func restoreConversationInto(items map[string]ConversationRecord, index map[string]string, deleted DeletedConversation) {
+	items[deleted.ID] = deleted.Record
+	for _, key := range deleted.IndexKeys {
+		if _, taken := index[key]; !taken {
+			index[key] = deleted.ID
+		}
+	}
+}
+
+func summarizeConversation(id string, rec ConversationRecord) ConversationSummary {
+	return ConversationSummary{
+		ID:        id,
+		Model:     rec.Model,
+		Messages:  len(rec.Messages),
+		CreatedAt: rec.CreatedAt,
+		UpdatedAt: rec.UpdatedAt,
+	}
+}
+
+// conversationLabel is the account label conversation matches are stored under.
+func (s *GeminiWebState) conversationLabel() string {
+	if label := strings.TrimSpace(s.Label()); label != "" {
+		return label
+	}
+	return s.accountID
+}
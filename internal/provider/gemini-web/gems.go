@@ -0,0 +1,215 @@
+package geminiwebapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// rpcData is a single call inside a batchexecute request.
+type rpcData struct {
+	RPCID      string
+	Payload    string
+	Identifier string
+}
+
+func (r rpcData) serialize() []any {
+	identifier := r.Identifier
+	if identifier == "" {
+		identifier = "generic"
+	}
+	return []any{r.RPCID, r.Payload, nil, identifier}
+}
+
+// batchExecute sends one or more RPC calls to the batchexecute endpoint and returns
+// the raw payload string of each response frame keyed by its identifier.
+func (c *GeminiClient) batchExecute(calls ...rpcData) (map[string]string, error) {
+	if c == nil || !c.Running {
+		return nil, &APIError{Msg: "Client is not running."}
+	}
+	serialized := make([]any, 0, len(calls))
+	rpcIDs := make([]string, 0, len(calls))
+	for _, call := range calls {
+		serialized = append(serialized, call.serialize())
+		rpcIDs = append(rpcIDs, call.RPCID)
+	}
+	fReq, _ := json.Marshal([]any{serialized})
+
+	form := url.Values{}
+	form.Set("at", c.AccessToken)
+	form.Set("f.req", string(fReq))
+
+	endpoint := EndpointBatchExec + "?rpcids=" + url.QueryEscape(strings.Join(rpcIDs, ",")) + "&rt=c"
+	req, _ := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	applyHeaders(req, HeadersGemini)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
+	applyCookies(req, c.Cookies)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &TimeoutError{GeminiError{Msg: "Batch execute request timed out."}}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{Msg: fmt.Sprintf("Batch execute failed. Status %d", resp.StatusCode)}
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	out := make(map[string]string, len(calls))
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		var frames []any
+		if err = json.Unmarshal([]byte(line), &frames); err != nil {
+			continue
+		}
+		for _, f := range frames {
+			arr, ok := f.([]any)
+			if !ok || len(arr) < 3 {
+				continue
+			}
+			if tag, _ := arr[0].(string); tag != "wrb.fr" {
+				continue
+			}
+			payload, _ := arr[2].(string)
+			identifier := "generic"
+			if id, okID := arr[len(arr)-1].(string); okID && id != "" {
+				identifier = id
+			}
+			out[identifier] = payload
+		}
+	}
+	if len(out) == 0 {
+		return nil, &APIError{Msg: "Invalid response data received."}
+	}
+	return out, nil
+}
+
+// FetchGems lists the predefined and custom Gems visible to the account.
+func (c *GeminiClient) FetchGems(includeHidden bool) ([]Gem, error) {
+	systemPayload := "[3]"
+	if includeHidden {
+		systemPayload = "[4]"
+	}
+	frames, err := c.batchExecute(
+		rpcData{RPCID: RPCListGems, Payload: systemPayload, Identifier: "system"},
+		rpcData{RPCID: RPCListGems, Payload: "[2]", Identifier: "custom"},
+	)
+	if err != nil {
+		return nil, err
+	}
+	gems := make([]Gem, 0)
+	gems = append(gems, parseGemList(frames["system"], true)...)
+	gems = append(gems, parseGemList(frames["custom"], false)...)
+	return gems, nil
+}
+
+// parseGemList decodes the gem container returned by the list RPC.
+// Each entry is laid out as [id, [name, description], [prompt], ...].
+func parseGemList(payload string, predefined bool) []Gem {
+	if strings.TrimSpace(payload) == "" {
+		return nil
+	}
+	var container []any
+	if err := json.Unmarshal([]byte(payload), &container); err != nil || len(container) < 3 {
+		return nil
+	}
+	items, ok := container[2].([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]Gem, 0, len(items))
+	for _, item := range items {
+		arr, okArr := item.([]any)
+		if !okArr || len(arr) < 2 {
+			continue
+		}
+		id, _ := arr[0].(string)
+		if id == "" {
+			continue
+		}
+		gem := Gem{ID: id, Predefined: predefined}
+		if info, okInfo := arr[1].([]any); okInfo {
+			if len(info) > 0 {
+				gem.Name, _ = info[0].(string)
+			}
+			if len(info) > 1 {
+				if desc, okDesc := info[1].(string); okDesc {
+					gem.Description = &desc
+				}
+			}
+		}
+		if len(arr) > 2 {
+			if promptArr, okPrompt := arr[2].([]any); okPrompt && len(promptArr) > 0 {
+				if prompt, okStr := promptArr[0].(string); okStr {
+					gem.Prompt = &prompt
+				}
+			}
+		}
+		out = append(out, gem)
+	}
+	return out
+}
+
+// gemDefinition builds the gem body shared by the create and update RPCs.
+func gemDefinition(name, description, prompt string) []any {
+	return []any{name, description, prompt, nil, nil, nil, nil, nil, 0, nil, 1, nil, nil, nil, []any{}}
+}
+
+// CreateGem creates a custom Gem and returns it with the server-assigned ID.
+func (c *GeminiClient) CreateGem(name, prompt, description string) (Gem, error) {
+	payload, _ := json.Marshal([]any{gemDefinition(name, description, prompt)})
+	frames, err := c.batchExecute(rpcData{RPCID: RPCCreateGem, Payload: string(payload)})
+	if err != nil {
+		return Gem{}, err
+	}
+	var created []any
+	if err = json.Unmarshal([]byte(frames["generic"]), &created); err != nil || len(created) == 0 {
+		return Gem{}, &APIError{Msg: "Failed to create gem: unexpected response."}
+	}
+	id, _ := created[0].(string)
+	if id == "" {
+		return Gem{}, &APIError{Msg: "Failed to create gem: missing gem ID."}
+	}
+	return Gem{ID: id, Name: name, Description: &description, Prompt: &prompt}, nil
+}
+
+// UpdateGem replaces the name, prompt and description of an existing custom Gem.
+func (c *GeminiClient) UpdateGem(id, name, prompt, description string) (Gem, error) {
+	if strings.TrimSpace(id) == "" {
+		return Gem{}, &ValueError{Msg: "gem ID is required."}
+	}
+	definition := append(gemDefinition(name, description, prompt), 0)
+	payload, _ := json.Marshal([]any{id, definition})
+	if _, err := c.batchExecute(rpcData{RPCID: RPCUpdateGem, Payload: string(payload)}); err != nil {
+		return Gem{}, err
+	}
+	return Gem{ID: id, Name: name, Description: &description, Prompt: &prompt}, nil
+}
+
+// DeleteGem removes a custom Gem.
+func (c *GeminiClient) DeleteGem(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return &ValueError{Msg: "gem ID is required."}
+	}
+	payload, _ := json.Marshal([]any{id})
+	_, err := c.batchExecute(rpcData{RPCID: RPCDeleteGem, Payload: string(payload)})
+	return err
+}
+
+// DeleteChat deletes a conversation (chat) from the account's Gemini Web history.
+func (c *GeminiClient) DeleteChat(cid string) error {
+	if strings.TrimSpace(cid) == "" {
+		return &ValueError{Msg: "conversation ID is required."}
+	}
+	payload, _ := json.Marshal([]any{cid})
+	_, err := c.batchExecute(rpcData{RPCID: RPCDeleteChat, Payload: string(payload)})
+	return err
+}
@@ -0,0 +1,145 @@
+package geminiwebapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+)
+
+// File categories with their own size limit.
+const (
+	fileKindImage    = "image"
+	fileKindDocument = "document"
+	fileKindAudio    = "audio"
+	fileKindVideo    = "video"
+)
+
+// inputFileTypes lists the MIME types Gemini Web accepts as attachments, with the category
+// that sets their size limit and the extension the uploaded file is named with, which is
+// how Gemini Web recognises the type.
+var inputFileTypes = map[string]struct {
+	kind string
+	ext  string
+}{
+	"image/png":  {fileKindImage, ".png"},
+	"image/jpeg": {fileKindImage, ".jpg"},
+	"image/webp": {fileKindImage, ".webp"},
+	"image/gif":  {fileKindImage, ".gif"},
+	"image/bmp":  {fileKindImage, ".bmp"},
+	"image/heic": {fileKindImage, ".heic"},
+	"image/heif": {fileKindImage, ".heif"},
+
+	"application/pdf": {fileKindDocument, ".pdf"},
+	"text/plain":      {fileKindDocument, ".txt"},
+	"text/markdown":   {fileKindDocument, ".md"},
+	"text/csv":        {fileKindDocument, ".csv"},
+	"text/html":       {fileKindDocument, ".html"},
+	"application/rtf": {fileKindDocument, ".rtf"},
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": {fileKindDocument, ".docx"},
+
+	"audio/mpeg":   {fileKindAudio, ".mp3"},
+	"audio/mp3":    {fileKindAudio, ".mp3"},
+	"audio/wav":    {fileKindAudio, ".wav"},
+	"audio/x-wav":  {fileKindAudio, ".wav"},
+	"audio/aac":    {fileKindAudio, ".aac"},
+	"audio/x-aac":  {fileKindAudio, ".aac"},
+	"audio/ogg":    {fileKindAudio, ".ogg"},
+	"audio/flac":   {fileKindAudio, ".flac"},
+	"audio/x-flac": {fileKindAudio, ".flac"},
+	"audio/mp4":    {fileKindAudio, ".m4a"},
+	"audio/webm":   {fileKindAudio, ".weba"},
+	"audio/aiff":   {fileKindAudio, ".aiff"},
+
+	"video/mp4":        {fileKindVideo, ".mp4"},
+	"video/mpeg":       {fileKindVideo, ".mpeg"},
+	"video/quicktime":  {fileKindVideo, ".mov"},
+	"video/webm":       {fileKindVideo, ".webm"},
+	"video/x-matroska": {fileKindVideo, ".mkv"},
+	"video/x-msvideo":  {fileKindVideo, ".avi"},
+	"video/3gpp":       {fileKindVideo, ".3gp"},
+}
+
+// maxFileBytes returns the size limit of a file category.
+func maxFileBytes(cfg *config.Config, kind string) int64 {
+	var mb int
+	if cfg != nil {
+		switch kind {
+		case fileKindImage:
+			mb = cfg.GeminiWeb.Files.MaxImageMB
+		case fileKindDocument:
+			mb = cfg.GeminiWeb.Files.MaxDocumentMB
+		case fileKindAudio:
+			mb = cfg.GeminiWeb.Files.MaxAudioMB
+		case fileKindVideo:
+			mb = cfg.GeminiWeb.Files.MaxVideoMB
+		}
+	}
+	if mb <= 0 {
+		switch kind {
+		case fileKindImage:
+			mb = 20
+		case fileKindVideo:
+			mb = 500
+		default:
+			mb = 100
+		}
+	}
+	return int64(mb) << 20
+}
+
+// normalizeFileMIME lowercases mime and strips parameters, sniffing the content when the
+// client sent no type.
+func normalizeFileMIME(mime string, data []byte) string {
+	mime = strings.ToLower(strings.TrimSpace(strings.SplitN(mime, ";", 2)[0]))
+	if mime == "" || mime == "application/octet-stream" {
+		mime = strings.SplitN(http.DetectContentType(data), ";", 2)[0]
+	}
+	if mime == "image/jpg" {
+		mime = "image/jpeg"
+	}
+	return mime
+}
+
+// supportedFileTypes returns the accepted MIME types, sorted, for error messages.
+func supportedFileTypes() string {
+	types := make([]string, 0, len(inputFileTypes))
+	for mime := range inputFileTypes {
+		types = append(types, mime)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// validateInputFiles checks the type and size of each attached file, normalizing mimes in
+// place. Unsupported types fail with 400 and oversized files with 413.
+func validateInputFiles(cfg *config.Config, files [][]byte, mimes []string) *interfaces.ErrorMessage {
+	for i, data := range files {
+		mime := ""
+		if i < len(mimes) {
+			mime = mimes[i]
+		}
+		mime = normalizeFileMIME(mime, data)
+		if i < len(mimes) {
+			mimes[i] = mime
+		}
+		info, ok := inputFileTypes[mime]
+		if !ok {
+			return &interfaces.ErrorMessage{
+				StatusCode: http.StatusBadRequest,
+				Error:      fmt.Errorf("bad request: attachment %d has unsupported type %q; supported types: %s", i+1, mime, supportedFileTypes()),
+			}
+		}
+		if limit := maxFileBytes(cfg, info.kind); int64(len(data)) > limit {
+			return &interfaces.ErrorMessage{
+				StatusCode: http.StatusRequestEntityTooLarge,
+				Error: fmt.Errorf("bad request: attachment %d (%s) is %.1f MB; the limit for %s files is %d MB",
+					i+1, mime, float64(len(data))/(1<<20), info.kind, limit>>20),
+			}
+		}
+	}
+	return nil
+}
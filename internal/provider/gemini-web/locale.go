@@ -0,0 +1,90 @@
+package geminiwebapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// Headers through which a client overrides the locale hint.
+const (
+	headerClientTimezone = "X-Client-Timezone"
+	headerClientUnits    = "X-Client-Units"
+)
+
+// localeContextBlock returns the date/time and unit hint for this request, or "" when the
+// hint is disabled. Client values from headers or x_cliproxy fields win over the config.
+func localeContextBlock(ctx context.Context, cfg *config.Config, original []byte, now time.Time) string {
+	if cfg == nil || !cfg.GeminiWeb.LocaleContext.Enabled {
+		return ""
+	}
+	lc := cfg.GeminiWeb.LocaleContext
+	tzName := firstNonEmpty(clientLocaleValue(ctx, original, headerClientTimezone, "timezone"), lc.Timezone)
+	units := strings.ToLower(firstNonEmpty(clientLocaleValue(ctx, original, headerClientUnits, "units"), lc.Units))
+
+	loc := time.Local
+	if tzName != "" {
+		if l, err := time.LoadLocation(tzName); err == nil {
+			loc = l
+		} else {
+			log.Debugf("gemini web: ignoring unknown time zone %q: %v", tzName, err)
+		}
+	}
+	local := now.In(loc)
+	var sb strings.Builder
+	sb.WriteString("<context>\n")
+	fmt.Fprintf(&sb, "Current date and time: %s (%s, UTC%s).", local.Format("Monday, 2006-01-02 15:04"), loc.String(), local.Format("-07:00"))
+	switch units {
+	case "metric":
+		sb.WriteString("\nPreferred units: metric (°C, km, kg).")
+	case "imperial":
+		sb.WriteString("\nPreferred units: imperial (°F, miles, pounds).")
+	}
+	sb.WriteString("\nUse these instead of any locale you would otherwise assume.\n</context>")
+	return sb.String()
+}
+
+func clientLocaleValue(ctx context.Context, original []byte, header, field string) string {
+	if ctx != nil {
+		if ginCtx := requestctx.Gin(ctx); ginCtx != nil && ginCtx.Request != nil {
+			if v := strings.TrimSpace(ginCtx.GetHeader(header)); v != "" {
+				return v
+			}
+		}
+	}
+	if len(original) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(gjson.GetBytes(original, "x_cliproxy."+field).String())
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// PrependContextBlock puts block in front of the last user message, so it accompanies the
+// turn being answered even when earlier turns are already on the remote thread.
+func PrependContextBlock(msgs []RoleText, block string) []RoleText {
+	if block == "" {
+		return msgs
+	}
+	out := cloneRoleTextSlice(msgs)
+	for i := len(out) - 1; i >= 0; i-- {
+		if strings.EqualFold(out[i].Role, "user") {
+			out[i].Text = block + "\n\n" + out[i].Text
+			return out
+		}
+	}
+	return append([]RoleText{{Role: "user", Text: block}}, out...)
+}
@@ -2,6 +2,7 @@ package geminiwebapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -14,12 +15,15 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/assets"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
@@ -192,6 +196,18 @@ func ParseMessagesAndFiles(rawJSON []byte) ([]RoleText, [][]byte, []string, [][]
 					}
 					b.WriteString(text.String())
 				}
+				if call := part.Get("functionCall"); call.Exists() {
+					if b.Len() > 0 {
+						b.WriteString("\n")
+					}
+					b.WriteString(renderToolCall(call))
+				}
+				if resp := part.Get("functionResponse"); resp.Exists() {
+					if b.Len() > 0 {
+						b.WriteString("\n")
+					}
+					b.WriteString(renderToolResult(resp))
+				}
 				if inlineData := part.Get("inlineData"); inlineData.Exists() {
 					data := inlineData.Get("data").String()
 					if data != "" {
@@ -258,14 +274,23 @@ func CleanupFiles(paths []string) {
 }
 
 func FetchGeneratedImageData(gi GeneratedImage) (string, string, error) {
-	path, err := gi.Save("", "", true, false, true, false)
+	mime, b, err := fetchGeneratedImage(gi)
 	if err != nil {
 		return "", "", err
 	}
+	return mime, base64.StdEncoding.EncodeToString(b), nil
+}
+
+// fetchGeneratedImage downloads the full-size image and returns its MIME type and bytes.
+func fetchGeneratedImage(gi GeneratedImage) (string, []byte, error) {
+	path, err := gi.Save("", "", true, false, true, false)
+	if err != nil {
+		return "", nil, err
+	}
 	defer func() { _ = os.Remove(path) }()
 	b, err := os.ReadFile(path)
 	if err != nil {
-		return "", "", err
+		return "", nil, err
 	}
 	mime := http.DetectContentType(b)
 	if !strings.HasPrefix(mime, "image/") {
@@ -275,7 +300,37 @@ func FetchGeneratedImageData(gi GeneratedImage) (string, string, error) {
 			mime = "image/png"
 		}
 	}
-	return mime, base64.StdEncoding.EncodeToString(b), nil
+	return mime, b, nil
+}
+
+// generatedImagePart downloads gi and returns it as a Gemini content part: a fileData link
+// to the re-hosted copy when asset storage is enabled, inline base64 data otherwise.
+func generatedImagePart(ctx context.Context, gi GeneratedImage) (map[string]any, bool) {
+	mime, b, err := fetchGeneratedImage(gi)
+	if err != nil || len(b) == 0 {
+		if err != nil {
+			log.Debugf("gemini web: failed to download generated image: %v", err)
+		}
+		return nil, false
+	}
+	if store := assets.GetService(); store.Enabled() {
+		link, errStore := store.Store(ctx, b, mime)
+		if errStore == nil {
+			return map[string]any{
+				"fileData": map[string]any{
+					"mimeType": mime,
+					"fileUri":  link,
+				},
+			}, true
+		}
+		log.Warnf("gemini web: returning image inline: %v", errStore)
+	}
+	return map[string]any{
+		"inlineData": map[string]any{
+			"mimeType": mime,
+			"data":     base64.StdEncoding.EncodeToString(b),
+		},
+	}, true
 }
 
 func MimeToExt(mimes []string, i int) string {
@@ -304,6 +359,9 @@ func MimeToPreferredExt(mime string) string {
 	if ext, ok := preferredExtByMIME[normalized]; ok {
 		return ext
 	}
+	if info, ok := inputFileTypes[normalized]; ok {
+		return info.ext
+	}
 	return ".png"
 }
 
@@ -320,6 +378,10 @@ func mimeFromExtension(ext string) string {
 
 // File upload helpers ------------------------------------------------------
 
+// uploadChunkSize is the chunk size of resumable uploads; files up to this size are sent in
+// a single multipart request. It is a multiple of the 256 KiB upload granularity.
+const uploadChunkSize = 8 << 20
+
 func uploadFile(path string, proxy string, insecure bool) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -328,6 +390,9 @@ func uploadFile(path string, proxy string, insecure bool) (string, error) {
 	defer func() {
 		_ = f.Close()
 	}()
+	if st, errStat := f.Stat(); errStat == nil && st.Size() > uploadChunkSize {
+		return uploadFileResumable(f, filepath.Base(path), st.Size(), proxy, insecure)
+	}
 
 	var buf bytes.Buffer
 	mw := multipart.NewWriter(&buf)
@@ -366,6 +431,69 @@ func uploadFile(path string, proxy string, insecure bool) (string, error) {
 	return string(b), nil
 }
 
+// uploadFileResumable sends a large file in uploadChunkSize chunks with the resumable upload
+// protocol of the upload endpoint and returns the file handle sent with the final chunk.
+func uploadFileResumable(f *os.File, name string, size int64, proxy string, insecure bool) (string, error) {
+	client := newHTTPClient(httpOptions{ProxyURL: proxy, Insecure: insecure, FollowRedirects: true})
+	client.Timeout = 300 * time.Second
+
+	req, _ := http.NewRequest(http.MethodPost, EndpointUpload, strings.NewReader("File name: "+name))
+	applyHeaders(req, HeadersUpload)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	req.Header.Set("X-Goog-Upload-Command", "start")
+	req.Header.Set("X-Goog-Upload-Header-Content-Length", strconv.FormatInt(size, 10))
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", &APIError{Msg: "upload start: " + resp.Status}
+	}
+	uploadURL := resp.Header.Get("X-Goog-Upload-URL")
+	if uploadURL == "" {
+		return "", &APIError{Msg: "upload start: no upload URL returned"}
+	}
+
+	buf := make([]byte, uploadChunkSize)
+	for offset := int64(0); offset < size; {
+		n, errRead := io.ReadFull(f, buf)
+		if errRead != nil && !errors.Is(errRead, io.ErrUnexpectedEOF) && !errors.Is(errRead, io.EOF) {
+			return "", errRead
+		}
+		if n == 0 {
+			return "", &APIError{Msg: "upload: file shrank while uploading"}
+		}
+		command := "upload"
+		if offset+int64(n) >= size {
+			command = "upload, finalize"
+		}
+		chunkReq, _ := http.NewRequest(http.MethodPost, uploadURL, bytes.NewReader(buf[:n]))
+		applyHeaders(chunkReq, HeadersUpload)
+		chunkReq.Header.Set("X-Goog-Upload-Command", command)
+		chunkReq.Header.Set("X-Goog-Upload-Offset", strconv.FormatInt(offset, 10))
+		chunkResp, errDo := client.Do(chunkReq)
+		if errDo != nil {
+			return "", errDo
+		}
+		body, errBody := io.ReadAll(chunkResp.Body)
+		_ = chunkResp.Body.Close()
+		if chunkResp.StatusCode < 200 || chunkResp.StatusCode >= 300 {
+			return "", &APIError{Msg: fmt.Sprintf("upload chunk at offset %d: %s", offset, chunkResp.Status)}
+		}
+		if errBody != nil {
+			return "", errBody
+		}
+		offset += int64(n)
+		if command != "upload" {
+			return string(body), nil
+		}
+	}
+	return "", &APIError{Msg: "upload: empty file"}
+}
+
 func parseFileName(path string) (string, error) {
 	if st, err := os.Stat(path); err != nil || st.IsDir() {
 		return "", &ValueError{Msg: path + " is not a valid file."}
@@ -431,7 +559,7 @@ func estimateTokens(s string) int {
 
 // ConvertOutputToGemini converts simplified ModelOutput to Gemini API-like JSON.
 // promptText is used only to estimate usage tokens to populate usage fields.
-func ConvertOutputToGemini(output *ModelOutput, modelName string, promptText string) ([]byte, error) {
+func ConvertOutputToGemini(ctx context.Context, output *ModelOutput, modelName string, promptText string) ([]byte, error) {
 	if output == nil || len(output.Candidates) == 0 {
 		return nil, fmt.Errorf("empty output")
 	}
@@ -457,13 +585,8 @@ func ConvertOutputToGemini(output *ModelOutput, modelName string, promptText str
 
 	if imgs := output.Candidates[0].GeneratedImages; len(imgs) > 0 {
 		for _, gi := range imgs {
-			if mime, data, err := FetchGeneratedImageData(gi); err == nil && data != "" {
-				parts = append(parts, map[string]any{
-					"inlineData": map[string]any{
-						"mimeType": mime,
-						"data":     data,
-					},
-				})
+			if part, ok := generatedImagePart(ctx, gi); ok {
+				parts = append(parts, part)
 			}
 		}
 	}
@@ -476,20 +599,23 @@ func ConvertOutputToGemini(output *ModelOutput, modelName string, promptText str
 	}
 	totalTokens := promptTokens + completionTokens
 
-	now := time.Now()
-	resp := map[string]any{
-		"candidates": []any{
-			map[string]any{
-				"content": map[string]any{
-					"parts": parts,
-					"role":  "model",
-				},
-				"finishReason": "stop",
-				"index":        0,
-			},
+	candidate := map[string]any{
+		"content": map[string]any{
+			"parts": parts,
+			"role":  "model",
 		},
+		"finishReason": "stop",
+		"index":        0,
+	}
+	if grounding := groundingMetadata(finalText, output.Candidates[0].WebImages); grounding != nil {
+		candidate["groundingMetadata"] = grounding
+	}
+
+	now := idgen.Now(ctx)
+	resp := map[string]any{
+		"candidates":   []any{candidate},
 		"createTime":   now.Format(time.RFC3339Nano),
-		"responseId":   fmt.Sprintf("gemini-web-%d", now.UnixNano()),
+		"responseId":   idgen.NewID(ctx, "gemini-web-"),
 		"modelVersion": modelName,
 		"usageMetadata": map[string]any{
 			"promptTokenCount":     promptTokens,
@@ -0,0 +1,207 @@
+package geminiwebapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// legacyConvStoreSuffix names the JSON account metadata files written by earlier releases.
+	legacyConvStoreSuffix = ".conv.json"
+	// legacyConvDataSuffix names the JSON conversation data files written by earlier releases.
+	legacyConvDataSuffix = ".data.json"
+	// migratedSuffix is appended to legacy files once their content is in the BoltDB store.
+	migratedSuffix = ".migrated"
+)
+
+// legacyConvData mirrors the layout of the old <account>.data.json files.
+type legacyConvData struct {
+	Items map[string]ConversationRecord `json:"items"`
+	Index map[string]string             `json:"index"`
+}
+
+// LegacyMigrationResult summarises the import of one account's legacy JSON files.
+type LegacyMigrationResult struct {
+	Account       string
+	BoltPath      string
+	MetaEntries   int
+	Conversations int
+	Skipped       int
+	Files         []string
+}
+
+// MigrateLegacyConversations folds legacy JSON conversation files found in dir into the
+// per-account .bolt stores. Entries already present in BoltDB win; imported conversations
+// keep their original client IDs and are re-indexed. Imported files are renamed with a
+// ".migrated" suffix so the migration runs once.
+func MigrateLegacyConversations(dir string) ([]LegacyMigrationResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	accounts := make(map[string]struct{})
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if account, ok := legacyAccountName(entry.Name()); ok {
+			accounts[account] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(accounts))
+	for account := range accounts {
+		names = append(names, account)
+	}
+	sort.Strings(names)
+
+	results := make([]LegacyMigrationResult, 0, len(names))
+	var errs []error
+	for _, account := range names {
+		result, errMigrate := migrateLegacyAccount(dir, account, account)
+		if errMigrate != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", account, errMigrate))
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+// migrateLegacyFor imports legacy files for the account backing boltPath, if any exist,
+// registering conversations in the global index under label.
+func migrateLegacyFor(boltPath, label string) {
+	dir := filepath.Dir(boltPath)
+	account := strings.TrimSuffix(filepath.Base(boltPath), ".bolt")
+	if !legacyFilesExist(dir, account) {
+		return
+	}
+	result, err := migrateLegacyAccount(dir, account, label)
+	if err != nil {
+		log.Warnf("gemini web: failed to migrate legacy conversation files for %s: %v", account, err)
+		return
+	}
+	log.Infof("gemini web: migrated legacy conversation files for %s (%d metadata entries, %d conversations, %d skipped)",
+		account, result.MetaEntries, result.Conversations, result.Skipped)
+}
+
+func legacyAccountName(fileName string) (string, bool) {
+	for _, suffix := range []string{legacyConvStoreSuffix, legacyConvDataSuffix} {
+		if account, ok := strings.CutSuffix(fileName, suffix); ok && account != "" {
+			return account, true
+		}
+	}
+	return "", false
+}
+
+func legacyFilesExist(dir, account string) bool {
+	for _, suffix := range []string{legacyConvStoreSuffix, legacyConvDataSuffix} {
+		if _, err := os.Stat(filepath.Join(dir, account+suffix)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func migrateLegacyAccount(dir, account, label string) (LegacyMigrationResult, error) {
+	if strings.TrimSpace(label) == "" {
+		label = account
+	}
+	boltPath := filepath.Join(dir, account+".bolt")
+	result := LegacyMigrationResult{Account: account, BoltPath: boltPath}
+
+	metaPath := filepath.Join(dir, account+legacyConvStoreSuffix)
+	dataPath := filepath.Join(dir, account+legacyConvDataSuffix)
+
+	var legacyMeta map[string][]string
+	if raw, err := os.ReadFile(metaPath); err == nil {
+		if err = json.Unmarshal(raw, &legacyMeta); err != nil {
+			return result, fmt.Errorf("parse %s: %w", metaPath, err)
+		}
+		result.Files = append(result.Files, metaPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return result, err
+	}
+
+	var legacyData legacyConvData
+	if raw, err := os.ReadFile(dataPath); err == nil {
+		if err = json.Unmarshal(raw, &legacyData); err != nil {
+			return result, fmt.Errorf("parse %s: %w", dataPath, err)
+		}
+		result.Files = append(result.Files, dataPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return result, err
+	}
+	if len(result.Files) == 0 {
+		return result, nil
+	}
+
+	if len(legacyMeta) > 0 {
+		store, err := LoadConvStore(boltPath)
+		if err != nil {
+			return result, err
+		}
+		for key, value := range legacyMeta {
+			if _, exists := store[key]; exists {
+				result.Skipped++
+				continue
+			}
+			store[key] = value
+			result.MetaEntries++
+		}
+		if err = SaveConvStore(boltPath, store); err != nil {
+			return result, err
+		}
+	}
+
+	if len(legacyData.Items) > 0 {
+		items, index, err := LoadConvData(boltPath)
+		if err != nil {
+			return result, err
+		}
+		for _, rec := range legacyData.Items {
+			if len(rec.Messages) == 0 || strings.TrimSpace(rec.ClientID) == "" {
+				result.Skipped++
+				continue
+			}
+			stableHash := conversation.HashConversationForAccount(rec.ClientID, rec.Model, rec.Messages)
+			if _, exists := items[stableHash]; exists {
+				result.Skipped++
+				continue
+			}
+			upgraded, errMigrate := migrateConversationRecord(rec)
+			if errMigrate != nil {
+				log.Warnf("gemini web: skipping legacy conversation of %s: %v", account, errMigrate)
+				result.Skipped++
+				continue
+			}
+			rec = upgraded
+			stableHash = indexConversationRecord(index, account, rec)
+			items[stableHash] = rec
+			if errStore := conversation.StoreConversation(label, rec.Model, conversation.StoredToMessages(rec.Messages), rec.Metadata); errStore != nil {
+				log.Debugf("gemini web: failed to index migrated conversation: %v", errStore)
+			}
+			result.Conversations++
+		}
+		if err = SaveConvData(boltPath, items, index); err != nil {
+			return result, err
+		}
+	}
+
+	for _, file := range result.Files {
+		if err := os.Rename(file, file+migratedSuffix); err != nil {
+			return result, fmt.Errorf("mark %s migrated: %w", file, err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package geminiwebapi
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	log "github.com/sirupsen/logrus"
+)
+
+// Conversation matches are keyed by model, so a client that switches a chat from one model to
+// another finds no conversation to continue. With gemini-web.model-migration enabled, a history
+// recorded under another model is replayed to the new model in a new upstream conversation,
+// and the record answering it replaces the old one.
+
+// migrationSource returns the record of another model whose history the incoming messages
+// extend, preferring the longest one.
+func (s *GeminiWebState) migrationSource(model string, msgs []RoleText) (string, bool) {
+	if len(msgs) < 3 {
+		return "", false
+	}
+	type candidate struct {
+		id       string
+		messages int
+	}
+	var candidates []candidate
+	s.convMu.RLock()
+	for id, header := range s.convHeaders {
+		if strings.EqualFold(strings.TrimSpace(header.Model), strings.TrimSpace(model)) {
+			continue
+		}
+		if header.Messages < 2 || header.Messages >= len(msgs) {
+			continue
+		}
+		candidates = append(candidates, candidate{id: id, messages: header.Messages})
+	}
+	s.convMu.RUnlock()
+	// Longest first, so the first record the messages extend is the best one.
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].messages > candidates[j].messages })
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.id
+	}
+	bestID := ""
+	s.scanConversations(ids, func(id string, rec ConversationRecord) bool {
+		history := s.recordHistory(rec, msgs)
+		if len(rec.Messages) < 2 || len(history) != len(rec.Messages) || !conversation.EqualMessages(history, msgs[:len(history)]) {
+			return true
+		}
+		bestID = id
+		return false
+	})
+	return bestID, bestID != ""
+}
+
+// completeMigration deletes the record a migrated conversation was replayed from, once the new
+// model's record has been stored.
+func (s *GeminiWebState) completeMigration(ctx context.Context, from, model string) {
+	if err := s.DeleteConversation(ctx, from, "model-migration", true); err != nil && !errors.Is(err, ErrConversationNotFound) {
+		log.Debugf("gemini web: failed to remove conversation migrated to %s: %v", model, err)
+	}
+}
+
+func (s *GeminiWebState) migrateAcrossModels() bool {
+	cfg := s.config()
+	return cfg != nil && cfg.GeminiWeb.ModelMigration
+}
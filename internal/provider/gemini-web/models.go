@@ -17,6 +17,17 @@ const (
 	EndpointGenerate      = "https://gemini.google.com/_/BardChatUi/data/assistant.lamda.BardFrontendService/StreamGenerate"
 	EndpointRotateCookies = "https://accounts.google.com/RotateCookies"
 	EndpointUpload        = "https://content-push.googleapis.com/upload"
+	EndpointBatchExec     = "https://gemini.google.com/_/BardChatUi/data/batchexecute"
+)
+
+// Batch execute RPC identifiers ---------------------------------------------
+const (
+	RPCListGems  = "CNgdBe"
+	RPCCreateGem = "oMH3Zd"
+	RPCUpdateGem = "kHv0Vd"
+	RPCDeleteGem = "UXcSJb"
+
+	RPCDeleteChat = "GzXR5e"
 )
 
 var (
@@ -126,6 +137,19 @@ type ConversationRecord struct {
 	Messages  []StoredMessage `json:"messages"`
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
+
+	// Summary caches the compacted form of the older messages.
+	Summary *HistorySummary `json:"summary,omitempty"`
+	// SchemaVersion is the conversation.SchemaVersion the record was written with.
+	SchemaVersion int `json:"schema_version,omitempty"`
+}
+
+// HistorySummary is a summary of the first Messages messages of a conversation, identified by
+// the digest of those messages.
+type HistorySummary struct {
+	Messages int    `json:"messages"`
+	Digest   string `json:"digest"`
+	Text     string `json:"text"`
 }
 
 type Candidate struct {
@@ -215,7 +239,12 @@ func (e *AuthError) Error() string {
 	return e.Msg
 }
 
-type APIError struct{ Msg string }
+// APIError is an unexpected reply from Gemini. Status holds the HTTP status when the
+// request was answered with a non-200 status.
+type APIError struct {
+	Msg    string
+	Status int
+}
 
 func (e *APIError) Error() string {
 	if e.Msg == "" {
@@ -237,6 +266,15 @@ func (e *GeminiError) Error() string {
 
 type TimeoutError struct{ GeminiError }
 
+// PartialOutputError reports a reply cut off by a timeout after part of it arrived. Output
+// holds that part.
+type PartialOutputError struct {
+	TimeoutError
+	Output ModelOutput
+}
+
+func (e *PartialOutputError) Unwrap() error { return &e.TimeoutError }
+
 type UsageLimitExceeded struct{ GeminiError }
 
 type ModelInvalid struct{ GeminiError }
@@ -0,0 +1,172 @@
+package geminiwebapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	watermarkZero  = '\u200b' // zero width space encodes a 0 bit
+	watermarkOne   = '\u200c' // zero width non-joiner encodes a 1 bit
+	watermarkFence = '\u2060' // word joiner marks the start and end of the token
+
+	// JSON replies carry the watermark as JSON whitespace so they stay valid: a space
+	// encodes a 0 bit, a tab a 1 bit, and carriage returns fence the token.
+	jsonWatermarkZero  = ' '
+	jsonWatermarkOne   = '\t'
+	jsonWatermarkFence = '\r'
+)
+
+// outputPolicyFor returns the first output policy matching apiKey.
+func outputPolicyFor(cfg *config.Config, apiKey string) *config.GeminiWebOutputPolicy {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.GeminiWeb.OutputPolicies {
+		policy := &cfg.GeminiWeb.OutputPolicies[i]
+		if len(policy.APIKeys) == 0 || (apiKey != "" && matchesAny(policy.APIKeys, apiKey)) {
+			return policy
+		}
+	}
+	return nil
+}
+
+// applyOutputPolicy mutates the chosen candidate text so that the response and the
+// persisted conversation both carry the footer and watermark the client will see.
+func (s *GeminiWebState) applyOutputPolicy(ctx context.Context, modelName string, output *ModelOutput) {
+	if output == nil || len(output.Candidates) == 0 {
+		return
+	}
+	policy := outputPolicyFor(s.config(), apiKeyFromContext(ctx))
+	if policy == nil {
+		return
+	}
+	text := output.Candidates[output.Chosen].Text
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	if policy.Watermark != "" {
+		text = EmbedWatermark(text, policy.Watermark)
+	}
+	if footer := strings.TrimSpace(policy.Footer); footer != "" {
+		text = strings.TrimRight(text, "\n") + "\n\n" + strings.ReplaceAll(footer, "{model}", modelName)
+	}
+	output.Candidates[output.Chosen].Text = text
+}
+
+// applyJSONOutputPolicy embeds the watermark of the caller's output policy in a validated
+// JSON reply without breaking it. Footers cannot be added to JSON, so checkJSONOutputPolicy
+// rejects JSON mode for keys whose policy has one.
+func (s *GeminiWebState) applyJSONOutputPolicy(ctx context.Context, output *ModelOutput) {
+	if output == nil || len(output.Candidates) == 0 {
+		return
+	}
+	policy := outputPolicyFor(s.config(), apiKeyFromContext(ctx))
+	if policy == nil || policy.Watermark == "" {
+		return
+	}
+	output.Candidates[output.Chosen].Text = EmbedJSONWatermark(output.Candidates[output.Chosen].Text, policy.Watermark)
+}
+
+// checkJSONOutputPolicy returns an error when the caller asked for a JSON reply but its
+// output policy appends a footer, which a JSON reply cannot carry.
+func (s *GeminiWebState) checkJSONOutputPolicy(ctx context.Context, original, payload []byte) error {
+	policy := outputPolicyFor(s.config(), apiKeyFromContext(ctx))
+	if policy == nil || strings.TrimSpace(policy.Footer) == "" {
+		return nil
+	}
+	if responseFormatFrom(original, payload) == nil {
+		return nil
+	}
+	return fmt.Errorf("response_format is not available for this API key: its output policy adds a footer")
+}
+
+// EmbedWatermark inserts token, encoded as invisible zero-width characters, after the first
+// word of text so it survives trimming and simple copy/paste.
+func EmbedWatermark(text, token string) string {
+	var b strings.Builder
+	b.WriteRune(watermarkFence)
+	for _, octet := range []byte(token) {
+		for bit := 7; bit >= 0; bit-- {
+			if octet&(1<<uint(bit)) != 0 {
+				b.WriteRune(watermarkOne)
+			} else {
+				b.WriteRune(watermarkZero)
+			}
+		}
+	}
+	b.WriteRune(watermarkFence)
+	mark := b.String()
+	if idx := strings.IndexAny(text, " \n"); idx > 0 {
+		return text[:idx] + mark + text[idx:]
+	}
+	return text + mark
+}
+
+// EmbedJSONWatermark inserts token, encoded as JSON whitespace, after the opening bracket
+// of a JSON reply. Text that is not a JSON object or array is returned unchanged.
+func EmbedJSONWatermark(text, token string) string {
+	if text == "" || (text[0] != '{' && text[0] != '[') {
+		return text
+	}
+	var b strings.Builder
+	b.WriteByte(text[0])
+	b.WriteRune(jsonWatermarkFence)
+	for _, octet := range []byte(token) {
+		for bit := 7; bit >= 0; bit-- {
+			if octet&(1<<uint(bit)) != 0 {
+				b.WriteRune(jsonWatermarkOne)
+			} else {
+				b.WriteRune(jsonWatermarkZero)
+			}
+		}
+	}
+	b.WriteRune(jsonWatermarkFence)
+	b.WriteString(text[1:])
+	return b.String()
+}
+
+// ExtractWatermark decodes a token previously embedded with EmbedWatermark or
+// EmbedJSONWatermark.
+func ExtractWatermark(text string) (string, bool) {
+	if token, ok := extractWatermark(text, watermarkFence, watermarkZero, watermarkOne); ok {
+		return token, true
+	}
+	return extractWatermark(text, jsonWatermarkFence, jsonWatermarkZero, jsonWatermarkOne)
+}
+
+func extractWatermark(text string, fence, zero, one rune) (string, bool) {
+	start := strings.IndexRune(text, fence)
+	if start < 0 {
+		return "", false
+	}
+	rest := text[start+len(string(fence)):]
+	end := strings.IndexRune(rest, fence)
+	if end < 0 {
+		return "", false
+	}
+	var (
+		out   []byte
+		octet byte
+		bits  int
+	)
+	for _, r := range rest[:end] {
+		switch r {
+		case zero:
+			octet <<= 1
+		case one:
+			octet = octet<<1 | 1
+		default:
+			return "", false
+		}
+		bits++
+		if bits == 8 {
+			out = append(out, octet)
+			octet, bits = 0, 0
+		}
+	}
+	return string(out), len(out) > 0
+}
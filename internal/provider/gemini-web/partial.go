@@ -0,0 +1,35 @@
+package geminiwebapi
+
+import (
+	"errors"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Finish reasons reported for a reply cut off by a timeout.
+const (
+	partialFinishLength  = "length"
+	partialFinishTimeout = "timeout"
+)
+
+// partialOutput returns the part of the reply received before err when err is a timeout that
+// cut off a reply and gemini-web.partial-on-timeout is enabled, with the finish reason to
+// report. The chat continues from the partial reply, so it is stored like a complete one.
+func (s *GeminiWebState) partialOutput(prep *geminiWebPrepared, err error) (ModelOutput, string, bool) {
+	cfg := s.config()
+	if cfg == nil || !cfg.GeminiWeb.PartialOnTimeout.Enabled || prep.format != nil {
+		return ModelOutput{}, "", false
+	}
+	var partial *PartialOutputError
+	if !errors.As(err, &partial) {
+		return ModelOutput{}, "", false
+	}
+	reason := partialFinishLength
+	if strings.EqualFold(strings.TrimSpace(cfg.GeminiWeb.PartialOnTimeout.FinishReason), partialFinishTimeout) {
+		reason = partialFinishTimeout
+	}
+	prep.chat.accept(partial.Output)
+	log.Warnf("gemini web account %s: reply timed out, returning the %d characters received", s.Label(), len([]rune(partial.Output.Text())))
+	return partial.Output, reason, true
+}
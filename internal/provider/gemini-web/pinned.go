@@ -0,0 +1,85 @@
+package geminiwebapi
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// ExtractPinnedMessages returns the messages the client marked with the vendor extension
+// `"x_cliproxy": {"pinned": true}` in the original request. Both OpenAI/Claude style
+// "messages" and Gemini style "contents" arrays are recognised.
+func ExtractPinnedMessages(original []byte) []RoleText {
+	if !bytes.Contains(original, []byte("x_cliproxy")) {
+		return nil
+	}
+	var pinned []RoleText
+	for _, path := range []string{"messages", "contents"} {
+		gjson.GetBytes(original, path).ForEach(func(_, msg gjson.Result) bool {
+			if !msg.Get("x_cliproxy.pinned").Bool() {
+				return true
+			}
+			role := strings.ToLower(strings.TrimSpace(msg.Get("role").String()))
+			if role == "model" {
+				role = "assistant"
+			}
+			if role == "" {
+				role = "user"
+			}
+			if text := pinnedMessageText(msg); text != "" {
+				pinned = append(pinned, RoleText{Role: role, Text: text})
+			}
+			return true
+		})
+	}
+	return pinned
+}
+
+func pinnedMessageText(msg gjson.Result) string {
+	content := msg.Get("content")
+	if content.Type == gjson.String {
+		return strings.TrimSpace(content.String())
+	}
+	parts := content
+	if !parts.IsArray() {
+		parts = msg.Get("parts")
+	}
+	var b strings.Builder
+	parts.ForEach(func(_, part gjson.Result) bool {
+		if text := part.Get("text"); text.Exists() && strings.TrimSpace(text.String()) != "" {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(text.String())
+		}
+		return true
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// PromotePinnedMessages moves pinned messages to the front of msgs, keeping their relative
+// order, so they are replayed first whenever a remote thread starts over. Pinned messages
+// that are not part of msgs are prepended as well.
+func PromotePinnedMessages(msgs, pinned []RoleText) []RoleText {
+	if len(pinned) == 0 {
+		return msgs
+	}
+	out := make([]RoleText, 0, len(msgs)+len(pinned))
+	used := make([]bool, len(msgs))
+	for _, pin := range pinned {
+		for i, msg := range msgs {
+			if !used[i] && strings.EqualFold(msg.Role, pin.Role) && strings.TrimSpace(msg.Text) == pin.Text {
+				used[i] = true
+				break
+			}
+		}
+		out = append(out, pin)
+	}
+	for i, msg := range msgs {
+		if !used[i] {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
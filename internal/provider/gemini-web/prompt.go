@@ -1,6 +1,8 @@
 package geminiwebapi
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"regexp"
@@ -13,7 +15,8 @@ import (
 )
 
 var (
-	reXMLAnyTag = regexp.MustCompile(`(?s)<\s*[^>]+>`)
+	reXMLAnyTag  = regexp.MustCompile(`(?s)<\s*[^>]+>`)
+	reThinkBlock = regexp.MustCompile(`(?is)<think>(.*?)</think>`)
 )
 
 // NormalizeRole converts a role to a standard format (lowercase, 'model' -> 'assistant').
@@ -80,6 +83,36 @@ func RemoveThinkTags(s string) string {
 	return conversation.RemoveThinkTags(s)
 }
 
+// SeparateThinking moves the <think> blocks of each candidate's reply text into its thoughts,
+// after any thoughts Gemini returned natively, so they reach clients as reasoning content
+// instead of inline text.
+func SeparateThinking(output *ModelOutput) {
+	if output == nil {
+		return
+	}
+	for i := range output.Candidates {
+		c := &output.Candidates[i]
+		blocks := reThinkBlock.FindAllStringSubmatch(c.Text, -1)
+		if len(blocks) == 0 {
+			continue
+		}
+		var thoughts []string
+		if c.Thoughts != nil && strings.TrimSpace(*c.Thoughts) != "" {
+			thoughts = append(thoughts, strings.TrimSpace(*c.Thoughts))
+		}
+		for _, b := range blocks {
+			if t := strings.TrimSpace(b[1]); t != "" {
+				thoughts = append(thoughts, t)
+			}
+		}
+		c.Text = RemoveThinkTags(c.Text)
+		if len(thoughts) > 0 {
+			joined := strings.Join(thoughts, "\n\n")
+			c.Thoughts = &joined
+		}
+	}
+}
+
 // SanitizeAssistantMessages removes think tags from assistant messages.
 func SanitizeAssistantMessages(msgs []RoleText) []RoleText {
 	cleaned := conversation.SanitizeAssistantMessages(msgs)
@@ -163,12 +196,16 @@ func MaxCharsPerRequest(cfg *config.Config) int {
 	return 1_000_000
 }
 
-func SendWithSplit(chat *ChatSession, text string, files []string, cfg *config.Config) (ModelOutput, error) {
+// SendWithSplit sends text, split into chunks when it exceeds the configured size. Each
+// upstream call is retried on transient errors per gemini-web.retry.
+func SendWithSplit(ctx context.Context, chat *ChatSession, text string, files []string, cfg *config.Config) (ModelOutput, error) {
 	// Validate chat session
 	if chat == nil {
 		return ModelOutput{}, fmt.Errorf("nil chat session")
 	}
 
+	policy := retryPolicyFor(cfg)
+
 	// Resolve maxChars characters per request
 	maxChars := MaxCharsPerRequest(cfg)
 	if maxChars <= 0 {
@@ -177,7 +214,7 @@ func SendWithSplit(chat *ChatSession, text string, files []string, cfg *config.C
 
 	// If within limit, send directly
 	if utf8.RuneCountInString(text) <= maxChars {
-		return chat.SendMessage(text, files)
+		return sendMessageWithRetry(ctx, chat, text, files, policy)
 	}
 
 	// Decide whether to use continuation hint (enabled by default)
@@ -210,11 +247,16 @@ func SendWithSplit(chat *ChatSession, text string, files []string, cfg *config.C
 		if useHint {
 			part += continuationHint
 		}
-		if _, err := chat.SendMessage(part, nil); err != nil {
+		if _, err := sendMessageWithRetry(ctx, chat, part, nil, policy); err != nil {
+			var partial *PartialOutputError
+			if errors.As(err, &partial) {
+				// Only a partial reply to the last chunk answers the request.
+				return ModelOutput{}, &partial.TimeoutError
+			}
 			return ModelOutput{}, err
 		}
 	}
 
 	// Send final chunk with files and return the actual output
-	return chat.SendMessage(chunks[len(chunks)-1], files)
+	return sendMessageWithRetry(ctx, chat, chunks[len(chunks)-1], files, policy)
 }
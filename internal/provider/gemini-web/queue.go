@@ -0,0 +1,202 @@
+package geminiwebapi
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	defaultQueueConcurrency = 1
+	defaultQueueDepth       = 32
+	defaultQueueTimeout     = 2 * time.Minute
+)
+
+// QueueStatus is a snapshot of an account's request queue.
+type QueueStatus struct {
+	Active         int   `json:"active"`
+	Queued         int   `json:"queued"`
+	MaxConcurrency int   `json:"max_concurrency"`
+	MaxDepth       int   `json:"max_depth"`
+	Served         int64 `json:"served"`
+	Rejected       int64 `json:"rejected"`
+	TimedOut       int64 `json:"timed_out"`
+	AverageHoldMs  int64 `json:"average_hold_ms"`
+}
+
+// QueueError reports a request that could not get a slot on the account. RetryAfter
+// estimates when a slot is likely to be free.
+type QueueError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *QueueError) Error() string {
+	return fmt.Sprintf("gemini web account busy: %s, retry in %s", e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+type queueSettings struct {
+	concurrency int
+	depth       int
+	timeout     time.Duration
+}
+
+func queueSettingsFor(cfg *config.Config) queueSettings {
+	s := queueSettings{concurrency: defaultQueueConcurrency, depth: defaultQueueDepth, timeout: defaultQueueTimeout}
+	if cfg == nil {
+		return s
+	}
+	q := cfg.GeminiWeb.Queue
+	if q.MaxConcurrency > 0 {
+		s.concurrency = q.MaxConcurrency
+	}
+	if q.MaxDepth > 0 {
+		s.depth = q.MaxDepth
+	}
+	if q.TimeoutSeconds > 0 {
+		s.timeout = time.Duration(q.TimeoutSeconds) * time.Second
+	}
+	return s
+}
+
+// requestQueue admits up to limit concurrent requests per account and lets the rest wait
+// in FIFO order.
+type requestQueue struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	waiters *list.List // of chan struct{}, closed when the waiter is granted a slot
+
+	served   int64
+	rejected int64
+	timedOut int64
+	// avgHold is a moving average of how long a slot is held, used for Retry-After.
+	avgHold time.Duration
+}
+
+// acquire waits for a slot and returns the function that releases it.
+func (q *requestQueue) acquire(ctx context.Context, s queueSettings) (func(), error) {
+	q.mu.Lock()
+	if q.waiters == nil {
+		q.waiters = list.New()
+	}
+	q.limit = s.concurrency
+	if q.active < q.limit && q.waiters.Len() == 0 {
+		q.active++
+		q.mu.Unlock()
+		return q.releaser(), nil
+	}
+	if q.waiters.Len() >= s.depth {
+		q.rejected++
+		err := &QueueError{Reason: "queue full", RetryAfter: q.retryAfterLocked()}
+		q.mu.Unlock()
+		return nil, err
+	}
+	ready := make(chan struct{})
+	elem := q.waiters.PushBack(ready)
+	q.dispatchLocked()
+	q.mu.Unlock()
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-ready:
+		return q.releaser(), nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-ready:
+		// The slot was granted while giving up; hand it on.
+		q.active--
+		q.dispatchLocked()
+	default:
+		q.waiters.Remove(elem)
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.timedOut++
+	return nil, &QueueError{Reason: "queue timeout", RetryAfter: q.retryAfterLocked()}
+}
+
+func (q *requestQueue) releaser() func() {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			hold := time.Since(start)
+			if q.avgHold == 0 {
+				q.avgHold = hold
+			} else {
+				q.avgHold = (q.avgHold*4 + hold) / 5
+			}
+			q.served++
+			q.active--
+			q.dispatchLocked()
+		})
+	}
+}
+
+// dispatchLocked grants free slots to waiters in arrival order.
+func (q *requestQueue) dispatchLocked() {
+	for q.active < q.limit && q.waiters.Len() > 0 {
+		front := q.waiters.Front()
+		q.waiters.Remove(front)
+		q.active++
+		close(front.Value.(chan struct{}))
+	}
+}
+
+// retryAfterLocked estimates the wait until a new request would be served.
+func (q *requestQueue) retryAfterLocked() time.Duration {
+	limit := q.limit
+	if limit <= 0 {
+		limit = 1
+	}
+	wait := q.avgHold * time.Duration(q.waiters.Len()+1) / time.Duration(limit)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+func (q *requestQueue) status(s queueSettings) QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	st := QueueStatus{
+		Active:         q.active,
+		MaxConcurrency: s.concurrency,
+		MaxDepth:       s.depth,
+		Served:         q.served,
+		Rejected:       q.rejected,
+		TimedOut:       q.timedOut,
+		AverageHoldMs:  q.avgHold.Milliseconds(),
+	}
+	if q.waiters != nil {
+		st.Queued = q.waiters.Len()
+	}
+	return st
+}
+
+// AcquireSlot waits in the account's queue until the request may be sent. The returned
+// function must be called once the request is finished.
+func (s *GeminiWebState) AcquireSlot(ctx context.Context) (func(), error) {
+	return s.queue.acquire(ctx, queueSettingsFor(s.config()))
+}
+
+// QueueStatus returns the request queue state of this account.
+func (s *GeminiWebState) QueueStatus() QueueStatus {
+	return s.queue.status(queueSettingsFor(s.config()))
+}
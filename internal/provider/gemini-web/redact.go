@@ -0,0 +1,254 @@
+package geminiwebapi
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	storageModeRedact         = "redact"
+	defaultRedactPrefixRunes  = 16
+	generatedRedactSaltFile   = "redact.salt"
+	generatedRedactSaltLength = 32
+)
+
+var (
+	redactSaltMu    sync.Mutex
+	redactSaltCache = make(map[string][]byte)
+)
+
+// messageRedactor replaces message text with a salted digest and a short prefix. Lookups
+// still go through the conversation index, whose hashes are computed before redaction;
+// the digests let the incoming history be aligned with a redacted record afterwards.
+type messageRedactor struct {
+	salt        []byte
+	prefixRunes int
+}
+
+// newMessageRedactor returns the redactor configured for the conversation stores next to
+// convPath, or nil when messages are stored as sent.
+func newMessageRedactor(cfg *config.Config, convPath string) *messageRedactor {
+	if cfg == nil || !strings.EqualFold(strings.TrimSpace(cfg.GeminiWeb.Storage.Mode), storageModeRedact) {
+		return nil
+	}
+	storage := cfg.GeminiWeb.Storage
+	salt := []byte(storage.Salt)
+	if len(salt) == 0 {
+		var err error
+		if salt, err = generatedRedactSalt(filepath.Dir(convPath)); err != nil {
+			log.Errorf("gemini web: redact mode unavailable, cannot load salt: %v", err)
+			return nil
+		}
+	}
+	prefix := storage.PrefixChars
+	switch {
+	case prefix == 0:
+		prefix = defaultRedactPrefixRunes
+	case prefix < 0:
+		prefix = 0
+	}
+	return &messageRedactor{salt: salt, prefixRunes: prefix}
+}
+
+// generatedRedactSalt loads, or creates on first use, the salt shared by every account
+// store in dir.
+func generatedRedactSalt(dir string) ([]byte, error) {
+	redactSaltMu.Lock()
+	defer redactSaltMu.Unlock()
+	if salt, ok := redactSaltCache[dir]; ok {
+		return salt, nil
+	}
+	path := filepath.Join(dir, generatedRedactSaltFile)
+	data, err := os.ReadFile(path)
+	if err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		salt := []byte(strings.TrimSpace(string(data)))
+		redactSaltCache[dir] = salt
+		return salt, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	raw := make([]byte, generatedRedactSaltLength)
+	if _, err = rand.Read(raw); err != nil {
+		return nil, err
+	}
+	salt := []byte(hex.EncodeToString(raw))
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(path, salt, 0o600); err != nil {
+		return nil, err
+	}
+	redactSaltCache[dir] = salt
+	return salt, nil
+}
+
+func (r *messageRedactor) digest(role, text string) string {
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(role))))
+	mac.Write([]byte{0})
+	mac.Write([]byte(text))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// redact returns msgs with every plaintext message replaced by its redacted form.
+func (r *messageRedactor) redact(msgs []conversation.StoredMessage) []conversation.StoredMessage {
+	out := make([]conversation.StoredMessage, len(msgs))
+	for i, msg := range msgs {
+		if msg.Digest == "" {
+			msg.Digest = r.digest(msg.Role, msg.Content)
+			msg.Content = runePrefix(msg.Content, r.prefixRunes)
+		}
+		out[i] = msg
+	}
+	return out
+}
+
+// redactRecord reports whether rec held plaintext and returns its redacted form.
+func (r *messageRedactor) redactRecord(rec ConversationRecord) (ConversationRecord, bool) {
+	changed := false
+	for _, msg := range rec.Messages {
+		if msg.Digest == "" {
+			changed = true
+			break
+		}
+	}
+	if changed {
+		rec.Messages = r.redact(rec.Messages)
+	}
+	return rec, changed
+}
+
+// matches reports whether an incoming message is the one stored as msg. Assistant text is
+// also compared without think tags, as the stored reply was recorded without them.
+func (r *messageRedactor) matches(msg conversation.StoredMessage, in RoleText) bool {
+	if !strings.EqualFold(strings.TrimSpace(msg.Role), strings.TrimSpace(in.Role)) {
+		return false
+	}
+	candidates := []string{in.Text}
+	if strings.EqualFold(strings.TrimSpace(in.Role), "assistant") {
+		if sanitized := conversation.RemoveThinkTags(in.Text); sanitized != in.Text {
+			candidates = append(candidates, sanitized)
+		}
+	}
+	for _, text := range candidates {
+		if msg.Digest == "" {
+			if msg.Content == text {
+				return true
+			}
+			continue
+		}
+		// The kept prefix rules out most mismatches without hashing the full text.
+		if strings.HasPrefix(text, msg.Content) && hmac.Equal([]byte(r.digest(msg.Role, text)), []byte(msg.Digest)) {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreHistory aligns a redacted record with the incoming messages and returns the
+// longest stored suffix that the client resent, in the client's plaintext. Earlier
+// messages cannot be recovered and are left out.
+func (r *messageRedactor) restoreHistory(stored []conversation.StoredMessage, incoming []RoleText) []RoleText {
+	max := len(stored)
+	if len(incoming) < max {
+		max = len(incoming)
+	}
+	for overlap := max; overlap > 0; overlap-- {
+		tail := stored[len(stored)-overlap:]
+		aligned := true
+		for i := range tail {
+			if !r.matches(tail[i], incoming[i]) {
+				aligned = false
+				break
+			}
+		}
+		if aligned {
+			return cloneRoleTextSlice(incoming[:overlap])
+		}
+	}
+	return nil
+}
+
+func runePrefix(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
+
+// convertToRedacted redacts the records and trashed conversations of the store at path that
+// were written in plain text, then removes the message blobs only they referenced and
+// compacts both files so the plain text does not linger in freed pages.
+func (s *GeminiWebState) convertToRedacted(redactor *messageRedactor, path string) {
+	changed := false
+	keys := make([]string, 0, len(s.convHeaders))
+	for key := range s.convHeaders {
+		keys = append(keys, key)
+	}
+	// Records are converted a batch at a time so the store is never read into memory whole.
+	for start := 0; start < len(keys); start += conversationScanBatch {
+		records, err := loadConvRecords(path, keys[start:min(start+conversationScanBatch, len(keys))])
+		if err != nil {
+			log.Warnf("gemini web: failed to read conversations for redaction: %v", err)
+			return
+		}
+		redacted := make(map[string]ConversationRecord)
+		for key, rec := range records {
+			if converted, ok := redactor.redactRecord(rec); ok {
+				redacted[key] = converted
+			}
+		}
+		if errWrite := writeConvRecords(path, redacted, nil, nil, nil); errWrite != nil {
+			log.Warnf("gemini web: failed to store redacted conversations: %v", errWrite)
+			return
+		}
+		changed = changed || len(redacted) > 0
+	}
+	deleted, err := LoadDeletedConversations(path)
+	if err != nil {
+		log.Warnf("gemini web: failed to read deleted conversations for redaction: %v", err)
+		return
+	}
+	for _, d := range deleted {
+		if redacted, ok := redactor.redactRecord(d.Record); ok {
+			d.Record = redacted
+			if errPut := putDeletedConversation(path, d); errPut != nil {
+				log.Warnf("gemini web: failed to redact deleted conversation %s: %v", d.ID, errPut)
+				return
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	dir := filepath.Dir(path)
+	if _, removed, errGC := CollectConversationBlobs(dir); errGC != nil {
+		log.Warnf("gemini web: plain-text message blobs kept, collection failed: %v", errGC)
+	} else if removed > 0 {
+		if errCompact := compactBoltFile(filepath.Join(dir, convBlobFile)); errCompact != nil {
+			log.Warnf("gemini web: failed to compact message blobs: %v", errCompact)
+		}
+	}
+	if errCompact := compactBoltFile(path); errCompact != nil {
+		log.Warnf("gemini web: failed to compact redacted store: %v", errCompact)
+	}
+}
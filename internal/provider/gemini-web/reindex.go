@@ -0,0 +1,272 @@
+package geminiwebapi
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ConvReindexResult summarises the rebuild of a conversation store index.
+type ConvReindexResult struct {
+	// Records is the number of records in the store.
+	Records int `json:"records"`
+	// IndexEntries is the number of entries in the rebuilt index.
+	IndexEntries int `json:"index_entries"`
+	// Added counts the entries that are new or now resolve to a different record.
+	Added int `json:"added"`
+	// Removed counts the previous entries that no longer exist.
+	Removed int `json:"removed"`
+	// Kept counts the records that could not be rehashed, stored redacted or with missing
+	// message blobs, and keep their previous entries.
+	Kept int `json:"kept"`
+
+	// normalization is the hash normalization fingerprint the index was computed with.
+	normalization string
+}
+
+// convNormalizationKey stores, in the schema bucket, the hash normalization fingerprint the
+// store's index was computed with.
+const convNormalizationKey = "normalization"
+
+// ReindexConversationStore rebuilds the index of the store at path from its records with the
+// current hashing rules. The account ID hashed into the index is the store's file name. The
+// store must not be in use; running servers reindex through GeminiWebState.ReindexConversations.
+func ReindexConversationStore(path string) (ConvReindexResult, error) {
+	return reindexConvStore(path, convStoreAccount(path))
+}
+
+// ReindexConversations rebuilds the index of the account's conversation store from its records
+// with the current hashing rules and account ID, after writing the records not saved yet.
+func (s *GeminiWebState) ReindexConversations() (ConvReindexResult, error) {
+	s.convSaveMu.Lock()
+	defer s.convSaveMu.Unlock()
+	return s.reindexConversationsLocked()
+}
+
+// ensureIndexNormalized rebuilds the index when the hash normalization settings changed since
+// it was computed, so that lookups hash the same way the index did.
+func (s *GeminiWebState) ensureIndexNormalized() {
+	current := conversation.NormalizationFingerprint()
+	s.convMu.RLock()
+	stale := s.convNormalization != current
+	s.convMu.RUnlock()
+	if !stale {
+		return
+	}
+	s.convSaveMu.Lock()
+	defer s.convSaveMu.Unlock()
+	s.convMu.RLock()
+	stale = s.convNormalization != current
+	s.convMu.RUnlock()
+	if !stale {
+		return
+	}
+	if result, err := s.reindexConversationsLocked(); err != nil {
+		log.Warnf("gemini web: failed to rebuild the conversation index of %s for the new hash normalization: %v", s.Label(), err)
+	} else {
+		log.Infof("gemini web: rebuilt the conversation index of %s for the new hash normalization (%d entries)", s.Label(), result.IndexEntries)
+	}
+}
+
+// reindexConversationsLocked implements ReindexConversations; convSaveMu must be held.
+func (s *GeminiWebState) reindexConversationsLocked() (ConvReindexResult, error) {
+	s.convMu.Lock()
+	err := s.ensureConvLoadedLocked()
+	s.convMu.Unlock()
+	if err == nil {
+		err = s.flushConversations(nil, nil)
+	}
+	if err != nil {
+		return ConvReindexResult{}, err
+	}
+	result, err := reindexConvStore(s.convPath(), s.accountID)
+	if err != nil {
+		return result, err
+	}
+	_, index, err := loadConvHeaders(s.convPath())
+	if err != nil {
+		return result, err
+	}
+	s.convMu.Lock()
+	for key, target := range s.convUnsavedIndex {
+		index[key] = target
+	}
+	s.convIndex = index
+	s.convNormalization = result.normalization
+	s.convMu.Unlock()
+	return result, nil
+}
+
+// syncConvIndexNormalization rebuilds the index of the store at path when it was computed with
+// other hash normalization settings, and returns the fingerprint of the settings it now uses.
+func syncConvIndexNormalization(path, accountID string) string {
+	current := conversation.NormalizationFingerprint()
+	stored, err := convIndexNormalization(path)
+	if err != nil || stored == current {
+		return stored
+	}
+	result, err := reindexConvStore(path, accountID)
+	if err != nil {
+		log.Warnf("gemini web: failed to rebuild the index of conversation store %s for the new hash normalization: %v", filepath.Base(path), err)
+		return stored
+	}
+	if result.Records > 0 {
+		log.Infof("gemini web: rebuilt the index of conversation store %s for the new hash normalization (%d entries)", filepath.Base(path), result.IndexEntries)
+	}
+	return result.normalization
+}
+
+// convIndexNormalization returns the hash normalization fingerprint the index of the store at
+// path was computed with. Stores without one predate normalization and were computed without.
+func convIndexNormalization(path string) (string, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return conversation.NormalizationFingerprint(), nil
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	fingerprint := ""
+	err = db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucketConvSchema)); b != nil {
+			fingerprint = string(b.Get([]byte(convNormalizationKey)))
+		}
+		return nil
+	})
+	return fingerprint, err
+}
+
+// convStoreAccount returns the account ID of the store at path, its file name without the
+// extension.
+func convStoreAccount(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// reindexConvStore replaces the index of the store at path with one computed from its records,
+// hashing accountID into the account-specific entries. Records stored redacted, or whose
+// message blobs are missing, cannot be rehashed: they are indexed under their own key and keep
+// the entries that resolved to them before.
+func reindexConvStore(path, accountID string) (ConvReindexResult, error) {
+	result := ConvReindexResult{normalization: conversation.NormalizationFingerprint()}
+	stored := make(map[string]storedConversationRecord)
+	previous := make(map[string]string)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second, ReadOnly: true})
+	if err != nil {
+		return result, err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte("conv_index")); b != nil {
+			if errIndex := b.ForEach(func(k, v []byte) error {
+				previous[string(k)] = string(v)
+				return nil
+			}); errIndex != nil {
+				return errIndex
+			}
+		}
+		b := tx.Bucket([]byte("conv_items"))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var rec storedConversationRecord
+			if len(v) > 0 && json.Unmarshal(v, &rec) == nil {
+				stored[string(k)] = rec
+			}
+			return nil
+		})
+	})
+	_ = db.Close()
+	if err != nil {
+		return result, err
+	}
+	items, _, err := resolveConversationRecords(path, stored)
+	if err != nil {
+		// Without the blobs the records can still be indexed under their own keys.
+		items = nil
+	}
+	index := make(map[string]string)
+	kept := make(map[string]bool)
+	for key := range stored {
+		index["hash:"+key] = key
+		rec, ok := items[key]
+		if !ok || recordRedacted(rec) {
+			kept[key] = true
+			continue
+		}
+		entries := make(map[string]string)
+		indexConversationRecord(entries, accountID, rec)
+		for entry := range entries {
+			index[entry] = key
+		}
+	}
+	for key, target := range previous {
+		if _, taken := index[key]; !taken && kept[target] {
+			index[key] = target
+		}
+	}
+	for key, target := range index {
+		if previous[key] != target {
+			result.Added++
+		}
+	}
+	for key := range previous {
+		if _, ok := index[key]; !ok {
+			result.Removed++
+		}
+	}
+	result.Records, result.IndexEntries, result.Kept = len(stored), len(index), len(kept)
+
+	db, err = bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	err = db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("conv_index")) != nil {
+			if errDelete := tx.DeleteBucket([]byte("conv_index")); errDelete != nil {
+				return errDelete
+			}
+		}
+		b, errCreate := tx.CreateBucket([]byte("conv_index"))
+		if errCreate != nil {
+			return errCreate
+		}
+		for key, target := range index {
+			if errPut := b.Put([]byte(key), []byte(target)); errPut != nil {
+				return errPut
+			}
+		}
+		schema, errSchema := tx.CreateBucketIfNotExists([]byte(bucketConvSchema))
+		if errSchema != nil {
+			return errSchema
+		}
+		return schema.Put([]byte(convNormalizationKey), []byte(result.normalization))
+	})
+	return result, err
+}
+
+// recordRedacted reports whether rec was stored in redact mode, so its messages no longer
+// hash to the index keys computed from the plain text.
+func recordRedacted(rec ConversationRecord) bool {
+	for _, msg := range rec.Messages {
+		if msg.Digest != "" {
+			return true
+		}
+	}
+	return false
+}
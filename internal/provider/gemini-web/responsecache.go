@@ -0,0 +1,139 @@
+package geminiwebapi
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+)
+
+const defaultResponseCacheEntries = 256
+
+// responseCacheIgnoredFields are top-level request fields that do not influence the
+// generated answer and would otherwise split identical prompts across cache keys.
+var responseCacheIgnoredFields = []string{"stream", "stream_options", "user", "metadata", "store", "request_id"}
+
+type responseCacheEntry struct {
+	key     string
+	payload []byte
+	expires time.Time
+}
+
+// responseCache is an LRU of converted Gemini responses shared by every account, since a
+// hit must not depend on which account the request was routed to.
+type responseCache struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+var sharedResponseCache = &responseCache{order: list.New(), entries: make(map[string]*list.Element)}
+
+func (c *responseCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*responseCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return bytes.Clone(entry.payload), true
+}
+
+func (c *responseCache) put(key string, payload []byte, ttl time.Duration, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*responseCacheEntry)
+		entry.payload = bytes.Clone(payload)
+		entry.expires = time.Now().Add(ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&responseCacheEntry{key: key, payload: bytes.Clone(payload), expires: time.Now().Add(ttl)})
+	for c.order.Len() > maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*responseCacheEntry).key)
+	}
+}
+
+// responseCacheSettings returns the cache TTL and capacity, with a zero TTL when caching
+// is disabled.
+func responseCacheSettings(cfg *config.Config) (time.Duration, int) {
+	if cfg == nil || cfg.GeminiWeb.ResponseCache.TTLSeconds <= 0 {
+		return 0, 0
+	}
+	maxEntries := cfg.GeminiWeb.ResponseCache.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultResponseCacheEntries
+	}
+	return time.Duration(cfg.GeminiWeb.ResponseCache.TTLSeconds) * time.Second, maxEntries
+}
+
+// responseCacheKey hashes the model, the client key and the request with its prompt and
+// sampling settings in canonical form. Requests that are not valid JSON are not cached.
+func responseCacheKey(ctx context.Context, modelName string, reqPayload []byte) (string, bool) {
+	var body map[string]any
+	if err := json.Unmarshal(reqPayload, &body); err != nil {
+		return "", false
+	}
+	for _, field := range responseCacheIgnoredFields {
+		delete(body, field)
+	}
+	canonical, err := json.Marshal(normalizeCacheValue(body))
+	if err != nil {
+		return "", false
+	}
+	handlerType := requestctx.HandlerType(ctx)
+	// Gems and output policies depend on the client key, so keys never share entries.
+	apiKey := conversation.Sha256Hex(apiKeyFromContext(ctx))
+	return conversation.Sha256Hex(strings.Join([]string{strings.ToLower(strings.TrimSpace(modelName)), handlerType, apiKey, string(canonical)}, "\x00")), true
+}
+
+// normalizeCacheValue trims surrounding whitespace from every string so prompts that
+// differ only in padding share an entry. Map keys are sorted by json.Marshal.
+func normalizeCacheValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, item := range value {
+			value[k] = normalizeCacheValue(item)
+		}
+		return value
+	case []any:
+		for i, item := range value {
+			value[i] = normalizeCacheValue(item)
+		}
+		return value
+	case string:
+		return strings.TrimSpace(value)
+	default:
+		return value
+	}
+}
+
+// cachedPrepared rebuilds the parts of a prepared request needed to convert a cached
+// response, without uploading files or opening a chat.
+func (s *GeminiWebState) cachedPrepared(ctx context.Context, modelName string, rawJSON []byte, stream bool, original []byte) *geminiWebPrepared {
+	res := &geminiWebPrepared{originalRaw: original, translatedRaw: bytes.Clone(rawJSON)}
+	if handlerType := requestctx.HandlerType(ctx); handlerType != "" {
+		res.handlerType = handlerType
+		res.translatedRaw = translator.Request(res.handlerType, constant.GeminiWeb, modelName, res.translatedRaw, stream)
+	}
+	return res
+}
@@ -0,0 +1,139 @@
+package geminiwebapi
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryInitial  = time.Second
+	defaultRetryMax      = 8 * time.Second
+
+	// Image generation failures are retried at most once, as before retries were configurable.
+	maxImageRetryAttempts = 2
+)
+
+// Retryable error classes, as named in gemini-web.retry.retry-on.
+const (
+	retryClassNetwork         = "network"
+	retryClassServer          = "server"
+	retryClassInvalidResponse = "invalid-response"
+	retryClassImage           = "image"
+)
+
+type retryPolicy struct {
+	attempts int
+	initial  time.Duration
+	max      time.Duration
+	// retryOn is nil when every class is retried.
+	retryOn map[string]bool
+}
+
+func retryPolicyFor(cfg *config.Config) retryPolicy {
+	p := retryPolicy{attempts: defaultRetryAttempts, initial: defaultRetryInitial, max: defaultRetryMax}
+	if cfg == nil {
+		return p
+	}
+	r := cfg.GeminiWeb.Retry
+	if r.MaxAttempts > 0 {
+		p.attempts = r.MaxAttempts
+	}
+	if r.InitialBackoffMs > 0 {
+		p.initial = time.Duration(r.InitialBackoffMs) * time.Millisecond
+	}
+	if r.MaxBackoffMs > 0 {
+		p.max = time.Duration(r.MaxBackoffMs) * time.Millisecond
+	}
+	if p.max < p.initial {
+		p.max = p.initial
+	}
+	for _, class := range r.RetryOn {
+		if class = strings.ToLower(strings.TrimSpace(class)); class != "" {
+			if p.retryOn == nil {
+				p.retryOn = make(map[string]bool)
+			}
+			p.retryOn[class] = true
+		}
+	}
+	return p
+}
+
+// classifyRetry returns the retry class of err, or "" when err is not transient. Rate
+// limits (TemporarilyBlocked, UsageLimitExceeded) are left to the account cooldown logic.
+func classifyRetry(err error) string {
+	var imgErr *ImageGenerationError
+	var apiErr *APIError
+	var timeoutErr *TimeoutError
+	switch {
+	case errors.As(err, &imgErr):
+		return retryClassImage
+	case errors.As(err, &timeoutErr):
+		return retryClassNetwork
+	case errors.As(err, &apiErr):
+		if apiErr.Status >= 500 {
+			return retryClassServer
+		}
+		if apiErr.Status == 0 {
+			return retryClassInvalidResponse
+		}
+	}
+	return ""
+}
+
+func (p retryPolicy) allows(class string, attempt int) bool {
+	if class == "" || attempt >= p.attempts {
+		return false
+	}
+	if class == retryClassImage && attempt >= maxImageRetryAttempts {
+		return false
+	}
+	return p.retryOn == nil || p.retryOn[class]
+}
+
+// backoff returns the delay before retry number attempt (1-based): exponential growth
+// capped at max, with equal jitter so concurrent retries spread out.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.initial
+	for i := 1; i < attempt && d < p.max; i++ {
+		d *= 2
+	}
+	if d > p.max {
+		d = p.max
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// sendMessageWithRetry sends one message, retrying transient failures under policy until
+// the attempts are exhausted or ctx is cancelled.
+func sendMessageWithRetry(ctx context.Context, chat *ChatSession, prompt string, files []string, policy retryPolicy) (ModelOutput, error) {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return ModelOutput{}, err
+		}
+		out, err := chat.SendMessage(ctx, prompt, files)
+		if err == nil {
+			return out, nil
+		}
+		class := classifyRetry(err)
+		if !policy.allows(class, attempt) {
+			return ModelOutput{}, err
+		}
+		delay := policy.backoff(attempt)
+		log.Debugf("gemini web: attempt %d failed (%s: %v), retrying in %s", attempt, class, err, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ModelOutput{}, err
+		case <-timer.C:
+		}
+	}
+}
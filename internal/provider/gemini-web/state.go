@@ -10,9 +10,9 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
@@ -20,6 +20,8 @@ import (
 	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -31,29 +33,62 @@ const (
 )
 
 type GeminiWebState struct {
-	cfg         *config.Config
+	// cfg holds the active configuration; it is swapped by SetConfig on config reloads.
+	cfg         atomic.Pointer[config.Config]
 	token       *gemini.GeminiWebTokenStorage
 	storagePath string
 	authLabel   string
 
 	stableClientID string
 	accountID      string
-
-	reqMu  sync.Mutex
-	client *GeminiClient
+	// bandwidthAccount is the auth ID that upstream traffic is attributed to.
+	bandwidthAccount string
+
+	// queue bounds the requests served by the account at once and those waiting for it.
+	queue requestQueue
+
+	// clientMu guards the upstream client and its lifecycle fields, since the queue may let
+	// several requests of the account run at once.
+	clientMu sync.Mutex
+	client   *GeminiClient
+	// clientProxy is the proxy URL client was built with, so EnsureClient can rebuild the
+	// client after a reload changes it.
+	clientProxy string
+	lastRefresh time.Time
 
 	tokenMu    sync.Mutex
 	tokenDirty bool
 
+	// browserMu serializes browser cookie refreshes and guards the fields below.
+	browserMu          sync.Mutex
+	rotateFailures     int
+	lastBrowserRefresh time.Time
+
 	convMu    sync.RWMutex
 	convStore map[string][]string
-	convData  map[string]ConversationRecord
-	convIndex map[string]string
-
-	lastRefresh time.Time
-
-	pendingMatchMu sync.Mutex
-	pendingMatch   *conversation.MatchResult
+	// convHeaders describes every conversation record; the records themselves are read from
+	// the store on lookup and kept in convCache.
+	convHeaders map[string]conversationHeader
+	convIndex   map[string]string
+	convCache   *conversationCache
+	// convUnsaved and convUnsavedIndex hold the records and index entries not written to the
+	// store yet.
+	convUnsaved      map[string]ConversationRecord
+	convUnsavedIndex map[string]string
+	// convNormalization is the hash normalization fingerprint convIndex was computed with.
+	convNormalization string
+	// convLoaded is set once the stored headers were read. Until then convHeaders holds only
+	// this process's records.
+	convLoaded bool
+	// convSaveMu orders writes to the conversation store.
+	convSaveMu sync.Mutex
+
+	systemGemMu sync.Mutex
+	systemGems  map[string]*systemGemEntry
+	// systemGemsSynced records that managed Gems already on the account were adopted.
+	systemGemsSynced bool
+
+	breaker circuitBreaker
 }
 
 type reuseComputation struct {
@@ -64,18 +99,22 @@ type reuseComputation struct {
 
 func NewGeminiWebState(cfg *config.Config, token *gemini.GeminiWebTokenStorage, storagePath, authLabel string) *GeminiWebState {
 	state := &GeminiWebState{
-		cfg:         cfg,
-		token:       token,
-		storagePath: storagePath,
-		authLabel:   strings.TrimSpace(authLabel),
-		convStore:   make(map[string][]string),
-		convData:    make(map[string]ConversationRecord),
-		convIndex:   make(map[string]string),
+		token:            token,
+		storagePath:      storagePath,
+		authLabel:        strings.TrimSpace(authLabel),
+		convStore:        make(map[string][]string),
+		convHeaders:      make(map[string]conversationHeader),
+		convIndex:        make(map[string]string),
+		convCache:        newConversationCache(),
+		convUnsaved:      make(map[string]ConversationRecord),
+		convUnsavedIndex: make(map[string]string),
+		systemGems:       make(map[string]*systemGemEntry),
 	}
 	suffix := conversation.Sha256Hex(token.Secure1PSID)
 	if len(suffix) > 16 {
 		suffix = suffix[:16]
 	}
+	state.cfg.Store(cfg)
 	state.stableClientID = "gemini-web-" + suffix
 	if storagePath != "" {
 		base := strings.TrimSuffix(filepath.Base(storagePath), filepath.Ext(storagePath))
@@ -91,28 +130,25 @@ func NewGeminiWebState(cfg *config.Config, token *gemini.GeminiWebTokenStorage,
 	return state
 }
 
-func (s *GeminiWebState) setPendingMatch(match *conversation.MatchResult) {
-	if s == nil {
-		return
+type pendingMatchKey struct{}
+
+// WithPendingMatch attaches a cached conversation match to the request carried by ctx.
+// The match travels with the request because an account may serve several at once.
+func WithPendingMatch(ctx context.Context, match *conversation.MatchResult) context.Context {
+	if match == nil {
+		return ctx
 	}
-	s.pendingMatchMu.Lock()
-	s.pendingMatch = match
-	s.pendingMatchMu.Unlock()
+	return context.WithValue(ctx, pendingMatchKey{}, match)
 }
 
-func (s *GeminiWebState) consumePendingMatch() *conversation.MatchResult {
-	s.pendingMatchMu.Lock()
-	defer s.pendingMatchMu.Unlock()
-	match := s.pendingMatch
-	s.pendingMatch = nil
+func pendingMatchFrom(ctx context.Context) *conversation.MatchResult {
+	if ctx == nil {
+		return nil
+	}
+	match, _ := ctx.Value(pendingMatchKey{}).(*conversation.MatchResult)
 	return match
 }
 
-// SetPendingMatch makes a cached conversation match available for the next request.
-func (s *GeminiWebState) SetPendingMatch(match *conversation.MatchResult) {
-	s.setPendingMatch(match)
-}
-
 // Label returns a stable account label for logging and persistence.
 // If a storage file path is known, it uses the file base name (without extension).
 // Otherwise, it falls back to the stable client ID (e.g., "gemini-web-<hash>").
@@ -142,13 +178,41 @@ func (s *GeminiWebState) loadConversationCaches() {
 	if path == "" {
 		return
 	}
+	migrateLegacyFor(path, s.Label())
+	startConvBackups(filepath.Dir(path))
+	ensureConvStoreIntact(path)
+	upgradeConvStore(path, s.accountID)
+	s.convNormalization = syncConvIndexNormalization(path, s.accountID)
 	if store, err := LoadConvStore(path); err == nil {
 		s.convStore = store
 	}
-	if items, index, err := LoadConvData(path); err == nil {
-		s.convData = items
+	startConvBlobCollector(filepath.Dir(path))
+	if headers, index, err := loadConvHeaders(path); err == nil {
+		s.convHeaders = headers
 		s.convIndex = index
+		s.convLoaded = true
+	} else {
+		log.Warnf("gemini web: failed to load conversations of %s, new ones are kept in memory until the store is readable: %v", s.Label(), err)
+		return
 	}
+	if redactor := s.redactor(); redactor != nil {
+		// Stores written before redact mode was enabled are converted on load.
+		s.convertToRedacted(redactor, path)
+	}
+}
+
+// redactor returns the message redactor when conversation storage runs in redact mode.
+func (s *GeminiWebState) redactor() *messageRedactor {
+	return newMessageRedactor(s.config(), s.convPath())
+}
+
+// recordHistory returns the history of rec for reuse against incoming. Redacted records
+// only yield the part the client resent.
+func (s *GeminiWebState) recordHistory(rec ConversationRecord, incoming []RoleText) []RoleText {
+	if redactor := s.redactor(); redactor != nil {
+		return redactor.restoreHistory(rec.Messages, incoming)
+	}
+	return cloneRoleTextSlice(storedMessagesToRoleText(rec.Messages))
 }
 
 // convPath returns the BoltDB file path used for both account metadata and conversation data.
@@ -215,81 +279,172 @@ func storedMessagesToRoleText(stored []conversation.StoredMessage) []RoleText {
 	return converted
 }
 
-func (s *GeminiWebState) findConversationByMetadata(model string, metadata []string) ([]RoleText, bool) {
+func (s *GeminiWebState) findConversationByMetadata(model string, metadata []string, incoming []RoleText) ([]RoleText, bool) {
 	if len(metadata) == 0 {
 		return nil, false
 	}
+	var keys []string
 	s.convMu.RLock()
-	defer s.convMu.RUnlock()
-	for _, rec := range s.convData {
-		if !strings.EqualFold(strings.TrimSpace(rec.Model), strings.TrimSpace(model)) {
-			continue
+	for key, header := range s.convHeaders {
+		if strings.EqualFold(strings.TrimSpace(header.Model), strings.TrimSpace(model)) && equalStringSlice(header.Metadata, metadata) {
+			keys = append(keys, key)
 		}
-		if !equalStringSlice(rec.Metadata, metadata) {
-			continue
+	}
+	s.convMu.RUnlock()
+	for _, key := range keys {
+		if rec, ok := s.conversationRecord(key); ok {
+			history := s.recordHistory(rec, incoming)
+			return history, len(history) > 0
 		}
-		return cloneRoleTextSlice(storedMessagesToRoleText(rec.Messages)), true
 	}
 	return nil, false
 }
 
-func (s *GeminiWebState) GetRequestMutex() *sync.Mutex { return &s.reqMu }
-
+// EnsureClient initialises the upstream client, rebuilding it when the configured proxy
+// changed since the running client was created.
 func (s *GeminiWebState) EnsureClient() error {
+	_, err := s.ensureClient()
+	return err
+}
+
+// ensureClient is EnsureClient returning the client, which callers use instead of reading
+// s.client so a concurrent rebuild cannot swap it underneath them.
+func (s *GeminiWebState) ensureClient() (*GeminiClient, error) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	proxyURL := s.proxyURL()
+	if s.client != nil && s.client.Running && s.clientProxy == proxyURL {
+		return s.client, nil
+	}
 	if s.client != nil && s.client.Running {
-		return nil
+		log.Infof("gemini web account %s: proxy changed, reconnecting", s.accountID)
 	}
-	proxyURL := ""
-	if s.cfg != nil {
-		proxyURL = s.cfg.ProxyURL
-	}
-	s.client = NewGeminiClient(
-		s.token.Secure1PSID,
-		s.token.Secure1PSIDTS,
-		proxyURL,
-	)
-	timeout := geminiWebDefaultTimeoutSec
-	if err := s.client.Init(float64(timeout), false); err != nil {
+	client := s.newClientLocked(proxyURL)
+	if err := client.Init(s.clientTimeoutSec(), false); err != nil {
 		s.client = nil
-		return err
+		return nil, err
 	}
+	s.client = client
+	s.clientProxy = proxyURL
 	s.lastRefresh = time.Now()
-	return nil
+	return client, nil
+}
+
+func (s *GeminiWebState) newClientLocked(proxyURL string) *GeminiClient {
+	s.tokenMu.Lock()
+	psid, psidts := s.token.Secure1PSID, s.token.Secure1PSIDTS
+	s.tokenMu.Unlock()
+	return NewGeminiClient(psid, psidts, proxyURL, WithBandwidthAccount(s.bandwidthAccount))
+}
+
+// SetBandwidthAccount attributes upstream traffic of clients created from now on to the
+// auth with the given ID.
+func (s *GeminiWebState) SetBandwidthAccount(authID string) {
+	if s == nil {
+		return
+	}
+	s.clientMu.Lock()
+	s.bandwidthAccount = authID
+	s.clientMu.Unlock()
 }
 
+// Close stops the upstream client; a later EnsureClient reconnects.
+func (s *GeminiWebState) Close() {
+	if s == nil {
+		return
+	}
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	if s.client == nil {
+		return
+	}
+	s.client.Close(0)
+}
+
+// SetConfig swaps the configuration used by subsequent requests. Settings are read per
+// request; a proxy change takes effect on the next EnsureClient.
+func (s *GeminiWebState) SetConfig(cfg *config.Config) {
+	if s == nil {
+		return
+	}
+	s.cfg.Store(cfg)
+}
+
+func (s *GeminiWebState) config() *config.Config {
+	return s.cfg.Load()
+}
+
+func (s *GeminiWebState) proxyURL() string {
+	if cfg := s.config(); cfg != nil {
+		return cfg.ProxyURL
+	}
+	return ""
+}
+
+// Refresh reconnects the account and rotates __Secure-1PSIDTS. When rotation keeps failing or
+// the cookies are rejected, the headless browser fallback may renew the cookies instead.
 func (s *GeminiWebState) Refresh(ctx context.Context) error {
-	_ = ctx
-	proxyURL := ""
-	if s.cfg != nil {
-		proxyURL = s.cfg.ProxyURL
-	}
-	s.client = NewGeminiClient(
-		s.token.Secure1PSID,
-		s.token.Secure1PSIDTS,
-		proxyURL,
-	)
-	timeout := geminiWebDefaultTimeoutSec
-	if err := s.client.Init(float64(timeout), false); err != nil {
-		return err
+	rotateErr, err := s.rotate()
+	switch {
+	case err != nil:
+		return s.refreshWithBrowser(ctx, err)
+	case rotateErr != nil:
+		// A failed rotation that leaves the connection usable is not reported, but counts
+		// towards the browser fallback.
+		log.Debugf("gemini web account %s: 1PSIDTS rotation failed: %v", s.accountID, rotateErr)
+		_ = s.refreshWithBrowser(ctx, rotateErr)
+		return nil
 	}
+	s.browserMu.Lock()
+	s.rotateFailures = 0
+	s.browserMu.Unlock()
+	return nil
+}
+
+// rotate reconnects and rotates __Secure-1PSIDTS. It returns the error of a rotation that
+// failed without the cookies being rejected separately from errors that fail the refresh.
+func (s *GeminiWebState) rotate() (rotateErr, err error) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	proxyURL := s.proxyURL()
+	client := s.newClientLocked(proxyURL)
+	if err := client.Init(s.clientTimeoutSec(), false); err != nil {
+		return nil, err
+	}
+	s.client = client
+	s.clientProxy = proxyURL
 	// Attempt rotation proactively to persist new TS sooner
-	if newTS, err := s.client.RotateTS(); err == nil && newTS != "" && newTS != s.token.Secure1PSIDTS {
+	newTS, err := client.RotateTS()
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return nil, err
+	}
+	if err != nil {
+		s.lastRefresh = time.Now()
+		return err, nil
+	}
+	if newTS != "" {
 		s.tokenMu.Lock()
-		s.token.Secure1PSIDTS = newTS
-		s.tokenDirty = true
-		if s.client != nil && s.client.Cookies != nil {
-			s.client.Cookies["__Secure-1PSIDTS"] = newTS
+		rotated := newTS != s.token.Secure1PSIDTS
+		if rotated {
+			s.token.Secure1PSIDTS = newTS
+			s.tokenDirty = true
+			if client.Cookies != nil {
+				client.Cookies["__Secure-1PSIDTS"] = newTS
+			}
 		}
 		s.tokenMu.Unlock()
-		// Detailed debug log: provider and account label.
-		label := strings.TrimSpace(s.Label())
-		if label == "" {
-			label = s.accountID
+		if rotated {
+			// Detailed debug log: provider and account label.
+			label := strings.TrimSpace(s.Label())
+			if label == "" {
+				label = s.accountID
+			}
+			log.Debugf("gemini web account %s rotated 1PSIDTS: %s", label, MaskToken28(newTS))
 		}
-		log.Debugf("gemini web account %s rotated 1PSIDTS: %s", label, MaskToken28(newTS))
 	}
 	s.lastRefresh = time.Now()
-	return nil
+	return nil, nil
 }
 
 func (s *GeminiWebState) TokenSnapshot() *gemini.GeminiWebTokenStorage {
@@ -310,16 +465,26 @@ type geminiWebPrepared struct {
 	reuse         bool
 	tagged        bool
 	originalRaw   []byte
+	tools         []gjson.Result
+	format        *responseFormat
+	// conversationID is the explicit conversation ID honoured for the request, if any.
+	conversationID *conversation.ConversationIDRef
+	// migratedFrom is the record of another model whose history the request replays.
+	migratedFrom string
+	// summary replaces the older messages of a compacted history.
+	summary *HistorySummary
+	// overlap counts the incoming messages the continued conversation already holds.
+	overlap int
 }
 
 func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON []byte, stream bool, original []byte) (*geminiWebPrepared, *interfaces.ErrorMessage) {
 	res := &geminiWebPrepared{originalRaw: original}
 	res.translatedRaw = bytes.Clone(rawJSON)
-	if handler, ok := ctx.Value("handler").(interfaces.APIHandler); ok && handler != nil {
-		res.handlerType = handler.HandlerType()
+	if handlerType := requestctx.HandlerType(ctx); handlerType != "" {
+		res.handlerType = handlerType
 		res.translatedRaw = translator.Request(res.handlerType, constant.GeminiWeb, modelName, res.translatedRaw, stream)
 	}
-	recordAPIRequest(ctx, s.cfg, res.translatedRaw)
+	recordAPIRequest(ctx, s.config(), res.translatedRaw)
 
 	messages, files, mimes, msgFileIdx, err := ParseMessagesAndFiles(res.translatedRaw)
 	if err != nil {
@@ -338,13 +503,42 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 	filesSubset := files
 	mimesSubset := mimes
 
-	if s.useReusableContext() {
-		reusePlan := s.reuseFromPending(res.underlying, cleaned)
-		if reusePlan == nil {
-			reusePlan = s.findReusableSession(res.underlying, cleaned)
+	contextMode, errMode := contextModeFrom(ctx, original)
+	if errMode != nil {
+		return nil, errMode
+	}
+	historyMatched := false
+	var explicitPlan *reuseComputation
+	res.conversationID, explicitPlan = s.explicitConversation(ctx, res.underlying, cleaned)
+	if res.conversationID != nil || s.useReusableContext() || contextMode != contextModeDefault {
+		// fresh starts a new upstream conversation instead of falling back to the account's last one.
+		var reusePlan *reuseComputation
+		fresh := contextMode == contextModeFresh
+		if res.conversationID != nil {
+			if !fresh {
+				reusePlan, fresh = explicitPlan, explicitPlan == nil
+			}
+		} else if !fresh {
+			reusePlan = s.reuseFromPending(ctx, res.underlying, cleaned)
+			if reusePlan == nil {
+				reusePlan = s.findReusableSession(res.underlying, cleaned)
+			}
+			if reusePlan != nil {
+				if at, diverged := s.branchPoint(reusePlan.metadata, cleaned); diverged {
+					log.Debugf("gemini web: history diverges from the upstream conversation at message %d, starting a new one", at)
+					reusePlan, fresh = nil, true
+				}
+			}
+			if reusePlan == nil && !fresh && s.migrateAcrossModels() {
+				if from, ok := s.migrationSource(res.underlying, cleaned); ok {
+					log.Debugf("gemini web: replaying a conversation recorded under another model to %s", res.underlying)
+					res.migratedFrom, fresh = from, true
+				}
+			}
 		}
 		if reusePlan != nil {
 			res.reuse = true
+			historyMatched = true
 			meta = cloneStringSlice(reusePlan.metadata)
 			overlap := reusePlan.overlap
 			if overlap > len(cleaned) {
@@ -359,6 +553,7 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 				fullCleaned = append(cloneRoleTextSlice(cleaned[:overlap]), delta...)
 			}
 			useMsgs = delta
+			res.overlap = overlap
 			if len(delta) == 0 && len(cleaned) > 0 {
 				useMsgs = []RoleText{cleaned[len(cleaned)-1]}
 			}
@@ -386,7 +581,7 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 				filesSubset = nil
 				mimesSubset = nil
 			}
-		} else {
+		} else if !fresh {
 			if len(cleaned) >= 2 && strings.EqualFold(cleaned[len(cleaned)-2].Role, "assistant") {
 				keyUnderlying := AccountMetaKey(s.accountID, res.underlying)
 				keyAlias := AccountMetaKey(s.accountID, modelName)
@@ -396,10 +591,14 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 					fallbackMeta = s.convStore[keyAlias]
 				}
 				s.convMu.RUnlock()
+				if _, diverged := s.branchPoint(fallbackMeta, cleaned); diverged {
+					fallbackMeta = nil
+				}
 				if len(fallbackMeta) > 0 {
 					meta = fallbackMeta
 					useMsgs = []RoleText{cleaned[len(cleaned)-1]}
 					res.reuse = true
+					res.overlap = len(cleaned) - 1
 					filesSubset = nil
 					mimesSubset = nil
 				}
@@ -417,6 +616,22 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 		s.convMu.RUnlock()
 	}
 
+	s.setReuseHeaders(ctx, res)
+	if contextMode == contextModeStrict && !res.reuse {
+		return nil, errNoReusableContext()
+	}
+
+	if !res.reuse {
+		useMsgs, res.summary = s.compactHistory(ctx, useMsgs)
+	}
+
+	// Unless the remote thread is known to hold the full history, replay pinned messages first.
+	if !historyMatched {
+		if pinned := ExtractPinnedMessages(original); len(pinned) > 0 {
+			useMsgs = PromotePinnedMessages(useMsgs, pinned)
+		}
+	}
+
 	res.cleaned = fullCleaned
 
 	res.tagged = NeedRoleTags(useMsgs)
@@ -424,24 +639,67 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 		res.tagged = false
 	}
 
-	enableXML := s.cfg != nil && s.cfg.GeminiWeb.CodeMode
+	cfg := s.config()
+	enableXML := cfg != nil && cfg.GeminiWeb.CodeMode
 	useMsgs = AppendXMLWrapHintIfNeeded(useMsgs, !enableXML)
 
+	systemPrompt := ""
+	if cfg != nil && !res.reuse {
+		systemPrompt = ExtractSystemInstruction(res.translatedRaw)
+	}
+	var errFit *interfaces.ErrorMessage
+	if useMsgs, errFit = s.fitContextWindow(res.underlying, useMsgs, res.tagged, systemPrompt); errFit != nil {
+		return nil, errFit
+	}
+	var systemGem *Gem
+	if systemPrompt != "" {
+		switch strings.ToLower(strings.TrimSpace(cfg.GeminiWeb.SystemPromptMode)) {
+		case SystemPromptModeGem:
+			gem, errGem := s.systemPromptGem(systemPrompt)
+			if errGem != nil {
+				log.Warnf("gemini web: failed to map system prompt to gem, falling back to delimiter: %v", errGem)
+				useMsgs = PrependSystemBlock(useMsgs, systemPrompt)
+			} else {
+				systemGem = gem
+			}
+		case SystemPromptModeDelimiter:
+			useMsgs = PrependSystemBlock(useMsgs, systemPrompt)
+		}
+	}
+
+	useMsgs = PrependContextBlock(useMsgs, localeContextBlock(ctx, cfg, original, idgen.Now(ctx)))
+	toolMode, forcedTool := toolChoiceFrom(original, res.translatedRaw)
+	if toolMode != toolChoiceNone {
+		res.tools = toolDeclarations(res.translatedRaw)
+	}
+	useMsgs = PrependContextBlock(useMsgs, toolPromptBlock(res.tools, toolMode, forcedTool))
+	if res.format = responseFormatFrom(original, res.translatedRaw); res.format != nil {
+		useMsgs = PrependContextBlock(useMsgs, res.format.promptBlock())
+	}
+
 	res.prompt = BuildPrompt(useMsgs, res.tagged, res.tagged)
 	if strings.TrimSpace(res.prompt) == "" {
 		return nil, &interfaces.ErrorMessage{StatusCode: 400, Error: errors.New("bad request: empty prompt after filtering system/thought content")}
 	}
 
+	if errFiles := validateInputFiles(cfg, filesSubset, mimesSubset); errFiles != nil {
+		return nil, errFiles
+	}
 	uploaded, upErr := MaterializeInlineFiles(filesSubset, mimesSubset)
 	if upErr != nil {
 		return nil, upErr
 	}
 	res.uploaded = uploaded
 
-	if err = s.EnsureClient(); err != nil {
+	client, err := s.ensureClient()
+	if err != nil {
 		return nil, &interfaces.ErrorMessage{StatusCode: 500, Error: err}
 	}
-	chat := s.client.StartChat(model, s.getConfiguredGem(), meta)
+	gem := systemGem
+	if gem == nil {
+		gem = s.getConfiguredGem(ctx, modelName, res.underlying)
+	}
+	chat := client.StartChat(model, gem, meta)
 	chat.SetRequestedModel(modelName)
 	res.chat = chat
 
@@ -449,15 +707,56 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 }
 
 func (s *GeminiWebState) Send(ctx context.Context, modelName string, reqPayload []byte, opts cliproxyexecutor.Options) ([]byte, *interfaces.ErrorMessage, *geminiWebPrepared) {
+	cacheTTL, cacheEntries := responseCacheSettings(s.config())
+	cacheKey := ""
+	if cacheTTL > 0 {
+		if key, ok := responseCacheKey(ctx, modelName, reqPayload); ok {
+			cacheKey = key
+			if cached, hit := sharedResponseCache.get(key); hit {
+				log.Debugf("gemini web: serving %s from response cache", modelName)
+				s.addAPIResponseData(ctx, cached)
+				return cached, nil, s.cachedPrepared(ctx, modelName, reqPayload, opts.Stream, opts.OriginalRequest)
+			}
+		}
+	}
+
+	if err := s.checkJSONOutputPolicy(ctx, opts.OriginalRequest, reqPayload); err != nil {
+		return nil, &interfaces.ErrorMessage{StatusCode: 400, Error: err}, nil
+	}
+	timeout, errTimeout := s.requestTimeout(ctx, modelName, opts.OriginalRequest)
+	if errTimeout != nil {
+		return nil, errTimeout, nil
+	}
+	// The breaker is consulted before prepare, which already uploads files and opens Gems
+	// and chats upstream.
+	breakerCfg := breakerSettingsFor(s.config())
+	if ok, wait := s.breaker.allow(breakerCfg, time.Now()); !ok {
+		return nil, &interfaces.ErrorMessage{
+			StatusCode: 503,
+			Error:      fmt.Errorf("gemini web account %s: circuit breaker open, retry in %s", s.Label(), wait.Round(time.Second)),
+		}, nil
+	}
 	prep, errMsg := s.prepare(ctx, modelName, reqPayload, opts.Stream, opts.OriginalRequest)
 	if errMsg != nil {
+		s.breaker.release(breakerCfg)
 		return nil, errMsg, nil
 	}
 	defer CleanupFiles(prep.uploaded)
 
-	output, err := SendWithSplit(prep.chat, prep.prompt, prep.uploaded, s.cfg)
+	sendCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	output, err := SendWithSplit(sendCtx, prep.chat, prep.prompt, prep.uploaded, s.config())
+	var partial *PartialOutputError
+	if err != nil && ctx.Err() == nil && errors.Is(sendCtx.Err(), context.DeadlineExceeded) && !errors.As(err, &partial) {
+		err = &TimeoutError{GeminiError{Msg: fmt.Sprintf("Gemini Web did not answer within %s.", timeout)}}
+	}
+	s.breaker.record(breakerCfg, err, time.Now())
+	partialReason := ""
 	if err != nil {
-		return nil, s.wrapSendError(err), nil
+		var salvaged bool
+		if output, partialReason, salvaged = s.partialOutput(prep, err); !salvaged {
+			return nil, s.wrapSendError(err), nil
+		}
 	}
 
 	// Hook: For gemini-2.5-flash-image-preview, if the API returns only images without any text,
@@ -479,39 +778,65 @@ func (s *GeminiWebState) Send(ctx context.Context, modelName string, reqPayload
 		}
 	}
 
-	gemBytes, err := ConvertOutputToGemini(&output, modelName, prep.prompt)
+	if cfg := s.config(); cfg != nil && cfg.GeminiWeb.ReasoningContent {
+		SeparateThinking(&output)
+	}
+
+	if prep.format != nil {
+		// JSON replies only take a watermark, embedded as JSON whitespace after validation.
+		var errFormat *interfaces.ErrorMessage
+		if output, errFormat = s.enforceResponseFormat(sendCtx, prep, output); errFormat != nil {
+			return nil, errFormat, nil
+		}
+		s.applyJSONOutputPolicy(ctx, &output)
+	} else {
+		s.applyOutputPolicy(ctx, modelName, &output)
+	}
+
+	gemBytes, err := ConvertOutputToGemini(ctx, &output, modelName, prep.prompt)
 	if err != nil {
 		return nil, &interfaces.ErrorMessage{StatusCode: 500, Error: err}, nil
 	}
+	if partialReason != "" {
+		gemBytes, _ = sjson.SetBytes(gemBytes, "candidates.0.finishReason", partialReason)
+	}
+	gemBytes = applyEmulatedToolCalls(gemBytes, prep.tools)
 
 	s.addAPIResponseData(ctx, gemBytes)
-	s.persistConversation(modelName, prep, &output)
+	s.persistConversation(ctx, modelName, prep, &output)
+	if cacheKey != "" && partialReason == "" {
+		sharedResponseCache.put(cacheKey, gemBytes, cacheTTL, cacheEntries)
+	}
 	return gemBytes, nil, prep
 }
 
 func (s *GeminiWebState) wrapSendError(genErr error) *interfaces.ErrorMessage {
 	status := 500
+	code := interfaces.ErrorCodeUpstreamError
 	var usage *UsageLimitExceeded
 	var blocked *TemporarilyBlocked
 	var invalid *ModelInvalid
 	var valueErr *ValueError
 	var timeout *TimeoutError
+	var authErr *AuthError
 	switch {
 	case errors.As(genErr, &usage):
-		status = 429
+		status, code = 429, interfaces.ErrorCodeUsageLimit
 	case errors.As(genErr, &blocked):
-		status = 429
+		status, code = 429, interfaces.ErrorCodeTempBlocked
 	case errors.As(genErr, &invalid):
-		status = 400
+		status, code = 400, interfaces.ErrorCodeModelInvalid
 	case errors.As(genErr, &valueErr):
-		status = 400
-	case errors.As(genErr, &timeout):
-		status = 504
+		status, code = 400, interfaces.ErrorCodeInvalidRequest
+	case errors.As(genErr, &timeout), errors.Is(genErr, context.DeadlineExceeded):
+		status, code = 504, interfaces.ErrorCodeUpstreamTimeout
+	case errors.As(genErr, &authErr):
+		code = interfaces.ErrorCodeCookieExpired
 	}
-	return &interfaces.ErrorMessage{StatusCode: status, Error: genErr}
+	return &interfaces.ErrorMessage{StatusCode: status, Error: genErr, Code: code}
 }
 
-func (s *GeminiWebState) persistConversation(modelName string, prep *geminiWebPrepared, output *ModelOutput) {
+func (s *GeminiWebState) persistConversation(ctx context.Context, modelName string, prep *geminiWebPrepared, output *ModelOutput) {
 	if output == nil || prep == nil || prep.chat == nil {
 		return
 	}
@@ -535,77 +860,72 @@ func (s *GeminiWebState) persistConversation(modelName string, prep *geminiWebPr
 		_ = SaveConvStore(s.convPath(), storeSnapshot)
 	}
 
+	if prep.conversationID != nil {
+		s.storeConversationID(ctx, prep.conversationID, prep.underlying, metadata, len(prep.cleaned)+1)
+	}
+
 	if !s.useReusableContext() {
 		return
 	}
-	rec, ok := BuildConversationRecord(prep.underlying, s.stableClientID, prep.cleaned, output, metadata)
+	rec, ok := BuildConversationRecord(ctx, prep.underlying, s.stableClientID, prep.cleaned, output, metadata)
 	if !ok {
 		return
 	}
-	label := strings.TrimSpace(s.Label())
-	if label == "" {
-		label = s.accountID
-	}
+	label := s.conversationLabel()
 	conversationMsgs := conversation.StoredToMessages(rec.Messages)
 	if err := conversation.StoreConversation(label, prep.underlying, conversationMsgs, metadata); err != nil {
 		log.Debugf("gemini web: failed to persist global conversation index: %v", err)
 	}
-	stableHash := conversation.HashConversationForAccount(rec.ClientID, prep.underlying, rec.Messages)
-	accountHash := conversation.HashConversationForAccount(s.accountID, prep.underlying, rec.Messages)
-
-	suffixSeen := make(map[string]struct{})
-	suffixSeen["hash:"+stableHash] = struct{}{}
-	if accountHash != stableHash {
-		suffixSeen["hash:"+accountHash] = struct{}{}
+	index := make(map[string]string)
+	stableHash := indexConversationRecord(index, s.accountID, rec)
+	if redactor := s.redactor(); redactor != nil {
+		// The index above is computed from the plaintext; only the record is redacted.
+		rec.Messages = redactor.redact(rec.Messages)
+	} else {
+		// A summary repeats the conversation text, so redacted stores do not cache it.
+		rec.Summary = prep.summary
 	}
-
+	s.convSaveMu.Lock()
 	s.convMu.Lock()
-	s.convData[stableHash] = rec
-	s.convIndex["hash:"+stableHash] = stableHash
-	if accountHash != stableHash {
-		s.convIndex["hash:"+accountHash] = stableHash
+	s.addConversationLocked(stableHash, rec, index)
+	err := s.ensureConvLoadedLocked()
+	s.convMu.Unlock()
+	if err == nil {
+		err = s.flushConversations(nil, nil)
+	}
+	s.convSaveMu.Unlock()
+	if err != nil {
+		log.Debugf("gemini web: conversation kept in memory only: %v", err)
+		return
 	}
+	if prep.migratedFrom != "" && prep.migratedFrom != stableHash {
+		s.completeMigration(ctx, prep.migratedFrom, prep.underlying)
+	}
+}
 
-	sanitizedHistory := conversation.SanitizeAssistantMessages(conversation.StoredToMessages(rec.Messages))
-	for start := 1; start < len(sanitizedHistory); start++ {
-		segment := sanitizedHistory[start:]
-		if len(segment) < 2 {
-			continue
-		}
-		tailRole := strings.ToLower(strings.TrimSpace(segment[len(segment)-1].Role))
-		if tailRole != "assistant" && tailRole != "system" {
-			continue
-		}
-		storedSegment := conversation.ToStoredMessages(segment)
-		segmentStableHash := conversation.HashConversationForAccount(rec.ClientID, prep.underlying, storedSegment)
-		keyStable := "hash:" + segmentStableHash
-		if _, exists := suffixSeen[keyStable]; !exists {
-			s.convIndex[keyStable] = stableHash
-			suffixSeen[keyStable] = struct{}{}
-		}
-		segmentAccountHash := conversation.HashConversationForAccount(s.accountID, prep.underlying, storedSegment)
-		if segmentAccountHash != segmentStableHash {
-			keyAccount := "hash:" + segmentAccountHash
-			if _, exists := suffixSeen[keyAccount]; !exists {
-				s.convIndex[keyAccount] = stableHash
-				suffixSeen[keyAccount] = struct{}{}
-			}
-		}
+// ensureConvLoadedLocked retries reading the stored headers when the initial load failed,
+// merging them under the records added since. It must be called with convMu held for
+// writing, and saving is only safe once it returns nil.
+func (s *GeminiWebState) ensureConvLoadedLocked() error {
+	if s.convLoaded {
+		return nil
 	}
-	dataSnapshot := make(map[string]ConversationRecord, len(s.convData))
-	for k, v := range s.convData {
-		dataSnapshot[k] = v
+	headers, index, err := loadConvHeaders(s.convPath())
+	if err != nil {
+		return err
 	}
-	indexSnapshot := make(map[string]string, len(s.convIndex))
-	for k, v := range s.convIndex {
-		indexSnapshot[k] = v
+	for key, header := range s.convHeaders {
+		headers[key] = header
 	}
-	s.convMu.Unlock()
-	_ = SaveConvData(s.convPath(), dataSnapshot, indexSnapshot)
+	for key, target := range s.convIndex {
+		index[key] = target
+	}
+	s.convHeaders, s.convIndex, s.convLoaded = headers, index, true
+	return nil
 }
 
 func (s *GeminiWebState) addAPIResponseData(ctx context.Context, line []byte) {
-	appendAPIResponseChunk(ctx, s.cfg, line)
+	appendAPIResponseChunk(ctx, s.config(), line)
 }
 
 func (s *GeminiWebState) ConvertToTarget(ctx context.Context, modelName string, prep *geminiWebPrepared, gemBytes []byte) []byte {
@@ -618,10 +938,11 @@ func (s *GeminiWebState) ConvertToTarget(ctx context.Context, modelName string,
 	var param any
 	out := translator.ResponseNonStream(prep.handlerType, constant.GeminiWeb, ctx, modelName, prep.originalRaw, prep.translatedRaw, gemBytes, &param)
 	if prep.handlerType == constant.OpenAI && out != "" {
-		newID := fmt.Sprintf("chatcmpl-%x", time.Now().UnixNano())
+		newID := idgen.NewID(ctx, "chatcmpl-")
 		if v := gjson.Parse(out).Get("id"); v.Exists() {
 			out, _ = sjson.Set(out, "id", newID)
 		}
+		out = finishOpenAIToolCalls(out, "choices.0.message.tool_calls")
 	}
 	return []byte(out)
 }
@@ -634,13 +955,27 @@ func (s *GeminiWebState) ConvertStream(ctx context.Context, modelName string, pr
 		return []string{string(gemBytes)}
 	}
 	var param any
-	return translator.Response(prep.handlerType, constant.GeminiWeb, ctx, modelName, prep.originalRaw, prep.translatedRaw, gemBytes, &param)
+	chunks := translator.Response(prep.handlerType, constant.GeminiWeb, ctx, modelName, prep.originalRaw, prep.translatedRaw, gemBytes, &param)
+	if prep.handlerType == constant.OpenAI {
+		for i := range chunks {
+			chunks[i] = finishOpenAIToolCalls(chunks[i], "choices.0.delta.tool_calls")
+		}
+		chunks = shapeOpenAIStream(chunks, streamProfileFor(s.config(), apiKeyFromContext(ctx)))
+	}
+	return chunks
 }
 
+// DoneStream returns the chunks that close a stream in the client's format. For OpenAI clients
+// whose stream profile sets omit-done, it also tells the handler to leave out "data: [DONE]".
 func (s *GeminiWebState) DoneStream(ctx context.Context, modelName string, prep *geminiWebPrepared) []string {
 	if prep == nil || prep.handlerType == "" {
 		return nil
 	}
+	if prep.handlerType == constant.OpenAI {
+		if profile := streamProfileFor(s.config(), apiKeyFromContext(ctx)); profile != nil && profile.OmitDone {
+			requestctx.SkipStreamDone(ctx)
+		}
+	}
 	if !translator.NeedConvert(prep.handlerType, constant.GeminiWeb) {
 		return nil
 	}
@@ -649,14 +984,15 @@ func (s *GeminiWebState) DoneStream(ctx context.Context, modelName string, prep
 }
 
 func (s *GeminiWebState) useReusableContext() bool {
-	if s.cfg == nil {
+	cfg := s.config()
+	if cfg == nil {
 		return true
 	}
-	return s.cfg.GeminiWeb.Context
+	return cfg.GeminiWeb.Context
 }
 
-func (s *GeminiWebState) reuseFromPending(modelName string, msgs []RoleText) *reuseComputation {
-	match := s.consumePendingMatch()
+func (s *GeminiWebState) reuseFromPending(ctx context.Context, modelName string, msgs []RoleText) *reuseComputation {
+	match := pendingMatchFrom(ctx)
 	if match == nil {
 		return nil
 	}
@@ -667,24 +1003,38 @@ func (s *GeminiWebState) reuseFromPending(modelName string, msgs []RoleText) *re
 	if len(metadata) == 0 {
 		return nil
 	}
-	history, ok := s.findConversationByMetadata(modelName, metadata)
+	history, ok := s.findConversationByMetadata(modelName, metadata, msgs)
 	if !ok {
-		return nil
+		// Matches found through the shared index may belong to a conversation another replica
+		// started; the matched prefix of the incoming messages is then the remote history.
+		if history, ok = historyForMatch(match, msgs); !ok {
+			return nil
+		}
 	}
 	overlap := longestHistoryOverlap(history, msgs)
 	return &reuseComputation{metadata: metadata, history: history, overlap: overlap}
 }
 
+// historyForMatch returns the prefix of msgs whose lookup hash produced match.
+func historyForMatch(match *conversation.MatchResult, msgs []RoleText) ([]RoleText, bool) {
+	for _, candidate := range conversation.BuildLookupHashes(match.Model, msgs) {
+		if candidate.Hash == match.Hash && candidate.PrefixLen <= len(msgs) {
+			return cloneRoleTextSlice(msgs[:candidate.PrefixLen]), true
+		}
+	}
+	return nil, false
+}
+
 func (s *GeminiWebState) findReusableSession(modelName string, msgs []RoleText) *reuseComputation {
+	s.ensureIndexNormalized()
 	s.convMu.RLock()
-	items := s.convData
 	index := s.convIndex
 	s.convMu.RUnlock()
-	rec, metadata, overlap, ok := FindReusableSessionIn(items, index, s.stableClientID, s.accountID, modelName, msgs)
+	rec, metadata, overlap, ok := FindReusableSessionIn(s.conversationRecord, index, s.stableClientID, s.accountID, modelName, msgs)
 	if !ok {
 		return nil
 	}
-	history := cloneRoleTextSlice(storedMessagesToRoleText(rec.Messages))
+	history := s.recordHistory(rec, msgs)
 	if len(history) == 0 {
 		return nil
 	}
@@ -695,19 +1045,92 @@ func (s *GeminiWebState) findReusableSession(modelName string, msgs []RoleText)
 	return &reuseComputation{metadata: cloneStringSlice(metadata), history: history, overlap: overlap}
 }
 
-func (s *GeminiWebState) getConfiguredGem() *Gem {
-	if s.cfg != nil && s.cfg.GeminiWeb.CodeMode {
+// getConfiguredGem resolves the Gem for a request from the configured rules,
+// falling back to the coding-partner Gem when CodeMode is enabled.
+func (s *GeminiWebState) getConfiguredGem(ctx context.Context, modelName, underlying string) *Gem {
+	cfg := s.config()
+	if cfg == nil {
+		return nil
+	}
+	apiKey := apiKeyFromContext(ctx)
+	for _, rule := range cfg.GeminiWeb.Gems {
+		gemID := strings.TrimSpace(rule.GemID)
+		if gemID == "" {
+			continue
+		}
+		if len(rule.Models) > 0 && !matchesAny(rule.Models, modelName, underlying) {
+			continue
+		}
+		if len(rule.APIKeys) > 0 && (apiKey == "" || !matchesAny(rule.APIKeys, apiKey)) {
+			continue
+		}
+		return &Gem{ID: gemID}
+	}
+	if cfg.GeminiWeb.CodeMode {
 		return &Gem{ID: "coding-partner", Name: "Coding partner", Predefined: true}
 	}
 	return nil
 }
 
+// ListGems returns the predefined and custom Gems available to this account.
+func (s *GeminiWebState) ListGems(includeHidden bool) ([]Gem, error) {
+	client, err := s.ensureClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.FetchGems(includeHidden)
+}
+
+// CreateGem creates a custom Gem on this account.
+func (s *GeminiWebState) CreateGem(name, prompt, description string) (Gem, error) {
+	client, err := s.ensureClient()
+	if err != nil {
+		return Gem{}, err
+	}
+	return client.CreateGem(name, prompt, description)
+}
+
+// UpdateGem updates a custom Gem on this account.
+func (s *GeminiWebState) UpdateGem(id, name, prompt, description string) (Gem, error) {
+	client, err := s.ensureClient()
+	if err != nil {
+		return Gem{}, err
+	}
+	return client.UpdateGem(id, name, prompt, description)
+}
+
+// DeleteGem deletes a custom Gem from this account.
+func (s *GeminiWebState) DeleteGem(id string) error {
+	client, err := s.ensureClient()
+	if err != nil {
+		return err
+	}
+	return client.DeleteGem(id)
+}
+
+func matchesAny(candidates []string, values ...string) bool {
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		for _, value := range values {
+			if value != "" && strings.EqualFold(candidate, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apiKeyFromContext returns the client API key recorded by the access middleware.
+func apiKeyFromContext(ctx context.Context) string {
+	return requestctx.Tenant(ctx)
+}
+
 // recordAPIRequest stores the upstream request payload in Gin context for request logging.
 func recordAPIRequest(ctx context.Context, cfg *config.Config, payload []byte) {
 	if cfg == nil || !cfg.RequestLog || len(payload) == 0 {
 		return
 	}
-	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+	if ginCtx := requestctx.Gin(ctx); ginCtx != nil {
 		ginCtx.Set("API_REQUEST", bytes.Clone(payload))
 	}
 }
@@ -721,7 +1144,7 @@ func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byt
 	if len(data) == 0 {
 		return
 	}
-	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+	if ginCtx := requestctx.Gin(ctx); ginCtx != nil {
 		if existing, exists := ginCtx.Get("API_RESPONSE"); exists {
 			if prev, okBytes := existing.([]byte); okBytes {
 				prev = append(prev, data...)
@@ -734,16 +1157,66 @@ func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byt
 	}
 }
 
+// indexConversationRecord registers rec and every assistant-terminated suffix of it in index,
+// under both the stable client hash and the account hash, and returns the stable hash that
+// keys the record itself.
+func indexConversationRecord(index map[string]string, accountID string, rec ConversationRecord) string {
+	stableHash := conversation.HashConversationForAccount(rec.ClientID, rec.Model, rec.Messages)
+	accountHash := conversation.HashConversationForAccount(accountID, rec.Model, rec.Messages)
+
+	suffixSeen := make(map[string]struct{})
+	suffixSeen["hash:"+stableHash] = struct{}{}
+	if accountHash != stableHash {
+		suffixSeen["hash:"+accountHash] = struct{}{}
+	}
+	index["hash:"+stableHash] = stableHash
+	if accountHash != stableHash {
+		index["hash:"+accountHash] = stableHash
+	}
+
+	sanitizedHistory := conversation.SanitizeAssistantMessages(conversation.StoredToMessages(rec.Messages))
+	for start := 1; start < len(sanitizedHistory); start++ {
+		segment := sanitizedHistory[start:]
+		if len(segment) < 2 {
+			continue
+		}
+		tailRole := strings.ToLower(strings.TrimSpace(segment[len(segment)-1].Role))
+		if tailRole != "assistant" && tailRole != "system" {
+			continue
+		}
+		storedSegment := conversation.ToStoredMessages(segment)
+		segmentStableHash := conversation.HashConversationForAccount(rec.ClientID, rec.Model, storedSegment)
+		keyStable := "hash:" + segmentStableHash
+		if _, exists := suffixSeen[keyStable]; !exists {
+			index[keyStable] = stableHash
+			suffixSeen[keyStable] = struct{}{}
+		}
+		segmentAccountHash := conversation.HashConversationForAccount(accountID, rec.Model, storedSegment)
+		if segmentAccountHash != segmentStableHash {
+			keyAccount := "hash:" + segmentAccountHash
+			if _, exists := suffixSeen[keyAccount]; !exists {
+				index[keyAccount] = stableHash
+				suffixSeen[keyAccount] = struct{}{}
+			}
+		}
+	}
+	return stableHash
+}
+
 // ConvBoltPath returns the BoltDB file path used for both account metadata and conversation data.
 // Different logical datasets are kept in separate buckets within this single DB file.
 func ConvBoltPath(tokenFilePath string) string {
+	base := strings.TrimSuffix(filepath.Base(tokenFilePath), filepath.Ext(tokenFilePath))
+	return filepath.Join(ConvDir(), base+".bolt")
+}
+
+// ConvDir returns the directory holding the per-account conversation stores.
+func ConvDir() string {
 	wd, err := os.Getwd()
 	if err != nil || wd == "" {
 		wd = "."
 	}
-	convDir := filepath.Join(wd, "conv")
-	base := strings.TrimSuffix(filepath.Base(tokenFilePath), filepath.Ext(tokenFilePath))
-	return filepath.Join(convDir, base+".bolt")
+	return filepath.Join(wd, "conv")
 }
 
 // LoadConvStore reads the account-level metadata store from disk.
@@ -826,7 +1299,8 @@ func AccountMetaKey(email, modelName string) string {
 	return fmt.Sprintf("account-meta|%s|%s", email, modelName)
 }
 
-// LoadConvData reads the full conversation data and index from disk.
+// LoadConvData reads the full conversation data and index from disk, resolving message
+// references against the shared blob DB.
 func LoadConvData(path string) (map[string]ConversationRecord, map[string]string, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, nil, err
@@ -838,19 +1312,19 @@ func LoadConvData(path string) (map[string]ConversationRecord, map[string]string
 	defer func() {
 		_ = db.Close()
 	}()
-	items := map[string]ConversationRecord{}
+	stored := map[string]storedConversationRecord{}
 	index := map[string]string{}
 	err = db.View(func(tx *bolt.Tx) error {
 		// Load conv_items
 		if b := tx.Bucket([]byte("conv_items")); b != nil {
 			if e := b.ForEach(func(k, v []byte) error {
-				var rec ConversationRecord
+				var rec storedConversationRecord
 				if len(v) > 0 {
 					if e2 := json.Unmarshal(v, &rec); e2 != nil {
 						// Skip malformed
 						return nil
 					}
-					items[string(k)] = rec
+					stored[string(k)] = rec
 				}
 				return nil
 			}); e != nil {
@@ -871,6 +1345,10 @@ func LoadConvData(path string) (map[string]ConversationRecord, map[string]string
 	if err != nil {
 		return nil, nil, err
 	}
+	items, err := decodeConversationRecords(path, stored)
+	if err != nil {
+		return nil, nil, err
+	}
 	return items, index, nil
 }
 
@@ -885,6 +1363,10 @@ func SaveConvData(path string, items map[string]ConversationRecord, index map[st
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
+	// Message bodies go to the shared blob DB first so records never reference missing blobs.
+	convBlobGCMu.RLock()
+	defer convBlobGCMu.RUnlock()
+	stored := encodeConversationRecords(path, items)
 	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
 	if err != nil {
 		return err
@@ -903,7 +1385,7 @@ func SaveConvData(path string, items map[string]ConversationRecord, index map[st
 		if errCreateBucket != nil {
 			return errCreateBucket
 		}
-		for k, rec := range items {
+		for k, rec := range stored {
 			enc, e := json.Marshal(rec)
 			if e != nil {
 				return e
@@ -934,7 +1416,7 @@ func SaveConvData(path string, items map[string]ConversationRecord, index map[st
 
 // BuildConversationRecord constructs a ConversationRecord from history and the latest output.
 // Returns false when output is empty or has no candidates.
-func BuildConversationRecord(model, clientID string, history []RoleText, output *ModelOutput, metadata []string) (ConversationRecord, bool) {
+func BuildConversationRecord(ctx context.Context, model, clientID string, history []RoleText, output *ModelOutput, metadata []string) (ConversationRecord, bool) {
 	if output == nil || len(output.Candidates) == 0 {
 		return ConversationRecord{}, false
 	}
@@ -944,61 +1426,66 @@ func BuildConversationRecord(model, clientID string, history []RoleText, output
 	}
 	final := append([]RoleText{}, history...)
 	final = append(final, RoleText{Role: "assistant", Text: text})
+	now := idgen.Now(ctx)
 	rec := ConversationRecord{
-		Model:     model,
-		ClientID:  clientID,
-		Metadata:  metadata,
-		Messages:  conversation.ToStoredMessages(final),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Model:         model,
+		ClientID:      clientID,
+		Metadata:      metadata,
+		Messages:      conversation.ToStoredMessages(final),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		SchemaVersion: conversation.SchemaVersion,
 	}
 	return rec, true
 }
 
+// ConversationLookup returns the conversation record stored under key.
+type ConversationLookup func(key string) (ConversationRecord, bool)
+
 // FindByMessageListIn looks up a conversation record by hashed message list.
 // It attempts both the stable client ID and a legacy email-based ID.
-func FindByMessageListIn(items map[string]ConversationRecord, index map[string]string, stableClientID, email, model string, msgs []RoleText) (ConversationRecord, bool) {
+func FindByMessageListIn(lookup ConversationLookup, index map[string]string, stableClientID, email, model string, msgs []RoleText) (ConversationRecord, bool) {
 	stored := conversation.ToStoredMessages(msgs)
 	stableHash := conversation.HashConversationForAccount(stableClientID, model, stored)
 	fallbackHash := conversation.HashConversationForAccount(email, model, stored)
 
 	// Try stable hash via index indirection first
 	if key, ok := index["hash:"+stableHash]; ok {
-		if rec, ok2 := items[key]; ok2 {
+		if rec, ok2 := lookup(key); ok2 {
 			return rec, true
 		}
 	}
-	if rec, ok := items[stableHash]; ok {
+	if rec, ok := lookup(stableHash); ok {
 		return rec, true
 	}
 	// Fallback to legacy hash (email-based)
 	if key, ok := index["hash:"+fallbackHash]; ok {
-		if rec, ok2 := items[key]; ok2 {
+		if rec, ok2 := lookup(key); ok2 {
 			return rec, true
 		}
 	}
-	if rec, ok := items[fallbackHash]; ok {
+	if rec, ok := lookup(fallbackHash); ok {
 		return rec, true
 	}
 	return ConversationRecord{}, false
 }
 
 // FindConversationIn tries exact then sanitized assistant messages.
-func FindConversationIn(items map[string]ConversationRecord, index map[string]string, stableClientID, email, model string, msgs []RoleText) (ConversationRecord, bool) {
+func FindConversationIn(lookup ConversationLookup, index map[string]string, stableClientID, email, model string, msgs []RoleText) (ConversationRecord, bool) {
 	if len(msgs) == 0 {
 		return ConversationRecord{}, false
 	}
-	if rec, ok := FindByMessageListIn(items, index, stableClientID, email, model, msgs); ok {
+	if rec, ok := FindByMessageListIn(lookup, index, stableClientID, email, model, msgs); ok {
 		return rec, true
 	}
-	if rec, ok := FindByMessageListIn(items, index, stableClientID, email, model, SanitizeAssistantMessages(msgs)); ok {
+	if rec, ok := FindByMessageListIn(lookup, index, stableClientID, email, model, SanitizeAssistantMessages(msgs)); ok {
 		return rec, true
 	}
 	return ConversationRecord{}, false
 }
 
 // FindReusableSessionIn returns reusable metadata and the remaining message suffix.
-func FindReusableSessionIn(items map[string]ConversationRecord, index map[string]string, stableClientID, email, model string, msgs []RoleText) (ConversationRecord, []string, int, bool) {
+func FindReusableSessionIn(lookup ConversationLookup, index map[string]string, stableClientID, email, model string, msgs []RoleText) (ConversationRecord, []string, int, bool) {
 	if len(msgs) < 2 {
 		return ConversationRecord{}, nil, 0, false
 	}
@@ -1007,7 +1494,7 @@ func FindReusableSessionIn(items map[string]ConversationRecord, index map[string
 		sub := msgs[:searchEnd]
 		tail := sub[len(sub)-1]
 		if strings.EqualFold(tail.Role, "assistant") || strings.EqualFold(tail.Role, "system") {
-			if rec, ok := FindConversationIn(items, index, stableClientID, email, model, sub); ok {
+			if rec, ok := FindConversationIn(lookup, index, stableClientID, email, model, sub); ok {
 				return rec, rec.Metadata, searchEnd, true
 			}
 		}
@@ -0,0 +1,85 @@
+package geminiwebapi
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// streamProfileFor returns the stream profile configured for apiKey. Profiles listing the
+// key take precedence over a catch-all profile without api-keys.
+func streamProfileFor(cfg *config.Config, apiKey string) *config.GeminiWebStreamProfile {
+	if cfg == nil {
+		return nil
+	}
+	var fallback *config.GeminiWebStreamProfile
+	for i := range cfg.GeminiWeb.StreamProfiles {
+		profile := &cfg.GeminiWeb.StreamProfiles[i]
+		if len(profile.APIKeys) == 0 {
+			if fallback == nil {
+				fallback = profile
+			}
+			continue
+		}
+		if apiKey != "" && matchesAny(profile.APIKeys, apiKey) {
+			return profile
+		}
+	}
+	return fallback
+}
+
+// shapeOpenAIStream rewrites OpenAI chat completion chunks according to profile.
+func shapeOpenAIStream(chunks []string, profile *config.GeminiWebStreamProfile) []string {
+	if profile == nil || len(chunks) == 0 {
+		return chunks
+	}
+	out := make([]string, 0, len(chunks)+2)
+	if profile.RoleChunk {
+		role := `{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}`
+		first := gjson.Parse(chunks[0])
+		role, _ = sjson.Set(role, "id", first.Get("id").String())
+		role, _ = sjson.Set(role, "created", first.Get("created").Int())
+		role, _ = sjson.Set(role, "model", first.Get("model").String())
+		out = append(out, role)
+	}
+	var final string
+	for _, chunk := range chunks {
+		if strings.TrimSpace(chunk) == "" {
+			continue
+		}
+		if profile.FinalChunk {
+			if reason := gjson.Get(chunk, "choices.0.finish_reason"); reason.Exists() && reason.Type != gjson.Null {
+				final = `{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[{"index":0,"delta":{},"finish_reason":""}]}`
+				final, _ = sjson.Set(final, "id", gjson.Get(chunk, "id").String())
+				final, _ = sjson.Set(final, "created", gjson.Get(chunk, "created").Int())
+				final, _ = sjson.Set(final, "model", gjson.Get(chunk, "model").String())
+				final, _ = sjson.Set(final, "choices.0.finish_reason", reason.String())
+				if usage := gjson.Get(chunk, "usage"); usage.Exists() {
+					final, _ = sjson.SetRaw(final, "usage", usage.Raw)
+				}
+				chunk, _ = sjson.Set(chunk, "choices.0.finish_reason", nil)
+				chunk, _ = sjson.Delete(chunk, "usage")
+			}
+		}
+		if profile.OmitNullFields {
+			chunk = omitNullChunkFields(chunk)
+		}
+		out = append(out, chunk)
+	}
+	if final != "" {
+		out = append(out, final)
+	}
+	return out
+}
+
+func omitNullChunkFields(chunk string) string {
+	chunk, _ = sjson.Delete(chunk, "choices.0.native_finish_reason")
+	for _, field := range []string{"role", "content", "reasoning_content", "tool_calls"} {
+		if v := gjson.Get(chunk, "choices.0.delta."+field); v.Exists() && v.Type == gjson.Null {
+			chunk, _ = sjson.Delete(chunk, "choices.0.delta."+field)
+		}
+	}
+	return chunk
+}
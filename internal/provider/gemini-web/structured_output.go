@@ -0,0 +1,377 @@
+package geminiwebapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// Gemini Web cannot be asked for JSON natively, so response_format is enforced by
+// instructing the model, validating its reply and asking it again when the reply does not
+// parse or does not match the schema.
+
+const defaultStructuredOutputAttempts = 3
+
+// responseFormat is the structured output a request asks for.
+type responseFormat struct {
+	// Schema is the JSON Schema the reply must satisfy; it does not exist for plain JSON mode.
+	Schema gjson.Result
+	// Name is the schema name given by the client, if any.
+	Name string
+}
+
+// responseFormatFrom returns the structured output requested by an OpenAI response_format or
+// a Gemini generationConfig, or nil when the request expects free text.
+func responseFormatFrom(original, translated []byte) *responseFormat {
+	rf := gjson.GetBytes(original, "response_format")
+	switch strings.ToLower(rf.Get("type").String()) {
+	case "json_object":
+		return &responseFormat{}
+	case "json_schema":
+		return &responseFormat{Schema: rf.Get("json_schema.schema"), Name: rf.Get("json_schema.name").String()}
+	case "text":
+		return nil
+	}
+	gen := gjson.GetBytes(translated, "generationConfig")
+	if !strings.EqualFold(gen.Get("responseMimeType").String(), "application/json") {
+		return nil
+	}
+	schema := gen.Get("responseJsonSchema")
+	if !schema.Exists() {
+		schema = gen.Get("responseSchema")
+	}
+	return &responseFormat{Schema: schema}
+}
+
+// promptBlock tells the model to reply with JSON only, conforming to the schema if any.
+func (f *responseFormat) promptBlock() string {
+	var sb strings.Builder
+	sb.WriteString("<response_format>\nReply with a single valid JSON value and nothing else: no prose, no explanations and no markdown code fences.\n")
+	if f.Schema.Exists() {
+		if f.Name != "" {
+			fmt.Fprintf(&sb, "The JSON must conform to the JSON Schema %q:\n", f.Name)
+		} else {
+			sb.WriteString("The JSON must conform to this JSON Schema:\n")
+		}
+		sb.WriteString(compactJSON(f.Schema.Raw))
+		sb.WriteString("\n")
+	} else {
+		sb.WriteString("The JSON must be an object.\n")
+	}
+	sb.WriteString("</response_format>")
+	return sb.String()
+}
+
+// check extracts the JSON from a reply and validates it, returning it compacted.
+func (f *responseFormat) check(text string) (string, error) {
+	raw := extractJSONText(unescapeGeminiText(text))
+	if raw == "" {
+		return "", fmt.Errorf("reply is not valid JSON")
+	}
+	value := gjson.Parse(raw)
+	if f.Schema.Exists() {
+		if err := validateJSONSchema(value, f.Schema, f.Schema, "$"); err != nil {
+			return "", err
+		}
+	} else if !value.IsObject() {
+		return "", fmt.Errorf("reply is not a JSON object")
+	}
+	return compactJSON(raw), nil
+}
+
+// reaskPrompt asks the model to correct a reply that failed validation.
+func (f *responseFormat) reaskPrompt(err error) string {
+	return "Your previous reply was rejected: " + err.Error() + ".\n\n" + f.promptBlock()
+}
+
+// extractJSONText returns the JSON value in text, tolerating a surrounding code fence or
+// prose, or "" when there is none.
+func extractJSONText(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+			text = text[nl+1:]
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+	}
+	if gjson.Valid(text) {
+		return text
+	}
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start && gjson.Valid(text[start:end+1]) {
+		return text[start : end+1]
+	}
+	return ""
+}
+
+// structuredOutputAttempts returns how many replies are requested before giving up.
+func structuredOutputAttempts(cfg *config.Config) int {
+	if cfg == nil || cfg.GeminiWeb.StructuredOutput.MaxAttempts <= 0 {
+		return defaultStructuredOutputAttempts
+	}
+	return cfg.GeminiWeb.StructuredOutput.MaxAttempts
+}
+
+// validateJSONSchema checks value against the commonly used subset of JSON Schema: type,
+// enum, const, properties, required, additionalProperties, items, length and range bounds,
+// allOf/anyOf/oneOf and local $ref. Unknown keywords are ignored.
+func validateJSONSchema(value, schema, root gjson.Result, path string) error {
+	if schema.Type == gjson.True || !schema.Exists() {
+		return nil
+	}
+	if schema.Type == gjson.False {
+		return fmt.Errorf("%s is not allowed", path)
+	}
+	if ref := schema.Get(gjsonKey("$ref")).String(); ref != "" {
+		target, ok := resolveSchemaRef(root, ref)
+		if !ok {
+			return fmt.Errorf("%s: unresolvable $ref %s", path, ref)
+		}
+		return validateJSONSchema(value, target, root, path)
+	}
+
+	if types := schema.Get("type"); types.Exists() {
+		var allowed []string
+		if types.IsArray() {
+			for _, t := range types.Array() {
+				allowed = append(allowed, strings.ToLower(t.String()))
+			}
+		} else {
+			allowed = []string{strings.ToLower(types.String())}
+		}
+		if schema.Get("nullable").Bool() {
+			allowed = append(allowed, "null")
+		}
+		matched := false
+		for _, t := range allowed {
+			if jsonTypeMatches(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s must be of type %s", path, strings.Join(allowed, " or "))
+		}
+	}
+	if enum := schema.Get("enum"); enum.IsArray() {
+		found := false
+		for _, e := range enum.Array() {
+			if jsonEqual(value, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s must be one of %s", path, compactJSON(enum.Raw))
+		}
+	}
+	if c := schema.Get("const"); c.Exists() && !jsonEqual(value, c) {
+		return fmt.Errorf("%s must equal %s", path, compactJSON(c.Raw))
+	}
+
+	switch {
+	case value.IsObject():
+		props := schema.Get("properties")
+		for _, req := range schema.Get("required").Array() {
+			if !value.Get(gjsonKey(req.String())).Exists() {
+				return fmt.Errorf("%s is missing required property %q", path, req.String())
+			}
+		}
+		additional := schema.Get("additionalProperties")
+		var errProp error
+		value.ForEach(func(key, v gjson.Result) bool {
+			childPath := path + "." + key.String()
+			if sub := props.Get(gjsonKey(key.String())); sub.Exists() {
+				errProp = validateJSONSchema(v, sub, root, childPath)
+			} else if additional.Type == gjson.False {
+				errProp = fmt.Errorf("%s has unexpected property %q", path, key.String())
+			} else if additional.IsObject() {
+				errProp = validateJSONSchema(v, additional, root, childPath)
+			}
+			return errProp == nil
+		})
+		if errProp != nil {
+			return errProp
+		}
+	case value.IsArray():
+		items := value.Array()
+		if min := schema.Get("minItems"); min.Exists() && int64(len(items)) < min.Int() {
+			return fmt.Errorf("%s must have at least %d items", path, min.Int())
+		}
+		if max := schema.Get("maxItems"); max.Exists() && int64(len(items)) > max.Int() {
+			return fmt.Errorf("%s must have at most %d items", path, max.Int())
+		}
+		if itemSchema := schema.Get("items"); itemSchema.Exists() {
+			for i, item := range items {
+				if err := validateJSONSchema(item, itemSchema, root, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case value.Type == gjson.String:
+		n := int64(len([]rune(value.String())))
+		if min := schema.Get("minLength"); min.Exists() && n < min.Int() {
+			return fmt.Errorf("%s must be at least %d characters", path, min.Int())
+		}
+		if max := schema.Get("maxLength"); max.Exists() && n > max.Int() {
+			return fmt.Errorf("%s must be at most %d characters", path, max.Int())
+		}
+	case value.Type == gjson.Number:
+		if min := schema.Get("minimum"); min.Exists() && value.Float() < min.Float() {
+			return fmt.Errorf("%s must be >= %v", path, min.Float())
+		}
+		if max := schema.Get("maximum"); max.Exists() && value.Float() > max.Float() {
+			return fmt.Errorf("%s must be <= %v", path, max.Float())
+		}
+	}
+
+	for _, sub := range schema.Get("allOf").Array() {
+		if err := validateJSONSchema(value, sub, root, path); err != nil {
+			return err
+		}
+	}
+	if anyOf := schema.Get("anyOf").Array(); len(anyOf) > 0 {
+		var firstErr error
+		for _, sub := range anyOf {
+			err := validateJSONSchema(value, sub, root, path)
+			if err == nil {
+				firstErr = nil
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr != nil {
+			return fmt.Errorf("%s matches none of anyOf: %v", path, firstErr)
+		}
+	}
+	if oneOf := schema.Get("oneOf").Array(); len(oneOf) > 0 {
+		matches := 0
+		for _, sub := range oneOf {
+			if validateJSONSchema(value, sub, root, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s must match exactly one of oneOf, matched %d", path, matches)
+		}
+	}
+	return nil
+}
+
+// resolveSchemaRef resolves a local reference such as "#/$defs/Item".
+func resolveSchemaRef(root gjson.Result, ref string) (gjson.Result, bool) {
+	if ref == "#" {
+		return root, true
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return gjson.Result{}, false
+	}
+	cur := root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		cur = cur.Get(gjsonKey(token))
+		if !cur.Exists() {
+			return gjson.Result{}, false
+		}
+	}
+	return cur, true
+}
+
+func jsonTypeMatches(value gjson.Result, t string) bool {
+	switch t {
+	case "object":
+		return value.IsObject()
+	case "array":
+		return value.IsArray()
+	case "string":
+		return value.Type == gjson.String
+	case "number":
+		return value.Type == gjson.Number
+	case "integer":
+		return value.Type == gjson.Number && value.Float() == float64(int64(value.Float()))
+	case "boolean":
+		return value.Type == gjson.True || value.Type == gjson.False
+	case "null":
+		return value.Type == gjson.Null
+	}
+	return true
+}
+
+func jsonEqual(a, b gjson.Result) bool {
+	var va, vb any
+	if json.Unmarshal([]byte(a.Raw), &va) != nil || json.Unmarshal([]byte(b.Raw), &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
+
+// gjsonKey escapes a literal object key for use as a gjson path.
+func gjsonKey(key string) string {
+	var sb strings.Builder
+	for _, r := range key {
+		switch r {
+		case '.', '*', '?', '|', '#', '@', '\\', '!', '=', '<', '>', '%', '$':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// enforceResponseFormat validates the reply against prep.format and, while attempts remain,
+// sends the validation error back in the same conversation so the model can correct it.
+// Replies that call a tool are returned unchecked.
+func (s *GeminiWebState) enforceResponseFormat(ctx context.Context, prep *geminiWebPrepared, output ModelOutput) (ModelOutput, *interfaces.ErrorMessage) {
+	cfg := s.config()
+	attempts := structuredOutputAttempts(cfg)
+	breakerCfg := breakerSettingsFor(cfg)
+	for attempt := 1; ; attempt++ {
+		if len(output.Candidates) == 0 {
+			return output, nil
+		}
+		text := output.Candidates[output.Chosen].Text
+		if len(prep.tools) > 0 {
+			if _, calls := extractToolCalls(unescapeGeminiText(text), toolNames(prep.tools)); len(calls) > 0 {
+				return output, nil
+			}
+		}
+		normalized, errCheck := prep.format.check(text)
+		if errCheck == nil {
+			output.Candidates[output.Chosen].Text = normalized
+			return output, nil
+		}
+		if attempt >= attempts {
+			return output, &interfaces.ErrorMessage{
+				StatusCode: http.StatusBadGateway,
+				Error:      fmt.Errorf("gemini web reply does not match response_format after %d attempts: %w", attempts, errCheck),
+			}
+		}
+		log.Debugf("gemini web: reply %d failed response_format validation, asking again: %v", attempt, errCheck)
+		if ok, wait := s.breaker.allow(breakerCfg, time.Now()); !ok {
+			return output, &interfaces.ErrorMessage{
+				StatusCode: http.StatusServiceUnavailable,
+				Error:      fmt.Errorf("gemini web account %s: circuit breaker open, retry in %s", s.Label(), wait.Round(time.Second)),
+			}
+		}
+		next, errSend := SendWithSplit(ctx, prep.chat, prep.format.reaskPrompt(errCheck), nil, cfg)
+		s.breaker.record(breakerCfg, errSend, time.Now())
+		if errSend != nil {
+			return output, s.wrapSendError(errSend)
+		}
+		output = next
+	}
+}
@@ -0,0 +1,149 @@
+package geminiwebapi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// SystemPromptModeDelimiter wraps the system prompt in a delimited block on the first turn.
+	SystemPromptModeDelimiter = "delimiter"
+	// SystemPromptModeGem attaches the system prompt as a custom Gem.
+	SystemPromptModeGem = "gem"
+
+	systemGemNamePrefix   = "CLIProxy system "
+	defaultSystemGemLimit = 16
+)
+
+// ExtractSystemInstruction returns the text of the Gemini-format system instruction, if any.
+func ExtractSystemInstruction(rawJSON []byte) string {
+	node := gjson.GetBytes(rawJSON, "system_instruction")
+	if !node.Exists() {
+		node = gjson.GetBytes(rawJSON, "systemInstruction")
+	}
+	if !node.Exists() {
+		return ""
+	}
+	var b strings.Builder
+	node.Get("parts").ForEach(func(_, part gjson.Result) bool {
+		if text := part.Get("text"); text.Exists() && strings.TrimSpace(text.String()) != "" {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(text.String())
+		}
+		return true
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// PrependSystemBlock places the system prompt, wrapped in a consistent delimiter, ahead of the
+// first message so Gemini Web treats it as standing instructions rather than conversation text.
+func PrependSystemBlock(msgs []RoleText, systemPrompt string) []RoleText {
+	block := "<system_instructions>\n" + systemPrompt + "\n</system_instructions>"
+	if len(msgs) == 0 {
+		return []RoleText{{Role: "user", Text: block}}
+	}
+	out := cloneRoleTextSlice(msgs)
+	out[0].Text = block + "\n\n" + out[0].Text
+	return out
+}
+
+// systemGemEntry is a managed system prompt Gem and when it was last attached to a request.
+type systemGemEntry struct {
+	id       string
+	lastUsed time.Time
+}
+
+// systemPromptGem returns a custom Gem carrying systemPrompt, reusing the one created for the
+// same content. Managed Gems already on the account are adopted on first use, and the least
+// recently used ones are deleted so no more than the configured limit exist.
+func (s *GeminiWebState) systemPromptGem(systemPrompt string) (*Gem, error) {
+	key := conversation.Sha256Hex(systemPrompt)[:12]
+	name := systemGemNamePrefix + key
+
+	s.systemGemMu.Lock()
+	defer s.systemGemMu.Unlock()
+
+	now := time.Now()
+	if entry, ok := s.systemGems[key]; ok {
+		entry.lastUsed = now
+		return &Gem{ID: entry.id, Name: name}, nil
+	}
+	client, err := s.ensureClient()
+	if err != nil {
+		return nil, err
+	}
+	if !s.systemGemsSynced {
+		if err = s.adoptSystemGems(client); err != nil {
+			return nil, err
+		}
+		if entry, ok := s.systemGems[key]; ok {
+			entry.lastUsed = now
+			return &Gem{ID: entry.id, Name: name}, nil
+		}
+	}
+	if err = s.evictSystemGems(client, s.systemGemLimit()-1); err != nil {
+		return nil, err
+	}
+	gem, err := client.CreateGem(name, systemPrompt, "System prompt managed by CLIProxyAPI")
+	if err != nil {
+		return nil, err
+	}
+	s.systemGems[key] = &systemGemEntry{id: gem.ID, lastUsed: now}
+	return &gem, nil
+}
+
+// adoptSystemGems records the managed Gems left on the account by earlier runs, deleting
+// duplicates of the same prompt. Adopted Gems count as least recently used.
+func (s *GeminiWebState) adoptSystemGems(client *GeminiClient) error {
+	gems, err := client.FetchGems(false)
+	if err != nil {
+		return err
+	}
+	for _, gem := range gems {
+		if gem.Predefined || !strings.HasPrefix(gem.Name, systemGemNamePrefix) {
+			continue
+		}
+		key := strings.TrimPrefix(gem.Name, systemGemNamePrefix)
+		if _, dup := s.systemGems[key]; dup {
+			if errDelete := client.DeleteGem(gem.ID); errDelete != nil {
+				log.Warnf("gemini web: failed to delete duplicate system prompt gem %s: %v", gem.ID, errDelete)
+			}
+			continue
+		}
+		s.systemGems[key] = &systemGemEntry{id: gem.ID}
+	}
+	s.systemGemsSynced = true
+	return nil
+}
+
+// evictSystemGems deletes least recently used managed Gems until at most keep remain.
+func (s *GeminiWebState) evictSystemGems(client *GeminiClient, keep int) error {
+	for len(s.systemGems) > keep {
+		oldestKey := ""
+		var oldest *systemGemEntry
+		for key, entry := range s.systemGems {
+			if oldest == nil || entry.lastUsed.Before(oldest.lastUsed) {
+				oldestKey, oldest = key, entry
+			}
+		}
+		if err := client.DeleteGem(oldest.id); err != nil {
+			return fmt.Errorf("evict system prompt gem %s: %w", oldest.id, err)
+		}
+		delete(s.systemGems, oldestKey)
+	}
+	return nil
+}
+
+func (s *GeminiWebState) systemGemLimit() int {
+	if cfg := s.config(); cfg != nil && cfg.GeminiWeb.SystemPromptGemLimit > 0 {
+		return cfg.GeminiWeb.SystemPromptGemLimit
+	}
+	return defaultSystemGemLimit
+}
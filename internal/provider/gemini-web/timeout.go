@@ -0,0 +1,75 @@
+package geminiwebapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+// headerTimeout lets a client set the timeout of one request in seconds, also accepted as
+// x_cliproxy.timeout in the body.
+const headerTimeout = "X-CLIProxy-Timeout"
+
+// modelTimeout returns the configured timeout of requests to modelName, whose underlying model
+// is underlying: the first model rule matching either name, else gemini-web.timeout.seconds.
+func modelTimeout(cfg *config.Config, modelName, underlying string) time.Duration {
+	timeout := time.Duration(geminiWebDefaultTimeoutSec) * time.Second
+	if cfg == nil {
+		return timeout
+	}
+	t := cfg.GeminiWeb.Timeout
+	if t.Seconds > 0 {
+		timeout = time.Duration(t.Seconds) * time.Second
+	}
+	for _, rule := range t.Models {
+		if rule.Seconds > 0 && matchesAny(rule.Models, modelName, underlying) {
+			return time.Duration(rule.Seconds) * time.Second
+		}
+	}
+	return timeout
+}
+
+// requestTimeout returns how long the upstream exchange of a request to modelName may take:
+// the client's timeout when it sent one, capped at gemini-web.timeout.max-request-seconds or
+// by default at the model timeout, and otherwise the model timeout.
+func (s *GeminiWebState) requestTimeout(ctx context.Context, modelName string, original []byte) (time.Duration, *interfaces.ErrorMessage) {
+	cfg := s.config()
+	timeout := modelTimeout(cfg, modelName, MapAliasToUnderlying(modelName))
+	value := clientLocaleValue(ctx, original, headerTimeout, "timeout")
+	if value == "" {
+		return timeout, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("bad request: %s must be a positive number of seconds, got %q", headerTimeout, value),
+			Code:       interfaces.ErrorCodeInvalidRequest,
+		}
+	}
+	limit := timeout
+	if cfg != nil && cfg.GeminiWeb.Timeout.MaxRequestSeconds > 0 {
+		limit = time.Duration(cfg.GeminiWeb.Timeout.MaxRequestSeconds) * time.Second
+	}
+	requested := time.Duration(seconds) * time.Second
+	if requested > limit {
+		log.Debugf("gemini web: requested timeout %s exceeds the limit of %s for %s", requested, limit, modelName)
+		return limit, nil
+	}
+	return requested, nil
+}
+
+// clientTimeoutSec is the timeout of the upstream client's own requests, such as fetching
+// Gems. Content generation is bounded by the request timeout instead.
+func (s *GeminiWebState) clientTimeoutSec() float64 {
+	if cfg := s.config(); cfg != nil && cfg.GeminiWeb.Timeout.Seconds > 0 {
+		return float64(cfg.GeminiWeb.Timeout.Seconds)
+	}
+	return geminiWebDefaultTimeoutSec
+}
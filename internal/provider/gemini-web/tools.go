@@ -0,0 +1,311 @@
+package geminiwebapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Gemini Web has no tools API, so function calling is emulated: the declared tools are
+// described in the prompt, the model answers with <tool_call> blocks, and those blocks are
+// turned back into functionCall parts of the Gemini-format response.
+
+// Tool choice modes, normalised from the OpenAI, Claude and Gemini request shapes.
+const (
+	toolChoiceAuto     = "auto"
+	toolChoiceNone     = "none"
+	toolChoiceRequired = "required"
+)
+
+var (
+	reToolCallFence = regexp.MustCompile("(?s)`{3,}[A-Za-z]*[ \\t]*\\r?\\n?\\s*((?:<tool_call>.*?</tool_call>\\s*)+)`{3,}")
+	reToolCallBlock = regexp.MustCompile(`(?s)<tool_call>(.*?)</tool_call>`)
+	reToolCallName  = regexp.MustCompile(`(?s)<name>(.*?)</name>`)
+	reToolCallArgs  = regexp.MustCompile(`(?s)<arguments>(.*?)</arguments>`)
+)
+
+// emulatedToolCall is one tool invocation parsed from the model's reply.
+type emulatedToolCall struct {
+	Name string
+	Args string
+}
+
+// toolDeclarations returns the function declarations of a Gemini-format request.
+func toolDeclarations(rawJSON []byte) []gjson.Result {
+	var out []gjson.Result
+	gjson.GetBytes(rawJSON, "tools").ForEach(func(_, tool gjson.Result) bool {
+		decls := tool.Get("functionDeclarations")
+		if !decls.Exists() {
+			decls = tool.Get("function_declarations")
+		}
+		decls.ForEach(func(_, decl gjson.Result) bool {
+			if strings.TrimSpace(decl.Get("name").String()) != "" {
+				out = append(out, decl)
+			}
+			return true
+		})
+		return true
+	})
+	return out
+}
+
+// toolChoiceFrom returns the tool choice mode of a request and, when one specific function is
+// forced, its name. original is the client request; translated is its Gemini form.
+func toolChoiceFrom(original, translated []byte) (string, string) {
+	choice := gjson.GetBytes(original, "tool_choice")
+	switch {
+	case choice.Type == gjson.String:
+		switch strings.ToLower(choice.String()) {
+		case "none":
+			return toolChoiceNone, ""
+		case "required", "any":
+			return toolChoiceRequired, ""
+		}
+	case choice.IsObject():
+		switch strings.ToLower(choice.Get("type").String()) {
+		case "none":
+			return toolChoiceNone, ""
+		case "any":
+			return toolChoiceRequired, ""
+		case "function":
+			if name := choice.Get("function.name").String(); name != "" {
+				return toolChoiceRequired, name
+			}
+			return toolChoiceRequired, choice.Get("name").String()
+		case "tool":
+			return toolChoiceRequired, choice.Get("name").String()
+		}
+	}
+	mode := gjson.GetBytes(translated, "toolConfig.functionCallingConfig.mode")
+	if !mode.Exists() {
+		mode = gjson.GetBytes(translated, "tool_config.function_calling_config.mode")
+	}
+	switch strings.ToUpper(mode.String()) {
+	case "NONE":
+		return toolChoiceNone, ""
+	case "ANY":
+		names := gjson.GetBytes(translated, "toolConfig.functionCallingConfig.allowedFunctionNames").Array()
+		if len(names) == 1 {
+			return toolChoiceRequired, names[0].String()
+		}
+		return toolChoiceRequired, ""
+	}
+	return toolChoiceAuto, ""
+}
+
+// toolPromptBlock describes the declared tools and the reply format for calling them, or
+// returns "" when there are no tools or the request disables tool use.
+func toolPromptBlock(decls []gjson.Result, mode, forced string) string {
+	if len(decls) == 0 || mode == toolChoiceNone {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("<tools>\nYou can call the following tools. Each has a name, a description and a JSON Schema for its arguments.\n")
+	for _, decl := range decls {
+		fmt.Fprintf(&sb, "\n- name: %s\n", decl.Get("name").String())
+		if desc := strings.TrimSpace(decl.Get("description").String()); desc != "" {
+			fmt.Fprintf(&sb, "  description: %s\n", desc)
+		}
+		params := decl.Get("parameters")
+		if !params.Exists() {
+			params = decl.Get("parametersJsonSchema")
+		}
+		if params.Exists() {
+			fmt.Fprintf(&sb, "  parameters: %s\n", compactJSON(params.Raw))
+		}
+	}
+	sb.WriteString("\nTo call a tool, reply with one block per call in exactly this format, and write nothing after the last block:\n")
+	sb.WriteString("```xml\n<tool_call>\n<name>TOOL_NAME</name>\n<arguments>{\"argument\": \"value\"}</arguments>\n</tool_call>\n```\n")
+	sb.WriteString("The arguments must be one JSON object matching the tool's parameters. Tool results come back in <tool_result> blocks.\n")
+	switch {
+	case forced != "":
+		fmt.Fprintf(&sb, "You must call the tool %s in this reply.\n", forced)
+	case mode == toolChoiceRequired:
+		sb.WriteString("You must call at least one tool in this reply.\n")
+	default:
+		sb.WriteString("If no tool is needed, answer normally without any tool_call block.\n")
+	}
+	sb.WriteString("</tools>")
+	return sb.String()
+}
+
+// renderToolCall renders a functionCall part of the history in the format the model is asked
+// to use, so earlier calls read the same as the model's own replies.
+func renderToolCall(call gjson.Result) string {
+	args := call.Get("args").Raw
+	if args == "" {
+		args = "{}"
+	}
+	return "<tool_call>\n<name>" + call.Get("name").String() + "</name>\n<arguments>" + compactJSON(args) + "</arguments>\n</tool_call>"
+}
+
+// renderToolResult renders a functionResponse part of the history.
+func renderToolResult(resp gjson.Result) string {
+	content := resp.Get("response.result")
+	if !content.Exists() {
+		content = resp.Get("response")
+	}
+	text := content.Raw
+	if content.Type == gjson.String {
+		text = content.String()
+	}
+	return "<tool_result>\n<name>" + resp.Get("name").String() + "</name>\n<content>" + text + "</content>\n</tool_result>"
+}
+
+// extractToolCalls removes the tool_call blocks naming a declared tool from text and returns
+// what is left with the parsed calls. Blocks that do not parse are left in the text.
+func extractToolCalls(text string, declared []string) (string, []emulatedToolCall) {
+	var calls []emulatedToolCall
+	parseBlocks := func(segment string) ([]emulatedToolCall, bool) {
+		var found []emulatedToolCall
+		for _, m := range reToolCallBlock.FindAllStringSubmatch(segment, -1) {
+			call, ok := parseToolCall(m[1], declared)
+			if !ok {
+				return nil, false
+			}
+			found = append(found, call)
+		}
+		return found, len(found) > 0
+	}
+	text = reToolCallFence.ReplaceAllStringFunc(text, func(m string) string {
+		found, ok := parseBlocks(m)
+		if !ok {
+			return m
+		}
+		calls = append(calls, found...)
+		return ""
+	})
+	text = reToolCallBlock.ReplaceAllStringFunc(text, func(m string) string {
+		found, ok := parseBlocks(m)
+		if !ok {
+			return m
+		}
+		calls = append(calls, found...)
+		return ""
+	})
+	if len(calls) == 0 {
+		return text, nil
+	}
+	return strings.TrimSpace(text), calls
+}
+
+// parseToolCall parses the body of one tool_call block. The model may use the requested
+// <name>/<arguments> form or a JSON object with "name" and "arguments".
+func parseToolCall(body string, declared []string) (emulatedToolCall, bool) {
+	var name, args string
+	if m := reToolCallName.FindStringSubmatch(body); m != nil {
+		name = m[1]
+		if a := reToolCallArgs.FindStringSubmatch(body); a != nil {
+			args = a[1]
+		}
+	} else if obj := gjson.Parse(strings.TrimSpace(body)); obj.IsObject() {
+		name = obj.Get("name").String()
+		a := obj.Get("arguments")
+		if !a.Exists() {
+			a = obj.Get("args")
+		}
+		args = a.Raw
+		if a.Type == gjson.String {
+			args = a.String()
+		}
+	}
+	name = declaredName(declared, strings.TrimSpace(name))
+	if name == "" {
+		return emulatedToolCall{}, false
+	}
+	args = strings.TrimSpace(args)
+	if args == "" {
+		args = "{}"
+	}
+	if !gjson.Valid(args) {
+		args = html.UnescapeString(args)
+	}
+	if !gjson.Valid(args) || !gjson.Parse(args).IsObject() {
+		return emulatedToolCall{}, false
+	}
+	return emulatedToolCall{Name: name, Args: compactJSON(args)}, true
+}
+
+// applyEmulatedToolCalls rewrites a Gemini-format response so tool_call blocks in its text
+// become functionCall parts.
+func applyEmulatedToolCalls(gemBytes []byte, decls []gjson.Result) []byte {
+	if len(decls) == 0 {
+		return gemBytes
+	}
+	declared := toolNames(decls)
+	parts := gjson.GetBytes(gemBytes, "candidates.0.content.parts").Array()
+	rebuilt := []byte(`[]`)
+	var calls []emulatedToolCall
+	for _, part := range parts {
+		text := part.Get("text")
+		if !text.Exists() || part.Get("thought").Bool() {
+			rebuilt, _ = sjson.SetRawBytes(rebuilt, "-1", []byte(part.Raw))
+			continue
+		}
+		rest, found := extractToolCalls(text.String(), declared)
+		calls = append(calls, found...)
+		if rest != "" {
+			p, _ := sjson.SetBytes([]byte(part.Raw), "text", rest)
+			rebuilt, _ = sjson.SetRawBytes(rebuilt, "-1", p)
+		}
+	}
+	if len(calls) == 0 {
+		return gemBytes
+	}
+	for _, call := range calls {
+		p := []byte(`{"functionCall":{"name":"","args":{}}}`)
+		p, _ = sjson.SetBytes(p, "functionCall.name", call.Name)
+		p, _ = sjson.SetRawBytes(p, "functionCall.args", []byte(call.Args))
+		rebuilt, _ = sjson.SetRawBytes(rebuilt, "-1", p)
+	}
+	out, _ := sjson.SetRawBytes(gemBytes, "candidates.0.content.parts", rebuilt)
+	return out
+}
+
+func toolNames(decls []gjson.Result) []string {
+	names := make([]string, 0, len(decls))
+	for _, decl := range decls {
+		names = append(names, decl.Get("name").String())
+	}
+	return names
+}
+
+// finishOpenAIToolCalls numbers the tool_calls at path in an OpenAI completion or chunk, as
+// streaming clients assemble calls by index, and reports them with finish_reason "tool_calls".
+func finishOpenAIToolCalls(payload, path string) string {
+	calls := gjson.Get(payload, path).Array()
+	if len(calls) == 0 {
+		return payload
+	}
+	for i := range calls {
+		payload, _ = sjson.Set(payload, fmt.Sprintf("%s.%d.index", path, i), i)
+	}
+	if reason := gjson.Get(payload, "choices.0.finish_reason"); reason.Exists() && reason.Type != gjson.Null {
+		payload, _ = sjson.Set(payload, "choices.0.finish_reason", "tool_calls")
+	}
+	return payload
+}
+
+func compactJSON(raw string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(raw)); err != nil {
+		return raw
+	}
+	return buf.String()
+}
+
+// declaredName returns the declared spelling of name, or "" when no tool has that name.
+func declaredName(declared []string, name string) string {
+	for _, d := range declared {
+		if name != "" && strings.EqualFold(d, name) {
+			return d
+		}
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package geminiwebapi
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mtls"
+)
+
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 16
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultTLSSessionCacheSize = 256
+)
+
+// transportKey identifies a shared transport: clients with the same proxy and TLS
+// verification setting share connections and TLS sessions.
+type transportKey struct {
+	proxyURL string
+	insecure bool
+}
+
+var (
+	transportMu  sync.Mutex
+	transportCfg config.GeminiWebTransport
+	transports   = make(map[transportKey]*http.Transport)
+)
+
+// ConfigureTransport applies the transport tuning. When it changes, the shared transports are
+// dropped and their idle connections closed; clients built afterwards use new transports.
+func ConfigureTransport(cfg config.GeminiWebTransport) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if reflect.DeepEqual(cfg, transportCfg) {
+		return
+	}
+	transportCfg = cfg
+	resetTransportsLocked()
+}
+
+// ResetTransports drops the shared transports, for example after the upstream TLS settings
+// changed, so that clients built afterwards start from fresh connections.
+func ResetTransports() {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	resetTransportsLocked()
+}
+
+func resetTransportsLocked() {
+	for key, transport := range transports {
+		transport.CloseIdleConnections()
+		delete(transports, key)
+	}
+}
+
+// sharedTransport returns the transport shared by clients using proxyURL, creating it on
+// first use.
+func sharedTransport(proxyURL string, insecure bool) *http.Transport {
+	key := transportKey{proxyURL: proxyURL, insecure: insecure}
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if transport, ok := transports[key]; ok {
+		return transport
+	}
+	transport := newTransport(transportCfg, proxyURL, insecure)
+	transports[key] = transport
+	return transport
+}
+
+func newTransport(cfg config.GeminiWebTransport, proxyURL string, insecure bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if resolver := newResolver(cfg.DNSServers); resolver != nil {
+		dialer.Resolver = resolver
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          defaultMaxIdleConns,
+		MaxIdleConnsPerHost:   defaultMaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       defaultIdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeoutSeconds > 0 {
+		transport.IdleConnTimeout = time.Duration(cfg.IdleConnTimeoutSeconds) * time.Second
+	}
+	if proxyURL != "" {
+		if pu, err := url.Parse(proxyURL); err == nil {
+			transport.Proxy = http.ProxyURL(pu)
+			mtls.ApplyOutbound(transport)
+		}
+	}
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	if size := cfg.TLSSessionCacheSize; size >= 0 {
+		if size == 0 {
+			size = defaultTLSSessionCacheSize
+		}
+		transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(size)
+	}
+	if cfg.DisableHTTP2 {
+		// A non-nil, empty TLSNextProto turns HTTP/2 off.
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	} else {
+		transport.ForceAttemptHTTP2 = true
+	}
+	return transport
+}
+
+// newResolver returns a resolver querying servers in rotation, or nil to use the system
+// resolver.
+func newResolver(servers []string) *net.Resolver {
+	var addrs []string
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		addrs = append(addrs, server)
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	var next atomic.Uint32
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			addr := addrs[int(next.Add(1)-1)%len(addrs)]
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package geminiwebapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Upload cache defaults. Upstream handles are short-lived, so the TTL is conservative.
+const (
+	defaultUploadCacheTTL        = 30 * time.Minute
+	defaultUploadCacheMaxEntries = 1024
+)
+
+type uploadCacheEntry struct {
+	id        string
+	expiresAt time.Time
+}
+
+// uploadCache maps the SHA-256 of an attachment's content to the handle returned by the upload endpoint.
+type uploadCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]uploadCacheEntry
+}
+
+func newUploadCache(ttl time.Duration, maxEntries int) *uploadCache {
+	return &uploadCache{ttl: ttl, maxEntries: maxEntries, entries: make(map[string]uploadCacheEntry)}
+}
+
+var sharedUploadCache = newUploadCache(defaultUploadCacheTTL, defaultUploadCacheMaxEntries)
+
+// ConfigureUploadCache applies gemini-web.upload-cache. Handles already cached keep their
+// expiry; disabling the cache drops them.
+func ConfigureUploadCache(cfg config.GeminiWebUploadCache) {
+	sharedUploadCache.configure(cfg)
+}
+
+func (c *uploadCache) configure(cfg config.GeminiWebUploadCache) {
+	ttl := defaultUploadCacheTTL
+	switch {
+	case cfg.TTLMinutes < 0:
+		ttl = 0
+	case cfg.TTLMinutes > 0:
+		ttl = time.Duration(cfg.TTLMinutes) * time.Minute
+	}
+	maxEntries := defaultUploadCacheMaxEntries
+	if cfg.MaxEntries > 0 {
+		maxEntries = cfg.MaxEntries
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl, c.maxEntries = ttl, maxEntries
+	if ttl == 0 {
+		clear(c.entries)
+	}
+}
+
+func (c *uploadCache) get(key string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if now.After(entry.expiresAt) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.id, true
+}
+
+// put caches id for key, dropping expired handles and, when the cache is full, the one that
+// expires first.
+func (c *uploadCache) put(key, id string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	if _, exists := c.entries[key]; !exists {
+		for len(c.entries) >= c.maxEntries {
+			oldest := ""
+			for k, entry := range c.entries {
+				if oldest == "" || entry.expiresAt.Before(c.entries[oldest].expiresAt) {
+					oldest = k
+				}
+			}
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = uploadCacheEntry{id: id, expiresAt: now.Add(c.ttl)}
+}
+
+// fileContentHash returns the hex SHA-256 of the file at path.
+func fileContentHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// uploadFileCached uploads the file unless identical content was uploaded within the TTL,
+// in which case the previously returned handle is reused.
+func uploadFileCached(path string, proxy string, insecure bool) (string, error) {
+	key, err := fileContentHash(path)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if id, ok := sharedUploadCache.get(key, now); ok {
+		log.Debugf("gemini web: reusing uploaded file handle for %s", key[:12])
+		return id, nil
+	}
+	id, err := uploadFile(path, proxy, insecure)
+	if err != nil {
+		return "", err
+	}
+	sharedUploadCache.put(key, id, now)
+	return id, nil
+}
@@ -0,0 +1,60 @@
+package geminiwebapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestUploadCacheEntryExpires(t *testing.T) {
+	cache := newUploadCache(defaultUploadCacheTTL, defaultUploadCacheMaxEntries)
+	cache.configure(config.GeminiWebUploadCache{TTLMinutes: 5})
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache.put("hash", "upload-id", now)
+
+	if id, ok := cache.get("hash", now.Add(5*time.Minute)); !ok || id != "upload-id" {
+		t.Fatalf("get at the TTL = %q, %v, want upload-id, true", id, ok)
+	}
+	if id, ok := cache.get("hash", now.Add(5*time.Minute+time.Second)); ok {
+		t.Fatalf("get after the TTL = %q, want a miss", id)
+	}
+	if _, ok := cache.entries["hash"]; ok {
+		t.Fatalf("expired entry is still cached")
+	}
+}
+
+func TestUploadCacheCapsEntries(t *testing.T) {
+	cache := newUploadCache(defaultUploadCacheTTL, defaultUploadCacheMaxEntries)
+	cache.configure(config.GeminiWebUploadCache{MaxEntries: 2})
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache.put("a", "id-a", now)
+	cache.put("b", "id-b", now.Add(time.Second))
+	cache.put("c", "id-c", now.Add(2*time.Second))
+
+	if len(cache.entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(cache.entries))
+	}
+	if _, ok := cache.get("a", now.Add(3*time.Second)); ok {
+		t.Fatalf("oldest entry a was kept")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := cache.get(key, now.Add(3*time.Second)); !ok {
+			t.Fatalf("entry %s was dropped", key)
+		}
+	}
+}
+
+func TestUploadCacheDisabled(t *testing.T) {
+	cache := newUploadCache(defaultUploadCacheTTL, defaultUploadCacheMaxEntries)
+	now := time.Now()
+	cache.put("hash", "upload-id", now)
+	cache.configure(config.GeminiWebUploadCache{TTLMinutes: -1})
+	if _, ok := cache.get("hash", now); ok {
+		t.Fatalf("disabling the cache kept its entries")
+	}
+	cache.put("hash", "upload-id", now)
+	if _, ok := cache.get("hash", now); ok {
+		t.Fatalf("disabled cache stored an entry")
+	}
+}
@@ -0,0 +1,378 @@
+// Package proxypool spreads accounts over a pool of egress proxies. Proxies are health-checked
+// in the background and by the outcome of the requests sent through them; an account whose
+// proxy becomes unhealthy is moved to a healthy one on its next request.
+package proxypool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHealthCheckURL      = "https://www.gstatic.com/generate_204"
+	defaultHealthCheckInterval = time.Minute
+	defaultHealthCheckTimeout  = 10 * time.Second
+	defaultFailureThreshold    = 2
+)
+
+// proxyState is the health of one pool proxy.
+type proxyState struct {
+	url       string
+	redacted  string
+	healthy   bool
+	failures  int
+	lastCheck time.Time
+	lastError string
+	latency   time.Duration
+	requests  int64
+}
+
+// ProxyStatus describes a pool proxy and the accounts assigned to it. Credentials in the
+// proxy URL are redacted.
+type ProxyStatus struct {
+	Proxy               string     `json:"proxy"`
+	Healthy             bool       `json:"healthy"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastCheck           *time.Time `json:"last_check,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LatencyMs           int64      `json:"latency_ms,omitempty"`
+	Requests            int64      `json:"requests"`
+	Accounts            []string   `json:"accounts"`
+}
+
+// Pool assigns accounts to proxies and tracks the proxies' health.
+type Pool struct {
+	mu       sync.Mutex
+	cfg      config.ProxyPoolConfig
+	proxies  []*proxyState
+	assigned map[string]*proxyState
+	started  bool
+	changed  chan struct{}
+}
+
+var defaultPool = &Pool{
+	assigned: make(map[string]*proxyState),
+	changed:  make(chan struct{}, 1),
+}
+
+// Default returns the shared proxy pool.
+func Default() *Pool { return defaultPool }
+
+// Configure applies cfg. Proxies that stay in the pool keep their health and accounts; the
+// health check loop starts on the first enabled configuration and re-checks every proxy
+// after each change.
+func (p *Pool) Configure(cfg config.ProxyPoolConfig) {
+	p.mu.Lock()
+	previous := make(map[string]*proxyState, len(p.proxies))
+	for _, state := range p.proxies {
+		previous[state.url] = state
+	}
+	var proxies []*proxyState
+	seen := make(map[string]bool)
+	for _, raw := range cfg.Proxies {
+		raw = strings.TrimSpace(raw)
+		if raw == "" || seen[raw] {
+			continue
+		}
+		parsed, err := url.Parse(raw)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "socks5" && parsed.Scheme != "http" && parsed.Scheme != "https") {
+			log.Warnf("proxy pool: ignoring invalid proxy %q", util.HideAPIKey(raw))
+			continue
+		}
+		seen[raw] = true
+		state := previous[raw]
+		if state == nil {
+			state = &proxyState{url: raw, redacted: parsed.Redacted(), healthy: true}
+		}
+		proxies = append(proxies, state)
+	}
+	p.cfg = cfg
+	p.proxies = proxies
+	for account, state := range p.assigned {
+		if !seen[state.url] {
+			delete(p.assigned, account)
+		}
+	}
+	start := cfg.Enabled && !p.started
+	if start {
+		p.started = true
+	}
+	p.mu.Unlock()
+
+	if start {
+		go p.run()
+		return
+	}
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Enabled reports whether the pool is enabled and holds at least one proxy.
+func (p *Pool) Enabled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cfg.Enabled && len(p.proxies) > 0
+}
+
+// ProxyFor returns the proxy URL assigned to account. Accounts keep their proxy while it is
+// healthy; otherwise they move to the healthy proxy with the fewest accounts. When no proxy
+// is healthy the current assignment is kept. ok is false when the pool is disabled.
+func (p *Pool) ProxyFor(account string) (proxyURL string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.cfg.Enabled || len(p.proxies) == 0 {
+		return "", false
+	}
+	current := p.assigned[account]
+	if current != nil && current.healthy {
+		return current.url, true
+	}
+	load := make(map[*proxyState]int, len(p.proxies))
+	for _, state := range p.assigned {
+		load[state]++
+	}
+	var next *proxyState
+	for _, state := range p.proxies {
+		if !state.healthy {
+			continue
+		}
+		if next == nil || load[state] < load[next] {
+			next = state
+		}
+	}
+	if next == nil {
+		if current != nil {
+			return current.url, true
+		}
+		next = p.proxies[0]
+		for _, state := range p.proxies[1:] {
+			if load[state] < load[next] {
+				next = state
+			}
+		}
+	}
+	if current != nil && current != next {
+		log.Infof("proxy pool: moved account %s from unhealthy proxy %s to %s", account, current.redacted, next.redacted)
+	}
+	if account != "" {
+		p.assigned[account] = next
+	}
+	return next.url, true
+}
+
+// Transport wraps base, a transport configured for proxyURL, so that the outcome of each
+// request counts towards the proxy's health and the proxy serving it is logged.
+func (p *Pool) Transport(base http.RoundTripper, proxyURL, account string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{pool: p, base: base, proxyURL: proxyURL, account: account}
+}
+
+type transport struct {
+	pool     *Pool
+	base     http.RoundTripper
+	proxyURL string
+	account  string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redacted := redact(t.proxyURL)
+	ctx := req.Context()
+	log.Debugf("Use proxy %s for account %s (request %s)", redacted, t.account, requestctx.CorrelationID(ctx))
+	if ginCtx := requestctx.Gin(ctx); ginCtx != nil {
+		ginCtx.Set(logging.UpstreamProxyKey, redacted)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil && ctx.Err() != nil {
+		// The caller gave up; that says nothing about the proxy.
+		return resp, err
+	}
+	t.pool.record(t.proxyURL, err, false)
+	return resp, err
+}
+
+// record counts the outcome of a request or health check against proxyURL. A success marks
+// the proxy healthy; FailureThreshold consecutive failures mark it unhealthy.
+func (p *Pool) record(proxyURL string, err error, check bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var state *proxyState
+	for _, candidate := range p.proxies {
+		if candidate.url == proxyURL {
+			state = candidate
+			break
+		}
+	}
+	if state == nil {
+		return
+	}
+	if !check {
+		state.requests++
+	}
+	if err == nil {
+		if !state.healthy {
+			log.Infof("proxy pool: proxy %s is healthy again", state.redacted)
+		}
+		state.healthy = true
+		state.failures = 0
+		state.lastError = ""
+		return
+	}
+	state.failures++
+	state.lastError = err.Error()
+	threshold := p.cfg.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	if state.healthy && state.failures >= threshold {
+		state.healthy = false
+		log.Warnf("proxy pool: proxy %s marked unhealthy after %d failures: %v", state.redacted, state.failures, err)
+	}
+}
+
+func (p *Pool) run() {
+	for {
+		p.checkAll()
+		timer := time.NewTimer(p.interval())
+		select {
+		case <-p.changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p *Pool) interval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cfg.HealthCheckIntervalSeconds > 0 {
+		return time.Duration(p.cfg.HealthCheckIntervalSeconds) * time.Second
+	}
+	return defaultHealthCheckInterval
+}
+
+// checkAll health-checks every proxy concurrently.
+func (p *Pool) checkAll() {
+	p.mu.Lock()
+	cfg := p.cfg
+	proxies := make([]string, 0, len(p.proxies))
+	for _, state := range p.proxies {
+		proxies = append(proxies, state.url)
+	}
+	p.mu.Unlock()
+	if !cfg.Enabled {
+		return
+	}
+	target := strings.TrimSpace(cfg.HealthCheckURL)
+	if target == "" {
+		target = defaultHealthCheckURL
+	}
+	timeout := defaultHealthCheckTimeout
+	if cfg.HealthCheckTimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.HealthCheckTimeoutSeconds) * time.Second
+	}
+
+	var wg sync.WaitGroup
+	for _, proxyURL := range proxies {
+		wg.Add(1)
+		go func(proxyURL string) {
+			defer wg.Done()
+			start := time.Now()
+			err := check(proxyURL, target, timeout)
+			p.mu.Lock()
+			for _, state := range p.proxies {
+				if state.url == proxyURL {
+					state.lastCheck = time.Now()
+					state.latency = time.Since(start)
+				}
+			}
+			p.mu.Unlock()
+			if err != nil {
+				log.Debugf("proxy pool: health check via %s failed: %v", redact(proxyURL), err)
+			}
+			p.record(proxyURL, err, true)
+		}(proxyURL)
+	}
+	wg.Wait()
+}
+
+// check fetches target through proxyURL; any response below 400 passes.
+func check(proxyURL, target string, timeout time.Duration) error {
+	client := util.SetProxy(&sdkconfig.SDKConfig{ProxyURL: proxyURL}, &http.Client{Timeout: timeout})
+	if client.Transport == nil {
+		return errors.New("unsupported proxy")
+	}
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		defer transport.CloseIdleConnections()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// Snapshot returns the status of every pool proxy in configuration order.
+func (p *Pool) Snapshot() []ProxyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	accounts := make(map[*proxyState][]string)
+	for account, state := range p.assigned {
+		accounts[state] = append(accounts[state], account)
+	}
+	out := make([]ProxyStatus, 0, len(p.proxies))
+	for _, state := range p.proxies {
+		status := ProxyStatus{
+			Proxy:               state.redacted,
+			Healthy:             state.healthy,
+			ConsecutiveFailures: state.failures,
+			LastError:           state.lastError,
+			LatencyMs:           state.latency.Milliseconds(),
+			Requests:            state.requests,
+			Accounts:            accounts[state],
+		}
+		if !state.lastCheck.IsZero() {
+			checked := state.lastCheck.UTC()
+			status.LastCheck = &checked
+		}
+		if status.Accounts == nil {
+			status.Accounts = []string{}
+		}
+		sort.Strings(status.Accounts)
+		out = append(out, status)
+	}
+	return out
+}
+
+func redact(proxyURL string) string {
+	if parsed, err := url.Parse(proxyURL); err == nil {
+		return parsed.Redacted()
+	}
+	return proxyURL
+}
@@ -0,0 +1,277 @@
+// Package ratelimit implements the token buckets behind the client-facing rate limits:
+// one global bucket, one per client API key and one per client IP.
+package ratelimit
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// Bucket scopes.
+const (
+	ScopeGlobal = "global"
+	ScopeKey    = "key"
+	ScopeIP     = "ip"
+)
+
+// sweepInterval bounds how often idle per-key and per-IP buckets are dropped.
+const sweepInterval = time.Minute
+
+type rule struct {
+	perSecond float64
+	burst     float64
+}
+
+func ruleFrom(r config.RateLimitRule) (rule, bool) {
+	if r.RequestsPerMinute <= 0 {
+		return rule{}, false
+	}
+	burst := float64(r.Burst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(r.RequestsPerMinute))
+	}
+	return rule{perSecond: r.RequestsPerMinute / 60, burst: burst}, true
+}
+
+type bucket struct {
+	rule   rule
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.rule.burst, b.tokens+elapsed*b.rule.perSecond)
+	}
+	b.last = now
+}
+
+// untilTokens returns how long until the bucket holds n tokens.
+func (b *bucket) untilTokens(n float64) time.Duration {
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rule.perSecond * float64(time.Second))
+}
+
+// State describes one bucket after a decision.
+type State struct {
+	Scope string `json:"scope"`
+	// ID is the masked API key or the client IP; empty for the global bucket.
+	ID                string    `json:"id,omitempty"`
+	Limit             int       `json:"limit"`
+	Remaining         int       `json:"remaining"`
+	RequestsPerMinute float64   `json:"requests_per_minute"`
+	Reset             time.Time `json:"reset"`
+}
+
+// Decision is the outcome of Allow.
+type Decision struct {
+	Allowed bool
+	// Tightest is the bucket with the fewest remaining requests, reported in headers.
+	Tightest State
+	// RetryAfter is set when the request was rejected.
+	RetryAfter time.Duration
+}
+
+// Limiter holds the configured rules and live buckets.
+type Limiter struct {
+	mu        sync.Mutex
+	enabled   bool
+	global    *bucket
+	perKey    rule
+	hasPerKey bool
+	perIP     rule
+	hasPerIP  bool
+	keyRules  map[string]rule
+	keys      map[string]*bucket
+	ips       map[string]*bucket
+	lastSweep time.Time
+}
+
+var defaultLimiter = &Limiter{keys: make(map[string]*bucket), ips: make(map[string]*bucket)}
+
+// GetLimiter returns the shared limiter.
+func GetLimiter() *Limiter { return defaultLimiter }
+
+// Configure applies cfg. Buckets whose rule changed start full under the new rule.
+func (l *Limiter) Configure(cfg config.RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enabled = cfg.Enabled
+	if r, ok := ruleFrom(cfg.Global); ok {
+		if l.global == nil || l.global.rule != r {
+			l.global = &bucket{rule: r, tokens: r.burst, last: time.Now()}
+		}
+	} else {
+		l.global = nil
+	}
+	l.perKey, l.hasPerKey = ruleFrom(cfg.PerKey)
+	l.perIP, l.hasPerIP = ruleFrom(cfg.PerIP)
+	l.keyRules = make(map[string]rule, len(cfg.Keys))
+	for _, entry := range cfg.Keys {
+		if r, ok := ruleFrom(entry.RateLimitRule); ok && entry.APIKey != "" {
+			l.keyRules[entry.APIKey] = r
+		}
+	}
+	for key, b := range l.keys {
+		if r, ok := l.keyRuleLocked(key); !ok || r != b.rule {
+			delete(l.keys, key)
+		}
+	}
+	for ip, b := range l.ips {
+		if !l.hasPerIP || b.rule != l.perIP {
+			delete(l.ips, ip)
+		}
+	}
+}
+
+// Enabled reports whether requests are rate limited.
+func (l *Limiter) Enabled() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enabled
+}
+
+func (l *Limiter) keyRuleLocked(apiKey string) (rule, bool) {
+	if r, ok := l.keyRules[apiKey]; ok {
+		return r, true
+	}
+	return l.perKey, l.hasPerKey
+}
+
+// Allow takes one token from every bucket that applies to a request from apiKey and ip.
+// Nothing is taken unless every bucket has a token.
+func (l *Limiter) Allow(apiKey, ip string, now time.Time) Decision {
+	return l.allow(apiKey, ip, true, now)
+}
+
+// AllowIP takes one token from the per-IP bucket of ip only. It lets requests be throttled
+// by address before they are authenticated; Allow then covers the global and per-key
+// buckets once the key is known.
+func (l *Limiter) AllowIP(ip string, now time.Time) Decision {
+	return l.allow("", ip, false, now)
+}
+
+func (l *Limiter) allow(apiKey, ip string, global bool, now time.Time) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabled {
+		return Decision{Allowed: true}
+	}
+	l.sweepLocked(now)
+
+	type scoped struct {
+		scope, id string
+		b         *bucket
+	}
+	var buckets []scoped
+	if global && l.global != nil {
+		buckets = append(buckets, scoped{scope: ScopeGlobal, b: l.global})
+	}
+	if apiKey != "" {
+		if r, ok := l.keyRuleLocked(apiKey); ok {
+			buckets = append(buckets, scoped{scope: ScopeKey, id: apiKey, b: fetchBucket(l.keys, apiKey, r, now)})
+		}
+	}
+	if ip != "" && l.hasPerIP {
+		buckets = append(buckets, scoped{scope: ScopeIP, id: ip, b: fetchBucket(l.ips, ip, l.perIP, now)})
+	}
+	if len(buckets) == 0 {
+		return Decision{Allowed: true}
+	}
+
+	decision := Decision{Allowed: true}
+	for _, sb := range buckets {
+		sb.b.refill(now)
+		if wait := sb.b.untilTokens(1); wait > 0 {
+			decision.Allowed = false
+			decision.RetryAfter = max(decision.RetryAfter, wait)
+		}
+	}
+	tightest := -1
+	for i, sb := range buckets {
+		if decision.Allowed {
+			sb.b.tokens--
+		}
+		if tightest < 0 || sb.b.tokens < buckets[tightest].b.tokens {
+			tightest = i
+		}
+	}
+	t := buckets[tightest]
+	decision.Tightest = stateOf(t.scope, t.id, t.b, now)
+	return decision
+}
+
+func fetchBucket(m map[string]*bucket, id string, r rule, now time.Time) *bucket {
+	b, ok := m[id]
+	if !ok {
+		b = &bucket{rule: r, tokens: r.burst, last: now}
+		m[id] = b
+	}
+	return b
+}
+
+// sweepLocked drops per-key and per-IP buckets that have refilled completely, since a new
+// full bucket is equivalent.
+func (l *Limiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for _, m := range []map[string]*bucket{l.keys, l.ips} {
+		for id, b := range m {
+			b.refill(now)
+			if b.tokens >= b.rule.burst {
+				delete(m, id)
+			}
+		}
+	}
+}
+
+func stateOf(scope, id string, b *bucket, now time.Time) State {
+	if scope == ScopeKey {
+		id = util.HideAPIKey(id)
+	}
+	return State{
+		Scope:             scope,
+		ID:                id,
+		Limit:             int(b.rule.burst),
+		Remaining:         max(int(math.Floor(b.tokens)), 0),
+		RequestsPerMinute: b.rule.perSecond * 60,
+		Reset:             now.Add(b.untilTokens(b.rule.burst)),
+	}
+}
+
+// Snapshot returns the state of the global bucket and of every live per-key and per-IP
+// bucket. Keys and IPs without a bucket have their full allowance.
+func (l *Limiter) Snapshot(now time.Time) []State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]State, 0, 1+len(l.keys)+len(l.ips))
+	if l.global != nil {
+		l.global.refill(now)
+		out = append(out, stateOf(ScopeGlobal, "", l.global, now))
+	}
+	for _, scoped := range []struct {
+		scope string
+		m     map[string]*bucket
+	}{{ScopeKey, l.keys}, {ScopeIP, l.ips}} {
+		for id, b := range scoped.m {
+			b.refill(now)
+			out = append(out, stateOf(scoped.scope, id, b, now))
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Scope != out[j].Scope {
+			return out[i].Scope < out[j].Scope
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
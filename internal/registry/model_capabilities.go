@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+)
+
+// ContextWindow returns the model's context window in tokens, whichever of the OpenAI-style
+// ContextLength or the Gemini-style InputTokenLimit is set.
+func (m *ModelInfo) ContextWindow() int {
+	if m == nil {
+		return 0
+	}
+	if m.ContextLength > 0 {
+		return m.ContextLength
+	}
+	return m.InputTokenLimit
+}
+
+// MaxOutputTokens returns the largest reply the model produces, whichever of the OpenAI-style
+// MaxCompletionTokens or the Gemini-style OutputTokenLimit is set.
+func (m *ModelInfo) MaxOutputTokens() int {
+	if m == nil {
+		return 0
+	}
+	if m.MaxCompletionTokens > 0 {
+		return m.MaxCompletionTokens
+	}
+	return m.OutputTokenLimit
+}
+
+// Modalities returns the input and output kinds the model handles. Explicit InputModalities and
+// OutputModalities win; otherwise they are inferred from the model family and, for embedding
+// models, from the supported generation methods.
+func (m *ModelInfo) Modalities() (input []string, output []string) {
+	if m == nil {
+		return []string{"text"}, []string{"text"}
+	}
+	input, output = m.InputModalities, m.OutputModalities
+	if len(input) == 0 {
+		switch strings.ToLower(m.Type) {
+		case "gemini":
+			input = []string{"text", "image", "audio", "video"}
+		case "claude", "openai":
+			input = []string{"text", "image"}
+		default:
+			input = []string{"text"}
+		}
+	}
+	if len(output) == 0 {
+		output = []string{"text"}
+		if isEmbeddingOnly(m.SupportedGenerationMethods) {
+			output = []string{"embedding"}
+			if len(m.InputModalities) == 0 {
+				input = []string{"text"}
+			}
+		}
+	}
+	return input, output
+}
+
+func isEmbeddingOnly(methods []string) bool {
+	embeds := false
+	for _, method := range methods {
+		switch method {
+		case "embedContent", "batchEmbedContents":
+			embeds = true
+		case "generateContent", "streamGenerateContent":
+			return false
+		}
+	}
+	return embeds
+}
+
+// providerNames lists the providers currently registered for the model, sorted by name.
+func (r *ModelRegistration) providerNames() []string {
+	if r == nil || len(r.Providers) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(r.Providers))
+	for name, count := range r.Providers {
+		if count > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
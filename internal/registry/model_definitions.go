@@ -9,52 +9,64 @@ import "time"
 func GetClaudeModels() []*ModelInfo {
 	return []*ModelInfo{
 		{
-			ID:          "claude-sonnet-4-5-20250929",
-			Object:      "model",
-			Created:     1759104000, // 2025-09-29
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 4.5 Sonnet",
+			ID:                  "claude-sonnet-4-5-20250929",
+			Object:              "model",
+			Created:             1759104000, // 2025-09-29
+			OwnedBy:             "anthropic",
+			Type:                "claude",
+			DisplayName:         "Claude 4.5 Sonnet",
+			ContextLength:       200000,
+			MaxCompletionTokens: 64000,
 		},
 		{
-			ID:          "claude-opus-4-1-20250805",
-			Object:      "model",
-			Created:     1722945600, // 2025-08-05
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 4.1 Opus",
+			ID:                  "claude-opus-4-1-20250805",
+			Object:              "model",
+			Created:             1722945600, // 2025-08-05
+			OwnedBy:             "anthropic",
+			Type:                "claude",
+			DisplayName:         "Claude 4.1 Opus",
+			ContextLength:       200000,
+			MaxCompletionTokens: 32000,
 		},
 		{
-			ID:          "claude-opus-4-20250514",
-			Object:      "model",
-			Created:     1715644800, // 2025-05-14
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 4 Opus",
+			ID:                  "claude-opus-4-20250514",
+			Object:              "model",
+			Created:             1715644800, // 2025-05-14
+			OwnedBy:             "anthropic",
+			Type:                "claude",
+			DisplayName:         "Claude 4 Opus",
+			ContextLength:       200000,
+			MaxCompletionTokens: 32000,
 		},
 		{
-			ID:          "claude-sonnet-4-20250514",
-			Object:      "model",
-			Created:     1715644800, // 2025-05-14
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 4 Sonnet",
+			ID:                  "claude-sonnet-4-20250514",
+			Object:              "model",
+			Created:             1715644800, // 2025-05-14
+			OwnedBy:             "anthropic",
+			Type:                "claude",
+			DisplayName:         "Claude 4 Sonnet",
+			ContextLength:       200000,
+			MaxCompletionTokens: 64000,
 		},
 		{
-			ID:          "claude-3-7-sonnet-20250219",
-			Object:      "model",
-			Created:     1708300800, // 2025-02-19
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 3.7 Sonnet",
+			ID:                  "claude-3-7-sonnet-20250219",
+			Object:              "model",
+			Created:             1708300800, // 2025-02-19
+			OwnedBy:             "anthropic",
+			Type:                "claude",
+			DisplayName:         "Claude 3.7 Sonnet",
+			ContextLength:       200000,
+			MaxCompletionTokens: 64000,
 		},
 		{
-			ID:          "claude-3-5-haiku-20241022",
-			Object:      "model",
-			Created:     1729555200, // 2024-10-22
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 3.5 Haiku",
+			ID:                  "claude-3-5-haiku-20241022",
+			Object:              "model",
+			Created:             1729555200, // 2024-10-22
+			OwnedBy:             "anthropic",
+			Type:                "claude",
+			DisplayName:         "Claude 3.5 Haiku",
+			ContextLength:       200000,
+			MaxCompletionTokens: 8192,
 		},
 	}
 }
@@ -107,6 +119,78 @@ func GetGeminiModels() []*ModelInfo {
 	}
 }
 
+// GetGeminiEmbeddingModels returns the embedding models served by the Gemini API.
+func GetGeminiEmbeddingModels() []*ModelInfo {
+	return []*ModelInfo{
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Text embedding model, served through /v1/embeddings and embedContent.",
+			InputTokenLimit:            2048,
+			OutputTokenLimit:           1,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+		},
+		{
+			ID:                         "text-embedding-004",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/text-embedding-004",
+			Version:                    "004",
+			DisplayName:                "Text Embedding 004",
+			Description:                "Text embedding model, served through /v1/embeddings and embedContent.",
+			InputTokenLimit:            2048,
+			OutputTokenLimit:           1,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+		},
+	}
+}
+
+// GetGeminiTTSModels returns the text-to-speech models served by the Gemini API.
+func GetGeminiTTSModels() []*ModelInfo {
+	return []*ModelInfo{
+		{
+			ID:                         "gemini-2.5-flash-preview-tts",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-2.5-flash-preview-tts",
+			Version:                    "2.5",
+			DisplayName:                "Gemini 2.5 Flash Preview TTS",
+			Description:                "Text-to-speech model, served through /v1/audio/speech.",
+			InputTokenLimit:            8192,
+			OutputTokenLimit:           16384,
+			SupportedGenerationMethods: []string{"generateContent", "countTokens"},
+			InputModalities:            []string{"text"},
+			OutputModalities:           []string{"audio"},
+		},
+		{
+			ID:                         "gemini-2.5-pro-preview-tts",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-2.5-pro-preview-tts",
+			Version:                    "2.5",
+			DisplayName:                "Gemini 2.5 Pro Preview TTS",
+			Description:                "Text-to-speech model, served through /v1/audio/speech.",
+			InputTokenLimit:            8192,
+			OutputTokenLimit:           16384,
+			SupportedGenerationMethods: []string{"generateContent", "countTokens"},
+			InputModalities:            []string{"text"},
+			OutputModalities:           []string{"audio"},
+		},
+	}
+}
+
 // GetGeminiCLIModels returns the standard Gemini model definitions
 func GetGeminiCLIModels() []*ModelInfo {
 	return []*ModelInfo{
@@ -45,6 +45,12 @@ type ModelInfo struct {
 	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
 	// SupportedParameters lists supported parameters
 	SupportedParameters []string `json:"supported_parameters,omitempty"`
+	// AliasFor names the model an alias is served by (e.g. "gemini-2.5-pro" for "gemini-2.5-pro-web")
+	AliasFor string `json:"alias_for,omitempty"`
+	// InputModalities lists accepted input kinds ("text", "image", "audio", "video"); inferred when empty
+	InputModalities []string `json:"input_modalities,omitempty"`
+	// OutputModalities lists produced output kinds ("text", "image", "audio", "embedding"); inferred when empty
+	OutputModalities []string `json:"output_modalities,omitempty"`
 }
 
 // ModelRegistration tracks a model's availability
@@ -71,6 +77,11 @@ type ModelRegistry struct {
 	clientModels map[string][]string
 	// clientProviders maps client ID to its provider identifier
 	clientProviders map[string]string
+	// aliases maps configured model aliases to the model serving them
+	aliases map[string]string
+	// builtinAliases holds the fixed aliases of providers (such as the "-web" names of
+	// Gemini Web), keyed by lowercased alias; configured aliases take precedence
+	builtinAliases map[string]string
 	// mutex ensures thread-safe access to the registry
 	mutex *sync.RWMutex
 }
@@ -359,6 +370,12 @@ func cloneModelInfo(model *ModelInfo) *ModelInfo {
 	if len(model.SupportedParameters) > 0 {
 		copy.SupportedParameters = append([]string(nil), model.SupportedParameters...)
 	}
+	if len(model.InputModalities) > 0 {
+		copy.InputModalities = append([]string(nil), model.InputModalities...)
+	}
+	if len(model.OutputModalities) > 0 {
+		copy.OutputModalities = append([]string(nil), model.OutputModalities...)
+	}
 	return &copy
 }
 
@@ -511,46 +528,169 @@ func (r *ModelRegistry) ResumeClientModel(clientID, modelID string) {
 //   - handlerType: The handler type to filter models for (e.g., "openai", "claude", "gemini")
 //
 // Returns:
-//   - []map[string]any: List of available models in the requested format
+//   - []map[string]any: List of available models in the requested format, sorted by ID
 func (r *ModelRegistry) GetAvailableModels(handlerType string) []map[string]any {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	models := make([]map[string]any, 0)
-	quotaExpiredDuration := 5 * time.Minute
+	now := time.Now()
+	registrations := make(map[string]*ModelRegistration, len(r.models)+len(r.aliases))
+	for id, registration := range r.models {
+		// Only include models that have available clients
+		if availableClientsLocked(registration, now) > 0 {
+			registrations[id] = registration
+		}
+	}
+	for alias, target := range r.aliases {
+		if _, exists := r.models[alias]; exists {
+			continue
+		}
+		if registration, ok := registrations[target]; ok {
+			registrations[alias] = aliasRegistration(alias, target, registration)
+		}
+	}
+	ids := make([]string, 0, len(registrations))
+	for id := range registrations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
 
-	for _, registration := range r.models {
-		// Check if model has any non-quota-exceeded clients
-		availableClients := registration.Count
-		now := time.Now()
+	models := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		if model := r.convertModelToMap(registrations[id], handlerType); model != nil {
+			models = append(models, model)
+		}
+	}
+	return models
+}
 
-		// Count clients that have exceeded quota but haven't recovered yet
-		expiredClients := 0
-		for _, quotaTime := range registration.QuotaExceededClients {
-			if quotaTime != nil && now.Sub(*quotaTime) < quotaExpiredDuration {
-				expiredClients++
-			}
+// SetModelAliases replaces the configured model aliases, which are listed alongside the
+// models they map to.
+func (r *ModelRegistry) SetModelAliases(aliases map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.aliases = make(map[string]string, len(aliases))
+	for alias, target := range aliases {
+		alias, target = strings.TrimSpace(alias), strings.TrimSpace(target)
+		if alias != "" && target != "" && alias != target {
+			r.aliases[alias] = target
 		}
+	}
+}
 
-		suspendedClients := 0
-		if registration.SuspendedClients != nil {
-			suspendedClients = len(registration.SuspendedClients)
+// AddBuiltinAliases records fixed aliases a provider resolves itself. They are consulted by
+// ResolveAlias after the configured aliases.
+func (r *ModelRegistry) AddBuiltinAliases(aliases map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.builtinAliases == nil {
+		r.builtinAliases = make(map[string]string, len(aliases))
+	}
+	for alias, target := range aliases {
+		alias, target = strings.ToLower(strings.TrimSpace(alias)), strings.TrimSpace(target)
+		if alias != "" && target != "" {
+			r.builtinAliases[alias] = target
 		}
-		effectiveClients := availableClients - expiredClients - suspendedClients
-		if effectiveClients < 0 {
-			effectiveClients = 0
+	}
+}
+
+// BuiltinAliases returns a copy of the aliases added by providers.
+func (r *ModelRegistry) BuiltinAliases() map[string]string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	out := make(map[string]string, len(r.builtinAliases))
+	for alias, target := range r.builtinAliases {
+		out[alias] = target
+	}
+	return out
+}
+
+// ResolveAlias returns the model an alias maps to, looking at the configured aliases first
+// (case-insensitively) and then at the built-in ones.
+func (r *ModelRegistry) ResolveAlias(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if target, ok := r.aliases[name]; ok {
+		return target, true
+	}
+	for alias, target := range r.aliases {
+		if strings.EqualFold(alias, name) {
+			return target, true
 		}
+	}
+	target, ok := r.builtinAliases[strings.ToLower(name)]
+	return target, ok
+}
 
-		// Only include models that have available clients
-		if effectiveClients > 0 {
-			model := r.convertModelToMap(registration.Info, handlerType)
-			if model != nil {
-				models = append(models, model)
+// aliasRegistration presents a registered model under an alias name.
+func aliasRegistration(alias, target string, registration *ModelRegistration) *ModelRegistration {
+	info := cloneModelInfo(registration.Info)
+	info.ID = alias
+	if info.Name != "" {
+		info.Name = alias
+		if strings.HasPrefix(registration.Info.Name, "models/") {
+			info.Name = "models/" + alias
+		}
+	}
+	info.AliasFor = target
+	cpy := *registration
+	cpy.Info = info
+	return &cpy
+}
+
+// GetAvailableModel returns a single model in the requested format, or nil when it is unknown
+// or has no available client. The ID may also be given as the Gemini-style name ("models/...").
+func (r *ModelRegistry) GetAvailableModel(modelID, handlerType string) map[string]any {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	registration, exists := r.models[modelID]
+	if !exists {
+		if target, ok := r.aliases[modelID]; ok {
+			if targetRegistration, found := r.models[target]; found {
+				registration, exists = aliasRegistration(modelID, target, targetRegistration), true
+			}
+		}
+	}
+	if !exists {
+		for _, candidate := range r.models {
+			if candidate.Info != nil && candidate.Info.Name != "" && strings.TrimPrefix(candidate.Info.Name, "models/") == strings.TrimPrefix(modelID, "models/") {
+				registration, exists = candidate, true
+				break
 			}
 		}
 	}
+	if !exists || availableClientsLocked(registration, time.Now()) <= 0 {
+		return nil
+	}
+	return r.convertModelToMap(registration, handlerType)
+}
 
-	return models
+// availableClientsLocked counts the clients of a registration that are neither over quota nor
+// suspended. The caller must hold the registry lock.
+func availableClientsLocked(registration *ModelRegistration, now time.Time) int {
+	if registration == nil {
+		return 0
+	}
+	quotaExpiredDuration := 5 * time.Minute
+
+	// Count clients that have exceeded quota but haven't recovered yet
+	expiredClients := 0
+	for _, quotaTime := range registration.QuotaExceededClients {
+		if quotaTime != nil && now.Sub(*quotaTime) < quotaExpiredDuration {
+			expiredClients++
+		}
+	}
+	suspendedClients := 0
+	if registration.SuspendedClients != nil {
+		suspendedClients = len(registration.SuspendedClients)
+	}
+	result := registration.Count - expiredClients - suspendedClients
+	if result < 0 {
+		return 0
+	}
+	return result
 }
 
 // GetModelCount returns the number of available clients for a specific model
@@ -564,25 +704,18 @@ func (r *ModelRegistry) GetModelCount(modelID string) int {
 	defer r.mutex.RUnlock()
 
 	if registration, exists := r.models[modelID]; exists {
-		now := time.Now()
-		quotaExpiredDuration := 5 * time.Minute
+		return availableClientsLocked(registration, time.Now())
+	}
+	return 0
+}
 
-		// Count clients that have exceeded quota but haven't recovered yet
-		expiredClients := 0
-		for _, quotaTime := range registration.QuotaExceededClients {
-			if quotaTime != nil && now.Sub(*quotaTime) < quotaExpiredDuration {
-				expiredClients++
-			}
-		}
-		suspendedClients := 0
-		if registration.SuspendedClients != nil {
-			suspendedClients = len(registration.SuspendedClients)
-		}
-		result := registration.Count - expiredClients - suspendedClients
-		if result < 0 {
-			return 0
-		}
-		return result
+// GetModelClientCount returns the number of registered clients for a model, including clients
+// that are currently suspended or over quota.
+func (r *ModelRegistry) GetModelClientCount(modelID string) int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if registration, exists := r.models[modelID]; exists {
+		return registration.Count
 	}
 	return 0
 }
@@ -644,11 +777,13 @@ func (r *ModelRegistry) GetModelProviders(modelID string) []string {
 	return result
 }
 
-// convertModelToMap converts ModelInfo to the appropriate format for different handler types
-func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string) map[string]any {
-	if model == nil {
+// convertModelToMap converts a registered model to the appropriate format for different handler types
+func (r *ModelRegistry) convertModelToMap(registration *ModelRegistration, handlerType string) map[string]any {
+	if registration == nil || registration.Info == nil {
 		return nil
 	}
+	model := registration.Info
+	inputModalities, outputModalities := model.Modalities()
 
 	switch handlerType {
 	case "openai":
@@ -672,15 +807,23 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if model.Description != "" {
 			result["description"] = model.Description
 		}
-		if model.ContextLength > 0 {
-			result["context_length"] = model.ContextLength
+		if contextWindow := model.ContextWindow(); contextWindow > 0 {
+			result["context_length"] = contextWindow
 		}
-		if model.MaxCompletionTokens > 0 {
-			result["max_completion_tokens"] = model.MaxCompletionTokens
+		if maxOutput := model.MaxOutputTokens(); maxOutput > 0 {
+			result["max_completion_tokens"] = maxOutput
 		}
 		if len(model.SupportedParameters) > 0 {
 			result["supported_parameters"] = model.SupportedParameters
 		}
+		if model.AliasFor != "" {
+			result["alias_for"] = model.AliasFor
+		}
+		result["input_modalities"] = inputModalities
+		result["output_modalities"] = outputModalities
+		if providers := registration.providerNames(); len(providers) > 0 {
+			result["providers"] = providers
+		}
 		return result
 
 	case "claude":
@@ -716,15 +859,22 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if model.Description != "" {
 			result["description"] = model.Description
 		}
-		if model.InputTokenLimit > 0 {
-			result["inputTokenLimit"] = model.InputTokenLimit
+		if contextWindow := model.ContextWindow(); contextWindow > 0 {
+			result["inputTokenLimit"] = contextWindow
 		}
-		if model.OutputTokenLimit > 0 {
-			result["outputTokenLimit"] = model.OutputTokenLimit
+		if maxOutput := model.MaxOutputTokens(); maxOutput > 0 {
+			result["outputTokenLimit"] = maxOutput
 		}
 		if len(model.SupportedGenerationMethods) > 0 {
 			result["supportedGenerationMethods"] = model.SupportedGenerationMethods
+		} else {
+			result["supportedGenerationMethods"] = []string{"generateContent", "countTokens"}
+		}
+		if model.AliasFor != "" {
+			result["baseModelId"] = model.AliasFor
 		}
+		result["inputModalities"] = inputModalities
+		result["outputModalities"] = outputModalities
 		return result
 
 	default:
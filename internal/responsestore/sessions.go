@@ -0,0 +1,113 @@
+package responsestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Session is the server-side history of a chat session.
+type Session struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Messages is the chat completions message array sent before the next turn.
+	Messages json.RawMessage `json:"messages"`
+}
+
+// NewSessionID returns a fresh session ID from the ID generator of ctx.
+func NewSessionID(ctx context.Context) string {
+	return idgen.NewID(ctx, "sess_")
+}
+
+// PutSession stores sess, replacing any session with the same ID.
+func (s *Store) PutSession(ctx context.Context, sess Session) error {
+	if sess.ID == "" {
+		return errors.New("response store: empty session id")
+	}
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketSessions)).Put([]byte(sess.ID), data)
+	})
+	if err == nil {
+		s.maybeSweep(idgen.Now(ctx))
+	}
+	return err
+}
+
+// GetSession returns the session with id when it belongs to owner and has not expired by the
+// clock of ctx.
+func (s *Store) GetSession(ctx context.Context, id, owner string) (Session, error) {
+	var sess Session
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket([]byte(bucketSessions)).Get([]byte(id))
+		if raw == nil {
+			return nil
+		}
+		if errUnmarshal := json.Unmarshal(raw, &sess); errUnmarshal != nil {
+			return errUnmarshal
+		}
+		found = true
+		return nil
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	if !found || sess.Owner != owner || (!sess.ExpiresAt.IsZero() && idgen.Now(ctx).After(sess.ExpiresAt)) {
+		return Session{}, ErrNotFound
+	}
+	return sess, nil
+}
+
+// DeleteSession removes the session with id when it belongs to owner.
+func (s *Store) DeleteSession(ctx context.Context, id, owner string) error {
+	if _, err := s.GetSession(ctx, id, owner); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketSessions)).Delete([]byte(id))
+	})
+}
+
+type sessionLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// LockSession serializes the turns of session id, so that each turn sees the history saved
+// by the previous one. The returned function releases the lock.
+func (s *Store) LockSession(id string) (unlock func()) {
+	s.mu.Lock()
+	if s.locks == nil {
+		s.locks = make(map[string]*sessionLock)
+	}
+	lock := s.locks[id]
+	if lock == nil {
+		lock = &sessionLock{}
+		s.locks[id] = lock
+	}
+	lock.refs++
+	s.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		s.mu.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(s.locks, id)
+		}
+		s.mu.Unlock()
+	}
+}
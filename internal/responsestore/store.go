@@ -0,0 +1,189 @@
+// Package responsestore persists Responses API results requested with store=true so they
+// can be retrieved later by ID, and the message history of chat sessions. Records live in a
+// BoltDB file and expire after a TTL.
+package responsestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	bucketResponses = "responses"
+	bucketSessions  = "sessions"
+	defaultFile     = "responses.bolt"
+	sweepInterval   = time.Hour
+)
+
+// ErrNotFound is returned for unknown, expired or foreign responses.
+var ErrNotFound = errors.New("response not found")
+
+// Record is one stored response.
+type Record struct {
+	ID        string          `json:"id"`
+	Owner     string          `json:"owner"`
+	Model     string          `json:"model,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Response  json.RawMessage `json:"response"`
+}
+
+// Store is a BoltDB-backed response store.
+type Store struct {
+	db *bolt.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+	locks     map[string]*sessionLock
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultStore *Store
+	defaultErr   error
+)
+
+// Default opens the process-wide store at <working dir>/data/responses.bolt on first use.
+func Default() (*Store, error) {
+	defaultOnce.Do(func() {
+		wd, err := os.Getwd()
+		if err != nil || wd == "" {
+			wd = "."
+		}
+		defaultStore, defaultErr = Open(filepath.Join(wd, "data", defaultFile))
+	})
+	return defaultStore, defaultErr
+}
+
+// Open opens (creating if needed) the store at path.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{bucketResponses, bucketSessions} {
+			if _, errCreate := tx.CreateBucketIfNotExists([]byte(name)); errCreate != nil {
+				return errCreate
+			}
+		}
+		return nil
+	}); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// OwnerOf derives the owner tag of a client API key; the key itself is never stored.
+// Requests without a key share the empty owner.
+func OwnerOf(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewID returns a fresh response ID in the OpenAI "resp_" format from the ID generator of ctx.
+func NewID(ctx context.Context) string {
+	return idgen.NewID(ctx, "resp_")
+}
+
+// Put stores rec, replacing any record with the same ID. Expired records are swept using the
+// clock of ctx.
+func (s *Store) Put(ctx context.Context, rec Record) error {
+	if rec.ID == "" {
+		return errors.New("response store: empty id")
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketResponses)).Put([]byte(rec.ID), data)
+	})
+	if err == nil {
+		s.maybeSweep(idgen.Now(ctx))
+	}
+	return err
+}
+
+// Get returns the record with id when it belongs to owner and has not expired by the clock of ctx.
+func (s *Store) Get(ctx context.Context, id, owner string) (Record, error) {
+	var rec Record
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket([]byte(bucketResponses)).Get([]byte(id))
+		if raw == nil {
+			return nil
+		}
+		if errUnmarshal := json.Unmarshal(raw, &rec); errUnmarshal != nil {
+			return errUnmarshal
+		}
+		found = true
+		return nil
+	})
+	if err != nil {
+		return Record{}, err
+	}
+	if !found || rec.Owner != owner || (!rec.ExpiresAt.IsZero() && idgen.Now(ctx).After(rec.ExpiresAt)) {
+		return Record{}, ErrNotFound
+	}
+	return rec, nil
+}
+
+// Delete removes the record with id when it belongs to owner.
+func (s *Store) Delete(ctx context.Context, id, owner string) error {
+	if _, err := s.Get(ctx, id, owner); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketResponses)).Delete([]byte(id))
+	})
+}
+
+// maybeSweep removes expired responses and sessions at most once per sweepInterval.
+func (s *Store) maybeSweep(now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+	_ = s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{bucketResponses, bucketSessions} {
+			b := tx.Bucket([]byte(name))
+			var expired [][]byte
+			_ = b.ForEach(func(k, v []byte) error {
+				var rec struct {
+					ExpiresAt time.Time `json:"expires_at"`
+				}
+				if json.Unmarshal(v, &rec) != nil || (!rec.ExpiresAt.IsZero() && now.After(rec.ExpiresAt)) {
+					expired = append(expired, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			for _, k := range expired {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package responsestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+)
+
+func TestStoreUsesInjectedClockAndIDs(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), defaultFile))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.db.Close() })
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	ctx := idgen.WithClock(context.Background(), idgen.ClockFunc(func() time.Time { return now }))
+	ctx = idgen.WithIDGenerator(ctx, idgen.IDGeneratorFunc(func(prefix string) string { return prefix + "fixed" }))
+
+	id := NewID(ctx)
+	if id != "resp_fixed" {
+		t.Fatalf("NewID = %q, want resp_fixed", id)
+	}
+	rec := Record{
+		ID:        id,
+		Owner:     "owner",
+		CreatedAt: idgen.Now(ctx),
+		ExpiresAt: idgen.Now(ctx).Add(time.Hour),
+		Response:  json.RawMessage(`{"id":"resp_fixed"}`),
+	}
+	if err = store.Put(ctx, rec); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	got, err := store.Get(ctx, id, "owner")
+	if err != nil {
+		t.Fatalf("get before expiry: %v", err)
+	}
+	if !got.CreatedAt.Equal(now) {
+		t.Fatalf("CreatedAt = %v, want %v", got.CreatedAt, now)
+	}
+	if _, err = store.Get(ctx, id, "someone-else"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get by foreign owner: err = %v, want ErrNotFound", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err = store.Get(ctx, id, "owner"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after expiry: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSessionsAreScopedToOwnerAndExpire(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), defaultFile))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.db.Close() })
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	ctx := idgen.WithClock(context.Background(), idgen.ClockFunc(func() time.Time { return now }))
+	ctx = idgen.WithIDGenerator(ctx, idgen.IDGeneratorFunc(func(prefix string) string { return prefix + "fixed" }))
+
+	sess := Session{
+		ID:        NewSessionID(ctx),
+		Owner:     "owner",
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+		Messages:  json.RawMessage(`[{"role":"user","content":"hi"}]`),
+	}
+	if sess.ID != "sess_fixed" {
+		t.Fatalf("NewSessionID = %q, want sess_fixed", sess.ID)
+	}
+	if err = store.PutSession(ctx, sess); err != nil {
+		t.Fatalf("put session: %v", err)
+	}
+	got, err := store.GetSession(ctx, sess.ID, "owner")
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if string(got.Messages) != string(sess.Messages) {
+		t.Fatalf("Messages = %s, want %s", got.Messages, sess.Messages)
+	}
+	if _, err = store.Get(ctx, sess.ID, "owner"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("session visible as a response: err = %v, want ErrNotFound", err)
+	}
+	if err = store.DeleteSession(ctx, sess.ID, "someone-else"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete by foreign owner: err = %v, want ErrNotFound", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err = store.GetSession(ctx, sess.ID, "owner"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after expiry: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// bandwidthCeilingExecutor refuses requests for accounts that have used up their bandwidth
+// ceiling, before any upstream traffic is generated.
+type bandwidthCeilingExecutor struct {
+	cliproxyauth.ProviderExecutor
+}
+
+// WithBandwidthCeilings wraps inner so that bandwidth ceilings apply to its accounts. An
+// account over its ceiling fails with 429, which moves the request to the next account.
+func WithBandwidthCeilings(inner cliproxyauth.ProviderExecutor) cliproxyauth.ProviderExecutor {
+	if inner == nil {
+		return nil
+	}
+	return bandwidthCeilingExecutor{ProviderExecutor: inner}
+}
+
+func (e bandwidthCeilingExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if err := checkBandwidthCeiling(auth); err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return e.ProviderExecutor.Execute(ctx, auth, req, opts)
+}
+
+func (e bandwidthCeilingExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	if err := checkBandwidthCeiling(auth); err != nil {
+		return nil, err
+	}
+	return e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+}
+
+func (e bandwidthCeilingExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if err := checkBandwidthCeiling(auth); err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return embedWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+func (e bandwidthCeilingExecutor) Speech(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	if err := checkBandwidthCeiling(auth); err != nil {
+		return nil, err
+	}
+	return speechWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+func (e bandwidthCeilingExecutor) Transcribe(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if err := checkBandwidthCeiling(auth); err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return transcribeWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+// Unwrap returns the wrapped executor.
+func (e bandwidthCeilingExecutor) Unwrap() cliproxyauth.ProviderExecutor { return e.ProviderExecutor }
+
+func checkBandwidthCeiling(auth *cliproxyauth.Auth) error {
+	if auth == nil {
+		return nil
+	}
+	status, ok := internalusage.GetBandwidthTracker().Check(auth.ID, time.Now())
+	if ok {
+		return nil
+	}
+	reset := status.DailyReset
+	if status.Exceeded == "monthly" {
+		reset = status.MonthlyReset
+	}
+	return statusErr{code: http.StatusTooManyRequests, msg: fmt.Sprintf("%s bandwidth ceiling reached for account %s until %s", status.Exceeded, auth.ID, reset.Format(time.RFC3339))}
+}
@@ -0,0 +1,277 @@
+package executor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Bedrock requests are translated to OpenAI chat completions first, like every other
+// OpenAI-shaped upstream, and converted to the Converse API shape here. Replies are converted
+// back to OpenAI chat completions, which the translators then map to the client's format.
+
+// openAIToConverse converts an OpenAI chat completion request to a Converse request body.
+func openAIToConverse(payload []byte) []byte {
+	root := gjson.ParseBytes(payload)
+	out := []byte(`{"messages":[]}`)
+
+	system := make([]string, 0)
+	type converseMessage struct {
+		role    string
+		content []string
+	}
+	messages := make([]converseMessage, 0)
+	appendContent := func(role string, blocks ...string) {
+		if len(blocks) == 0 {
+			return
+		}
+		// Converse requires alternating roles, so consecutive messages of one role are merged.
+		if n := len(messages); n > 0 && messages[n-1].role == role {
+			messages[n-1].content = append(messages[n-1].content, blocks...)
+			return
+		}
+		messages = append(messages, converseMessage{role: role, content: blocks})
+	}
+
+	root.Get("messages").ForEach(func(_, msg gjson.Result) bool {
+		role := msg.Get("role").String()
+		switch role {
+		case "system", "developer":
+			if text := openAIContentText(msg.Get("content")); text != "" {
+				system = append(system, text)
+			}
+		case "user":
+			appendContent("user", converseContentBlocks(msg.Get("content"))...)
+		case "assistant":
+			blocks := converseContentBlocks(msg.Get("content"))
+			msg.Get("tool_calls").ForEach(func(_, call gjson.Result) bool {
+				block := `{"toolUse":{}}`
+				block, _ = sjson.Set(block, "toolUse.toolUseId", call.Get("id").String())
+				block, _ = sjson.Set(block, "toolUse.name", call.Get("function.name").String())
+				args := strings.TrimSpace(call.Get("function.arguments").String())
+				if args == "" || !gjson.Valid(args) || !gjson.Parse(args).IsObject() {
+					args = "{}"
+				}
+				block, _ = sjson.SetRaw(block, "toolUse.input", args)
+				blocks = append(blocks, block)
+				return true
+			})
+			appendContent("assistant", blocks...)
+		case "tool":
+			block := `{"toolResult":{"content":[]}}`
+			block, _ = sjson.Set(block, "toolResult.toolUseId", msg.Get("tool_call_id").String())
+			block, _ = sjson.Set(block, "toolResult.content.0.text", openAIContentText(msg.Get("content")))
+			appendContent("user", block)
+		}
+		return true
+	})
+
+	for _, text := range system {
+		out, _ = sjson.SetBytes(out, "system.-1", map[string]string{"text": text})
+	}
+	for _, msg := range messages {
+		m := []byte(`{"content":[]}`)
+		m, _ = sjson.SetBytes(m, "role", msg.role)
+		for _, block := range msg.content {
+			m, _ = sjson.SetRawBytes(m, "content.-1", []byte(block))
+		}
+		out, _ = sjson.SetRawBytes(out, "messages.-1", m)
+	}
+
+	if v := root.Get("max_completion_tokens"); v.Exists() {
+		out, _ = sjson.SetBytes(out, "inferenceConfig.maxTokens", v.Int())
+	} else if v = root.Get("max_tokens"); v.Exists() {
+		out, _ = sjson.SetBytes(out, "inferenceConfig.maxTokens", v.Int())
+	}
+	if v := root.Get("temperature"); v.Exists() {
+		out, _ = sjson.SetBytes(out, "inferenceConfig.temperature", v.Float())
+	}
+	if v := root.Get("top_p"); v.Exists() {
+		out, _ = sjson.SetBytes(out, "inferenceConfig.topP", v.Float())
+	}
+	if stop := root.Get("stop"); stop.Exists() {
+		if stop.IsArray() {
+			stop.ForEach(func(_, s gjson.Result) bool {
+				out, _ = sjson.SetBytes(out, "inferenceConfig.stopSequences.-1", s.String())
+				return true
+			})
+		} else if stop.String() != "" {
+			out, _ = sjson.SetBytes(out, "inferenceConfig.stopSequences.-1", stop.String())
+		}
+	}
+
+	tools := root.Get("tools")
+	if tools.IsArray() && len(tools.Array()) > 0 && root.Get("tool_choice").String() != "none" {
+		tools.ForEach(func(_, tool gjson.Result) bool {
+			if tool.Get("type").String() != "function" {
+				return true
+			}
+			spec := `{"toolSpec":{"inputSchema":{}}}`
+			spec, _ = sjson.Set(spec, "toolSpec.name", tool.Get("function.name").String())
+			if desc := tool.Get("function.description").String(); desc != "" {
+				spec, _ = sjson.Set(spec, "toolSpec.description", desc)
+			}
+			params := tool.Get("function.parameters").Raw
+			if params == "" {
+				params = `{"type":"object","properties":{}}`
+			}
+			spec, _ = sjson.SetRaw(spec, "toolSpec.inputSchema.json", params)
+			out, _ = sjson.SetRawBytes(out, "toolConfig.tools.-1", []byte(spec))
+			return true
+		})
+		choice := root.Get("tool_choice")
+		switch {
+		case choice.String() == "required":
+			out, _ = sjson.SetRawBytes(out, "toolConfig.toolChoice", []byte(`{"any":{}}`))
+		case choice.IsObject() && choice.Get("function.name").String() != "":
+			out, _ = sjson.SetBytes(out, "toolConfig.toolChoice.tool.name", choice.Get("function.name").String())
+		case gjson.GetBytes(out, "toolConfig.tools").Exists():
+			out, _ = sjson.SetRawBytes(out, "toolConfig.toolChoice", []byte(`{"auto":{}}`))
+		}
+	}
+	return out
+}
+
+// converseContentBlocks converts OpenAI message content to Converse content blocks. Images are
+// only supported as base64 data URLs, since Converse does not fetch remote images.
+func converseContentBlocks(content gjson.Result) []string {
+	blocks := make([]string, 0)
+	if content.Type == gjson.String {
+		if content.Str != "" {
+			block, _ := sjson.Set(`{}`, "text", content.Str)
+			blocks = append(blocks, block)
+		}
+		return blocks
+	}
+	content.ForEach(func(_, part gjson.Result) bool {
+		switch part.Get("type").String() {
+		case "text":
+			if text := part.Get("text").String(); text != "" {
+				block, _ := sjson.Set(`{}`, "text", text)
+				blocks = append(blocks, block)
+			}
+		case "image_url":
+			format, data, ok := parseImageDataURL(part.Get("image_url.url").String())
+			if !ok {
+				return true
+			}
+			block := `{"image":{}}`
+			block, _ = sjson.Set(block, "image.format", format)
+			block, _ = sjson.Set(block, "image.source.bytes", data)
+			blocks = append(blocks, block)
+		}
+		return true
+	})
+	return blocks
+}
+
+// parseImageDataURL splits a data:image/<format>;base64,<data> URL.
+func parseImageDataURL(u string) (format, data string, ok bool) {
+	if !strings.HasPrefix(u, "data:image/") {
+		return "", "", false
+	}
+	meta, payload, found := strings.Cut(strings.TrimPrefix(u, "data:image/"), ",")
+	if !found {
+		return "", "", false
+	}
+	format, encoding, _ := strings.Cut(meta, ";")
+	if encoding != "base64" {
+		return "", "", false
+	}
+	if _, err := base64.StdEncoding.DecodeString(payload); err != nil {
+		return "", "", false
+	}
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	return format, payload, true
+}
+
+// openAIContentText joins the text of OpenAI message content.
+func openAIContentText(content gjson.Result) string {
+	if content.Type == gjson.String {
+		return content.Str
+	}
+	parts := make([]string, 0)
+	content.ForEach(func(_, part gjson.Result) bool {
+		if part.Get("type").String() == "text" {
+			parts = append(parts, part.Get("text").String())
+		}
+		return true
+	})
+	return strings.Join(parts, "\n")
+}
+
+// converseToOpenAI converts a Converse response to an OpenAI chat completion.
+func converseToOpenAI(body []byte, model string, now time.Time) []byte {
+	root := gjson.ParseBytes(body)
+	out := []byte(`{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant"}}]}`)
+	out, _ = sjson.SetBytes(out, "id", fmt.Sprintf("chatcmpl-%d", now.UnixNano()))
+	out, _ = sjson.SetBytes(out, "created", now.Unix())
+	out, _ = sjson.SetBytes(out, "model", model)
+
+	var text, reasoning strings.Builder
+	toolCalls := 0
+	root.Get("output.message.content").ForEach(func(_, block gjson.Result) bool {
+		switch {
+		case block.Get("text").Exists():
+			text.WriteString(block.Get("text").String())
+		case block.Get("reasoningContent").Exists():
+			reasoning.WriteString(block.Get("reasoningContent.reasoningText.text").String())
+		case block.Get("toolUse").Exists():
+			call := []byte(`{"type":"function","function":{}}`)
+			call, _ = sjson.SetBytes(call, "id", block.Get("toolUse.toolUseId").String())
+			call, _ = sjson.SetBytes(call, "function.name", block.Get("toolUse.name").String())
+			args := block.Get("toolUse.input").Raw
+			if args == "" {
+				args = "{}"
+			}
+			call, _ = sjson.SetBytes(call, "function.arguments", args)
+			out, _ = sjson.SetRawBytes(out, "choices.0.message.tool_calls.-1", call)
+			toolCalls++
+		}
+		return true
+	})
+	if text.Len() > 0 || toolCalls == 0 {
+		out, _ = sjson.SetBytes(out, "choices.0.message.content", text.String())
+	} else {
+		out, _ = sjson.SetRawBytes(out, "choices.0.message.content", []byte("null"))
+	}
+	if reasoning.Len() > 0 {
+		out, _ = sjson.SetBytes(out, "choices.0.message.reasoning_content", reasoning.String())
+	}
+	out, _ = sjson.SetBytes(out, "choices.0.finish_reason", converseFinishReason(root.Get("stopReason").String()))
+	if usage := root.Get("usage"); usage.Exists() {
+		out = setConverseUsage(out, "usage", usage)
+	}
+	return out
+}
+
+// setConverseUsage writes Converse token usage at path as OpenAI usage.
+func setConverseUsage(out []byte, path string, usage gjson.Result) []byte {
+	out, _ = sjson.SetBytes(out, path+".prompt_tokens", usage.Get("inputTokens").Int())
+	out, _ = sjson.SetBytes(out, path+".completion_tokens", usage.Get("outputTokens").Int())
+	out, _ = sjson.SetBytes(out, path+".total_tokens", usage.Get("totalTokens").Int())
+	if cached := usage.Get("cacheReadInputTokens").Int(); cached > 0 {
+		out, _ = sjson.SetBytes(out, path+".prompt_tokens_details.cached_tokens", cached)
+	}
+	return out
+}
+
+// converseFinishReason maps a Converse stop reason to an OpenAI finish reason.
+func converseFinishReason(reason string) string {
+	switch reason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "content_filtered", "guardrail_intervened":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
@@ -0,0 +1,208 @@
+package executor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ConverseStream replies use the AWS event stream encoding (application/vnd.amazon.eventstream):
+// binary frames of a prelude, typed headers and a JSON payload, each guarded by CRC32 checksums.
+
+const maxEventStreamFrame = 16 * 1024 * 1024
+
+// eventStreamMessage is one decoded event stream frame.
+type eventStreamMessage struct {
+	headers map[string]string
+	payload []byte
+}
+
+// readEventStreamMessage reads the next frame from r. It returns io.EOF at the end of the stream.
+func readEventStreamMessage(r io.Reader) (eventStreamMessage, error) {
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return eventStreamMessage{}, fmt.Errorf("bedrock event stream: truncated prelude")
+		}
+		return eventStreamMessage{}, err
+	}
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return eventStreamMessage{}, fmt.Errorf("bedrock event stream: prelude checksum mismatch")
+	}
+	if totalLen < 16 || totalLen > maxEventStreamFrame || headersLen > totalLen-16 {
+		return eventStreamMessage{}, fmt.Errorf("bedrock event stream: invalid frame length %d", totalLen)
+	}
+	frame := make([]byte, totalLen)
+	copy(frame, prelude)
+	if _, err := io.ReadFull(r, frame[12:]); err != nil {
+		return eventStreamMessage{}, fmt.Errorf("bedrock event stream: truncated frame: %w", err)
+	}
+	if crc32.ChecksumIEEE(frame[:totalLen-4]) != binary.BigEndian.Uint32(frame[totalLen-4:]) {
+		return eventStreamMessage{}, fmt.Errorf("bedrock event stream: message checksum mismatch")
+	}
+	headers, err := parseEventStreamHeaders(frame[12 : 12+headersLen])
+	if err != nil {
+		return eventStreamMessage{}, err
+	}
+	return eventStreamMessage{headers: headers, payload: frame[12+headersLen : totalLen-4]}, nil
+}
+
+// parseEventStreamHeaders decodes the frame headers. Only string values are kept; the other
+// types are skipped over.
+func parseEventStreamHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	errMalformed := fmt.Errorf("bedrock event stream: malformed headers")
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, errMalformed
+		}
+		name := string(b[1 : 1+nameLen])
+		valueType := b[1+nameLen]
+		b = b[2+nameLen:]
+		var size int
+		switch valueType {
+		case 0, 1: // bool true / false
+			size = 0
+		case 2: // byte
+			size = 1
+		case 3: // short
+			size = 2
+		case 4: // int
+			size = 4
+		case 5, 8: // long, timestamp
+			size = 8
+		case 9: // uuid
+			size = 16
+		case 6, 7: // byte array, string
+			if len(b) < 2 {
+				return nil, errMalformed
+			}
+			n := int(binary.BigEndian.Uint16(b[:2]))
+			if len(b) < 2+n {
+				return nil, errMalformed
+			}
+			if valueType == 7 {
+				headers[name] = string(b[2 : 2+n])
+			}
+			b = b[2+n:]
+			continue
+		default:
+			return nil, errMalformed
+		}
+		if len(b) < size {
+			return nil, errMalformed
+		}
+		b = b[size:]
+	}
+	return headers, nil
+}
+
+// bedrockStreamError converts an exception frame to an error carrying the matching HTTP status.
+func bedrockStreamError(msg eventStreamMessage) error {
+	kind := msg.headers[":exception-type"]
+	if kind == "" {
+		kind = msg.headers[":error-code"]
+	}
+	text := gjson.GetBytes(msg.payload, "message").String()
+	if text == "" {
+		text = msg.headers[":error-message"]
+	}
+	if text == "" {
+		text = string(msg.payload)
+	}
+	code := http.StatusBadGateway
+	switch kind {
+	case "throttlingException", "ThrottlingException":
+		code = http.StatusTooManyRequests
+	case "validationException", "ValidationException":
+		code = http.StatusBadRequest
+	case "serviceUnavailableException", "ServiceUnavailableException":
+		code = http.StatusServiceUnavailable
+	case "modelStreamErrorException", "internalServerException", "InternalServerException":
+		code = http.StatusInternalServerError
+	}
+	return statusErr{code: code, msg: fmt.Sprintf("bedrock %s: %s", kind, text)}
+}
+
+// converseStreamState converts ConverseStream events to OpenAI chat completion chunks.
+type converseStreamState struct {
+	id      string
+	model   string
+	created int64
+	// toolIndex maps Converse content block indexes to OpenAI tool call indexes.
+	toolIndex map[int64]int
+}
+
+func newConverseStreamState(model string, now time.Time) *converseStreamState {
+	return &converseStreamState{
+		id:        fmt.Sprintf("chatcmpl-%d", now.UnixNano()),
+		model:     model,
+		created:   now.Unix(),
+		toolIndex: make(map[int64]int),
+	}
+}
+
+// chunk returns the "data: " line of an event, or nil for events without OpenAI counterpart.
+func (s *converseStreamState) chunk(eventType string, payload []byte) []byte {
+	ev := gjson.ParseBytes(payload)
+	out := []byte(`{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{}}]}`)
+	out, _ = sjson.SetBytes(out, "id", s.id)
+	out, _ = sjson.SetBytes(out, "created", s.created)
+	out, _ = sjson.SetBytes(out, "model", s.model)
+	switch eventType {
+	case "messageStart":
+		out, _ = sjson.SetBytes(out, "choices.0.delta.role", "assistant")
+	case "contentBlockStart":
+		if !ev.Get("start.toolUse").Exists() {
+			return nil
+		}
+		idx := len(s.toolIndex)
+		s.toolIndex[ev.Get("contentBlockIndex").Int()] = idx
+		call := []byte(`{"type":"function","function":{"arguments":""}}`)
+		call, _ = sjson.SetBytes(call, "index", idx)
+		call, _ = sjson.SetBytes(call, "id", ev.Get("start.toolUse.toolUseId").String())
+		call, _ = sjson.SetBytes(call, "function.name", ev.Get("start.toolUse.name").String())
+		out, _ = sjson.SetRawBytes(out, "choices.0.delta.tool_calls", append(append([]byte("["), call...), ']'))
+	case "contentBlockDelta":
+		delta := ev.Get("delta")
+		switch {
+		case delta.Get("text").Exists():
+			out, _ = sjson.SetBytes(out, "choices.0.delta.content", delta.Get("text").String())
+		case delta.Get("reasoningContent.text").Exists():
+			out, _ = sjson.SetBytes(out, "choices.0.delta.reasoning_content", delta.Get("reasoningContent.text").String())
+		case delta.Get("toolUse").Exists():
+			idx, ok := s.toolIndex[ev.Get("contentBlockIndex").Int()]
+			if !ok {
+				return nil
+			}
+			call := []byte(`{"function":{}}`)
+			call, _ = sjson.SetBytes(call, "index", idx)
+			call, _ = sjson.SetBytes(call, "function.arguments", delta.Get("toolUse.input").String())
+			out, _ = sjson.SetRawBytes(out, "choices.0.delta.tool_calls", append(append([]byte("["), call...), ']'))
+		default:
+			return nil
+		}
+	case "messageStop":
+		out, _ = sjson.SetBytes(out, "choices.0.finish_reason", converseFinishReason(ev.Get("stopReason").String()))
+	case "metadata":
+		usage := ev.Get("usage")
+		if !usage.Exists() {
+			return nil
+		}
+		out, _ = sjson.SetRawBytes(out, "choices", []byte("[]"))
+		out = setConverseUsage(out, "usage", usage)
+	default:
+		return nil
+	}
+	return append([]byte("data: "), out...)
+}
@@ -0,0 +1,228 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// BedrockExecutor is a stateless executor for Amazon Bedrock over the Converse API. Requests
+// are signed with AWS Signature Version 4 using the credentials of the auth.
+type BedrockExecutor struct {
+	cfg *config.Config
+}
+
+func NewBedrockExecutor(cfg *config.Config) *BedrockExecutor { return &BedrockExecutor{cfg: cfg} }
+
+func (e *BedrockExecutor) Identifier() string { return "bedrock" }
+
+func (e *BedrockExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
+
+func (e *BedrockExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
+	creds, baseURL := bedrockCreds(auth)
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" || creds.region == "" {
+		return cliproxyexecutor.Response{}, statusErr{code: http.StatusUnauthorized, msg: "missing bedrock credentials or region"}
+	}
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	translated := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), opts.Stream)
+	body := openAIToConverse(translated)
+
+	body, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, body)
+	if errHook != nil {
+		return cliproxyexecutor.Response{}, errHook
+	}
+	httpReq, err := e.newRequest(ctx, baseURL, e.resolveModelID(req.Model, auth), "converse", body, creds)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	recordAPIRequest(ctx, e.cfg, body)
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
+		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(data)}
+	}
+	completion := converseToOpenAI(data, req.Model, time.Now())
+	reporter.publish(ctx, parseOpenAIUsage(completion))
+	var param any
+	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, completion, &param)
+	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
+}
+
+func (e *BedrockExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ <-chan cliproxyexecutor.StreamChunk, err error) {
+	creds, baseURL := bedrockCreds(auth)
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" || creds.region == "" {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: "missing bedrock credentials or region"}
+	}
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	translated := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), true)
+	body := openAIToConverse(translated)
+
+	body, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, body)
+	if errHook != nil {
+		return nil, errHook
+	}
+	httpReq, err := e.newRequest(ctx, baseURL, e.resolveModelID(req.Model, auth), "converse-stream", body, creds)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	recordAPIRequest(ctx, e.cfg, body)
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		b, _ := io.ReadAll(resp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
+		return nil, statusErr{code: resp.StatusCode, msg: string(b)}
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer func() { _ = resp.Body.Close() }()
+		reader := bufio.NewReaderSize(resp.Body, 64*1024)
+		state := newConverseStreamState(req.Model, time.Now())
+		var param any
+		emit := func(line []byte) {
+			chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, line, &param)
+			for i := range chunks {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
+			}
+		}
+		for {
+			msg, errRead := readEventStreamMessage(reader)
+			if errRead != nil {
+				if !errors.Is(errRead, io.EOF) {
+					out <- cliproxyexecutor.StreamChunk{Err: errRead}
+					return
+				}
+				break
+			}
+			appendAPIResponseChunk(ctx, e.cfg, msg.payload)
+			if msgType := msg.headers[":message-type"]; msgType != "" && msgType != "event" {
+				out <- cliproxyexecutor.StreamChunk{Err: bedrockStreamError(msg)}
+				return
+			}
+			line := state.chunk(msg.headers[":event-type"], msg.payload)
+			if line == nil {
+				continue
+			}
+			if detail, ok := parseOpenAIStreamUsage(line); ok {
+				reporter.publish(ctx, detail)
+			}
+			emit(line)
+		}
+		emit([]byte("data: [DONE]"))
+	}()
+	return reporter.trackStream(ctx, out), nil
+}
+
+func (e *BedrockExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{Payload: []byte{}}, fmt.Errorf("not implemented")
+}
+
+// Refresh is a no-op: Bedrock credentials come from the configuration.
+func (e *BedrockExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	log.Debugf("bedrock executor: refresh called")
+	_ = ctx
+	return auth, nil
+}
+
+// newRequest builds a signed request for the Converse action of modelID.
+func (e *BedrockExecutor) newRequest(ctx context.Context, baseURL, modelID, action string, body []byte, creds bedrockCredentials) (*http.Request, error) {
+	if baseURL == "" {
+		baseURL = "https://bedrock-runtime." + creds.region + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: invalid base url: %w", err)
+	}
+	// Model and inference profile IDs may be ARNs, whose slashes must be escaped.
+	basePath := u.EscapedPath()
+	u.Path += "/model/" + modelID + "/" + action
+	u.RawPath = basePath + "/model/" + url.PathEscape(modelID) + "/" + action
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	signSigV4(httpReq, body, creds, time.Now())
+	return httpReq, nil
+}
+
+// resolveModelID maps a requested alias to the configured Bedrock model ID.
+func (e *BedrockExecutor) resolveModelID(alias string, auth *cliproxyauth.Auth) string {
+	key := e.resolveBedrockKey(auth)
+	if key == nil {
+		return alias
+	}
+	for i := range key.Models {
+		model := key.Models[i]
+		if strings.EqualFold(model.Alias, alias) || (model.Alias == "" && strings.EqualFold(model.Name, alias)) {
+			return model.Name
+		}
+	}
+	return alias
+}
+
+func (e *BedrockExecutor) resolveBedrockKey(auth *cliproxyauth.Auth) *config.BedrockKey {
+	if auth == nil || auth.Attributes == nil || e.cfg == nil {
+		return nil
+	}
+	for i := range e.cfg.Bedrock {
+		key := &e.cfg.Bedrock[i]
+		if strings.TrimSpace(key.AccessKeyID) == auth.Attributes["access_key_id"] &&
+			strings.TrimSpace(key.Region) == auth.Attributes["region"] &&
+			strings.TrimSpace(key.BaseURL) == auth.Attributes["base_url"] {
+			return key
+		}
+	}
+	return nil
+}
+
+func bedrockCreds(a *cliproxyauth.Auth) (creds bedrockCredentials, baseURL string) {
+	if a == nil || a.Attributes == nil {
+		return bedrockCredentials{}, ""
+	}
+	creds = bedrockCredentials{
+		accessKeyID:     a.Attributes["access_key_id"],
+		secretAccessKey: a.Attributes["secret_access_key"],
+		sessionToken:    a.Attributes["session_token"],
+		region:          a.Attributes["region"],
+	}
+	return creds, a.Attributes["base_url"]
+}
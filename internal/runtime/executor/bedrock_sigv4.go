@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bedrockCredentials are the AWS credentials a Bedrock request is signed with.
+type bedrockCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	region          string
+}
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	sigV4Service   = "bedrock"
+	sigV4TimeFmt   = "20060102T150405Z"
+	sigV4DateFmt   = "20060102"
+)
+
+// signSigV4 signs req with AWS Signature Version 4 for the bedrock service. body must be the
+// exact payload sent with req.
+func signSigV4(req *http.Request, body []byte, creds bedrockCredentials, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(sigV4TimeFmt)
+	date := now.Format(sigV4DateFmt)
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+	host := req.URL.Host
+	if req.Host != "" {
+		host = req.Host
+	}
+
+	headers := map[string]string{
+		"content-type": strings.TrimSpace(req.Header.Get("Content-Type")),
+		"host":         host,
+		"x-amz-date":   amzDate,
+	}
+	if creds.sessionToken != "" {
+		headers["x-amz-security-token"] = creds.sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name)
+		canonicalHeaders.WriteByte(':')
+		canonicalHeaders.WriteString(headers[name])
+		canonicalHeaders.WriteByte('\n')
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4CanonicalURI(req.URL),
+		sigV4CanonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + creds.region + "/" + sigV4Service + "/aws4_request"
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	key = hmacSHA256(key, creds.region)
+	key = hmacSHA256(key, sigV4Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential="+creds.accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// sigV4CanonicalURI encodes every path segment once more on top of the escaping already on the
+// wire, as AWS requires for services other than S3.
+func sigV4CanonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sigV4CanonicalQuery(u *url.URL) string {
+	query := u.Query()
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, sigV4Escape(k)+"="+sigV4Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// sigV4Escape percent-encodes everything except the RFC 3986 unreserved characters.
+func sigV4Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteString(strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -16,12 +16,11 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
-
-	"github.com/gin-gonic/gin"
 )
 
 // ClaudeExecutor is a stateless executor for Anthropic Claude over the messages API.
@@ -36,30 +35,36 @@ func (e *ClaudeExecutor) Identifier() string { return "claude" }
 
 func (e *ClaudeExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
 
-func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
 	apiKey, baseURL := claudeCreds(auth)
 
 	if baseURL == "" {
 		baseURL = "https://api.anthropic.com"
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("claude")
 	// Use streaming translation to preserve function calling, except for claude.
 	stream := from != to
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), stream)
+	body := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), stream)
+	apiKeyMode := claudeAPIKeyMode(auth)
 
-	if !strings.HasPrefix(req.Model, "claude-3-5-haiku") {
+	if !apiKeyMode && !strings.HasPrefix(req.Model, "claude-3-5-haiku") {
 		body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
 	}
 
-	url := fmt.Sprintf("%s/v1/messages?beta=true", baseURL)
+	url := claudeURL(baseURL, "/v1/messages", apiKeyMode)
+	body, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, body)
+	if errHook != nil {
+		return cliproxyexecutor.Response{}, errHook
+	}
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
-	applyClaudeHeaders(httpReq, apiKey, false)
+	applyClaudeAuthHeaders(httpReq, apiKey, apiKeyMode, false)
 
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
 	resp, err := httpClient.Do(httpReq)
@@ -107,25 +112,33 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
 }
 
-func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ <-chan cliproxyexecutor.StreamChunk, err error) {
 	apiKey, baseURL := claudeCreds(auth)
 
 	if baseURL == "" {
 		baseURL = "https://api.anthropic.com"
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("claude")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
-	body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
+	body := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), true)
+	apiKeyMode := claudeAPIKeyMode(auth)
+	if !apiKeyMode {
+		body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
+	}
 
-	url := fmt.Sprintf("%s/v1/messages?beta=true", baseURL)
+	url := claudeURL(baseURL, "/v1/messages", apiKeyMode)
+	body, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, body)
+	if errHook != nil {
+		return nil, errHook
+	}
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
-	applyClaudeHeaders(httpReq, apiKey, true)
+	applyClaudeAuthHeaders(httpReq, apiKey, apiKeyMode, true)
 
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
 	resp, err := httpClient.Do(httpReq)
@@ -162,7 +175,7 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 			out <- cliproxyexecutor.StreamChunk{Err: err}
 		}
 	}()
-	return out, nil
+	return reporter.trackStream(ctx, out), nil
 }
 
 func (e *ClaudeExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
@@ -176,19 +189,24 @@ func (e *ClaudeExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	to := sdktranslator.FromString("claude")
 	// Use streaming translation to preserve function calling, except for claude.
 	stream := from != to
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), stream)
+	body := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), stream)
+	apiKeyMode := claudeAPIKeyMode(auth)
 
-	if !strings.HasPrefix(req.Model, "claude-3-5-haiku") {
+	if !apiKeyMode && !strings.HasPrefix(req.Model, "claude-3-5-haiku") {
 		body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
 	}
 
-	url := fmt.Sprintf("%s/v1/messages/count_tokens?beta=true", baseURL)
+	url := claudeURL(baseURL, "/v1/messages/count_tokens", apiKeyMode)
+	body, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, body)
+	if errHook != nil {
+		return cliproxyexecutor.Response{}, errHook
+	}
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
-	applyClaudeHeaders(httpReq, apiKey, false)
+	applyClaudeAuthHeaders(httpReq, apiKey, apiKeyMode, false)
 
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
 	resp, err := httpClient.Do(httpReq)
@@ -272,13 +290,59 @@ func hasZSTDEcoding(contentEncoding string) bool {
 	return false
 }
 
+// claudeAPIKeyMode reports whether auth is a configured Anthropic API key, which is served as
+// a plain Messages API client: x-api-key auth and the client's own system prompt. Keys with
+// claude-code set, and OAuth logins, are sent as Claude Code instead.
+func claudeAPIKeyMode(a *cliproxyauth.Auth) bool {
+	if a == nil || a.Attributes == nil {
+		return false
+	}
+	return a.Attributes["api_key"] != "" && !strings.EqualFold(a.Attributes["claude_code"], "true")
+}
+
+func claudeURL(baseURL, path string, apiKeyMode bool) string {
+	if apiKeyMode {
+		return strings.TrimRight(baseURL, "/") + path
+	}
+	return fmt.Sprintf("%s%s?beta=true", baseURL, path)
+}
+
+func applyClaudeAuthHeaders(r *http.Request, apiKey string, apiKeyMode, stream bool) {
+	if apiKeyMode {
+		applyClaudeAPIKeyHeaders(r, apiKey, stream)
+		return
+	}
+	applyClaudeHeaders(r, apiKey, stream)
+}
+
+// applyClaudeAPIKeyHeaders sets the headers of a Messages API call made with an API key. Beta
+// features are only requested when the client asked for them.
+func applyClaudeAPIKeyHeaders(r *http.Request, apiKey string, stream bool) {
+	r.Header.Set("X-Api-Key", apiKey)
+	r.Header.Set("Content-Type", "application/json")
+
+	var ginHeaders http.Header
+	if ginCtx := requestctx.Gin(r.Context()); ginCtx != nil && ginCtx.Request != nil {
+		ginHeaders = ginCtx.Request.Header
+	}
+	misc.EnsureHeader(r.Header, ginHeaders, "Anthropic-Version", "2023-06-01")
+	if beta := ginHeaders.Get("Anthropic-Beta"); beta != "" {
+		r.Header.Set("Anthropic-Beta", beta)
+	}
+	if stream {
+		r.Header.Set("Accept", "text/event-stream")
+		return
+	}
+	r.Header.Set("Accept", "application/json")
+}
+
 func applyClaudeHeaders(r *http.Request, apiKey string, stream bool) {
 	r.Header.Set("Authorization", "Bearer "+apiKey)
 	r.Header.Set("Content-Type", "application/json")
 	r.Header.Set("Anthropic-Beta", "claude-code-20250219,oauth-2025-04-20,interleaved-thinking-2025-05-14,fine-grained-tool-streaming-2025-05-14")
 
 	var ginHeaders http.Header
-	if ginCtx, ok := r.Context().Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+	if ginCtx := requestctx.Gin(r.Context()); ginCtx != nil && ginCtx.Request != nil {
 		ginHeaders = ginCtx.Request.Header
 	}
 
@@ -296,7 +360,7 @@ func applyClaudeHeaders(r *http.Request, apiKey string, stream bool) {
 	misc.EnsureHeader(r.Header, ginHeaders, "X-Stainless-Timeout", "60")
 	r.Header.Set("Connection", "keep-alive")
 	r.Header.Set("User-Agent", "claude-cli/1.0.83 (external, cli)")
-	r.Header.Set("Accept-Encoding", "gzip, deflate, br, zstd")
+	r.Header.Set("Accept-Encoding", "gzip, deflate, zstd")
 	if stream {
 		r.Header.Set("Accept", "text/event-stream")
 		return
@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// embedWith forwards an embeddings request from a wrapping executor to inner.
+func embedWith(ctx context.Context, inner cliproxyauth.ProviderExecutor, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	embedder, ok := inner.(cliproxyauth.EmbeddingExecutor)
+	if !ok {
+		return cliproxyexecutor.Response{}, statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("%s does not support embeddings", inner.Identifier())}
+	}
+	return embedder.Embed(ctx, auth, req, opts)
+}
+
+// geminiBatchEmbedRequest converts a normalised OpenAI embeddings request into a Gemini
+// batchEmbedContents body, one request per input.
+func geminiBatchEmbedRequest(model string, payload []byte) []byte {
+	root := gjson.ParseBytes(payload)
+	taskType := root.Get("task_type").String()
+	dimensions := root.Get("dimensions").Int()
+	body := []byte(`{"requests":[]}`)
+	for _, input := range root.Get("input").Array() {
+		item := []byte(`{}`)
+		item, _ = sjson.SetBytes(item, "model", "models/"+model)
+		item, _ = sjson.SetBytes(item, "content.parts.0.text", input.String())
+		if taskType != "" {
+			item, _ = sjson.SetBytes(item, "taskType", taskType)
+		}
+		if dimensions > 0 {
+			item, _ = sjson.SetBytes(item, "outputDimensionality", dimensions)
+		}
+		body, _ = sjson.SetRawBytes(body, "requests.-1", item)
+	}
+	return body
+}
+
+// openAIEmbeddingsFromGemini converts a Gemini batchEmbedContents response into an OpenAI
+// embeddings response. Usage is taken from usageMetadata when Gemini reports it and is zero
+// otherwise.
+func openAIEmbeddingsFromGemini(model string, data []byte) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"object":"list","data":[`)
+	for i, embedding := range gjson.GetBytes(data, "embeddings").Array() {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		values := embedding.Get("values").Raw
+		if values == "" {
+			values = "[]"
+		}
+		fmt.Fprintf(&sb, `{"object":"embedding","index":%d,"embedding":%s}`, i, values)
+	}
+	sb.WriteString(`]}`)
+	out, _ := sjson.SetBytes([]byte(sb.String()), "model", model)
+	detail := parseGeminiUsage(data)
+	out, _ = sjson.SetBytes(out, "usage.prompt_tokens", detail.InputTokens)
+	out, _ = sjson.SetBytes(out, "usage.total_tokens", detail.TotalTokens)
+	return out
+}
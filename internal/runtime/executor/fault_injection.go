@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	faultInjectionMu  sync.RWMutex
+	faultInjectionCfg config.FaultInjectionConfig
+)
+
+// ConfigureFaultInjection replaces the active fault injection settings.
+func ConfigureFaultInjection(cfg config.FaultInjectionConfig) {
+	faultInjectionMu.Lock()
+	defer faultInjectionMu.Unlock()
+	if cfg.Enabled && !faultInjectionCfg.Enabled {
+		log.Warnf("fault injection enabled with %d rule(s); upstream requests will fail deliberately", len(cfg.Rules))
+	}
+	faultInjectionCfg = cfg
+}
+
+func faultRuleFor(provider, model string) (config.FaultInjectionRule, bool) {
+	faultInjectionMu.RLock()
+	defer faultInjectionMu.RUnlock()
+	if !faultInjectionCfg.Enabled {
+		return config.FaultInjectionRule{}, false
+	}
+	for _, rule := range faultInjectionCfg.Rules {
+		if p := strings.TrimSpace(rule.Provider); p != "" && p != "*" && !strings.EqualFold(p, provider) {
+			continue
+		}
+		if len(rule.Models) > 0 {
+			matched := false
+			for _, m := range rule.Models {
+				if strings.EqualFold(strings.TrimSpace(m), model) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		return rule, true
+	}
+	return config.FaultInjectionRule{}, false
+}
+
+// faultInjectingExecutor wraps a provider executor and applies the configured faults before
+// delegating. Rules are read on every call so config reloads take effect immediately.
+type faultInjectingExecutor struct {
+	cliproxyauth.ProviderExecutor
+}
+
+// WithFaultInjection wraps inner so that fault injection rules apply to its requests.
+func WithFaultInjection(inner cliproxyauth.ProviderExecutor) cliproxyauth.ProviderExecutor {
+	if inner == nil {
+		return nil
+	}
+	return faultInjectingExecutor{ProviderExecutor: inner}
+}
+
+func (e faultInjectingExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	rule, ok := faultRuleFor(e.Identifier(), req.Model)
+	if ok {
+		if err := injectFault(ctx, e.Identifier(), req.Model, rule); err != nil {
+			return cliproxyexecutor.Response{}, err
+		}
+	}
+	return e.ProviderExecutor.Execute(ctx, auth, req, opts)
+}
+
+func (e faultInjectingExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	rule, ok := faultRuleFor(e.Identifier(), req.Model)
+	if !ok {
+		return e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+	}
+	if err := injectFault(ctx, e.Identifier(), req.Model, rule); err != nil {
+		return nil, err
+	}
+	in, err := e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+	if err != nil || in == nil || rule.TruncateRate <= 0 || rand.Float64() >= rule.TruncateRate {
+		return in, err
+	}
+	limit := rule.TruncateAfterChunks
+	if limit < 0 {
+		limit = 0
+	}
+	log.Debugf("fault injection: truncating %s stream for %s after %d chunk(s)", e.Identifier(), req.Model, limit)
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		sent := 0
+		for chunk := range in {
+			if sent >= limit {
+				continue
+			}
+			select {
+			case out <- chunk:
+				sent++
+			case <-ctx.Done():
+				sent = limit
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (e faultInjectingExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if rule, ok := faultRuleFor(e.Identifier(), req.Model); ok {
+		if err := injectFault(ctx, e.Identifier(), req.Model, rule); err != nil {
+			return cliproxyexecutor.Response{}, err
+		}
+	}
+	return embedWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+func (e faultInjectingExecutor) Speech(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	if rule, ok := faultRuleFor(e.Identifier(), req.Model); ok {
+		if err := injectFault(ctx, e.Identifier(), req.Model, rule); err != nil {
+			return nil, err
+		}
+	}
+	return speechWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+func (e faultInjectingExecutor) Transcribe(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if rule, ok := faultRuleFor(e.Identifier(), req.Model); ok {
+		if err := injectFault(ctx, e.Identifier(), req.Model, rule); err != nil {
+			return cliproxyexecutor.Response{}, err
+		}
+	}
+	return transcribeWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+// Unwrap returns the wrapped executor.
+func (e faultInjectingExecutor) Unwrap() cliproxyauth.ProviderExecutor { return e.ProviderExecutor }
+
+// injectFault applies latency and returns an injected error when the dice say so.
+func injectFault(ctx context.Context, provider, model string, rule config.FaultInjectionRule) error {
+	delay := time.Duration(rule.LatencyMs) * time.Millisecond
+	if rule.LatencyJitterMs > 0 {
+		delay += time.Duration(rand.IntN(rule.LatencyJitterMs)) * time.Millisecond
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if rule.CookieExpiryRate > 0 && rand.Float64() < rule.CookieExpiryRate {
+		log.Debugf("fault injection: simulated cookie expiry for %s/%s", provider, model)
+		return statusErr{code: http.StatusUnauthorized, msg: "fault injection: session cookies expired"}
+	}
+	if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+		statuses := rule.ErrorStatuses
+		if len(statuses) == 0 {
+			statuses = []int{http.StatusTooManyRequests, http.StatusInternalServerError}
+		}
+		code := statuses[rand.IntN(len(statuses))]
+		log.Debugf("fault injection: returning %d for %s/%s", code, provider, model)
+		return statusErr{code: code, msg: "fault injection: " + http.StatusText(code)}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,6 +17,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -68,15 +70,16 @@ func (e *GeminiExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) e
 // Returns:
 //   - cliproxyexecutor.Response: The response from the API
 //   - error: An error if the request fails
-func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
 	apiKey, bearer := geminiCreds(auth)
 
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
 
 	// Official Gemini API via API key or OAuth bearer
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	body := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), false)
 
 	action := "generateContent"
 	if req.Metadata != nil {
@@ -91,6 +94,10 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 
 	body, _ = sjson.DeleteBytes(body, "session_id")
 
+	body, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, body)
+	if errHook != nil {
+		return cliproxyexecutor.Response{}, errHook
+	}
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
@@ -126,14 +133,15 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
 }
 
-func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ <-chan cliproxyexecutor.StreamChunk, err error) {
 	apiKey, bearer := geminiCreds(auth)
 
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), true)
 
 	url := fmt.Sprintf("%s/%s/models/%s:%s", glEndpoint, glAPIVersion, req.Model, "streamGenerateContent")
 	if opts.Alt == "" {
@@ -144,6 +152,10 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 
 	body, _ = sjson.DeleteBytes(body, "session_id")
 
+	body, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, body)
+	if errHook != nil {
+		return nil, errHook
+	}
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
@@ -195,7 +207,7 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 			out <- cliproxyexecutor.StreamChunk{Err: err}
 		}
 	}()
-	return out, nil
+	return reporter.trackStream(ctx, out), nil
 }
 
 func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
@@ -203,12 +215,16 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	translatedReq := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
-	respCtx := context.WithValue(ctx, "alt", opts.Alt)
+	translatedReq := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), false)
+	respCtx := requestctx.WithAlt(ctx, opts.Alt)
 	translatedReq, _ = sjson.DeleteBytes(translatedReq, "tools")
 	translatedReq, _ = sjson.DeleteBytes(translatedReq, "generationConfig")
 
 	url := fmt.Sprintf("%s/%s/models/%s:%s", glEndpoint, glAPIVersion, req.Model, "countTokens")
+	translatedReq, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, translatedReq)
+	if errHook != nil {
+		return cliproxyexecutor.Response{}, errHook
+	}
 	recordAPIRequest(ctx, e.cfg, translatedReq)
 
 	requestBody := bytes.NewReader(translatedReq)
@@ -246,6 +262,175 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
 }
 
+// Embed computes embeddings through the batchEmbedContents endpoint.
+func (e *GeminiExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
+	apiKey, bearer := geminiCreds(auth)
+
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	body := geminiBatchEmbedRequest(req.Model, req.Payload)
+	url := fmt.Sprintf("%s/%s/models/%s:batchEmbedContents", glEndpoint, glAPIVersion, req.Model)
+	recordAPIRequest(ctx, e.cfg, body)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("x-goog-api-key", apiKey)
+	} else if bearer != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
+		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(data)}
+	}
+	reporter.publish(ctx, parseGeminiUsage(data))
+	return cliproxyexecutor.Response{Payload: openAIEmbeddingsFromGemini(req.Model, data)}, nil
+}
+
+// Transcribe transcribes audio with a Gemini model, which returns the transcript as timed
+// segments that are converted to an OpenAI verbose_json transcription.
+func (e *GeminiExecutor) Transcribe(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
+	audio, err := transcriptionAudio(req.Payload)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	if len(audio) > geminiInlineAudioLimit {
+		return cliproxyexecutor.Response{}, statusErr{code: http.StatusRequestEntityTooLarge, msg: fmt.Sprintf("audio is %d MB; Gemini models transcribe files up to %d MB", len(audio)>>20, geminiInlineAudioLimit>>20)}
+	}
+	apiKey, bearer := geminiCreds(auth)
+
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	body := geminiTranscriptionRequest(req.Payload)
+	url := fmt.Sprintf("%s/%s/models/%s:generateContent", glEndpoint, glAPIVersion, req.Model)
+	recordAPIRequest(ctx, e.cfg, body)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("x-goog-api-key", apiKey)
+	} else if bearer != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
+		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(data)}
+	}
+	reporter.publish(ctx, parseGeminiUsage(data))
+	transcript, err := openAITranscriptionFromGemini(data)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return cliproxyexecutor.Response{Payload: transcript}, nil
+}
+
+// Speech synthesizes an OpenAI audio/speech request with a Gemini TTS model, streaming the
+// PCM audio as it is generated.
+func (e *GeminiExecutor) Speech(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ <-chan cliproxyexecutor.StreamChunk, err error) {
+	format, err := geminiSpeechFormat(req.Payload)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, bearer := geminiCreds(auth)
+
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	body := geminiSpeechRequest(req.Payload)
+	url := fmt.Sprintf("%s/%s/models/%s:streamGenerateContent?alt=sse", glEndpoint, glAPIVersion, req.Model)
+	recordAPIRequest(ctx, e.cfg, body)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("x-goog-api-key", apiKey)
+	} else if bearer != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		b, _ := io.ReadAll(resp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
+		return nil, statusErr{code: resp.StatusCode, msg: string(b)}
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer func() { _ = resp.Body.Close() }()
+		scanner := bufio.NewScanner(resp.Body)
+		buf := make([]byte, 4*1024*1024)
+		scanner.Buffer(buf, 4*1024*1024)
+		gotAudio := false
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if detail, ok := parseGeminiStreamUsage(line); ok {
+				reporter.publish(ctx, detail)
+			}
+			payload, found := bytes.CutPrefix(line, []byte("data:"))
+			if !found {
+				continue
+			}
+			for _, part := range gjson.GetBytes(bytes.TrimSpace(payload), "candidates.0.content.parts").Array() {
+				inline := part.Get("inlineData")
+				audio, errDecode := base64.StdEncoding.DecodeString(inline.Get("data").String())
+				if errDecode != nil || len(audio) == 0 {
+					continue
+				}
+				if !gotAudio && format == "wav" {
+					out <- cliproxyexecutor.StreamChunk{Payload: wavHeader(pcmSampleRate(inline.Get("mimeType").String()))}
+				}
+				gotAudio = true
+				out <- cliproxyexecutor.StreamChunk{Payload: audio}
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			out <- cliproxyexecutor.StreamChunk{Err: err}
+		} else if !gotAudio {
+			out <- cliproxyexecutor.StreamChunk{Err: statusErr{code: http.StatusBadGateway, msg: "gemini returned no audio"}}
+		}
+	}()
+	return reporter.trackStream(ctx, out), nil
+}
+
 func (e *GeminiExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
 	log.Debugf("gemini executor: refresh called")
 	// OAuth bearer token refresh for official Gemini API.
@@ -3,10 +3,14 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
@@ -14,17 +18,23 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
 	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
 )
 
 type GeminiWebExecutor struct {
 	cfg *config.Config
-	mu  sync.Mutex
 }
 
+// geminiWebStateMu guards lazy creation of per-auth Gemini Web state.
+var geminiWebStateMu sync.Mutex
+
 func NewGeminiWebExecutor(cfg *config.Config) *GeminiWebExecutor {
 	return &GeminiWebExecutor{cfg: cfg}
 }
@@ -33,27 +43,25 @@ func (e *GeminiWebExecutor) Identifier() string { return "gemini-web" }
 
 func (e *GeminiWebExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
 
-func (e *GeminiWebExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (e *GeminiWebExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
 	state, err := e.stateFor(auth)
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
 	if err = state.EnsureClient(); err != nil {
-		return cliproxyexecutor.Response{}, err
+		return cliproxyexecutor.Response{}, geminiWebInitError(err)
 	}
 	match := extractGeminiWebMatch(opts.Metadata)
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
 
-	mutex := state.GetRequestMutex()
-	if mutex != nil {
-		mutex.Lock()
-		defer mutex.Unlock()
-		if match != nil {
-			state.SetPendingMatch(match)
-		}
-	} else if match != nil {
-		state.SetPendingMatch(match)
+	release, err := state.AcquireSlot(ctx)
+	if err != nil {
+		return cliproxyexecutor.Response{}, geminiWebQueueError(err)
 	}
+	defer release()
+	ctx = geminiwebapi.WithPendingMatch(ctx, match)
+	ctx = geminiwebapi.WithConversationID(ctx, extractGeminiWebConversationID(opts.Metadata))
 
 	payload := bytes.Clone(req.Payload)
 	resp, errMsg, prep := state.Send(ctx, req.Model, payload, opts)
@@ -71,33 +79,28 @@ func (e *GeminiWebExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
 }
 
-func (e *GeminiWebExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+func (e *GeminiWebExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ <-chan cliproxyexecutor.StreamChunk, err error) {
 	state, err := e.stateFor(auth)
 	if err != nil {
 		return nil, err
 	}
 	if err = state.EnsureClient(); err != nil {
-		return nil, err
+		return nil, geminiWebInitError(err)
 	}
 	match := extractGeminiWebMatch(opts.Metadata)
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
 
-	mutex := state.GetRequestMutex()
-	if mutex != nil {
-		mutex.Lock()
-		if match != nil {
-			state.SetPendingMatch(match)
-		}
-	}
-	if mutex == nil && match != nil {
-		state.SetPendingMatch(match)
+	release, err := state.AcquireSlot(ctx)
+	if err != nil {
+		return nil, geminiWebQueueError(err)
 	}
+	ctx = geminiwebapi.WithPendingMatch(ctx, match)
+	ctx = geminiwebapi.WithConversationID(ctx, extractGeminiWebConversationID(opts.Metadata))
 
 	gemBytes, errMsg, prep := state.Send(ctx, req.Model, bytes.Clone(req.Payload), opts)
 	if errMsg != nil {
-		if mutex != nil {
-			mutex.Unlock()
-		}
+		release()
 		return nil, geminiWebErrorFromMessage(errMsg)
 	}
 	reporter.publish(ctx, parseGeminiUsage(gemBytes))
@@ -111,9 +114,7 @@ func (e *GeminiWebExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 	out := make(chan cliproxyexecutor.StreamChunk)
 	go func() {
 		defer close(out)
-		if mutex != nil {
-			defer mutex.Unlock()
-		}
+		defer release()
 		for _, line := range lines {
 			lines = sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), req.Payload, bytes.Clone([]byte(line)), &param)
 			for _, l := range lines {
@@ -127,11 +128,20 @@ func (e *GeminiWebExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 			}
 		}
 	}()
-	return out, nil
+	return reporter.trackStream(ctx, out), nil
 }
 
+// CountTokens estimates the prompt tokens locally, since Gemini Web has no counting endpoint.
 func (e *GeminiWebExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
-	return cliproxyexecutor.Response{Payload: []byte{}}, fmt.Errorf("not implemented")
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("gemini")
+	translatedReq := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	translatedReq, _ = sjson.DeleteBytes(translatedReq, "generationConfig")
+	count := internalusage.EstimatePromptTokens(translatedReq)
+	data, _ := sjson.SetBytes([]byte(`{}`), "totalTokens", count)
+	respCtx := requestctx.WithAlt(ctx, opts.Alt)
+	translated := sdktranslator.TranslateTokenCount(respCtx, to, from, count, data)
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
 }
 
 func (e *GeminiWebExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
@@ -141,7 +151,7 @@ func (e *GeminiWebExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth
 		return nil, err
 	}
 	if err = state.Refresh(ctx); err != nil {
-		return nil, err
+		return nil, geminiWebInitError(err)
 	}
 	ts := state.TokenSnapshot()
 	if auth.Metadata == nil {
@@ -161,20 +171,32 @@ func (e *GeminiWebExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth
 
 type geminiWebRuntime struct {
 	state *geminiwebapi.GeminiWebState
+	// base is the configuration the state was last synced from.
+	base atomic.Pointer[config.Config]
+	// pooled is the proxy-pool proxy the state was last synced with.
+	pooled atomic.Value
 }
 
 func (e *GeminiWebExecutor) stateFor(auth *cliproxyauth.Auth) (*geminiwebapi.GeminiWebState, error) {
+	return GeminiWebStateFor(e.cfg, auth)
+}
+
+// GeminiWebStateFor returns the Gemini Web state cached on auth.Runtime, creating it on first use.
+// It allows callers outside the executor (e.g. management handlers) to reuse the account's session.
+func GeminiWebStateFor(cfg *config.Config, auth *cliproxyauth.Auth) (*geminiwebapi.GeminiWebState, error) {
 	if auth == nil {
 		return nil, fmt.Errorf("gemini-web executor: auth is nil")
 	}
 	if runtime, ok := auth.Runtime.(*geminiWebRuntime); ok && runtime != nil && runtime.state != nil {
+		runtime.sync(cfg, auth)
 		return runtime.state, nil
 	}
 
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	geminiWebStateMu.Lock()
+	defer geminiWebStateMu.Unlock()
 
 	if runtime, ok := auth.Runtime.(*geminiWebRuntime); ok && runtime != nil && runtime.state != nil {
+		runtime.sync(cfg, auth)
 		return runtime.state, nil
 	}
 
@@ -183,25 +205,83 @@ func (e *GeminiWebExecutor) stateFor(auth *cliproxyauth.Auth) (*geminiwebapi.Gem
 		return nil, err
 	}
 
-	cfg := e.cfg
-	if auth.ProxyURL != "" && cfg != nil {
-		copyCfg := *cfg
-		copyCfg.ProxyURL = auth.ProxyURL
-		cfg = &copyCfg
-	}
-
 	storagePath := ""
 	if auth.Attributes != nil {
 		if p, ok := auth.Attributes["path"]; ok {
 			storagePath = p
 		}
 	}
-	state := geminiwebapi.NewGeminiWebState(cfg, ts, storagePath, auth.Label)
+	state := geminiwebapi.NewGeminiWebState(geminiWebConfigFor(cfg, auth), ts, storagePath, cliproxyauth.GeminiWebAccountLabel(auth))
+	state.SetBandwidthAccount(auth.ID)
 	runtime := &geminiWebRuntime{state: state}
+	runtime.base.Store(cfg)
+	runtime.pooled.Store(pooledProxyFor(auth))
 	auth.Runtime = runtime
 	return state, nil
 }
 
+// ExistingGeminiWebState returns the cached Gemini Web session of auth without creating one.
+func ExistingGeminiWebState(auth *cliproxyauth.Auth) (*geminiwebapi.GeminiWebState, bool) {
+	if auth == nil {
+		return nil, false
+	}
+	if runtime, ok := auth.Runtime.(*geminiWebRuntime); ok && runtime != nil && runtime.state != nil {
+		return runtime.state, true
+	}
+	return nil, false
+}
+
+// ReleaseGeminiWebState closes the cached Gemini Web session of auth, if one was created.
+func ReleaseGeminiWebState(auth *cliproxyauth.Auth) {
+	if auth == nil {
+		return
+	}
+	if runtime, ok := auth.Runtime.(*geminiWebRuntime); ok && runtime != nil && runtime.state != nil {
+		runtime.state.Close()
+	}
+}
+
+// sync pushes a reloaded configuration, or a move to another pool proxy, into the cached
+// state.
+func (r *geminiWebRuntime) sync(cfg *config.Config, auth *cliproxyauth.Auth) {
+	if cfg == nil {
+		return
+	}
+	pooled := pooledProxyFor(auth)
+	if r.base.Load() == cfg && r.pooled.Load() == pooled {
+		return
+	}
+	r.base.Store(cfg)
+	r.pooled.Store(pooled)
+	r.state.SetConfig(geminiWebConfigFor(cfg, auth))
+}
+
+// geminiWebConfigFor applies the auth's own proxy URL, or else its pool proxy, on top of cfg.
+func geminiWebConfigFor(cfg *config.Config, auth *cliproxyauth.Auth) *config.Config {
+	if auth == nil || cfg == nil {
+		return cfg
+	}
+	proxyURL := auth.ProxyURL
+	if proxyURL == "" {
+		proxyURL = pooledProxyFor(auth)
+	}
+	if proxyURL == "" {
+		return cfg
+	}
+	copyCfg := *cfg
+	copyCfg.ProxyURL = proxyURL
+	return &copyCfg
+}
+
+// pooledProxyFor returns the proxy-pool proxy of an auth without its own proxy URL.
+func pooledProxyFor(auth *cliproxyauth.Auth) string {
+	if auth == nil || auth.ProxyURL != "" {
+		return ""
+	}
+	proxyURL, _ := proxypool.Default().ProxyFor(auth.ID)
+	return proxyURL
+}
+
 func parseGeminiWebToken(auth *cliproxyauth.Auth) (*gemini.GeminiWebTokenStorage, error) {
 	if auth == nil {
 		return nil, fmt.Errorf("gemini-web executor: auth is nil")
@@ -250,6 +330,36 @@ func (e geminiWebError) Error() string {
 	return fmt.Sprintf("gemini-web error: status %d", e.message.StatusCode)
 }
 
+// geminiWebQueueError maps a queue rejection to 503 with Retry-After so the request can
+// move to another account or be retried by the client.
+func geminiWebQueueError(err error) error {
+	var queueErr *geminiwebapi.QueueError
+	if !errors.As(err, &queueErr) {
+		return err
+	}
+	headers := http.Header{}
+	headers.Set("Retry-After", strconv.Itoa(int(math.Ceil(queueErr.RetryAfter.Seconds()))))
+	return geminiWebError{message: &interfaces.ErrorMessage{StatusCode: http.StatusServiceUnavailable, Error: queueErr, Addon: headers, Code: interfaces.ErrorCodeAccountBusy}}
+}
+
+// geminiWebInitError maps a rejection of the account cookies while connecting or rotating to
+// 401 with COOKIE_EXPIRED, which makes the auth manager stop routing to the account.
+func geminiWebInitError(err error) error {
+	var authErr *geminiwebapi.AuthError
+	if !errors.As(err, &authErr) {
+		return err
+	}
+	return geminiWebError{message: &interfaces.ErrorMessage{StatusCode: http.StatusUnauthorized, Error: authErr, Code: interfaces.ErrorCodeCookieExpired}}
+}
+
+// Headers returns the response headers that accompany the error, such as Retry-After.
+func (e geminiWebError) Headers() http.Header {
+	if e.message == nil {
+		return nil
+	}
+	return e.message.Addon
+}
+
 func (e geminiWebError) StatusCode() int {
 	if e.message == nil {
 		return 0
@@ -257,6 +367,14 @@ func (e geminiWebError) StatusCode() int {
 	return e.message.StatusCode
 }
 
+// ErrorCode returns the machine-readable code of the error.
+func (e geminiWebError) ErrorCode() string {
+	if e.message == nil {
+		return ""
+	}
+	return e.message.Code
+}
+
 func extractGeminiWebMatch(metadata map[string]any) *conversation.MatchResult {
 	if metadata == nil {
 		return nil
@@ -274,3 +392,17 @@ func extractGeminiWebMatch(metadata map[string]any) *conversation.MatchResult {
 		return nil
 	}
 }
+
+func extractGeminiWebConversationID(metadata map[string]any) *conversation.ConversationIDRef {
+	if metadata == nil {
+		return nil
+	}
+	switch v := metadata[conversation.MetadataConversationIDKey].(type) {
+	case *conversation.ConversationIDRef:
+		return v
+	case conversation.ConversationIDRef:
+		return &v
+	default:
+		return nil
+	}
+}
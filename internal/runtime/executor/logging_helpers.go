@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"context"
 
-	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 )
 
 // recordAPIRequest stores the upstream request payload in Gin context for request logging.
@@ -13,7 +13,7 @@ func recordAPIRequest(ctx context.Context, cfg *config.Config, payload []byte) {
 	if cfg == nil || !cfg.RequestLog || len(payload) == 0 {
 		return
 	}
-	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+	if ginCtx := requestctx.Gin(ctx); ginCtx != nil {
 		ginCtx.Set("API_REQUEST", bytes.Clone(payload))
 	}
 }
@@ -27,7 +27,7 @@ func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byt
 	if len(data) == 0 {
 		return
 	}
-	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+	if ginCtx := requestctx.Gin(ctx); ginCtx != nil {
 		if existing, exists := ginCtx.Get("API_RESPONSE"); exists {
 			if prev, okBytes := existing.([]byte); okBytes {
 				prev = append(prev, data...)
@@ -38,22 +38,28 @@ func (e *OpenAICompatExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.A
 	return nil
 }
 
-func (e *OpenAICompatExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (e *OpenAICompatExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
 	baseURL, apiKey := e.resolveCredentials(auth)
+	baseURL = e.resolveModelBaseURL(req.Model, auth, baseURL)
 	if baseURL == "" || apiKey == "" {
 		return cliproxyexecutor.Response{}, statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL or apiKey"}
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
 
 	// Translate inbound request to OpenAI format
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("openai")
-	translated := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), opts.Stream)
+	translated := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), opts.Stream)
 	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
 		translated = e.overrideModel(translated, modelOverride)
 	}
 
 	url := strings.TrimSuffix(baseURL, "/") + "/chat/completions"
+	translated, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, translated)
+	if errHook != nil {
+		return cliproxyexecutor.Response{}, errHook
+	}
 	recordAPIRequest(ctx, e.cfg, translated)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(translated))
 	if err != nil {
@@ -87,20 +93,26 @@ func (e *OpenAICompatExecutor) Execute(ctx context.Context, auth *cliproxyauth.A
 	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
 }
 
-func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ <-chan cliproxyexecutor.StreamChunk, err error) {
 	baseURL, apiKey := e.resolveCredentials(auth)
+	baseURL = e.resolveModelBaseURL(req.Model, auth, baseURL)
 	if baseURL == "" || apiKey == "" {
 		return nil, statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL or apiKey"}
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("openai")
-	translated := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	translated := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), true)
 	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
 		translated = e.overrideModel(translated, modelOverride)
 	}
 
 	url := strings.TrimSuffix(baseURL, "/") + "/chat/completions"
+	translated, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, translated)
+	if errHook != nil {
+		return nil, errHook
+	}
 	recordAPIRequest(ctx, e.cfg, translated)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(translated))
 	if err != nil {
@@ -152,13 +164,169 @@ func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxy
 			out <- cliproxyexecutor.StreamChunk{Err: err}
 		}
 	}()
-	return out, nil
+	return reporter.trackStream(ctx, out), nil
 }
 
 func (e *OpenAICompatExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	return cliproxyexecutor.Response{Payload: []byte{}}, fmt.Errorf("not implemented")
 }
 
+// Embed forwards an embeddings request to the provider's /embeddings endpoint.
+func (e *OpenAICompatExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
+	baseURL, apiKey := e.resolveCredentials(auth)
+	baseURL = e.resolveModelBaseURL(req.Model, auth, baseURL)
+	if baseURL == "" || apiKey == "" {
+		return cliproxyexecutor.Response{}, statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL or apiKey"}
+	}
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	body, _ := sjson.DeleteBytes(stripVendorExtensions(req.Payload), "task_type")
+	body, _ = sjson.SetBytes(body, "model", req.Model)
+	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
+		body = e.overrideModel(body, modelOverride)
+	}
+
+	url := strings.TrimSuffix(baseURL, "/") + "/embeddings"
+	recordAPIRequest(ctx, e.cfg, body)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	httpReq.Header.Set("User-Agent", "cli-proxy-openai-compat")
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
+		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(data)}
+	}
+	reporter.publish(ctx, parseOpenAIUsage(data))
+	data, _ = sjson.SetBytes(data, "model", req.Model)
+	return cliproxyexecutor.Response{Payload: data}, nil
+}
+
+// Transcribe forwards a transcription request to the provider's /audio/transcriptions
+// endpoint as a multipart upload and returns the result in verbose_json shape.
+func (e *OpenAICompatExecutor) Transcribe(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
+	baseURL, apiKey := e.resolveCredentials(auth)
+	baseURL = e.resolveModelBaseURL(req.Model, auth, baseURL)
+	if baseURL == "" || apiKey == "" {
+		return cliproxyexecutor.Response{}, statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL or apiKey"}
+	}
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	model := req.Model
+	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
+		model = modelOverride
+	}
+	body, contentType, err := openAITranscriptionForm(model, req.Payload)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+
+	url := strings.TrimSuffix(baseURL, "/") + "/audio/transcriptions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	httpReq.Header.Set("User-Agent", "cli-proxy-openai-compat")
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
+		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(data)}
+	}
+	reporter.publish(ctx, parseOpenAIUsage(data))
+	return cliproxyexecutor.Response{Payload: normalizeOpenAITranscription(data, req.Payload)}, nil
+}
+
+// Speech forwards an audio/speech request to the provider's /audio/speech endpoint and
+// streams the audio it returns.
+func (e *OpenAICompatExecutor) Speech(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ <-chan cliproxyexecutor.StreamChunk, err error) {
+	baseURL, apiKey := e.resolveCredentials(auth)
+	baseURL = e.resolveModelBaseURL(req.Model, auth, baseURL)
+	if baseURL == "" || apiKey == "" {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL or apiKey"}
+	}
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	// Audio is always requested raw; SSE framing for the client is done by the handler.
+	body, _ := sjson.DeleteBytes(stripVendorExtensions(req.Payload), "stream_format")
+	body, _ = sjson.SetBytes(body, "model", req.Model)
+	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
+		body = e.overrideModel(body, modelOverride)
+	}
+
+	url := strings.TrimSuffix(baseURL, "/") + "/audio/speech"
+	recordAPIRequest(ctx, e.cfg, body)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	httpReq.Header.Set("User-Agent", "cli-proxy-openai-compat")
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		b, _ := io.ReadAll(resp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
+		return nil, statusErr{code: resp.StatusCode, msg: string(b)}
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer func() { _ = resp.Body.Close() }()
+		buf := make([]byte, 32*1024)
+		for {
+			n, errRead := resp.Body.Read(buf)
+			if n > 0 {
+				out <- cliproxyexecutor.StreamChunk{Payload: bytes.Clone(buf[:n])}
+			}
+			if errRead == io.EOF {
+				return
+			}
+			if errRead != nil {
+				out <- cliproxyexecutor.StreamChunk{Err: errRead}
+				return
+			}
+		}
+	}()
+	return reporter.trackStream(ctx, out), nil
+}
+
 // Refresh is a no-op for API-key based compatibility providers.
 func (e *OpenAICompatExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
 	log.Debugf("openai compat executor: refresh called")
@@ -178,29 +346,48 @@ func (e *OpenAICompatExecutor) resolveCredentials(auth *cliproxyauth.Auth) (base
 }
 
 func (e *OpenAICompatExecutor) resolveUpstreamModel(alias string, auth *cliproxyauth.Auth) string {
-	if alias == "" || auth == nil || e.cfg == nil {
+	model := e.resolveModelConfig(alias, auth)
+	if model == nil {
 		return ""
 	}
+	if model.Name != "" {
+		return model.Name
+	}
+	return alias
+}
+
+// resolveModelBaseURL returns the base URL configured for the model, falling back to the
+// provider's.
+func (e *OpenAICompatExecutor) resolveModelBaseURL(alias string, auth *cliproxyauth.Auth, fallback string) string {
+	if model := e.resolveModelConfig(alias, auth); model != nil {
+		if base := strings.TrimSpace(model.BaseURL); base != "" {
+			return base
+		}
+	}
+	return fallback
+}
+
+func (e *OpenAICompatExecutor) resolveModelConfig(alias string, auth *cliproxyauth.Auth) *config.OpenAICompatibilityModel {
+	if alias == "" || auth == nil || e.cfg == nil {
+		return nil
+	}
 	compat := e.resolveCompatConfig(auth)
 	if compat == nil {
-		return ""
+		return nil
 	}
 	for i := range compat.Models {
-		model := compat.Models[i]
+		model := &compat.Models[i]
 		if model.Alias != "" {
 			if strings.EqualFold(model.Alias, alias) {
-				if model.Name != "" {
-					return model.Name
-				}
-				return alias
+				return model
 			}
 			continue
 		}
 		if strings.EqualFold(model.Name, alias) {
-			return model.Name
+			return model
 		}
 	}
-	return ""
+	return nil
 }
 
 func (e *OpenAICompatExecutor) resolveCompatConfig(auth *cliproxyauth.Auth) *config.OpenAICompatibility {
@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/pipeline"
+)
+
+// Payload hooks are registered by embedders through sdk/cliproxy/pipeline. Pre-send hooks run
+// inside each executor on the translated body; post-receive hooks run in payloadHookExecutor
+// on the client-format response.
+
+func hookInfo(provider string, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, stream bool) pipeline.HookInfo {
+	return pipeline.HookInfo{
+		Provider:     provider,
+		Model:        req.Model,
+		Auth:         auth,
+		SourceFormat: opts.SourceFormat.String(),
+		Stream:       stream,
+	}
+}
+
+// applyBeforeSendHooks runs the pre-send hooks of provider on the translated request body.
+func applyBeforeSendHooks(ctx context.Context, provider string, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, body []byte) ([]byte, error) {
+	if !pipeline.HasPayloadHooks(provider) {
+		return body, nil
+	}
+	return pipeline.ApplyBeforeSend(ctx, hookInfo(provider, auth, req, opts, opts.Stream), body)
+}
+
+// payloadHookExecutor wraps a provider executor and runs the post-receive hooks on its
+// responses. Hooks are looked up on every call, so hooks registered later still apply.
+type payloadHookExecutor struct {
+	cliproxyauth.ProviderExecutor
+}
+
+// WithPayloadHooks wraps inner so that post-receive payload hooks apply to its responses.
+func WithPayloadHooks(inner cliproxyauth.ProviderExecutor) cliproxyauth.ProviderExecutor {
+	if inner == nil {
+		return nil
+	}
+	return payloadHookExecutor{ProviderExecutor: inner}
+}
+
+func (e payloadHookExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	resp, err := e.ProviderExecutor.Execute(ctx, auth, req, opts)
+	if err != nil || !pipeline.HasPayloadHooks(e.Identifier()) {
+		return resp, err
+	}
+	payload, err := pipeline.ApplyAfterReceive(ctx, hookInfo(e.Identifier(), auth, req, opts, false), resp.Payload)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	resp.Payload = payload
+	return resp, nil
+}
+
+func (e payloadHookExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	in, err := e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+	if err != nil || in == nil || !pipeline.HasPayloadHooks(e.Identifier()) {
+		return in, err
+	}
+	info := hookInfo(e.Identifier(), auth, req, opts, true)
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		failed := false
+		for chunk := range in {
+			// After a hook fails the rest of the stream is drained so the executor can finish.
+			if failed {
+				continue
+			}
+			if chunk.Err == nil && len(chunk.Payload) > 0 {
+				payload, errHook := pipeline.ApplyAfterReceive(ctx, info, chunk.Payload)
+				if errHook != nil {
+					chunk = cliproxyexecutor.StreamChunk{Err: errHook}
+					failed = true
+				} else {
+					chunk.Payload = payload
+				}
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				failed = true
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (e payloadHookExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return embedWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+func (e payloadHookExecutor) Speech(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return speechWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+func (e payloadHookExecutor) Transcribe(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return transcribeWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+// Unwrap returns the wrapped executor.
+func (e payloadHookExecutor) Unwrap() cliproxyauth.ProviderExecutor { return e.ProviderExecutor }
@@ -9,15 +9,22 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mtls"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
 )
 
 // newProxyAwareHTTPClient creates an HTTP client with proper proxy configuration priority:
 // 1. Use auth.ProxyURL if configured (highest priority)
-// 2. Use cfg.ProxyURL if auth proxy is not configured
-// 3. Use RoundTripper from context if neither are configured
+// 2. Use the account's proxy-pool proxy if the pool is enabled
+// 3. Use cfg.ProxyURL if neither of the above is configured
+// 4. Use RoundTripper from context if no proxy is configured
+//
+// Traffic of the client is attributed to auth for bandwidth accounting.
 //
 // Parameters:
 //   - ctx: The context containing optional RoundTripper
@@ -39,7 +46,13 @@ func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *clip
 		proxyURL = strings.TrimSpace(auth.ProxyURL)
 	}
 
-	// Priority 2: Use cfg.ProxyURL if auth proxy is not configured
+	// Priority 2: Use the proxy pool if auth proxy is not configured
+	pooled := false
+	if proxyURL == "" && auth != nil {
+		proxyURL, pooled = proxypool.Default().ProxyFor(auth.ID)
+	}
+
+	// Priority 3: Use cfg.ProxyURL if no other proxy is configured
 	if proxyURL == "" && cfg != nil {
 		proxyURL = strings.TrimSpace(cfg.ProxyURL)
 	}
@@ -49,15 +62,25 @@ func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *clip
 		transport := buildProxyTransport(proxyURL)
 		if transport != nil {
 			httpClient.Transport = transport
-			return httpClient
+			if pooled {
+				httpClient.Transport = proxypool.Default().Transport(transport, proxyURL, auth.ID)
+			}
+		} else {
+			// If proxy setup failed, log and fall through to context RoundTripper
+			log.Debugf("failed to setup proxy from URL: %s, falling back to context transport", proxyURL)
 		}
-		// If proxy setup failed, log and fall through to context RoundTripper
-		log.Debugf("failed to setup proxy from URL: %s, falling back to context transport", proxyURL)
 	}
 
-	// Priority 3: Use RoundTripper from context (typically from RoundTripperFor)
-	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
+	// Priority 4: Use RoundTripper from context (typically from RoundTripperFor)
+	if httpClient.Transport == nil {
+		if rt := requestctx.RoundTripper(ctx); rt != nil {
+			httpClient.Transport = rt
+		}
+	}
+
+	// Count the account's upstream traffic for bandwidth accounting and ceilings.
+	if auth != nil {
+		httpClient.Transport = internalusage.NewBandwidthTransport(httpClient.Transport, auth.Provider, auth.ID)
 	}
 
 	return httpClient
@@ -106,7 +129,7 @@ func buildProxyTransport(proxyURL string) *http.Transport {
 		}
 	} else if parsedURL.Scheme == "http" || parsedURL.Scheme == "https" {
 		// Configure HTTP or HTTPS proxy
-		transport = &http.Transport{Proxy: http.ProxyURL(parsedURL)}
+		transport = mtls.ApplyOutbound(&http.Transport{Proxy: http.ProxyURL(parsedURL)})
 	} else {
 		log.Errorf("unsupported proxy scheme: %s", parsedURL.Scheme)
 		return nil
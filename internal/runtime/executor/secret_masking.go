@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+)
+
+// secretMaskingExecutor wraps a provider executor and scans the client payload for credentials
+// before delegating, masking them when configured. Findings are flagged in the audit log.
+type secretMaskingExecutor struct {
+	cliproxyauth.ProviderExecutor
+}
+
+// WithSecretMasking wraps inner so that secret detection applies to its requests.
+func WithSecretMasking(inner cliproxyauth.ProviderExecutor) cliproxyauth.ProviderExecutor {
+	if inner == nil {
+		return nil
+	}
+	return secretMaskingExecutor{ProviderExecutor: inner}
+}
+
+func (e secretMaskingExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	req, opts = e.scan(ctx, req, opts)
+	return e.ProviderExecutor.Execute(ctx, auth, req, opts)
+}
+
+func (e secretMaskingExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	req, opts = e.scan(ctx, req, opts)
+	return e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+}
+
+func (e secretMaskingExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	req, opts = e.scan(ctx, req, opts)
+	return e.ProviderExecutor.CountTokens(ctx, auth, req, opts)
+}
+
+func (e secretMaskingExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	req, opts = e.scan(ctx, req, opts)
+	return embedWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+func (e secretMaskingExecutor) Speech(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	req, opts = e.scan(ctx, req, opts)
+	return speechWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+func (e secretMaskingExecutor) Transcribe(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return transcribeWith(ctx, e.ProviderExecutor, auth, req, opts)
+}
+
+// Unwrap returns the wrapped executor.
+func (e secretMaskingExecutor) Unwrap() cliproxyauth.ProviderExecutor { return e.ProviderExecutor }
+
+// scan masks secrets in the request payload and in the original request, which response
+// translators may echo back.
+func (e secretMaskingExecutor) scan(ctx context.Context, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Request, cliproxyexecutor.Options) {
+	scanner := moderation.GetSecretScanner()
+	if !scanner.Applies(e.Identifier()) {
+		return req, opts
+	}
+	payload, found := scanner.Scan(req.Payload)
+	if len(found) == 0 {
+		return req, opts
+	}
+	log.Warnf("secret detection: %v found in request to %s", found, e.Identifier())
+	if ginCtx := requestctx.Gin(ctx); ginCtx != nil {
+		var flagged []string
+		if existing, exists := ginCtx.Get(logging.SecretsDetectedKey); exists {
+			flagged, _ = existing.([]string)
+		}
+		for _, name := range found {
+			if !util.InArray(flagged, name) {
+				flagged = append(flagged, name)
+			}
+		}
+		ginCtx.Set(logging.SecretsDetectedKey, flagged)
+	}
+	req.Payload = payload
+	opts.OriginalRequest, _ = scanner.Scan(opts.OriginalRequest)
+	return req, opts
+}
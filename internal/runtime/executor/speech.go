@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// speechWith forwards a speech request to inner, which must synthesize speech.
+func speechWith(ctx context.Context, inner cliproxyauth.ProviderExecutor, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	speaker, ok := inner.(cliproxyauth.SpeechExecutor)
+	if !ok {
+		return nil, statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("%s does not support speech", inner.Identifier())}
+	}
+	return speaker.Speech(ctx, auth, req, opts)
+}
+
+// geminiVoices maps the OpenAI voice names to Gemini prebuilt voices of a similar character.
+// Other names are passed through, so Gemini voices such as "Kore" can be requested directly.
+var geminiVoices = map[string]string{
+	"alloy":   "Kore",
+	"ash":     "Orus",
+	"ballad":  "Aoede",
+	"coral":   "Leda",
+	"echo":    "Puck",
+	"fable":   "Fenrir",
+	"nova":    "Zephyr",
+	"onyx":    "Charon",
+	"sage":    "Sulafat",
+	"shimmer": "Despina",
+	"verse":   "Iapetus",
+}
+
+// geminiSpeechFormat returns the audio format a Gemini TTS request is answered in. Gemini
+// produces 16-bit PCM, which is returned as is ("pcm") or with a WAV header ("wav", the
+// default); other formats would need an encoder.
+func geminiSpeechFormat(payload []byte) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(gjson.GetBytes(payload, "response_format").String()))
+	switch format {
+	case "", "wav":
+		return "wav", nil
+	case "pcm":
+		return "pcm", nil
+	default:
+		return "", statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("response_format %q is not supported by Gemini TTS models; use wav or pcm", format)}
+	}
+}
+
+// geminiSpeechRequest converts an OpenAI audio/speech request to a Gemini generateContent
+// body asking for audio. Instructions are given to the model as a spoken-style direction.
+func geminiSpeechRequest(payload []byte) []byte {
+	root := gjson.ParseBytes(payload)
+	text := root.Get("input").String()
+	if instructions := strings.TrimSpace(root.Get("instructions").String()); instructions != "" {
+		text = instructions + ":\n" + text
+	}
+	voice := strings.TrimSpace(root.Get("voice").String())
+	if mapped, ok := geminiVoices[strings.ToLower(voice)]; ok {
+		voice = mapped
+	}
+	if voice == "" {
+		voice = "Kore"
+	}
+	body := []byte(`{"contents":[{"role":"user","parts":[{"text":""}]}],"generationConfig":{"responseModalities":["AUDIO"],"speechConfig":{"voiceConfig":{"prebuiltVoiceConfig":{"voiceName":""}}}}}`)
+	body, _ = sjson.SetBytes(body, "contents.0.parts.0.text", text)
+	body, _ = sjson.SetBytes(body, "generationConfig.speechConfig.voiceConfig.prebuiltVoiceConfig.voiceName", voice)
+	return body
+}
+
+// pcmSampleRate reads the rate parameter of a Gemini audio MIME type such as
+// "audio/L16;codec=pcm;rate=24000", defaulting to 24 kHz.
+func pcmSampleRate(mimeType string) int {
+	for _, param := range strings.Split(mimeType, ";") {
+		if value, found := strings.CutPrefix(strings.TrimSpace(param), "rate="); found {
+			if rate, err := strconv.Atoi(value); err == nil && rate > 0 {
+				return rate
+			}
+		}
+	}
+	return 24000
+}
+
+// wavHeader returns the header of a mono 16-bit PCM WAV stream. The data size is unknown
+// while streaming, so the size fields hold the maximum value, which players read as "until
+// the end of the stream".
+func wavHeader(sampleRate int) []byte {
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], 0xFFFFFFFF)
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], 1)
+	binary.LittleEndian.PutUint16(h[22:], 1)
+	binary.LittleEndian.PutUint32(h[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(h[32:], 2)
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], 0xFFFFFFFF)
+	return h
+}
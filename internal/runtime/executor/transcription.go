@@ -0,0 +1,177 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// geminiInlineAudioLimit is the largest audio file sent inline to generateContent; the
+// whole request must stay under 20 MB.
+const geminiInlineAudioLimit = 19 << 20
+
+// transcribeWith forwards a transcription request to inner, which must transcribe audio.
+func transcribeWith(ctx context.Context, inner cliproxyauth.ProviderExecutor, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	transcriber, ok := inner.(cliproxyauth.TranscriptionExecutor)
+	if !ok {
+		return cliproxyexecutor.Response{}, statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("%s does not support transcription", inner.Identifier())}
+	}
+	return transcriber.Transcribe(ctx, auth, req, opts)
+}
+
+// transcriptionAudio decodes the audio file of a transcription request.
+func transcriptionAudio(payload []byte) ([]byte, error) {
+	audio, err := base64.StdEncoding.DecodeString(gjson.GetBytes(payload, "file").String())
+	if err != nil || len(audio) == 0 {
+		return nil, statusErr{code: http.StatusBadRequest, msg: "transcription request has no audio file"}
+	}
+	return audio, nil
+}
+
+// geminiTranscriptionRequest converts a transcription request to a Gemini generateContent
+// body that returns the transcript as timed segments in JSON.
+func geminiTranscriptionRequest(payload []byte) []byte {
+	root := gjson.ParseBytes(payload)
+	instruction := "Transcribe the speech in this audio verbatim, in the language it is spoken. " +
+		"Split the transcript into segments of one or two sentences, each with its start and end time in seconds from the beginning of the audio, " +
+		"and report the spoken language as an ISO-639-1 code."
+	if language := strings.TrimSpace(root.Get("language").String()); language != "" {
+		instruction += " The audio is in " + language + "."
+	}
+	if prompt := strings.TrimSpace(root.Get("prompt").String()); prompt != "" {
+		instruction += " Use this context for spelling and style: " + prompt
+	}
+	mimeType := root.Get("mime_type").String()
+	if mimeType == "" {
+		mimeType = "audio/mpeg"
+	}
+	body := []byte(`{"contents":[{"role":"user","parts":[{"text":""},{"inlineData":{"mimeType":"","data":""}}]}],"generationConfig":{"responseMimeType":"application/json","responseSchema":{"type":"OBJECT","properties":{"language":{"type":"STRING"},"segments":{"type":"ARRAY","items":{"type":"OBJECT","properties":{"start":{"type":"NUMBER"},"end":{"type":"NUMBER"},"text":{"type":"STRING"}},"required":["start","end","text"]}}},"required":["language","segments"]}}}`)
+	body, _ = sjson.SetBytes(body, "contents.0.parts.0.text", instruction)
+	body, _ = sjson.SetBytes(body, "contents.0.parts.1.inlineData.mimeType", mimeType)
+	body, _ = sjson.SetBytes(body, "contents.0.parts.1.inlineData.data", root.Get("file").String())
+	if temperature := root.Get("temperature"); temperature.Exists() {
+		body, _ = sjson.SetBytes(body, "generationConfig.temperature", temperature.Float())
+	}
+	return body
+}
+
+// openAITranscriptionFromGemini builds a verbose_json transcription from the JSON segments
+// a Gemini model returned for geminiTranscriptionRequest.
+func openAITranscriptionFromGemini(data []byte) ([]byte, error) {
+	var text strings.Builder
+	for _, part := range gjson.GetBytes(data, "candidates.0.content.parts").Array() {
+		text.WriteString(part.Get("text").String())
+	}
+	result := gjson.Parse(text.String())
+	if !result.IsObject() {
+		return nil, statusErr{code: http.StatusBadGateway, msg: "gemini returned no transcript"}
+	}
+	out := []byte(`{"task":"transcribe","language":"","duration":0,"text":"","segments":[]}`)
+	out, _ = sjson.SetBytes(out, "language", result.Get("language").String())
+	texts := make([]string, 0)
+	duration := 0.0
+	for i, segment := range result.Get("segments").Array() {
+		segmentText := strings.TrimSpace(segment.Get("text").String())
+		if segmentText == "" {
+			continue
+		}
+		start, end := segment.Get("start").Float(), segment.Get("end").Float()
+		if end < start {
+			end = start
+		}
+		entry := []byte(`{}`)
+		entry, _ = sjson.SetBytes(entry, "id", i)
+		entry, _ = sjson.SetBytes(entry, "start", start)
+		entry, _ = sjson.SetBytes(entry, "end", end)
+		entry, _ = sjson.SetBytes(entry, "text", segmentText)
+		out, _ = sjson.SetRawBytes(out, "segments.-1", entry)
+		texts = append(texts, segmentText)
+		duration = max(duration, end)
+	}
+	out, _ = sjson.SetBytes(out, "duration", duration)
+	out, _ = sjson.SetBytes(out, "text", strings.Join(texts, " "))
+	return out, nil
+}
+
+// openAITranscriptionForm builds the multipart body of an OpenAI audio/transcriptions
+// request. Timed segments are requested (verbose_json) when the client asked for a format
+// that needs them; otherwise the plain json format is used, which every model supports.
+func openAITranscriptionForm(model string, payload []byte) ([]byte, string, error) {
+	root := gjson.ParseBytes(payload)
+	audio, err := transcriptionAudio(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	filename := root.Get("filename").String()
+	if filename == "" {
+		filename = "audio"
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filename))
+	if mimeType := root.Get("mime_type").String(); mimeType != "" {
+		header.Set("Content-Type", mimeType)
+	} else {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = part.Write(audio); err != nil {
+		return nil, "", err
+	}
+	format := "json"
+	switch root.Get("response_format").String() {
+	case "verbose_json", "srt", "vtt":
+		format = "verbose_json"
+	}
+	fields := [][2]string{{"model", model}, {"response_format", format}}
+	for _, key := range []string{"language", "prompt", "temperature"} {
+		if value := root.Get(key); value.Exists() && value.String() != "" {
+			fields = append(fields, [2]string{key, value.String()})
+		}
+	}
+	if format == "verbose_json" {
+		fields = append(fields, [2]string{"timestamp_granularities[]", "segment"})
+	}
+	for _, field := range fields {
+		if err = w.WriteField(field[0], field[1]); err != nil {
+			return nil, "", err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// normalizeOpenAITranscription completes a json or verbose_json transcription response
+// with the verbose_json fields it lacks.
+func normalizeOpenAITranscription(data []byte, payload []byte) []byte {
+	out := data
+	if !gjson.GetBytes(out, "task").Exists() {
+		out, _ = sjson.SetBytes(out, "task", "transcribe")
+	}
+	if !gjson.GetBytes(out, "language").Exists() {
+		out, _ = sjson.SetBytes(out, "language", gjson.GetBytes(payload, "language").String())
+	}
+	if !gjson.GetBytes(out, "duration").Exists() {
+		out, _ = sjson.SetBytes(out, "duration", 0)
+	}
+	if !gjson.GetBytes(out, "segments").Exists() {
+		out, _ = sjson.SetRawBytes(out, "segments", []byte(`[]`))
+	}
+	return out
+}
@@ -3,13 +3,16 @@ package executor
 import (
 	"bytes"
 	"context"
-	"fmt"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	"github.com/tidwall/gjson"
 )
 
@@ -17,9 +20,17 @@ type usageReporter struct {
 	provider    string
 	model       string
 	authID      string
+	authLabel   string
 	apiKey      string
 	requestedAt time.Time
 	once        sync.Once
+
+	// streaming defers publication until trackStream observes the end of the stream,
+	// so the record can carry the final outcome and delivered byte count.
+	streaming bool
+	mu        sync.Mutex
+	pending   *usage.Detail
+	delivered atomic.Int64
 }
 
 func newUsageReporter(ctx context.Context, provider, model string, auth *cliproxyauth.Auth) *usageReporter {
@@ -30,6 +41,7 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 	}
 	if auth != nil {
 		reporter.authID = auth.ID
+		reporter.authLabel = auth.Label
 	}
 	reporter.apiKey = apiKeyFromContext(ctx)
 	return reporter
@@ -48,37 +60,124 @@ func (r *usageReporter) publish(ctx context.Context, detail usage.Detail) {
 	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 {
 		return
 	}
+	r.mu.Lock()
+	if r.streaming {
+		r.pending = &detail
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+	r.emit(ctx, detail, usage.OutcomeCompleted)
+}
+
+// trackFailure records a failed or cancelled non-streaming request. It is meant to be
+// deferred with a pointer to the caller's named error result.
+func (r *usageReporter) trackFailure(ctx context.Context, errPtr *error) {
+	if r == nil || errPtr == nil || *errPtr == nil {
+		return
+	}
+	r.emit(ctx, usage.Detail{}, outcomeFor(ctx))
+}
+
+// trackStream forwards chunks from in, counting delivered bytes, and publishes a single
+// record once the stream ends. If the request context is cancelled while chunks are still
+// pending, the remaining chunks are drained so the producer goroutine can exit.
+func (r *usageReporter) trackStream(ctx context.Context, in <-chan cliproxyexecutor.StreamChunk) <-chan cliproxyexecutor.StreamChunk {
+	if r == nil {
+		return in
+	}
+	r.mu.Lock()
+	r.streaming = true
+	r.mu.Unlock()
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		outcome := usage.OutcomeCompleted
+		cancelled := false
+		for chunk := range in {
+			if cancelled {
+				continue
+			}
+			if chunk.Err != nil {
+				outcome = usage.OutcomeUpstreamFailed
+			}
+			select {
+			case out <- chunk:
+				r.delivered.Add(int64(len(chunk.Payload)))
+			case <-ctx.Done():
+				cancelled = true
+			}
+		}
+		if cancelled || (outcome == usage.OutcomeCompleted && ctx.Err() != nil) {
+			outcome = usage.OutcomeClientCancelled
+		}
+		r.mu.Lock()
+		pending := r.pending
+		r.mu.Unlock()
+		if pending != nil {
+			r.emit(ctx, *pending, outcome)
+		} else if outcome != usage.OutcomeCompleted {
+			r.emit(ctx, usage.Detail{}, outcome)
+		}
+	}()
+	return out
+}
+
+func (r *usageReporter) emit(ctx context.Context, detail usage.Detail, outcome string) {
 	r.once.Do(func() {
+		r.attachRequestUsage(ctx, detail, outcome)
 		usage.PublishRecord(ctx, usage.Record{
-			Provider:    r.provider,
-			Model:       r.model,
-			APIKey:      r.apiKey,
-			AuthID:      r.authID,
-			RequestedAt: r.requestedAt,
-			Detail:      detail,
+			Provider:       r.provider,
+			Model:          r.model,
+			APIKey:         r.apiKey,
+			AuthID:         r.authID,
+			RequestedAt:    r.requestedAt,
+			Detail:         detail,
+			Outcome:        outcome,
+			DeliveredBytes: r.delivered.Load(),
 		})
 	})
 }
 
-func apiKeyFromContext(ctx context.Context) string {
+// attachRequestUsage appends this attempt's usage to the Gin context so request-scoped
+// middleware (audit log, quota accounting) can see tokens and the serving account.
+func (r *usageReporter) attachRequestUsage(ctx context.Context, detail usage.Detail, outcome string) {
 	if ctx == nil {
-		return ""
-	}
-	ginCtx, ok := ctx.Value("gin").(*gin.Context)
-	if !ok || ginCtx == nil {
-		return ""
-	}
-	if v, exists := ginCtx.Get("apiKey"); exists {
-		switch value := v.(type) {
-		case string:
-			return value
-		case fmt.Stringer:
-			return value.String()
-		default:
-			return fmt.Sprintf("%v", value)
-		}
+		return
+	}
+	ginCtx := requestctx.Gin(ctx)
+	if ginCtx == nil {
+		return
 	}
-	return ""
+	entry := logging.RequestUsage{
+		Provider:        r.provider,
+		Model:           r.model,
+		AuthID:          r.authID,
+		AuthLabel:       r.authLabel,
+		Outcome:         outcome,
+		InputTokens:     detail.InputTokens,
+		OutputTokens:    detail.OutputTokens,
+		ReasoningTokens: detail.ReasoningTokens,
+		CachedTokens:    detail.CachedTokens,
+		TotalTokens:     detail.TotalTokens,
+	}
+	var entries []logging.RequestUsage
+	if existing, exists := ginCtx.Get(logging.RequestUsageKey); exists {
+		entries, _ = existing.([]logging.RequestUsage)
+	}
+	ginCtx.Set(logging.RequestUsageKey, append(entries, entry))
+}
+
+// outcomeFor classifies a failed request by whether the caller's context was cancelled.
+func outcomeFor(ctx context.Context) string {
+	if ctx != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return usage.OutcomeClientCancelled
+	}
+	return usage.OutcomeUpstreamFailed
+}
+
+func apiKeyFromContext(ctx context.Context) string {
+	return requestctx.Tenant(ctx)
 }
 
 func parseCodexUsage(data []byte) (usage.Detail, bool) {
@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// vendorExtensionField is the request field (top level or per message) carrying proxy-only
+// options such as message pinning.
+const vendorExtensionField = "x_cliproxy"
+
+// stripVendorExtensions returns a copy of payload without vendor extension fields; upstream
+// APIs reject them as unknown input.
+func stripVendorExtensions(payload []byte) []byte {
+	out := bytes.Clone(payload)
+	if !bytes.Contains(out, []byte(vendorExtensionField)) {
+		return out
+	}
+	out, _ = sjson.DeleteBytes(out, vendorExtensionField)
+	for _, path := range []string{"messages", "contents", "input"} {
+		items := gjson.GetBytes(out, path)
+		if !items.IsArray() {
+			continue
+		}
+		for i := len(items.Array()) - 1; i >= 0; i-- {
+			field := path + "." + strconv.Itoa(i) + "." + vendorExtensionField
+			if gjson.GetBytes(out, field).Exists() {
+				out, _ = sjson.DeleteBytes(out, field)
+			}
+		}
+	}
+	return out
+}
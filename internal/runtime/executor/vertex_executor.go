@@ -0,0 +1,295 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// vertexScope is the OAuth scope of the Vertex AI API.
+const vertexScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// VertexExecutor is a stateless executor for the Gemini models on Vertex AI. Requests use the
+// Gemini API format and are authorized with access tokens minted from a service account key.
+type VertexExecutor struct {
+	cfg *config.Config
+}
+
+func NewVertexExecutor(cfg *config.Config) *VertexExecutor { return &VertexExecutor{cfg: cfg} }
+
+func (e *VertexExecutor) Identifier() string { return "vertex" }
+
+func (e *VertexExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
+
+func (e *VertexExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("gemini")
+	body := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), false)
+	body, _ = sjson.DeleteBytes(body, "session_id")
+
+	query := ""
+	if opts.Alt != "" {
+		query = "$alt=" + opts.Alt
+	}
+	body, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, body)
+	if errHook != nil {
+		return cliproxyexecutor.Response{}, errHook
+	}
+	httpReq, err := e.newRequest(ctx, auth, req.Model, "generateContent", query, body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	recordAPIRequest(ctx, e.cfg, body)
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
+		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(b)}
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	reporter.publish(ctx, parseGeminiUsage(data))
+	var param any
+	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
+	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
+}
+
+func (e *VertexExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ <-chan cliproxyexecutor.StreamChunk, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("gemini")
+	body := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), true)
+	body, _ = sjson.DeleteBytes(body, "session_id")
+
+	query := "alt=sse"
+	if opts.Alt != "" {
+		query = "$alt=" + opts.Alt
+	}
+	body, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, body)
+	if errHook != nil {
+		return nil, errHook
+	}
+	httpReq, err := e.newRequest(ctx, auth, req.Model, "streamGenerateContent", query, body)
+	if err != nil {
+		return nil, err
+	}
+	recordAPIRequest(ctx, e.cfg, body)
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		b, _ := io.ReadAll(resp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
+		return nil, statusErr{code: resp.StatusCode, msg: string(b)}
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer func() { _ = resp.Body.Close() }()
+		scanner := bufio.NewScanner(resp.Body)
+		buf := make([]byte, 1024*1024)
+		scanner.Buffer(buf, 1024*1024)
+		var param any
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			appendAPIResponseChunk(ctx, e.cfg, line)
+			if detail, ok := parseGeminiStreamUsage(line); ok {
+				reporter.publish(ctx, detail)
+			}
+			lines := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			for i := range lines {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(lines[i])}
+			}
+		}
+		lines := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone([]byte("[DONE]")), &param)
+		for i := range lines {
+			out <- cliproxyexecutor.StreamChunk{Payload: []byte(lines[i])}
+		}
+		if err = scanner.Err(); err != nil {
+			out <- cliproxyexecutor.StreamChunk{Err: err}
+		}
+	}()
+	return reporter.trackStream(ctx, out), nil
+}
+
+func (e *VertexExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("gemini")
+	translatedReq := sdktranslator.TranslateRequest(from, to, req.Model, stripVendorExtensions(req.Payload), false)
+	respCtx := requestctx.WithAlt(ctx, opts.Alt)
+	translatedReq, _ = sjson.DeleteBytes(translatedReq, "session_id")
+	translatedReq, _ = sjson.DeleteBytes(translatedReq, "generationConfig")
+
+	translatedReq, errHook := applyBeforeSendHooks(ctx, e.Identifier(), auth, req, opts, translatedReq)
+	if errHook != nil {
+		return cliproxyexecutor.Response{}, errHook
+	}
+	httpReq, err := e.newRequest(ctx, auth, req.Model, "countTokens", "", translatedReq)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	recordAPIRequest(ctx, e.cfg, translatedReq)
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
+		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(data)}
+	}
+
+	count := gjson.GetBytes(data, "totalTokens").Int()
+	translated := sdktranslator.TranslateTokenCount(respCtx, to, from, count, data)
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
+}
+
+// Refresh is a no-op: access tokens are minted from the service account key on demand.
+func (e *VertexExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	log.Debugf("vertex executor: refresh called")
+	_ = ctx
+	return auth, nil
+}
+
+// newRequest builds an authorized request for the model action, e.g. generateContent.
+func (e *VertexExecutor) newRequest(ctx context.Context, auth *cliproxyauth.Auth, model, action, query string, body []byte) (*http.Request, error) {
+	creds, project, location, err := e.credentials(auth)
+	if err != nil {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: err.Error()}
+	}
+	tok, err := creds.TokenSource.Token()
+	if err != nil {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: fmt.Sprintf("vertex: failed to obtain access token: %v", err)}
+	}
+	host := "aiplatform.googleapis.com"
+	if location != "global" {
+		host = location + "-" + host
+	}
+	url := fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s", host, project, location, model, action)
+	if query != "" {
+		url += "?" + query
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	return httpReq, nil
+}
+
+// vertexCredentials caches the parsed service account keys, whose token sources reuse access
+// tokens until they expire. Entries are keyed by file, modification time and proxy, so a
+// rotated key file is picked up without a restart.
+var vertexCredentials = struct {
+	sync.Mutex
+	byKey map[string]*google.Credentials
+}{byKey: make(map[string]*google.Credentials)}
+
+// credentials returns the service account credentials of auth with the project and location
+// requests are sent to.
+func (e *VertexExecutor) credentials(auth *cliproxyauth.Auth) (*google.Credentials, string, string, error) {
+	if auth == nil || auth.Attributes == nil || strings.TrimSpace(auth.Attributes["credentials_file"]) == "" {
+		return nil, "", "", fmt.Errorf("vertex: missing service account credentials file")
+	}
+	path := auth.Attributes["credentials_file"]
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("vertex: %w", err)
+	}
+	key := fmt.Sprintf("%s|%d|%s", path, info.ModTime().UnixNano(), auth.ProxyURL)
+
+	vertexCredentials.Lock()
+	creds, ok := vertexCredentials.byKey[key]
+	vertexCredentials.Unlock()
+	if !ok {
+		data, errRead := authcrypt.ReadFile(path)
+		if errRead != nil {
+			return nil, "", "", fmt.Errorf("vertex: %w", errRead)
+		}
+		// Token requests outlive any single request, so they are bound to a background context.
+		tokenCtx := context.WithValue(context.Background(), oauth2.HTTPClient, e.tokenHTTPClient(auth))
+		creds, err = google.CredentialsFromJSON(tokenCtx, data, vertexScope)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("vertex: invalid service account key %s: %w", path, err)
+		}
+		vertexCredentials.Lock()
+		for k := range vertexCredentials.byKey {
+			if strings.HasPrefix(k, path+"|") {
+				delete(vertexCredentials.byKey, k)
+			}
+		}
+		vertexCredentials.byKey[key] = creds
+		vertexCredentials.Unlock()
+	}
+
+	project := strings.TrimSpace(auth.Attributes["project_id"])
+	if project == "" {
+		project = creds.ProjectID
+	}
+	if project == "" {
+		return nil, "", "", fmt.Errorf("vertex: no project id configured or found in %s", path)
+	}
+	location := strings.TrimSpace(auth.Attributes["location"])
+	if location == "" {
+		location = "us-central1"
+	}
+	return creds, project, location, nil
+}
+
+// tokenHTTPClient returns the client access tokens are requested with, honouring the proxy of
+// auth before the global one.
+func (e *VertexExecutor) tokenHTTPClient(auth *cliproxyauth.Auth) *http.Client {
+	if auth != nil && strings.TrimSpace(auth.ProxyURL) != "" {
+		if transport := buildProxyTransport(strings.TrimSpace(auth.ProxyURL)); transport != nil {
+			return &http.Client{Transport: transport}
+		}
+	}
+	if e.cfg == nil {
+		return &http.Client{}
+	}
+	return util.SetProxy(&e.cfg.SDKConfig, &http.Client{})
+}
@@ -0,0 +1,272 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// awsBackend keeps secrets in AWS Secrets Manager, one secret per name with the JSON object
+// as its secret string. Requests use the JSON protocol signed with Signature Version 4.
+type awsBackend struct {
+	client   *http.Client
+	endpoint *url.URL
+	region   string
+	prefix   string
+	kmsKeyID string
+	keyID    string
+	secret   string
+	token    string
+}
+
+func newAWSBackend(cfg config.AuthStoreAWSConfig, prefix string) (*awsBackend, error) {
+	region := firstNonEmpty(cfg.Region, "AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		return nil, errors.New("aws-secrets-manager: region is required (auth-store.aws.region or AWS_REGION)")
+	}
+	keyID := firstNonEmpty(cfg.AccessKeyID, "AWS_ACCESS_KEY_ID")
+	secret := firstNonEmpty(cfg.SecretAccessKey, "AWS_SECRET_ACCESS_KEY")
+	if keyID == "" || secret == "" {
+		return nil, errors.New("aws-secrets-manager: credentials are required (auth-store.aws.access-key-id and secret-access-key, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+	endpoint := strings.TrimSpace(cfg.Endpoint)
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("aws-secrets-manager: invalid endpoint %q", endpoint)
+	}
+	token := cfg.SessionToken
+	if cfg.AccessKeyID == "" {
+		token = firstNonEmpty("", "AWS_SESSION_TOKEN")
+	}
+	return &awsBackend{
+		client:   &http.Client{Timeout: requestTimeout},
+		endpoint: u,
+		region:   region,
+		prefix:   prefix,
+		kmsKeyID: strings.TrimSpace(cfg.KMSKeyID),
+		keyID:    keyID,
+		secret:   secret,
+		token:    token,
+	}, nil
+}
+
+// awsError is the error body of the JSON protocol. Services spell the message field either
+// "message" or "Message"; decoding matches both.
+type awsError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *awsError) Error() string {
+	return fmt.Sprintf("aws-secrets-manager: %s: %s", e.code(), e.Message)
+}
+
+// code strips the namespace some services put in front of the error type.
+func (e *awsError) code() string {
+	if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+		return e.Type[i+1:]
+	}
+	return e.Type
+}
+
+func (b *awsBackend) List(ctx context.Context) ([]string, error) {
+	var names []string
+	nextToken := ""
+	for {
+		input := map[string]any{
+			"MaxResults": 100,
+			"Filters":    []map[string]any{{"Key": "name", "Values": []string{b.prefix}}},
+		}
+		if nextToken != "" {
+			input["NextToken"] = nextToken
+		}
+		var out struct {
+			SecretList []struct {
+				Name string `json:"Name"`
+			} `json:"SecretList"`
+			NextToken string `json:"NextToken"`
+		}
+		if err := b.call(ctx, "ListSecrets", input, &out); err != nil {
+			return nil, err
+		}
+		for _, s := range out.SecretList {
+			// The name filter matches prefixes of any word in the name; keep true prefixes only.
+			if name, ok := strings.CutPrefix(s.Name, b.prefix); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+		if out.NextToken == "" {
+			return names, nil
+		}
+		nextToken = out.NextToken
+	}
+}
+
+func (b *awsBackend) Get(ctx context.Context, name string) ([]byte, error) {
+	var out struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := b.call(ctx, "GetSecretValue", map[string]any{"SecretId": b.prefix + name}, &out); err != nil {
+		return nil, err
+	}
+	if out.SecretString == nil {
+		return nil, fmt.Errorf("aws-secrets-manager: secret %s has no string value", b.prefix+name)
+	}
+	return []byte(*out.SecretString), nil
+}
+
+func (b *awsBackend) Put(ctx context.Context, name string, data []byte) error {
+	input := map[string]any{
+		"SecretId":           b.prefix + name,
+		"SecretString":       string(data),
+		"ClientRequestToken": uuid.NewString(),
+	}
+	err := b.call(ctx, "PutSecretValue", input, nil)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	create := map[string]any{
+		"Name":               b.prefix + name,
+		"SecretString":       string(data),
+		"ClientRequestToken": uuid.NewString(),
+	}
+	if b.kmsKeyID != "" {
+		create["KmsKeyId"] = b.kmsKeyID
+	}
+	return b.call(ctx, "CreateSecret", create, nil)
+}
+
+// Delete removes the secret without the default recovery window, so that an account
+// deleted by mistake can be added again under the same name right away.
+func (b *awsBackend) Delete(ctx context.Context, name string) error {
+	input := map[string]any{"SecretId": b.prefix + name, "ForceDeleteWithoutRecovery": true}
+	if err := b.call(ctx, "DeleteSecret", input, nil); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (b *awsBackend) Location(name string) string {
+	return "aws-secrets-manager:" + b.region + "/" + b.prefix + name
+}
+
+// call invokes action with input and decodes the response into out when it is not nil.
+// ResourceNotFoundException maps to ErrNotFound.
+func (b *awsBackend) call(ctx context.Context, action string, input, out any) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	u := *b.endpoint
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager."+action)
+	b.sign(req, body, time.Now())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("aws-secrets-manager: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("aws-secrets-manager: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &awsError{}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Type == "" {
+			return fmt.Errorf("aws-secrets-manager: %s: %d %s", action, resp.StatusCode, strings.TrimSpace(string(data)))
+		}
+		if apiErr.code() == "ResourceNotFoundException" {
+			return fmt.Errorf("%w: %s", ErrNotFound, apiErr.Error())
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("aws-secrets-manager: invalid %s response: %w", action, err)
+	}
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 for the secretsmanager service to req.
+func (b *awsBackend) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if b.token != "" {
+		req.Header.Set("X-Amz-Security-Token", b.token)
+	}
+	headers := map[string]string{
+		"content-type": req.Header.Get("Content-Type"),
+		"host":         req.URL.Host,
+		"x-amz-date":   amzDate,
+		"x-amz-target": req.Header.Get("X-Amz-Target"),
+	}
+	if b.token != "" {
+		headers["x-amz-security-token"] = b.token
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, "", canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.region + "/secretsmanager/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := hmacSHA256([]byte("AWS4"+b.secret), date)
+	key = hmacSHA256(key, b.region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+b.keyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,75 @@
+// Package secrets reads and writes JSON secrets in an external secrets backend, such as
+// HashiCorp Vault or AWS Secrets Manager, so that auth tokens need not live on local disk.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	// TypeFile keeps auth tokens as files in auth-dir; it has no backend.
+	TypeFile = "file"
+	// TypeVault keeps auth tokens in a HashiCorp Vault KV version 2 engine.
+	TypeVault = "vault"
+	// TypeAWSSecretsManager keeps auth tokens in AWS Secrets Manager.
+	TypeAWSSecretsManager = "aws-secrets-manager"
+
+	defaultPrefix  = "cliproxy/auth/"
+	requestTimeout = 30 * time.Second
+)
+
+// ErrNotFound is returned for secrets that do not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Backend stores JSON objects under names. Names are relative to the configured prefix.
+type Backend interface {
+	// List returns the names of all secrets under the prefix.
+	List(ctx context.Context) ([]string, error)
+	// Get returns the JSON object stored under name, or ErrNotFound.
+	Get(ctx context.Context, name string) ([]byte, error)
+	// Put creates or replaces the secret name with the JSON object data.
+	Put(ctx context.Context, name string, data []byte) error
+	// Delete removes the secret name; deleting a missing secret is not an error.
+	Delete(ctx context.Context, name string) error
+	// Location describes where the secret name is kept, for logs and messages.
+	Location(name string) string
+}
+
+// New returns the backend selected by cfg, or nil when auth tokens stay in files.
+func New(cfg config.AuthStoreConfig) (Backend, error) {
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Type)) {
+	case "", TypeFile:
+		return nil, nil
+	case TypeVault:
+		return newVaultBackend(cfg.Vault, prefix)
+	case TypeAWSSecretsManager:
+		return newAWSBackend(cfg.AWS, prefix)
+	default:
+		return nil, fmt.Errorf("unknown auth-store type %q", cfg.Type)
+	}
+}
+
+// firstNonEmpty returns the first value that is not blank after trimming, or the first
+// environment variable among envs that is set.
+func firstNonEmpty(value string, envs ...string) string {
+	if v := strings.TrimSpace(value); v != "" {
+		return v
+	}
+	for _, env := range envs {
+		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// vaultBackend keeps secrets in a Vault KV version 2 engine, one secret per name, with the
+// JSON object as the secret's data so that its fields show up in the Vault UI.
+type vaultBackend struct {
+	client    *http.Client
+	address   *url.URL
+	token     string
+	namespace string
+	mount     string
+	prefix    string
+}
+
+func newVaultBackend(cfg config.AuthStoreVaultConfig, prefix string) (*vaultBackend, error) {
+	address := firstNonEmpty(cfg.Address, "VAULT_ADDR")
+	if address == "" {
+		return nil, errors.New("vault: address is required (auth-store.vault.address or VAULT_ADDR)")
+	}
+	u, err := url.Parse(strings.TrimSuffix(address, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("vault: invalid address %q", address)
+	}
+	token := firstNonEmpty(cfg.Token, "VAULT_TOKEN")
+	if token == "" {
+		return nil, errors.New("vault: token is required (auth-store.vault.token or VAULT_TOKEN)")
+	}
+	mount := strings.Trim(cfg.Mount, "/")
+	if mount == "" {
+		mount = "secret"
+	}
+	return &vaultBackend{
+		client:    &http.Client{Timeout: requestTimeout},
+		address:   u,
+		token:     token,
+		namespace: firstNonEmpty(cfg.Namespace, "VAULT_NAMESPACE"),
+		mount:     mount,
+		prefix:    strings.TrimPrefix(prefix, "/"),
+	}, nil
+}
+
+func (b *vaultBackend) List(ctx context.Context) ([]string, error) {
+	resp, err := b.do(ctx, "LIST", "metadata", strings.TrimSuffix(b.prefix, "/"), nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var out struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("vault: invalid list response: %w", err)
+	}
+	names := make([]string, 0, len(out.Data.Keys))
+	for _, key := range out.Data.Keys {
+		// Keys ending in "/" are folders below the prefix.
+		if !strings.HasSuffix(key, "/") {
+			names = append(names, key)
+		}
+	}
+	return names, nil
+}
+
+func (b *vaultBackend) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, "data", b.prefix+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var out struct {
+		Data struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("vault: invalid read response: %w", err)
+	}
+	// A deleted latest version reads as null data.
+	if len(out.Data.Data) == 0 || string(out.Data.Data) == "null" {
+		return nil, ErrNotFound
+	}
+	return out.Data.Data, nil
+}
+
+func (b *vaultBackend) Put(ctx context.Context, name string, data []byte) error {
+	body, err := json.Marshal(map[string]json.RawMessage{"data": data})
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(ctx, http.MethodPost, "data", b.prefix+name, body)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (b *vaultBackend) Delete(ctx context.Context, name string) error {
+	resp, err := b.do(ctx, http.MethodDelete, "metadata", b.prefix+name, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (b *vaultBackend) Location(name string) string {
+	return "vault:" + b.mount + "/" + b.prefix + name
+}
+
+// do sends a request to the KV API section kind ("data" or "metadata") for path. Responses
+// other than 2xx are closed and returned as errors; 404 maps to ErrNotFound.
+func (b *vaultBackend) do(ctx context.Context, method, kind, path string, body []byte) (*http.Response, error) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	u := *b.address
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/" + b.mount + "/" + kind + "/" + strings.Join(segments, "/")
+	u.RawPath = ""
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", b.token)
+	if b.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", b.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	return nil, fmt.Errorf("vault: %s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
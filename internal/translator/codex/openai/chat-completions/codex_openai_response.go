@@ -8,8 +8,8 @@ package chat_completions
 import (
 	"bytes"
 	"context"
-	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -164,14 +164,14 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 //
 // Returns:
 //   - string: An OpenAI-compatible JSON response containing all message content and metadata
-func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+func ConvertCodexResponseToOpenAINonStream(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	rootResult := gjson.ParseBytes(rawJSON)
 	// Verify this is a response.completed event
 	if rootResult.Get("type").String() != "response.completed" {
 		return ""
 	}
 
-	unixTimestamp := time.Now().Unix()
+	unixTimestamp := idgen.Now(ctx).Unix()
 
 	responseResult := rootResult.Get("response")
 
@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -30,7 +31,7 @@ import (
 // Returns:
 //   - []string: The transformed request data in Gemini API format
 func ConvertGeminiCliRequestToGemini(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) []string {
-	if alt, ok := ctx.Value("alt").(string); ok {
+	if alt, ok := requestctx.Alt(ctx); ok {
 		var chunk []byte
 		if alt == "" {
 			responseResult := gjson.GetBytes(rawJSON, "response")
@@ -0,0 +1,21 @@
+package claude
+
+import (
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	geminiClaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/claude"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
+)
+
+func init() {
+	translator.Register(
+		Claude,
+		GeminiWeb,
+		geminiClaude.ConvertClaudeRequestToGemini,
+		interfaces.TranslateResponse{
+			Stream:     geminiClaude.ConvertGeminiResponseToClaude,
+			NonStream:  geminiClaude.ConvertGeminiResponseToClaudeNonStream,
+			TokenCount: geminiClaude.ClaudeTokenCount,
+		},
+	)
+}
@@ -0,0 +1,48 @@
+package chat_completions
+
+import (
+	"bytes"
+
+	geminiChat "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ConvertOpenAIRequestToGeminiWeb converts an OpenAI chat request with the Gemini translator.
+// Tool calls are emulated in the Gemini Web prompt, so an assistant turn that carries text
+// alongside tool_calls is split first; the Gemini translator would otherwise keep only the text
+// and the model would lose track of the calls it already made.
+func ConvertOpenAIRequestToGeminiWeb(modelName string, inputRawJSON []byte, stream bool) []byte {
+	return geminiChat.ConvertOpenAIRequestToGemini(modelName, splitAssistantToolCalls(inputRawJSON), stream)
+}
+
+// splitAssistantToolCalls rewrites every assistant message with both content and tool_calls into
+// a text-only message followed by a tool_calls-only message.
+func splitAssistantToolCalls(rawJSON []byte) []byte {
+	messages := gjson.GetBytes(rawJSON, "messages")
+	if !messages.IsArray() {
+		return rawJSON
+	}
+	split := false
+	out := []byte(`[]`)
+	for _, m := range messages.Array() {
+		content := m.Get("content")
+		if m.Get("role").String() != "assistant" || !m.Get("tool_calls").IsArray() || !content.Exists() || content.Type == gjson.Null {
+			out, _ = sjson.SetRawBytes(out, "-1", []byte(m.Raw))
+			continue
+		}
+		split = true
+		text, _ := sjson.DeleteBytes([]byte(m.Raw), "tool_calls")
+		calls, _ := sjson.SetRawBytes([]byte(m.Raw), "content", []byte("null"))
+		out, _ = sjson.SetRawBytes(out, "-1", text)
+		out, _ = sjson.SetRawBytes(out, "-1", calls)
+	}
+	if !split {
+		return rawJSON
+	}
+	result, err := sjson.SetRawBytes(bytes.Clone(rawJSON), "messages", out)
+	if err != nil {
+		return rawJSON
+	}
+	return result
+}
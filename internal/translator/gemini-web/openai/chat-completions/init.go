@@ -11,7 +11,7 @@ func init() {
 	translator.Register(
 		OpenAI,
 		GeminiWeb,
-		geminiChat.ConvertOpenAIRequestToGemini,
+		ConvertOpenAIRequestToGeminiWeb,
 		interfaces.TranslateResponse{
 			Stream:    geminiChat.ConvertGeminiResponseToOpenAI,
 			NonStream: geminiChat.ConvertGeminiResponseToOpenAINonStream,
@@ -0,0 +1,98 @@
+package chat_completions
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/tidwall/gjson"
+)
+
+// InlineData extracts the MIME type and base64 payload of an OpenAI content item carrying
+// a file: image_url / input_image data URLs, file / input_file data (a data URL or bare
+// base64 typed by the file name extension or content) and input_audio. ok is false for
+// items without inline data, such as remote URLs or uploaded file IDs.
+func InlineData(item gjson.Result) (mime string, data string, ok bool) {
+	switch item.Get("type").String() {
+	case "image_url":
+		return parseDataURL(item.Get("image_url.url").String())
+	case "input_image":
+		url := item.Get("image_url")
+		if url.IsObject() {
+			url = url.Get("url")
+		}
+		return parseDataURL(url.String())
+	case "file", "input_file":
+		file := item
+		if item.Get("file").IsObject() {
+			file = item.Get("file")
+		}
+		fileData := file.Get("file_data").String()
+		if mime, data, ok = parseDataURL(fileData); ok {
+			return mime, data, true
+		}
+		if fileData == "" {
+			return "", "", false
+		}
+		if mime = mimeFromFilename(file.Get("filename").String()); mime == "" {
+			mime = sniffBase64(fileData)
+		}
+		return mime, fileData, mime != ""
+	case "input_audio":
+		data = item.Get("input_audio.data").String()
+		if data == "" {
+			return "", "", false
+		}
+		format := strings.ToLower(item.Get("input_audio.format").String())
+		switch format {
+		case "mp3":
+			mime = "audio/mpeg"
+		case "wav":
+			mime = "audio/wav"
+		default:
+			mime = misc.MimeTypes[format]
+		}
+		return mime, data, true
+	}
+	return "", "", false
+}
+
+// parseDataURL splits a base64 data URL into its MIME type and payload.
+func parseDataURL(url string) (string, string, bool) {
+	rest, found := strings.CutPrefix(url, "data:")
+	if !found {
+		return "", "", false
+	}
+	mime, data, found := strings.Cut(rest, ";base64,")
+	if !found || data == "" {
+		return "", "", false
+	}
+	return mime, data, true
+}
+
+// mimeFromFilename returns the MIME type of a file name extension, or "" when unknown.
+func mimeFromFilename(name string) string {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return ""
+	}
+	return misc.MimeTypes[strings.ToLower(name[i+1:])]
+}
+
+// sniffBase64 detects the MIME type of a base64 payload from its first bytes, returning ""
+// when the content is not recognised.
+func sniffBase64(data string) string {
+	if len(data) > 684 {
+		data = data[:684]
+	}
+	head, err := base64.StdEncoding.DecodeString(data[:len(data)/4*4])
+	if err != nil || len(head) == 0 {
+		return ""
+	}
+	mime, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	if mime == "application/octet-stream" {
+		return ""
+	}
+	return mime
+}
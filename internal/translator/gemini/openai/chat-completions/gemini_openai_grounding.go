@@ -0,0 +1,89 @@
+package chat_completions
+
+import (
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// Citation is a source cited by a Gemini candidate, with its span in the answer text in
+// characters as OpenAI annotations expect.
+type Citation struct {
+	URL        string
+	Title      string
+	StartIndex int
+	EndIndex   int
+}
+
+// GroundingCitations maps the groundingMetadata of a Gemini candidate to citations of text,
+// the visible answer. Each grounding support yields a citation for every chunk it references;
+// chunks no support references are cited with an empty span at the end of the text.
+func GroundingCitations(candidate gjson.Result, text string) []Citation {
+	meta := candidate.Get("groundingMetadata")
+	chunks := meta.Get("groundingChunks").Array()
+	if len(chunks) == 0 {
+		return nil
+	}
+	source := func(i int64) (string, string, bool) {
+		if i < 0 || int(i) >= len(chunks) {
+			return "", "", false
+		}
+		web := chunks[i].Get("web")
+		if !web.Exists() {
+			web = chunks[i].Get("retrievedContext")
+		}
+		uri := web.Get("uri").String()
+		return uri, web.Get("title").String(), uri != ""
+	}
+	charIndex := func(byteIndex int64) int {
+		if byteIndex <= 0 {
+			return 0
+		}
+		if int(byteIndex) >= len(text) {
+			return utf8.RuneCountInString(text)
+		}
+		return utf8.RuneCountInString(text[:byteIndex])
+	}
+
+	var citations []Citation
+	cited := make(map[int64]bool)
+	for _, support := range meta.Get("groundingSupports").Array() {
+		segment := support.Get("segment")
+		start, end := charIndex(segment.Get("startIndex").Int()), charIndex(segment.Get("endIndex").Int())
+		for _, idx := range support.Get("groundingChunkIndices").Array() {
+			uri, title, ok := source(idx.Int())
+			if !ok {
+				continue
+			}
+			cited[idx.Int()] = true
+			citations = append(citations, Citation{URL: uri, Title: title, StartIndex: start, EndIndex: end})
+		}
+	}
+	end := utf8.RuneCountInString(text)
+	for i := range chunks {
+		if cited[int64(i)] {
+			continue
+		}
+		if uri, title, ok := source(int64(i)); ok {
+			citations = append(citations, Citation{URL: uri, Title: title, StartIndex: end, EndIndex: end})
+		}
+	}
+	return citations
+}
+
+// chatAnnotations formats citations as Chat Completions url_citation annotations.
+func chatAnnotations(citations []Citation) []map[string]any {
+	annotations := make([]map[string]any, 0, len(citations))
+	for _, c := range citations {
+		annotations = append(annotations, map[string]any{
+			"type": "url_citation",
+			"url_citation": map[string]any{
+				"start_index": c.StartIndex,
+				"end_index":   c.EndIndex,
+				"url":         c.URL,
+				"title":       c.Title,
+			},
+		})
+	}
+	return annotations
+}
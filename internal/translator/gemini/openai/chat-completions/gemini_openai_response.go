@@ -10,8 +10,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -19,6 +21,8 @@ import (
 // convertGeminiResponseToOpenAIChatParams holds parameters for response conversion.
 type convertGeminiResponseToOpenAIChatParams struct {
 	UnixTimestamp int64
+	// Text is the answer streamed so far; grounding offsets refer to it.
+	Text strings.Builder
 }
 
 // ConvertGeminiResponseToOpenAI translates a single chunk of a streaming response from the
@@ -35,7 +39,7 @@ type convertGeminiResponseToOpenAIChatParams struct {
 //
 // Returns:
 //   - []string: A slice of strings, each containing an OpenAI-compatible JSON response
-func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+func ConvertGeminiResponseToOpenAI(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
 		*param = &convertGeminiResponseToOpenAIChatParams{
 			UnixTimestamp: 0,
@@ -108,6 +112,7 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 			if !inlineDataResult.Exists() {
 				inlineDataResult = partResult.Get("inline_data")
 			}
+			fileURI := partResult.Get("fileData.fileUri").String()
 
 			if partTextResult.Exists() {
 				// Handle text content, distinguishing between regular content and reasoning/thoughts.
@@ -115,6 +120,7 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 					template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", partTextResult.String())
 				} else {
 					template, _ = sjson.Set(template, "choices.0.delta.content", partTextResult.String())
+					(*param).(*convertGeminiResponseToOpenAIChatParams).Text.WriteString(partTextResult.String())
 				}
 				template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
 			} else if functionCallResult.Exists() {
@@ -126,26 +132,30 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 
 				functionCallTemplate := `{"id": "","type": "function","function": {"name": "","arguments": ""}}`
 				fcName := functionCallResult.Get("name").String()
-				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "id", fmt.Sprintf("%s-%d", fcName, time.Now().UnixNano()))
+				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "id", idgen.NewID(ctx, fcName+"-"))
 				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "function.name", fcName)
 				if fcArgsResult := functionCallResult.Get("args"); fcArgsResult.Exists() {
 					functionCallTemplate, _ = sjson.Set(functionCallTemplate, "function.arguments", fcArgsResult.Raw)
 				}
 				template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
 				template, _ = sjson.SetRaw(template, "choices.0.delta.tool_calls.-1", functionCallTemplate)
-			} else if inlineDataResult.Exists() {
-				data := inlineDataResult.Get("data").String()
-				if data == "" {
-					continue
-				}
-				mimeType := inlineDataResult.Get("mimeType").String()
-				if mimeType == "" {
-					mimeType = inlineDataResult.Get("mime_type").String()
-				}
-				if mimeType == "" {
-					mimeType = "image/png"
+			} else if inlineDataResult.Exists() || fileURI != "" {
+				// Images are inline data, or links to copies re-hosted by the proxy.
+				imageURL := fileURI
+				if inlineDataResult.Exists() {
+					data := inlineDataResult.Get("data").String()
+					if data == "" {
+						continue
+					}
+					mimeType := inlineDataResult.Get("mimeType").String()
+					if mimeType == "" {
+						mimeType = inlineDataResult.Get("mime_type").String()
+					}
+					if mimeType == "" {
+						mimeType = "image/png"
+					}
+					imageURL = fmt.Sprintf("data:%s;base64,%s", mimeType, data)
 				}
-				imageURL := fmt.Sprintf("data:%s;base64,%s", mimeType, data)
 				imagePayload, err := json.Marshal(map[string]any{
 					"type": "image_url",
 					"image_url": map[string]string{
@@ -165,6 +175,11 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 		}
 	}
 
+	text := (*param).(*convertGeminiResponseToOpenAIChatParams).Text.String()
+	if citations := GroundingCitations(gjson.GetBytes(rawJSON, "candidates.0"), text); len(citations) > 0 {
+		template, _ = sjson.Set(template, "choices.0.delta.annotations", chatAnnotations(citations))
+	}
+
 	return []string{template}
 }
 
@@ -181,7 +196,7 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 //
 // Returns:
 //   - string: An OpenAI-compatible JSON response containing all message content and metadata
-func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+func ConvertGeminiResponseToOpenAINonStream(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	var unixTimestamp int64
 	template := `{"id":"","object":"chat.completion","created":123456,"model":"model","choices":[{"index":0,"message":{"role":"assistant","content":null,"reasoning_content":null,"tool_calls":null},"finish_reason":null,"native_finish_reason":null}]}`
 	if modelVersionResult := gjson.GetBytes(rawJSON, "modelVersion"); modelVersionResult.Exists() {
@@ -234,6 +249,7 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 			if !inlineDataResult.Exists() {
 				inlineDataResult = partResult.Get("inline_data")
 			}
+			fileURI := partResult.Get("fileData.fileUri").String()
 
 			if partTextResult.Exists() {
 				// Append text content, distinguishing between regular content and reasoning.
@@ -251,26 +267,30 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 				}
 				functionCallItemTemplate := `{"id": "","type": "function","function": {"name": "","arguments": ""}}`
 				fcName := functionCallResult.Get("name").String()
-				functionCallItemTemplate, _ = sjson.Set(functionCallItemTemplate, "id", fmt.Sprintf("%s-%d", fcName, time.Now().UnixNano()))
+				functionCallItemTemplate, _ = sjson.Set(functionCallItemTemplate, "id", idgen.NewID(ctx, fcName+"-"))
 				functionCallItemTemplate, _ = sjson.Set(functionCallItemTemplate, "function.name", fcName)
 				if fcArgsResult := functionCallResult.Get("args"); fcArgsResult.Exists() {
 					functionCallItemTemplate, _ = sjson.Set(functionCallItemTemplate, "function.arguments", fcArgsResult.Raw)
 				}
 				template, _ = sjson.Set(template, "choices.0.message.role", "assistant")
 				template, _ = sjson.SetRaw(template, "choices.0.message.tool_calls.-1", functionCallItemTemplate)
-			} else if inlineDataResult.Exists() {
-				data := inlineDataResult.Get("data").String()
-				if data == "" {
-					continue
-				}
-				mimeType := inlineDataResult.Get("mimeType").String()
-				if mimeType == "" {
-					mimeType = inlineDataResult.Get("mime_type").String()
-				}
-				if mimeType == "" {
-					mimeType = "image/png"
+			} else if inlineDataResult.Exists() || fileURI != "" {
+				// Images are inline data, or links to copies re-hosted by the proxy.
+				imageURL := fileURI
+				if inlineDataResult.Exists() {
+					data := inlineDataResult.Get("data").String()
+					if data == "" {
+						continue
+					}
+					mimeType := inlineDataResult.Get("mimeType").String()
+					if mimeType == "" {
+						mimeType = inlineDataResult.Get("mime_type").String()
+					}
+					if mimeType == "" {
+						mimeType = "image/png"
+					}
+					imageURL = fmt.Sprintf("data:%s;base64,%s", mimeType, data)
 				}
-				imageURL := fmt.Sprintf("data:%s;base64,%s", mimeType, data)
 				imagePayload, err := json.Marshal(map[string]any{
 					"type": "image_url",
 					"image_url": map[string]string{
@@ -290,5 +310,10 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 		}
 	}
 
+	text := gjson.Get(template, "choices.0.message.content").String()
+	if citations := GroundingCitations(gjson.GetBytes(rawJSON, "candidates.0"), text); len(citations) > 0 {
+		template, _ = sjson.Set(template, "choices.0.message.annotations", chatAnnotations(citations))
+	}
+
 	return template
 }
@@ -2,8 +2,10 @@ package responses
 
 import (
 	"bytes"
+	"strconv"
 	"strings"
 
+	geminiChat "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -93,6 +95,18 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 								one, _ = sjson.SetRaw(one, "parts.-1", textPart)
 								out, _ = sjson.SetRaw(out, "contents.-1", one)
 							}
+						case "input_image", "input_file", "input_audio":
+							// Files join the user turn of the text before them.
+							if mime, data, ok := geminiChat.InlineData(contentItem); ok {
+								part := `{"inlineData":{"mime_type":"","data":""}}`
+								part, _ = sjson.Set(part, "inlineData.mime_type", mime)
+								part, _ = sjson.Set(part, "inlineData.data", data)
+								if last := gjson.Get(out, "contents.@reverse.0"); last.Get("role").String() == "user" {
+									out, _ = sjson.SetRaw(out, "contents."+strconv.Itoa(int(gjson.Get(out, "contents.#").Int())-1)+".parts.-1", part)
+								} else {
+									out, _ = sjson.SetRaw(out, "contents.-1", `{"role":"user","parts":[`+part+`]}`)
+								}
+							}
 						}
 						return true
 					})
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	geminiChat "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -22,6 +24,7 @@ type geminiToResponsesState struct {
 	MsgIndex     int
 	CurrentMsgID string
 	TextBuf      strings.Builder
+	Annotations  []interface{}
 
 	// reasoning aggregation
 	ReasoningOpened bool
@@ -42,7 +45,7 @@ func emitEvent(event string, payload string) string {
 }
 
 // ConvertGeminiResponseToOpenAIResponses converts Gemini SSE chunks into OpenAI Responses SSE events.
-func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+func ConvertGeminiResponseToOpenAIResponses(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
 		*param = &geminiToResponsesState{
 			FuncArgsBuf: make(map[int]*strings.Builder),
@@ -107,7 +110,7 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 			}
 		}
 		if st.CreatedAt == 0 {
-			st.CreatedAt = time.Now().Unix()
+			st.CreatedAt = idgen.Now(ctx).Unix()
 		}
 
 		created := `{"type":"response.created","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"in_progress","background":false,"error":null}}`
@@ -205,7 +208,7 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 					st.FuncArgsBuf[idx] = &strings.Builder{}
 				}
 				if st.FuncCallIDs[idx] == "" {
-					st.FuncCallIDs[idx] = fmt.Sprintf("call_%d", time.Now().UnixNano())
+					st.FuncCallIDs[idx] = idgen.NewID(ctx, "call_")
 				}
 				st.FuncNames[idx] = name
 
@@ -237,6 +240,10 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 		})
 	}
 
+	if citations := geminiChat.GroundingCitations(root.Get("candidates.0"), st.TextBuf.String()); len(citations) > 0 {
+		st.Annotations = responsesAnnotations(citations)
+	}
+
 	// Finalization on finishReason
 	if fr := root.Get("candidates.0.finishReason"); fr.Exists() && fr.String() != "" {
 		// Finalize reasoning first to keep ordering tight with last delta
@@ -252,6 +259,9 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 			partDone, _ = sjson.Set(partDone, "sequence_number", nextSeq())
 			partDone, _ = sjson.Set(partDone, "item_id", st.CurrentMsgID)
 			partDone, _ = sjson.Set(partDone, "output_index", st.MsgIndex)
+			if len(st.Annotations) > 0 {
+				partDone, _ = sjson.Set(partDone, "part.annotations", st.Annotations)
+			}
 			out = append(out, emitEvent("response.content_part.done", partDone))
 			final := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","text":""}],"role":"assistant"}}`
 			final, _ = sjson.Set(final, "sequence_number", nextSeq())
@@ -385,7 +395,7 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 				"status": "completed",
 				"content": []interface{}{map[string]interface{}{
 					"type":        "output_text",
-					"annotations": []interface{}{},
+					"annotations": append([]interface{}{}, st.Annotations...),
 					"logprobs":    []interface{}{},
 					"text":        st.TextBuf.String(),
 				}},
@@ -449,7 +459,7 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 }
 
 // ConvertGeminiResponseToOpenAIResponsesNonStream aggregates Gemini response JSON into a single OpenAI Responses JSON object.
-func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+func ConvertGeminiResponseToOpenAIResponsesNonStream(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	root := gjson.ParseBytes(rawJSON)
 
 	// Base response scaffold
@@ -458,7 +468,7 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	// id: prefer provider responseId, otherwise synthesize
 	id := root.Get("responseId").String()
 	if id == "" {
-		id = fmt.Sprintf("resp_%x", time.Now().UnixNano())
+		id = idgen.NewID(ctx, "resp_")
 	}
 	// Normalize to response-style id (prefix resp_ if missing)
 	if !strings.HasPrefix(id, "resp_") {
@@ -467,7 +477,7 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	resp, _ = sjson.Set(resp, "id", id)
 
 	// created_at: map from createTime if available
-	createdAt := time.Now().Unix()
+	createdAt := idgen.Now(ctx).Unix()
 	if v := root.Get("createTime"); v.Exists() {
 		if t, err := time.Parse(time.RFC3339Nano, v.String()); err == nil {
 			createdAt = t.Unix()
@@ -569,7 +579,7 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			if fc := p.Get("functionCall"); fc.Exists() {
 				name := fc.Get("name").String()
 				args := fc.Get("args")
-				callID := fmt.Sprintf("call_%x", time.Now().UnixNano())
+				callID := idgen.NewID(ctx, "call_")
 				outputs = append(outputs, map[string]interface{}{
 					"id":     fmt.Sprintf("fc_%s", callID),
 					"type":   "function_call",
@@ -618,7 +628,7 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			"status": "completed",
 			"content": []interface{}{map[string]interface{}{
 				"type":        "output_text",
-				"annotations": []interface{}{},
+				"annotations": responsesAnnotations(geminiChat.GroundingCitations(root.Get("candidates.0"), messageText.String())),
 				"logprobs":    []interface{}{},
 				"text":        messageText.String(),
 			}},
@@ -651,3 +661,18 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 
 	return resp
 }
+
+// responsesAnnotations formats citations as Responses API url_citation annotations.
+func responsesAnnotations(citations []geminiChat.Citation) []interface{} {
+	annotations := make([]interface{}, 0, len(citations))
+	for _, c := range citations {
+		annotations = append(annotations, map[string]interface{}{
+			"type":        "url_citation",
+			"start_index": c.StartIndex,
+			"end_index":   c.EndIndex,
+			"url":         c.URL,
+			"title":       c.Title,
+		})
+	}
+	return annotations
+}
@@ -7,11 +7,11 @@ package gemini
 
 import (
 	"bytes"
-	"crypto/rand"
+	"context"
 	"encoding/json"
-	"math/big"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -28,14 +28,7 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 
 	// Helper for generating tool call IDs in the form: call_<alphanum>
 	genToolCallID := func() string {
-		const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-		var b strings.Builder
-		// 24 chars random suffix
-		for i := 0; i < 24; i++ {
-			n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
-			b.WriteByte(letters[n.Int64()])
-		}
-		return "call_" + b.String()
+		return idgen.NewID(context.Background(), "call_")
 	}
 
 	// Model mapping
@@ -0,0 +1,193 @@
+// Package trash keeps deleted auth files restorable for a retention window.
+//
+// A deleted file is moved to a ".trash" directory inside the auth directory, wrapped in a
+// record that says who deleted it and when. The ".trash" suffix keeps records out of the
+// auth directory scans, which load every *.json file recursively.
+package trash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	dirName    = ".trash"
+	fileSuffix = ".trash"
+)
+
+var (
+	// ErrNotFound is returned when no deleted file has the requested name.
+	ErrNotFound = errors.New("deleted file not found")
+	// ErrExists is returned when restoring over a file that exists again.
+	ErrExists = errors.New("a file with this name already exists")
+)
+
+// Entry describes a deleted auth file.
+type Entry struct {
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+	// ExpiresAt is when the entry is purged, derived from the current retention.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type record struct {
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+	Data      []byte    `json:"data"`
+}
+
+// Delete moves the auth file name out of authDir into the trash. Deleting a name that is
+// already in the trash replaces the older entry.
+func Delete(authDir, name, by string, now time.Time) (Entry, error) {
+	if err := validName(name); err != nil {
+		return Entry{}, err
+	}
+	src := filepath.Join(authDir, name)
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return Entry{}, err
+	}
+	rec := record{Name: name, DeletedAt: now.UTC(), DeletedBy: by, Data: data}
+	if err = writeRecord(authDir, rec); err != nil {
+		return Entry{}, err
+	}
+	if err = os.Remove(src); err != nil {
+		_ = os.Remove(recordPath(authDir, name))
+		return Entry{}, err
+	}
+	return Entry{Name: rec.Name, DeletedAt: rec.DeletedAt, DeletedBy: rec.DeletedBy}, nil
+}
+
+// List returns the deleted files, most recently deleted first.
+func List(authDir string, retention time.Duration) ([]Entry, error) {
+	files, err := os.ReadDir(filepath.Join(authDir, dirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileSuffix) {
+			continue
+		}
+		rec, errRead := readRecord(filepath.Join(authDir, dirName, f.Name()))
+		if errRead != nil {
+			continue
+		}
+		entries = append(entries, Entry{
+			Name:      rec.Name,
+			DeletedAt: rec.DeletedAt,
+			DeletedBy: rec.DeletedBy,
+			ExpiresAt: rec.DeletedAt.Add(retention),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].DeletedAt.After(entries[j].DeletedAt) })
+	return entries, nil
+}
+
+// Restore writes the deleted file name back to authDir and returns its path.
+func Restore(authDir, name string) (string, error) {
+	if err := validName(name); err != nil {
+		return "", err
+	}
+	rec, err := readRecord(recordPath(authDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	dst := filepath.Join(authDir, name)
+	if _, err = os.Stat(dst); err == nil {
+		return "", ErrExists
+	}
+	if err = os.WriteFile(dst, rec.Data, 0o600); err != nil {
+		return "", err
+	}
+	if err = os.Remove(recordPath(authDir, name)); err != nil {
+		return dst, err
+	}
+	return dst, nil
+}
+
+// Purge permanently removes entries deleted longer than retention ago and returns how many
+// were removed.
+func Purge(authDir string, retention time.Duration, now time.Time) (int, error) {
+	entries, err := List(authDir, retention)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, entry := range entries {
+		if now.Before(entry.ExpiresAt) {
+			continue
+		}
+		if errRemove := os.Remove(recordPath(authDir, entry.Name)); errRemove != nil && !os.IsNotExist(errRemove) {
+			return purged, errRemove
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid name: %q", name)
+	}
+	return nil
+}
+
+func recordPath(authDir, name string) string {
+	return filepath.Join(authDir, dirName, name+fileSuffix)
+}
+
+func writeRecord(authDir string, rec record) error {
+	dir := filepath.Join(authDir, dirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), recordPath(authDir, rec.Name)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func readRecord(path string) (record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record{}, err
+	}
+	var rec record
+	if err = json.Unmarshal(data, &rec); err != nil {
+		return record{}, fmt.Errorf("invalid trash record %s: %w", filepath.Base(path), err)
+	}
+	return rec, nil
+}
@@ -0,0 +1,373 @@
+package usage
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// bandwidthCounter tracks upstream traffic of one account.
+type bandwidthCounter struct {
+	Provider   string `json:"provider"`
+	Day        string `json:"day"`
+	DayBytes   int64  `json:"day_bytes"`
+	Month      string `json:"month"`
+	MonthBytes int64  `json:"month_bytes"`
+	BytesOut   int64  `json:"bytes_out"`
+	BytesIn    int64  `json:"bytes_in"`
+	Requests   int64  `json:"requests"`
+}
+
+func (c *bandwidthCounter) roll(now time.Time) {
+	day := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+	if c.Day != day {
+		c.Day = day
+		c.DayBytes = 0
+	}
+	if c.Month != month {
+		c.Month = month
+		c.MonthBytes = 0
+	}
+}
+
+// BandwidthStatus describes an account's upstream traffic against its ceilings.
+type BandwidthStatus struct {
+	Account      string    `json:"account"`
+	Provider     string    `json:"provider,omitempty"`
+	Requests     int64     `json:"requests"`
+	BytesOut     int64     `json:"bytes_out"`
+	BytesIn      int64     `json:"bytes_in"`
+	DailyLimit   int64     `json:"daily_limit"`
+	DailyUsed    int64     `json:"daily_used"`
+	DailyReset   time.Time `json:"daily_reset"`
+	MonthlyLimit int64     `json:"monthly_limit"`
+	MonthlyUsed  int64     `json:"monthly_used"`
+	MonthlyReset time.Time `json:"monthly_reset"`
+	// Exceeded names the window ("daily" or "monthly") whose ceiling has been reached.
+	Exceeded string `json:"exceeded,omitempty"`
+}
+
+// BandwidthTracker counts upstream bytes per account and evaluates configured ceilings.
+// Response bytes are counted after decompression.
+type BandwidthTracker struct {
+	mu       sync.Mutex
+	cfg      config.BandwidthConfig
+	path     string
+	counters map[string]*bandwidthCounter
+	dirty    bool
+	lastSave time.Time
+}
+
+var defaultBandwidthTracker = &BandwidthTracker{counters: make(map[string]*bandwidthCounter)}
+
+// GetBandwidthTracker returns the shared bandwidth tracker.
+func GetBandwidthTracker() *BandwidthTracker { return defaultBandwidthTracker }
+
+// Configure applies ceiling settings. Counters are persisted only while ceilings are
+// enabled; they are loaded when the store path changes.
+func (t *BandwidthTracker) Configure(cfg config.BandwidthConfig, authDir string) {
+	file := strings.TrimSpace(cfg.StoreFile)
+	if file == "" {
+		file = "bandwidth.usage"
+	}
+	if !filepath.IsAbs(file) && authDir != "" {
+		if dir, err := util.ResolveAuthDir(authDir); err == nil {
+			file = filepath.Join(dir, file)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cfg = cfg
+	if !cfg.Enabled || file == t.path {
+		return
+	}
+	if t.dirty && t.path != "" {
+		t.saveLocked(time.Now())
+	}
+	t.path = file
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("bandwidth: failed to read %s: %v", file, err)
+		}
+		return
+	}
+	stored := make(map[string]*bandwidthCounter)
+	if err = json.Unmarshal(data, &stored); err != nil {
+		log.Warnf("bandwidth: failed to parse %s: %v", file, err)
+		return
+	}
+	// Traffic counted before ceilings were enabled is kept on top of the stored totals.
+	for account, counter := range t.counters {
+		if prev, ok := stored[account]; ok {
+			prev.roll(time.Now().UTC())
+			counter.roll(time.Now().UTC())
+			prev.DayBytes += counter.DayBytes
+			prev.MonthBytes += counter.MonthBytes
+			prev.BytesOut += counter.BytesOut
+			prev.BytesIn += counter.BytesIn
+			prev.Requests += counter.Requests
+			continue
+		}
+		stored[account] = counter
+	}
+	t.counters = stored
+}
+
+// Enabled reports whether ceilings are enforced.
+func (t *BandwidthTracker) Enabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cfg.Enabled
+}
+
+// record adds traffic for account. requests is the number of requests started.
+func (t *BandwidthTracker) record(provider, account string, requests, sent, received int64) {
+	if account == "" {
+		return
+	}
+	now := time.Now().UTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counter, ok := t.counters[account]
+	if !ok {
+		counter = &bandwidthCounter{}
+		t.counters[account] = counter
+	}
+	if provider != "" {
+		counter.Provider = provider
+	}
+	counter.roll(now)
+	counter.Requests += requests
+	counter.BytesOut += sent
+	counter.BytesIn += received
+	counter.DayBytes += sent + received
+	counter.MonthBytes += sent + received
+	t.dirty = true
+	if t.cfg.Enabled && now.Sub(t.lastSave) >= quotaSaveInterval {
+		t.saveLocked(now)
+	}
+}
+
+// Check returns the status of account and whether it may send another request.
+func (t *BandwidthTracker) Check(account string, now time.Time) (BandwidthStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := t.statusLocked(account, now.UTC())
+	return status, !t.cfg.Enabled || status.Exceeded == ""
+}
+
+// Flush writes pending counter changes to disk.
+func (t *BandwidthTracker) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.dirty {
+		t.saveLocked(time.Now())
+	}
+}
+
+// Snapshot returns the status of every account with recorded traffic or a configured ceiling.
+func (t *BandwidthTracker) Snapshot(now time.Time) []BandwidthStatus {
+	now = now.UTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seen := make(map[string]struct{}, len(t.counters))
+	out := make([]BandwidthStatus, 0, len(t.counters))
+	for account := range t.counters {
+		seen[account] = struct{}{}
+		out = append(out, t.statusLocked(account, now))
+	}
+	for _, entry := range t.cfg.Accounts {
+		if _, ok := seen[entry.Account]; ok || entry.Account == "" {
+			continue
+		}
+		seen[entry.Account] = struct{}{}
+		out = append(out, t.statusLocked(entry.Account, now))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Account < out[j].Account })
+	return out
+}
+
+func (t *BandwidthTracker) statusLocked(account string, now time.Time) BandwidthStatus {
+	limits := t.cfg.Default
+	for _, entry := range t.cfg.Accounts {
+		if entry.Account == account {
+			limits = entry.BandwidthLimits
+			break
+		}
+	}
+	counter := bandwidthCounter{}
+	if existing, ok := t.counters[account]; ok {
+		counter = *existing
+	}
+	counter.roll(now)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	status := BandwidthStatus{
+		Account:      account,
+		Provider:     counter.Provider,
+		Requests:     counter.Requests,
+		BytesOut:     counter.BytesOut,
+		BytesIn:      counter.BytesIn,
+		DailyLimit:   limits.DailyBytes,
+		DailyUsed:    counter.DayBytes,
+		DailyReset:   startOfDay.AddDate(0, 0, 1),
+		MonthlyLimit: limits.MonthlyBytes,
+		MonthlyUsed:  counter.MonthBytes,
+		MonthlyReset: startOfMonth.AddDate(0, 1, 0),
+	}
+	switch {
+	case limits.MonthlyBytes > 0 && counter.MonthBytes >= limits.MonthlyBytes:
+		status.Exceeded = "monthly"
+	case limits.DailyBytes > 0 && counter.DayBytes >= limits.DailyBytes:
+		status.Exceeded = "daily"
+	}
+	return status
+}
+
+func (t *BandwidthTracker) saveLocked(now time.Time) {
+	t.lastSave = now
+	if t.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(t.counters, "", "  ")
+	if err != nil {
+		log.Warnf("bandwidth: failed to encode counters: %v", err)
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(t.path), 0o700); err != nil {
+		log.Warnf("bandwidth: failed to create store directory: %v", err)
+		return
+	}
+	tmp := t.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Warnf("bandwidth: failed to write %s: %v", tmp, err)
+		return
+	}
+	if err = os.Rename(tmp, t.path); err != nil {
+		log.Warnf("bandwidth: failed to replace %s: %v", t.path, err)
+		return
+	}
+	t.dirty = false
+}
+
+// bandwidthTransport counts request and response body bytes of an account. Compressed
+// responses are decoded here, so callers always see plain bodies and the counts reflect
+// the payload size rather than the wire size.
+type bandwidthTransport struct {
+	base     http.RoundTripper
+	provider string
+	account  string
+}
+
+// NewBandwidthTransport wraps base so that its traffic is attributed to account. A nil base
+// uses http.DefaultTransport.
+func NewBandwidthTransport(base http.RoundTripper, provider, account string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if account == "" {
+		return base
+	}
+	return &bandwidthTransport{base: base, provider: provider, account: account}
+}
+
+func (t *bandwidthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tracker := GetBandwidthTracker()
+	tracker.record(t.provider, t.account, 1, 0, 0)
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &countingBody{ReadCloser: req.Body, add: func(n int64) { tracker.record(t.provider, t.account, 0, n, 0) }}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		return resp, err
+	}
+	decodeResponseBody(resp)
+	resp.Body = &countingBody{ReadCloser: resp.Body, add: func(n int64) { tracker.record(t.provider, t.account, 0, 0, n) }}
+	return resp, nil
+}
+
+type countingBody struct {
+	io.ReadCloser
+	add func(int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.add(int64(n))
+	}
+	return n, err
+}
+
+// decodeResponseBody replaces a gzip, deflate or zstd encoded body with its decoded form.
+// Other encodings are left for the caller.
+func decodeResponseBody(resp *http.Response) {
+	if resp.Uncompressed {
+		return
+	}
+	var open func(io.Reader) (io.ReadCloser, error)
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		open = func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+	case "deflate":
+		open = zlib.NewReader
+	case "zstd":
+		open = func(r io.Reader) (io.ReadCloser, error) {
+			dec, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return dec.IOReadCloser(), nil
+		}
+	default:
+		return
+	}
+	resp.Body = &lazyDecoder{raw: resp.Body, open: open}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// lazyDecoder opens the decompressor on first read, so that an empty or error body does
+// not fail before the caller looks at the status code.
+type lazyDecoder struct {
+	raw     io.ReadCloser
+	open    func(io.Reader) (io.ReadCloser, error)
+	decoder io.ReadCloser
+	err     error
+}
+
+func (d *lazyDecoder) Read(p []byte) (int, error) {
+	if d.decoder == nil && d.err == nil {
+		d.decoder, d.err = d.open(d.raw)
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.decoder.Read(p)
+}
+
+func (d *lazyDecoder) Close() error {
+	if d.decoder != nil {
+		_ = d.decoder.Close()
+	}
+	return d.raw.Close()
+}
@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"bytes"
+
+	"github.com/tidwall/gjson"
+)
+
+// estimateSkipKeys are fields whose string values are protocol metadata or inline binary data
+// rather than text the model reads or writes.
+var estimateSkipKeys = map[string]struct{}{
+	"id":                 {},
+	"object":             {},
+	"model":              {},
+	"role":               {},
+	"type":               {},
+	"finish_reason":      {},
+	"finishReason":       {},
+	"stop_reason":        {},
+	"tool_call_id":       {},
+	"call_id":            {},
+	"mime_type":          {},
+	"mimeType":           {},
+	"data":               {},
+	"url":                {},
+	"image_url":          {},
+	"file_data":          {},
+	"system_fingerprint": {},
+	"responseId":         {},
+	"modelVersion":       {},
+	"thoughtSignature":   {},
+	"signature":          {},
+}
+
+// EstimatePromptTokens approximates the prompt tokens of a request body from the text it
+// carries (messages, system prompt, tool definitions), used when the upstream does not
+// report usage. JSON syntax and inline images are not counted.
+func EstimatePromptTokens(body []byte) int64 {
+	if !gjson.ValidBytes(body) {
+		return estimateTokens(len(body))
+	}
+	return estimateTokens(textLength(gjson.ParseBytes(body)))
+}
+
+// EstimateCompletionTokens approximates the completion tokens of a JSON or server-sent-events
+// response body from the generated text it carries.
+func EstimateCompletionTokens(body []byte) int64 {
+	if gjson.ValidBytes(body) {
+		return estimateTokens(textLength(gjson.ParseBytes(body)))
+	}
+	n := 0
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		payload := bytes.TrimSpace(line[len("data:"):])
+		if gjson.ValidBytes(payload) {
+			n += textLength(gjson.ParseBytes(payload))
+		}
+	}
+	return estimateTokens(n)
+}
+
+// EstimateTextTokens approximates the tokens of plain text.
+func EstimateTextTokens(text string) int64 {
+	return estimateTokens(len(text))
+}
+
+// textLength sums the lengths of the text values in node.
+func textLength(node gjson.Result) int {
+	switch {
+	case node.Type == gjson.String:
+		return len(node.Str)
+	case node.IsObject():
+		n := 0
+		node.ForEach(func(key, value gjson.Result) bool {
+			if _, skip := estimateSkipKeys[key.String()]; !skip {
+				n += textLength(value)
+			}
+			return true
+		})
+		return n
+	case node.IsArray():
+		n := 0
+		node.ForEach(func(_, value gjson.Result) bool {
+			n += textLength(value)
+			return true
+		})
+		return n
+	}
+	return 0
+}
+
+// estimateTokens converts a text length to tokens at about four bytes per token.
+func estimateTokens(byteLen int) int64 {
+	if byteLen <= 0 {
+		return 0
+	}
+	return int64((byteLen + 3) / 4)
+}
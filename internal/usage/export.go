@@ -0,0 +1,256 @@
+package usage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	defaultExportEpsilon   = 1.0
+	defaultExportMaxTokens = 32768
+	defaultExportMinCount  = 10
+
+	// exportQueries is the number of noised query families (user/model requests and tokens,
+	// daily requests and tokens) a single request contributes to; epsilon is split evenly.
+	exportQueries = 4
+)
+
+// UsageExport is an aggregated per-user usage report that can be shared outside the
+// deployment. It carries no request details, and client API keys are replaced by pseudonyms.
+type UsageExport struct {
+	GeneratedAt   time.Time        `json:"generated_at"`
+	Privacy       *ExportPrivacy   `json:"privacy,omitempty"`
+	TotalRequests int64            `json:"total_requests"`
+	TotalTokens   int64            `json:"total_tokens"`
+	Users         []ExportUser     `json:"users"`
+	RequestsByDay map[string]int64 `json:"requests_by_day"`
+	TokensByDay   map[string]int64 `json:"tokens_by_day"`
+	// SuppressedRows counts user/model rows left out because their count was too small.
+	SuppressedRows int `json:"suppressed_rows,omitempty"`
+}
+
+// ExportPrivacy describes the noise applied to an export.
+type ExportPrivacy struct {
+	Mechanism           string  `json:"mechanism"`
+	Epsilon             float64 `json:"epsilon"`
+	MaxTokensPerRequest int64   `json:"max_tokens_per_request"`
+	MinCount            int64   `json:"min_count"`
+	// EpsilonSpent is the budget spent by all releases since the server started.
+	EpsilonSpent float64 `json:"epsilon_spent,omitempty"`
+	// NextReleaseAt is when the current release expires and a new one will be drawn.
+	NextReleaseAt *time.Time `json:"next_release_at,omitempty"`
+}
+
+// ErrExportBudgetSpent is returned by ReleaseExport once usage-export.differential-privacy.total-epsilon
+// would be exceeded by another release.
+var ErrExportBudgetSpent = errors.New("usage export privacy budget is spent")
+
+// exportReleaser keeps the current noised release so that repeated exports within a period
+// return the same figures instead of fresh noise, and tracks the budget spent so far.
+type exportReleaser struct {
+	mu          sync.Mutex
+	period      int64
+	fingerprint string
+	release     *UsageExport
+	spent       float64
+}
+
+var sharedExportReleaser exportReleaser
+
+// ReleaseExport returns the usage export for the current release period. Without differential
+// privacy it is rebuilt on every call. With it, the first call in a period draws the noise and
+// later calls in the same period (with the same settings) get that release back; each new
+// release spends epsilon, and ErrExportBudgetSpent is returned once total-epsilon is used up.
+func ReleaseExport(snapshot StatisticsSnapshot, cfg config.UsageExportConfig, now time.Time) (UsageExport, error) {
+	dp := cfg.DifferentialPrivacy
+	if !dp.Enabled {
+		return BuildExport(snapshot, cfg, now), nil
+	}
+	periodLen := int64(dp.ReleasePeriod() / time.Second)
+	period := now.Unix() / periodLen
+	fingerprint := fmt.Sprintf("%+v", cfg)
+
+	r := &sharedExportReleaser
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.release != nil && r.period == period && r.fingerprint == fingerprint {
+		return *r.release, nil
+	}
+	epsilon := exportEpsilon(dp)
+	if dp.TotalEpsilon > 0 && r.spent+epsilon > dp.TotalEpsilon+1e-9 {
+		return UsageExport{}, ErrExportBudgetSpent
+	}
+	export := BuildExport(snapshot, cfg, now)
+	r.spent += epsilon
+	next := time.Unix((period+1)*periodLen, 0).UTC()
+	export.Privacy.EpsilonSpent = r.spent
+	export.Privacy.NextReleaseAt = &next
+	r.period, r.fingerprint, r.release = period, fingerprint, &export
+	return export, nil
+}
+
+func exportEpsilon(dp config.UsageExportPrivacy) float64 {
+	if dp.Epsilon <= 0 {
+		return defaultExportEpsilon
+	}
+	return dp.Epsilon
+}
+
+// ExportUser is the usage of one client in an export.
+type ExportUser struct {
+	User     string        `json:"user"`
+	Requests int64         `json:"requests"`
+	Tokens   int64         `json:"tokens"`
+	Models   []ExportModel `json:"models"`
+}
+
+// ExportModel is the usage of one model by one client.
+type ExportModel struct {
+	Model    string `json:"model"`
+	Requests int64  `json:"requests"`
+	Tokens   int64  `json:"tokens"`
+}
+
+type exportCell struct {
+	requests float64
+	tokens   float64
+}
+
+// BuildExport aggregates snapshot per user and model. With differential privacy enabled
+// every count is released through the Laplace mechanism: one request changes each of the
+// four query families by at most 1 request and MaxTokensPerRequest tokens, so the export as
+// a whole is epsilon-differentially private with respect to any single request. Rows whose
+// noised request count is below MinCount are suppressed so that light users do not stand out.
+// Every call draws fresh noise; serve exports through ReleaseExport to keep the budget bounded.
+func BuildExport(snapshot StatisticsSnapshot, cfg config.UsageExportConfig, now time.Time) UsageExport {
+	dp := cfg.DifferentialPrivacy
+	export := UsageExport{GeneratedAt: now.UTC(), RequestsByDay: map[string]int64{}, TokensByDay: map[string]int64{}}
+
+	tokenBound := int64(0)
+	if dp.Enabled {
+		export.Privacy = &ExportPrivacy{Mechanism: "laplace", Epsilon: exportEpsilon(dp), MaxTokensPerRequest: dp.MaxTokensPerRequest, MinCount: dp.MinCount}
+		if export.Privacy.MaxTokensPerRequest <= 0 {
+			export.Privacy.MaxTokensPerRequest = defaultExportMaxTokens
+		}
+		if export.Privacy.MinCount <= 0 {
+			export.Privacy.MinCount = defaultExportMinCount
+		}
+		tokenBound = export.Privacy.MaxTokensPerRequest
+	}
+
+	cells := make(map[string]map[string]*exportCell)
+	days := make(map[string]*exportCell)
+	for apiKey, api := range snapshot.APIs {
+		user := exportPseudonym(apiKey, cfg.PseudonymSalt)
+		models, ok := cells[user]
+		if !ok {
+			models = make(map[string]*exportCell)
+			cells[user] = models
+		}
+		for modelName, model := range api.Models {
+			cell := &exportCell{}
+			models[modelName] = cell
+			for _, detail := range model.Details {
+				tokens := detail.Tokens.TotalTokens
+				if tokenBound > 0 && tokens > tokenBound {
+					tokens = tokenBound
+				}
+				cell.requests++
+				cell.tokens += float64(tokens)
+				day := detail.Timestamp.Format("2006-01-02")
+				if days[day] == nil {
+					days[day] = &exportCell{}
+				}
+				days[day].requests++
+				days[day].tokens += float64(tokens)
+			}
+		}
+	}
+
+	noise := func(value, sensitivity float64) float64 { return value }
+	if export.Privacy != nil {
+		scale := float64(exportQueries) / export.Privacy.Epsilon
+		noise = func(value, sensitivity float64) float64 {
+			return value + laplace(sensitivity*scale)
+		}
+	}
+	tokenSensitivity := float64(tokenBound)
+
+	var totalRequests, totalTokens float64
+	for user, models := range cells {
+		entry := ExportUser{User: user}
+		for modelName, cell := range models {
+			requests := noise(cell.requests, 1)
+			tokens := noise(cell.tokens, tokenSensitivity)
+			// Totals include suppressed rows; summing released values costs no extra budget.
+			totalRequests += requests
+			totalTokens += tokens
+			if export.Privacy != nil && requests < float64(export.Privacy.MinCount) {
+				export.SuppressedRows++
+				continue
+			}
+			row := ExportModel{Model: modelName, Requests: roundCount(requests), Tokens: roundCount(tokens)}
+			entry.Requests += row.Requests
+			entry.Tokens += row.Tokens
+			entry.Models = append(entry.Models, row)
+		}
+		if len(entry.Models) == 0 {
+			continue
+		}
+		sort.Slice(entry.Models, func(i, j int) bool { return entry.Models[i].Model < entry.Models[j].Model })
+		export.Users = append(export.Users, entry)
+	}
+	sort.Slice(export.Users, func(i, j int) bool { return export.Users[i].User < export.Users[j].User })
+	export.TotalRequests = roundCount(totalRequests)
+	export.TotalTokens = roundCount(totalTokens)
+
+	for day, cell := range days {
+		export.RequestsByDay[day] = roundCount(noise(cell.requests, 1))
+		export.TokensByDay[day] = roundCount(noise(cell.tokens, tokenSensitivity))
+	}
+	return export
+}
+
+// exportPseudonym replaces a client API key (or the endpoint recorded for unauthenticated
+// traffic) with a stable identifier that does not reveal it.
+func exportPseudonym(apiKey, salt string) string {
+	var sum []byte
+	if salt == "" {
+		digest := sha256.Sum256([]byte(apiKey))
+		sum = digest[:]
+	} else {
+		mac := hmac.New(sha256.New, []byte(salt))
+		mac.Write([]byte(apiKey))
+		sum = mac.Sum(nil)
+	}
+	return "user-" + hex.EncodeToString(sum[:8])
+}
+
+// laplace draws from a zero-centred Laplace distribution with the given scale.
+func laplace(scale float64) float64 {
+	if scale <= 0 {
+		return 0
+	}
+	u := rand.Float64() - 0.5
+	for u == -0.5 {
+		u = rand.Float64() - 0.5
+	}
+	return -scale * math.Copysign(math.Log1p(-2*math.Abs(u)), u)
+}
+
+func roundCount(v float64) int64 {
+	if v <= 0 {
+		return 0
+	}
+	return int64(math.Round(v))
+}
@@ -6,12 +6,14 @@ package usage
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 )
 
 var statisticsEnabled atomic.Bool
@@ -64,12 +66,26 @@ type RequestStatistics struct {
 	failureCount  int64
 	totalTokens   int64
 
+	outcomes       map[string]int64
+	deliveredBytes int64
+
 	apis map[string]*apiStats
 
 	requestsByDay  map[string]int64
 	requestsByHour map[int]int64
 	tokensByDay    map[string]int64
 	tokensByHour   map[int]int64
+
+	allFailed AllCandidatesFailedSnapshot
+}
+
+// AllCandidatesFailedSnapshot counts requests for which every routing candidate failed and
+// the attempts made for them.
+type AllCandidatesFailedSnapshot struct {
+	Requests   int64            `json:"requests"`
+	Attempts   int64            `json:"attempts"`
+	ByStatus   map[string]int64 `json:"by_status"`
+	ByProvider map[string]int64 `json:"by_provider"`
 }
 
 // apiStats holds aggregated metrics for a single API key.
@@ -88,8 +104,10 @@ type modelStats struct {
 
 // RequestDetail stores the timestamp and token usage for a single request.
 type RequestDetail struct {
-	Timestamp time.Time  `json:"timestamp"`
-	Tokens    TokenStats `json:"tokens"`
+	Timestamp      time.Time  `json:"timestamp"`
+	Tokens         TokenStats `json:"tokens"`
+	Outcome        string     `json:"outcome,omitempty"`
+	DeliveredBytes int64      `json:"delivered_bytes,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -108,12 +126,18 @@ type StatisticsSnapshot struct {
 	FailureCount  int64 `json:"failure_count"`
 	TotalTokens   int64 `json:"total_tokens"`
 
+	// Outcomes counts requests by how they ended (completed, client_cancelled, upstream_failed).
+	Outcomes       map[string]int64 `json:"outcomes"`
+	DeliveredBytes int64            `json:"delivered_bytes"`
+
 	APIs map[string]APISnapshot `json:"apis"`
 
 	RequestsByDay  map[string]int64 `json:"requests_by_day"`
 	RequestsByHour map[string]int64 `json:"requests_by_hour"`
 	TokensByDay    map[string]int64 `json:"tokens_by_day"`
 	TokensByHour   map[string]int64 `json:"tokens_by_hour"`
+
+	AllCandidatesFailed AllCandidatesFailedSnapshot `json:"all_candidates_failed"`
 }
 
 // APISnapshot summarises metrics for a single API key.
@@ -138,11 +162,35 @@ func GetRequestStatistics() *RequestStatistics { return defaultRequestStatistics
 // NewRequestStatistics constructs an empty statistics store.
 func NewRequestStatistics() *RequestStatistics {
 	return &RequestStatistics{
+		outcomes:       make(map[string]int64),
 		apis:           make(map[string]*apiStats),
 		requestsByDay:  make(map[string]int64),
 		requestsByHour: make(map[int]int64),
 		tokensByDay:    make(map[string]int64),
 		tokensByHour:   make(map[int]int64),
+		allFailed: AllCandidatesFailedSnapshot{
+			ByStatus:   make(map[string]int64),
+			ByProvider: make(map[string]int64),
+		},
+	}
+}
+
+// RecordAllCandidatesFailed counts a request whose routing candidates all failed.
+func (s *RequestStatistics) RecordAllCandidatesFailed(attempts []coreauth.Attempt) {
+	if s == nil || !statisticsEnabled.Load() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allFailed.Requests++
+	for _, attempt := range attempts {
+		s.allFailed.Attempts++
+		status := "none"
+		if attempt.Status > 0 {
+			status = strconv.Itoa(attempt.Status)
+		}
+		s.allFailed.ByStatus[status]++
+		s.allFailed.ByProvider[attempt.Provider]++
 	}
 }
 
@@ -165,6 +213,9 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		statsKey = resolveAPIIdentifier(ctx, record)
 	}
 	success := resolveSuccess(ctx)
+	if record.Outcome != "" {
+		success = record.Outcome == coreusage.OutcomeCompleted
+	}
 	modelName := record.Model
 	if modelName == "" {
 		modelName = "unknown"
@@ -182,13 +233,22 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		s.failureCount++
 	}
 	s.totalTokens += totalTokens
+	if record.Outcome != "" {
+		s.outcomes[record.Outcome]++
+	}
+	s.deliveredBytes += record.DeliveredBytes
 
 	stats, ok := s.apis[statsKey]
 	if !ok {
 		stats = &apiStats{Models: make(map[string]*modelStats)}
 		s.apis[statsKey] = stats
 	}
-	s.updateAPIStats(stats, modelName, RequestDetail{Timestamp: timestamp, Tokens: detail})
+	s.updateAPIStats(stats, modelName, RequestDetail{
+		Timestamp:      timestamp,
+		Tokens:         detail,
+		Outcome:        record.Outcome,
+		DeliveredBytes: record.DeliveredBytes,
+	})
 
 	s.requestsByDay[dayKey]++
 	s.requestsByHour[hourKey]++
@@ -223,6 +283,12 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result.SuccessCount = s.successCount
 	result.FailureCount = s.failureCount
 	result.TotalTokens = s.totalTokens
+	result.DeliveredBytes = s.deliveredBytes
+
+	result.Outcomes = make(map[string]int64, len(s.outcomes))
+	for k, v := range s.outcomes {
+		result.Outcomes[k] = v
+	}
 
 	result.APIs = make(map[string]APISnapshot, len(s.apis))
 	for apiName, stats := range s.apis {
@@ -265,12 +331,25 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 		result.TokensByHour[key] = v
 	}
 
+	result.AllCandidatesFailed = AllCandidatesFailedSnapshot{
+		Requests:   s.allFailed.Requests,
+		Attempts:   s.allFailed.Attempts,
+		ByStatus:   make(map[string]int64, len(s.allFailed.ByStatus)),
+		ByProvider: make(map[string]int64, len(s.allFailed.ByProvider)),
+	}
+	for k, v := range s.allFailed.ByStatus {
+		result.AllCandidatesFailed.ByStatus[k] = v
+	}
+	for k, v := range s.allFailed.ByProvider {
+		result.AllCandidatesFailed.ByProvider[k] = v
+	}
+
 	return result
 }
 
 func resolveAPIIdentifier(ctx context.Context, record coreusage.Record) string {
 	if ctx != nil {
-		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		if ginCtx := requestctx.Gin(ctx); ginCtx != nil {
 			path := ginCtx.FullPath()
 			if path == "" && ginCtx.Request != nil {
 				path = ginCtx.Request.URL.Path
@@ -297,8 +376,8 @@ func resolveSuccess(ctx context.Context) bool {
 	if ctx == nil {
 		return true
 	}
-	ginCtx, ok := ctx.Value("gin").(*gin.Context)
-	if !ok || ginCtx == nil {
+	ginCtx := requestctx.Gin(ctx)
+	if ginCtx == nil {
 		return true
 	}
 	status := ginCtx.Writer.Status()
@@ -0,0 +1,306 @@
+package usage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/clientkeys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// quotaSaveInterval bounds how often counters are flushed to disk.
+const quotaSaveInterval = 5 * time.Second
+
+// quotaCounter tracks token usage for one API key in the current day and month.
+type quotaCounter struct {
+	Day              string `json:"day"`
+	DayTokens        int64  `json:"day_tokens"`
+	Month            string `json:"month"`
+	MonthTokens      int64  `json:"month_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalRequests    int64  `json:"total_requests"`
+}
+
+// roll resets the day and month windows when now falls outside them.
+func (c *quotaCounter) roll(now time.Time) {
+	day := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+	if c.Day != day {
+		c.Day = day
+		c.DayTokens = 0
+	}
+	if c.Month != month {
+		c.Month = month
+		c.MonthTokens = 0
+	}
+}
+
+// QuotaStatus describes a key's usage against its limits.
+type QuotaStatus struct {
+	Key              string    `json:"key"`
+	DailyLimit       int64     `json:"daily_limit"`
+	DailyUsed        int64     `json:"daily_used"`
+	DailyReset       time.Time `json:"daily_reset"`
+	MonthlyLimit     int64     `json:"monthly_limit"`
+	MonthlyUsed      int64     `json:"monthly_used"`
+	MonthlyReset     time.Time `json:"monthly_reset"`
+	TotalTokens      int64     `json:"total_tokens"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	TotalRequests    int64     `json:"total_requests"`
+	// Exceeded names the window ("daily" or "monthly") whose limit has been reached.
+	Exceeded string `json:"exceeded,omitempty"`
+	// ClientKeyID identifies the managed client key the counters belong to, if any.
+	ClientKeyID string `json:"client_key_id,omitempty"`
+}
+
+// QuotaManager persists per-API-key token counters and evaluates configured quotas.
+// Counters are keyed by a SHA-256 digest so the store file never holds raw keys.
+type QuotaManager struct {
+	mu       sync.Mutex
+	cfg      config.APIKeyQuotaConfig
+	path     string
+	counters map[string]*quotaCounter
+	dirty    bool
+	lastSave time.Time
+}
+
+var defaultQuotaManager = &QuotaManager{counters: make(map[string]*quotaCounter)}
+
+// GetQuotaManager returns the shared quota manager.
+func GetQuotaManager() *QuotaManager { return defaultQuotaManager }
+
+// Configure applies quota settings and loads persisted counters when the store path changes.
+func (m *QuotaManager) Configure(cfg config.APIKeyQuotaConfig, authDir string) {
+	file := strings.TrimSpace(cfg.StoreFile)
+	if file == "" {
+		file = "api-key-quotas.usage"
+	}
+	if !filepath.IsAbs(file) && authDir != "" {
+		if dir, err := util.ResolveAuthDir(authDir); err == nil {
+			file = filepath.Join(dir, file)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	if !cfg.Enabled || file == m.path {
+		return
+	}
+	if m.dirty && m.path != "" {
+		m.saveLocked(time.Now())
+	}
+	m.path = file
+	m.counters = make(map[string]*quotaCounter)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("api key quotas: failed to read %s: %v", file, err)
+		}
+		return
+	}
+	if err = json.Unmarshal(data, &m.counters); err != nil {
+		log.Warnf("api key quotas: failed to parse %s: %v", file, err)
+		m.counters = make(map[string]*quotaCounter)
+	}
+}
+
+// Enabled reports whether quota accounting is active.
+func (m *QuotaManager) Enabled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cfg.Enabled
+}
+
+// Status returns the current usage and limits for apiKey, whose request carries the access
+// metadata md. Principals granted a quota tier in md are limited by that tier.
+func (m *QuotaManager) Status(apiKey string, md map[string]string, now time.Time) QuotaStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.statusLocked(apiKey, md, now.UTC())
+}
+
+// Add records the prompt and completion tokens consumed by one request for apiKey. Both count
+// towards the quota windows.
+func (m *QuotaManager) Add(apiKey string, promptTokens, completionTokens int64, now time.Time) {
+	if apiKey == "" {
+		return
+	}
+	now = now.UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.cfg.Enabled {
+		return
+	}
+	id := quotaKeyID(apiKey)
+	counter, ok := m.counters[id]
+	if !ok {
+		counter = &quotaCounter{}
+		m.counters[id] = counter
+	}
+	counter.roll(now)
+	tokens := promptTokens + completionTokens
+	counter.DayTokens += tokens
+	counter.MonthTokens += tokens
+	counter.TotalTokens += tokens
+	counter.PromptTokens += promptTokens
+	counter.CompletionTokens += completionTokens
+	counter.TotalRequests++
+	m.dirty = true
+	if now.Sub(m.lastSave) >= quotaSaveInterval {
+		m.saveLocked(now)
+	}
+}
+
+// Flush writes pending counter changes to disk.
+func (m *QuotaManager) Flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dirty {
+		m.saveLocked(time.Now())
+	}
+}
+
+// Snapshot returns the status of every configured key and every key with recorded usage.
+// Keys without a configured entry are identified by their digest.
+func (m *QuotaManager) Snapshot(now time.Time) []QuotaStatus {
+	now = now.UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]struct{})
+	out := make([]QuotaStatus, 0, len(m.counters))
+	for _, entry := range m.cfg.Keys {
+		if entry.APIKey == "" {
+			continue
+		}
+		seen[quotaKeyID(entry.APIKey)] = struct{}{}
+		out = append(out, m.statusLocked(entry.APIKey, nil, now))
+	}
+	// Managed keys are listed by their hint and limited by their tier.
+	managed := make(map[string]clientkeys.Key)
+	for _, key := range clientkeys.Default().List() {
+		if len(key.Hash) >= 32 {
+			managed[key.Hash[:32]] = key
+		}
+	}
+	for id := range m.counters {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		if key, ok := managed[id]; ok {
+			status := m.statusForIDLocked(id, m.tierLimitsLocked(key.QuotaTier), now)
+			status.Key = key.Hint + "..."
+			status.ClientKeyID = key.ID
+			out = append(out, status)
+			continue
+		}
+		status := m.statusForIDLocked(id, m.cfg.Default, now)
+		status.Key = "sha256:" + id
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func (m *QuotaManager) statusLocked(apiKey string, md map[string]string, now time.Time) QuotaStatus {
+	limits := m.cfg.Default
+	configured := false
+	for _, entry := range m.cfg.Keys {
+		if entry.APIKey == apiKey {
+			limits = entry.APIKeyQuotaLimits
+			configured = true
+			break
+		}
+	}
+	key, managed := clientkeys.Lookup(apiKey, md)
+	if managed && !configured {
+		limits = m.tierLimitsLocked(key.QuotaTier)
+	}
+	status := m.statusForIDLocked(quotaKeyID(apiKey), limits, now)
+	status.Key = util.HideAPIKey(apiKey)
+	if managed {
+		status.ClientKeyID = key.ID
+	}
+	return status
+}
+
+// tierLimitsLocked returns the limits of a managed key's quota tier, or the default limits
+// when the tier is empty or unknown.
+func (m *QuotaManager) tierLimitsLocked(tier string) config.APIKeyQuotaLimits {
+	if limits, ok := m.cfg.Tiers[tier]; ok && tier != "" {
+		return limits
+	}
+	return m.cfg.Default
+}
+
+func (m *QuotaManager) statusForIDLocked(id string, limits config.APIKeyQuotaLimits, now time.Time) QuotaStatus {
+	counter := quotaCounter{}
+	if existing, ok := m.counters[id]; ok {
+		counter = *existing
+	}
+	counter.roll(now)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	status := QuotaStatus{
+		DailyLimit:       limits.DailyTokens,
+		DailyUsed:        counter.DayTokens,
+		DailyReset:       startOfDay.AddDate(0, 0, 1),
+		MonthlyLimit:     limits.MonthlyTokens,
+		MonthlyUsed:      counter.MonthTokens,
+		MonthlyReset:     startOfMonth.AddDate(0, 1, 0),
+		TotalTokens:      counter.TotalTokens,
+		PromptTokens:     counter.PromptTokens,
+		CompletionTokens: counter.CompletionTokens,
+		TotalRequests:    counter.TotalRequests,
+	}
+	switch {
+	case limits.MonthlyTokens > 0 && counter.MonthTokens >= limits.MonthlyTokens:
+		status.Exceeded = "monthly"
+	case limits.DailyTokens > 0 && counter.DayTokens >= limits.DailyTokens:
+		status.Exceeded = "daily"
+	}
+	return status
+}
+
+func (m *QuotaManager) saveLocked(now time.Time) {
+	m.lastSave = now
+	if m.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(m.counters, "", "  ")
+	if err != nil {
+		log.Warnf("api key quotas: failed to encode counters: %v", err)
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(m.path), 0o700); err != nil {
+		log.Warnf("api key quotas: failed to create store directory: %v", err)
+		return
+	}
+	tmp := m.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Warnf("api key quotas: failed to write %s: %v", tmp, err)
+		return
+	}
+	if err = os.Rename(tmp, m.path); err != nil {
+		log.Warnf("api key quotas: failed to replace %s: %v", m.path, err)
+		return
+	}
+	m.dirty = false
+}
+
+func quotaKeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,20 @@
+package usage
+
+import "context"
+
+type replayContextKey struct{}
+
+// WithReplay marks ctx as an operator replay of a logged client request. Replays are
+// checked against the client key's quota but not charged to it.
+func WithReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayContextKey{}, true)
+}
+
+// IsReplay reports whether ctx belongs to a replayed request.
+func IsReplay(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	replay, _ := ctx.Value(replayContextKey{}).(bool)
+	return replay
+}
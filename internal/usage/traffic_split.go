@@ -0,0 +1,94 @@
+package usage
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TrafficSplitStats counts the requests of each traffic split route, so the routes of a model
+// can be compared. Counters are kept in memory only.
+type TrafficSplitStats struct {
+	mu     sync.Mutex
+	routes map[trafficRouteKey]*trafficRouteCounter
+}
+
+type trafficRouteKey struct {
+	model string
+	route string
+}
+
+type trafficRouteCounter struct {
+	requests  int64
+	failures  int64
+	latency   time.Duration
+	lastUsage time.Time
+}
+
+// TrafficRouteSnapshot summarises one traffic split route.
+type TrafficRouteSnapshot struct {
+	Model        string    `json:"model"`
+	Route        string    `json:"route"`
+	Requests     int64     `json:"requests"`
+	Successes    int64     `json:"successes"`
+	Failures     int64     `json:"failures"`
+	SuccessRate  float64   `json:"success_rate"`
+	AvgLatencyMs int64     `json:"avg_latency_ms"`
+	LastRequest  time.Time `json:"last_request"`
+}
+
+var defaultTrafficSplitStats = &TrafficSplitStats{routes: make(map[trafficRouteKey]*trafficRouteCounter)}
+
+// GetTrafficSplitStats returns the shared traffic split counters.
+func GetTrafficSplitStats() *TrafficSplitStats { return defaultTrafficSplitStats }
+
+// Record counts a request of model served on route. latency covers the whole response,
+// including streamed ones.
+func (s *TrafficSplitStats) Record(model, route string, success bool, latency time.Duration) {
+	if s == nil || !statisticsEnabled.Load() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := trafficRouteKey{model: model, route: route}
+	counter, ok := s.routes[key]
+	if !ok {
+		counter = &trafficRouteCounter{}
+		s.routes[key] = counter
+	}
+	counter.requests++
+	if !success {
+		counter.failures++
+	}
+	counter.latency += latency
+	counter.lastUsage = time.Now()
+}
+
+// Snapshot returns the counters of every route, ordered by model and route.
+func (s *TrafficSplitStats) Snapshot() []TrafficRouteSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]TrafficRouteSnapshot, 0, len(s.routes))
+	for key, counter := range s.routes {
+		snap := TrafficRouteSnapshot{
+			Model:       key.model,
+			Route:       key.route,
+			Requests:    counter.requests,
+			Successes:   counter.requests - counter.failures,
+			Failures:    counter.failures,
+			LastRequest: counter.lastUsage,
+		}
+		if counter.requests > 0 {
+			snap.SuccessRate = float64(snap.Successes) / float64(counter.requests)
+			snap.AvgLatencyMs = (counter.latency / time.Duration(counter.requests)).Milliseconds()
+		}
+		out = append(out, snap)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Model != out[j].Model {
+			return out[i].Model < out[j].Model
+		}
+		return out[i].Route < out[j].Route
+	})
+	return out
+}
@@ -0,0 +1,272 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	// utilizationRetention bounds how much hourly history is kept in memory.
+	utilizationRetention = 7 * 24 * time.Hour
+	// defaultSaturationThreshold is the share of a model's accounts that must hit quota in
+	// the same hour before a recommendation is produced.
+	defaultSaturationThreshold = 0.5
+)
+
+func init() {
+	coreusage.RegisterPlugin(defaultUtilizationTracker)
+}
+
+type utilizationKey struct {
+	model string
+	hour  time.Time
+}
+
+type utilizationBucket struct {
+	requests  int64
+	tokens    int64
+	accounts  map[string]int64
+	saturated map[string]struct{}
+}
+
+// UtilizationTracker aggregates hourly per-model traffic and quota exhaustion per account.
+// It receives traffic as a usage plugin and quota signals as an auth manager hook.
+type UtilizationTracker struct {
+	coreauth.NoopHook
+
+	mu      sync.Mutex
+	buckets map[utilizationKey]*utilizationBucket
+}
+
+var defaultUtilizationTracker = NewUtilizationTracker()
+
+// GetUtilizationTracker returns the shared utilization tracker.
+func GetUtilizationTracker() *UtilizationTracker { return defaultUtilizationTracker }
+
+// NewUtilizationTracker constructs an empty tracker.
+func NewUtilizationTracker() *UtilizationTracker {
+	return &UtilizationTracker{buckets: make(map[utilizationKey]*utilizationBucket)}
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (t *UtilizationTracker) HandleUsage(_ context.Context, record coreusage.Record) {
+	if t == nil || record.Model == "" {
+		return
+	}
+	at := record.RequestedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	tokens := normaliseDetail(record.Detail).TotalTokens
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bucket := t.bucketLocked(record.Model, at)
+	bucket.requests++
+	bucket.tokens += tokens
+	if record.AuthID != "" {
+		bucket.accounts[record.AuthID]++
+	}
+}
+
+// OnResult implements coreauth.Hook, marking the account saturated for the hour when the
+// provider reports a quota error.
+func (t *UtilizationTracker) OnResult(_ context.Context, result coreauth.Result) {
+	if t == nil || result.Success || result.Model == "" || result.AuthID == "" || result.Error == nil {
+		return
+	}
+	if result.Error.StatusCode() != http.StatusTooManyRequests {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bucket := t.bucketLocked(result.Model, time.Now())
+	bucket.saturated[result.AuthID] = struct{}{}
+	if _, ok := bucket.accounts[result.AuthID]; !ok {
+		bucket.accounts[result.AuthID] = 0
+	}
+}
+
+func (t *UtilizationTracker) bucketLocked(model string, at time.Time) *utilizationBucket {
+	hour := at.UTC().Truncate(time.Hour)
+	key := utilizationKey{model: model, hour: hour}
+	bucket, ok := t.buckets[key]
+	if !ok {
+		bucket = &utilizationBucket{
+			accounts:  make(map[string]int64),
+			saturated: make(map[string]struct{}),
+		}
+		t.buckets[key] = bucket
+		cutoff := hour.Add(-utilizationRetention)
+		for k := range t.buckets {
+			if k.hour.Before(cutoff) {
+				delete(t.buckets, k)
+			}
+		}
+	}
+	return bucket
+}
+
+// UtilizationReport summarises account pool usage over a time window.
+type UtilizationReport struct {
+	GeneratedAt     time.Time                   `json:"generated_at"`
+	WindowHours     int                         `json:"window_hours"`
+	Models          []ModelUtilization          `json:"models"`
+	Recommendations []UtilizationRecommendation `json:"recommendations"`
+}
+
+// ModelUtilization describes traffic and peak saturation for one model.
+type ModelUtilization struct {
+	Model          string    `json:"model"`
+	Accounts       int       `json:"accounts"`
+	Requests       int64     `json:"requests"`
+	Tokens         int64     `json:"tokens"`
+	ActiveAccounts int       `json:"active_accounts"`
+	PeakHour       time.Time `json:"peak_hour"`
+	PeakRequests   int64     `json:"peak_requests"`
+	PeakSaturated  int       `json:"peak_saturated"`
+	SaturatedHours int       `json:"saturated_hours"`
+}
+
+// UtilizationRecommendation is a capacity suggestion for one saturated model hour.
+type UtilizationRecommendation struct {
+	Model       string    `json:"model"`
+	Hour        time.Time `json:"hour"`
+	Saturated   int       `json:"saturated"`
+	Accounts    int       `json:"accounts"`
+	AddAccounts int       `json:"add_accounts"`
+	Message     string    `json:"message"`
+}
+
+// Report builds a utilization report for the trailing window. A model hour produces a
+// recommendation when the share of its accounts that hit quota reaches threshold
+// (defaultSaturationThreshold when threshold <= 0).
+func (t *UtilizationTracker) Report(now time.Time, window time.Duration, threshold float64) UtilizationReport {
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	if threshold <= 0 {
+		threshold = defaultSaturationThreshold
+	}
+	report := UtilizationReport{
+		GeneratedAt:     now.UTC(),
+		WindowHours:     int(math.Ceil(window.Hours())),
+		Models:          []ModelUtilization{},
+		Recommendations: []UtilizationRecommendation{},
+	}
+	if t == nil {
+		return report
+	}
+	since := now.UTC().Add(-window).Truncate(time.Hour)
+
+	type modelAgg struct {
+		summary ModelUtilization
+		seen    map[string]struct{}
+		hours   []utilizationKey
+	}
+	aggs := make(map[string]*modelAgg)
+
+	t.mu.Lock()
+	snapshot := make(map[utilizationKey]utilizationBucket, len(t.buckets))
+	for key, bucket := range t.buckets {
+		if key.hour.Before(since) {
+			continue
+		}
+		snapshot[key] = utilizationBucket{
+			requests:  bucket.requests,
+			tokens:    bucket.tokens,
+			accounts:  cloneCounts(bucket.accounts),
+			saturated: cloneSet(bucket.saturated),
+		}
+	}
+	t.mu.Unlock()
+
+	for key, bucket := range snapshot {
+		agg, ok := aggs[key.model]
+		if !ok {
+			agg = &modelAgg{summary: ModelUtilization{Model: key.model}, seen: make(map[string]struct{})}
+			aggs[key.model] = agg
+		}
+		agg.summary.Requests += bucket.requests
+		agg.summary.Tokens += bucket.tokens
+		for id := range bucket.accounts {
+			agg.seen[id] = struct{}{}
+		}
+		agg.hours = append(agg.hours, key)
+	}
+
+	models := make([]string, 0, len(aggs))
+	for model := range aggs {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	for _, model := range models {
+		agg := aggs[model]
+		summary := agg.summary
+		summary.ActiveAccounts = len(agg.seen)
+		summary.Accounts = registry.GetGlobalRegistry().GetModelClientCount(model)
+		if summary.Accounts < summary.ActiveAccounts {
+			summary.Accounts = summary.ActiveAccounts
+		}
+		sort.Slice(agg.hours, func(i, j int) bool { return agg.hours[i].hour.Before(agg.hours[j].hour) })
+		for _, key := range agg.hours {
+			bucket := snapshot[key]
+			saturated := len(bucket.saturated)
+			if bucket.requests > summary.PeakRequests || (saturated > summary.PeakSaturated && bucket.requests == summary.PeakRequests) {
+				summary.PeakHour = key.hour
+				summary.PeakRequests = bucket.requests
+			}
+			if saturated > summary.PeakSaturated {
+				summary.PeakSaturated = saturated
+			}
+			if saturated == 0 || summary.Accounts == 0 {
+				continue
+			}
+			if float64(saturated)/float64(summary.Accounts) < threshold {
+				continue
+			}
+			summary.SaturatedHours++
+			add := saturated - (summary.Accounts - saturated)
+			if add < 1 {
+				add = 1
+			}
+			report.Recommendations = append(report.Recommendations, UtilizationRecommendation{
+				Model:       model,
+				Hour:        key.hour,
+				Saturated:   saturated,
+				Accounts:    summary.Accounts,
+				AddAccounts: add,
+				Message: fmt.Sprintf("model %s saturated %d/%d accounts at %s UTC; add %d account(s) or enable fallback",
+					model, saturated, summary.Accounts, key.hour.Format("15:04"), add),
+			})
+		}
+		report.Models = append(report.Models, summary)
+	}
+	return report
+}
+
+func cloneCounts(in map[string]int64) map[string]int64 {
+	out := make(map[string]int64, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func cloneSet(in map[string]struct{}) map[string]struct{} {
+	out := make(map[string]struct{}, len(in))
+	for k := range in {
+		out[k] = struct{}{}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// utilizationWebhook posts a daily UtilizationReport to the configured URL.
+type utilizationWebhook struct {
+	mu      sync.Mutex
+	cfg     config.UtilizationReportConfig
+	started bool
+	changed chan struct{}
+	client  *http.Client
+}
+
+var defaultUtilizationWebhook = &utilizationWebhook{
+	changed: make(chan struct{}, 1),
+	client:  &http.Client{Timeout: 30 * time.Second},
+}
+
+// ConfigureUtilizationWebhook applies the utilization report settings. The delivery loop is
+// started on the first call with a webhook URL and picks up later changes without restarting.
+func ConfigureUtilizationWebhook(cfg config.UtilizationReportConfig) {
+	w := defaultUtilizationWebhook
+	w.mu.Lock()
+	w.cfg = cfg
+	start := !w.started && strings.TrimSpace(cfg.WebhookURL) != ""
+	if start {
+		w.started = true
+	}
+	w.mu.Unlock()
+	if start {
+		go w.run()
+		return
+	}
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (w *utilizationWebhook) current() config.UtilizationReportConfig {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cfg
+}
+
+func (w *utilizationWebhook) run() {
+	for {
+		cfg := w.current()
+		wait := time.Until(nextUtilizationReportAt(time.Now(), cfg.HourUTC))
+		timer := time.NewTimer(wait)
+		select {
+		case <-w.changed:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+		cfg = w.current()
+		if strings.TrimSpace(cfg.WebhookURL) == "" {
+			continue
+		}
+		report := GetUtilizationTracker().Report(time.Now(), 24*time.Hour, cfg.SaturationThreshold)
+		if err := w.deliver(cfg.WebhookURL, report); err != nil {
+			log.Warnf("utilization report: webhook delivery failed: %v", err)
+		} else {
+			log.Debugf("utilization report: delivered daily summary with %d recommendation(s)", len(report.Recommendations))
+		}
+	}
+}
+
+func (w *utilizationWebhook) deliver(url string, report UtilizationReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// nextUtilizationReportAt returns the next occurrence of hourUTC:00 strictly after now.
+func nextUtilizationReportAt(now time.Time, hourUTC int) time.Time {
+	if hourUTC < 0 || hourUTC > 23 {
+		hourUTC = 0
+	}
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), hourUTC, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next
+}
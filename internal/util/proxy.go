@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mtls"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
@@ -44,7 +45,7 @@ func SetProxy(cfg *config.SDKConfig, httpClient *http.Client) *http.Client {
 			}
 		} else if proxyURL.Scheme == "http" || proxyURL.Scheme == "https" {
 			// Configure HTTP or HTTPS proxy.
-			transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+			transport = mtls.ApplyOutbound(&http.Transport{Proxy: http.ProxyURL(proxyURL)})
 		}
 	}
 	// If a new transport was created, apply it to the HTTP client.
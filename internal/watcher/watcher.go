@@ -25,6 +25,7 @@ import (
 	// "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
 	// "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/qwen"
 	// "github.com/router-for-me/CLIProxyAPI/v6/internal/client"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	// "github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 
@@ -51,6 +52,8 @@ type Watcher struct {
 	pendingUpdates map[string]AuthUpdate
 	pendingOrder   []string
 	dispatchCancel context.CancelFunc
+	// reloadMu serialises config reloads from file events and Reload.
+	reloadMu sync.Mutex
 }
 
 type stableIDGenerator struct {
@@ -187,14 +190,49 @@ func (w *Watcher) SetAuthUpdateQueue(queue chan<- AuthUpdate) {
 	}
 }
 
+// Reload re-reads the config file and rescans the auth directory even when nothing changed
+// on disk, as on SIGHUP. Accounts are diffed against the running set, so only added, removed
+// and changed accounts are touched and requests in flight are not interrupted.
+func (w *Watcher) Reload() {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+	log.Infof("reloading config and auth directory: %s", w.configPath)
+	data, errRead := os.ReadFile(w.configPath)
+	if errRead != nil {
+		log.Errorf("failed to read config file for reload: %v", errRead)
+		return
+	}
+	if !w.reloadConfig(true) {
+		return
+	}
+	sum := sha256.Sum256(data)
+	w.clientsMutex.Lock()
+	w.lastConfigHash = hex.EncodeToString(sum[:])
+	w.clientsMutex.Unlock()
+}
+
 func (w *Watcher) refreshAuthState() {
 	auths := w.SnapshotCoreAuths()
 	w.clientsMutex.Lock()
 	updates := w.prepareAuthUpdatesLocked(auths)
 	w.clientsMutex.Unlock()
+	logAuthUpdates(updates)
 	w.dispatchAuthUpdates(updates)
 }
 
+// logAuthUpdates summarises an account diff.
+func logAuthUpdates(updates []AuthUpdate) {
+	if len(updates) == 0 {
+		return
+	}
+	counts := make(map[AuthUpdateAction]int, 3)
+	for _, update := range updates {
+		counts[update.Action]++
+		log.Debugf("auth %s: %s", update.Action, update.ID)
+	}
+	log.Infof("accounts reconciled: %d added, %d changed, %d removed", counts[AuthUpdateActionAdd], counts[AuthUpdateActionModify], counts[AuthUpdateActionDelete])
+}
+
 func (w *Watcher) prepareAuthUpdatesLocked(auths []*coreauth.Auth) []AuthUpdate {
 	newState := make(map[string]*coreauth.Auth, len(auths))
 	for _, auth := range auths {
@@ -368,6 +406,18 @@ func computeOpenAICompatModelsHash(models []config.OpenAICompatibilityModel) str
 	return hex.EncodeToString(sum[:])
 }
 
+func computeBedrockModelsHash(models []config.BedrockModel) string {
+	if len(models) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(models)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 // SetClients sets the file-based clients.
 // SetClients removed
 // SetAPIKeyClients removed
@@ -429,7 +479,9 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 			return
 		}
 		fmt.Printf("config file changed, reloading: %s\n", w.configPath)
-		if w.reloadConfig() {
+		w.reloadMu.Lock()
+		defer w.reloadMu.Unlock()
+		if w.reloadConfig(false) {
 			w.clientsMutex.Lock()
 			w.lastConfigHash = newHash
 			w.clientsMutex.Unlock()
@@ -454,8 +506,9 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	}
 }
 
-// reloadConfig reloads the configuration and triggers a full reload
-func (w *Watcher) reloadConfig() bool {
+// reloadConfig reloads the configuration and triggers a full reload. The auth directory is
+// rescanned when rescanAuth is set or auth-dir changed.
+func (w *Watcher) reloadConfig(rescanAuth bool) bool {
 	log.Debug("=========================== CONFIG RELOAD ============================")
 	log.Debugf("starting config reload from: %s", w.configPath)
 
@@ -505,6 +558,9 @@ func (w *Watcher) reloadConfig() bool {
 		if oldConfig.RequestRetry != newConfig.RequestRetry {
 			log.Debugf("  request-retry: %d -> %d", oldConfig.RequestRetry, newConfig.RequestRetry)
 		}
+		if oldConfig.StreamKeepaliveSeconds != newConfig.StreamKeepaliveSeconds {
+			log.Debugf("  stream-keepalive-seconds: %d -> %d", oldConfig.StreamKeepaliveSeconds, newConfig.StreamKeepaliveSeconds)
+		}
 		if oldConfig.GeminiWeb.Context != newConfig.GeminiWeb.Context {
 			log.Debugf("  gemini-web.context: %t -> %t", oldConfig.GeminiWeb.Context, newConfig.GeminiWeb.Context)
 		}
@@ -517,15 +573,57 @@ func (w *Watcher) reloadConfig() bool {
 		if oldConfig.GeminiWeb.CodeMode != newConfig.GeminiWeb.CodeMode {
 			log.Debugf("  gemini-web.code-mode: %t -> %t", oldConfig.GeminiWeb.CodeMode, newConfig.GeminiWeb.CodeMode)
 		}
+		if oldConfig.GeminiWeb.ConversationCacheSize != newConfig.GeminiWeb.ConversationCacheSize {
+			log.Debugf("  gemini-web.conversation-cache-size: %d -> %d", oldConfig.GeminiWeb.ConversationCacheSize, newConfig.GeminiWeb.ConversationCacheSize)
+		}
+		if oldConfig.GeminiWeb.Backup != newConfig.GeminiWeb.Backup {
+			log.Debugf("  gemini-web.backup: keep %d -> %d, interval-hours %d -> %d", oldConfig.GeminiWeb.Backup.Keep, newConfig.GeminiWeb.Backup.Keep, oldConfig.GeminiWeb.Backup.IntervalHours, newConfig.GeminiWeb.Backup.IntervalHours)
+		}
+		if oldConfig.GeminiWeb.ContextReuseHeaders != newConfig.GeminiWeb.ContextReuseHeaders {
+			log.Debugf("  gemini-web.context-reuse-headers: %t -> %t", oldConfig.GeminiWeb.ContextReuseHeaders, newConfig.GeminiWeb.ContextReuseHeaders)
+		}
+		if !reflect.DeepEqual(oldConfig.GeminiWeb.HashNormalization, newConfig.GeminiWeb.HashNormalization) {
+			log.Debugf("  gemini-web.hash-normalization: updated, conversation indexes will be rebuilt")
+		}
+		if oldConfig.GeminiWeb.PartialOnTimeout != newConfig.GeminiWeb.PartialOnTimeout {
+			log.Debugf("  gemini-web.partial-on-timeout: enabled %t -> %t, finish-reason %q -> %q", oldConfig.GeminiWeb.PartialOnTimeout.Enabled, newConfig.GeminiWeb.PartialOnTimeout.Enabled, oldConfig.GeminiWeb.PartialOnTimeout.FinishReason, newConfig.GeminiWeb.PartialOnTimeout.FinishReason)
+		}
+		if !reflect.DeepEqual(oldConfig.GeminiWeb.Timeout, newConfig.GeminiWeb.Timeout) {
+			log.Debugf("  gemini-web.timeout: updated")
+		}
+		if !reflect.DeepEqual(oldConfig.GeminiWeb.Transport, newConfig.GeminiWeb.Transport) {
+			log.Debugf("  gemini-web.transport: updated, shared transports will be rebuilt")
+		}
+		if oldConfig.GeminiWeb.UploadCache != newConfig.GeminiWeb.UploadCache {
+			log.Debugf("  gemini-web.upload-cache: ttl-minutes %d -> %d, max-entries %d -> %d", oldConfig.GeminiWeb.UploadCache.TTLMinutes, newConfig.GeminiWeb.UploadCache.TTLMinutes, oldConfig.GeminiWeb.UploadCache.MaxEntries, newConfig.GeminiWeb.UploadCache.MaxEntries)
+		}
 		if len(oldConfig.APIKeys) != len(newConfig.APIKeys) {
 			log.Debugf("  api-keys count: %d -> %d", len(oldConfig.APIKeys), len(newConfig.APIKeys))
 		}
+		if !reflect.DeepEqual(oldConfig.APIKeyRules, newConfig.APIKeyRules) {
+			log.Debugf("  api-key-rules count: %d -> %d", len(oldConfig.APIKeyRules), len(newConfig.APIKeyRules))
+		}
+		if oldConfig.ClientKeys.Enabled != newConfig.ClientKeys.Enabled {
+			log.Debugf("  client-keys.enabled: %t -> %t", oldConfig.ClientKeys.Enabled, newConfig.ClientKeys.Enabled)
+		}
+		if !reflect.DeepEqual(oldConfig.OIDC, newConfig.OIDC) {
+			log.Debugf("  oidc: enabled %t -> %t, issuer %q -> %q", oldConfig.OIDC.Enabled, newConfig.OIDC.Enabled, oldConfig.OIDC.Issuer, newConfig.OIDC.Issuer)
+		}
+		if !reflect.DeepEqual(oldConfig.ProxyPool, newConfig.ProxyPool) {
+			log.Debugf("  proxy-pool: enabled %t -> %t, proxies %d -> %d", oldConfig.ProxyPool.Enabled, newConfig.ProxyPool.Enabled, len(oldConfig.ProxyPool.Proxies), len(newConfig.ProxyPool.Proxies))
+		}
 		if len(oldConfig.GlAPIKey) != len(newConfig.GlAPIKey) {
 			log.Debugf("  generative-language-api-key count: %d -> %d", len(oldConfig.GlAPIKey), len(newConfig.GlAPIKey))
 		}
 		if len(oldConfig.ClaudeKey) != len(newConfig.ClaudeKey) {
 			log.Debugf("  claude-api-key count: %d -> %d", len(oldConfig.ClaudeKey), len(newConfig.ClaudeKey))
 		}
+		if len(oldConfig.Bedrock) != len(newConfig.Bedrock) {
+			log.Debugf("  bedrock count: %d -> %d", len(oldConfig.Bedrock), len(newConfig.Bedrock))
+		}
+		if len(oldConfig.VertexAI) != len(newConfig.VertexAI) {
+			log.Debugf("  vertex-ai count: %d -> %d", len(oldConfig.VertexAI), len(newConfig.VertexAI))
+		}
 		if len(oldConfig.CodexKey) != len(newConfig.CodexKey) {
 			log.Debugf("  codex-api-key count: %d -> %d", len(oldConfig.CodexKey), len(newConfig.CodexKey))
 		}
@@ -553,7 +651,7 @@ func (w *Watcher) reloadConfig() bool {
 
 	log.Infof("config successfully reloaded, triggering client reload")
 	// Reload clients with new config
-	w.reloadClients(authDirChanged)
+	w.reloadClients(authDirChanged || rescanAuth)
 	return true
 }
 
@@ -749,6 +847,9 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			if ck.BaseURL != "" {
 				attrs["base_url"] = ck.BaseURL
 			}
+			if ck.ClaudeCode {
+				attrs["claude_code"] = "true"
+			}
 			proxyURL := strings.TrimSpace(ck.ProxyURL)
 			a := &coreauth.Auth{
 				ID:         id,
@@ -790,6 +891,75 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			}
 			out = append(out, a)
 		}
+		// Bedrock credentials -> synthesize auths
+		for i := range cfg.Bedrock {
+			bk := cfg.Bedrock[i]
+			accessKey := strings.TrimSpace(bk.AccessKeyID)
+			secret := strings.TrimSpace(bk.SecretAccessKey)
+			region := strings.TrimSpace(bk.Region)
+			if accessKey == "" || secret == "" || region == "" {
+				continue
+			}
+			id, token := idGen.next("bedrock:key", accessKey, region, bk.BaseURL)
+			attrs := map[string]string{
+				"source":            fmt.Sprintf("config:bedrock[%s]", token),
+				"access_key_id":     accessKey,
+				"secret_access_key": secret,
+				"region":            region,
+			}
+			if v := strings.TrimSpace(bk.SessionToken); v != "" {
+				attrs["session_token"] = v
+			}
+			if v := strings.TrimSpace(bk.BaseURL); v != "" {
+				attrs["base_url"] = v
+			}
+			if hash := computeBedrockModelsHash(bk.Models); hash != "" {
+				attrs["models_hash"] = hash
+			}
+			a := &coreauth.Auth{
+				ID:         id,
+				Provider:   "bedrock",
+				Label:      "bedrock-" + region,
+				Status:     coreauth.StatusActive,
+				ProxyURL:   strings.TrimSpace(bk.ProxyURL),
+				Attributes: attrs,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			out = append(out, a)
+		}
+		// Vertex AI service accounts -> synthesize auths
+		for i := range cfg.VertexAI {
+			vk := cfg.VertexAI[i]
+			credentialsFile := strings.TrimSpace(vk.CredentialsFile)
+			if credentialsFile == "" {
+				continue
+			}
+			location := strings.TrimSpace(vk.Location)
+			if location == "" {
+				location = "us-central1"
+			}
+			id, token := idGen.next("vertex:sa", credentialsFile, vk.ProjectID, location)
+			attrs := map[string]string{
+				"source":           fmt.Sprintf("config:vertex-ai[%s]", token),
+				"credentials_file": credentialsFile,
+				"location":         location,
+			}
+			if v := strings.TrimSpace(vk.ProjectID); v != "" {
+				attrs["project_id"] = v
+			}
+			a := &coreauth.Auth{
+				ID:         id,
+				Provider:   "vertex",
+				Label:      "vertex-" + location,
+				Status:     coreauth.StatusActive,
+				ProxyURL:   strings.TrimSpace(vk.ProxyURL),
+				Attributes: attrs,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			out = append(out, a)
+		}
 		for i := range cfg.OpenAICompatibility {
 			compat := &cfg.OpenAICompatibility[i]
 			providerName := strings.ToLower(strings.TrimSpace(compat.Name))
@@ -875,8 +1045,14 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			continue
 		}
 		full := filepath.Join(w.authDir, name)
-		data, err := os.ReadFile(full)
-		if err != nil || len(data) == 0 {
+		data, err := authcrypt.ReadFile(full)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Errorf("failed to read auth file %s: %v", name, err)
+			}
+			continue
+		}
+		if len(data) == 0 {
 			continue
 		}
 		var metadata map[string]any
@@ -914,6 +1090,13 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			CreatedAt: now,
 			UpdatedAt: now,
 		}
+		if disabled, _ := metadata["disabled"].(bool); disabled {
+			a.Disabled = true
+			a.Status = coreauth.StatusDisabled
+		} else if expired, _ := metadata["expired"].(bool); expired {
+			a.Status = coreauth.StatusExpired
+			a.StatusMessage = "credentials expired"
+		}
 		out = append(out, a)
 	}
 	return out
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+// applyAccountHints honours x_cliproxy.account (pin) and x_cliproxy.exclude_accounts in the
+// request and the caller's allowed-accounts rule. Hints resolve to auth IDs among the
+// accounts the caller may use for providers; a pin also narrows providers to those of the
+// pinned accounts. The returned metadata carries the hints to the auth manager.
+func (h *BaseAPIHandler) applyAccountHints(ctx context.Context, modelName string, providers []string, rawJSON []byte, metadata map[string]any) ([]string, map[string]any, *interfaces.ErrorMessage) {
+	var allowed []string
+	if rule := h.apiKeyRule(ctx); rule != nil {
+		allowed = trimmedNonEmpty(rule.AllowedAccounts)
+	}
+	hints := gjson.GetBytes(rawJSON, "x_cliproxy")
+	pin := strings.TrimSpace(hints.Get("account").String())
+	var excludes []string
+	for _, v := range hints.Get("exclude_accounts").Array() {
+		excludes = append(excludes, v.String())
+	}
+	excludes = trimmedNonEmpty(excludes)
+	if len(allowed) == 0 && pin == "" && len(excludes) == 0 {
+		return providers, metadata, nil
+	}
+	if metadata == nil {
+		metadata = make(map[string]any)
+	}
+	if len(allowed) > 0 {
+		metadata[coreauth.MetadataAccountAllowKey] = allowed
+	}
+	if pin == "" && len(excludes) == 0 {
+		return providers, metadata, nil
+	}
+	if h.AuthManager == nil {
+		return nil, nil, &interfaces.ErrorMessage{StatusCode: http.StatusServiceUnavailable, Error: fmt.Errorf("account hints are unavailable")}
+	}
+
+	var usable []*coreauth.Auth
+	for _, auth := range h.AuthManager.List() {
+		if !util.InArray(providers, auth.Provider) || !matchesAny(auth, allowed) {
+			continue
+		}
+		usable = append(usable, auth)
+	}
+
+	if pin != "" {
+		var pinned []string
+		var pinnedProviders []string
+		disabled := false
+		for _, auth := range usable {
+			if !auth.MatchesAccount(pin) {
+				continue
+			}
+			if auth.Disabled {
+				disabled = true
+				continue
+			}
+			pinned = append(pinned, auth.ID)
+			if !util.InArray(pinnedProviders, auth.Provider) {
+				pinnedProviders = append(pinnedProviders, auth.Provider)
+			}
+		}
+		if len(pinned) == 0 {
+			if disabled {
+				return nil, nil, &interfaces.ErrorMessage{StatusCode: http.StatusConflict, Error: fmt.Errorf("account %s is disabled", pin)}
+			}
+			return nil, nil, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("account %s is not available to this API key for model %s", pin, modelName)}
+		}
+		metadata[coreauth.MetadataAccountPinKey] = pinned
+		narrowed := make([]string, 0, len(pinnedProviders))
+		for _, provider := range providers {
+			if util.InArray(pinnedProviders, provider) {
+				narrowed = append(narrowed, provider)
+			}
+		}
+		providers = narrowed
+	}
+
+	if len(excludes) > 0 {
+		var excluded []string
+		for _, auth := range usable {
+			if matchesAny(auth, excludes) {
+				excluded = append(excluded, auth.ID)
+			}
+		}
+		if len(excluded) > 0 {
+			metadata[coreauth.MetadataAccountExcludeKey] = excluded
+		}
+	}
+	return providers, metadata, nil
+}
+
+// matchesAny reports whether auth matches one of refs; an empty refs matches everything.
+func matchesAny(auth *coreauth.Auth, refs []string) bool {
+	if len(refs) == 0 {
+		return true
+	}
+	for _, ref := range refs {
+		if auth.MatchesAccount(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimmedNonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
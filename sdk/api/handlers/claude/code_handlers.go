@@ -197,6 +197,8 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 }
 
 func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+	keepalive, stopKeepalive := h.StreamKeepalive()
+	defer stopKeepalive()
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -222,7 +224,7 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -231,6 +233,8 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 			}
 			cancel(execErr)
 			return
+		case <-keepalive:
+			h.WriteStreamKeepalive(c, flusher)
 		case <-time.After(500 * time.Millisecond):
 		}
 	}
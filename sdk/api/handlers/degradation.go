@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultDegradationTTL          = 24 * time.Hour
+	defaultDegradationEntries      = 512
+	defaultDegradationFinishReason = "degraded"
+
+	// maxDegradationStreamBytes bounds the size of a stream kept for replay.
+	maxDegradationStreamBytes = 4 << 20
+
+	// degradedHeader tells the client which kind of degraded answer it received:
+	// "cached" or "fallback".
+	degradedHeader = "X-CLIProxy-Degraded"
+)
+
+// degradationIgnoredFields are top-level request fields that do not change the answer.
+var degradationIgnoredFields = []string{"stream", "stream_options", "user", "metadata", "store", "request_id"}
+
+type degradationEntry struct {
+	key     string
+	chunks  [][]byte
+	expires time.Time
+}
+
+// degradationCache is an LRU of the last good answer for each request, shared by every
+// handler so that a model keeps its answers across config reloads.
+type degradationCache struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+var sharedDegradationCache = &degradationCache{order: list.New(), entries: make(map[string]*list.Element)}
+
+func (c *degradationCache) get(key string, now time.Time) ([][]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*degradationEntry)
+	if now.After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return cloneChunks(entry.chunks), true
+}
+
+func (c *degradationCache) put(key string, chunks [][]byte, expires time.Time, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*degradationEntry)
+		entry.chunks = cloneChunks(chunks)
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&degradationEntry{key: key, chunks: cloneChunks(chunks), expires: expires})
+	for c.order.Len() > maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*degradationEntry).key)
+	}
+}
+
+// degradationEnabled reports whether degraded answers are configured for modelName.
+func (h *BaseAPIHandler) degradationEnabled(modelName string) bool {
+	if h == nil || h.Cfg == nil || !h.Cfg.Degradation.Enabled {
+		return false
+	}
+	return len(h.Cfg.Degradation.Models) == 0 || modelAllowed(h.Cfg.Degradation.Models, modelName)
+}
+
+// rememberGoodAnswer keeps the chunks of a successful answer for later degraded requests.
+// A non-streaming answer is a single chunk.
+func (h *BaseAPIHandler) rememberGoodAnswer(ctx context.Context, handlerType, modelName string, rawJSON []byte, stream bool, chunks [][]byte) {
+	if !h.degradationEnabled(modelName) || len(chunks) == 0 {
+		return
+	}
+	key, ok := degradationKey(ctx, handlerType, modelName, rawJSON, stream)
+	if !ok {
+		return
+	}
+	ttl := defaultDegradationTTL
+	if minutes := h.Cfg.Degradation.CacheTTLMinutes; minutes > 0 {
+		ttl = time.Duration(minutes) * time.Minute
+	}
+	maxEntries := h.Cfg.Degradation.CacheEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultDegradationEntries
+	}
+	sharedDegradationCache.put(key, chunks, idgen.Now(ctx).Add(ttl), maxEntries)
+}
+
+// degradedAnswer returns the chunks served instead of errMsg: the last good answer for an
+// identical request, or else the configured fallback message. It returns nil when the
+// error is the client's fault or degradation does not apply.
+func (h *BaseAPIHandler) degradedAnswer(ctx context.Context, handlerType, modelName string, rawJSON []byte, stream bool, errMsg *interfaces.ErrorMessage) [][]byte {
+	if errMsg == nil || !degradableStatus(errMsg.StatusCode) || !h.degradationEnabled(modelName) {
+		return nil
+	}
+	kind := "cached"
+	var chunks [][]byte
+	if key, ok := degradationKey(ctx, handlerType, modelName, rawJSON, stream); ok {
+		chunks, _ = sharedDegradationCache.get(key, idgen.Now(ctx))
+	}
+	if len(chunks) == 0 {
+		message := h.Cfg.Degradation.FallbackMessage
+		if strings.TrimSpace(message) == "" {
+			return nil
+		}
+		finishReason := strings.TrimSpace(h.Cfg.Degradation.FinishReason)
+		if finishReason == "" {
+			finishReason = defaultDegradationFinishReason
+		}
+		kind = "fallback"
+		payload := fallbackPayload(ctx, handlerType, modelName, message, finishReason)
+		if payload == nil {
+			return nil
+		}
+		chunks = [][]byte{payload}
+		if stream {
+			chunks = fallbackStreamChunks(handlerType, payload, message, finishReason)
+		}
+	}
+	log.Warnf("serving %s answer for model %s: %v", kind, modelName, errMsg.Error)
+	if c := requestctx.Gin(ctx); c != nil {
+		c.Header(degradedHeader, kind)
+	}
+	return chunks
+}
+
+// degradableStatus reports whether a failure with status may be answered in degraded mode.
+// Malformed or oversized requests would fail the same way on a healthy account.
+func degradableStatus(status int) bool {
+	switch status {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return false
+	}
+	return true
+}
+
+// degradationKey hashes the model, handler format, streaming mode, client key and the
+// canonical request. Requests that are not valid JSON have no key.
+func degradationKey(ctx context.Context, handlerType, modelName string, rawJSON []byte, stream bool) (string, bool) {
+	var body map[string]any
+	if err := json.Unmarshal(rawJSON, &body); err != nil {
+		return "", false
+	}
+	for _, field := range degradationIgnoredFields {
+		delete(body, field)
+	}
+	canonical, err := json.Marshal(body)
+	if err != nil {
+		return "", false
+	}
+	mode := "once"
+	if stream {
+		mode = "stream"
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{strings.ToLower(strings.TrimSpace(modelName)), handlerType, mode, requestctx.Tenant(ctx), string(canonical)}, "\x00")))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// fallbackPayload builds a non-streaming response carrying message in the format of
+// handlerType, or nil when the format is unknown.
+func fallbackPayload(ctx context.Context, handlerType, modelName, message, finishReason string) []byte {
+	created := idgen.Now(ctx).Unix()
+	var out []byte
+	switch handlerType {
+	case "openai":
+		out = []byte(`{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant"}}],"usage":{"prompt_tokens":0,"completion_tokens":0,"total_tokens":0}}`)
+		out, _ = sjson.SetBytes(out, "id", idgen.NewID(ctx, "chatcmpl-"))
+		out, _ = sjson.SetBytes(out, "created", created)
+		out, _ = sjson.SetBytes(out, "model", modelName)
+		out, _ = sjson.SetBytes(out, "choices.0.message.content", message)
+		out, _ = sjson.SetBytes(out, "choices.0.finish_reason", finishReason)
+	case "openai-response":
+		out = []byte(`{"object":"response","status":"incomplete","output":[{"type":"message","status":"completed","role":"assistant","content":[{"type":"output_text","annotations":[]}]}],"usage":{"input_tokens":0,"output_tokens":0,"total_tokens":0}}`)
+		out, _ = sjson.SetBytes(out, "id", idgen.NewID(ctx, "resp_"))
+		out, _ = sjson.SetBytes(out, "created_at", created)
+		out, _ = sjson.SetBytes(out, "model", modelName)
+		out, _ = sjson.SetBytes(out, "incomplete_details.reason", finishReason)
+		out, _ = sjson.SetBytes(out, "output.0.id", idgen.NewID(ctx, "msg_"))
+		out, _ = sjson.SetBytes(out, "output.0.content.0.text", message)
+	case "claude":
+		out = []byte(`{"type":"message","role":"assistant","content":[{"type":"text"}],"stop_sequence":null,"usage":{"input_tokens":0,"output_tokens":0}}`)
+		out, _ = sjson.SetBytes(out, "id", idgen.NewID(ctx, "msg_"))
+		out, _ = sjson.SetBytes(out, "model", modelName)
+		out, _ = sjson.SetBytes(out, "content.0.text", message)
+		out, _ = sjson.SetBytes(out, "stop_reason", finishReason)
+	case "gemini", "gemini-cli":
+		out = []byte(`{"candidates":[{"content":{"role":"model","parts":[{}]},"index":0}],"usageMetadata":{"promptTokenCount":0,"candidatesTokenCount":0,"totalTokenCount":0}}`)
+		out, _ = sjson.SetBytes(out, "candidates.0.content.parts.0.text", message)
+		out, _ = sjson.SetBytes(out, "candidates.0.finishReason", finishReason)
+		out, _ = sjson.SetBytes(out, "modelVersion", modelName)
+		if handlerType == "gemini-cli" {
+			out, _ = sjson.SetRawBytes([]byte(`{}`), "response", out)
+		}
+	default:
+		return nil
+	}
+	return out
+}
+
+// fallbackStreamChunks splits the fallback response payload built by fallbackPayload into
+// the stream chunks of handlerType. Gemini streams carry whole responses, so the payload is
+// its own single chunk.
+func fallbackStreamChunks(handlerType string, payload []byte, message, finishReason string) [][]byte {
+	switch handlerType {
+	case "openai":
+		chunk := []byte(`{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant"}}]}`)
+		chunk, _ = sjson.SetBytes(chunk, "id", gjson.GetBytes(payload, "id").String())
+		chunk, _ = sjson.SetBytes(chunk, "created", gjson.GetBytes(payload, "created").Int())
+		chunk, _ = sjson.SetBytes(chunk, "model", gjson.GetBytes(payload, "model").String())
+		chunk, _ = sjson.SetBytes(chunk, "choices.0.delta.content", message)
+		chunk, _ = sjson.SetBytes(chunk, "choices.0.finish_reason", finishReason)
+		return [][]byte{chunk}
+	case "claude":
+		start, _ := sjson.SetRawBytes([]byte(`{"type":"message_start"}`), "message", payload)
+		start, _ = sjson.SetRawBytes(start, "message.content", []byte(`[]`))
+		start, _ = sjson.SetBytes(start, "message.stop_reason", nil)
+		delta, _ := sjson.SetBytes([]byte(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta"}}`), "delta.text", message)
+		stop, _ := sjson.SetBytes([]byte(`{"type":"message_delta","delta":{"stop_sequence":null},"usage":{"output_tokens":0}}`), "delta.stop_reason", finishReason)
+		return [][]byte{
+			sseEvent("message_start", start),
+			sseEvent("content_block_start", []byte(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)),
+			sseEvent("content_block_delta", delta),
+			sseEvent("content_block_stop", []byte(`{"type":"content_block_stop","index":0}`)),
+			sseEvent("message_delta", stop),
+			sseEvent("message_stop", []byte(`{"type":"message_stop"}`)),
+		}
+	case "openai-response":
+		item := gjson.GetBytes(payload, "output.0").Raw
+		created, _ := sjson.SetRawBytes([]byte(`{"type":"response.created","sequence_number":0}`), "response", payload)
+		created, _ = sjson.SetBytes(created, "response.status", "in_progress")
+		created, _ = sjson.SetRawBytes(created, "response.output", []byte(`[]`))
+		created, _ = sjson.SetBytes(created, "response.incomplete_details", nil)
+		added, _ := sjson.SetRawBytes([]byte(`{"type":"response.output_item.added","sequence_number":1,"output_index":0}`), "item", []byte(item))
+		added, _ = sjson.SetBytes(added, "item.status", "in_progress")
+		added, _ = sjson.SetRawBytes(added, "item.content", []byte(`[]`))
+		delta, _ := sjson.SetBytes([]byte(`{"type":"response.output_text.delta","sequence_number":2,"output_index":0,"content_index":0}`), "item_id", gjson.GetBytes(payload, "output.0.id").String())
+		delta, _ = sjson.SetBytes(delta, "delta", message)
+		done, _ := sjson.SetRawBytes([]byte(`{"type":"response.output_item.done","sequence_number":3,"output_index":0}`), "item", []byte(item))
+		incomplete, _ := sjson.SetRawBytes([]byte(`{"type":"response.incomplete","sequence_number":4}`), "response", payload)
+		return [][]byte{
+			sseEvent("response.created", created),
+			sseEvent("response.output_item.added", added),
+			sseEvent("response.output_text.delta", delta),
+			sseEvent("response.output_item.done", done),
+			sseEvent("response.incomplete", incomplete),
+		}
+	default:
+		return [][]byte{payload}
+	}
+}
+
+func sseEvent(event string, data []byte) []byte {
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n", event, data))
+}
+
+func cloneChunks(chunks [][]byte) [][]byte {
+	out := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		out[i] = bytes.Clone(chunk)
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// backendProviderHeader and backendModelHeader name the provider and model of the fallback
+	// chain step that answered a request.
+	backendProviderHeader = "X-CLIProxy-Provider"
+	backendModelHeader    = "X-CLIProxy-Model"
+)
+
+// chainStep is a fallback chain step the request may use.
+type chainStep struct {
+	provider string
+	model    string
+}
+
+// fallbackChain returns the fallback chain configured for model, or nil.
+func (h *BaseAPIHandler) fallbackChain(model string) *config.FallbackChain {
+	if h.Cfg == nil {
+		return nil
+	}
+	for i := range h.Cfg.FallbackChains {
+		chain := &h.Cfg.FallbackChains[i]
+		if strings.EqualFold(strings.TrimSpace(chain.Model), model) && len(chain.Steps) > 0 {
+			return chain
+		}
+	}
+	return nil
+}
+
+// chainProviders returns the distinct providers of chain in step order.
+func chainProviders(chain *config.FallbackChain) []string {
+	providers := make([]string, 0, len(chain.Steps))
+	for _, step := range chain.Steps {
+		provider := strings.ToLower(strings.TrimSpace(step.Provider))
+		if provider != "" && !util.InArray(providers, provider) {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+// chainSteps returns the steps of chain whose provider is among providers, the providers left
+// after API key rules and account hints.
+func chainSteps(chain *config.FallbackChain, model string, providers []string) []chainStep {
+	steps := make([]chainStep, 0, len(chain.Steps))
+	for _, step := range chain.Steps {
+		provider := strings.ToLower(strings.TrimSpace(step.Provider))
+		if provider == "" || !util.InArray(providers, provider) {
+			continue
+		}
+		stepModel := strings.TrimSpace(step.Model)
+		if stepModel == "" {
+			stepModel = model
+		}
+		steps = append(steps, chainStep{provider: provider, model: stepModel})
+	}
+	return steps
+}
+
+// runFallbackChain runs exec for each step until one succeeds or fails with an error that does
+// not cascade. The step that answered is recorded in the response headers.
+func runFallbackChain[T any](ctx context.Context, steps []chainStep, exec func(chainStep) (T, error)) (T, error) {
+	var zero T
+	var lastErr error
+	for i, step := range steps {
+		out, err := exec(step)
+		if err == nil {
+			requestctx.SetHeader(ctx, backendProviderHeader, step.provider)
+			requestctx.SetHeader(ctx, backendModelHeader, step.model)
+			return out, nil
+		}
+		lastErr = err
+		if !chainCascades(ctx, err) {
+			break
+		}
+		if i+1 < len(steps) {
+			log.Debugf("fallback chain: %s failed for model %s, trying %s: %v", step.provider, step.model, steps[i+1].provider, err)
+		}
+	}
+	if lastErr == nil {
+		lastErr = &coreauth.Error{Code: "provider_not_found", Message: "no provider of the fallback chain is available", HTTPStatus: http.StatusServiceUnavailable}
+	}
+	return zero, lastErr
+}
+
+// chainCascades reports whether a failed step passes the request on: rate limits, upstream
+// errors, transport errors and steps without an available account do; client errors do not.
+func chainCascades(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr coreexecutor.StatusError
+	if errors.As(err, &statusErr) && statusErr != nil {
+		if code := statusErr.StatusCode(); code > 0 {
+			return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// finishReasonPaths lists where each handler format carries its finish reason.
+// Paths containing "#" address every element of an array.
+var finishReasonPaths = map[string][]string{
+	"openai":          {"choices.#.finish_reason"},
+	"openai-response": {"incomplete_details.reason", "response.incomplete_details.reason"},
+	"claude":          {"stop_reason", "delta.stop_reason", "message.stop_reason"},
+	"gemini":          {"candidates.#.finishReason", "response.candidates.#.finishReason"},
+	"gemini-cli":      {"candidates.#.finishReason", "response.candidates.#.finishReason"},
+}
+
+// applyFinishReasonMap rewrites finish reasons in a response payload using the configured
+// mapping for handlerType. Payloads may be a single JSON document or SSE-framed lines.
+func (h *BaseAPIHandler) applyFinishReasonMap(handlerType string, payload []byte) []byte {
+	if h == nil || h.Cfg == nil || len(payload) == 0 {
+		return payload
+	}
+	mapping := h.Cfg.FinishReasonMap[handlerType]
+	paths := finishReasonPaths[handlerType]
+	if len(mapping) == 0 || len(paths) == 0 {
+		return payload
+	}
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return rewriteFinishReasons(payload, paths, mapping)
+	}
+	lines := bytes.Split(payload, []byte("\n"))
+	for i, line := range lines {
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(line[len("data:"):])
+		if len(data) == 0 || data[0] != '{' {
+			continue
+		}
+		rewritten := rewriteFinishReasons(data, paths, mapping)
+		lines[i] = append([]byte("data: "), rewritten...)
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+func rewriteFinishReasons(doc []byte, paths []string, mapping map[string]string) []byte {
+	for _, path := range paths {
+		prefix, suffix, isArray := strings.Cut(path, ".#.")
+		if !isArray {
+			doc = rewriteFinishReasonAt(doc, path, mapping)
+			continue
+		}
+		count := gjson.GetBytes(doc, prefix+".#").Int()
+		for i := int64(0); i < count; i++ {
+			doc = rewriteFinishReasonAt(doc, prefix+"."+strconv.FormatInt(i, 10)+"."+suffix, mapping)
+		}
+	}
+	return doc
+}
+
+func rewriteFinishReasonAt(doc []byte, path string, mapping map[string]string) []byte {
+	current := gjson.GetBytes(doc, path)
+	if current.Type != gjson.String {
+		return doc
+	}
+	mapped, ok := lookupFinishReason(mapping, current.String())
+	if !ok || mapped == current.String() {
+		return doc
+	}
+	if updated, err := sjson.SetBytes(doc, path, mapped); err == nil {
+		return updated
+	}
+	return doc
+}
+
+func lookupFinishReason(mapping map[string]string, value string) (string, bool) {
+	if mapped, ok := mapping[value]; ok {
+		return mapped, true
+	}
+	for key, mapped := range mapping {
+		if strings.EqualFold(key, value) {
+			return mapped, true
+		}
+	}
+	return "", false
+}
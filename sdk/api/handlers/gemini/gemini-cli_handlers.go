@@ -182,6 +182,13 @@ func (h *GeminiCLIAPIHandler) handleInternalGenerateContent(c *gin.Context, rawJ
 }
 
 func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flusher, alt string, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+	// Keepalives are SSE comments, so only SSE responses get them.
+	var keepalive <-chan time.Time
+	if alt == "" {
+		var stopKeepalive func()
+		keepalive, stopKeepalive = h.StreamKeepalive()
+		defer stopKeepalive()
+	}
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -212,7 +219,7 @@ func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flus
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -221,6 +228,8 @@ func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flus
 			}
 			cancel(execErr)
 			return
+		case <-keepalive:
+			h.WriteStreamKeepalive(c, flusher)
 		case <-time.After(500 * time.Millisecond):
 		}
 	}
@@ -0,0 +1,111 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// handleEmbedContent serves the embedContent and batchEmbedContents methods. The request is
+// converted to the shared OpenAI embeddings shape so any embedding-capable provider can
+// serve it, and the result is converted back to the Gemini response shape.
+//
+// Parameters:
+//   - c: The Gin context for the request
+//   - modelName: The embedding model from the request path
+//   - rawJSON: The raw JSON request body
+//   - batch: Whether the body is a batchEmbedContents request
+func (h *GeminiAPIHandler) handleEmbedContent(c *gin.Context, modelName string, rawJSON []byte, batch bool) {
+	body, errConvert := embeddingsRequestFromGemini(modelName, rawJSON, batch)
+	if errConvert != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: errConvert.Error(),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteEmbeddingsWithAuthManager(cliCtx, h.HandlerType(), modelName, body)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	_, _ = c.Writer.Write(geminiEmbeddingsResponse(resp, batch))
+	cliCancel()
+}
+
+// embeddingsRequestFromGemini converts an embedContent or batchEmbedContents body to an
+// OpenAI embeddings request. Each content becomes one input, its text parts joined by
+// newlines. A batch takes taskType and outputDimensionality from its first request.
+func embeddingsRequestFromGemini(modelName string, rawJSON []byte, batch bool) ([]byte, error) {
+	root := gjson.ParseBytes(rawJSON)
+	requests := []gjson.Result{root}
+	if batch {
+		requests = root.Get("requests").Array()
+		if len(requests) == 0 {
+			return nil, fmt.Errorf("requests must not be empty")
+		}
+	}
+	inputs := make([]string, 0, len(requests))
+	for _, request := range requests {
+		var texts []string
+		for _, part := range request.Get("content.parts").Array() {
+			if text := part.Get("text"); text.Exists() {
+				texts = append(texts, text.String())
+			}
+		}
+		if len(texts) == 0 {
+			return nil, fmt.Errorf("content must contain at least one text part")
+		}
+		inputs = append(inputs, strings.Join(texts, "\n"))
+	}
+
+	body := []byte(`{}`)
+	body, _ = sjson.SetBytes(body, "model", modelName)
+	body, _ = sjson.SetBytes(body, "input", inputs)
+	if taskType := requests[0].Get("taskType").String(); taskType != "" {
+		body, _ = sjson.SetBytes(body, "task_type", taskType)
+	}
+	if dims := requests[0].Get("outputDimensionality").Int(); dims > 0 {
+		body, _ = sjson.SetBytes(body, "dimensions", dims)
+	}
+	if ext := root.Get("x_cliproxy"); ext.IsObject() {
+		body, _ = sjson.SetRawBytes(body, "x_cliproxy", []byte(ext.Raw))
+	}
+	return body, nil
+}
+
+// geminiEmbeddingsResponse converts an OpenAI embeddings response to the embedContent
+// ({"embedding": ...}) or batchEmbedContents ({"embeddings": [...]}) shape.
+func geminiEmbeddingsResponse(resp []byte, batch bool) []byte {
+	data := gjson.GetBytes(resp, "data").Array()
+	if !batch {
+		values := "[]"
+		if len(data) > 0 && data[0].Get("embedding").IsArray() {
+			values = data[0].Get("embedding").Raw
+		}
+		out, _ := sjson.SetRawBytes([]byte(`{"embedding":{}}`), "embedding.values", []byte(values))
+		return out
+	}
+	out := []byte(`{"embeddings":[]}`)
+	for _, item := range data {
+		values := item.Get("embedding").Raw
+		if !item.Get("embedding").IsArray() {
+			values = "[]"
+		}
+		entry, _ := sjson.SetRawBytes([]byte(`{}`), "values", []byte(values))
+		out, _ = sjson.SetRawBytes(out, "embeddings.-1", entry)
+	}
+	return out
+}
@@ -54,7 +54,7 @@ func (h *GeminiAPIHandler) GeminiModels(c *gin.Context) {
 }
 
 // GeminiGetHandler handles GET requests for specific Gemini model information.
-// It returns detailed information about a specific Gemini model based on the action parameter.
+// It looks the model named by the action parameter up among the models currently available.
 func (h *GeminiAPIHandler) GeminiGetHandler(c *gin.Context) {
 	var request struct {
 		Action string `uri:"action" binding:"required"`
@@ -68,73 +68,17 @@ func (h *GeminiAPIHandler) GeminiGetHandler(c *gin.Context) {
 		})
 		return
 	}
-	switch request.Action {
-	case "gemini-2.5-pro":
-		c.JSON(http.StatusOK, gin.H{
-			"name":             "models/gemini-2.5-pro",
-			"version":          "2.5",
-			"displayName":      "Gemini 2.5 Pro",
-			"description":      "Stable release (June 17th, 2025) of Gemini 2.5 Pro",
-			"inputTokenLimit":  1048576,
-			"outputTokenLimit": 65536,
-			"supportedGenerationMethods": []string{
-				"generateContent",
-				"countTokens",
-				"createCachedContent",
-				"batchGenerateContent",
-			},
-			"temperature":    1,
-			"topP":           0.95,
-			"topK":           64,
-			"maxTemperature": 2,
-			"thinking":       true,
-		},
-		)
-	case "gemini-2.5-flash":
-		c.JSON(http.StatusOK, gin.H{
-			"name":             "models/gemini-2.5-flash",
-			"version":          "001",
-			"displayName":      "Gemini 2.5 Flash",
-			"description":      "Stable version of Gemini 2.5 Flash, our mid-size multimodal model that supports up to 1 million tokens, released in June of 2025.",
-			"inputTokenLimit":  1048576,
-			"outputTokenLimit": 65536,
-			"supportedGenerationMethods": []string{
-				"generateContent",
-				"countTokens",
-				"createCachedContent",
-				"batchGenerateContent",
-			},
-			"temperature":    1,
-			"topP":           0.95,
-			"topK":           64,
-			"maxTemperature": 2,
-			"thinking":       true,
-		})
-	case "gpt-5":
-		c.JSON(http.StatusOK, gin.H{
-			"name":             "gpt-5",
-			"version":          "001",
-			"displayName":      "GPT 5",
-			"description":      "Stable version of GPT 5, The best model for coding and agentic tasks across domains.",
-			"inputTokenLimit":  400000,
-			"outputTokenLimit": 128000,
-			"supportedGenerationMethods": []string{
-				"generateContent",
-			},
-			"temperature":    1,
-			"topP":           0.95,
-			"topK":           64,
-			"maxTemperature": 2,
-			"thinking":       true,
-		})
-	default:
+	model := registry.GetGlobalRegistry().GetAvailableModel(request.Action, "gemini")
+	if model == nil {
 		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
 			Error: handlers.ErrorDetail{
 				Message: "Not Found",
 				Type:    "not_found",
 			},
 		})
+		return
 	}
+	c.JSON(http.StatusOK, model)
 }
 
 // GeminiHandler handles POST requests for Gemini API operations.
@@ -173,6 +117,10 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		h.handleStreamGenerateContent(c, action[0], rawJSON)
 	case "countTokens":
 		h.handleCountTokens(c, action[0], rawJSON)
+	case "embedContent":
+		h.handleEmbedContent(c, action[0], rawJSON, false)
+	case "batchEmbedContents":
+		h.handleEmbedContent(c, action[0], rawJSON, true)
 	}
 }
 
@@ -259,6 +207,13 @@ func (h *GeminiAPIHandler) handleGenerateContent(c *gin.Context, modelName strin
 }
 
 func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flusher, alt string, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+	// Keepalives are SSE comments, so only SSE responses get them.
+	var keepalive <-chan time.Time
+	if alt == "" {
+		var stopKeepalive func()
+		keepalive, stopKeepalive = h.StreamKeepalive()
+		defer stopKeepalive()
+	}
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -282,7 +237,7 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -291,6 +246,8 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 			}
 			cancel(execErr)
 			return
+		case <-keepalive:
+			h.WriteStreamKeepalive(c, flusher)
 		case <-time.After(500 * time.Millisecond):
 		}
 	}
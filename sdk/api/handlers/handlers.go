@@ -4,17 +4,25 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 	"golang.org/x/net/context"
 )
 
@@ -97,7 +105,8 @@ func (h *BaseAPIHandler) GetAlt(c *gin.Context) string {
 }
 
 // GetContextWithCancel creates a new context with cancellation capabilities.
-// It embeds the Gin context and the API handler into the new context for later use.
+// It embeds the Gin context and the request metadata (see package requestctx) into the new
+// context for later use.
 // The returned cancel function also handles logging the API response if request logging is enabled.
 //
 // Parameters:
@@ -109,9 +118,10 @@ func (h *BaseAPIHandler) GetAlt(c *gin.Context) string {
 //   - context.Context: The new context with cancellation and embedded values.
 //   - APIHandlerCancelFunc: A function to cancel the context and log the response.
 func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *gin.Context, ctx context.Context) (context.Context, APIHandlerCancelFunc) {
-	newCtx, cancel := context.WithCancel(ctx)
-	newCtx = context.WithValue(newCtx, "gin", c)
-	newCtx = context.WithValue(newCtx, "handler", handler)
+	newCtx, cancel := context.WithCancel(idgen.Propagate(ctx, c.Request.Context()))
+	newCtx = requestctx.WithGin(newCtx, c)
+	newCtx = requestctx.WithMetadata(newCtx, requestMetadata(newCtx, handler, c))
+	requestctx.GuardResponse(c)
 	return newCtx, func(params ...interface{}) {
 		if h.Cfg.RequestLog {
 			if len(params) == 1 {
@@ -136,11 +146,15 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers := util.GetProviderName(modelName)
-	if len(providers) == 0 {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+	modelName, providers, errMsg := h.resolveModelRoute(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	metadata := h.buildGeminiWebMetadata(ctx, handlerType, providers, rawJSON)
+	providers, metadata, errMsg = h.applyAccountHints(ctx, modelName, providers, rawJSON, metadata)
+	if errMsg != nil {
+		return nil, errMsg
 	}
-	metadata := h.buildGeminiWebMetadata(handlerType, providers, rawJSON)
 	req := coreexecutor.Request{
 		Model:   modelName,
 		Payload: cloneBytes(rawJSON),
@@ -152,21 +166,46 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		SourceFormat:    sdktranslator.FromString(handlerType),
 		Metadata:        metadata,
 	}
-	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	var resp coreexecutor.Response
+	var err error
+	if chain := h.fallbackChain(modelName); chain != nil {
+		resp, err = runFallbackChain(ctx, chainSteps(chain, modelName, providers), func(step chainStep) (coreexecutor.Response, error) {
+			req.Model = step.model
+			return h.AuthManager.Execute(ctx, []string{step.provider}, req, opts)
+		})
+	} else {
+		route := h.pickTrafficRoute(ctx, modelName, providers, metadata)
+		providers, opts.Metadata = route.apply(&req, providers, metadata)
+		resp, err = h.AuthManager.Execute(ctx, providers, req, opts)
+		route.record(err == nil)
+	}
 	if err != nil {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		errMsg = errorMessageFromExecution(err)
+		if degraded := h.degradedAnswer(ctx, handlerType, modelName, rawJSON, false, errMsg); degraded != nil {
+			return degraded[0], nil
+		}
+		return nil, errMsg
 	}
-	return cloneBytes(resp.Payload), nil
+	payload, errMsg := moderateCompletion(h.applyFinishReasonMap(handlerType, cloneBytes(resp.Payload)))
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	h.rememberGoodAnswer(ctx, handlerType, modelName, rawJSON, false, [][]byte{payload})
+	return payload, nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers := util.GetProviderName(modelName)
-	if len(providers) == 0 {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+	modelName, providers, errMsg := h.resolveModelRoute(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	metadata := h.buildGeminiWebMetadata(ctx, handlerType, providers, rawJSON)
+	providers, metadata, errMsg = h.applyAccountHints(ctx, modelName, providers, rawJSON, metadata)
+	if errMsg != nil {
+		return nil, errMsg
 	}
-	metadata := h.buildGeminiWebMetadata(handlerType, providers, rawJSON)
 	req := coreexecutor.Request{
 		Model:   modelName,
 		Payload: cloneBytes(rawJSON),
@@ -178,60 +217,269 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 		SourceFormat:    sdktranslator.FromString(handlerType),
 		Metadata:        metadata,
 	}
-	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+	var resp coreexecutor.Response
+	var err error
+	if chain := h.fallbackChain(modelName); chain != nil {
+		resp, err = runFallbackChain(ctx, chainSteps(chain, modelName, providers), func(step chainStep) (coreexecutor.Response, error) {
+			req.Model = step.model
+			return h.AuthManager.ExecuteCount(ctx, []string{step.provider}, req, opts)
+		})
+	} else {
+		route := h.pickTrafficRoute(ctx, modelName, providers, metadata)
+		providers, opts.Metadata = route.apply(&req, providers, metadata)
+		resp, err = h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+		route.record(err == nil)
+	}
 	if err != nil {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		return nil, errorMessageFromExecution(err)
 	}
 	return cloneBytes(resp.Payload), nil
 }
 
-// ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
-// This path is the only supported execution route.
-func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers := util.GetProviderName(modelName)
-	if len(providers) == 0 {
+// ExecuteEmbeddingsWithAuthManager computes embeddings via the core auth manager. rawJSON is
+// an OpenAI embeddings request with "input" normalised to an array of strings; the result is
+// an OpenAI embeddings response.
+func (h *BaseAPIHandler) ExecuteEmbeddingsWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	modelName, providers, errMsg := h.resolveModelRoute(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	providers, metadata, errMsg := h.applyAccountHints(ctx, modelName, providers, rawJSON, nil)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if len(h.AuthManager.EmbeddingProviders(providers)) == 0 {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("model %s does not support embeddings", modelName)}
+	}
+	req := coreexecutor.Request{
+		Model:   modelName,
+		Payload: cloneBytes(rawJSON),
+	}
+	opts := coreexecutor.Options{
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+		Metadata:        metadata,
+	}
+	resp, err := h.AuthManager.ExecuteEmbeddings(ctx, providers, req, opts)
+	if err != nil {
+		return nil, errorMessageFromExecution(err)
+	}
+	return cloneBytes(resp.Payload), nil
+}
+
+// ExecuteTranscriptionWithAuthManager transcribes audio via the core auth manager. rawJSON is
+// a transcription request as described by coreauth.TranscriptionExecutor; the result is an
+// OpenAI verbose_json transcription.
+func (h *BaseAPIHandler) ExecuteTranscriptionWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	modelName, providers, errMsg := h.resolveModelRoute(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	providers, metadata, errMsg := h.applyAccountHints(ctx, modelName, providers, rawJSON, nil)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if len(h.AuthManager.TranscriptionProviders(providers)) == 0 {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("model %s does not support transcription", modelName)}
+	}
+	req := coreexecutor.Request{
+		Model:   modelName,
+		Payload: cloneBytes(rawJSON),
+	}
+	opts := coreexecutor.Options{
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+		Metadata:        metadata,
+	}
+	resp, err := h.AuthManager.ExecuteTranscription(ctx, providers, req, opts)
+	if err != nil {
+		return nil, errorMessageFromExecution(err)
+	}
+	return cloneBytes(resp.Payload), nil
+}
+
+// ExecuteSpeechWithAuthManager synthesizes speech via the core auth manager. rawJSON is an
+// OpenAI audio/speech request; the data channel carries the raw audio bytes and is closed
+// before the error channel, which holds at most one error.
+func (h *BaseAPIHandler) ExecuteSpeechWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	fail := func(errMsg *interfaces.ErrorMessage) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+		dataChan := make(chan []byte)
+		close(dataChan)
 		errChan := make(chan *interfaces.ErrorMessage, 1)
-		errChan <- &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+		errChan <- errMsg
 		close(errChan)
-		return nil, errChan
+		return dataChan, errChan
+	}
+	modelName, providers, errMsg := h.resolveModelRoute(ctx, modelName)
+	if errMsg != nil {
+		return fail(errMsg)
+	}
+	providers, metadata, errMsg := h.applyAccountHints(ctx, modelName, providers, rawJSON, nil)
+	if errMsg != nil {
+		return fail(errMsg)
+	}
+	if len(h.AuthManager.SpeechProviders(providers)) == 0 {
+		return fail(&interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("model %s does not support speech", modelName)})
 	}
-	metadata := h.buildGeminiWebMetadata(handlerType, providers, rawJSON)
 	req := coreexecutor.Request{
 		Model:   modelName,
 		Payload: cloneBytes(rawJSON),
 	}
 	opts := coreexecutor.Options{
 		Stream:          true,
-		Alt:             alt,
 		OriginalRequest: cloneBytes(rawJSON),
 		SourceFormat:    sdktranslator.FromString(handlerType),
 		Metadata:        metadata,
 	}
-	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	chunks, err := h.AuthManager.ExecuteSpeech(ctx, providers, req, opts)
 	if err != nil {
+		return fail(errorMessageFromExecution(err))
+	}
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer close(errChan)
+		defer close(dataChan)
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				errChan <- errorMessageFromExecution(chunk.Err)
+				return
+			}
+			if len(chunk.Payload) > 0 {
+				dataChan <- chunk.Payload
+			}
+		}
+	}()
+	return dataChan, errChan
+}
+
+// ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
+// This path is the only supported execution route.
+func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	modelName, providers, errMsg := h.resolveModelRoute(ctx, modelName)
+	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
-		errChan <- &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		errChan <- errMsg
 		close(errChan)
 		return nil, errChan
 	}
+	metadata := h.buildGeminiWebMetadata(ctx, handlerType, providers, rawJSON)
+	providers, metadata, errMsg = h.applyAccountHints(ctx, modelName, providers, rawJSON, metadata)
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	req := coreexecutor.Request{
+		Model:   modelName,
+		Payload: cloneBytes(rawJSON),
+	}
+	opts := coreexecutor.Options{
+		Stream:          true,
+		Alt:             alt,
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+		Metadata:        metadata,
+	}
 	dataChan := make(chan []byte)
 	errChan := make(chan *interfaces.ErrorMessage, 1)
+	// The request runs in the background so that the handler's stream loop, which writes
+	// keepalives, is already running while the executor waits for the first chunk.
 	go func() {
 		defer close(dataChan)
 		defer close(errChan)
+		var chunks <-chan coreexecutor.StreamChunk
+		var route *trafficRoute
+		var err error
+		if chain := h.fallbackChain(modelName); chain != nil {
+			chunks, err = runFallbackChain(ctx, chainSteps(chain, modelName, providers), func(step chainStep) (<-chan coreexecutor.StreamChunk, error) {
+				req.Model = step.model
+				return h.AuthManager.ExecuteStream(ctx, []string{step.provider}, req, opts)
+			})
+		} else {
+			route = h.pickTrafficRoute(ctx, modelName, providers, metadata)
+			providers, opts.Metadata = route.apply(&req, providers, metadata)
+			chunks, err = h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+		}
+		if err != nil {
+			route.record(false)
+			errMsg := errorMessageFromExecution(err)
+			if degraded := h.degradedAnswer(ctx, handlerType, modelName, rawJSON, true, errMsg); degraded != nil {
+				for _, payload := range degraded {
+					dataChan <- payload
+				}
+				return
+			}
+			errChan <- errMsg
+			return
+		}
+		// sent keeps the forwarded chunks for degraded replay; it is dropped once the
+		// stream outgrows maxDegradationStreamBytes.
+		var sent [][]byte
+		sentBytes, overflow, forwarded := 0, !h.degradationEnabled(modelName), false
 		for chunk := range chunks {
 			if chunk.Err != nil {
-				errChan <- &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: chunk.Err}
+				route.record(false)
+				errMsg := &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: chunk.Err}
+				if !forwarded {
+					// Nothing has reached the client yet, so a degraded answer can still replace the error.
+					if degraded := h.degradedAnswer(ctx, handlerType, modelName, rawJSON, true, errMsg); degraded != nil {
+						for _, payload := range degraded {
+							dataChan <- payload
+						}
+						return
+					}
+				}
+				errChan <- errMsg
 				return
 			}
 			if len(chunk.Payload) > 0 {
-				dataChan <- cloneBytes(chunk.Payload)
+				payload, errMsg := moderateCompletion(h.applyFinishReasonMap(handlerType, cloneBytes(chunk.Payload)))
+				if errMsg != nil {
+					route.record(false)
+					errChan <- errMsg
+					return
+				}
+				forwarded = true
+				if !overflow {
+					sentBytes += len(payload)
+					overflow = sentBytes > maxDegradationStreamBytes
+					sent = append(sent, payload)
+				}
+				dataChan <- payload
 			}
 		}
+		route.record(ctx.Err() == nil)
+		if !overflow && ctx.Err() == nil {
+			h.rememberGoodAnswer(ctx, handlerType, modelName, rawJSON, true, sent)
+		}
 	}()
 	return dataChan, errChan
 }
 
+// errorMessageFromExecution wraps an execution error for the client. Errors that carry
+// response headers, such as Retry-After from a saturated account, keep their status code
+// and headers; all other errors are reported as 500. Failures of every routing candidate are
+// counted in the usage statistics.
+func errorMessageFromExecution(err error) *interfaces.ErrorMessage {
+	var attemptsErr *coreauth.AttemptsError
+	if errors.As(err, &attemptsErr) {
+		usage.GetRequestStatistics().RecordAllCandidatesFailed(attemptsErr.Attempts)
+	}
+	msg := &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+	var withHeaders interface{ Headers() http.Header }
+	if errors.As(err, &withHeaders) && withHeaders != nil {
+		var statusErr coreexecutor.StatusError
+		if errors.As(err, &statusErr) && statusErr != nil && statusErr.StatusCode() > 0 {
+			msg.StatusCode = statusErr.StatusCode()
+		}
+		msg.Addon = withHeaders.Headers().Clone()
+	}
+	msg.Code = interfaces.ErrorCodeOf(err, msg.StatusCode)
+	return msg
+}
+
 func cloneBytes(src []byte) []byte {
 	if len(src) == 0 {
 		return nil
@@ -241,7 +489,7 @@ func cloneBytes(src []byte) []byte {
 	return dst
 }
 
-func (h *BaseAPIHandler) buildGeminiWebMetadata(handlerType string, providers []string, rawJSON []byte) map[string]any {
+func (h *BaseAPIHandler) buildGeminiWebMetadata(ctx context.Context, handlerType string, providers []string, rawJSON []byte) map[string]any {
 	if !util.InArray(providers, geminiWebProvider) {
 		return nil
 	}
@@ -250,26 +498,188 @@ func (h *BaseAPIHandler) buildGeminiWebMetadata(handlerType string, providers []
 	if len(msgs) > 0 {
 		meta[conversation.MetadataMessagesKey] = msgs
 	}
+	header := ""
+	if ginCtx := requestctx.Gin(ctx); ginCtx != nil {
+		header = ginCtx.GetHeader(conversation.ConversationIDHeader)
+	}
+	if ref, ok := conversation.ExplicitConversationID(header, rawJSON, requestctx.Tenant(ctx)); ok {
+		meta[conversation.MetadataConversationIDKey] = ref
+	}
 	return meta
 }
 
-// WriteErrorResponse writes an error message to the response writer using the HTTP status embedded in the message.
+// WriteErrorResponse writes an error message using the HTTP status embedded in the message. The
+// body is shaped like the API that was called and, with the X-CLIProxy-Error-Code header,
+// carries the machine-readable error code.
 func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.ErrorMessage) {
-	status := http.StatusInternalServerError
+	if msg != nil {
+		for key, values := range msg.Addon {
+			for _, value := range values {
+				c.Writer.Header().Add(key, value)
+			}
+		}
+	}
+	status, code, text := errorDetails(msg)
+	path := ""
+	if c.Request != nil {
+		path = c.Request.URL.Path
+	}
+	c.Header(interfaces.ErrorCodeHeader, code)
+	c.Header("Content-Type", "application/json")
+	c.Status(status)
+	_, _ = c.Writer.Write(errorBody(path, status, code, text))
+}
+
+// errorDetails returns the status code, error code and message reported for msg.
+func errorDetails(msg *interfaces.ErrorMessage) (status int, code, text string) {
+	status = http.StatusInternalServerError
 	if msg != nil && msg.StatusCode > 0 {
 		status = msg.StatusCode
 	}
-	c.Status(status)
-	if msg != nil && msg.Error != nil {
-		_, _ = c.Writer.Write([]byte(msg.Error.Error()))
-	} else {
-		_, _ = c.Writer.Write([]byte(http.StatusText(status)))
+	var err error
+	if msg != nil {
+		err = msg.Error
+		code = msg.Code
+	}
+	if code == "" {
+		code = interfaces.ErrorCodeOf(err, status)
+	}
+	text = http.StatusText(status)
+	if err != nil {
+		text = err.Error()
+	}
+	return status, code, text
+}
+
+// errorBody renders an error in the shape of the API at path: Gemini for /v1beta and
+// /v1internal, Claude for /v1/messages and OpenAI otherwise. JSON error bodies returned by
+// the upstream are kept and only gain the code where it is missing.
+func errorBody(path string, status int, code, text string) []byte {
+	gemini := strings.HasPrefix(path, "/v1beta") || strings.HasPrefix(path, "/v1internal")
+	claude := strings.HasPrefix(path, "/v1/messages")
+	if trimmed := strings.TrimSpace(text); gjson.Valid(trimmed) && gjson.Get(trimmed, "error").IsObject() {
+		body := []byte(trimmed)
+		switch existing := gjson.GetBytes(body, "error.code"); {
+		case existing.Type == gjson.Number:
+			// Gemini errors use a numeric code; the reason goes in an ErrorInfo detail.
+			body, _ = sjson.SetBytes(body, "error.details.-1", geminiErrorInfo(code))
+		case existing.String() == "":
+			body, _ = sjson.SetBytes(body, "error.code", code)
+		}
+		return body
+	}
+
+	var body []byte
+	switch {
+	case gemini:
+		body, _ = sjson.SetBytes([]byte(`{"error":{}}`), "error.code", status)
+		body, _ = sjson.SetBytes(body, "error.message", text)
+		body, _ = sjson.SetBytes(body, "error.status", geminiStatus(status))
+		body, _ = sjson.SetBytes(body, "error.details.-1", geminiErrorInfo(code))
+	case claude:
+		body, _ = sjson.SetBytes([]byte(`{"type":"error","error":{}}`), "error.type", claudeErrorType(status))
+		body, _ = sjson.SetBytes(body, "error.message", text)
+		body, _ = sjson.SetBytes(body, "error.code", code)
+	default:
+		body, _ = sjson.SetBytes([]byte(`{"error":{}}`), "error.message", text)
+		body, _ = sjson.SetBytes(body, "error.type", openAIErrorType(status))
+		body, _ = sjson.SetBytes(body, "error.code", code)
+	}
+	return body
+}
+
+func geminiErrorInfo(code string) map[string]any {
+	return map[string]any{
+		"@type":  "type.googleapis.com/google.rpc.ErrorInfo",
+		"reason": code,
+		"domain": "cliproxyapi",
+	}
+}
+
+// geminiStatus returns the google.rpc status name for an HTTP status.
+func geminiStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ABORTED"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		if status >= 400 && status < 500 {
+			return "FAILED_PRECONDITION"
+		}
+		return "INTERNAL"
+	}
+}
+
+func claudeErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		if status >= 400 && status < 500 {
+			return "invalid_request_error"
+		}
+		return "api_error"
+	}
+}
+
+func openAIErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status == http.StatusForbidden:
+		return "permission_error"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status >= 400 && status < 500:
+		return "invalid_request_error"
+	default:
+		return "server_error"
+	}
+}
+
+// requestMetadata describes the client request for requestctx. The correlation ID is taken
+// from the X-Request-ID header when the client sent one and is echoed back in the response;
+// the debug flag follows the server's debug setting.
+func requestMetadata(ctx context.Context, handler interfaces.APIHandler, c *gin.Context) requestctx.Metadata {
+	md := requestctx.Metadata{Tenant: c.GetString("apiKey")}
+	if handler != nil {
+		md.HandlerType = handler.HandlerType()
+	}
+	md.Debug = log.IsLevelEnabled(log.DebugLevel)
+	md.CorrelationID = strings.TrimSpace(c.GetHeader(requestctx.CorrelationHeader))
+	if md.CorrelationID == "" || len(md.CorrelationID) > 128 {
+		md.CorrelationID = idgen.NewID(ctx, "req_")
 	}
+	c.Header(requestctx.CorrelationHeader, md.CorrelationID)
+	return md
 }
 
 func (h *BaseAPIHandler) LoggingAPIResponseError(ctx context.Context, err *interfaces.ErrorMessage) {
 	if h.Cfg.RequestLog {
-		if ginContext, ok := ctx.Value("gin").(*gin.Context); ok {
+		if ginContext := requestctx.Gin(ctx); ginContext != nil {
 			if apiResponseErrors, isExist := ginContext.Get("API_RESPONSE_ERROR"); isExist {
 				if slicesAPIResponseError, isOk := apiResponseErrors.([]*interfaces.ErrorMessage); isOk {
 					slicesAPIResponseError = append(slicesAPIResponseError, err)
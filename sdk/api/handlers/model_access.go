@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/clientkeys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+)
+
+// resolveModelRoute applies the caller's API key rule (alias, model allowlist, provider
+// allowlist) and the global model aliases, and returns the model to execute with the
+// providers that may serve it. Models with a fallback chain are served by its providers.
+func (h *BaseAPIHandler) resolveModelRoute(ctx context.Context, modelName string) (string, []string, *interfaces.ErrorMessage) {
+	rule := h.apiKeyRule(ctx)
+	requested := modelName
+	if rule != nil {
+		if target, ok := lookupModelAlias(rule.ModelAliases, modelName); ok {
+			modelName = target
+		}
+	}
+	if h.Cfg != nil {
+		if target, ok := lookupModelAlias(h.Cfg.ModelAliases, modelName); ok {
+			modelName = target
+		}
+	}
+	// The allowlist applies to the model that will serve the request, so an allowed alias
+	// cannot reach a model the key may not use.
+	if rule != nil && len(rule.AllowedModels) > 0 && !modelAllowed(rule.AllowedModels, modelName) {
+		return "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("model %s is not allowed for this API key", requested)}
+	}
+
+	var providers []string
+	if chain := h.fallbackChain(modelName); chain != nil {
+		providers = chainProviders(chain)
+	} else {
+		providers = util.GetProviderName(modelName)
+	}
+	if len(providers) == 0 {
+		return "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+	}
+	if rule != nil && len(rule.AllowedProviders) > 0 {
+		allowed := make([]string, 0, len(providers))
+		for _, provider := range providers {
+			for _, candidate := range rule.AllowedProviders {
+				if strings.EqualFold(strings.TrimSpace(candidate), provider) {
+					allowed = append(allowed, provider)
+					break
+				}
+			}
+		}
+		if len(allowed) == 0 {
+			return "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("no allowed provider serves model %s for this API key", modelName)}
+		}
+		providers = allowed
+	}
+	return modelName, providers, nil
+}
+
+// apiKeyRule returns the rule configured for the authenticated client key, if any. Managed
+// client keys and principals granted allowed models without a configured rule get one holding
+// those models.
+func (h *BaseAPIHandler) apiKeyRule(ctx context.Context) *config.APIKeyRule {
+	if h.Cfg == nil || ctx == nil {
+		return nil
+	}
+	apiKey := requestctx.Tenant(ctx)
+	if apiKey == "" {
+		return nil
+	}
+	for i := range h.Cfg.APIKeyRules {
+		if h.Cfg.APIKeyRules[i].APIKey == apiKey {
+			return &h.Cfg.APIKeyRules[i]
+		}
+	}
+	if key, ok := clientkeys.Lookup(apiKey, requestctx.AccessMetadata(ctx)); ok && len(key.AllowedModels) > 0 {
+		return &config.APIKeyRule{APIKey: apiKey, AllowedModels: key.AllowedModels}
+	}
+	return nil
+}
+
+func lookupModelAlias(aliases map[string]string, model string) (string, bool) {
+	if target, ok := aliases[model]; ok && strings.TrimSpace(target) != "" {
+		return strings.TrimSpace(target), true
+	}
+	for alias, target := range aliases {
+		if strings.EqualFold(alias, model) && strings.TrimSpace(target) != "" {
+			return strings.TrimSpace(target), true
+		}
+	}
+	return "", false
+}
+
+func modelAllowed(patterns []string, model string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "*" || strings.EqualFold(pattern, model) {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(strings.ToLower(model), strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+)
+
+// moderateCompletion applies the completion moderation rules to a response payload or stream
+// chunk before it is returned or remembered. Prompts are filtered by the moderation middleware.
+func moderateCompletion(payload []byte) ([]byte, *interfaces.ErrorMessage) {
+	filtered, blockedBy := moderation.GetFilter().Apply(moderation.StageCompletion, payload)
+	if blockedBy != "" {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("response blocked by moderation rule %s", blockedBy)}
+	}
+	return filtered, nil
+}
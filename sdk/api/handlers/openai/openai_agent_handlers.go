@@ -0,0 +1,213 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/agent"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultAgentMaxSteps = 8
+	defaultAgentTimeout  = 120 * time.Second
+)
+
+// ChatCompletionsAction serves POST /v1/chat/:action, which carries the ":run" extension of
+// chat completions ("completions:run").
+func (h *OpenAIAPIHandler) ChatCompletionsAction(c *gin.Context) {
+	if c.Param("action") != "completions:run" {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "Not found", Type: "invalid_request_error"},
+		})
+		return
+	}
+	h.ChatCompletionsRun(c)
+}
+
+// agentToolCall records one tool executed by the agent loop.
+type agentToolCall struct {
+	Step int    `json:"step"`
+	Name string `json:"name"`
+}
+
+// ChatCompletionsRun runs a server-side agent loop: the model is called repeatedly while it
+// requests server-side tools (built-in or MCP), up to the configured step and time budget,
+// and the final answer is returned. Calls to tools the server does not know end the loop
+// and are returned to the client unchanged.
+func (h *OpenAIAPIHandler) ChatCompletionsRun(c *gin.Context) {
+	if h.Cfg == nil || !h.Cfg.AgentLoop.Enabled {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "The agent loop is not enabled on this server", Type: "invalid_request_error"},
+		})
+		return
+	}
+	rawJSON, err := c.GetRawData()
+	if err != nil || !gjson.ValidBytes(rawJSON) {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: fmt.Sprintf("Invalid request: %v", err), Type: "invalid_request_error"},
+		})
+		return
+	}
+	cfg := h.Cfg.AgentLoop
+	maxSteps := cfg.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultAgentMaxSteps
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultAgentTimeout
+	}
+	stream := gjson.GetBytes(rawJSON, "stream").Bool()
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	loopCtx, loopCancel := context.WithTimeout(cliCtx, timeout)
+	defer loopCancel()
+
+	toolbox := agent.NewToolbox(loopCtx, cfg)
+	payload, _ := sjson.DeleteBytes(rawJSON, "stream")
+	payload, _ = sjson.DeleteBytes(payload, "stream_options")
+	for _, tool := range toolbox.OpenAITools() {
+		name, _ := tool["function"].(map[string]any)["name"].(string)
+		if gjson.GetBytes(payload, fmt.Sprintf(`tools.#(function.name==%q)`, name)).Exists() {
+			continue
+		}
+		payload, _ = sjson.SetBytes(payload, "tools.-1", tool)
+	}
+
+	var (
+		resp      []byte
+		calls     []agentToolCall
+		usage     [3]int64
+		exhausted bool
+		steps     int
+	)
+	for steps = 1; ; steps++ {
+		if loopCtx.Err() != nil && resp != nil {
+			// The time budget ran out during tool calls; the last answer, with its
+			// unanswered tool calls, is returned as is.
+			exhausted = true
+			steps--
+			break
+		}
+		final := steps > maxSteps
+		if final {
+			exhausted = true
+			payload, _ = sjson.SetBytes(payload, "tool_choice", "none")
+		}
+		var errMsg *interfaces.ErrorMessage
+		resp, errMsg = h.ExecuteWithAuthManager(loopCtx, h.HandlerType(), modelName, payload, "")
+		if errMsg != nil {
+			if errors.Is(loopCtx.Err(), context.DeadlineExceeded) {
+				errMsg = &interfaces.ErrorMessage{StatusCode: http.StatusGatewayTimeout, Error: fmt.Errorf("agent loop exceeded its time budget of %s", timeout)}
+			}
+			h.WriteErrorResponse(c, errMsg)
+			cliCancel(errMsg.Error)
+			return
+		}
+		usage[0] += gjson.GetBytes(resp, "usage.prompt_tokens").Int()
+		usage[1] += gjson.GetBytes(resp, "usage.completion_tokens").Int()
+		usage[2] += gjson.GetBytes(resp, "usage.total_tokens").Int()
+		if final {
+			break
+		}
+
+		message := gjson.GetBytes(resp, "choices.0.message")
+		toolCalls := message.Get("tool_calls").Array()
+		if len(toolCalls) == 0 || !allServerSide(toolbox, toolCalls) {
+			break
+		}
+		payload, _ = sjson.SetRawBytes(payload, "messages.-1", []byte(message.Raw))
+		for _, call := range toolCalls {
+			name := call.Get("function.name").String()
+			args := call.Get("function.arguments").String()
+			result := toolbox.Call(loopCtx, name, json.RawMessage(args))
+			calls = append(calls, agentToolCall{Step: steps, Name: name})
+			payload, _ = sjson.SetBytes(payload, "messages.-1", map[string]any{
+				"role":         "tool",
+				"tool_call_id": call.Get("id").String(),
+				"content":      result,
+			})
+		}
+	}
+	resp, _ = sjson.SetBytes(resp, "usage.prompt_tokens", usage[0])
+	resp, _ = sjson.SetBytes(resp, "usage.completion_tokens", usage[1])
+	resp, _ = sjson.SetBytes(resp, "usage.total_tokens", usage[2])
+	if calls == nil {
+		calls = []agentToolCall{}
+	}
+	resp, _ = sjson.SetBytes(resp, "x_cliproxy.agent", map[string]any{
+		"steps":            steps,
+		"tool_calls":       calls,
+		"budget_exhausted": exhausted,
+	})
+
+	if !stream {
+		c.Header("Content-Type", "application/json")
+		_, _ = c.Writer.Write(resp)
+		cliCancel()
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+	for _, chunk := range completionToChunks(resp) {
+		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
+	}
+	_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+	if flusher, ok := c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	cliCancel()
+}
+
+func allServerSide(toolbox *agent.Toolbox, calls []gjson.Result) bool {
+	for _, call := range calls {
+		if !toolbox.Has(call.Get("function.name").String()) {
+			return false
+		}
+	}
+	return true
+}
+
+// completionToChunks re-expresses a chat completion as a streamed chunk sequence: one chunk
+// carrying the message and a final chunk carrying the finish reason, usage and extensions.
+func completionToChunks(resp []byte) [][]byte {
+	base := `{"object":"chat.completion.chunk"}`
+	for _, field := range []string{"id", "created", "model", "system_fingerprint"} {
+		if v := gjson.GetBytes(resp, field); v.Exists() {
+			base, _ = sjson.SetRaw(base, field, v.Raw)
+		}
+	}
+	message := gjson.GetBytes(resp, "choices.0.message")
+	first, _ := sjson.SetRaw(base, "choices", `[{"index":0,"delta":{},"finish_reason":null}]`)
+	first, _ = sjson.Set(first, "choices.0.delta.role", "assistant")
+	for _, field := range []string{"content", "reasoning_content", "tool_calls"} {
+		if v := message.Get(field); v.Exists() && v.Type != gjson.Null {
+			first, _ = sjson.SetRaw(first, "choices.0.delta."+field, v.Raw)
+		}
+	}
+	if calls := message.Get("tool_calls"); calls.IsArray() {
+		for i := range calls.Array() {
+			first, _ = sjson.Set(first, fmt.Sprintf("choices.0.delta.tool_calls.%d.index", i), i)
+		}
+	}
+	last, _ := sjson.SetRaw(base, "choices", `[{"index":0,"delta":{}}]`)
+	last, _ = sjson.Set(last, "choices.0.finish_reason", gjson.GetBytes(resp, "choices.0.finish_reason").String())
+	for _, field := range []string{"usage", "x_cliproxy"} {
+		if v := gjson.GetBytes(resp, field); v.Exists() {
+			last, _ = sjson.SetRaw(last, field, v.Raw)
+		}
+	}
+	return [][]byte{[]byte(first), []byte(last)}
+}
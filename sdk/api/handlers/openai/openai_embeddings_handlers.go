@@ -0,0 +1,119 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Embeddings handles the /v1/embeddings endpoint. The request is routed to a provider that
+// supports embeddings for the model; "input" may be a string or an array of strings, and
+// "encoding_format" may be "float" (default) or "base64".
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) Embeddings(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		writeEmbeddingsError(c, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	root := gjson.ParseBytes(rawJSON)
+	modelName := strings.TrimSpace(root.Get("model").String())
+	if modelName == "" {
+		writeEmbeddingsError(c, "model is required")
+		return
+	}
+	encoding := strings.ToLower(strings.TrimSpace(root.Get("encoding_format").String()))
+	if encoding != "" && encoding != "float" && encoding != "base64" {
+		writeEmbeddingsError(c, fmt.Sprintf("unsupported encoding_format: %s", encoding))
+		return
+	}
+	inputs, errInput := embeddingInputs(root.Get("input"))
+	if errInput != nil {
+		writeEmbeddingsError(c, errInput.Error())
+		return
+	}
+
+	body := []byte(`{}`)
+	body, _ = sjson.SetBytes(body, "model", modelName)
+	body, _ = sjson.SetBytes(body, "input", inputs)
+	if dims := root.Get("dimensions").Int(); dims > 0 {
+		body, _ = sjson.SetBytes(body, "dimensions", dims)
+	}
+	if user := root.Get("user").String(); user != "" {
+		body, _ = sjson.SetBytes(body, "user", user)
+	}
+	if ext := root.Get("x_cliproxy"); ext.IsObject() {
+		body, _ = sjson.SetRawBytes(body, "x_cliproxy", []byte(ext.Raw))
+	}
+
+	c.Header("Content-Type", "application/json")
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteEmbeddingsWithAuthManager(cliCtx, h.HandlerType(), modelName, body)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	if encoding == "base64" {
+		resp = base64Embeddings(resp)
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
+// embeddingInputs normalises an OpenAI "input" value to a list of strings. Token-array
+// inputs are rejected because not every provider accepts them.
+func embeddingInputs(input gjson.Result) ([]string, error) {
+	var out []string
+	switch {
+	case input.Type == gjson.String:
+		out = append(out, input.String())
+	case input.IsArray():
+		for _, item := range input.Array() {
+			if item.Type != gjson.String {
+				return nil, fmt.Errorf("input must be a string or an array of strings")
+			}
+			out = append(out, item.String())
+		}
+	default:
+		return nil, fmt.Errorf("input must be a string or an array of strings")
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("input must not be empty")
+	}
+	return out, nil
+}
+
+// base64Embeddings rewrites every embedding as base64 of little-endian float32 values, as
+// OpenAI does for encoding_format "base64".
+func base64Embeddings(resp []byte) []byte {
+	for i, item := range gjson.GetBytes(resp, "data").Array() {
+		values := item.Get("embedding").Array()
+		buf := make([]byte, 4*len(values))
+		for j, v := range values {
+			binary.LittleEndian.PutUint32(buf[4*j:], math.Float32bits(float32(v.Float())))
+		}
+		resp, _ = sjson.SetBytes(resp, fmt.Sprintf("data.%d.embedding", i), base64.StdEncoding.EncodeToString(buf))
+	}
+	return resp
+}
+
+func writeEmbeddingsError(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: message,
+			Type:    "invalid_request_error",
+		},
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -55,39 +56,31 @@ func (h *OpenAIAPIHandler) Models() []map[string]any {
 }
 
 // OpenAIModels handles the /v1/models endpoint.
-// It returns a list of available AI models with their capabilities
-// and specifications in OpenAI-compatible format.
+// It returns the models currently served by the configured accounts, including
+// context window sizes, modalities, alias targets and the providers behind each model.
 func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
-	// Get all available models
-	allModels := h.Models()
-
-	// Filter to only include the 4 required fields: id, object, created, owned_by
-	filteredModels := make([]map[string]any, len(allModels))
-	for i, model := range allModels {
-		filteredModel := map[string]any{
-			"id":     model["id"],
-			"object": model["object"],
-		}
-
-		// Add created field if it exists
-		if created, exists := model["created"]; exists {
-			filteredModel["created"] = created
-		}
-
-		// Add owned_by field if it exists
-		if ownedBy, exists := model["owned_by"]; exists {
-			filteredModel["owned_by"] = ownedBy
-		}
-
-		filteredModels[i] = filteredModel
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"object": "list",
-		"data":   filteredModels,
+		"data":   h.Models(),
 	})
 }
 
+// OpenAIModel handles the /v1/models/{model} endpoint and returns a single model entry.
+func (h *OpenAIAPIHandler) OpenAIModel(c *gin.Context) {
+	model := registry.GetGlobalRegistry().GetAvailableModel(c.Param("model"), "openai")
+	if model == nil {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("The model '%s' does not exist", c.Param("model")),
+				Type:    "invalid_request_error",
+				Code:    "model_not_found",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, model)
+}
+
 // ChatCompletions handles the /v1/chat/completions endpoint.
 // It determines whether the request is for a streaming or non-streaming response
 // and calls the appropriate handler based on the model provider.
@@ -107,6 +100,11 @@ func (h *OpenAIAPIHandler) ChatCompletions(c *gin.Context) {
 		return
 	}
 
+	if sessionID := gjson.GetBytes(rawJSON, "session_id").String(); sessionID != "" {
+		h.sessionChatCompletions(c, rawJSON, sessionID)
+		return
+	}
+
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
 	if streamResult.Type == gjson.True {
@@ -498,6 +496,8 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, chatCompletionsJSON, "")
 
+	keepalive, stopKeepalive := h.StreamKeepalive()
+	defer stopKeepalive()
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -505,7 +505,9 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 			return
 		case chunk, isOk := <-dataChan:
 			if !isOk {
-				_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+				if !requestctx.StreamDoneSkipped(c) {
+					_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+				}
 				flusher.Flush()
 				cliCancel()
 				return
@@ -520,7 +522,7 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -529,11 +531,15 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 			}
 			cliCancel(execErr)
 			return
+		case <-keepalive:
+			h.WriteStreamKeepalive(c, flusher)
 		case <-time.After(500 * time.Millisecond):
 		}
 	}
 }
 func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+	keepalive, stopKeepalive := h.StreamKeepalive()
+	defer stopKeepalive()
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -541,7 +547,9 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 			return
 		case chunk, ok := <-data:
 			if !ok {
-				_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+				if !requestctx.StreamDoneSkipped(c) {
+					_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+				}
 				flusher.Flush()
 				cancel(nil)
 				return
@@ -553,7 +561,7 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -562,6 +570,8 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 			}
 			cancel(execErr)
 			return
+		case <-keepalive:
+			h.WriteStreamKeepalive(c, flusher)
 		case <-time.After(500 * time.Millisecond):
 		}
 	}
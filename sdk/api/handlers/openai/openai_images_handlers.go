@@ -0,0 +1,193 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/assets"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// defaultImageModel is the model used when an images request does not name one.
+	defaultImageModel = "gemini-2.5-flash-image-preview"
+	// maxImagesPerRequest bounds the number of upstream generations a single request may trigger.
+	maxImagesPerRequest = 4
+)
+
+// ImageGenerations handles the /v1/images/generations endpoint.
+// The prompt is routed through the chat pipeline to an image-capable model and the
+// generated images are returned in the OpenAI images API shape as base64 payloads
+// ("b64_json"), or as links to copies re-hosted by the proxy ("url") when asset storage
+// is enabled.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageGenerations(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	root := gjson.ParseBytes(rawJSON)
+	prompt := strings.TrimSpace(root.Get("prompt").String())
+	if prompt == "" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "prompt is required",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	responseFormat := strings.ToLower(strings.TrimSpace(root.Get("response_format").String()))
+	if responseFormat == "" {
+		responseFormat = "b64_json"
+	}
+	if responseFormat == "url" && !assets.GetService().Enabled() {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "response_format url requires asset storage to be enabled; use b64_json",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	if responseFormat != "b64_json" && responseFormat != "url" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("unsupported response_format: %s; use b64_json or url", responseFormat),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	n := int(root.Get("n").Int())
+	if n <= 0 {
+		n = 1
+	}
+	if n > maxImagesPerRequest {
+		n = maxImagesPerRequest
+	}
+	modelName := strings.TrimSpace(root.Get("model").String())
+	if modelName == "" || strings.HasPrefix(modelName, "dall-e") || strings.HasPrefix(modelName, "gpt-image") {
+		modelName = defaultImageModel
+	}
+
+	chatJSON := convertImagesRequestToChatCompletions(modelName, prompt)
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+
+	data := make([]map[string]any, 0, n)
+	for i := 0; i < n && len(data) < n; i++ {
+		resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, chatJSON, "")
+		if errMsg != nil {
+			if len(data) > 0 {
+				// Return the images generated so far rather than discarding them.
+				break
+			}
+			h.WriteErrorResponse(c, errMsg)
+			cliCancel(errMsg.Error)
+			return
+		}
+		data = append(data, extractGeneratedImages(cliCtx, resp, responseFormat)...)
+	}
+	if len(data) > n {
+		// A single generation may return several images.
+		data = data[:n]
+	}
+
+	if len(data) == 0 {
+		c.JSON(http.StatusBadGateway, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "upstream returned no images",
+				Type:    "server_error",
+			},
+		})
+		cliCancel(fmt.Errorf("upstream returned no images"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"created": idgen.Now(c.Request.Context()).Unix(),
+		"data":    data,
+	})
+	cliCancel()
+}
+
+// convertImagesRequestToChatCompletions wraps an images prompt into a minimal chat completions request.
+func convertImagesRequestToChatCompletions(modelName, prompt string) []byte {
+	out := `{"model":"","messages":[{"role":"user","content":""}]}`
+	out, _ = sjson.Set(out, "model", modelName)
+	out, _ = sjson.Set(out, "messages.0.content", prompt)
+	return []byte(out)
+}
+
+// extractGeneratedImages collects the images attached to a chat completions response and
+// converts them into OpenAI images API entries in responseFormat. Inline images are
+// re-hosted for "url"; re-hosted images are read back for "b64_json".
+//
+// Parameters:
+//   - ctx: The request context, used to build re-hosted links
+//   - rawJSON: The raw JSON bytes of the chat completions response
+//   - responseFormat: "b64_json" or "url"
+//
+// Returns:
+//   - []map[string]any: One entry per generated image
+func extractGeneratedImages(ctx context.Context, rawJSON []byte, responseFormat string) []map[string]any {
+	out := make([]map[string]any, 0)
+	revisedPrompt := strings.TrimSpace(gjson.GetBytes(rawJSON, "choices.0.message.content").String())
+	gjson.GetBytes(rawJSON, "choices.0.message.images").ForEach(func(_, image gjson.Result) bool {
+		url := image.Get("image_url.url").String()
+		if url == "" {
+			return true
+		}
+		var entry map[string]any
+		if idx := strings.Index(url, ";base64,"); strings.HasPrefix(url, "data:") && idx >= 0 {
+			b64 := url[idx+len(";base64,"):]
+			entry = map[string]any{"b64_json": b64}
+			if responseFormat == "url" {
+				raw, err := base64.StdEncoding.DecodeString(b64)
+				if err != nil {
+					return true
+				}
+				link, err := assets.GetService().Store(ctx, raw, url[len("data:"):idx])
+				if err != nil {
+					log.Warnf("images: failed to re-host image: %v", err)
+					return true
+				}
+				entry = map[string]any{"url": link}
+			}
+		} else if i := strings.LastIndex(url, assets.RoutePath); i >= 0 {
+			entry = map[string]any{"url": url}
+			if responseFormat == "b64_json" {
+				obj, err := assets.GetService().Open(ctx, url[i+len(assets.RoutePath):])
+				if err != nil {
+					return true
+				}
+				entry = map[string]any{"b64_json": base64.StdEncoding.EncodeToString(obj.Data)}
+			}
+		} else {
+			return true
+		}
+		if revisedPrompt != "" {
+			entry["revised_prompt"] = revisedPrompt
+		}
+		out = append(out, entry)
+		return true
+	})
+	return out
+}
@@ -113,6 +113,7 @@ func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, r
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
+	resp = h.storeResponse(c, rawJSON, resp)
 	_, _ = c.Writer.Write(resp)
 	return
 
@@ -149,11 +150,21 @@ func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJ
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
-	h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+	var onChunk func([]byte)
+	if h.wantsStore(rawJSON) {
+		onChunk = func(chunk []byte) {
+			if resp, ok := completedResponse(chunk); ok {
+				h.storeResponse(c, rawJSON, resp)
+			}
+		}
+	}
+	h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, onChunk)
 	return
 }
 
-func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, onChunk func([]byte)) {
+	keepalive, stopKeepalive := h.StreamKeepalive()
+	defer stopKeepalive()
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -172,6 +183,9 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 			}
 			_, _ = c.Writer.Write(chunk)
 			_, _ = c.Writer.Write([]byte("\n"))
+			if onChunk != nil {
+				onChunk(chunk)
+			}
 
 			flusher.Flush()
 		case errMsg, ok := <-errs:
@@ -179,7 +193,7 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -188,6 +202,8 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 			}
 			cancel(execErr)
 			return
+		case <-keepalive:
+			h.WriteStreamKeepalive(c, flusher)
 		case <-time.After(500 * time.Millisecond):
 		}
 	}
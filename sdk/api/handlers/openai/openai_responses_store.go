@@ -0,0 +1,139 @@
+package openai
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultResponseStoreTTL = 30 * 24 * time.Hour
+
+// GetResponse handles GET /v1/responses/:id, returning a response stored with store=true.
+// Responses are only visible to the API key that created them.
+func (h *OpenAIResponsesAPIHandler) GetResponse(c *gin.Context) {
+	store, ok := h.responseStore(c)
+	if !ok {
+		return
+	}
+	rec, err := store.Get(c.Request.Context(), c.Param("id"), responsestore.OwnerOf(c.GetString("apiKey")))
+	if err != nil {
+		writeResponseStoreError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", rec.Response)
+}
+
+// DeleteResponse handles DELETE /v1/responses/:id.
+func (h *OpenAIResponsesAPIHandler) DeleteResponse(c *gin.Context) {
+	store, ok := h.responseStore(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	if err := store.Delete(c.Request.Context(), id, responsestore.OwnerOf(c.GetString("apiKey"))); err != nil {
+		writeResponseStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "response", "deleted": true})
+}
+
+func (h *OpenAIResponsesAPIHandler) responseStore(c *gin.Context) (*responsestore.Store, bool) {
+	if h.Cfg != nil && h.Cfg.ResponseStore.Disabled {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "Response storage is disabled on this server", Type: "invalid_request_error"},
+		})
+		return nil, false
+	}
+	store, err := responsestore.Default()
+	if err != nil {
+		log.Errorf("failed to open response store: %v", err)
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "Response store unavailable", Type: "server_error"},
+		})
+		return nil, false
+	}
+	return store, true
+}
+
+func writeResponseStoreError(c *gin.Context, err error) {
+	if errors.Is(err, responsestore.ErrNotFound) {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "No response found with id '" + c.Param("id") + "'", Type: "invalid_request_error"},
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{Message: err.Error(), Type: "server_error"},
+	})
+}
+
+// wantsStore reports whether the request asked for its response to be stored.
+func (h *OpenAIResponsesAPIHandler) wantsStore(rawJSON []byte) bool {
+	if h.Cfg != nil && h.Cfg.ResponseStore.Disabled {
+		return false
+	}
+	return gjson.GetBytes(rawJSON, "store").Type == gjson.True
+}
+
+// storeResponse persists resp for the requesting key, assigning an ID when the upstream
+// translation did not produce one, and returns the response as sent to the client.
+func (h *OpenAIResponsesAPIHandler) storeResponse(c *gin.Context, rawJSON, resp []byte) []byte {
+	if !h.wantsStore(rawJSON) || !gjson.ValidBytes(resp) {
+		return resp
+	}
+	id := gjson.GetBytes(resp, "id").String()
+	if id == "" {
+		id = responsestore.NewID(c.Request.Context())
+		resp, _ = sjson.SetBytes(resp, "id", id)
+	}
+	store, err := responsestore.Default()
+	if err != nil {
+		log.Errorf("failed to open response store: %v", err)
+		return resp
+	}
+	ttl := defaultResponseStoreTTL
+	if h.Cfg != nil && h.Cfg.ResponseStore.TTLHours > 0 {
+		ttl = time.Duration(h.Cfg.ResponseStore.TTLHours) * time.Hour
+	}
+	now := idgen.Now(c.Request.Context())
+	rec := responsestore.Record{
+		ID:        id,
+		Owner:     responsestore.OwnerOf(c.GetString("apiKey")),
+		Model:     gjson.GetBytes(resp, "model").String(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		Response:  bytes.Clone(resp),
+	}
+	if err = store.Put(c.Request.Context(), rec); err != nil {
+		log.Errorf("failed to store response %s: %v", id, err)
+	}
+	return resp
+}
+
+// completedResponse extracts the final response object from a response.completed event
+// in a streamed chunk.
+func completedResponse(chunk []byte) ([]byte, bool) {
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if gjson.GetBytes(data, "type").String() != "response.completed" {
+			continue
+		}
+		if response := gjson.GetBytes(data, "response"); response.IsObject() {
+			return []byte(response.Raw), true
+		}
+	}
+	return nil, false
+}
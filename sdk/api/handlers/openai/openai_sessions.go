@@ -0,0 +1,389 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultSessionTTL = 24 * time.Hour
+
+// CreateChatSession handles POST /v1/sessions. The optional body sets the default model and
+// the initial messages, such as a system prompt, of the session.
+func (h *OpenAIAPIHandler) CreateChatSession(c *gin.Context) {
+	store, ok := h.sessionStore(c)
+	if !ok {
+		return
+	}
+	rawJSON, err := c.GetRawData()
+	if err != nil || (len(bytes.TrimSpace(rawJSON)) > 0 && !gjson.ValidBytes(rawJSON)) {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: fmt.Sprintf("Invalid request: %v", err), Type: "invalid_request_error"},
+		})
+		return
+	}
+	messages := gjson.GetBytes(rawJSON, "messages")
+	if messages.Exists() && !messages.IsArray() {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "messages must be an array", Type: "invalid_request_error"},
+		})
+		return
+	}
+	var initial []string
+	for _, msg := range messages.Array() {
+		initial = append(initial, msg.Raw)
+	}
+	ctx := c.Request.Context()
+	now := idgen.Now(ctx)
+	sess := responsestore.Session{
+		ID:        responsestore.NewSessionID(ctx),
+		Owner:     responsestore.OwnerOf(c.GetString("apiKey")),
+		Model:     gjson.GetBytes(rawJSON, "model").String(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(h.sessionTTL()),
+		Messages:  []byte(joinMessages(initial)),
+	}
+	if err = store.PutSession(ctx, sess); err != nil {
+		writeSessionError(c, sess.ID, err)
+		return
+	}
+	c.JSON(http.StatusOK, sessionView(sess))
+}
+
+// GetChatSession handles GET /v1/sessions/:id, returning the session and its history.
+// Sessions are only visible to the API key that created them.
+func (h *OpenAIAPIHandler) GetChatSession(c *gin.Context) {
+	store, ok := h.sessionStore(c)
+	if !ok {
+		return
+	}
+	sess, err := store.GetSession(c.Request.Context(), c.Param("id"), responsestore.OwnerOf(c.GetString("apiKey")))
+	if err != nil {
+		writeSessionError(c, c.Param("id"), err)
+		return
+	}
+	c.JSON(http.StatusOK, sessionView(sess))
+}
+
+// DeleteChatSession handles DELETE /v1/sessions/:id.
+func (h *OpenAIAPIHandler) DeleteChatSession(c *gin.Context) {
+	store, ok := h.sessionStore(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	if err := store.DeleteSession(c.Request.Context(), id, responsestore.OwnerOf(c.GetString("apiKey"))); err != nil {
+		writeSessionError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "chat.session", "deleted": true})
+}
+
+func sessionView(sess responsestore.Session) gin.H {
+	return gin.H{
+		"id":         sess.ID,
+		"object":     "chat.session",
+		"model":      sess.Model,
+		"created_at": sess.CreatedAt.Unix(),
+		"updated_at": sess.UpdatedAt.Unix(),
+		"expires_at": sess.ExpiresAt.Unix(),
+		"messages":   sess.Messages,
+	}
+}
+
+func (h *OpenAIAPIHandler) sessionStore(c *gin.Context) (*responsestore.Store, bool) {
+	if h.Cfg == nil || !h.Cfg.Sessions.Enabled {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "Sessions are not enabled on this server", Type: "invalid_request_error"},
+		})
+		return nil, false
+	}
+	store, err := responsestore.Default()
+	if err != nil {
+		log.Errorf("failed to open response store: %v", err)
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "Session store unavailable", Type: "server_error"},
+		})
+		return nil, false
+	}
+	return store, true
+}
+
+func (h *OpenAIAPIHandler) sessionTTL() time.Duration {
+	if h.Cfg != nil && h.Cfg.Sessions.TTLHours > 0 {
+		return time.Duration(h.Cfg.Sessions.TTLHours) * time.Hour
+	}
+	return defaultSessionTTL
+}
+
+func writeSessionError(c *gin.Context, id string, err error) {
+	if errors.Is(err, responsestore.ErrNotFound) {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "No session found with id '" + id + "'", Type: "invalid_request_error", Code: "session_not_found"},
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{Message: err.Error(), Type: "server_error"},
+	})
+}
+
+// sessionTurn is a chat completions request running in a session. The session stays locked
+// until the turn is saved or abandoned.
+type sessionTurn struct {
+	store    *responsestore.Store
+	session  responsestore.Session
+	messages []string
+	unlock   func()
+}
+
+// save appends the assistant reply to the history of the turn and stores the session.
+func (t *sessionTurn) save(ctx context.Context, reply string, maxMessages int, ttl time.Duration) {
+	messages := trimHistory(append(t.messages, reply), maxMessages)
+	now := idgen.Now(ctx)
+	t.session.Messages = []byte(joinMessages(messages))
+	t.session.UpdatedAt = now
+	t.session.ExpiresAt = now.Add(ttl)
+	if err := t.store.PutSession(ctx, t.session); err != nil {
+		log.Errorf("failed to save session %s: %v", t.session.ID, err)
+	}
+}
+
+// sessionChatCompletions runs a chat completions request that names a session_id: the
+// messages of the request are appended to the stored history, the whole conversation is sent
+// upstream, and the reply is appended to the history once the response completes.
+func (h *OpenAIAPIHandler) sessionChatCompletions(c *gin.Context, rawJSON []byte, sessionID string) {
+	store, ok := h.sessionStore(c)
+	if !ok {
+		return
+	}
+	newMessages := gjson.GetBytes(rawJSON, "messages")
+	if !newMessages.IsArray() || len(newMessages.Array()) == 0 {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: "messages must hold the new messages of the turn", Type: "invalid_request_error"},
+		})
+		return
+	}
+	unlock := store.LockSession(sessionID)
+	sess, err := store.GetSession(c.Request.Context(), sessionID, responsestore.OwnerOf(c.GetString("apiKey")))
+	if err != nil {
+		unlock()
+		writeSessionError(c, sessionID, err)
+		return
+	}
+
+	var messages []string
+	for _, msg := range gjson.ParseBytes(sess.Messages).Array() {
+		messages = append(messages, msg.Raw)
+	}
+	for _, msg := range newMessages.Array() {
+		messages = append(messages, msg.Raw)
+	}
+	body, _ := sjson.DeleteBytes(rawJSON, "session_id")
+	body, _ = sjson.SetRawBytes(body, "messages", []byte(joinMessages(messages)))
+	if gjson.GetBytes(body, "model").String() == "" && sess.Model != "" {
+		body, _ = sjson.SetBytes(body, "model", sess.Model)
+	}
+	c.Header("X-Session-ID", sessionID)
+
+	turn := &sessionTurn{store: store, session: sess, messages: messages, unlock: unlock}
+	if gjson.GetBytes(body, "stream").Type == gjson.True {
+		h.handleSessionStreamingResponse(c, body, turn)
+	} else {
+		h.handleSessionNonStreamingResponse(c, body, turn)
+	}
+}
+
+func (h *OpenAIAPIHandler) handleSessionNonStreamingResponse(c *gin.Context, rawJSON []byte, turn *sessionTurn) {
+	defer turn.unlock()
+	c.Header("Content-Type", "application/json")
+
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	if reply := gjson.GetBytes(resp, "choices.0.message"); reply.IsObject() {
+		turn.save(c.Request.Context(), reply.Raw, h.Cfg.Sessions.MaxMessages, h.sessionTTL())
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
+func (h *OpenAIAPIHandler) handleSessionStreamingResponse(c *gin.Context, rawJSON []byte, turn *sessionTurn) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		turn.unlock()
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Streaming not supported",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
+	if dataChan == nil {
+		turn.unlock()
+		h.handleStreamResult(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+		return
+	}
+
+	// The chunks are copied to the client and into the reply; the turn is saved only when the
+	// stream ends without an error and without the client going away.
+	var reply streamedReply
+	var completed, failed bool
+	var wg sync.WaitGroup
+	data := make(chan []byte)
+	errs := make(chan *interfaces.ErrorMessage, 1)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(data)
+		for chunk := range dataChan {
+			reply.add(chunk)
+			select {
+			case data <- chunk:
+			case <-cliCtx.Done():
+			}
+		}
+		completed = cliCtx.Err() == nil
+	}()
+	go func() {
+		defer wg.Done()
+		defer close(errs)
+		for errMsg := range errChan {
+			if errMsg != nil {
+				failed = true
+			}
+			select {
+			case errs <- errMsg:
+			case <-cliCtx.Done():
+			}
+		}
+	}()
+	reqCtx := c.Request.Context()
+	maxMessages, ttl := h.Cfg.Sessions.MaxMessages, h.sessionTTL()
+	go func() {
+		defer turn.unlock()
+		wg.Wait()
+		if completed && !failed {
+			turn.save(reqCtx, reply.message(), maxMessages, ttl)
+		}
+	}()
+	h.handleStreamResult(c, flusher, func(err error) { cliCancel(err) }, data, errs)
+}
+
+// streamedReply rebuilds the assistant message from chat completion chunks.
+type streamedReply struct {
+	content   strings.Builder
+	toolCalls map[int64]*streamedToolCall
+}
+
+type streamedToolCall struct {
+	id        string
+	name      string
+	arguments strings.Builder
+}
+
+func (r *streamedReply) add(chunk []byte) {
+	delta := gjson.GetBytes(chunk, "choices.0.delta")
+	r.content.WriteString(delta.Get("content").String())
+	for _, call := range delta.Get("tool_calls").Array() {
+		if r.toolCalls == nil {
+			r.toolCalls = make(map[int64]*streamedToolCall)
+		}
+		index := call.Get("index").Int()
+		tc := r.toolCalls[index]
+		if tc == nil {
+			tc = &streamedToolCall{}
+			r.toolCalls[index] = tc
+		}
+		if id := call.Get("id").String(); id != "" {
+			tc.id = id
+		}
+		if name := call.Get("function.name").String(); name != "" {
+			tc.name = name
+		}
+		tc.arguments.WriteString(call.Get("function.arguments").String())
+	}
+}
+
+func (r *streamedReply) message() string {
+	msg := `{"role":"assistant"}`
+	if r.content.Len() > 0 || len(r.toolCalls) == 0 {
+		msg, _ = sjson.Set(msg, "content", r.content.String())
+	} else {
+		msg, _ = sjson.SetRaw(msg, "content", "null")
+	}
+	indexes := make([]int64, 0, len(r.toolCalls))
+	for index := range r.toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	for _, index := range indexes {
+		tc := r.toolCalls[index]
+		call := `{"type":"function"}`
+		call, _ = sjson.Set(call, "id", tc.id)
+		call, _ = sjson.Set(call, "function.name", tc.name)
+		call, _ = sjson.Set(call, "function.arguments", tc.arguments.String())
+		msg, _ = sjson.SetRaw(msg, "tool_calls.-1", call)
+	}
+	return msg
+}
+
+// trimHistory drops the oldest messages beyond maxMessages, keeping the leading system
+// messages and never starting the kept history with orphaned tool results.
+func trimHistory(messages []string, maxMessages int) []string {
+	if maxMessages <= 0 || len(messages) <= maxMessages {
+		return messages
+	}
+	system := 0
+	for system < len(messages) {
+		role := gjson.Get(messages[system], "role").String()
+		if role != "system" && role != "developer" {
+			break
+		}
+		system++
+	}
+	rest := messages[system:]
+	if keep := maxMessages - system; keep < len(rest) {
+		rest = rest[len(rest)-max(keep, 0):]
+	}
+	for len(rest) > 0 && gjson.Get(rest[0], "role").String() == "tool" {
+		rest = rest[1:]
+	}
+	return append(append([]string(nil), messages[:system]...), rest...)
+}
+
+// joinMessages builds a JSON array from raw messages.
+func joinMessages(messages []string) string {
+	return "[" + strings.Join(messages, ",") + "]"
+}
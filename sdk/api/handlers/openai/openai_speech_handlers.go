@@ -0,0 +1,153 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxSpeechInputChars is the longest input OpenAI accepts for audio/speech.
+const maxSpeechInputChars = 4096
+
+// speechContentTypes maps the OpenAI response_format values to the Content-Type returned.
+var speechContentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"aac":  "audio/aac",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"pcm":  "audio/pcm",
+}
+
+// AudioSpeech handles the /v1/audio/speech endpoint. The request is routed to a provider that
+// synthesizes speech for the model and the audio is streamed back as it is produced, either
+// as raw bytes or, with "stream_format": "sse", as speech.audio.delta events.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) AudioSpeech(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		writeAudioError(c, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	root := gjson.ParseBytes(rawJSON)
+	modelName := strings.TrimSpace(root.Get("model").String())
+	if modelName == "" {
+		writeAudioError(c, "model is required")
+		return
+	}
+	input := root.Get("input").String()
+	if strings.TrimSpace(input) == "" {
+		writeAudioError(c, "input is required")
+		return
+	}
+	if utf8.RuneCountInString(input) > maxSpeechInputChars {
+		writeAudioError(c, fmt.Sprintf("input is longer than %d characters", maxSpeechInputChars))
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(root.Get("response_format").String()))
+	if _, ok := speechContentTypes[format]; format != "" && !ok {
+		writeAudioError(c, fmt.Sprintf("unsupported response_format: %s", format))
+		return
+	}
+	streamFormat := strings.ToLower(strings.TrimSpace(root.Get("stream_format").String()))
+	if streamFormat != "" && streamFormat != "audio" && streamFormat != "sse" {
+		writeAudioError(c, fmt.Sprintf("unsupported stream_format: %s", streamFormat))
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Streaming not supported",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	dataChan, errChan := h.ExecuteSpeechWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON)
+
+	// Wait for the first chunk so that failures before any audio are returned as JSON errors.
+	first, ok := <-dataChan
+	if !ok {
+		errMsg := <-errChan
+		if errMsg == nil {
+			errMsg = &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: fmt.Errorf("upstream returned no audio")}
+		}
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+
+	sse := streamFormat == "sse"
+	if sse {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+	} else if contentType := speechContentTypes[format]; contentType != "" {
+		c.Header("Content-Type", contentType)
+	} else {
+		c.Header("Content-Type", http.DetectContentType(first))
+	}
+	c.Status(http.StatusOK)
+	writeSpeechChunk(c, sse, first)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			cliCancel(c.Request.Context().Err())
+			return
+		case chunk, isOk := <-dataChan:
+			if !isOk {
+				var execErr error
+				if errMsg := <-errChan; errMsg != nil {
+					execErr = errMsg.Error
+					if sse {
+						event, _ := sjson.SetBytes([]byte(`{"type":"error","error":{"type":"server_error"}}`), "error.message", errMsg.Error.Error())
+						_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", event)
+					}
+				} else if sse {
+					_, _ = fmt.Fprint(c.Writer, "data: {\"type\":\"speech.audio.done\"}\n\n")
+				}
+				flusher.Flush()
+				cliCancel(execErr)
+				return
+			}
+			writeSpeechChunk(c, sse, chunk)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSpeechChunk writes audio bytes as is, or as a speech.audio.delta event when sse is set.
+func writeSpeechChunk(c *gin.Context, sse bool, audio []byte) {
+	if !sse {
+		_, _ = c.Writer.Write(audio)
+		return
+	}
+	event, _ := sjson.SetBytes([]byte(`{"type":"speech.audio.delta"}`), "audio", base64.StdEncoding.EncodeToString(audio))
+	_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", event)
+}
+
+// writeAudioError reports an invalid audio request with 400.
+func writeAudioError(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: message,
+			Type:    "invalid_request_error",
+		},
+	})
+}
@@ -0,0 +1,164 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxTranscriptionFileBytes is the largest audio file OpenAI accepts for transcription.
+const maxTranscriptionFileBytes = 25 << 20
+
+// AudioTranscriptions handles the /v1/audio/transcriptions endpoint. The multipart upload is
+// routed to a provider that transcribes audio for the model, and the verbose_json result is
+// rendered in the requested response_format: json (default), text, srt, vtt or verbose_json.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) AudioTranscriptions(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTranscriptionFileBytes+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		writeAudioError(c, fmt.Sprintf("file is required: %v", err))
+		return
+	}
+	if fileHeader.Size > maxTranscriptionFileBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("file is larger than %d MB", maxTranscriptionFileBytes>>20),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	modelName := strings.TrimSpace(c.PostForm("model"))
+	if modelName == "" {
+		writeAudioError(c, "model is required")
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(c.PostForm("response_format")))
+	switch format {
+	case "":
+		format = "json"
+	case "json", "text", "srt", "vtt", "verbose_json":
+	default:
+		writeAudioError(c, fmt.Sprintf("unsupported response_format: %s", format))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		writeAudioError(c, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	audio, err := io.ReadAll(file)
+	_ = file.Close()
+	if err != nil {
+		writeAudioError(c, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	mimeType, _, _ := strings.Cut(fileHeader.Header.Get("Content-Type"), ";")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileHeader.Filename))); byExt != "" {
+			mimeType, _, _ = strings.Cut(byExt, ";")
+		} else {
+			mimeType, _, _ = strings.Cut(http.DetectContentType(audio), ";")
+		}
+	}
+
+	body := []byte(`{}`)
+	body, _ = sjson.SetBytes(body, "model", modelName)
+	body, _ = sjson.SetBytes(body, "filename", fileHeader.Filename)
+	body, _ = sjson.SetBytes(body, "mime_type", mimeType)
+	body, _ = sjson.SetBytes(body, "response_format", format)
+	for _, key := range []string{"language", "prompt"} {
+		if value := strings.TrimSpace(c.PostForm(key)); value != "" {
+			body, _ = sjson.SetBytes(body, key, value)
+		}
+	}
+	if value := strings.TrimSpace(c.PostForm("temperature")); value != "" {
+		temperature, errParse := strconv.ParseFloat(value, 64)
+		if errParse != nil {
+			writeAudioError(c, fmt.Sprintf("invalid temperature: %s", value))
+			return
+		}
+		body, _ = sjson.SetBytes(body, "temperature", temperature)
+	}
+	body, _ = sjson.SetBytes(body, "file", base64.StdEncoding.EncodeToString(audio))
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteTranscriptionWithAuthManager(cliCtx, h.HandlerType(), modelName, body)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	contentType, out := renderTranscription(resp, format)
+	c.Data(http.StatusOK, contentType, out)
+	cliCancel()
+}
+
+// renderTranscription renders a verbose_json transcription in an OpenAI response_format.
+func renderTranscription(verbose []byte, format string) (string, []byte) {
+	root := gjson.ParseBytes(verbose)
+	switch format {
+	case "verbose_json":
+		return "application/json", verbose
+	case "text":
+		return "text/plain; charset=utf-8", []byte(root.Get("text").String() + "\n")
+	case "srt", "vtt":
+		var b strings.Builder
+		if format == "vtt" {
+			b.WriteString("WEBVTT\n\n")
+		}
+		segments := root.Get("segments").Array()
+		if len(segments) == 0 && root.Get("text").String() != "" {
+			// Without timings the whole transcript becomes one cue spanning the audio.
+			cue, _ := sjson.SetBytes([]byte(`{"start":0}`), "end", root.Get("duration").Float())
+			cue, _ = sjson.SetBytes(cue, "text", root.Get("text").String())
+			segments = []gjson.Result{gjson.ParseBytes(cue)}
+		}
+		for i, segment := range segments {
+			if format == "srt" {
+				fmt.Fprintf(&b, "%d\n", i+1)
+			}
+			start := subtitleTimestamp(segment.Get("start").Float(), format)
+			end := subtitleTimestamp(segment.Get("end").Float(), format)
+			fmt.Fprintf(&b, "%s --> %s\n%s\n\n", start, end, strings.TrimSpace(segment.Get("text").String()))
+		}
+		contentType := "application/x-subrip; charset=utf-8"
+		if format == "vtt" {
+			contentType = "text/vtt; charset=utf-8"
+		}
+		return contentType, []byte(b.String())
+	default:
+		out, _ := sjson.SetBytes([]byte(`{}`), "text", root.Get("text").String())
+		if u := root.Get("usage"); u.Exists() {
+			out, _ = sjson.SetRawBytes(out, "usage", []byte(u.Raw))
+		}
+		return "application/json", out
+	}
+}
+
+// subtitleTimestamp formats seconds as an SRT (00:00:01,500) or VTT (00:00:01.500) timestamp.
+func subtitleTimestamp(seconds float64, format string) string {
+	ms := int64(seconds*1000 + 0.5)
+	if ms < 0 {
+		ms = 0
+	}
+	separator := ","
+	if format == "vtt" {
+		separator = "."
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+)
+
+// streamKeepalive is the SSE comment written to streaming clients by WriteStreamKeepalive.
+// SSE clients ignore comments, but they reset the idle timers of clients and proxies.
+const streamKeepalive = ": keepalive\n\n"
+
+// StreamKeepalive returns a channel that ticks every stream-keepalive-seconds, for the stream
+// loops of the handlers, and the function that stops it. The channel is nil, so it never
+// ticks, when keepalives are disabled.
+func (h *BaseAPIHandler) StreamKeepalive() (<-chan time.Time, func()) {
+	if h == nil || h.Cfg == nil || h.Cfg.StreamKeepaliveSeconds <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(time.Duration(h.Cfg.StreamKeepaliveSeconds) * time.Second)
+	return ticker.C, ticker.Stop
+}
+
+// WriteStreamKeepalive writes a keepalive comment to the SSE response of c. The first one
+// commits the status and the headers set so far; headers executors set afterwards, such as
+// the provider that answered a fallback chain, are not sent.
+func (h *BaseAPIHandler) WriteStreamKeepalive(c *gin.Context, flusher http.Flusher) {
+	requestctx.WriteResponse(c, func() {
+		_, _ = c.Writer.WriteString(streamKeepalive)
+		flusher.Flush()
+	})
+}
+
+// WriteStreamError reports msg to a streaming client. Before anything was written it is an
+// ordinary error response with its status code; once an SSE stream has started it is sent as
+// an "error" event carrying the same body.
+func (h *BaseAPIHandler) WriteStreamError(c *gin.Context, msg *interfaces.ErrorMessage) {
+	if !c.Writer.Written() || !strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
+		h.WriteErrorResponse(c, msg)
+		return
+	}
+	status, code, text := errorDetails(msg)
+	body := errorBody(c.Request.URL.Path, status, code, text)
+	// Upstream error bodies may be indented; an SSE data line must not contain newlines.
+	body = bytes.ReplaceAll(bytes.ReplaceAll(body, []byte("\r"), nil), []byte("\n"), nil)
+	_, _ = fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", body)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// trafficRoute is the traffic split route picked for one request.
+type trafficRoute struct {
+	splitModel string
+	name       string
+	model      string
+	providers  []string
+	accounts   []string
+	started    time.Time
+}
+
+// pickTrafficRoute picks a weighted route of the traffic split configured for modelName, among
+// the routes left with a provider after API key rules and account hints. It returns nil when
+// the model is not split, has a fallback chain, or the request pins an account.
+func (h *BaseAPIHandler) pickTrafficRoute(ctx context.Context, modelName string, providers []string, metadata map[string]any) *trafficRoute {
+	if h.Cfg == nil || len(h.Cfg.TrafficSplits) == 0 || h.fallbackChain(modelName) != nil {
+		return nil
+	}
+	if _, pinned := metadata[coreauth.MetadataAccountPinKey]; pinned {
+		return nil
+	}
+	var split *config.TrafficSplit
+	for i := range h.Cfg.TrafficSplits {
+		if strings.EqualFold(strings.TrimSpace(h.Cfg.TrafficSplits[i].Model), modelName) {
+			split = &h.Cfg.TrafficSplits[i]
+			break
+		}
+	}
+	if split == nil {
+		return nil
+	}
+	rule := h.apiKeyRule(ctx)
+	candidates := make([]trafficRoute, 0, len(split.Routes))
+	weights := make([]int, 0, len(split.Routes))
+	total := 0
+	for _, route := range split.Routes {
+		if route.Weight <= 0 {
+			continue
+		}
+		routeModel := strings.TrimSpace(route.Model)
+		if routeModel == "" {
+			routeModel = modelName
+		}
+		routeProviders := providers
+		if !strings.EqualFold(routeModel, modelName) {
+			routeProviders = nil
+			for _, provider := range util.GetProviderName(routeModel) {
+				if rule == nil || len(rule.AllowedProviders) == 0 || providerListed(rule.AllowedProviders, provider) {
+					routeProviders = append(routeProviders, provider)
+				}
+			}
+		}
+		if provider := strings.ToLower(strings.TrimSpace(route.Provider)); provider != "" {
+			if !util.InArray(routeProviders, provider) {
+				continue
+			}
+			routeProviders = []string{provider}
+		}
+		if len(routeProviders) == 0 {
+			continue
+		}
+		name := strings.TrimSpace(route.Name)
+		if name == "" {
+			name = strings.TrimSpace(route.Provider)
+		}
+		if name == "" {
+			name = routeModel
+		}
+		candidates = append(candidates, trafficRoute{
+			splitModel: modelName,
+			name:       name,
+			model:      routeModel,
+			providers:  routeProviders,
+			accounts:   trimmedNonEmpty(route.Accounts),
+		})
+		weights = append(weights, route.Weight)
+		total += route.Weight
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	n := rand.IntN(total)
+	for i := range candidates {
+		if n < weights[i] {
+			picked := candidates[i]
+			picked.started = time.Now()
+			return &picked
+		}
+		n -= weights[i]
+	}
+	return nil
+}
+
+// apply narrows the request to the route: its model, providers and account group.
+func (r *trafficRoute) apply(req *coreexecutor.Request, providers []string, metadata map[string]any) ([]string, map[string]any) {
+	if r == nil {
+		return providers, metadata
+	}
+	req.Model = r.model
+	if len(r.accounts) > 0 {
+		if metadata == nil {
+			metadata = make(map[string]any)
+		}
+		metadata[coreauth.MetadataAccountGroupKey] = r.accounts
+	}
+	return r.providers, metadata
+}
+
+// record counts the request in the traffic split metrics. It is a no-op for a nil route.
+func (r *trafficRoute) record(success bool) {
+	if r == nil {
+		return
+	}
+	usage.GetTrafficSplitStats().Record(r.splitModel, r.name, success, time.Since(r.started))
+}
+
+func providerListed(list []string, provider string) bool {
+	for _, candidate := range list {
+		if strings.EqualFold(strings.TrimSpace(candidate), provider) {
+			return true
+		}
+	}
+	return false
+}
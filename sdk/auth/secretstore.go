@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/secrets"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// SecretTokenStore persists auth records as JSON secrets in a secrets backend, one secret per
+// auth ID holding the same fields as the auth file would. Records that carry an "api_key"
+// field become API key accounts of their "type", so provider API keys can live in the
+// backend too.
+type SecretTokenStore struct {
+	backend secrets.Backend
+	mu      sync.Mutex
+	// saved holds the last content read or written per secret, so that saving an unchanged
+	// record does not create a new secret version.
+	saved map[string][]byte
+}
+
+// NewSecretTokenStore creates a token store backed by backend.
+func NewSecretTokenStore(backend secrets.Backend) *SecretTokenStore {
+	return &SecretTokenStore{backend: backend, saved: make(map[string][]byte)}
+}
+
+// Save writes the auth record to its secret and returns the secret's location.
+func (s *SecretTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (string, error) {
+	if auth == nil {
+		return "", fmt.Errorf("auth secretstore: auth is nil")
+	}
+	name := strings.TrimSpace(auth.ID)
+	if name == "" {
+		name = strings.TrimSpace(auth.FileName)
+	}
+	if name == "" {
+		return "", fmt.Errorf("auth secretstore: missing id")
+	}
+
+	var raw []byte
+	var err error
+	switch {
+	case auth.Storage != nil:
+		raw, err = storageJSON(auth)
+	case auth.Metadata != nil:
+		raw, err = json.Marshal(auth.Metadata)
+	default:
+		return "", fmt.Errorf("auth secretstore: nothing to persist for %s", auth.ID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("auth secretstore: marshal %s failed: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if previous, ok := s.saved[name]; ok && jsonEqual(previous, raw) {
+		return s.backend.Location(name), nil
+	}
+	if auth.Disabled {
+		// Like the file store, do not recreate records that were removed from the backend.
+		if _, errGet := s.backend.Get(ctx, name); errors.Is(errGet, secrets.ErrNotFound) {
+			return "", nil
+		}
+	}
+	if err = s.backend.Put(ctx, name, raw); err != nil {
+		return "", fmt.Errorf("auth secretstore: write %s failed: %w", name, err)
+	}
+	s.saved[name] = raw
+	if strings.TrimSpace(auth.FileName) == "" {
+		auth.FileName = name
+	}
+	return s.backend.Location(name), nil
+}
+
+// List reads every auth record from the backend. Records that cannot be read are logged and
+// skipped.
+func (s *SecretTokenStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error) {
+	names, err := s.backend.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("auth secretstore: list failed: %w", err)
+	}
+	now := time.Now()
+	entries := make([]*cliproxyauth.Auth, 0, len(names))
+	for _, name := range names {
+		data, errGet := s.backend.Get(ctx, name)
+		if errGet != nil {
+			log.Warnf("auth secretstore: failed to read %s: %v", s.backend.Location(name), errGet)
+			continue
+		}
+		auth, errAuth := authFromSecret(name, data, now)
+		if errAuth != nil {
+			log.Warnf("auth secretstore: skipping %s: %v", s.backend.Location(name), errAuth)
+			continue
+		}
+		s.mu.Lock()
+		s.saved[name] = data
+		s.mu.Unlock()
+		entries = append(entries, auth)
+	}
+	return entries, nil
+}
+
+// Delete removes the auth record's secret.
+func (s *SecretTokenStore) Delete(ctx context.Context, id string) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return fmt.Errorf("auth secretstore: id is empty")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.backend.Delete(ctx, id); err != nil {
+		return fmt.Errorf("auth secretstore: delete failed: %w", err)
+	}
+	delete(s.saved, id)
+	return nil
+}
+
+// authFromSecret builds the auth of the secret name the way the watcher builds it from an
+// auth file.
+func authFromSecret(name string, data []byte, now time.Time) (*cliproxyauth.Auth, error) {
+	metadata := make(map[string]any)
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	t, _ := metadata["type"].(string)
+	provider := strings.ToLower(strings.TrimSpace(t))
+	if provider == "" {
+		return nil, fmt.Errorf("missing type")
+	}
+	auth := &cliproxyauth.Auth{
+		ID:         name,
+		FileName:   name,
+		Provider:   provider,
+		Label:      provider,
+		Status:     cliproxyauth.StatusActive,
+		Attributes: map[string]string{"source": "secret:" + name},
+		Metadata:   metadata,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if apiKey, _ := metadata["api_key"].(string); strings.TrimSpace(apiKey) != "" {
+		auth.Label = provider + "-apikey"
+		auth.Attributes["api_key"] = strings.TrimSpace(apiKey)
+		if baseURL, _ := metadata["base_url"].(string); baseURL != "" {
+			auth.Attributes["base_url"] = baseURL
+		}
+		if proxyURL, _ := metadata["proxy_url"].(string); proxyURL != "" {
+			auth.ProxyURL = proxyURL
+		}
+	} else {
+		if provider == "gemini" {
+			auth.Provider = "gemini-cli"
+		}
+		if email, _ := metadata["email"].(string); email != "" {
+			auth.Label = email
+			auth.Attributes["email"] = email
+		}
+	}
+	if disabled, _ := metadata["disabled"].(bool); disabled {
+		auth.Disabled = true
+		auth.Status = cliproxyauth.StatusDisabled
+	} else if expired, _ := metadata["expired"].(bool); expired {
+		auth.Status = cliproxyauth.StatusExpired
+		auth.StatusMessage = "credentials expired"
+	}
+	return auth, nil
+}
+
+// storageJSON serializes the token storage of a fresh login. Storages set their type only
+// when writing a file, so it is filled in from the provider when missing.
+func storageJSON(auth *cliproxyauth.Auth) ([]byte, error) {
+	raw, err := json.Marshal(auth.Storage)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]any)
+	if err = json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	if t, _ := fields["type"].(string); t == "" {
+		fields["type"] = auth.Provider
+	}
+	for key, value := range auth.Metadata {
+		if _, ok := fields[key]; !ok {
+			fields[key] = value
+		}
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Execution metadata keys that narrow which auths may serve a request.
+const (
+	// MetadataAccountPinKey holds the auth IDs ([]string) a request is pinned to.
+	MetadataAccountPinKey = "account_pin"
+	// MetadataAccountExcludeKey holds auth IDs ([]string) a request must not use.
+	MetadataAccountExcludeKey = "account_exclude"
+	// MetadataAccountAllowKey holds account references ([]string) the caller may use,
+	// matched with MatchesAccount.
+	MetadataAccountAllowKey = "account_allow"
+	// MetadataAccountGroupKey holds the account references ([]string) of a traffic split
+	// route, matched with MatchesAccount. It narrows the allowed accounts further.
+	MetadataAccountGroupKey = "account_group"
+)
+
+// MatchesAccount reports whether ref names auth by ID, label, or auth file name (with or
+// without the .json extension). Comparison is case-insensitive.
+func (a *Auth) MatchesAccount(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	if a == nil || ref == "" {
+		return false
+	}
+	candidates := []string{a.ID, a.Label}
+	if a.Metadata != nil {
+		if v, ok := a.Metadata["label"].(string); ok {
+			candidates = append(candidates, v)
+		}
+	}
+	if a.FileName != "" {
+		base := filepath.Base(a.FileName)
+		candidates = append(candidates, base, strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+	for _, candidate := range candidates {
+		if candidate = strings.TrimSpace(candidate); candidate != "" && strings.EqualFold(candidate, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// accountHintsAllow applies the pin, exclude and allow hints in metadata to auth.
+func accountHintsAllow(metadata map[string]any, auth *Auth) bool {
+	if len(metadata) == 0 {
+		return true
+	}
+	if pinned := metadataStrings(metadata, MetadataAccountPinKey); pinned != nil && !containsString(pinned, auth.ID) {
+		return false
+	}
+	if containsString(metadataStrings(metadata, MetadataAccountExcludeKey), auth.ID) {
+		return false
+	}
+	if group := metadataStrings(metadata, MetadataAccountGroupKey); group != nil && !auth.matchesAnyAccount(group) {
+		return false
+	}
+	if allowed := metadataStrings(metadata, MetadataAccountAllowKey); allowed != nil {
+		for _, ref := range allowed {
+			if auth.MatchesAccount(ref) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (a *Auth) matchesAnyAccount(refs []string) bool {
+	for _, ref := range refs {
+		if a.MatchesAccount(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+func metadataStrings(metadata map[string]any, key string) []string {
+	values, _ := metadata[key].([]string)
+	return values
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+)
+
+// EmbeddingExecutor is implemented by provider executors that can compute embeddings.
+// req.Payload is an OpenAI embeddings request whose "input" is an array of strings, with
+// an optional Gemini "task_type"; the response payload is an OpenAI embeddings response.
+type EmbeddingExecutor interface {
+	Embed(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
+// ExecutorWrapper is implemented by executors that decorate another executor, so optional
+// capabilities are looked up on the executor doing the work.
+type ExecutorWrapper interface {
+	Unwrap() ProviderExecutor
+}
+
+// SupportsEmbeddings reports whether exec, or the executor it wraps, computes embeddings.
+func SupportsEmbeddings(exec ProviderExecutor) bool {
+	for exec != nil {
+		if wrapper, ok := exec.(ExecutorWrapper); ok {
+			exec = wrapper.Unwrap()
+			continue
+		}
+		_, ok := exec.(EmbeddingExecutor)
+		return ok
+	}
+	return false
+}
+
+// EmbeddingProviders returns the providers whose registered executor computes embeddings.
+func (m *Manager) EmbeddingProviders(providers []string) []string {
+	normalized := m.normalizeProviders(providers)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]string, 0, len(normalized))
+	for _, provider := range normalized {
+		if SupportsEmbeddings(m.executors[provider]) {
+			out = append(out, provider)
+		}
+	}
+	return out
+}
+
+// ExecuteEmbeddings computes embeddings with the first provider and auth that succeeds.
+// Providers without embedding support are skipped.
+func (m *Manager) ExecuteEmbeddings(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	supported := m.EmbeddingProviders(providers)
+	if len(supported) == 0 {
+		return cliproxyexecutor.Response{}, &Error{Code: "embeddings_unsupported", Message: "no provider for this model supports embeddings", HTTPStatus: 400}
+	}
+	rotated := m.rotateProviders(req.Model, supported)
+	defer m.advanceProviderCursor(req.Model, supported)
+
+	var lastErr error
+	for _, provider := range rotated {
+		resp, errExec := m.executeEmbeddingsWithProvider(ctx, provider, req, opts)
+		if errExec == nil {
+			return resp, nil
+		}
+		lastErr = errExec
+	}
+	if lastErr != nil {
+		return cliproxyexecutor.Response{}, lastErr
+	}
+	return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
+}
+
+func (m *Manager) executeEmbeddingsWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	tried := make(map[string]struct{})
+	var lastErr error
+	for {
+		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		if errPick != nil {
+			if lastErr != nil {
+				return cliproxyexecutor.Response{}, lastErr
+			}
+			return cliproxyexecutor.Response{}, errPick
+		}
+		embedder, ok := executor.(EmbeddingExecutor)
+		if !ok {
+			return cliproxyexecutor.Response{}, &Error{Code: "embeddings_unsupported", Message: "executor does not support embeddings", HTTPStatus: 400}
+		}
+
+		if accountType, accountInfo := auth.AccountInfo(); accountType == "api_key" {
+			log.Debugf("Use API key %s for embeddings with model %s", util.HideAPIKey(accountInfo), req.Model)
+		} else if accountType != "" {
+			log.Debugf("Use %s %s for embeddings with model %s", accountType, accountInfo, req.Model)
+		}
+
+		tried[auth.ID] = struct{}{}
+		execCtx := ctx
+		if rt := m.roundTripperFor(auth); rt != nil {
+			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+			execCtx = requestctx.WithRoundTripper(execCtx, rt)
+		}
+		resp, errExec := embedder.Embed(execCtx, auth, req, opts)
+		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
+		if errExec != nil {
+			result.Error = resultError(errExec)
+			m.MarkResult(execCtx, result)
+			lastErr = errExec
+			continue
+		}
+		m.MarkResult(execCtx, result)
+		return resp, nil
+	}
+}
@@ -1,5 +1,18 @@
 package auth
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/gjson"
+)
+
 // Error describes an authentication related failure in a provider agnostic format.
 type Error struct {
 	// Code is a short machine readable identifier.
@@ -30,3 +43,180 @@ func (e *Error) StatusCode() int {
 	}
 	return e.HTTPStatus
 }
+
+// ErrorCode returns the machine-readable code of the error for client responses.
+func (e *Error) ErrorCode() string {
+	if e == nil || e.Code == "" {
+		return ""
+	}
+	if e.Code == "auth_not_found" || e.Code == "auth_unavailable" {
+		return interfaces.ErrorCodeNoAvailableAccount
+	}
+	return strings.ToUpper(e.Code)
+}
+
+// resultError converts an executor error into the Error recorded for the auth, keeping its
+// status and machine-readable code.
+func resultError(err error) *Error {
+	out := &Error{Message: err.Error()}
+	var se interface{ StatusCode() int }
+	if errors.As(err, &se) && se != nil {
+		out.HTTPStatus = se.StatusCode()
+	}
+	var coder interfaces.ErrorCoder
+	if errors.As(err, &coder) && coder != nil {
+		out.Code = coder.ErrorCode()
+	}
+	return out
+}
+
+// Attempt describes one failed execution attempt against a routing candidate.
+type Attempt struct {
+	Provider string `json:"provider"`
+	// Account is a digest of the account label, so the error can be shown to clients.
+	Account string `json:"account"`
+	Status  int    `json:"status,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// AttemptsError is returned when several routing candidates were tried and all failed. It
+// lists every attempt instead of only the last error, which it wraps.
+type AttemptsError struct {
+	Attempts []Attempt
+	Last     error
+}
+
+// Error renders the attempts as an OpenAI-style JSON error body.
+func (e *AttemptsError) Error() string {
+	body := map[string]any{
+		"error": map[string]any{
+			"message":  fmt.Sprintf("all %d routing candidates failed", len(e.Attempts)),
+			"type":     "upstream_error",
+			"code":     "all_candidates_failed",
+			"attempts": e.Attempts,
+		},
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Sprintf("all %d routing candidates failed", len(e.Attempts))
+	}
+	return string(out)
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *AttemptsError) Unwrap() error { return e.Last }
+
+// StatusCode returns the status shared by every attempt, or 502 when they differ.
+func (e *AttemptsError) StatusCode() int {
+	status := 0
+	for i, attempt := range e.Attempts {
+		if i == 0 {
+			status = attempt.Status
+			continue
+		}
+		if attempt.Status != status {
+			return http.StatusBadGateway
+		}
+	}
+	if status <= 0 {
+		return http.StatusBadGateway
+	}
+	return status
+}
+
+// ErrorCode returns the code shared by every attempt, or ALL_CANDIDATES_FAILED when they differ.
+func (e *AttemptsError) ErrorCode() string {
+	code := ""
+	for i, attempt := range e.Attempts {
+		if i > 0 && attempt.Code != code {
+			return interfaces.ErrorCodeAllCandidatesFailed
+		}
+		code = attempt.Code
+	}
+	if code == "" {
+		return interfaces.ErrorCodeAllCandidatesFailed
+	}
+	return code
+}
+
+// Headers returns the response headers of the last attempt, such as Retry-After.
+func (e *AttemptsError) Headers() http.Header {
+	var withHeaders interface{ Headers() http.Header }
+	if errors.As(e.Last, &withHeaders) && withHeaders != nil {
+		if headers := withHeaders.Headers(); headers != nil {
+			return headers.Clone()
+		}
+	}
+	return http.Header{}
+}
+
+// attemptLog collects the failed attempts of one execution across providers.
+type attemptLog struct {
+	attempts []Attempt
+}
+
+func (l *attemptLog) add(provider string, auth *Auth, err error) {
+	attempt := Attempt{Provider: provider, Reason: attemptReason(err)}
+	var se interface{ StatusCode() int }
+	if errors.As(err, &se) && se != nil {
+		attempt.Status = se.StatusCode()
+	}
+	// Attempts without a status never got an upstream response.
+	codeStatus := attempt.Status
+	if codeStatus == 0 {
+		codeStatus = http.StatusBadGateway
+	}
+	attempt.Code = interfaces.ErrorCodeOf(err, codeStatus)
+	if label := attemptAccountLabel(auth); label != "" {
+		sum := sha256.Sum256([]byte(label))
+		attempt.Account = "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	l.attempts = append(l.attempts, attempt)
+}
+
+// result returns last unchanged when at most one attempt failed, and an AttemptsError otherwise.
+func (l *attemptLog) result(last error) error {
+	if last == nil || len(l.attempts) < 2 {
+		return last
+	}
+	return &AttemptsError{Attempts: append([]Attempt(nil), l.attempts...), Last: last}
+}
+
+func attemptAccountLabel(auth *Auth) string {
+	if auth == nil {
+		return ""
+	}
+	if strings.EqualFold(auth.Provider, geminiWebProviderKey) {
+		return GeminiWebAccountLabel(auth)
+	}
+	if label := strings.TrimSpace(auth.Label); label != "" {
+		return label
+	}
+	return auth.ID
+}
+
+// maxAttemptReasonLen caps the reason reported for one attempt.
+const maxAttemptReasonLen = 300
+
+// attemptReason shortens an error to the message a client needs, taking the message field of
+// JSON error bodies returned by upstreams.
+func attemptReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	reason := err.Error()
+	if gjson.Valid(reason) {
+		for _, path := range []string{"error.message", "message", "error"} {
+			if v := gjson.Get(reason, path); v.Type == gjson.String && v.String() != "" {
+				reason = v.String()
+				break
+			}
+		}
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) > maxAttemptReasonLen {
+		reason = reason[:maxAttemptReasonLen] + "..."
+	}
+	return reason
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// A provider may name fallback providers for its models: once every auth of the provider has
+// exhausted its quota for the requested model, the request is retried on the fallbacks with
+// the same model name, e.g. Gemini Web behind Vertex AI.
+
+// SetProviderFallback sets the providers tried after provider runs out of quota. Passing no
+// fallbacks removes them.
+func (m *Manager) SetProviderFallback(provider string, fallbacks ...string) {
+	if m == nil {
+		return
+	}
+	key := strings.ToLower(strings.TrimSpace(provider))
+	if key == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(fallbacks) == 0 {
+		delete(m.fallbacks, key)
+		return
+	}
+	if m.fallbacks == nil {
+		m.fallbacks = make(map[string][]string)
+	}
+	m.fallbacks[key] = m.normalizeProviders(fallbacks)
+}
+
+// fallbackProviders returns the fallbacks of the providers whose quota for model is exhausted,
+// skipping providers already tried and those without a registered executor.
+func (m *Manager) fallbackProviders(model string, tried []string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.fallbacks) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(tried))
+	for _, provider := range tried {
+		seen[provider] = struct{}{}
+	}
+	var out []string
+	now := time.Now()
+	for _, provider := range tried {
+		fallbacks := m.fallbacks[provider]
+		if len(fallbacks) == 0 || !m.quotaExhaustedLocked(provider, model, now) {
+			continue
+		}
+		for _, fallback := range fallbacks {
+			if _, ok := seen[fallback]; ok {
+				continue
+			}
+			if _, ok := m.executors[fallback]; !ok {
+				continue
+			}
+			seen[fallback] = struct{}{}
+			log.Debugf("%s quota exhausted for model %s, falling back to %s", provider, model, fallback)
+			out = append(out, fallback)
+		}
+	}
+	return out
+}
+
+// quotaExhaustedLocked reports whether every enabled auth of provider is cooling down after a
+// quota error for model. m.mu must be held.
+func (m *Manager) quotaExhaustedLocked(provider, model string, now time.Time) bool {
+	found := false
+	for _, auth := range m.auths {
+		if auth.Provider != provider || auth.Disabled {
+			continue
+		}
+		found = true
+		if state := auth.ModelStates[model]; state != nil && state.Quota.Exceeded && state.Quota.NextRecoverAt.After(now) {
+			continue
+		}
+		if auth.Quota.Exceeded && auth.Quota.NextRecoverAt.After(now) {
+			continue
+		}
+		return false
+	}
+	return found
+}
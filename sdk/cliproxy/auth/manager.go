@@ -3,7 +3,7 @@ package auth
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,9 +11,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/alerts"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -93,6 +96,8 @@ type Manager struct {
 	auths     map[string]*Auth
 	// providerOffsets tracks per-model provider rotation state for multi-provider routing.
 	providerOffsets map[string]int
+	// fallbacks lists the providers tried once a provider runs out of quota.
+	fallbacks map[string][]string
 
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
@@ -172,6 +177,22 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 	return auth.Clone(), nil
 }
 
+// AttachRuntime sets the runtime of the registered auth id unless it already has one, and
+// returns the runtime the auth carries afterwards. Callers that built a runtime on a copy
+// returned by List or GetByID use it so that later copies share that runtime.
+func (m *Manager) AttachRuntime(id string, runtime any) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.auths[id]
+	if !ok || current == nil {
+		return nil, false
+	}
+	if current.Runtime == nil {
+		current.Runtime = runtime
+	}
+	return current.Runtime, true
+}
+
 // Load resets manager state from the backing store.
 func (m *Manager) Load(ctx context.Context) error {
 	m.mu.Lock()
@@ -204,15 +225,23 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 	defer m.advanceProviderCursor(req.Model, normalized)
 
 	var lastErr error
+	attempts := &attemptLog{}
 	for _, provider := range rotated {
-		resp, errExec := m.executeWithProvider(ctx, provider, req, opts)
+		resp, errExec := m.executeWithProvider(ctx, provider, req, opts, attempts)
+		if errExec == nil {
+			return resp, nil
+		}
+		lastErr = errExec
+	}
+	for _, provider := range m.fallbackProviders(req.Model, normalized) {
+		resp, errExec := m.executeWithProvider(ctx, provider, req, opts, attempts)
 		if errExec == nil {
 			return resp, nil
 		}
 		lastErr = errExec
 	}
 	if lastErr != nil {
-		return cliproxyexecutor.Response{}, lastErr
+		return cliproxyexecutor.Response{}, attempts.result(lastErr)
 	}
 	return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
@@ -228,15 +257,23 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 	defer m.advanceProviderCursor(req.Model, normalized)
 
 	var lastErr error
+	attempts := &attemptLog{}
 	for _, provider := range rotated {
-		resp, errExec := m.executeCountWithProvider(ctx, provider, req, opts)
+		resp, errExec := m.executeCountWithProvider(ctx, provider, req, opts, attempts)
+		if errExec == nil {
+			return resp, nil
+		}
+		lastErr = errExec
+	}
+	for _, provider := range m.fallbackProviders(req.Model, normalized) {
+		resp, errExec := m.executeCountWithProvider(ctx, provider, req, opts, attempts)
 		if errExec == nil {
 			return resp, nil
 		}
 		lastErr = errExec
 	}
 	if lastErr != nil {
-		return cliproxyexecutor.Response{}, lastErr
+		return cliproxyexecutor.Response{}, attempts.result(lastErr)
 	}
 	return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
@@ -252,20 +289,28 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	defer m.advanceProviderCursor(req.Model, normalized)
 
 	var lastErr error
+	attempts := &attemptLog{}
 	for _, provider := range rotated {
-		chunks, errStream := m.executeStreamWithProvider(ctx, provider, req, opts)
+		chunks, errStream := m.executeStreamWithProvider(ctx, provider, req, opts, attempts)
+		if errStream == nil {
+			return chunks, nil
+		}
+		lastErr = errStream
+	}
+	for _, provider := range m.fallbackProviders(req.Model, normalized) {
+		chunks, errStream := m.executeStreamWithProvider(ctx, provider, req, opts, attempts)
 		if errStream == nil {
 			return chunks, nil
 		}
 		lastErr = errStream
 	}
 	if lastErr != nil {
-		return nil, lastErr
+		return nil, attempts.result(lastErr)
 	}
 	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
 
-func (m *Manager) executeWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (m *Manager) executeWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, attempts *attemptLog) (cliproxyexecutor.Response, error) {
 	if provider == "" {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
@@ -294,17 +339,14 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+			execCtx = requestctx.WithRoundTripper(execCtx, rt)
 		}
 		resp, errExec := executor.Execute(execCtx, auth, req, opts)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
 		if errExec != nil {
-			result.Error = &Error{Message: errExec.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errExec, &se) && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
-			}
+			result.Error = resultError(errExec)
 			m.MarkResult(execCtx, result)
+			attempts.add(provider, auth, errExec)
 			lastErr = errExec
 			continue
 		}
@@ -313,7 +355,7 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 	}
 }
 
-func (m *Manager) executeCountWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (m *Manager) executeCountWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, attempts *attemptLog) (cliproxyexecutor.Response, error) {
 	if provider == "" {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
@@ -341,17 +383,14 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+			execCtx = requestctx.WithRoundTripper(execCtx, rt)
 		}
 		resp, errExec := executor.CountTokens(execCtx, auth, req, opts)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
 		if errExec != nil {
-			result.Error = &Error{Message: errExec.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errExec, &se) && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
-			}
+			result.Error = resultError(errExec)
 			m.MarkResult(execCtx, result)
+			attempts.add(provider, auth, errExec)
 			lastErr = errExec
 			continue
 		}
@@ -360,7 +399,7 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 	}
 }
 
-func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, attempts *attemptLog) (<-chan cliproxyexecutor.StreamChunk, error) {
 	if provider == "" {
 		return nil, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
@@ -388,17 +427,13 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+			execCtx = requestctx.WithRoundTripper(execCtx, rt)
 		}
 		chunks, errStream := executor.ExecuteStream(execCtx, auth, req, opts)
 		if errStream != nil {
-			rerr := &Error{Message: errStream.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errStream, &se) && se != nil {
-				rerr.HTTPStatus = se.StatusCode()
-			}
-			result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: false, Error: rerr}
+			result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: false, Error: resultError(errStream)}
 			m.MarkResult(execCtx, result)
+			attempts.add(provider, auth, errStream)
 			lastErr = errStream
 			continue
 		}
@@ -409,12 +444,7 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 			for chunk := range streamChunks {
 				if chunk.Err != nil && !failed {
 					failed = true
-					rerr := &Error{Message: chunk.Err.Error()}
-					var se cliproxyexecutor.StatusError
-					if errors.As(chunk.Err, &se) && se != nil {
-						rerr.HTTPStatus = se.StatusCode()
-					}
-					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: false, Error: rerr})
+					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: false, Error: resultError(chunk.Err)})
 				}
 				out <- chunk
 			}
@@ -492,6 +522,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	var expired *Auth
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
@@ -560,10 +591,17 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 			}
 		}
 
+		if !result.Success && result.Error != nil && result.Error.Code == interfaces.ErrorCodeCookieExpired {
+			expired = expireAuthLocked(auth, result.Error.Message, now)
+		}
+
 		_ = m.persist(ctx, auth)
 	}
 	m.mu.Unlock()
 
+	if expired != nil {
+		alertExpired(expired, result.Error.Message)
+	}
 	if clearModelQuota && result.Model != "" {
 		registry.GetGlobalRegistry().ClearModelQuotaExceeded(result.AuthID, result.Model)
 	}
@@ -716,6 +754,45 @@ func statusCodeFromResult(err *Error) int {
 	return err.StatusCode()
 }
 
+// expireAuthLocked marks the credentials of auth as expired and records it in the metadata so
+// that the state survives restarts. It returns a copy of auth when it was not expired before.
+func expireAuthLocked(auth *Auth, message string, now time.Time) *Auth {
+	if auth.Status == StatusExpired {
+		return nil
+	}
+	auth.Status = StatusExpired
+	auth.StatusMessage = "credentials expired"
+	if message != "" {
+		auth.StatusMessage += ": " + message
+	}
+	auth.Unavailable = true
+	auth.UpdatedAt = now
+	metadata := make(map[string]any, len(auth.Metadata)+1)
+	for key, value := range auth.Metadata {
+		metadata[key] = value
+	}
+	metadata["expired"] = true
+	auth.Metadata = metadata
+	return auth.Clone()
+}
+
+// alertExpired tells the operators that auth no longer receives requests.
+func alertExpired(auth *Auth, reason string) {
+	label := attemptAccountLabel(auth)
+	message := fmt.Sprintf("credentials of %s account %s expired; requests are no longer routed to it until they are replaced", auth.Provider, label)
+	if reason != "" {
+		message += " (" + reason + ")"
+	}
+	alerts.Send(alerts.Alert{
+		Event:    alerts.EventCredentialsExpired,
+		Provider: auth.Provider,
+		AuthID:   auth.ID,
+		Account:  label,
+		Message:  message,
+		Time:     auth.UpdatedAt,
+	})
+}
+
 func applyAuthFailureState(auth *Auth, resultErr *Error, now time.Time) {
 	if auth == nil {
 		return
@@ -794,6 +871,9 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		if _, used := tried[auth.ID]; used {
 			continue
 		}
+		if !accountHintsAllow(opts.Metadata, auth) {
+			continue
+		}
 		candidates = append(candidates, auth.Clone())
 	}
 	m.mu.RUnlock()
@@ -894,7 +974,7 @@ func (m *Manager) snapshotAuths() []*Auth {
 }
 
 func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
-	if a == nil || a.Disabled {
+	if a == nil || a.Disabled || a.Status == StatusExpired {
 		return false
 	}
 	if !a.NextRefreshAfter.IsZero() && now.Before(a.NextRefreshAfter) {
@@ -1129,13 +1209,21 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	log.Debugf("refreshed %s, %s, %v", auth.Provider, auth.ID, err)
 	now := time.Now()
 	if err != nil {
+		var expired *Auth
 		m.mu.Lock()
 		if current := m.auths[id]; current != nil {
 			current.NextRefreshAfter = now.Add(refreshFailureBackoff)
-			current.LastError = &Error{Message: err.Error()}
+			current.LastError = resultError(err)
+			if current.LastError.Code == interfaces.ErrorCodeCookieExpired {
+				expired = expireAuthLocked(current, current.LastError.Message, now)
+				_ = m.persist(ctx, current)
+			}
 			m.auths[id] = current
 		}
 		m.mu.Unlock()
+		if expired != nil {
+			alertExpired(expired, err.Error())
+		}
 		return
 	}
 	if updated == nil {
@@ -59,7 +59,7 @@ func isAuthBlockedForModel(auth *Auth, model string, now time.Time) bool {
 	if auth == nil {
 		return true
 	}
-	if auth.Disabled || auth.Status == StatusDisabled {
+	if auth.Disabled || auth.Status == StatusDisabled || auth.Status == StatusExpired {
 		return true
 	}
 	// If a specific model is requested, prefer its per-model state over any aggregated
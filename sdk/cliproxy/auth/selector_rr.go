@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
+	"path/filepath"
 	"strings"
+	"time"
 
 	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -15,6 +17,10 @@ const (
 
 type geminiWebStickySelector struct {
 	base Selector
+	// known reports whether any registered account, usable or not, owns label. Affinity
+	// entries of unknown labels are stale and removed; entries of accounts that are merely
+	// unavailable right now are kept.
+	known func(label string) bool
 }
 
 func NewGeminiWebStickySelector(base Selector) Selector {
@@ -36,7 +42,40 @@ func (m *Manager) EnableGeminiWebStickySelector() {
 	if _, ok := m.selector.(*geminiWebStickySelector); ok {
 		return
 	}
-	m.selector = NewGeminiWebStickySelector(m.selector)
+	sticky := NewGeminiWebStickySelector(m.selector).(*geminiWebStickySelector)
+	sticky.known = m.hasGeminiWebLabel
+	m.selector = sticky
+}
+
+func (m *Manager) hasGeminiWebLabel(label string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, auth := range m.auths {
+		if strings.EqualFold(auth.Provider, geminiWebProviderKey) && strings.EqualFold(GeminiWebAccountLabel(auth), label) {
+			return true
+		}
+	}
+	return false
+}
+
+// GeminiWebAccountLabel returns the label under which a Gemini Web account records the
+// conversations it owns: the label stored in its auth file, or else the file's base name.
+func GeminiWebAccountLabel(auth *Auth) string {
+	if auth == nil {
+		return ""
+	}
+	if auth.Metadata != nil {
+		if v, ok := auth.Metadata["label"].(string); ok && strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	if auth.ID != "" {
+		base := filepath.Base(auth.ID)
+		if base = strings.TrimSuffix(base, filepath.Ext(base)); base != "" {
+			return base
+		}
+	}
+	return strings.TrimSpace(auth.Label)
 }
 
 func (s *geminiWebStickySelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
@@ -47,6 +86,23 @@ func (s *geminiWebStickySelector) Pick(ctx context.Context, provider, model stri
 		return s.base.Pick(ctx, provider, model, opts, auths)
 	}
 
+	// An explicit conversation ID routes to the account holding its upstream conversation and
+	// bypasses hash matching; IDs from the user field may be disabled, so those fall through.
+	if ref, ok := opts.Metadata[conversation.MetadataConversationIDKey].(conversation.ConversationIDRef); ok {
+		record, found, err := conversation.LookupConversationID(ref.Key())
+		if err != nil {
+			log.Warnf("gemini-web selector: conversation id lookup failed: %v", err)
+		}
+		if found {
+			if auth := findAuthByLabel(auths, record.AccountLabel); auth != nil && !isAuthBlockedForModel(auth, model, time.Now()) {
+				return auth, nil
+			}
+		}
+		if !ref.FromUser {
+			return s.base.Pick(ctx, provider, model, opts, auths)
+		}
+	}
+
 	messages := extractGeminiWebMessages(opts.Metadata)
 	if len(messages) >= 2 {
 		normalizedModel := conversation.NormalizeModel(model)
@@ -65,7 +121,7 @@ func (s *geminiWebStickySelector) Pick(ctx context.Context, provider, model stri
 				continue
 			}
 			auth := findAuthByLabel(auths, label)
-			if auth != nil {
+			if auth != nil && !isAuthBlockedForModel(auth, model, time.Now()) {
 				if opts.Metadata != nil {
 					opts.Metadata[conversation.MetadataMatchKey] = &conversation.MatchResult{
 						Hash:   candidate.Hash,
@@ -75,6 +131,12 @@ func (s *geminiWebStickySelector) Pick(ctx context.Context, provider, model stri
 				}
 				return auth, nil
 			}
+			if auth != nil || (s.known != nil && s.known(label)) {
+				// The owner exists but cannot serve this request (cooling down, disabled or
+				// already tried); keep its affinity for later turns.
+				log.Debugf("gemini-web selector: owner %s of conversation %s unavailable, selecting another account", label, candidate.Hash)
+				break
+			}
 			_ = conversation.RemoveMatchForLabel(candidate.Hash, label)
 		}
 	}
@@ -104,22 +166,10 @@ func extractGeminiWebMessages(metadata map[string]any) []conversation.Message {
 }
 
 func findAuthByLabel(auths []*Auth, label string) *Auth {
-	if len(auths) == 0 {
-		return nil
-	}
-	normalized := strings.ToLower(strings.TrimSpace(label))
 	for _, auth := range auths {
-		if auth == nil {
-			continue
-		}
-		if strings.ToLower(strings.TrimSpace(auth.Label)) == normalized {
+		if auth != nil && strings.EqualFold(GeminiWebAccountLabel(auth), strings.TrimSpace(label)) {
 			return auth
 		}
-		if auth.Metadata != nil {
-			if v, ok := auth.Metadata["label"].(string); ok && strings.ToLower(strings.TrimSpace(v)) == normalized {
-				return auth
-			}
-		}
 	}
 	return nil
 }
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+)
+
+// SpeechExecutor is implemented by provider executors that synthesize speech. req.Payload is
+// an OpenAI audio/speech request; the stream carries the raw audio bytes in the requested
+// response_format.
+type SpeechExecutor interface {
+	Speech(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error)
+}
+
+// SupportsSpeech reports whether exec, or the executor it wraps, synthesizes speech.
+func SupportsSpeech(exec ProviderExecutor) bool {
+	for exec != nil {
+		if wrapper, ok := exec.(ExecutorWrapper); ok {
+			exec = wrapper.Unwrap()
+			continue
+		}
+		_, ok := exec.(SpeechExecutor)
+		return ok
+	}
+	return false
+}
+
+// SpeechProviders returns the providers whose registered executor synthesizes speech.
+func (m *Manager) SpeechProviders(providers []string) []string {
+	normalized := m.normalizeProviders(providers)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]string, 0, len(normalized))
+	for _, provider := range normalized {
+		if SupportsSpeech(m.executors[provider]) {
+			out = append(out, provider)
+		}
+	}
+	return out
+}
+
+// ExecuteSpeech synthesizes speech with the first provider and auth that starts a stream.
+// Providers without speech support are skipped.
+func (m *Manager) ExecuteSpeech(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	supported := m.SpeechProviders(providers)
+	if len(supported) == 0 {
+		return nil, &Error{Code: "speech_unsupported", Message: "no provider for this model supports speech", HTTPStatus: 400}
+	}
+	rotated := m.rotateProviders(req.Model, supported)
+	defer m.advanceProviderCursor(req.Model, supported)
+
+	var lastErr error
+	for _, provider := range rotated {
+		chunks, errExec := m.executeSpeechWithProvider(ctx, provider, req, opts)
+		if errExec == nil {
+			return chunks, nil
+		}
+		lastErr = errExec
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
+}
+
+func (m *Manager) executeSpeechWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	tried := make(map[string]struct{})
+	var lastErr error
+	for {
+		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		if errPick != nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, errPick
+		}
+		speaker, ok := executor.(SpeechExecutor)
+		if !ok {
+			return nil, &Error{Code: "speech_unsupported", Message: "executor does not support speech", HTTPStatus: 400}
+		}
+
+		if accountType, accountInfo := auth.AccountInfo(); accountType == "api_key" {
+			log.Debugf("Use API key %s for speech with model %s", util.HideAPIKey(accountInfo), req.Model)
+		} else if accountType != "" {
+			log.Debugf("Use %s %s for speech with model %s", accountType, accountInfo, req.Model)
+		}
+
+		tried[auth.ID] = struct{}{}
+		execCtx := ctx
+		if rt := m.roundTripperFor(auth); rt != nil {
+			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+			execCtx = requestctx.WithRoundTripper(execCtx, rt)
+		}
+		chunks, errExec := speaker.Speech(execCtx, auth, req, opts)
+		if errExec != nil {
+			result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: false, Error: resultError(errExec)}
+			m.MarkResult(execCtx, result)
+			lastErr = errExec
+			continue
+		}
+		out := make(chan cliproxyexecutor.StreamChunk)
+		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
+			defer close(out)
+			var failed bool
+			for chunk := range streamChunks {
+				if chunk.Err != nil && !failed {
+					failed = true
+					rerr := &Error{Message: chunk.Err.Error()}
+					var se cliproxyexecutor.StatusError
+					if errors.As(chunk.Err, &se) && se != nil {
+						rerr.HTTPStatus = se.StatusCode()
+					}
+					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: false, Error: rerr})
+				}
+				out <- chunk
+			}
+			if !failed {
+				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: true})
+			}
+		}(execCtx, auth.Clone(), provider, chunks)
+		return out, nil
+	}
+}
@@ -16,4 +16,7 @@ const (
 	StatusError Status = "error"
 	// StatusDisabled marks the auth as intentionally disabled.
 	StatusDisabled Status = "disabled"
+	// StatusExpired marks credentials the provider rejected; the auth is skipped until they
+	// are replaced.
+	StatusExpired Status = "expired"
 )
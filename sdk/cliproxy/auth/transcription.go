@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/requestctx"
+	log "github.com/sirupsen/logrus"
+)
+
+// TranscriptionExecutor is implemented by provider executors that transcribe audio.
+// req.Payload is a JSON transcription request carrying the base64 audio in "file" with its
+// "filename" and "mime_type", plus the optional OpenAI "language", "prompt", "temperature"
+// and "response_format"; the response payload is an OpenAI verbose_json transcription
+// ("segments" may be empty when the provider has no timings).
+type TranscriptionExecutor interface {
+	Transcribe(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
+// SupportsTranscription reports whether exec, or the executor it wraps, transcribes audio.
+func SupportsTranscription(exec ProviderExecutor) bool {
+	for exec != nil {
+		if wrapper, ok := exec.(ExecutorWrapper); ok {
+			exec = wrapper.Unwrap()
+			continue
+		}
+		_, ok := exec.(TranscriptionExecutor)
+		return ok
+	}
+	return false
+}
+
+// TranscriptionProviders returns the providers whose registered executor transcribes audio.
+func (m *Manager) TranscriptionProviders(providers []string) []string {
+	normalized := m.normalizeProviders(providers)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]string, 0, len(normalized))
+	for _, provider := range normalized {
+		if SupportsTranscription(m.executors[provider]) {
+			out = append(out, provider)
+		}
+	}
+	return out
+}
+
+// ExecuteTranscription transcribes audio with the first provider and auth that succeeds.
+// Providers without transcription support are skipped.
+func (m *Manager) ExecuteTranscription(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	supported := m.TranscriptionProviders(providers)
+	if len(supported) == 0 {
+		return cliproxyexecutor.Response{}, &Error{Code: "transcription_unsupported", Message: "no provider for this model supports transcription", HTTPStatus: 400}
+	}
+	rotated := m.rotateProviders(req.Model, supported)
+	defer m.advanceProviderCursor(req.Model, supported)
+
+	var lastErr error
+	for _, provider := range rotated {
+		resp, errExec := m.executeTranscriptionWithProvider(ctx, provider, req, opts)
+		if errExec == nil {
+			return resp, nil
+		}
+		lastErr = errExec
+	}
+	if lastErr != nil {
+		return cliproxyexecutor.Response{}, lastErr
+	}
+	return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
+}
+
+func (m *Manager) executeTranscriptionWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	tried := make(map[string]struct{})
+	var lastErr error
+	for {
+		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		if errPick != nil {
+			if lastErr != nil {
+				return cliproxyexecutor.Response{}, lastErr
+			}
+			return cliproxyexecutor.Response{}, errPick
+		}
+		transcriber, ok := executor.(TranscriptionExecutor)
+		if !ok {
+			return cliproxyexecutor.Response{}, &Error{Code: "transcription_unsupported", Message: "executor does not support transcription", HTTPStatus: 400}
+		}
+
+		if accountType, accountInfo := auth.AccountInfo(); accountType == "api_key" {
+			log.Debugf("Use API key %s for transcription with model %s", util.HideAPIKey(accountInfo), req.Model)
+		} else if accountType != "" {
+			log.Debugf("Use %s %s for transcription with model %s", accountType, accountInfo, req.Model)
+		}
+
+		tried[auth.ID] = struct{}{}
+		execCtx := ctx
+		if rt := m.roundTripperFor(auth); rt != nil {
+			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+			execCtx = requestctx.WithRoundTripper(execCtx, rt)
+		}
+		resp, errExec := transcriber.Transcribe(execCtx, auth, req, opts)
+		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
+		if errExec != nil {
+			result.Error = resultError(errExec)
+			m.MarkResult(execCtx, result)
+			lastErr = errExec
+			continue
+		}
+		m.MarkResult(execCtx, result)
+		return resp, nil
+	}
+}
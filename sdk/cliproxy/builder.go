@@ -8,9 +8,11 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/idgen"
 )
 
 // Builder constructs a Service instance with customizable providers.
@@ -46,6 +48,10 @@ type Builder struct {
 
 	// serverOptions contains additional server configuration options.
 	serverOptions []api.ServerOption
+
+	// clock and ids replace the process-wide defaults of the idgen package when set.
+	clock idgen.Clock
+	ids   idgen.IDGenerator
 }
 
 // Hooks allows callers to plug into service lifecycle stages.
@@ -151,6 +157,20 @@ func (b *Builder) WithLocalManagementPassword(password string) *Builder {
 	return b
 }
 
+// WithClock sets the clock used for response timestamps and stored records. It is installed
+// process-wide when Build is called; use idgen.WithClock to override it for one request.
+func (b *Builder) WithClock(clock idgen.Clock) *Builder {
+	b.clock = clock
+	return b
+}
+
+// WithIDGenerator sets the generator used for response and tool call IDs. It is installed
+// process-wide when Build is called; use idgen.WithIDGenerator to override it for one request.
+func (b *Builder) WithIDGenerator(ids idgen.IDGenerator) *Builder {
+	b.ids = ids
+	return b
+}
+
 // Build validates inputs, applies defaults, and returns a ready-to-run service.
 func (b *Builder) Build() (*Service, error) {
 	if b.cfg == nil {
@@ -197,8 +217,10 @@ func (b *Builder) Build() (*Service, error) {
 		if dirSetter, ok := tokenStore.(interface{ SetBaseDir(string) }); ok && b.cfg != nil {
 			dirSetter.SetBaseDir(b.cfg.AuthDir)
 		}
-		coreManager = coreauth.NewManager(tokenStore, nil, nil)
+		coreManager = coreauth.NewManager(tokenStore, nil, usage.GetUtilizationTracker())
 	}
+	idgen.SetDefaults(b.clock, b.ids)
+
 	// Attach a default RoundTripper provider so providers can opt-in per-auth transports.
 	coreManager.SetRoundTripperProvider(newDefaultRoundTripperProvider())
 
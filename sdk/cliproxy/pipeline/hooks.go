@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// HookInfo describes the request a payload hook runs for.
+type HookInfo struct {
+	// Provider is the provider key of the executor, e.g. "gemini" or an openai-compatibility name.
+	Provider string
+	// Model is the model requested from the provider.
+	Model string
+	// Auth is the credential selected for the request.
+	Auth *cliproxyauth.Auth
+	// SourceFormat is the client's API format ("openai", "claude", "gemini", ...).
+	SourceFormat string
+	// Stream reports whether the response is streamed.
+	Stream bool
+}
+
+// PayloadHook mutates payloads around upstream calls.
+//
+// BeforeSend receives the request body after translation to the provider's format, right
+// before it is sent, and returns the body to send. AfterReceive receives the response after
+// translation back to the client's format (the whole body, or one chunk of a stream) and
+// returns the payload to return. Returning an error fails the request; a
+// cliproxyexecutor.StatusError sets the HTTP status the client receives.
+type PayloadHook interface {
+	BeforeSend(ctx context.Context, info HookInfo, body []byte) ([]byte, error)
+	AfterReceive(ctx context.Context, info HookInfo, payload []byte) ([]byte, error)
+}
+
+// PayloadHookFunc adapts optional functions to PayloadHook.
+type PayloadHookFunc struct {
+	Before func(context.Context, HookInfo, []byte) ([]byte, error)
+	After  func(context.Context, HookInfo, []byte) ([]byte, error)
+}
+
+// BeforeSend implements PayloadHook.
+func (h PayloadHookFunc) BeforeSend(ctx context.Context, info HookInfo, body []byte) ([]byte, error) {
+	if h.Before == nil {
+		return body, nil
+	}
+	return h.Before(ctx, info, body)
+}
+
+// AfterReceive implements PayloadHook.
+func (h PayloadHookFunc) AfterReceive(ctx context.Context, info HookInfo, payload []byte) ([]byte, error) {
+	if h.After == nil {
+		return payload, nil
+	}
+	return h.After(ctx, info, payload)
+}
+
+type registeredHook struct {
+	hook      PayloadHook
+	providers []string
+}
+
+var (
+	hooksMu sync.RWMutex
+	hooks   []registeredHook
+)
+
+// RegisterPayloadHook adds a hook for the given providers, or for every provider when none
+// are given. Hooks run in registration order, each receiving the previous hook's output.
+func RegisterPayloadHook(hook PayloadHook, providers ...string) {
+	if hook == nil {
+		return
+	}
+	normalized := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+			normalized = append(normalized, provider)
+		}
+	}
+	hooksMu.Lock()
+	hooks = append(hooks, registeredHook{hook: hook, providers: normalized})
+	hooksMu.Unlock()
+}
+
+// ApplyBeforeSend runs the hooks registered for info.Provider on a translated request body.
+// Executors call it before sending; custom executors should do the same.
+func ApplyBeforeSend(ctx context.Context, info HookInfo, body []byte) ([]byte, error) {
+	for _, hook := range hooksFor(info.Provider) {
+		out, err := hook.BeforeSend(ctx, info, body)
+		if err != nil {
+			return nil, err
+		}
+		body = out
+	}
+	return body, nil
+}
+
+// ApplyAfterReceive runs the hooks registered for info.Provider on a response payload.
+func ApplyAfterReceive(ctx context.Context, info HookInfo, payload []byte) ([]byte, error) {
+	for _, hook := range hooksFor(info.Provider) {
+		out, err := hook.AfterReceive(ctx, info, payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = out
+	}
+	return payload, nil
+}
+
+// HasPayloadHooks reports whether any hook applies to provider.
+func HasPayloadHooks(provider string) bool { return len(hooksFor(provider)) > 0 }
+
+func hooksFor(provider string) []PayloadHook {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+	provider = strings.ToLower(provider)
+	out := make([]PayloadHook, 0, len(hooks))
+	for _, registered := range hooks {
+		if len(registered.providers) == 0 || containsProvider(registered.providers, provider) {
+			out = append(out, registered.hook)
+		}
+	}
+	return out
+}
+
+func containsProvider(providers []string, provider string) bool {
+	for _, p := range providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
@@ -8,8 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
@@ -18,12 +20,14 @@ import (
 	conversation "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web/conversation"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/trash"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -67,6 +71,9 @@ type Service struct {
 	// watcherCancel cancels the watcher context.
 	watcherCancel context.CancelFunc
 
+	// trashPurgeCancel stops the periodic purge of expired soft-deleted items.
+	trashPurgeCancel context.CancelFunc
+
 	// authUpdates channel for authentication updates.
 	authUpdates chan watcher.AuthUpdate
 
@@ -183,13 +190,28 @@ func (s *Service) applyCoreAuthAddOrUpdate(ctx context.Context, auth *coreauth.A
 	}
 	auth = auth.Clone()
 	s.ensureExecutorsForAuth(auth)
-	s.registerModelsForAuth(auth)
+	if auth.Disabled {
+		GlobalModelRegistry().UnregisterClient(auth.ID)
+	} else {
+		s.registerModelsForAuth(auth)
+	}
 	if existing, ok := s.coreManager.GetByID(auth.ID); ok && existing != nil {
 		auth.CreatedAt = existing.CreatedAt
 		auth.LastRefreshedAt = existing.LastRefreshedAt
 		auth.NextRefreshAfter = existing.NextRefreshAfter
+		keepSession := sameGeminiWebSession(existing, auth)
+		if keepSession {
+			// Keep the warm session when only non-cookie fields of the file changed.
+			auth.Runtime = existing.Runtime
+		}
 		if _, err := s.coreManager.Update(ctx, auth); err != nil {
 			log.Errorf("failed to update auth %s: %v", auth.ID, err)
+			return
+		}
+		if !keepSession {
+			// New cookies get a fresh session on the next request; requests still running on
+			// the old one finish on it.
+			executor.ReleaseGeminiWebState(existing)
 		}
 		return
 	}
@@ -208,22 +230,13 @@ func (s *Service) applyCoreAuthRemoval(ctx context.Context, id string) {
 	GlobalModelRegistry().UnregisterClient(id)
 	if existing, ok := s.coreManager.GetByID(id); ok && existing != nil {
 		if strings.EqualFold(existing.Provider, "gemini-web") {
-			// Prefer the stable cookie label stored in metadata when available.
-			var label string
-			if existing.Metadata != nil {
-				if v, ok := existing.Metadata["label"].(string); ok {
-					label = strings.TrimSpace(v)
-				}
-			}
-			if label == "" {
-				label = strings.TrimSpace(existing.Label)
-			}
-			if label != "" {
+			if label := coreauth.GeminiWebAccountLabel(existing); label != "" {
 				if err := conversation.RemoveMatchesByLabel(label); err != nil {
 					log.Debugf("failed to remove gemini web sticky entries for %s: %v", label, err)
 				}
 			}
 		}
+		executor.ReleaseGeminiWebState(existing)
 		existing.Disabled = true
 		existing.Status = coreauth.StatusDisabled
 		if _, err := s.coreManager.Update(ctx, existing); err != nil {
@@ -232,30 +245,108 @@ func (s *Service) applyCoreAuthRemoval(ctx context.Context, id string) {
 	}
 }
 
+// sameGeminiWebSession reports whether two revisions of a Gemini Web auth use the same
+// __Secure-1PSID cookie and can share a session.
+func sameGeminiWebSession(existing, updated *coreauth.Auth) bool {
+	if existing.Runtime == nil || existing.Disabled || updated.Disabled || !strings.EqualFold(updated.Provider, "gemini-web") {
+		return false
+	}
+	oldPSID, _ := existing.Metadata["secure_1psid"].(string)
+	newPSID, _ := updated.Metadata["secure_1psid"].(string)
+	return oldPSID != "" && oldPSID == newPSID && existing.ProxyURL == updated.ProxyURL
+}
+
+// wrapExecutor applies the cross-provider guards: bandwidth ceilings are checked before
+// fault injection so that an account over its ceiling is never sent anything.
+func wrapExecutor(e coreauth.ProviderExecutor) coreauth.ProviderExecutor {
+	return executor.WithBandwidthCeilings(executor.WithFaultInjection(executor.WithSecretMasking(executor.WithPayloadHooks(e))))
+}
+
 func (s *Service) ensureExecutorsForAuth(a *coreauth.Auth) {
 	if s == nil || a == nil {
 		return
 	}
 	switch strings.ToLower(a.Provider) {
 	case "gemini":
-		s.coreManager.RegisterExecutor(executor.NewGeminiExecutor(s.cfg))
+		s.coreManager.RegisterExecutor(wrapExecutor(executor.NewGeminiExecutor(s.cfg)))
 	case "gemini-cli":
-		s.coreManager.RegisterExecutor(executor.NewGeminiCLIExecutor(s.cfg))
+		s.coreManager.RegisterExecutor(wrapExecutor(executor.NewGeminiCLIExecutor(s.cfg)))
 	case "gemini-web":
-		s.coreManager.RegisterExecutor(executor.NewGeminiWebExecutor(s.cfg))
+		s.coreManager.RegisterExecutor(wrapExecutor(executor.NewGeminiWebExecutor(s.cfg)))
 		s.coreManager.EnableGeminiWebStickySelector()
 	case "claude":
-		s.coreManager.RegisterExecutor(executor.NewClaudeExecutor(s.cfg))
+		s.coreManager.RegisterExecutor(wrapExecutor(executor.NewClaudeExecutor(s.cfg)))
 	case "codex":
-		s.coreManager.RegisterExecutor(executor.NewCodexExecutor(s.cfg))
+		s.coreManager.RegisterExecutor(wrapExecutor(executor.NewCodexExecutor(s.cfg)))
 	case "qwen":
-		s.coreManager.RegisterExecutor(executor.NewQwenExecutor(s.cfg))
+		s.coreManager.RegisterExecutor(wrapExecutor(executor.NewQwenExecutor(s.cfg)))
+	case "bedrock":
+		s.coreManager.RegisterExecutor(wrapExecutor(executor.NewBedrockExecutor(s.cfg)))
+	case "vertex":
+		s.coreManager.RegisterExecutor(wrapExecutor(executor.NewVertexExecutor(s.cfg)))
+		// Gemini Web serves the same models once every service account is out of quota.
+		s.coreManager.SetProviderFallback("vertex", "gemini-web")
 	default:
 		providerKey := strings.ToLower(strings.TrimSpace(a.Provider))
 		if providerKey == "" {
 			providerKey = "openai-compatibility"
 		}
-		s.coreManager.RegisterExecutor(executor.NewOpenAICompatExecutor(providerKey, s.cfg))
+		s.coreManager.RegisterExecutor(wrapExecutor(executor.NewOpenAICompatExecutor(providerKey, s.cfg)))
+	}
+}
+
+// configureTranslationCache sizes the translated request cache from the configuration.
+func configureTranslationCache(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	capacity := cfg.TranslationCacheEntries
+	if capacity == 0 {
+		capacity = sdktranslator.DefaultRequestCacheCapacity
+	}
+	sdktranslator.SetRequestCacheCapacity(capacity)
+}
+
+// configureGeminiWebSharedIndex applies the gemini-web shared-index settings.
+func configureGeminiWebSharedIndex(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	shared := cfg.GeminiWeb.SharedIndex
+	ttl := time.Duration(shared.TTLHours) * time.Hour
+	if err := conversation.ConfigureSharedIndex(shared.RedisURL, shared.KeyPrefix, ttl); err != nil {
+		log.Warnf("gemini web shared conversation index disabled: %v", err)
+	}
+}
+
+// configureGeminiWebHashNormalization applies the gemini-web hash-normalization settings.
+func configureGeminiWebHashNormalization(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	n := cfg.GeminiWeb.HashNormalization
+	if err := conversation.ConfigureNormalization(n.Trim, n.CollapseWhitespace, n.StripPatterns); err != nil {
+		log.Warnf("gemini web hash normalization: %v", err)
+	}
+}
+
+// refreshExecutors re-registers the executors of every known auth so they pick up the
+// current configuration after a reload.
+func (s *Service) refreshExecutors() {
+	if s == nil || s.coreManager == nil {
+		return
+	}
+	seen := make(map[string]struct{})
+	for _, a := range s.coreManager.List() {
+		if a == nil {
+			continue
+		}
+		provider := strings.ToLower(strings.TrimSpace(a.Provider))
+		if _, ok := seen[provider]; ok {
+			continue
+		}
+		seen[provider] = struct{}{}
+		s.ensureExecutorsForAuth(a)
 	}
 }
 
@@ -290,10 +381,24 @@ func (s *Service) Run(ctx context.Context) error {
 		return err
 	}
 
+	configureGeminiWebSharedIndex(s.cfg)
+	configureGeminiWebHashNormalization(s.cfg)
+	configureTranslationCache(s.cfg)
+
 	if s.coreManager != nil {
 		if errLoad := s.coreManager.Load(ctx); errLoad != nil {
 			log.Warnf("failed to load auth store: %v", errLoad)
 		}
+		// File auths are announced by the watcher; auths kept in a secrets backend are not,
+		// so their executors and models are registered here.
+		if _, isFileStore := sdkAuth.GetTokenStore().(*sdkAuth.FileTokenStore); !isFileStore {
+			for _, auth := range s.coreManager.List() {
+				s.ensureExecutorsForAuth(auth)
+				if !auth.Disabled {
+					s.registerModelsForAuth(auth)
+				}
+			}
+		}
 	}
 
 	tokenResult, err := s.tokenProvider.Load(ctx, s.cfg)
@@ -357,7 +462,10 @@ func (s *Service) Run(ctx context.Context) error {
 		s.cfgMu.Lock()
 		s.cfg = newCfg
 		s.cfgMu.Unlock()
-
+		s.refreshExecutors()
+		configureGeminiWebSharedIndex(newCfg)
+		configureGeminiWebHashNormalization(newCfg)
+		configureTranslationCache(newCfg)
 	}
 
 	watcherWrapper, err = s.watcherFactory(s.configPath, s.cfg.AuthDir, reloadCallback)
@@ -377,6 +485,7 @@ func (s *Service) Run(ctx context.Context) error {
 		return fmt.Errorf("cliproxy: failed to start watcher: %w", err)
 	}
 	log.Info("file watcher started for config and auth directory changes")
+	go s.reloadOnSignal(watcherCtx, watcherWrapper)
 
 	// Prefer core auth manager auto refresh if available.
 	if s.coreManager != nil {
@@ -385,6 +494,10 @@ func (s *Service) Run(ctx context.Context) error {
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
 	}
 
+	purgeCtx, purgeCancel := context.WithCancel(context.Background())
+	s.trashPurgeCancel = purgeCancel
+	go s.runTrashPurge(purgeCtx)
+
 	select {
 	case <-ctx.Done():
 		log.Debug("service context cancelled, shutting down...")
@@ -394,6 +507,64 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 }
 
+// reloadOnSignal reloads the config and auth directory on every SIGHUP until ctx is cancelled.
+// The server keeps listening and serving throughout.
+func (s *Service) reloadOnSignal(ctx context.Context, w *WatcherWrapper) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			log.Info("received SIGHUP, reloading config and accounts")
+			w.Reload()
+		}
+	}
+}
+
+// trashPurgeInterval is how often expired soft-deleted items are removed while serving.
+const trashPurgeInterval = time.Hour
+
+// runTrashPurge removes soft-deleted auth files and Gemini Web conversations whose restore
+// window has passed, once at start and then every trashPurgeInterval until ctx is cancelled.
+func (s *Service) runTrashPurge(ctx context.Context) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		s.purgeTrash()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) purgeTrash() {
+	s.cfgMu.RLock()
+	cfg := s.cfg
+	s.cfgMu.RUnlock()
+	if cfg == nil {
+		return
+	}
+	retention := cfg.SoftDelete.Retention()
+	now := time.Now()
+	if cfg.AuthDir != "" {
+		if n, err := trash.Purge(cfg.AuthDir, retention, now); err != nil {
+			log.Warnf("failed to purge deleted auth files: %v", err)
+		} else if n > 0 {
+			log.Infof("purged %d expired deleted auth files", n)
+		}
+	}
+	if n, err := geminiwebclient.PurgeDeletedConversations(geminiwebclient.ConvDir(), retention, now); err != nil {
+		log.Warnf("failed to purge deleted conversations: %v", err)
+	} else if n > 0 {
+		log.Infof("purged %d expired deleted conversations", n)
+	}
+}
+
 // Shutdown gracefully stops background workers and the HTTP server.
 // It ensures all resources are properly cleaned up and connections are closed.
 // The shutdown is idempotent and can be called multiple times safely.
@@ -418,6 +589,9 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		if s.watcherCancel != nil {
 			s.watcherCancel()
 		}
+		if s.trashPurgeCancel != nil {
+			s.trashPurgeCancel()
+		}
 		if s.coreManager != nil {
 			s.coreManager.StopAutoRefresh()
 		}
@@ -485,7 +659,8 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 	var models []*ModelInfo
 	switch provider {
 	case "gemini":
-		models = registry.GetGeminiModels()
+		models = append(registry.GetGeminiModels(), registry.GetGeminiEmbeddingModels()...)
+		models = append(models, registry.GetGeminiTTSModels()...)
 	case "gemini-cli":
 		models = registry.GetGeminiCLIModels()
 	case "gemini-web":
@@ -496,6 +671,14 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		models = registry.GetOpenAIModels()
 	case "qwen":
 		models = registry.GetQwenModels()
+	case "vertex":
+		models = registry.GetGeminiModels()
+	case "bedrock":
+		models = s.bedrockModels(a)
+		if len(models) == 0 {
+			GlobalModelRegistry().UnregisterClient(a.ID)
+			return
+		}
 	default:
 		// Handle OpenAI-compatibility providers by name using config
 		if s.cfg != nil {
@@ -571,3 +754,40 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		GlobalModelRegistry().RegisterClient(a.ID, key, models)
 	}
 }
+
+// bedrockModels returns the models configured for the Bedrock credentials of a, exposed under
+// their aliases.
+func (s *Service) bedrockModels(a *coreauth.Auth) []*ModelInfo {
+	if s.cfg == nil || a.Attributes == nil {
+		return nil
+	}
+	for i := range s.cfg.Bedrock {
+		key := &s.cfg.Bedrock[i]
+		if strings.TrimSpace(key.AccessKeyID) != a.Attributes["access_key_id"] ||
+			strings.TrimSpace(key.Region) != a.Attributes["region"] ||
+			strings.TrimSpace(key.BaseURL) != a.Attributes["base_url"] {
+			continue
+		}
+		models := make([]*ModelInfo, 0, len(key.Models))
+		for j := range key.Models {
+			m := key.Models[j]
+			id := strings.TrimSpace(m.Alias)
+			if id == "" {
+				id = strings.TrimSpace(m.Name)
+			}
+			if id == "" {
+				continue
+			}
+			models = append(models, &ModelInfo{
+				ID:          id,
+				Object:      "model",
+				Created:     time.Now().Unix(),
+				OwnedBy:     "bedrock",
+				Type:        "bedrock",
+				DisplayName: m.Name,
+			})
+		}
+		return models
+	}
+	return nil
+}
@@ -86,6 +86,7 @@ type WatcherWrapper struct {
 	setConfig      func(cfg *config.Config)
 	snapshotAuths  func() []*coreauth.Auth
 	setUpdateQueue func(queue chan<- watcher.AuthUpdate)
+	reload         func()
 }
 
 // Start proxies to the underlying watcher Start implementation.
@@ -104,6 +105,14 @@ func (w *WatcherWrapper) Stop() error {
 	return w.stop()
 }
 
+// Reload re-reads the config file and auth directory and applies what changed.
+func (w *WatcherWrapper) Reload() {
+	if w == nil || w.reload == nil {
+		return
+	}
+	w.reload()
+}
+
 // SetConfig updates the watcher configuration cache.
 func (w *WatcherWrapper) SetConfig(cfg *config.Config) {
 	if w == nil || w.setConfig == nil {
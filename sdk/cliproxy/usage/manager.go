@@ -8,6 +8,16 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// Outcome values describe how a provider request ended.
+const (
+	// OutcomeCompleted marks a request that finished normally.
+	OutcomeCompleted = "completed"
+	// OutcomeClientCancelled marks a request abandoned by the client before completion.
+	OutcomeClientCancelled = "client_cancelled"
+	// OutcomeUpstreamFailed marks a request that failed at the provider.
+	OutcomeUpstreamFailed = "upstream_failed"
+)
+
 // Record contains the usage statistics captured for a single provider request.
 type Record struct {
 	Provider    string
@@ -16,6 +26,10 @@ type Record struct {
 	AuthID      string
 	RequestedAt time.Time
 	Detail      Detail
+	// Outcome is one of the Outcome* constants; empty for records from older emitters.
+	Outcome string
+	// DeliveredBytes counts response payload bytes handed to the client before the request ended.
+	DeliveredBytes int64
 }
 
 // Detail holds the token usage breakdown.
@@ -17,6 +17,198 @@ type SDKConfig struct {
 
 	// Access holds request authentication provider configuration.
 	Access AccessConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
+
+	// FinishReasonMap rewrites finish/stop reasons in responses, keyed by handler type
+	// ("openai", "openai-response", "claude", "gemini", "gemini-cli") and then by the
+	// upstream value. For example {"openai": {"content_filter": "stop"}}.
+	FinishReasonMap map[string]map[string]string `yaml:"finish-reason-map,omitempty" json:"finish-reason-map,omitempty"`
+
+	// ModelAliases maps model names clients may request to the model that serves them, for
+	// example {"gemini-pro-latest": "gemini-2.5-pro"}. The model-aliases of an API key rule
+	// are applied first and override these.
+	ModelAliases map[string]string `yaml:"model-aliases,omitempty" json:"model-aliases,omitempty"`
+
+	// FallbackChains route a model through an ordered list of providers, cascading to the next
+	// one when a provider is rate limited or failing.
+	FallbackChains []FallbackChain `yaml:"fallback-chains,omitempty" json:"fallback-chains,omitempty"`
+
+	// TrafficSplits send a weighted share of a model's traffic to other providers or account
+	// groups, e.g. to validate a new backend. Models with a fallback chain are not split.
+	TrafficSplits []TrafficSplit `yaml:"traffic-splits,omitempty" json:"traffic-splits,omitempty"`
+
+	// APIKeyRules restricts and reroutes models per client API key. Keys without a rule
+	// may use every model.
+	APIKeyRules []APIKeyRule `yaml:"api-key-rules,omitempty" json:"api-key-rules,omitempty"`
+
+	// AgentLoop configures the server-side tool loop behind POST /v1/chat/completions:run.
+	AgentLoop AgentLoopConfig `yaml:"agent-loop,omitempty" json:"agent-loop,omitempty"`
+
+	// ResponseStore configures persistence of Responses API results requested with store=true.
+	ResponseStore ResponseStoreConfig `yaml:"response-store,omitempty" json:"response-store,omitempty"`
+
+	// Sessions keeps chat completions history server-side for clients that send only the
+	// new messages of each turn.
+	Sessions SessionConfig `yaml:"sessions,omitempty" json:"sessions,omitempty"`
+
+	// Degradation answers requests from the last known good response or a static message
+	// when every account for the model fails.
+	Degradation DegradationConfig `yaml:"degradation,omitempty" json:"degradation,omitempty"`
+
+	// TranslationCacheEntries is how many translated request skeletons (the body without its
+	// messages) are kept so that repeated system prompts and tools are not translated again
+	// every turn. 0 uses the default of 256; a negative value disables the cache.
+	TranslationCacheEntries int `yaml:"translation-cache-entries,omitempty" json:"translation-cache-entries,omitempty"`
+
+	// StreamKeepaliveSeconds writes an SSE comment to streaming clients at this interval, so
+	// idle timeouts of clients and proxies do not cut slow generations. 0 disables keepalives.
+	StreamKeepaliveSeconds int `yaml:"stream-keepalive-seconds,omitempty" json:"stream-keepalive-seconds,omitempty"`
+}
+
+// DegradationConfig controls degraded answers for models whose accounts are all failing.
+type DegradationConfig struct {
+	// Enabled turns degraded answers on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Models limits degradation to these models; a trailing "*" matches by prefix. An empty
+	// list covers every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// CacheTTLMinutes is how long a successful answer is kept for identical requests.
+	// Defaults to 1440 (one day).
+	CacheTTLMinutes int `yaml:"cache-ttl-minutes,omitempty" json:"cache-ttl-minutes,omitempty"`
+
+	// CacheEntries caps the number of kept answers. Defaults to 512.
+	CacheEntries int `yaml:"cache-entries,omitempty" json:"cache-entries,omitempty"`
+
+	// FallbackMessage is answered when no kept answer matches. When empty the error is
+	// returned instead.
+	FallbackMessage string `yaml:"fallback-message,omitempty" json:"fallback-message,omitempty"`
+
+	// FinishReason marks fallback answers. Defaults to "degraded".
+	FinishReason string `yaml:"finish-reason,omitempty" json:"finish-reason,omitempty"`
+}
+
+// ResponseStoreConfig controls how stored responses are kept.
+type ResponseStoreConfig struct {
+	// Disabled ignores store=true and rejects retrieval.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// TTLHours is how long a stored response can be retrieved. Defaults to 720 (30 days).
+	TTLHours int `yaml:"ttl-hours,omitempty" json:"ttl-hours,omitempty"`
+}
+
+// SessionConfig controls server-side chat sessions.
+type SessionConfig struct {
+	// Enabled exposes /v1/sessions and the session_id field of chat completions.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// TTLHours is how long a session is kept after its last turn. Defaults to 24.
+	TTLHours int `yaml:"ttl-hours,omitempty" json:"ttl-hours,omitempty"`
+
+	// MaxMessages caps the kept history; the oldest non-system messages are dropped first.
+	// 0 keeps every message.
+	MaxMessages int `yaml:"max-messages,omitempty" json:"max-messages,omitempty"`
+}
+
+// AgentLoopConfig controls the server-side agent loop, which keeps calling the model while it
+// requests server-side tools and returns the final answer.
+type AgentLoopConfig struct {
+	// Enabled exposes the :run extension on chat completions.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// MaxSteps caps the number of model calls per request. Defaults to 8.
+	MaxSteps int `yaml:"max-steps,omitempty" json:"max-steps,omitempty"`
+
+	// TimeoutSeconds caps the wall time of the whole loop. Defaults to 120.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// BuiltinTools lists the built-in tools offered to the model ("current_time").
+	// An empty list offers all of them.
+	BuiltinTools []string `yaml:"builtin-tools,omitempty" json:"builtin-tools,omitempty"`
+
+	// MCPServers lists MCP servers (streamable HTTP transport) whose tools are offered.
+	MCPServers []MCPServerConfig `yaml:"mcp-servers,omitempty" json:"mcp-servers,omitempty"`
+}
+
+// MCPServerConfig describes an MCP server reachable over HTTP.
+type MCPServerConfig struct {
+	// Name prefixes the server's tool names as "<name>__<tool>".
+	Name string `yaml:"name" json:"name"`
+
+	// URL is the MCP endpoint.
+	URL string `yaml:"url" json:"url"`
+
+	// Headers are sent with every request, e.g. an Authorization header.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// APIKeyRule limits which models and providers a client API key may use.
+type APIKeyRule struct {
+	// APIKey is the client key the rule applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// AllowedModels lists permitted models; a trailing "*" matches by prefix.
+	// An empty list allows every model.
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+
+	// AllowedProviders lists permitted providers (e.g. "gemini-web", "claude").
+	// An empty list allows every provider.
+	AllowedProviders []string `yaml:"allowed-providers,omitempty" json:"allowed-providers,omitempty"`
+
+	// AllowedAccounts lists the accounts (auth ID, label or auth file name) that may serve
+	// this key, including through x_cliproxy.account pins. An empty list allows every account.
+	AllowedAccounts []string `yaml:"allowed-accounts,omitempty" json:"allowed-accounts,omitempty"`
+
+	// ModelAliases maps a requested model name to the model actually served,
+	// e.g. {"gpt-4o": "gemini-2.5-pro"}. Aliases are resolved before the allowlist check.
+	ModelAliases map[string]string `yaml:"model-aliases,omitempty" json:"model-aliases,omitempty"`
+}
+
+// FallbackChain lists the providers tried in order for a model. A provider answering 429 or
+// 5xx, or without an available account, passes the request on to the next step.
+type FallbackChain struct {
+	// Model is the requested model, after model-aliases, the chain applies to.
+	Model string `yaml:"model" json:"model"`
+
+	// Steps are tried in order until one succeeds.
+	Steps []FallbackStep `yaml:"steps" json:"steps"`
+}
+
+// FallbackStep is one provider of a fallback chain.
+type FallbackStep struct {
+	// Provider is the provider key, e.g. "gemini-web", "vertex" or the name of an
+	// openai-compatibility provider.
+	Provider string `yaml:"provider" json:"provider"`
+
+	// Model is the model requested from this provider. Defaults to the chain's model.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+}
+
+// TrafficSplit divides the traffic of a model between weighted routes.
+type TrafficSplit struct {
+	// Model is the requested model, after model-aliases, the split applies to.
+	Model string `yaml:"model" json:"model"`
+
+	// Routes receive requests in proportion to their weight.
+	Routes []TrafficRoute `yaml:"routes" json:"routes"`
+}
+
+// TrafficRoute is one share of a traffic split.
+type TrafficRoute struct {
+	// Name labels the route in the traffic split metrics. Defaults to the provider.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Weight is the relative share of requests; routes with weight 0 receive none.
+	Weight int `yaml:"weight" json:"weight"`
+
+	// Provider limits the route to one provider. Empty keeps every provider of the model.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Model is the model requested on this route. Defaults to the split's model.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	// Accounts limits the route to an account group (auth ID, label or auth file name).
+	Accounts []string `yaml:"accounts,omitempty" json:"accounts,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
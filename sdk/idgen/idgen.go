@@ -0,0 +1,149 @@
+// Package idgen supplies the clock and the ID generator behind response IDs, tool call IDs
+// and timestamps written by translators, providers and stores.
+//
+// The defaults use the system clock and random IDs. Embedders that need reproducible output,
+// typically in tests, install their own implementations process-wide with SetDefaults or for
+// a single request with WithClock and WithIDGenerator.
+package idgen
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// Clock reports the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// IDGenerator returns a new identifier that starts with prefix, such as "chatcmpl-" or
+// "toolu_". Identifiers only need to be unique within the process.
+type IDGenerator interface {
+	NewID(prefix string) string
+}
+
+// ClockFunc adapts a function to the Clock interface.
+type ClockFunc func() time.Time
+
+// Now calls f.
+func (f ClockFunc) Now() time.Time { return f() }
+
+// IDGeneratorFunc adapts a function to the IDGenerator interface.
+type IDGeneratorFunc func(prefix string) string
+
+// NewID calls f.
+func (f IDGeneratorFunc) NewID(prefix string) string { return f(prefix) }
+
+// SystemClock returns the clock backed by time.Now.
+func SystemClock() Clock { return ClockFunc(time.Now) }
+
+// RandomIDs returns the generator that appends 24 random alphanumeric characters to the prefix.
+func RandomIDs() IDGenerator { return IDGeneratorFunc(randomID) }
+
+type clockKey struct{}
+
+type idGeneratorKey struct{}
+
+var (
+	mu               sync.RWMutex
+	defaultClock     = SystemClock()
+	defaultGenerator = RandomIDs()
+)
+
+// SetDefaults replaces the process-wide clock and ID generator. A nil argument keeps the
+// current value.
+func SetDefaults(clock Clock, ids IDGenerator) {
+	mu.Lock()
+	defer mu.Unlock()
+	if clock != nil {
+		defaultClock = clock
+	}
+	if ids != nil {
+		defaultGenerator = ids
+	}
+}
+
+// ResetDefaults restores the system clock and random IDs.
+func ResetDefaults() {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultClock = SystemClock()
+	defaultGenerator = RandomIDs()
+}
+
+// WithClock returns a context whose requests use clock instead of the default.
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// WithIDGenerator returns a context whose requests use ids instead of the default.
+func WithIDGenerator(ctx context.Context, ids IDGenerator) context.Context {
+	return context.WithValue(ctx, idGeneratorKey{}, ids)
+}
+
+// Propagate copies the clock and ID generator installed on src, if any, onto dst. Handlers use
+// it when they derive a fresh context from the one of the incoming request.
+func Propagate(dst, src context.Context) context.Context {
+	if src == nil {
+		return dst
+	}
+	if clock, ok := src.Value(clockKey{}).(Clock); ok && clock != nil {
+		dst = WithClock(dst, clock)
+	}
+	if ids, ok := src.Value(idGeneratorKey{}).(IDGenerator); ok && ids != nil {
+		dst = WithIDGenerator(dst, ids)
+	}
+	return dst
+}
+
+// ClockFrom returns the clock installed on ctx, or the default.
+func ClockFrom(ctx context.Context) Clock {
+	if ctx != nil {
+		if clock, ok := ctx.Value(clockKey{}).(Clock); ok && clock != nil {
+			return clock
+		}
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultClock
+}
+
+// IDGeneratorFrom returns the ID generator installed on ctx, or the default.
+func IDGeneratorFrom(ctx context.Context) IDGenerator {
+	if ctx != nil {
+		if ids, ok := ctx.Value(idGeneratorKey{}).(IDGenerator); ok && ids != nil {
+			return ids
+		}
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultGenerator
+}
+
+// Now returns the current time of the clock for ctx.
+func Now(ctx context.Context) time.Time {
+	return ClockFrom(ctx).Now()
+}
+
+// NewID returns a new identifier with prefix from the ID generator for ctx.
+func NewID(ctx context.Context, prefix string) string {
+	return IDGeneratorFrom(ctx).NewID(prefix)
+}
+
+const idLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomID(prefix string) string {
+	b := make([]byte, 24)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(idLetters))))
+		if err != nil {
+			b[i] = idLetters[time.Now().UnixNano()%int64(len(idLetters))]
+			continue
+		}
+		b[i] = idLetters[n.Int64()]
+	}
+	return prefix + string(b)
+}
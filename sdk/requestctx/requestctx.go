@@ -0,0 +1,211 @@
+// Package requestctx carries per-request metadata through the context handed to executors,
+// translators and hooks under typed keys.
+//
+// The API handlers install the metadata once per request: the client API format, the
+// authenticated tenant, a correlation ID and the debug flag, together with the Gin context and
+// the response alt parameter. Custom providers read it back with the accessors below instead of
+// looking up untyped string keys.
+package requestctx
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CorrelationHeader is the header the correlation ID is read from and echoed back in.
+const CorrelationHeader = "X-Request-ID"
+
+// Metadata describes the client request behind a context.
+type Metadata struct {
+	// HandlerType is the client API format, such as "openai", "claude" or "gemini".
+	HandlerType string
+	// Tenant is the authenticated principal, normally the client API key. Empty when access
+	// control is disabled.
+	Tenant string
+	// CorrelationID identifies the request in logs and in the X-Request-ID response header.
+	CorrelationID string
+	// Debug reports whether debug features are enabled for the request.
+	Debug bool
+}
+
+type metadataKey struct{}
+
+type ginKey struct{}
+
+type altKey struct{}
+
+type roundTripperKey struct{}
+
+// WithMetadata returns a context carrying md.
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFrom returns the metadata installed on ctx.
+func MetadataFrom(ctx context.Context) (Metadata, bool) {
+	if ctx == nil {
+		return Metadata{}, false
+	}
+	md, ok := ctx.Value(metadataKey{}).(Metadata)
+	return md, ok
+}
+
+// HandlerType returns the client API format of the request, or "" outside a client request.
+func HandlerType(ctx context.Context) string {
+	md, _ := MetadataFrom(ctx)
+	return md.HandlerType
+}
+
+// Tenant returns the authenticated principal of the request.
+func Tenant(ctx context.Context) string {
+	md, _ := MetadataFrom(ctx)
+	return md.Tenant
+}
+
+// CorrelationID returns the correlation ID of the request.
+func CorrelationID(ctx context.Context) string {
+	md, _ := MetadataFrom(ctx)
+	return md.CorrelationID
+}
+
+// Debug reports whether debug features are enabled for the request.
+func Debug(ctx context.Context) bool {
+	md, _ := MetadataFrom(ctx)
+	return md.Debug
+}
+
+// WithGin returns a context carrying the Gin context of the client request.
+func WithGin(ctx context.Context, c *gin.Context) context.Context {
+	return context.WithValue(ctx, ginKey{}, c)
+}
+
+// Gin returns the Gin context of the client request, or nil for requests that did not come
+// through the HTTP server.
+func Gin(ctx context.Context) *gin.Context {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value(ginKey{}).(*gin.Context)
+	return c
+}
+
+// AccessMetadataKey is the Gin context key under which the authentication middleware stores
+// the metadata of the access provider result.
+const AccessMetadataKey = "accessMetadata"
+
+// AccessMetadata returns the metadata the access provider attached to the authenticated
+// request behind ctx, such as a granted quota tier, or nil.
+func AccessMetadata(ctx context.Context) map[string]string {
+	c := Gin(ctx)
+	if c == nil {
+		return nil
+	}
+	return c.GetStringMapString(AccessMetadataKey)
+}
+
+// skipStreamDoneKey marks, on the Gin context, a stream that must not end with "data: [DONE]".
+const skipStreamDoneKey = "requestctx.skip-stream-done"
+
+// SkipStreamDone asks the handler serving ctx to end an OpenAI-compatible stream without the
+// "data: [DONE]" line. It has no effect for requests without a Gin context.
+func SkipStreamDone(ctx context.Context) {
+	if c := Gin(ctx); c != nil {
+		c.Set(skipStreamDoneKey, true)
+	}
+}
+
+// StreamDoneSkipped reports whether a provider called SkipStreamDone for the request of c.
+func StreamDoneSkipped(c *gin.Context) bool {
+	return c != nil && c.GetBool(skipStreamDoneKey)
+}
+
+// responseGuardKey holds, on the Gin context, the *responseGuard of the request.
+const responseGuardKey = "requestctx.response-guard"
+
+// responseGuard orders the response headers set while a request executes against writes to
+// the response made concurrently, such as stream keepalives.
+type responseGuard struct {
+	mu        sync.Mutex
+	committed bool
+}
+
+// GuardResponse prepares the response of c for SetHeader and WriteResponse. Handlers call it
+// before executing the request, while no other goroutine uses c.
+func GuardResponse(c *gin.Context) {
+	if c != nil {
+		c.Set(responseGuardKey, &responseGuard{})
+	}
+}
+
+func guardOf(c *gin.Context) *responseGuard {
+	value, ok := c.Get(responseGuardKey)
+	if !ok {
+		return nil
+	}
+	guard, _ := value.(*responseGuard)
+	return guard
+}
+
+// SetHeader sets a response header of the client request behind ctx. It reports false, and
+// the header is not sent, when the response was already committed by WriteResponse or when
+// ctx has no Gin context.
+func SetHeader(ctx context.Context, key, value string) bool {
+	c := Gin(ctx)
+	if c == nil {
+		return false
+	}
+	if guard := guardOf(c); guard != nil {
+		guard.mu.Lock()
+		defer guard.mu.Unlock()
+		if guard.committed {
+			return false
+		}
+	}
+	c.Header(key, value)
+	return true
+}
+
+// WriteResponse runs write, which writes to the response of c, without overlapping SetHeader
+// calls made while the request executes. The headers are committed by then.
+func WriteResponse(c *gin.Context, write func()) {
+	guard := guardOf(c)
+	if guard == nil {
+		write()
+		return
+	}
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	write()
+	guard.committed = true
+}
+
+// WithAlt returns a context carrying the response alt parameter ("sse" or "" for JSON).
+func WithAlt(ctx context.Context, alt string) context.Context {
+	return context.WithValue(ctx, altKey{}, alt)
+}
+
+// Alt returns the response alt parameter of the request.
+func Alt(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	alt, ok := ctx.Value(altKey{}).(string)
+	return alt, ok
+}
+
+// WithRoundTripper returns a context whose upstream requests use rt.
+func WithRoundTripper(ctx context.Context, rt http.RoundTripper) context.Context {
+	return context.WithValue(ctx, roundTripperKey{}, rt)
+}
+
+// RoundTripper returns the round tripper selected for the request's credential, or nil.
+func RoundTripper(ctx context.Context) http.RoundTripper {
+	if ctx == nil {
+		return nil
+	}
+	rt, _ := ctx.Value(roundTripperKey{}).(http.RoundTripper)
+	return rt
+}
@@ -0,0 +1,225 @@
+package translator
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"hash/maphash"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// DefaultRequestCacheCapacity is the number of translated skeletons retained by the default registry.
+	DefaultRequestCacheCapacity = 256
+	// minCacheableRequestSize skips caching for small skeletons, where translating again is cheaper than hashing.
+	minCacheableRequestSize = 4 * 1024
+)
+
+// messagePaths locates the conversation of each inbound format. Everything else in the body
+// (system prompt, tools, generation settings) forms the skeleton, which usually stays the
+// same from one turn to the next.
+var messagePaths = map[Format]string{
+	"openai":          "messages",
+	"openai-response": "input",
+	"claude":          "messages",
+	"gemini":          "contents",
+	"gemini-cli":      "request.contents",
+}
+
+type requestCacheKey struct {
+	from   Format
+	to     Format
+	model  string
+	stream bool
+	sum    uint64
+}
+
+type requestCacheEntry struct {
+	key      requestCacheKey
+	skeleton []byte
+	// translated is the skeleton translated with an empty conversation, and empty is a body
+	// holding only an empty conversation translated; the fields in which a translated
+	// conversation differs from empty are the ones spliced into translated.
+	translated []byte
+	empty      []byte
+	// spliceable records whether splicing reproduced the full translation when the entry was
+	// created. Pairs whose message and skeleton translations interact are always translated whole.
+	spliceable bool
+}
+
+// requestCache is a fixed-size LRU of translated request skeletons keyed by a fast hash of
+// the inbound body without its conversation. Clients that resend the same large system prompt
+// and tools every turn only pay for translating the messages; the cached skeleton is reused
+// with the newly translated messages spliced in.
+type requestCache struct {
+	mu       sync.Mutex
+	seed     maphash.Seed
+	capacity int
+	order    *list.List
+	items    map[requestCacheKey]*list.Element
+}
+
+func newRequestCache(capacity int) *requestCache {
+	return &requestCache{
+		seed:     maphash.MakeSeed(),
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[requestCacheKey]*list.Element),
+	}
+}
+
+// translate runs fn on rawJSON, reusing the cached skeleton translation when the body differs
+// from an earlier one only in its conversation.
+func (c *requestCache) translate(fn RequestTransform, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	path, ok := messagePaths[from]
+	if !ok || len(rawJSON) < minCacheableRequestSize {
+		return fn(model, rawJSON, stream)
+	}
+	messages := gjson.GetBytes(rawJSON, path)
+	if !messages.Exists() {
+		return fn(model, rawJSON, stream)
+	}
+	skeleton, err := sjson.SetRawBytes(bytes.Clone(rawJSON), path, []byte("[]"))
+	if err != nil || len(skeleton) < minCacheableRequestSize {
+		return fn(model, rawJSON, stream)
+	}
+	onlyMessages, err := sjson.SetRawBytes([]byte("{}"), path, []byte(messages.Raw))
+	if err != nil {
+		return fn(model, rawJSON, stream)
+	}
+
+	key := requestCacheKey{from: from, to: to, model: model, stream: stream, sum: maphash.Bytes(c.seed, skeleton)}
+	if entry, hit := c.get(key, skeleton); hit {
+		if !entry.spliceable {
+			return fn(model, rawJSON, stream)
+		}
+		if out, okSplice := spliceMessages(entry.translated, entry.empty, fn(model, onlyMessages, stream)); okSplice {
+			return out
+		}
+		return fn(model, rawJSON, stream)
+	}
+
+	full := fn(model, rawJSON, stream)
+	emptyMessages, _ := sjson.SetRawBytes([]byte("{}"), path, []byte("[]"))
+	entry := &requestCacheEntry{
+		key:        key,
+		skeleton:   skeleton,
+		translated: bytes.Clone(fn(model, skeleton, stream)),
+		empty:      bytes.Clone(fn(model, emptyMessages, stream)),
+	}
+	if out, okSplice := spliceMessages(entry.translated, entry.empty, fn(model, onlyMessages, stream)); okSplice {
+		entry.spliceable = jsonEqual(out, full)
+	}
+	c.put(entry)
+	return full
+}
+
+func (c *requestCache) get(key requestCacheKey, skeleton []byte) (*requestCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*requestCacheEntry)
+	if !bytes.Equal(entry.skeleton, skeleton) {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+func (c *requestCache) put(entry *requestCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[entry.key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*requestCacheEntry).key)
+	}
+}
+
+// spliceMessages copies into skeleton every field in which messages differs from empty. It
+// reports false when the skeleton itself contributes to such a field, since the two parts
+// cannot then be combined by replacement.
+func spliceMessages(skeleton, empty, messages []byte) ([]byte, bool) {
+	out := bytes.Clone(skeleton)
+	ok := spliceObject(&out, gjson.ParseBytes(empty), gjson.ParseBytes(messages), "")
+	return out, ok
+}
+
+func spliceObject(out *[]byte, empty, messages gjson.Result, prefix string) bool {
+	keys := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, node := range []gjson.Result{empty, messages} {
+		node.ForEach(func(k, _ gjson.Result) bool {
+			if _, dup := seen[k.String()]; !dup {
+				seen[k.String()] = struct{}{}
+				keys = append(keys, k.String())
+			}
+			return true
+		})
+	}
+	for _, k := range keys {
+		path := prefix + escapePathKey(k)
+		before, after := empty.Get(escapePathKey(k)), messages.Get(escapePathKey(k))
+		if before.Raw == after.Raw {
+			continue
+		}
+		if before.IsObject() && after.IsObject() {
+			if !spliceObject(out, before, after, path+".") {
+				return false
+			}
+			continue
+		}
+		if current := gjson.GetBytes(*out, path); current.Raw != before.Raw {
+			return false
+		}
+		var err error
+		if after.Exists() {
+			*out, err = sjson.SetRawBytes(*out, path, []byte(after.Raw))
+		} else {
+			*out, err = sjson.DeleteBytes(*out, path)
+		}
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func escapePathKey(k string) string {
+	if !strings.ContainsAny(k, `.*?\|#@!`) {
+		return k
+	}
+	var b strings.Builder
+	for _, r := range k {
+		if strings.ContainsRune(`.*?\|#@!`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func jsonEqual(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -10,6 +10,7 @@ type Registry struct {
 	mu        sync.RWMutex
 	requests  map[Format]map[Format]RequestTransform
 	responses map[Format]map[Format]ResponseTransform
+	cache     *requestCache
 }
 
 // NewRegistry constructs an empty translator registry.
@@ -17,9 +18,26 @@ func NewRegistry() *Registry {
 	return &Registry{
 		requests:  make(map[Format]map[Format]RequestTransform),
 		responses: make(map[Format]map[Format]ResponseTransform),
+		cache:     newRequestCache(DefaultRequestCacheCapacity),
 	}
 }
 
+// SetRequestCacheCapacity resizes the translated request cache, dropping its entries when the
+// capacity changes. A capacity of zero or less disables caching.
+func (r *Registry) SetRequestCacheCapacity(capacity int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cache != nil && r.cache.capacity == capacity {
+		return
+	}
+	if capacity <= 0 {
+		r.cache = nil
+		return
+	}
+	r.cache = newRequestCache(capacity)
+}
+
 // Register stores request/response transforms between two formats.
 func (r *Registry) Register(from, to Format, request RequestTransform, response ResponseTransform) {
 	r.mu.Lock()
@@ -46,7 +64,10 @@ func (r *Registry) TranslateRequest(from, to Format, model string, rawJSON []byt
 
 	if byTarget, ok := r.requests[from]; ok {
 		if fn, isOk := byTarget[to]; isOk && fn != nil {
-			return fn(model, rawJSON, stream)
+			if r.cache == nil {
+				return fn(model, rawJSON, stream)
+			}
+			return r.cache.translate(fn, from, to, model, rawJSON, stream)
 		}
 	}
 	return rawJSON
@@ -116,6 +137,11 @@ func Register(from, to Format, request RequestTransform, response ResponseTransf
 	defaultRegistry.Register(from, to, request, response)
 }
 
+// SetRequestCacheCapacity resizes the translated request cache of the default registry.
+func SetRequestCacheCapacity(capacity int) {
+	defaultRegistry.SetRequestCacheCapacity(capacity)
+}
+
 // TranslateRequest is a helper on the default registry.
 func TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool) []byte {
 	return defaultRegistry.TranslateRequest(from, to, model, rawJSON, stream)